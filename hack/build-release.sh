@@ -32,19 +32,33 @@ mkdir -p "${S2I_LOCAL_RELEASEPATH}"
 s2i::build::get_version_vars
 s2i::build::save_version_vars "${S2I_ROOT}/sti-version-defs"
 
+# The checksums of the release are signed with the Ed25519 private key of the
+# S2I_RELEASE_SIGNING_KEY PEM file, whose public key is pinned in the binaries.
+if [[ -n "${S2I_RELEASE_SIGNING_KEY-}" ]]; then
+  S2I_RELEASE_KEY=$(openssl pkey -in "${S2I_RELEASE_SIGNING_KEY}" -pubout -outform DER | tail -c 32 | base64)
+fi
+
 echo "++ Building release ${S2I_GIT_VERSION}"
 
 # Perform the build and release in podman or docker.
 if [[ "$(go env GOHOSTOS)" == "darwin" ]]; then
-    $buildCmd run --rm -it -e RELEASE_LDFLAGS="-w -s" \
+    $buildCmd run --rm -it -e RELEASE_LDFLAGS="-w -s" -e S2I_RELEASE_KEY="${S2I_RELEASE_KEY-}" \
   -v "${S2I_ROOT}":/opt/app-root/src/source-to-image \
   openshift/sti-release
   else
-    $buildCmd run --rm -it -e RELEASE_LDFLAGS="-w -s" \
+    $buildCmd run --rm -it -e RELEASE_LDFLAGS="-w -s" -e S2I_RELEASE_KEY="${S2I_RELEASE_KEY-}" \
   -v "${S2I_ROOT}":/opt/app-root/src/source-to-image:z \
   openshift/sti-release
   fi
 
 echo "${S2I_GIT_COMMIT}" > "${S2I_LOCAL_RELEASEPATH}/.commit"
 
+if [[ -n "${S2I_RELEASE_SIGNING_KEY-}" ]]; then
+  echo "++ Signing SHA512-SUMS.txt"
+  openssl pkeyutl -sign -rawin -inkey "${S2I_RELEASE_SIGNING_KEY}" \
+    -in "${S2I_LOCAL_RELEASEPATH}/SHA512-SUMS.txt" -out "${S2I_LOCAL_RELEASEPATH}/SHA512-SUMS.txt.sig"
+else
+  echo "!! S2I_RELEASE_SIGNING_KEY is not set, the release cannot be updated to by s2i self-update"
+fi
+
 ret=$?; ENDTIME=$(date +%s); echo "$0 took $((ENDTIME - STARTTIME)) seconds"; exit "$ret"
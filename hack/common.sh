@@ -480,6 +480,12 @@ s2i::build::ldflags() {
     ldflags+=($(s2i::build::ldflag "minorFromGit" "${S2I_GIT_MINOR}"))
     ldflags+=($(s2i::build::ldflag "versionFromGit" "${S2I_GIT_VERSION}"))
     ldflags+=($(s2i::build::ldflag "commitFromGit" "${S2I_GIT_COMMIT}"))
+    ldflags+=($(s2i::build::ldflag "buildDate" "${S2I_BUILD_DATE:-$(date -u +'%Y-%m-%dT%H:%M:%SZ')}"))
+    # Pin the public key the checksums of the releases are signed with, which
+    # s2i self-update verifies them against.
+    if [[ -n "${S2I_RELEASE_KEY-}" ]]; then
+      ldflags+=("-X ${S2I_GO_PACKAGE}/pkg/selfupdate.releaseKey=${S2I_RELEASE_KEY}")
+    fi
     # The -ldflags parameter takes a single string, so join the output.
     echo "${ldflags[*]-}"
   )
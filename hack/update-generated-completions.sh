@@ -10,6 +10,9 @@ source "${S2I_ROOT}/hack/common.sh"
 
 s2i::build::build_binaries "$@"
 
-echo "+++ Updating Bash completion in contrib/bash/s2i"
+echo "+++ Updating the completions in contrib/completions"
+mkdir -p ${S2I_ROOT}/contrib/completions/fish ${S2I_ROOT}/contrib/completions/powershell
 ${S2I_LOCAL_BINPATH}/s2i completion bash> ${S2I_ROOT}/contrib/completions/bash/s2i
-${S2I_LOCAL_BINPATH}/s2i completion zsh> ${S2I_ROOT}/contrib/completions/zsh/s2i
\ No newline at end of file
+${S2I_LOCAL_BINPATH}/s2i completion zsh> ${S2I_ROOT}/contrib/completions/zsh/s2i
+${S2I_LOCAL_BINPATH}/s2i completion fish> ${S2I_ROOT}/contrib/completions/fish/s2i.fish
+${S2I_LOCAL_BINPATH}/s2i completion powershell> ${S2I_ROOT}/contrib/completions/powershell/s2i.ps1
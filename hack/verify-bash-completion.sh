@@ -9,15 +9,18 @@ source "${S2I_ROOT}/hack/common.sh"
 
 cd "${S2I_ROOT}"
 
-mv contrib/completions/bash/s2i contrib/completions/bash/s2i-proposed
-trap "mv contrib/completions/bash/s2i-proposed contrib/completions/bash/s2i" exit
-mv contrib/completions/zsh/s2i contrib/completions/zsh/s2i-proposed
-trap "mv contrib/completions/zsh/s2i-proposed contrib/completions/zsh/s2i" exit
+COMPLETIONS="contrib/completions/bash/s2i contrib/completions/zsh/s2i contrib/completions/fish/s2i.fish contrib/completions/powershell/s2i.ps1"
+
+for f in ${COMPLETIONS}; do
+  mv "${f}" "${f}-proposed"
+done
+trap 'for f in ${COMPLETIONS}; do mv "${f}-proposed" "${f}"; done' exit
 hack/update-generated-completions.sh
 
 ret=0
-diff -Naupr contrib/completions/bash/s2i contrib/completions/bash/s2i-proposed || ret=$?
-diff -Naupr contrib/completions/zsh/s2i contrib/completions/zsh/s2i-proposed || ret=$?
+for f in ${COMPLETIONS}; do
+  diff -Naupr "${f}" "${f}-proposed" || ret=$?
+done
 
 if [[ $ret -eq 0 ]]
 then
@@ -11,3 +11,26 @@ const (
 	// DEPRECATED - use ScriptsURLLabel instead.
 	ScriptsURLEnvironment = "STI_SCRIPTS_URL"
 )
+
+// Environment variables describing the sources, set for the S2I scripts.
+const (
+	// GitCommitEnvironment is the environment variable holding the ID of the
+	// commit built.
+	GitCommitEnvironment = "S2I_GIT_COMMIT"
+	// GitRefEnvironment is the environment variable holding the ref built.
+	GitRefEnvironment = "S2I_GIT_REF"
+	// GitAuthorEnvironment is the environment variable holding the author of
+	// the commit built, as "name <email>".
+	GitAuthorEnvironment = "S2I_GIT_AUTHOR"
+	// GitDateEnvironment is the environment variable holding the date of the
+	// commit built.
+	GitDateEnvironment = "S2I_GIT_DATE"
+	// SourceURLEnvironment is the environment variable holding the location of
+	// the sources.
+	SourceURLEnvironment = "S2I_SOURCE_URL"
+)
+
+// SaveArtifactsCompressionEnvironment is the environment variable telling the
+// save-artifacts script the compression to apply to the tar stream it writes,
+// among the ones listed by the SaveArtifactsCompressionLabel of the image.
+const SaveArtifactsCompressionEnvironment = "S2I_SAVE_ARTIFACTS_COMPRESSION"
@@ -62,6 +62,54 @@ const (
 	// ScriptsURLLabel is the Docker image LABEL that tells S2I where to look for the S2I scripts.
 	// This label is also copied into the output image.
 	ScriptsURLLabel = DefaultNamespace + "scripts-url"
+
+	// ReportsLabel is the Docker image LABEL that tells S2I the directory the assemble script writes
+	// its test reports (e.g. JUnit XML files) to. They are extracted from the build container, whether
+	// the build succeeds or fails, when a reports directory is requested.
+	ReportsLabel = DefaultNamespace + "reports"
+
+	// CacheMountLabel is the Docker volume LABEL that records the directory
+	// a dependency cache volume is mounted to in the assemble container.
+	CacheMountLabel = DefaultNamespace + "cache-mount"
+
+	// SaveArtifactsCompressionLabel is the Docker image LABEL that lists, comma-separated, the
+	// compressions (gzip, zstd) the save-artifacts script of the image can apply to the tar stream it
+	// writes, as requested by the S2I_SAVE_ARTIFACTS_COMPRESSION environment variable.
+	SaveArtifactsCompressionLabel = DefaultNamespace + "save-artifacts-compression"
+
+	// WorkerIDLabel is the Docker LABEL that records the worker ID of the build
+	// on the containers and temporary images created by S2I.
+	WorkerIDLabel = DefaultNamespace + "worker-id"
+
+	// InvocationIDLabel is the Docker LABEL that records the ID of the s2i
+	// invocation which created a container, an image or a volume.
+	InvocationIDLabel = DefaultNamespace + "invocation-id"
+
+	// BuildIDLabel is the Docker LABEL that records the ID of the build which
+	// created a container, an image or a volume.
+	BuildIDLabel = DefaultNamespace + "build-id"
+
+	// CreatorVersionLabel is the Docker LABEL that records the version of s2i
+	// which created a container, an image or a volume.
+	CreatorVersionLabel = DefaultNamespace + "creator-version"
+
+	// TemporaryLabel is the Docker image LABEL that marks the temporary images
+	// removed by "s2i cleanup". It is overridden with "false" in the images
+	// built on top of them.
+	TemporaryLabel = DefaultNamespace + "temporary"
+
+	// LayeredNamespace is the namespace for the Docker image labels S2I sets on the
+	// intermediate images produced by layered builds. These labels are not copied
+	// into the output image.
+	LayeredNamespace = DefaultNamespace + "layered."
+
+	// LayeredBuilderImageLabel is the Docker image LABEL that records the builder image
+	// an intermediate layered image was created from.
+	LayeredBuilderImageLabel = LayeredNamespace + "builder-image"
+
+	// LayeredTagLabel is the Docker image LABEL that records the tag of the image being
+	// built on top of an intermediate layered image.
+	LayeredTagLabel = LayeredNamespace + "tag"
 )
 
 // Deprecated Docker image labels
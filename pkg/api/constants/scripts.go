@@ -20,10 +20,18 @@ const (
 	// Usage is the name of the script responsible for printing the builder image's short info.
 	Usage = "usage"
 
+	// Reload is the name of the script reloading the application after its sources were synced into
+	// the running container by s2i dev --sync.
+	Reload = "reload"
+
 	// Environment contains list of key value pairs that will be set during the STI build.
 	// Users can use this file to provide extra configuration depending on the builder image used.
 	Environment = "environment"
 
+	// RuntimeEnvironment contains list of key value pairs that will be set only during the
+	// assemble-runtime phase and in the runtime image, instead of the ones of the build.
+	RuntimeEnvironment = "environment.runtime"
+
 	// UserScripts is the location of scripts downloaded from user provided URL (-s flag).
 	UserScripts = "downloads" + string(os.PathSeparator) + "scripts"
 
@@ -42,6 +50,10 @@ const (
 	// Injections is the location of injection content (configmaps+secrets).
 	Injections = "upload" + string(os.PathSeparator) + "injections"
 
+	// LiteralInjections is the location of the files written for the literal
+	// injections, kept out of the uploaded directory.
+	LiteralInjections = "literals"
+
 	// ContextTmp is the location of applications sources off of a supplied context dir
 	ContextTmp = "upload" + string(os.PathSeparator) + "tmp"
 
@@ -50,4 +62,7 @@ const (
 
 	// IgnoreFile is the s2i version for ignore files like we see with .gitignore or .dockerignore .. initial impl mirrors documented .dockerignore capabilities
 	IgnoreFile = ".s2iignore"
+
+	// GitIgnoreFile is the name of the git ignore files processed by the gitignore ignorer
+	GitIgnoreFile = ".gitignore"
 )
@@ -6,16 +6,25 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/docker"
+	"github.com/openshift/source-to-image/pkg/util"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
 )
 
 // Config returns the Config object in nice readable, tabbed format.
 func Config(client docker.Client, config *api.Config) string {
+	return configTable(client, config, nil)
+}
+
+// configTable returns the Config object in tabbed format, with the fields set
+// from the labels of the images and these labels.
+func configTable(client docker.Client, config *api.Config, fromLabels map[string]string) string {
 	out, err := tabbedString(func(out io.Writer) error {
 		if len(config.DisplayName) > 0 {
 			fmt.Fprintf(out, "Application Name:\t%s\n", config.DisplayName)
@@ -23,7 +32,7 @@ func Config(client docker.Client, config *api.Config) string {
 		if len(config.Description) > 0 {
 			fmt.Fprintf(out, "Description:\t%s\n", config.Description)
 		}
-		if len(config.AsDockerfile) == 0 {
+		if len(config.AsDockerfile) == 0 && client != nil {
 			describeBuilderImage(client, config, out)
 			describeRuntimeImage(config, out)
 		}
@@ -32,7 +41,7 @@ func Config(client docker.Client, config *api.Config) string {
 			fmt.Fprintf(out, "Context Directory:\t%s\n", config.ContextDir)
 		}
 		fmt.Fprintf(out, "Output Image Tag:\t%s\n", config.Tag)
-		printEnv(out, config.Environment)
+		printEnv(out, util.RedactEnvironment(config.Environment, config.RedactEnvPatterns))
 		if len(config.EnvironmentFile) > 0 {
 			fmt.Fprintf(out, "Environment File:\t%s\n", config.EnvironmentFile)
 		}
@@ -49,12 +58,15 @@ func Config(client docker.Client, config *api.Config) string {
 		if len(config.Destination) > 0 {
 			fmt.Fprintf(out, "Artifacts Destination:\t%s\n", config.Destination)
 		}
-		if len(config.CallbackURL) > 0 {
-			fmt.Fprintf(out, "Callback URL:\t%s\n", config.CallbackURL)
+		for _, callbackURL := range config.CallbackURLs {
+			fmt.Fprintf(out, "Callback URL:\t%s\n", callbackURL)
 		}
 		if len(config.ScriptsURL) > 0 {
 			fmt.Fprintf(out, "S2I Scripts URL:\t%s\n", config.ScriptsURL)
 		}
+		if len(config.AssembleUser) > 0 {
+			fmt.Fprintf(out, "Assemble User:\t%s\n", config.AssembleUser)
+		}
 		if len(config.WorkingDir) > 0 {
 			fmt.Fprintf(out, "Workdir:\t%s\n", config.WorkingDir)
 		}
@@ -63,7 +75,7 @@ func Config(client docker.Client, config *api.Config) string {
 		}
 		fmt.Fprintf(out, "Docker Endpoint:\t%s\n", config.DockerConfig.Endpoint)
 
-		if _, err := os.Open(config.DockerCfgPath); err == nil {
+		if _, err := os.Open(hostpath.ExpandHome(config.DockerCfgPath)); err == nil {
 			fmt.Fprintf(out, "Docker Pull Config:\t%s\n", config.DockerCfgPath)
 			fmt.Fprintf(out, "Docker Pull User:\t%s\n", config.PullAuthentication.Username)
 		}
@@ -75,6 +87,13 @@ func Config(client docker.Client, config *api.Config) string {
 			}
 			fmt.Fprintf(out, "Injections:\t%s\n", strings.Join(result, ","))
 		}
+		if len(config.LiteralInjections) > 0 {
+			result := []string{}
+			for _, i := range config.LiteralInjections {
+				result = append(result, fmt.Sprintf("%s->%s", i.Name, i.Destination))
+			}
+			fmt.Fprintf(out, "Literal Injections:\t%s\n", strings.Join(result, ","))
+		}
 		if len(config.BuildVolumes) > 0 {
 			result := []string{}
 			for _, i := range config.BuildVolumes {
@@ -87,6 +106,10 @@ func Config(client docker.Client, config *api.Config) string {
 			}
 			fmt.Fprintf(out, "Bind mounts:\t%s\n", strings.Join(result, ","))
 		}
+		if len(config.Caches) > 0 {
+			fmt.Fprintf(out, "Caches:\t%s\n", config.Caches.String())
+		}
+		printFromLabels(out, fromLabels)
 		return nil
 	})
 
@@ -134,6 +157,12 @@ func describeRuntimeImage(config *api.Config, out io.Writer) {
 	if len(config.RuntimeAuthentication.Username) > 0 {
 		fmt.Fprintf(out, "Runtime Image Pull User:\t%s\n", config.RuntimeAuthentication.Username)
 	}
+	if len(config.RuntimeArtifacts) > 0 {
+		fmt.Fprintf(out, "Runtime Artifacts:\t%s\n", config.RuntimeArtifacts.String())
+	}
+	if len(config.AssembleRuntimeUser) > 0 {
+		fmt.Fprintf(out, "Assemble Runtime User:\t%s\n", config.AssembleRuntimeUser)
+	}
 }
 
 func printEnv(out io.Writer, env api.EnvironmentList) {
@@ -152,6 +181,18 @@ func printLabels(out io.Writer, labels map[string]string) {
 	fmt.Fprintf(out, "Labels:\t%s\n", strings.Join(result, ","))
 }
 
+func printFromLabels(out io.Writer, fromLabels map[string]string) {
+	if len(fromLabels) == 0 {
+		return
+	}
+	result := []string{}
+	for field, label := range fromLabels {
+		result = append(result, fmt.Sprintf("%s=%s", field, label))
+	}
+	sort.Strings(result)
+	fmt.Fprintf(out, "From Image Labels:\t%s\n", strings.Join(result, ","))
+}
+
 func printBool(b bool) string {
 	if b {
 		return "\033[1menabled\033[0m"
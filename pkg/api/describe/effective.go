@@ -0,0 +1,306 @@
+package describe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	v1 "github.com/openshift/source-to-image/pkg/api/v1"
+	"github.com/openshift/source-to-image/pkg/docker"
+	"github.com/openshift/source-to-image/pkg/util"
+)
+
+// The formats the configuration is described in.
+const (
+	// FormatTable is the human readable, tabbed format of Config.
+	FormatTable = "table"
+	// FormatJSON is the JSON document of the EffectiveConfig.
+	FormatJSON = "json"
+	// FormatYAML is the YAML document of the EffectiveConfig.
+	FormatYAML = "yaml"
+)
+
+// Formats are the formats the configuration is described in.
+var Formats = []string{FormatTable, FormatJSON, FormatYAML}
+
+// redacted replaces the secrets of the described configuration.
+const redacted = "REDACTED"
+
+// EffectiveConfig is the configuration a build runs with, in the versioned
+// schema, with the secrets it holds redacted.
+type EffectiveConfig struct {
+	*v1.Config
+
+	// BuilderImageVersion and BuilderBaseImageVersion are the versions of the
+	// builder image and of its base image given by its labels.
+	BuilderImageVersion     string `json:"builderImageVersion,omitempty"`
+	BuilderBaseImageVersion string `json:"builderBaseImageVersion,omitempty"`
+
+	// PullAuthentication, IncrementalAuthentication and RuntimeAuthentication
+	// are the registry credentials the images are pulled with.
+	PullAuthentication        *Authentication `json:"pullAuthentication,omitempty"`
+	IncrementalAuthentication *Authentication `json:"incrementalAuthentication,omitempty"`
+	RuntimeAuthentication     *Authentication `json:"runtimeAuthentication,omitempty"`
+
+	// LiteralInjections are the files injected with their content given on
+	// the command line.
+	LiteralInjections []LiteralInjection `json:"literalInjections,omitempty"`
+
+	// FromLabels maps the fields the labels of the builder and runtime images
+	// set to these labels.
+	FromLabels map[string]string `json:"fromLabels,omitempty"`
+}
+
+// Authentication describes registry credentials, without their secret.
+type Authentication struct {
+	Username      string `json:"username,omitempty"`
+	ServerAddress string `json:"serverAddress,omitempty"`
+	Password      string `json:"password,omitempty"`
+}
+
+// LiteralInjection describes an injected file, without its content.
+type LiteralInjection struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Value       string `json:"value"`
+}
+
+// ValidateFormat returns an error if the configuration cannot be described
+// in the given format.
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format %q, valid values are: %s", format, strings.Join(Formats, ", "))
+}
+
+// Resolve returns a copy of the configuration with the settings it leaves to
+// the labels of the builder and runtime images set from these labels, as the
+// build does, and a map of the fields set to the labels they come from. The
+// images are pulled according to the pull policies. Nothing is resolved
+// without a client, or when the build is written as a Dockerfile.
+func Resolve(client docker.Client, config *api.Config) (*api.Config, map[string]string, error) {
+	resolved := *config
+	fromLabels := map[string]string{}
+	if client == nil || len(config.AsDockerfile) > 0 {
+		return &resolved, fromLabels, nil
+	}
+
+	resolve := func(labels map[string]string, field string, value *string, names ...string) {
+		if len(*value) > 0 {
+			return
+		}
+		for _, name := range names {
+			if v := labels[name]; len(v) > 0 {
+				*value = v
+				fromLabels[field] = name
+				return
+			}
+		}
+	}
+
+	builder, err := docker.GetBuilderImage(docker.New(client, config.PullAuthentication), config)
+	if err != nil {
+		return nil, nil, err
+	}
+	labels := imageLabels(builder.Image)
+	resolved.BuilderImageVersion = labels[constants.BuilderVersionLabel]
+	resolved.BuilderBaseImageVersion = labels[constants.BuilderBaseVersionLabel]
+	resolve(labels, "scriptsURL", &resolved.ScriptsURL, constants.ScriptsURLLabel, constants.DeprecatedScriptsURLLabel)
+	resolve(labels, "destination", &resolved.Destination, constants.DestinationLabel, constants.DeprecatedDestinationLabel)
+	if len(resolved.Destination) == 0 {
+		resolved.Destination = docker.DefaultDestination
+	}
+	resolve(labels, "assembleUser", &resolved.AssembleUser, constants.AssembleUserLabel)
+
+	if len(config.RuntimeImage) == 0 {
+		return &resolved, fromLabels, nil
+	}
+	dkr := docker.New(client, config.RuntimeAuthentication)
+	if err := docker.GetRuntimeImage(dkr, config); err != nil {
+		return nil, nil, err
+	}
+	labels, err = dkr.GetLabels(config.RuntimeImage)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolve(labels, "assembleRuntimeUser", &resolved.AssembleRuntimeUser, constants.AssembleRuntimeUserLabel)
+	if mapping := labels[constants.AssembleInputFilesLabel]; len(resolved.RuntimeArtifacts) == 0 && len(mapping) > 0 {
+		if err := resolved.RuntimeArtifacts.Set(mapping); err != nil {
+			return nil, nil, fmt.Errorf("could not parse %q label with value %q on image %q: %v",
+				constants.AssembleInputFilesLabel, mapping, config.RuntimeImage, err)
+		}
+		fromLabels["runtimeArtifacts"] = constants.AssembleInputFilesLabel
+	}
+	return &resolved, fromLabels, nil
+}
+
+// imageLabels returns the labels of the image, which may have none.
+func imageLabels(image *api.Image) map[string]string {
+	if image == nil || image.Config == nil {
+		return map[string]string{}
+	}
+	return image.Config.Labels
+}
+
+// Effective returns the effective configuration of the given configuration,
+// where fromLabels maps the fields set from the labels of the images to these
+// labels. The passwords of the URLs and of the registry credentials, the
+// content of the literal injections and the values of the environment
+// variables matching the redaction patterns are redacted.
+func Effective(config *api.Config, fromLabels map[string]string) *EffectiveConfig {
+	effective := &EffectiveConfig{
+		Config:                    v1.FromInternal(config),
+		BuilderImageVersion:       config.BuilderImageVersion,
+		BuilderBaseImageVersion:   config.BuilderBaseImageVersion,
+		PullAuthentication:        authentication(config.PullAuthentication),
+		IncrementalAuthentication: authentication(config.IncrementalAuthentication),
+		RuntimeAuthentication:     authentication(config.RuntimeAuthentication),
+	}
+	if len(fromLabels) > 0 {
+		effective.FromLabels = fromLabels
+	}
+	for _, i := range config.LiteralInjections {
+		effective.LiteralInjections = append(effective.LiteralInjections, LiteralInjection{Name: i.Name, Destination: i.Destination, Value: redacted})
+	}
+
+	c := effective.Config
+	c.Source = redactURL(c.Source)
+	for i := range c.Sources {
+		c.Sources[i].Source = redactURL(c.Sources[i].Source)
+	}
+	c.ScriptsURL = redactURL(c.ScriptsURL)
+	c.ImageScriptsURL = redactURL(c.ImageScriptsURL)
+	for name, scriptURL := range c.ScriptURLs {
+		c.ScriptURLs[name] = redactURL(scriptURL)
+	}
+	if c.ScriptDownloadProxyConfig != nil {
+		c.ScriptDownloadProxyConfig.HTTPProxy = redactURL(c.ScriptDownloadProxyConfig.HTTPProxy)
+		c.ScriptDownloadProxyConfig.HTTPSProxy = redactURL(c.ScriptDownloadProxyConfig.HTTPSProxy)
+	}
+	c.CallbackURL = redactURL(c.CallbackURL)
+	for i := range c.CallbackURLs {
+		c.CallbackURLs[i] = redactURL(c.CallbackURLs[i])
+	}
+	for i, header := range c.CallbackHeaders {
+		if name, _, ok := strings.Cut(header, ":"); ok {
+			c.CallbackHeaders[i] = name + ": " + redacted
+		}
+	}
+	c.TelemetryEndpoint = redactURL(c.TelemetryEndpoint)
+	for _, env := range [][]v1.EnvironmentSpec{c.Environment, c.RuntimeEnvironment, c.BuildArgs, c.RunEnvironment} {
+		for i := range env {
+			if util.IsRedactedEnv(env[i].Name, config.RedactEnvPatterns) {
+				env[i].Value = redacted
+			}
+		}
+	}
+	return effective
+}
+
+// authentication describes the registry credentials, which is nil when there
+// are none.
+func authentication(auth api.AuthConfig) *Authentication {
+	if len(auth.Username) == 0 && len(auth.Password) == 0 && len(auth.IdentityToken) == 0 {
+		return nil
+	}
+	a := &Authentication{Username: auth.Username, ServerAddress: auth.ServerAddress}
+	if len(auth.Password) > 0 || len(auth.IdentityToken) > 0 {
+		a.Password = redacted
+	}
+	return a
+}
+
+// redactURL replaces the password of the URL, the user of the HTTP URLs
+// without a password, which is then a token, and the values of its query,
+// leaving the values which are not URLs with credentials, e.g. the SSH
+// sources, as they are.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	redactedURL := false
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			redactedURL = true
+		} else if len(u.User.Username()) > 0 && (u.Scheme == "http" || u.Scheme == "https") {
+			u.User = url.User(redacted)
+			redactedURL = true
+		}
+	}
+	if len(u.RawQuery) > 0 {
+		query := u.Query()
+		for name := range query {
+			query[name] = []string{redacted}
+		}
+		u.RawQuery = query.Encode()
+		redactedURL = true
+	}
+	if !redactedURL {
+		return value
+	}
+	return u.String()
+}
+
+// ConfigAs returns the configuration in the given format: the tabbed format
+// of Config, or the JSON or YAML document of its EffectiveConfig. fromLabels
+// maps the fields set from the labels of the images, as returned by Resolve,
+// to these labels.
+func ConfigAs(client docker.Client, config *api.Config, fromLabels map[string]string, format string) (string, error) {
+	if err := ValidateFormat(format); err != nil {
+		return "", err
+	}
+	if format == FormatTable {
+		return configTable(client, config, fromLabels), nil
+	}
+	data, err := json.MarshalIndent(Effective(config, fromLabels), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if format == FormatJSON {
+		return string(data) + "\n", nil
+	}
+	data, err = toYAML(data)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// toYAML converts the JSON document to a YAML document in block style, which
+// keeps the order of its fields.
+func toYAML(data []byte) ([]byte, error) {
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+	var blockStyle func(n *yaml.Node)
+	blockStyle = func(n *yaml.Node) {
+		n.Style = 0
+		for _, c := range n.Content {
+			blockStyle(c)
+		}
+	}
+	blockStyle(node)
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -40,3 +40,14 @@ func RecordStageAndStepInfo(stages []StageInfo, stageName StageName, stepName St
 	})
 	return stages
 }
+
+// MergeStageInfo records the steps of the other stages into the given stages.
+func MergeStageInfo(stages []StageInfo, other []StageInfo) []StageInfo {
+	for _, stage := range other {
+		for _, step := range stage.Steps {
+			endTime := step.StartTime.Add(time.Duration(step.DurationMilliseconds) * time.Millisecond)
+			stages = RecordStageAndStepInfo(stages, stage.Name, step.Name, step.StartTime, endTime)
+		}
+	}
+	return stages
+}
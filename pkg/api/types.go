@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
 	"github.com/openshift/source-to-image/pkg/util/user"
 )
@@ -29,6 +32,10 @@ const (
 	// PullIfNotPresent means that we pull if the image isn't present on disk.
 	PullIfNotPresent PullPolicy = "if-not-present"
 
+	// PullIfChanged means that we pull if the image isn't present on disk or
+	// if its digest in the registry differs from the one of the image on disk.
+	PullIfChanged PullPolicy = "if-changed"
+
 	// DefaultBuilderPullPolicy specifies the default pull policy to use
 	DefaultBuilderPullPolicy = PullIfNotPresent
 
@@ -40,6 +47,27 @@ const (
 	DefaultPreviousImagePullPolicy = PullIfNotPresent
 )
 
+// DefaultShutdownGracePeriod is the default time given to the build containers
+// to exit after a termination signal, shorter than the 30 seconds Kubernetes
+// gives to the pods so that s2i can clean up before it is killed.
+const DefaultShutdownGracePeriod = 10 * time.Second
+
+// DefaultCallbackRetries is the default number of times the callback is
+// retried when it cannot be delivered.
+const DefaultCallbackRetries = 2
+
+// DefaultCommitRetries is the default number of times the commit of the
+// resulting image is retried when it fails.
+const DefaultCommitRetries = 2
+
+// DefaultInjectionParallelism is the default number of injections uploaded at
+// the same time into the container that runs assemble.
+const DefaultInjectionParallelism = 4
+
+// DefaultRedactEnvPatterns are the default patterns of the names of the
+// environment variables whose values are redacted.
+var DefaultRedactEnvPatterns = []string{"*TOKEN*", "*PASSWORD*", "*SECRET*"}
+
 // Config contains essential fields for performing build.
 type Config struct {
 	// DisplayName is a result image display-name label. This defaults to the
@@ -73,7 +101,8 @@ type Config struct {
 
 	// RuntimeArtifacts specifies a list of source/destination pairs that will
 	// be copied from builder to a runtime image. Source can be a file or
-	// directory. Destination must be a directory. Regardless whether it
+	// directory, or a glob pattern matching them, e.g. /opt/app/libs/*.jar.
+	// Destination must be a directory. Regardless whether it
 	// is an absolute or relative path, it will be placed into image's WORKDIR.
 	// Destination also can be empty or equals to ".", in this case it just
 	// refers to a root of WORKDIR.
@@ -81,6 +110,17 @@ type Config struct {
 	// io.openshift.s2i.assemble-input-files label on a RuntimeImage.
 	RuntimeArtifacts VolumeList
 
+	// DryRun runs the assemble script of a build with a RuntimeImage, and
+	// reports the RuntimeArtifacts which would be copied to the runtime image,
+	// without building it.
+	DryRun bool
+
+	// ReportsDir is the local directory the test reports written by the
+	// assemble script, in the directory named by the io.openshift.s2i.reports
+	// label of the builder image, are extracted to, whether the build
+	// succeeds or fails.
+	ReportsDir string
+
 	// DockerConfig describes how to access host docker daemon.
 	DockerConfig *DockerConfig
 
@@ -95,6 +135,17 @@ type Config struct {
 	// previous image from private repositories
 	IncrementalAuthentication AuthConfig
 
+	// PullCredentials, IncrementalCredentials and RuntimeCredentials override
+	// the credentials of the Docker configuration file for pulling the builder
+	// image, the previous image and the runtime image.
+	PullCredentials        RegistryCredentials
+	IncrementalCredentials RegistryCredentials
+	RuntimeCredentials     RegistryCredentials
+
+	// PushCredentials are the credentials the kubernetes executor pushes the
+	// image with, instead of the ones of its push secret.
+	PushCredentials RegistryCredentials
+
 	// DockerNetworkMode is used to set the docker network setting to --net=container:<id>
 	// when the builder is invoked from a container.
 	DockerNetworkMode DockerNetworkMode
@@ -102,16 +153,55 @@ type Config struct {
 	// PreserveWorkingDir describes if working directory should be left after processing.
 	PreserveWorkingDir bool
 
+	// KeepLayeredImage describes if the intermediate image produced by a layered
+	// build should be left after processing, for debugging purposes.
+	KeepLayeredImage bool
+
 	// IgnoreSubmodules determines whether we will attempt to pull in submodules
 	// (via --recursive or submodule init)
 	IgnoreSubmodules bool
 
+	// PartialClone clones the sources without their blobs, which the checkout
+	// fetches on demand, when the Git server supports it.
+	PartialClone bool
+
+	// VerifyCommitSignature verifies the GPG signature of the commit, or of
+	// the annotated tag, checked out, failing the build when it is invalid.
+	VerifyCommitSignature bool
+
+	// Keyring is the file of the public keys trusted to sign the commit, or the
+	// tag, when verifying its signature. The default GPG keyring is used when
+	// it is empty.
+	Keyring string
+
 	// Source URL describing the location of sources used to build the result image.
 	Source *git.URL
 
+	// ArtifactPath is the path of a prebuilt artifact, a file or a directory,
+	// uploaded for the assemble script instead of the sources of a binary
+	// build, which has no Source.
+	ArtifactPath string
+
+	// Sources lists additional sources, such as a separately versioned
+	// configuration repository, downloaded into subdirectories of the sources
+	// of the build.
+	Sources SourceList
+
 	// Tag is a result image tag name.
 	Tag string
 
+	// AdditionalTags are the tags applied to the result image besides Tag.
+	AdditionalTags []string
+
+	// TagTemplates are Go templates of tags applied to the result image
+	// besides Tag, rendered with the repository and tag of Tag and the
+	// commit of the sources, e.g. {{.Repo}}:{{.GitShortSHA}}.
+	TagTemplates []string
+
+	// AutoTag derives the tag part of Tag from the state of the source
+	// repository.
+	AutoTag AutoTagMode
+
 	// BuilderPullPolicy specifies when to pull the builder image
 	BuilderPullPolicy PullPolicy
 
@@ -133,24 +223,77 @@ type Config struct {
 	// Environment is a map of environment variables to be passed to the image.
 	Environment EnvironmentList
 
+	// RuntimeEnvironment lists the environment variables passed to the
+	// assemble-runtime script and committed to the runtime image, instead of
+	// the Environment of the build, in addition to the ones of the
+	// .s2i/environment.runtime file.
+	RuntimeEnvironment EnvironmentList
+
 	// EnvironmentFile provides the path to a file with list of environment
-	// variables.
+	// variables, in the dotenv format or, with the .yaml or .yml extension, as
+	// a YAML map.
 	EnvironmentFile string
 
+	// StrictEnvironmentFile fails the build when the environment file has a
+	// malformed line, instead of skipping it.
+	StrictEnvironmentFile bool
+
+	// RedactEnvPatterns are the glob patterns, matched case insensitively, of
+	// the names of the environment variables whose values are redacted from the
+	// described configuration, the logs and the saved .s2ifile.
+	RedactEnvPatterns []string
+
 	// LabelNamespace provides the namespace under which the labels will be generated.
 	LabelNamespace string
 
-	// CallbackURL is a URL which is called upon successful build to inform about that fact.
-	CallbackURL string
+	// CallbackURLs are the URLs which are called upon completion of the build
+	// to inform about its result.
+	CallbackURLs []string
+
+	// CallbackEvents are the lifecycle events of the build, besides its
+	// completion, the callback URLs are called upon.
+	CallbackEvents []string
+
+	// CallbackRetries is the number of times the callback is retried, with an
+	// exponential backoff, when it cannot be delivered.
+	CallbackRetries int
+
+	// CallbackTimeout is the time each attempt to call the callback URL is
+	// given, or 0 for no timeout.
+	CallbackTimeout time.Duration
+
+	// CallbackHeaders are the headers, in the "Name: value" form, added to the
+	// callback requests, e.g. to authenticate them with a token.
+	CallbackHeaders []string
+
+	// CallbackCertFile and CallbackKeyFile are the client certificate and key
+	// the callback requests are authenticated with, and CallbackCAFile the
+	// certificate authority verifying the server of the callback URL.
+	CallbackCertFile string
+	CallbackKeyFile  string
+	CallbackCAFile   string
 
 	// ScriptsURL is a URL describing where to fetch the S2I scripts from during build process.
 	// This url can be a reference within the builder image if the scheme is specified as image://
 	ScriptsURL string
 
+	// ScriptURLs maps the names of individual S2I scripts to the URLs to fetch
+	// them from, taking precedence over ScriptsURL, the sources and the builder
+	// image labels.
+	ScriptURLs map[string]string
+
 	// BuilderImageLabels is a map containing the builder image labels for possible adjustment of fields
 	// on this object.
 	BuilderImageLabels map[string]string
 
+	// BuilderImageUser is the user of the builder image, inspected in its
+	// registry along with BuilderImageLabels.
+	BuilderImageUser string
+
+	// BuilderImageID is the ID of the builder image, inspected along with
+	// BuilderImageLabels.
+	BuilderImageID string
+
 	// Destination specifies a location where the untar operation will place its artifacts.
 	Destination string
 
@@ -164,10 +307,29 @@ type Config struct {
 	// LayeredBuild describes if this is build which layered scripts and sources on top of BuilderImage.
 	LayeredBuild bool
 
+	// LayeredFallback selects when the build layers the scripts and sources on
+	// top of BuilderImage instead of uploading them into the assemble container.
+	LayeredFallback LayeredFallbackMode
+
+	// Strategy selects the strategy building the image, which is selected
+	// from the builder image and --as-dockerfile when empty or auto, and is
+	// set to the selected strategy by the build.
+	Strategy BuildStrategy
+
+	// SkipDiskCheck skips checking, before the images are pulled and the
+	// sources are fetched, that the file systems have room for them.
+	SkipDiskCheck bool
+
 	// Operate quietly. Progress and assemble script output are not reported, only fatal errors.
 	// (default: false).
 	Quiet bool
 
+	// Progress selects how the progress of the build is displayed.
+	Progress ProgressMode
+
+	// Color selects whether the prefixes of the logged lines are colored.
+	Color ColorMode
+
 	// ForceCopy results in only the file SCM plugin being used (i.e. no `git clone`); allows for empty directories to be included
 	// in resulting image (since git does not support that).
 	// (default: false).
@@ -189,14 +351,46 @@ type Config struct {
 	// can see if it operates as he would expect
 	RunImage bool
 
+	// RunPublish lists the ports of the container published on the host when
+	// the produced image is run, in the ip:hostPort:containerPort/protocol
+	// format of docker run --publish. When empty, all the ports exposed by the
+	// image are published on random host ports.
+	RunPublish []string
+
+	// RunEnvironment is the environment of the container running the produced
+	// image.
+	RunEnvironment EnvironmentList
+
+	// RunDetach leaves the container running the produced image in the
+	// background instead of streaming its output until it exits.
+	RunDetach bool
+
 	// Usage allows for properly shortcircuiting s2i logic when `s2i usage` is invoked
 	Usage bool
 
+	// UsageMode selects how `s2i usage` shows the usage script.
+	UsageMode UsageMode
+
 	// Injections specifies a list source/destination folders that are injected to
 	// the container that runs assemble.
 	// All files we inject will be truncated after the assemble script finishes.
 	Injections VolumeList
 
+	// LiteralInjections specifies a list of files, with their content provided
+	// inline, that are injected to the container that runs assemble. They are
+	// truncated after the assemble script finishes, like the Injections.
+	LiteralInjections LiteralInjectionList
+
+	// KeepInjections lists the absolute paths, in the container that runs
+	// assemble, of the injected files, or the directories of injected files,
+	// that are not truncated and thus remain in the resulting image.
+	KeepInjections []string
+
+	// InjectionParallelism is the number of injections uploaded at the same
+	// time into the container that runs assemble. The injections are uploaded
+	// one by one when it is not greater than 1.
+	InjectionParallelism int
+
 	// CGroupLimits describes the cgroups limits that will be applied to any containers
 	// run by s2i.
 	CGroupLimits *CGroupLimits
@@ -208,6 +402,15 @@ type Config struct {
 	// to use when downloading scripts
 	ScriptDownloadProxyConfig *ProxyConfig
 
+	// DownloadCacheDir is the directory the downloads over http(s) are cached
+	// in, revalidated with their ETag and resumed when interrupted. Downloads
+	// are not cached when it is empty.
+	DownloadCacheDir string
+
+	// DownloadTimeout is the time each attempt of a download over http(s) is
+	// allowed to take, or 0 for no timeout.
+	DownloadTimeout time.Duration
+
 	// ExcludeRegExp contains a string representation of the regular expression desired for
 	// deciding which files to exclude from the tar stream
 	ExcludeRegExp string
@@ -221,10 +424,25 @@ type Config struct {
 	// HasOnBuild will be set to true if the builder image contains ONBUILD instructions
 	HasOnBuild bool
 
+	// AllowOnBuild confirms that the ONBUILD instructions of the builder image
+	// are executed by a docker build of the onbuild strategy, which otherwise
+	// fails the builds of the images with ONBUILD instructions.
+	AllowOnBuild bool
+
+	// OnBuildAllowlist are the regular expressions the ONBUILD instructions
+	// of the builder image must match for them to be executed. Any instruction
+	// is allowed when there are none.
+	OnBuildAllowlist []string
+
 	// BuildVolumes specifies a list of volumes to mount to container running the
 	// build.
 	BuildVolumes []string
 
+	// Caches are the dependency caches mounted in the container running the
+	// assemble script, each one a volume named after the hash of its key
+	// files so that it is reused by the builds of the same dependencies.
+	Caches CacheList
+
 	// Labels specify labels and their values to be applied to the resulting image. Label keys
 	// must have non-zero length. The labels defined here override generated labels in case
 	// they have the same name.
@@ -256,6 +474,209 @@ type Config struct {
 
 	// AssembleRuntimeUser specifies the user to run the assemble-runtime script in container
 	AssembleRuntimeUser string
+
+	// ContextCompression specifies the compression applied to the build context
+	// sent to the container engine when a docker build is performed (layered and
+	// ONBUILD builds). Defaults to no compression.
+	ContextCompression Compression
+
+	// RestoreArtifacts is the archive of build artifacts, written by s2i
+	// save-artifacts, provided to the assemble script instead of the artifacts
+	// of the previous image.
+	RestoreArtifacts string
+
+	// SaveArtifactsCompression specifies the compression requested from the
+	// save-artifacts script of incremental builds, among the ones its image
+	// supports. Defaults to no compression.
+	SaveArtifactsCompression Compression
+
+	// SymlinkPolicy specifies how symbolic links pointing outside of the
+	// uploaded source tree are handled. Defaults to preserving them as-is.
+	SymlinkPolicy SymlinkPolicy
+
+	// ConvertCRLF selects the files whose CRLF line endings are converted to
+	// LF when they are uploaded. Defaults to no conversion.
+	ConvertCRLF CRLFConversion
+
+	// Ignorers lists the ignore file processors applied to the source tree, in
+	// order. Defaults to processing the .s2iignore file only.
+	Ignorers []string
+
+	// ExcludeGlobs lists gitignore style patterns of files excluded from the
+	// build, in addition to ExcludeRegExp.
+	ExcludeGlobs []string
+
+	// IncludeGlobs lists gitignore style patterns of files included in the
+	// build even though they match one of the ExcludeGlobs.
+	IncludeGlobs []string
+
+	// RenderTemplates lists gitignore style patterns of the files of the
+	// sources rendered as Go templates, with the environment of the build,
+	// before the sources are uploaded.
+	RenderTemplates []string
+
+	// BuildArgs lists the build-time variables passed to the container engine
+	// when a docker build is performed (layered and ONBUILD builds) and
+	// declared as ARG in Dockerfiles generated with AsDockerfile.
+	BuildArgs BuildArgList
+
+	// Scanner is the vulnerability scanner run against the resulting image,
+	// if any.
+	Scanner Scanner
+
+	// ScanSeverityThreshold fails the build when the vulnerability scan finds
+	// a vulnerability of this severity or higher. When empty, the findings of
+	// the scan are only reported.
+	ScanSeverityThreshold Severity
+
+	// PostCommitCommand is the smoke test of the resulting image: a shell
+	// command run in a container of the image, once it is started, which fails
+	// the build and removes the image when it does not succeed.
+	PostCommitCommand string
+
+	// PostCommitTimeout is the time given to the PostCommitCommand to succeed,
+	// as it is retried while the application starts. Defaults to a minute.
+	PostCommitTimeout time.Duration
+
+	// VerifyRunScript runs a container of the resulting image, with its
+	// entrypoint and command, for the VerifyRunScriptPeriod, and fails the
+	// build and removes the image when the container does not start or exits
+	// with an error within it.
+	VerifyRunScript bool
+
+	// VerifyRunScriptPeriod is the time the container of VerifyRunScript has
+	// to keep running. Defaults to five seconds.
+	VerifyRunScriptPeriod time.Duration
+
+	// ShutdownGracePeriod is the time given to the build containers to exit
+	// after the termination signal received by s2i is forwarded to them,
+	// before they are killed.
+	ShutdownGracePeriod time.Duration
+
+	// DiffReport prints, once the image is built, the files added, removed
+	// and changed in its working directory and the size of its layers compared
+	// to the previous image of its tag.
+	DiffReport bool
+
+	// MaxBuildDuration is the wall clock time after which the build is
+	// aborted. Zero means no limit.
+	MaxBuildDuration time.Duration
+
+	// BuildDeadline is the time the containers of the build are stopped at,
+	// set from MaxBuildDuration when the build starts. Zero means no limit.
+	BuildDeadline time.Time
+
+	// MaxOutputSize is the maximum size, in megabytes, of the layer committed
+	// by the build. A larger image is removed before it is tagged. Zero means
+	// no limit.
+	MaxOutputSize int64
+
+	// CommitRetries is the number of times the commit of the resulting image
+	// is retried, with an exponential backoff, when it fails.
+	CommitRetries int
+
+	// CommitExportFallback creates the resulting image by exporting the file
+	// system of the container and importing it, flattened into a single
+	// layer, when its commit still fails after the CommitRetries.
+	CommitExportFallback bool
+
+	// PolicyDir is the directory of the Open Policy Agent Rego policies
+	// evaluated against the configuration and the builder image before the
+	// build starts. The build is rejected when the data.s2i.deny set of the
+	// policies is not empty.
+	PolicyDir string
+
+	// LockFile is the lockfile the inputs resolved by the build (image IDs,
+	// source commit, scripts and environment) are written to.
+	LockFile string
+
+	// Locked fails the build when the resolved inputs differ from the ones
+	// recorded in the LockFile, instead of updating it.
+	Locked bool
+
+	// Hermetic runs the assemble scripts without network, auditing the
+	// addresses they try to reach through a seccomp notify profile, and fails
+	// the build when outbound access was attempted.
+	Hermetic bool
+
+	// ComposeFile is the Docker Compose file a service running the produced
+	// image is added to, or updated in, once the build succeeds.
+	ComposeFile string
+
+	// ComposeService is the name of the service added to the ComposeFile.
+	ComposeService string
+
+	// Executor is where the build is executed: by the local container engine,
+	// or in a pod of a Kubernetes cluster.
+	Executor Executor
+
+	// Kubernetes configures the pods running the builds of the kubernetes
+	// executor.
+	Kubernetes KubernetesConfig
+
+	// TelemetryEndpoint is the URL the anonymized usage report of the build
+	// is posted to. Telemetry is opt-in: nothing is reported when it is empty.
+	TelemetryEndpoint string
+}
+
+// Executor is where the builds are executed.
+type Executor string
+
+const (
+	// ExecutorLocal executes the builds with the local container engine.
+	ExecutorLocal Executor = "local"
+
+	// ExecutorKubernetes executes the builds in a pod of a Kubernetes cluster,
+	// which pushes the resulting image.
+	ExecutorKubernetes Executor = "kubernetes"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (e *Executor) String() string {
+	if len(string(*e)) == 0 {
+		return string(ExecutorLocal)
+	}
+	return string(*e)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (e *Executor) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "local" or "kubernetes"
+func (e *Executor) Set(v string) error {
+	switch Executor(v) {
+	case ExecutorLocal, ExecutorKubernetes:
+		*e = Executor(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: local or kubernetes", v)
+	}
+	return nil
+}
+
+// KubernetesConfig configures the pods running the builds of the kubernetes
+// executor.
+type KubernetesConfig struct {
+	// Kubeconfig is the kubeconfig file of the cluster. The in-cluster
+	// configuration, or the default kubeconfig file, is used when empty.
+	Kubeconfig string
+
+	// Namespace is the namespace of the pods. The namespace of the current
+	// context is used when empty.
+	Namespace string
+
+	// S2IImage is the image generating the Dockerfile of the build with s2i.
+	S2IImage string
+
+	// BuildahImage is the image building and pushing the image with buildah.
+	BuildahImage string
+
+	// PushSecret is the name of the kubernetes.io/dockerconfigjson secret
+	// holding the credentials of the registry the image is pushed to.
+	PushSecret string
 }
 
 // EnvironmentSpec specifies a single environment variable.
@@ -296,6 +717,46 @@ type VolumeSpec struct {
 // VolumeList contains list of VolumeSpec.
 type VolumeList []VolumeSpec
 
+// CacheSpec is a dependency cache mounted in the assemble container.
+type CacheSpec struct {
+	// Mount is the directory of the container the cache is mounted to.
+	Mount string
+	// KeyFiles are the files of the sources, such as lockfiles, whose content
+	// keys the cache: a new cache is used when they change.
+	KeyFiles []string
+}
+
+// CacheList contains list of CacheSpec.
+type CacheList []CacheSpec
+
+// LiteralInjection represents a file, with its content provided inline, that
+// is injected into the container that runs assemble.
+type LiteralInjection struct {
+	// Name is the name of the injected file.
+	Name string
+	// Value is the content of the injected file.
+	Value string
+	// Destination is the directory to inject the file to - absolute or relative.
+	Destination string
+}
+
+// LiteralInjectionList contains list of LiteralInjection.
+type LiteralInjectionList []LiteralInjection
+
+// SourceSpec represents an additional source repository, downloaded into a
+// subdirectory of the sources of the build.
+type SourceSpec struct {
+	// Source is the location of the sources, with the ref to check out as its
+	// fragment.
+	Source *git.URL
+	// Directory is the subdirectory of the sources the sources are downloaded
+	// to.
+	Directory string
+}
+
+// SourceList contains list of SourceSpec.
+type SourceList []SourceSpec
+
 // DockerConfig contains the configuration for a Docker connection.
 type DockerConfig struct {
 	// Endpoint is the docker network endpoint or socket
@@ -315,6 +776,101 @@ type DockerConfig struct {
 
 	// TLSVerify indicates if TLS peer must be verified
 	TLSVerify bool
+
+	// TLSMinVersion is the minimum TLS version of the connection, e.g. "1.3".
+	TLSMinVersion string
+
+	// TLSCipherSuites is the list of cipher suites allowed for TLS 1.2 and
+	// lower versions, named as in the crypto/tls Go package.
+	TLSCipherSuites []string
+
+	// SPIFFEEndpointSocket is the address of the SPIFFE Workload API socket
+	// the client certificate and the trusted CA bundle of the TLS connection
+	// are fetched from.
+	SPIFFEEndpointSocket string
+
+	// Context is the name of the Docker context whose docker endpoint is used
+	// instead of Endpoint.
+	Context string
+
+	// Engine is the container engine used to run the builds.
+	Engine Engine
+
+	// ContainerdAddress is the address of the containerd socket used by the
+	// containerd engine.
+	ContainerdAddress string
+
+	// ContainerdNamespace is the containerd namespace holding the images and
+	// containers of the containerd engine.
+	ContainerdNamespace string
+
+	// RegistriesConf is the registries.conf file resolving the short names,
+	// the mirrors and the blocked registries of the images pulled by the
+	// containerd engine. The default files of containers/image are read when
+	// empty.
+	RegistriesConf string
+
+	// NamePrefix replaces the "s2i" prefix of the names of the containers and
+	// temporary images created by S2I.
+	NamePrefix string
+
+	// WorkerID identifies the worker running the builds. It is part of the
+	// names of the containers and temporary images created by S2I and is
+	// recorded in their labels.
+	WorkerID string
+
+	// ResourceLabels are labels set on the containers and temporary images
+	// created by S2I.
+	ResourceLabels map[string]string
+
+	// BuildID identifies the build in the labels of the containers, temporary
+	// images and volumes created by S2I.
+	BuildID string
+
+	// Offline forbids all network access by S2I itself: the images are not
+	// pulled, and the sources and scripts must be local.
+	Offline bool
+
+	// ImageStore is the OCI image layout directory the images missing locally
+	// are loaded from offline.
+	ImageStore string
+}
+
+// Engine is the container engine used to run the builds.
+type Engine string
+
+const (
+	// EngineDocker runs the builds using the Docker daemon.
+	EngineDocker Engine = "docker"
+
+	// EngineContainerd runs the builds using containerd, through nerdctl.
+	EngineContainerd Engine = "containerd"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (e *Engine) String() string {
+	if len(string(*e)) == 0 {
+		return string(EngineDocker)
+	}
+	return string(*e)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (e *Engine) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "docker" or "containerd"
+func (e *Engine) Set(v string) error {
+	switch Engine(v) {
+	case EngineDocker, EngineContainerd:
+		*e = Engine(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: docker or containerd", v)
+	}
+	return nil
 }
 
 // AuthConfig is our abstraction of the Registry authorization information for whatever
@@ -324,6 +880,63 @@ type AuthConfig struct {
 	Password      string
 	Email         string
 	ServerAddress string
+	// IdentityToken is the OAuth refresh token obtained by docker login,
+	// used in place of the password by the registries supporting it.
+	IdentityToken string
+	// Source is where the credentials come from: the Docker configuration
+	// file or the command line flag giving them, and Key the registry key they
+	// were found under in the file. They are reported when the registry
+	// rejects the credentials.
+	Source string
+	Key    string
+}
+
+// RegistryCredentials are the credentials of a registry given on the command
+// line, in the user:password[@registry] form.
+type RegistryCredentials struct {
+	Username string
+	Password string
+	// Registry is the registry the credentials are used for. The credentials
+	// are used for the image whatever its registry when empty.
+	Registry string
+}
+
+// Set implements the Set() function of pflags.Value interface.
+// The registry follows the last @, so that the password can contain @.
+func (c *RegistryCredentials) Set(value string) error {
+	credentials := RegistryCredentials{}
+	if i := strings.LastIndex(value, "@"); i != -1 {
+		credentials.Registry = value[i+1:]
+		value = value[:i]
+		if len(credentials.Registry) == 0 {
+			return errors.New("the registry following @ is empty")
+		}
+	}
+	userpass := strings.SplitN(value, ":", 2)
+	if len(userpass) != 2 || len(userpass[0]) == 0 {
+		return errors.New("invalid format, must be user:password[@registry]")
+	}
+	credentials.Username, credentials.Password = userpass[0], userpass[1]
+	*c = credentials
+	return nil
+}
+
+// String implements the String() function of pflags.Value interface. The
+// password is masked.
+func (c *RegistryCredentials) String() string {
+	if len(c.Username) == 0 {
+		return ""
+	}
+	s := c.Username + ":***"
+	if len(c.Registry) > 0 {
+		s += "@" + c.Registry
+	}
+	return s
+}
+
+// Type implements the Type() function of pflags.Value interface.
+func (c *RegistryCredentials) Type() string {
+	return "string"
 }
 
 // ContainerConfig is the abstraction of the docker client provider (formerly go-dockerclient, now either
@@ -358,6 +971,33 @@ type Result struct {
 
 	// BuildInfo holds information about the result of a build.
 	BuildInfo BuildInfo
+
+	// Callbacks are the delivery statuses of the callbacks, for each lifecycle
+	// event and callback URL.
+	Callbacks []CallbackStatus
+}
+
+// CallbackStatus describes the delivery of a lifecycle event of a build to a
+// callback URL.
+type CallbackStatus struct {
+	// URL is the callback URL, without its password.
+	URL string
+
+	// Event is the lifecycle event of the build.
+	Event string
+
+	// Delivered is true when the callback URL accepted the result.
+	Delivered bool
+
+	// Attempts is the number of times the callback URL was called.
+	Attempts int
+
+	// StatusCode is the HTTP status code of the last response, or 0 when the
+	// callback URL could not be reached.
+	StatusCode int
+
+	// Error describes why the last attempt failed.
+	Error string
 }
 
 // BuildInfo contains information about the build process.
@@ -369,6 +1009,40 @@ type BuildInfo struct {
 	// back to the OpenShift builder with information why any of the steps in the
 	// build failed.
 	FailureReason FailureReason
+
+	// Resources holds the resources consumed by the build.
+	Resources ResourceUsage
+}
+
+// ResourceUsage contains the resources consumed by a build, so that the cost
+// of the builds can be attributed to the applications.
+type ResourceUsage struct {
+	// PeakMemoryBytes is the highest memory usage observed among the containers
+	// run by the build.
+	PeakMemoryBytes uint64
+
+	// CPUTimeNanoseconds is the CPU time consumed by the containers run by the
+	// build.
+	CPUTimeNanoseconds uint64
+
+	// PulledBytes is the size of the image layers downloaded by the build.
+	PulledBytes int64
+
+	// ImageSizeBytes is the size of the resulting image.
+	ImageSizeBytes int64
+
+	// Layers contains the layers of the resulting image, from the most recent
+	// one.
+	Layers []LayerUsage
+}
+
+// LayerUsage contains the size of an image layer.
+type LayerUsage struct {
+	// CreatedBy is the instruction which created the layer.
+	CreatedBy string
+
+	// SizeBytes is the size of the layer.
+	SizeBytes int64
 }
 
 // StageInfo contains details about a build stage.
@@ -394,6 +1068,9 @@ const (
 	// StagePullImages pulls the docker images.
 	StagePullImages StageName = "PullImages"
 
+	// StageFetchInputs fetches the application sources.
+	StageFetchInputs StageName = "FetchInputs"
+
 	//StageAssemble runs the assemble steps.
 	StageAssemble StageName = "Assemble"
 
@@ -405,6 +1082,12 @@ const (
 
 	// StageRetrieve retrieves artifacts.
 	StageRetrieve StageName = "RetrieveArtifacts"
+
+	// StageScan scans the resulting image for vulnerabilities.
+	StageScan StageName = "ScanImage"
+
+	// StagePostCommit tests the resulting image.
+	StagePostCommit StageName = "PostCommit"
 )
 
 // StepInfo contains details about a build step.
@@ -430,18 +1113,40 @@ const (
 	// StepPullPreviousImage pulls the previous image for an incremental build.
 	StepPullPreviousImage StepName = "PullPreviousImage"
 
+	// StepFetchSource fetches the application sources.
+	StepFetchSource StepName = "FetchSource"
+
 	// StepPullRuntimeImage pull the runtime image.
 	StepPullRuntimeImage StepName = "PullRuntimeImage"
 
+	// StepProbeBuilderImage checks that the builder image meets the
+	// requirements of the build.
+	StepProbeBuilderImage StepName = "ProbeBuilderImage"
+
 	// StepAssembleBuildScripts runs the assemble scripts.
 	StepAssembleBuildScripts StepName = "AssembleBuildScripts"
 
+	// StepLayeredFallback runs the assemble scripts in a builder image missing
+	// the basic requirements, before falling back to a layered build.
+	StepLayeredFallback StepName = "LayeredFallback"
+
 	// StepBuildDockerImage builds the Docker image for layered builds.
 	StepBuildDockerImage StepName = "BuildDockerImage"
 
 	// StepCommitContainer commits the container to the builder image.
 	StepCommitContainer StepName = "CommitContainer"
 
+	// StepScanImage runs the vulnerability scanner against the resulting image.
+	StepScanImage StepName = "ScanImage"
+
+	// StepPostCommitTest runs the smoke test command in a container of the
+	// resulting image.
+	StepPostCommitTest StepName = "PostCommitTest"
+
+	// StepVerifyRunScript runs a container of the resulting image to confirm
+	// that its run script starts.
+	StepVerifyRunScript StepName = "VerifyRunScript"
+
 	// StepRetrievePreviousArtifacts restores archived artifacts from the previous build.
 	StepRetrievePreviousArtifacts StepName = "RetrievePreviousArtifacts"
 )
@@ -488,6 +1193,9 @@ type InstallResult struct {
 type DockerNetworkMode string
 
 const (
+	// DockerNetworkModeNone gives the container a network namespace without any
+	// interface but the loopback one, so that it cannot reach the network.
+	DockerNetworkModeNone DockerNetworkMode = "none"
 	// DockerNetworkModeHost places the container in the default (host) network namespace.
 	DockerNetworkModeHost DockerNetworkMode = "host"
 	// DockerNetworkModeBridge instructs docker to create a network namespace for this container connected to the docker0 bridge via a veth-pair.
@@ -498,6 +1206,49 @@ const (
 	DockerNetworkModeNetworkNamespacePrefix string = "netns:"
 )
 
+// IsValid returns true if the network mode conforms to the docker remote API
+// specification: none, bridge, host, container:<name|id> or
+// netns:/proc/<pid>/ns/net.
+func (m DockerNetworkMode) IsValid() bool {
+	switch m {
+	case DockerNetworkModeNone, DockerNetworkModeBridge, DockerNetworkModeHost:
+		return true
+	}
+	for _, prefix := range []string{DockerNetworkModeContainerPrefix, DockerNetworkModeNetworkNamespacePrefix} {
+		if strings.HasPrefix(string(m), prefix) && len(m) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsContainer returns true if the network mode places the container in the
+// network namespace of another container.
+func (m DockerNetworkMode) IsContainer() bool {
+	return strings.HasPrefix(string(m), DockerNetworkModeContainerPrefix)
+}
+
+// String implements the String() function of pflags.Value interface.
+func (m *DockerNetworkMode) String() string {
+	return string(*m)
+}
+
+// Type implements the Type() function of pflags.Value interface.
+func (m *DockerNetworkMode) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface.
+// The valid options are "none", "bridge", "host", "container:<name|id>" or
+// "netns:/proc/<pid>/ns/net".
+func (m *DockerNetworkMode) Set(v string) error {
+	if !DockerNetworkMode(v).IsValid() {
+		return fmt.Errorf("invalid value %q, valid values are: none, bridge, host, container:<name|id> or netns:/proc/<pid>/ns/net", v)
+	}
+	*m = DockerNetworkMode(v)
+	return nil
+}
+
 // NewDockerNetworkModeContainer creates a DockerNetworkMode value which instructs docker to place the container in the network namespace of an existing container.
 // It can be used, for instance, to place the s2i container in the network namespace of the infrastructure container of a k8s pod.
 func NewDockerNetworkModeContainer(id string) DockerNetworkMode {
@@ -524,7 +1275,7 @@ func (p *PullPolicy) Type() string {
 }
 
 // Set implements the Set() function of pflags.Value interface
-// The valid options are "always", "never" or "if-not-present"
+// The valid options are "always", "never", "if-not-present" or "if-changed"
 func (p *PullPolicy) Set(v string) error {
 	switch v {
 	case "always":
@@ -533,100 +1284,779 @@ func (p *PullPolicy) Set(v string) error {
 		*p = PullNever
 	case "if-not-present":
 		*p = PullIfNotPresent
+	case "if-changed":
+		*p = PullIfChanged
 	default:
-		return fmt.Errorf("invalid value %q, valid values are: always, never or if-not-present", v)
+		return fmt.Errorf("invalid value %q, valid values are: always, never, if-not-present or if-changed", v)
 	}
 	return nil
 }
 
-// IsInvalidFilename verifies if the provided filename contains malicious
-// characters.
-func IsInvalidFilename(name string) bool {
-	return strings.ContainsAny(name, invalidFilenameCharacters)
-}
+// Compression specifies the compression algorithm applied to tar streams
+// uploaded to the container engine.
+type Compression string
 
-// Set implements the Set() function of pflags.Value interface.
-// This function parses the string that contains source:destination pair.
-// When the destination is not specified, the source get copied into current
-// working directory in container.
-func (l *VolumeList) Set(value string) error {
-	volumes := strings.Split(value, ";")
-	newVols := make([]VolumeSpec, len(volumes))
-	for i, v := range volumes {
-		spec, err := l.parseSpec(v)
-		if err != nil {
-			return err
-		}
-		newVols[i] = *spec
-	}
-	*l = append(*l, newVols...)
-	return nil
-}
+const (
+	// CompressionNone sends tar streams uncompressed.
+	CompressionNone Compression = "none"
 
-func (l *VolumeList) parseSpec(value string) (*VolumeSpec, error) {
-	if len(value) == 0 {
-		return nil, errors.New("invalid format, must be source:destination")
-	}
-	var mount []string
-	pos := strings.LastIndex(value, ":")
-	if pos == -1 {
-		mount = []string{value, ""}
-	} else {
-		mount = []string{value[:pos], value[pos+1:]}
-	}
-	mount[0] = strings.Trim(mount[0], `"'`)
-	mount[1] = strings.Trim(mount[1], `"'`)
-	s := &VolumeSpec{Source: filepath.Clean(mount[0]), Destination: filepath.ToSlash(filepath.Clean(mount[1]))}
-	if IsInvalidFilename(s.Source) || IsInvalidFilename(s.Destination) {
-		return nil, fmt.Errorf("invalid characters in filename: %q", value)
-	}
-	return s, nil
-}
+	// CompressionGzip compresses tar streams using gzip.
+	CompressionGzip Compression = "gzip"
 
-// String implements the String() function of pflags.Value interface.
-func (l *VolumeList) String() string {
-	result := []string{}
-	for _, i := range *l {
-		result = append(result, strings.Join([]string{i.Source, i.Destination}, ":"))
+	// CompressionZstd compresses tar streams using zstd. Older daemons that
+	// cannot decompress zstd fall back to gzip.
+	CompressionZstd Compression = "zstd"
+
+	// CompressionAuto picks the best compression supported by the daemon.
+	CompressionAuto Compression = "auto"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (c *Compression) String() string {
+	if len(string(*c)) == 0 {
+		return string(CompressionNone)
 	}
-	return strings.Join(result, ",")
+	return string(*c)
 }
 
-// Type implements the Type() function of pflags.Value interface.
-func (l *VolumeList) Type() string {
+// Type implements the Type() function of pflags.Value interface
+func (c *Compression) Type() string {
 	return "string"
 }
 
-// Set implements the Set() function of pflags.Value interface.
-func (e *EnvironmentList) Set(value string) error {
-	parts := strings.SplitN(value, "=", 2)
-	if len(parts) != 2 || len(parts[0]) == 0 {
-		return fmt.Errorf("invalid environment format %q, must be NAME=VALUE", value)
-	}
-	if strings.Contains(parts[1], ",") && strings.Contains(parts[1], "=") {
-		log.Warningf("DEPRECATED: Use multiple -e flags to specify multiple environment variables instead of comma (%q)", value)
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "none", "gzip", "zstd" or "auto"
+func (c *Compression) Set(v string) error {
+	switch Compression(v) {
+	case CompressionNone, CompressionGzip, CompressionZstd, CompressionAuto:
+		*c = Compression(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: none, gzip, zstd or auto", v)
 	}
-	*e = append(*e, EnvironmentSpec{
-		Name:  strings.TrimSpace(parts[0]),
-		Value: strings.TrimSpace(parts[1]),
-	})
 	return nil
 }
 
-// String implements the String() function of pflags.Value interface.
-func (e *EnvironmentList) String() string {
-	result := []string{}
-	for _, i := range *e {
-		result = append(result, strings.Join([]string{i.Name, i.Value}, "="))
-	}
-	return strings.Join(result, ",")
-}
+// Scanner is a vulnerability scanner run against the resulting image.
+type Scanner string
 
-// Type implements the Type() function of pflags.Value interface.
+const (
+	// ScannerTrivy scans the image using Trivy.
+	ScannerTrivy Scanner = "trivy"
+
+	// ScannerGrype scans the image using Grype.
+	ScannerGrype Scanner = "grype"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (s *Scanner) String() string {
+	return string(*s)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (s *Scanner) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "trivy" or "grype"
+func (s *Scanner) Set(v string) error {
+	switch Scanner(v) {
+	case ScannerTrivy, ScannerGrype:
+		*s = Scanner(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: trivy or grype", v)
+	}
+	return nil
+}
+
+// Severity is the severity of a vulnerability.
+type Severity string
+
+const (
+	// SeverityUnknown is the severity of vulnerabilities not rated yet.
+	SeverityUnknown Severity = "unknown"
+
+	// SeverityNegligible is the severity of negligible vulnerabilities, as
+	// rated by some scanners.
+	SeverityNegligible Severity = "negligible"
+
+	// SeverityLow is the severity of low vulnerabilities.
+	SeverityLow Severity = "low"
+
+	// SeverityMedium is the severity of medium vulnerabilities.
+	SeverityMedium Severity = "medium"
+
+	// SeverityHigh is the severity of high vulnerabilities.
+	SeverityHigh Severity = "high"
+
+	// SeverityCritical is the severity of critical vulnerabilities.
+	SeverityCritical Severity = "critical"
+)
+
+// severityRanks orders the severities, from the least to the most severe.
+var severityRanks = map[Severity]int{
+	SeverityUnknown:    0,
+	SeverityNegligible: 1,
+	SeverityLow:        2,
+	SeverityMedium:     3,
+	SeverityHigh:       4,
+	SeverityCritical:   5,
+}
+
+// AtLeast returns true if the severity is the same as, or more severe than,
+// the given one.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRanks[s] >= severityRanks[other]
+}
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (s *Severity) String() string {
+	return string(*s)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (s *Severity) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "low", "medium", "high" or "critical"
+func (s *Severity) Set(v string) error {
+	switch Severity(v) {
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		*s = Severity(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: low, medium, high or critical", v)
+	}
+	return nil
+}
+
+const (
+	// CallbackEventStarted is notified to the callback URLs when the build
+	// starts.
+	CallbackEventStarted = "started"
+
+	// CallbackEventAssembled is notified to the callback URLs when the
+	// assemble script finished and its image is committed.
+	CallbackEventAssembled = "assembled"
+
+	// CallbackEventPushed is notified to the callback URLs when the image
+	// built by the kubernetes executor is pushed.
+	CallbackEventPushed = "pushed"
+
+	// CallbackEventCompleted is notified to the callback URLs, with the result
+	// of the build, when it completes.
+	CallbackEventCompleted = "completed"
+)
+
+const (
+	// IgnorerS2I processes the .s2iignore file in the root of the source tree.
+	IgnorerS2I = "s2iignore"
+
+	// IgnorerGit processes the .gitignore files found in the source tree.
+	IgnorerGit = "gitignore"
+)
+
+// SymlinkPolicy specifies how symbolic links pointing outside of the directory
+// tree being archived are handled.
+type SymlinkPolicy string
+
+const (
+	// SymlinkPreserve stores symbolic links as-is, even when they point outside
+	// of the archived tree.
+	SymlinkPreserve SymlinkPolicy = "preserve"
+
+	// SymlinkRewrite makes absolute symbolic links pointing inside the archived
+	// tree relative, and replaces symbolic links to files outside of the
+	// archived tree with the content of the file they point to.
+	SymlinkRewrite SymlinkPolicy = "rewrite"
+
+	// SymlinkError fails the archiving when a symbolic link points outside of
+	// the archived tree.
+	SymlinkError SymlinkPolicy = "error"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (p *SymlinkPolicy) String() string {
+	if len(string(*p)) == 0 {
+		return string(SymlinkPreserve)
+	}
+	return string(*p)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (p *SymlinkPolicy) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "preserve", "rewrite" or "error"
+func (p *SymlinkPolicy) Set(v string) error {
+	switch SymlinkPolicy(v) {
+	case SymlinkPreserve, SymlinkRewrite, SymlinkError:
+		*p = SymlinkPolicy(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: preserve, rewrite or error", v)
+	}
+	return nil
+}
+
+// CRLFConversion selects the files whose CRLF line endings are converted to LF
+// during tar creation.
+type CRLFConversion string
+
+const (
+	// CRLFConversionOff converts no file.
+	CRLFConversionOff CRLFConversion = "off"
+
+	// CRLFConversionScripts converts the text files of the S2I scripts: the
+	// scripts directory of the upload directory and the .s2i/bin directories
+	// of the sources.
+	CRLFConversionScripts CRLFConversion = "scripts"
+
+	// CRLFConversionAll converts all the text files.
+	CRLFConversionAll CRLFConversion = "all"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (c *CRLFConversion) String() string {
+	if len(string(*c)) == 0 {
+		return string(CRLFConversionOff)
+	}
+	return string(*c)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (c *CRLFConversion) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "scripts", "all" or "off"
+func (c *CRLFConversion) Set(v string) error {
+	switch CRLFConversion(v) {
+	case CRLFConversionScripts, CRLFConversionAll, CRLFConversionOff:
+		*c = CRLFConversion(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: scripts, all or off", v)
+	}
+	return nil
+}
+
+// LayeredFallbackMode selects when a build falls back to a layered build.
+type LayeredFallbackMode string
+
+const (
+	// LayeredFallbackAuto falls back to a layered build when the builder image
+	// is missing the basic requirements (sh or tar) to receive the scripts and
+	// sources.
+	LayeredFallbackAuto LayeredFallbackMode = "auto"
+
+	// LayeredFallbackNever fails the build when the builder image is missing
+	// the basic requirements.
+	LayeredFallbackNever LayeredFallbackMode = "never"
+
+	// LayeredFallbackAlways always performs a layered build.
+	LayeredFallbackAlways LayeredFallbackMode = "always"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (m *LayeredFallbackMode) String() string {
+	if len(string(*m)) == 0 {
+		return string(LayeredFallbackAuto)
+	}
+	return string(*m)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (m *LayeredFallbackMode) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "auto", "never" or "always"
+func (m *LayeredFallbackMode) Set(v string) error {
+	switch LayeredFallbackMode(v) {
+	case LayeredFallbackAuto, LayeredFallbackNever, LayeredFallbackAlways:
+		*m = LayeredFallbackMode(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: auto, never or always", v)
+	}
+	return nil
+}
+
+// BuildStrategy is the strategy building the image.
+type BuildStrategy string
+
+const (
+	// StrategyAuto selects the dockerfile strategy with --as-dockerfile, the
+	// onbuild strategy for the builder images with ONBUILD instructions, and
+	// the source strategy otherwise.
+	StrategyAuto BuildStrategy = "auto"
+
+	// StrategySource runs the S2I scripts of the builder image, layering them
+	// on top of it when needed.
+	StrategySource BuildStrategy = "source"
+
+	// StrategyOnBuild builds the sources with a docker build from the builder
+	// image, executing its ONBUILD instructions.
+	StrategyOnBuild BuildStrategy = "onbuild"
+
+	// StrategyDockerfile writes the Dockerfile of the build to --as-dockerfile.
+	StrategyDockerfile BuildStrategy = "dockerfile"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (s *BuildStrategy) String() string {
+	if len(string(*s)) == 0 {
+		return string(StrategyAuto)
+	}
+	return string(*s)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (s *BuildStrategy) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "auto", "source", "onbuild" or "dockerfile"
+func (s *BuildStrategy) Set(v string) error {
+	switch BuildStrategy(v) {
+	case StrategyAuto, StrategySource, StrategyOnBuild, StrategyDockerfile:
+		*s = BuildStrategy(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: auto, source, onbuild or dockerfile", v)
+	}
+	return nil
+}
+
+// UsageMode selects how the usage script of an image is shown.
+type UsageMode string
+
+const (
+	// UsageModeContainer runs the usage script in a container of the image.
+	UsageModeContainer UsageMode = "container"
+
+	// UsageModeAuto runs the usage script in a container of the image, and
+	// prints it when the image cannot run locally.
+	UsageModeAuto UsageMode = "auto"
+
+	// UsageModePrint prints the usage script without running it.
+	UsageModePrint UsageMode = "print"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (m *UsageMode) String() string {
+	if len(string(*m)) == 0 {
+		return string(UsageModeContainer)
+	}
+	return string(*m)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (m *UsageMode) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "container", "auto" or "print"
+func (m *UsageMode) Set(v string) error {
+	switch UsageMode(v) {
+	case UsageModeContainer, UsageModeAuto, UsageModePrint:
+		*m = UsageMode(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: container, auto or print", v)
+	}
+	return nil
+}
+
+// ProgressMode selects how the progress of the builds is displayed.
+type ProgressMode string
+
+const (
+	// ProgressAuto displays the progress as with ProgressTTY when the output is
+	// a terminal, and as with ProgressPlain otherwise.
+	ProgressAuto ProgressMode = "auto"
+
+	// ProgressPlain displays the progress as line-based logs.
+	ProgressPlain ProgressMode = "plain"
+
+	// ProgressTTY displays a live status line for each step of the build.
+	ProgressTTY ProgressMode = "tty"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (m *ProgressMode) String() string {
+	if len(string(*m)) == 0 {
+		return string(ProgressAuto)
+	}
+	return string(*m)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (m *ProgressMode) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "auto", "plain" or "tty"
+func (m *ProgressMode) Set(v string) error {
+	switch ProgressMode(v) {
+	case ProgressAuto, ProgressPlain, ProgressTTY:
+		*m = ProgressMode(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: auto, plain or tty", v)
+	}
+	return nil
+}
+
+// ColorMode selects whether the output is colored.
+type ColorMode string
+
+const (
+	// ColorAuto colors the output when it is a terminal and the NO_COLOR
+	// environment variable is not set.
+	ColorAuto ColorMode = "auto"
+
+	// ColorAlways always colors the output.
+	ColorAlways ColorMode = "always"
+
+	// ColorNever never colors the output.
+	ColorNever ColorMode = "never"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (m *ColorMode) String() string {
+	if len(string(*m)) == 0 {
+		return string(ColorAuto)
+	}
+	return string(*m)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (m *ColorMode) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "auto", "always" or "never"
+func (m *ColorMode) Set(v string) error {
+	switch ColorMode(v) {
+	case ColorAuto, ColorAlways, ColorNever:
+		*m = ColorMode(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: auto, always or never", v)
+	}
+	return nil
+}
+
+// AutoTagMode specifies how the tag of the resulting image is derived from the
+// state of the source repository.
+type AutoTagMode string
+
+const (
+	// AutoTagGitDescribe tags the image with the output of git describe, e.g.
+	// v1.2.0-3-g0123456.
+	AutoTagGitDescribe AutoTagMode = "git-describe"
+
+	// AutoTagCommit tags the image with the abbreviated commit of the sources.
+	AutoTagCommit AutoTagMode = "commit"
+
+	// AutoTagBranch tags the image with the branch of the sources.
+	AutoTagBranch AutoTagMode = "branch"
+)
+
+// String implements the String() function of pflags.Value so this can be used as
+// command line parameter.
+func (m *AutoTagMode) String() string {
+	return string(*m)
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (m *AutoTagMode) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface
+// The valid options are "git-describe", "commit" or "branch"
+func (m *AutoTagMode) Set(v string) error {
+	switch AutoTagMode(v) {
+	case AutoTagGitDescribe, AutoTagCommit, AutoTagBranch:
+		*m = AutoTagMode(v)
+	default:
+		return fmt.Errorf("invalid value %q, valid values are: git-describe, commit or branch", v)
+	}
+	return nil
+}
+
+// IsInvalidFilename verifies if the provided filename contains malicious
+// characters.
+func IsInvalidFilename(name string) bool {
+	return strings.ContainsAny(name, invalidFilenameCharacters)
+}
+
+// Set implements the Set() function of pflags.Value interface.
+// This function parses the string that contains source:destination pair.
+// When the destination is not specified, the source get copied into current
+// working directory in container.
+func (l *VolumeList) Set(value string) error {
+	volumes := strings.Split(value, ";")
+	newVols := make([]VolumeSpec, len(volumes))
+	for i, v := range volumes {
+		spec, err := l.parseSpec(v)
+		if err != nil {
+			return err
+		}
+		newVols[i] = *spec
+	}
+	*l = append(*l, newVols...)
+	return nil
+}
+
+func (l *VolumeList) parseSpec(value string) (*VolumeSpec, error) {
+	if len(value) == 0 {
+		return nil, errors.New("invalid format, must be source:destination")
+	}
+	// the source might be a Windows path starting with a drive letter
+	source, destination := hostpath.SplitVolume(value)
+	source = strings.Trim(source, `"'`)
+	destination = strings.Trim(destination, `"'`)
+	s := &VolumeSpec{Source: filepath.Clean(source), Destination: path.Clean(hostpath.ToSlash(destination))}
+	if IsInvalidFilename(s.Source) || IsInvalidFilename(s.Destination) {
+		return nil, fmt.Errorf("invalid characters in filename: %q", value)
+	}
+	return s, nil
+}
+
+// String implements the String() function of pflags.Value interface.
+func (l *VolumeList) String() string {
+	result := []string{}
+	for _, i := range *l {
+		result = append(result, strings.Join([]string{i.Source, i.Destination}, ":"))
+	}
+	return strings.Join(result, ",")
+}
+
+// Type implements the Type() function of pflags.Value interface.
+func (l *VolumeList) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface.
+// This function parses the mount=dir[,key=sha256(file[,file...])] form of a
+// cache.
+func (l *CacheList) Set(value string) error {
+	spec := CacheSpec{}
+	for len(value) > 0 {
+		field := value
+		if end := strings.Index(value, ")"); strings.HasPrefix(value, "key=") && end != -1 {
+			field = value[:end+1]
+		} else if end := strings.Index(value, ","); end != -1 {
+			field = value[:end]
+		}
+		value = strings.TrimPrefix(value[len(field):], ",")
+		switch {
+		case strings.HasPrefix(field, "mount="):
+			spec.Mount = path.Clean(strings.TrimPrefix(field, "mount="))
+		case strings.HasPrefix(field, "key=sha256(") && strings.HasSuffix(field, ")"):
+			for _, f := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(field, "key=sha256("), ")"), ",") {
+				if f = strings.TrimSpace(f); len(f) > 0 {
+					spec.KeyFiles = append(spec.KeyFiles, f)
+				}
+			}
+		default:
+			return fmt.Errorf("invalid field %q, must be mount=dir or key=sha256(file[,file...])", field)
+		}
+	}
+	if !path.IsAbs(spec.Mount) {
+		return fmt.Errorf("the mount directory of the cache must be absolute")
+	}
+	*l = append(*l, spec)
+	return nil
+}
+
+// String implements the String() function of pflags.Value interface.
+func (l *CacheList) String() string {
+	result := []string{}
+	for _, c := range *l {
+		s := "mount=" + c.Mount
+		if len(c.KeyFiles) > 0 {
+			s += ",key=sha256(" + strings.Join(c.KeyFiles, ",") + ")"
+		}
+		result = append(result, s)
+	}
+	return strings.Join(result, ";")
+}
+
+// Type implements the Type() function of pflags.Value interface.
+func (l *CacheList) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface.
+// This function parses the string that contains name=VALUE:destination. The
+// destination is the directory the file is injected to, when it is not
+// specified, the file gets injected into current working directory in
+// container.
+func (l *LiteralInjectionList) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return fmt.Errorf("invalid literal injection format %q, must be name=VALUE:destination", value)
+	}
+	name, content := parts[0], parts[1]
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." || IsInvalidFilename(name) {
+		return fmt.Errorf("invalid literal injection name %q, must be a file name", name)
+	}
+	destination := ""
+	if pos := strings.LastIndex(content, ":"); pos != -1 {
+		content, destination = content[:pos], content[pos+1:]
+	}
+	destination = strings.Trim(destination, `"'`)
+	if len(destination) > 0 {
+		destination = hostpath.ContainerPath(destination)
+	}
+	if IsInvalidFilename(destination) {
+		return fmt.Errorf("invalid characters in filename: %q", destination)
+	}
+	*l = append(*l, LiteralInjection{Name: name, Value: content, Destination: destination})
+	return nil
+}
+
+// String implements the String() function of pflags.Value interface. The
+// values are left out, as they usually are secrets.
+func (l *LiteralInjectionList) String() string {
+	result := []string{}
+	for _, i := range *l {
+		result = append(result, strings.Join([]string{i.Name, i.Destination}, ":"))
+	}
+	return strings.Join(result, ",")
+}
+
+// Type implements the Type() function of pflags.Value interface.
+func (l *LiteralInjectionList) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface.
+// This function parses the string that contains url[#ref]:directory.
+func (l *SourceList) Set(value string) error {
+	pos := strings.LastIndex(value, ":")
+	if pos == -1 || len(value[pos+1:]) == 0 {
+		return fmt.Errorf("invalid source format %q, must be url[#ref]:directory", value)
+	}
+	source, err := git.Parse(value[:pos])
+	if err != nil {
+		return err
+	}
+	*l = append(*l, SourceSpec{Source: source, Directory: value[pos+1:]})
+	return nil
+}
+
+// String implements the String() function of pflags.Value interface.
+func (l *SourceList) String() string {
+	result := []string{}
+	for _, i := range *l {
+		result = append(result, i.Source.String()+":"+i.Directory)
+	}
+	return strings.Join(result, ",")
+}
+
+// Type implements the Type() function of pflags.Value interface.
+func (l *SourceList) Type() string {
+	return "string"
+}
+
+// Set implements the Set() function of pflags.Value interface.
+func (e *EnvironmentList) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return fmt.Errorf("invalid environment format %q, must be NAME=VALUE", value)
+	}
+	if strings.Contains(parts[1], ",") && strings.Contains(parts[1], "=") {
+		log.Warningf("DEPRECATED: Use multiple -e flags to specify multiple environment variables instead of comma (%q)", value)
+	}
+	*e = append(*e, EnvironmentSpec{
+		Name:  strings.TrimSpace(parts[0]),
+		Value: strings.TrimSpace(parts[1]),
+	})
+	return nil
+}
+
+// String implements the String() function of pflags.Value interface.
+func (e *EnvironmentList) String() string {
+	result := []string{}
+	for _, i := range *e {
+		result = append(result, strings.Join([]string{i.Name, i.Value}, "="))
+	}
+	return strings.Join(result, ",")
+}
+
+// Type implements the Type() function of pflags.Value interface.
 func (e *EnvironmentList) Type() string {
 	return "string"
 }
 
+// BuildArgList contains list of build-time variables.
+type BuildArgList []EnvironmentSpec
+
+// Set implements the Set() function of pflags.Value interface.
+// The value is either NAME=VALUE, or NAME, in which case the value is taken
+// from the environment.
+func (b *BuildArgList) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	name := strings.TrimSpace(parts[0])
+	if len(name) == 0 {
+		return fmt.Errorf("invalid build argument format %q, must be NAME=VALUE or NAME", value)
+	}
+	if len(parts) == 1 {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return fmt.Errorf("build argument %q has no value and is not set in the environment", name)
+		}
+		parts = append(parts, v)
+	}
+	*b = append(*b, EnvironmentSpec{
+		Name:  name,
+		Value: parts[1],
+	})
+	return nil
+}
+
+// String implements the String() function of pflags.Value interface.
+func (b *BuildArgList) String() string {
+	result := []string{}
+	for _, i := range *b {
+		result = append(result, strings.Join([]string{i.Name, i.Value}, "="))
+	}
+	return strings.Join(result, ",")
+}
+
+// Type implements the Type() function of pflags.Value interface.
+func (b *BuildArgList) Type() string {
+	return "string"
+}
+
+// AsMap converts the list of build arguments to the map expected by the docker
+// build API.
+func (b BuildArgList) AsMap() map[string]*string {
+	result := make(map[string]*string, len(b))
+	for i := range b {
+		result[b[i].Name] = &b[i].Value
+	}
+	return result
+}
+
 // AsBinds converts the list of volume definitions to go-dockerclient compatible
 // list of bind mounts.
 func (l *VolumeList) AsBinds() []string {
@@ -4,6 +4,8 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/openshift/source-to-image/pkg/scm/git"
 )
 
 func TestVolumeListSet(t *testing.T) {
@@ -22,6 +24,8 @@ func TestVolumeListSet(t *testing.T) {
 		{`'/test':"/foo"`, VolumeList{{Source: "/test", Destination: "/foo"}}},
 		{`C:\test:/bar`, VolumeList{{Source: `C:\test`, Destination: "/bar"}}},
 		{`C:\test:bar`, VolumeList{{Source: `C:\test`, Destination: "bar"}}},
+		{`C:\test`, VolumeList{{Source: `C:\test`, Destination: "."}}},
+		{`C:\test:\opt\bar\`, VolumeList{{Source: `C:\test`, Destination: "/opt/bar"}}},
 		{`"/te"st":"/foo"`, VolumeList{}},
 		{"/test/foo:/ss;ss", VolumeList{
 			{Source: "/test/foo", Destination: "/ss"},
@@ -45,6 +49,103 @@ func TestVolumeListSet(t *testing.T) {
 	}
 }
 
+func TestLiteralInjectionListSet(t *testing.T) {
+	table := []struct {
+		Input    string
+		Expected LiteralInjectionList
+	}{
+		{"token=secret:/var/run/secrets", LiteralInjectionList{{Name: "token", Value: "secret", Destination: "/var/run/secrets"}}},
+		{"token=secret", LiteralInjectionList{{Name: "token", Value: "secret"}}},
+		{"token=secret:", LiteralInjectionList{{Name: "token", Value: "secret"}}},
+		{"token=-:certs/", LiteralInjectionList{{Name: "token", Value: "-", Destination: "certs"}}},
+		{`token=secret:\var\run\secrets`, LiteralInjectionList{{Name: "token", Value: "secret", Destination: "/var/run/secrets"}}},
+		{"token=a=b:c:/etc", LiteralInjectionList{{Name: "token", Value: "a=b:c", Destination: "/etc"}}},
+		{"token=", LiteralInjectionList{{Name: "token"}}},
+		{"=secret:/etc", LiteralInjectionList{}},
+		{"token", LiteralInjectionList{}},
+		{"certs/ca.crt=secret:/etc", LiteralInjectionList{}},
+		{"..=secret:/etc", LiteralInjectionList{}},
+		{"token=secret:/b@!dF1nl3m!", LiteralInjectionList{}},
+	}
+	for _, test := range table {
+		got := LiteralInjectionList{}
+		got.Set(test.Input)
+		if !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("On test %s, got %#v, expected %#v", test.Input, got, test.Expected)
+		}
+	}
+}
+
+func TestCacheListSet(t *testing.T) {
+	table := []struct {
+		Input    string
+		Expected CacheList
+	}{
+		{"mount=/opt/app-root/src/.m2,key=sha256(pom.xml)", CacheList{{Mount: "/opt/app-root/src/.m2", KeyFiles: []string{"pom.xml"}}}},
+		{"key=sha256(package.json, package-lock.json),mount=/opt/app-root/src/.npm/", CacheList{{Mount: "/opt/app-root/src/.npm", KeyFiles: []string{"package.json", "package-lock.json"}}}},
+		{"mount=/root/.cache/pip", CacheList{{Mount: "/root/.cache/pip"}}},
+		{"mount=.m2,key=sha256(pom.xml)", CacheList{}},
+		{"key=sha256(pom.xml)", CacheList{}},
+		{"mount=/root/.m2,key=pom.xml", CacheList{}},
+	}
+	for _, test := range table {
+		got := CacheList{}
+		got.Set(test.Input)
+		if !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("On test %s, got %#v, expected %#v", test.Input, got, test.Expected)
+		}
+	}
+}
+
+func TestRegistryCredentialsSet(t *testing.T) {
+	table := []struct {
+		Input    string
+		Expected RegistryCredentials
+		Error    bool
+	}{
+		{Input: "user:pass", Expected: RegistryCredentials{Username: "user", Password: "pass"}},
+		{Input: "user:p@ss:word@registry.redhat.io", Expected: RegistryCredentials{Username: "user", Password: "p@ss:word", Registry: "registry.redhat.io"}},
+		{Input: "user:@quay.io", Expected: RegistryCredentials{Username: "user", Registry: "quay.io"}},
+		{Input: "user@quay.io", Error: true},
+		{Input: ":pass", Error: true},
+		{Input: "user:pass@", Error: true},
+	}
+	for _, test := range table {
+		got := RegistryCredentials{}
+		err := got.Set(test.Input)
+		if (err != nil) != test.Error {
+			t.Errorf("On test %s, got error %v", test.Input, err)
+		}
+		if !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("On test %s, got %#v, expected %#v", test.Input, got, test.Expected)
+		}
+	}
+	credentials := RegistryCredentials{Username: "user", Password: "pass", Registry: "quay.io"}
+	if s := credentials.String(); s != "user:***@quay.io" {
+		t.Errorf("Expected the password to be masked, got %q", s)
+	}
+}
+
+func TestSourceListSet(t *testing.T) {
+	table := []struct {
+		Input    string
+		Expected SourceList
+	}{
+		{"https://github.com/user/config#v1.2:config", SourceList{{Source: git.MustParse("https://github.com/user/config#v1.2"), Directory: "config"}}},
+		{"git@github.com:user/config.git:etc/config", SourceList{{Source: git.MustParse("git@github.com:user/config.git"), Directory: "etc/config"}}},
+		{"../config:config", SourceList{{Source: git.MustParse("../config"), Directory: "config"}}},
+		{"https://github.com/user/config:", SourceList{}},
+		{"config", SourceList{}},
+	}
+	for _, test := range table {
+		got := SourceList{}
+		got.Set(test.Input)
+		if !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("On test %s, got %#v, expected %#v", test.Input, got, test.Expected)
+		}
+	}
+}
+
 func TestEnvironmentSet(t *testing.T) {
 	table := map[string][]EnvironmentSpec{
 		"FOO=bar":  {{Name: "FOO", Value: "bar"}},
@@ -82,3 +183,56 @@ func TestEnvironmentSet(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildArgSet(t *testing.T) {
+	t.Setenv("S2I_TEST_PROXY", "http://proxy:3128")
+	table := map[string][]EnvironmentSpec{
+		"FOO=bar":        {{Name: "FOO", Value: "bar"}},
+		"FOO=":           {{Name: "FOO", Value: ""}},
+		"FOO=a=b":        {{Name: "FOO", Value: "a=b"}},
+		"S2I_TEST_PROXY": {{Name: "S2I_TEST_PROXY", Value: "http://proxy:3128"}},
+		"S2I_TEST_UNSET": {},
+		"=bar":           {},
+	}
+
+	for v, expected := range table {
+		got := BuildArgList{}
+		err := got.Set(v)
+		if len(expected) == 0 {
+			if err == nil {
+				t.Errorf("Expected error for build argument %q", v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for build argument %q: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual([]EnvironmentSpec(got), expected) {
+			t.Errorf("got %#v, expected %#v for %q", got, expected, v)
+		}
+	}
+}
+
+func TestDockerNetworkModeSet(t *testing.T) {
+	table := map[string]bool{
+		"none":                   true,
+		"bridge":                 true,
+		"host":                   true,
+		"container:8d873e496bc3": true,
+		"netns:/proc/42/ns/net":  true,
+		"container:":             false,
+		"default":                false,
+		"":                       false,
+	}
+	for v, valid := range table {
+		var mode DockerNetworkMode
+		err := mode.Set(v)
+		if valid != (err == nil) {
+			t.Errorf("Unexpected result for network mode %q: %v", v, err)
+		}
+		if valid && string(mode) != v {
+			t.Errorf("Expected network mode %q, got %q", v, mode)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+// ToInternal converts the given versioned configuration to the internal
+// configuration of the builds. The configuration is expected to be defaulted
+// with SetDefaults; the fields which cannot be parsed are reported with their
+// JSON path.
+func ToInternal(in *Config) (*api.Config, error) {
+	if len(in.APIVersion) > 0 && in.APIVersion != APIVersion {
+		return nil, fmt.Errorf("unsupported apiVersion %q, this version of s2i supports %q", in.APIVersion, APIVersion)
+	}
+	if len(in.Kind) > 0 && in.Kind != Kind {
+		return nil, fmt.Errorf("unsupported kind %q, expected %q", in.Kind, Kind)
+	}
+	out := &api.Config{
+		DisplayName:              in.DisplayName,
+		Description:              in.Description,
+		BuilderImage:             in.BuilderImage,
+		BuilderPullPolicy:        api.PullPolicy(in.BuilderPullPolicy),
+		RuntimeImage:             in.RuntimeImage,
+		RuntimeImagePullPolicy:   api.PullPolicy(in.RuntimeImagePullPolicy),
+		RuntimeArtifacts:         volumesToInternal(in.RuntimeArtifacts),
+		RuntimeEnvironment:       environmentToInternal(in.RuntimeEnvironment),
+		DryRun:                   in.DryRun,
+		ContextDir:               in.ContextDir,
+		ArtifactPath:             in.ArtifactPath,
+		IgnoreSubmodules:         in.IgnoreSubmodules,
+		PartialClone:             in.PartialClone,
+		ForceCopy:                in.ForceCopy,
+		KeepSymlinks:             in.KeepSymlinks,
+		VerifyCommitSignature:    in.VerifyCommitSignature,
+		Keyring:                  in.Keyring,
+		ExcludeGlobs:             copySlice(in.ExcludeGlobs),
+		IncludeGlobs:             copySlice(in.IncludeGlobs),
+		Ignorers:                 copySlice(in.Ignorers),
+		RenderTemplates:          copySlice(in.RenderTemplates),
+		SymlinkPolicy:            api.SymlinkPolicy(in.SymlinkPolicy),
+		ConvertCRLF:              api.CRLFConversion(in.ConvertCRLF),
+		Tag:                      in.Tag,
+		AdditionalTags:           copySlice(in.AdditionalTags),
+		TagTemplates:             copySlice(in.TagTemplates),
+		AutoTag:                  api.AutoTagMode(in.AutoTag),
+		Labels:                   copyMap(in.Labels),
+		LabelNamespace:           in.LabelNamespace,
+		Incremental:              in.Incremental,
+		IncrementalFromTag:       in.IncrementalFromTag,
+		PreviousImagePullPolicy:  api.PullPolicy(in.PreviousImagePullPolicy),
+		RemovePreviousImage:      in.RemovePreviousImage,
+		RestoreArtifacts:         in.RestoreArtifacts,
+		SaveArtifactsCompression: api.Compression(in.SaveArtifactsCompression),
+		Environment:              environmentToInternal(in.Environment),
+		EnvironmentFile:          in.EnvironmentFile,
+		StrictEnvironmentFile:    in.StrictEnvironmentFile,
+		RedactEnvPatterns:        copySlice(in.RedactEnvPatterns),
+		BuildArgs:                api.BuildArgList(environmentToInternal(in.BuildArgs)),
+		ScriptsURL:               in.ScriptsURL,
+		ScriptURLs:               copyMap(in.ScriptURLs),
+		ImageScriptsURL:          in.ImageScriptsURL,
+		Destination:              in.Destination,
+		ImageWorkDir:             in.ImageWorkDir,
+		DownloadCacheDir:         in.DownloadCacheDir,
+		DownloadTimeout:          durationOf(in.DownloadTimeout),
+		AssembleUser:             in.AssembleUser,
+		AssembleRuntimeUser:      in.AssembleRuntimeUser,
+		Injections:               volumesToInternal(in.Injections),
+		KeepInjections:           copySlice(in.KeepInjections),
+		BuildVolumes:             copySlice(in.BuildVolumes),
+		DockerNetworkMode:        api.DockerNetworkMode(in.DockerNetworkMode),
+		AddHost:                  copySlice(in.AddHost),
+		DropCapabilities:         copySlice(in.DropCapabilities),
+		SecurityOpt:              copySlice(in.SecurityOpt),
+		Hermetic:                 in.Hermetic,
+		LayeredFallback:          api.LayeredFallbackMode(in.LayeredFallback),
+		Strategy:                 api.BuildStrategy(in.Strategy),
+		KeepLayeredImage:         in.KeepLayeredImage,
+		BlockOnBuild:             in.BlockOnBuild,
+		AllowOnBuild:             in.AllowOnBuild,
+		OnBuildAllowlist:         copySlice(in.OnBuildAllowlist),
+		ContextCompression:       api.Compression(in.ContextCompression),
+		AsDockerfile:             in.AsDockerfile,
+		RunImage:                 in.RunImage,
+		RunPublish:               copySlice(in.RunPublish),
+		RunEnvironment:           environmentToInternal(in.RunEnvironment),
+		RunDetach:                in.RunDetach,
+		ComposeFile:              in.ComposeFile,
+		ComposeService:           in.ComposeService,
+		PostCommitCommand:        in.PostCommitCommand,
+		PostCommitTimeout:        durationOf(in.PostCommitTimeout),
+		VerifyRunScript:          in.VerifyRunScript,
+		VerifyRunScriptPeriod:    durationOf(in.VerifyRunScriptPeriod),
+		Scanner:                  api.Scanner(in.Scanner),
+		ScanSeverityThreshold:    api.Severity(in.ScanSeverityThreshold),
+		PolicyDir:                in.PolicyDir,
+		LockFile:                 in.LockFile,
+		Locked:                   in.Locked,
+		DiffReport:               in.DiffReport,
+		ReportsDir:               in.ReportsDir,
+		MaxBuildDuration:         durationOf(in.MaxBuildDuration),
+		MaxOutputSize:            in.MaxOutputSize,
+		CommitExportFallback:     in.CommitExportFallback,
+		ShutdownGracePeriod:      durationOf(in.ShutdownGracePeriod),
+		PreserveWorkingDir:       in.PreserveWorkingDir,
+		SkipDiskCheck:            in.SkipDiskCheck,
+		CallbackEvents:           copySlice(in.CallbackEvents),
+		CallbackTimeout:          durationOf(in.CallbackTimeout),
+		CallbackHeaders:          copySlice(in.CallbackHeaders),
+		CallbackCertFile:         in.CallbackCertFile,
+		CallbackKeyFile:          in.CallbackKeyFile,
+		CallbackCAFile:           in.CallbackCAFile,
+		TelemetryEndpoint:        in.TelemetryEndpoint,
+		DockerCfgPath:            in.DockerCfgPath,
+		Executor:                 api.Executor(in.Executor),
+	}
+	if in.ExcludeRegExp != nil {
+		out.ExcludeRegExp = *in.ExcludeRegExp
+	}
+	if in.CallbackRetries != nil {
+		out.CallbackRetries = *in.CallbackRetries
+	}
+	if in.CommitRetries != nil {
+		out.CommitRetries = *in.CommitRetries
+	}
+	if in.InjectionParallelism != nil {
+		out.InjectionParallelism = *in.InjectionParallelism
+	}
+	if len(in.CallbackURL) > 0 {
+		out.CallbackURLs = append(out.CallbackURLs, in.CallbackURL)
+	}
+	out.CallbackURLs = append(out.CallbackURLs, in.CallbackURLs...)
+	if len(in.Source) > 0 {
+		source, err := git.Parse(in.Source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source: %v", err)
+		}
+		out.Source = source
+	}
+	for i, spec := range in.Sources {
+		source, err := git.Parse(spec.Source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sources[%d].source: %v", i, err)
+		}
+		out.Sources = append(out.Sources, api.SourceSpec{Source: source, Directory: spec.Directory})
+	}
+	for _, cache := range in.Caches {
+		out.Caches = append(out.Caches, api.CacheSpec{Mount: cache.Mount, KeyFiles: copySlice(cache.KeyFiles)})
+	}
+	if len(in.AllowedUIDs) > 0 {
+		if err := out.AllowedUIDs.Set(in.AllowedUIDs); err != nil {
+			return nil, fmt.Errorf("invalid allowedUIDs: %v", err)
+		}
+	}
+	if in.ScriptDownloadProxyConfig != nil {
+		proxy := &api.ProxyConfig{}
+		var err error
+		if proxy.HTTPProxy, err = parseProxy(in.ScriptDownloadProxyConfig.HTTPProxy); err != nil {
+			return nil, fmt.Errorf("invalid scriptDownloadProxyConfig.httpProxy: %v", err)
+		}
+		if proxy.HTTPSProxy, err = parseProxy(in.ScriptDownloadProxyConfig.HTTPSProxy); err != nil {
+			return nil, fmt.Errorf("invalid scriptDownloadProxyConfig.httpsProxy: %v", err)
+		}
+		out.ScriptDownloadProxyConfig = proxy
+	}
+	if in.CGroupLimits != nil {
+		limits := api.CGroupLimits(*in.CGroupLimits)
+		out.CGroupLimits = &limits
+	}
+	if in.DockerConfig != nil {
+		d := in.DockerConfig
+		out.DockerConfig = &api.DockerConfig{
+			Endpoint:             d.Endpoint,
+			CertFile:             d.CertFile,
+			KeyFile:              d.KeyFile,
+			CAFile:               d.CAFile,
+			UseTLS:               d.UseTLS,
+			TLSVerify:            d.TLSVerify,
+			TLSMinVersion:        d.TLSMinVersion,
+			TLSCipherSuites:      copySlice(d.TLSCipherSuites),
+			SPIFFEEndpointSocket: d.SPIFFEEndpointSocket,
+			Context:              d.Context,
+			Engine:               api.Engine(d.Engine),
+			ContainerdAddress:    d.ContainerdAddress,
+			ContainerdNamespace:  d.ContainerdNamespace,
+			RegistriesConf:       d.RegistriesConf,
+			NamePrefix:           d.NamePrefix,
+			WorkerID:             d.WorkerID,
+			ResourceLabels:       copyMap(d.ResourceLabels),
+			BuildID:              d.BuildID,
+			Offline:              d.Offline,
+			ImageStore:           d.ImageStore,
+		}
+	}
+	if in.Kubernetes != nil {
+		out.Kubernetes = api.KubernetesConfig(*in.Kubernetes)
+	}
+	return out, nil
+}
+
+// FromInternal converts the given internal configuration to the versioned
+// configuration. The credentials and the state computed during the build are
+// not part of the versioned configuration, and are dropped.
+func FromInternal(in *api.Config) *Config {
+	out := &Config{
+		APIVersion:               APIVersion,
+		Kind:                     Kind,
+		DisplayName:              in.DisplayName,
+		Description:              in.Description,
+		BuilderImage:             in.BuilderImage,
+		BuilderPullPolicy:        string(in.BuilderPullPolicy),
+		RuntimeImage:             in.RuntimeImage,
+		RuntimeImagePullPolicy:   string(in.RuntimeImagePullPolicy),
+		RuntimeArtifacts:         volumesFromInternal(in.RuntimeArtifacts),
+		RuntimeEnvironment:       environmentFromInternal(in.RuntimeEnvironment),
+		DryRun:                   in.DryRun,
+		ContextDir:               in.ContextDir,
+		ArtifactPath:             in.ArtifactPath,
+		IgnoreSubmodules:         in.IgnoreSubmodules,
+		PartialClone:             in.PartialClone,
+		ForceCopy:                in.ForceCopy,
+		KeepSymlinks:             in.KeepSymlinks,
+		VerifyCommitSignature:    in.VerifyCommitSignature,
+		Keyring:                  in.Keyring,
+		ExcludeRegExp:            copyPointer(&in.ExcludeRegExp),
+		ExcludeGlobs:             copySlice(in.ExcludeGlobs),
+		IncludeGlobs:             copySlice(in.IncludeGlobs),
+		Ignorers:                 copySlice(in.Ignorers),
+		RenderTemplates:          copySlice(in.RenderTemplates),
+		SymlinkPolicy:            string(in.SymlinkPolicy),
+		ConvertCRLF:              string(in.ConvertCRLF),
+		Tag:                      in.Tag,
+		AdditionalTags:           copySlice(in.AdditionalTags),
+		TagTemplates:             copySlice(in.TagTemplates),
+		AutoTag:                  string(in.AutoTag),
+		Labels:                   copyMap(in.Labels),
+		LabelNamespace:           in.LabelNamespace,
+		Incremental:              in.Incremental,
+		IncrementalFromTag:       in.IncrementalFromTag,
+		PreviousImagePullPolicy:  string(in.PreviousImagePullPolicy),
+		RemovePreviousImage:      in.RemovePreviousImage,
+		RestoreArtifacts:         in.RestoreArtifacts,
+		SaveArtifactsCompression: string(in.SaveArtifactsCompression),
+		Environment:              environmentFromInternal(in.Environment),
+		EnvironmentFile:          in.EnvironmentFile,
+		StrictEnvironmentFile:    in.StrictEnvironmentFile,
+		RedactEnvPatterns:        copySlice(in.RedactEnvPatterns),
+		BuildArgs:                environmentFromInternal(api.EnvironmentList(in.BuildArgs)),
+		ScriptsURL:               in.ScriptsURL,
+		ScriptURLs:               copyMap(in.ScriptURLs),
+		ImageScriptsURL:          in.ImageScriptsURL,
+		Destination:              in.Destination,
+		ImageWorkDir:             in.ImageWorkDir,
+		DownloadCacheDir:         in.DownloadCacheDir,
+		DownloadTimeout:          durationFrom(in.DownloadTimeout),
+		AssembleUser:             in.AssembleUser,
+		AssembleRuntimeUser:      in.AssembleRuntimeUser,
+		AllowedUIDs:              in.AllowedUIDs.String(),
+		Injections:               volumesFromInternal(in.Injections),
+		KeepInjections:           copySlice(in.KeepInjections),
+		InjectionParallelism:     copyPointer(&in.InjectionParallelism),
+		BuildVolumes:             copySlice(in.BuildVolumes),
+		DockerNetworkMode:        string(in.DockerNetworkMode),
+		AddHost:                  copySlice(in.AddHost),
+		DropCapabilities:         copySlice(in.DropCapabilities),
+		SecurityOpt:              copySlice(in.SecurityOpt),
+		Hermetic:                 in.Hermetic,
+		LayeredFallback:          string(in.LayeredFallback),
+		Strategy:                 string(in.Strategy),
+		KeepLayeredImage:         in.KeepLayeredImage,
+		BlockOnBuild:             in.BlockOnBuild,
+		AllowOnBuild:             in.AllowOnBuild,
+		OnBuildAllowlist:         copySlice(in.OnBuildAllowlist),
+		ContextCompression:       string(in.ContextCompression),
+		AsDockerfile:             in.AsDockerfile,
+		RunImage:                 in.RunImage,
+		RunPublish:               copySlice(in.RunPublish),
+		RunEnvironment:           environmentFromInternal(in.RunEnvironment),
+		RunDetach:                in.RunDetach,
+		ComposeFile:              in.ComposeFile,
+		ComposeService:           in.ComposeService,
+		PostCommitCommand:        in.PostCommitCommand,
+		PostCommitTimeout:        durationFrom(in.PostCommitTimeout),
+		VerifyRunScript:          in.VerifyRunScript,
+		VerifyRunScriptPeriod:    durationFrom(in.VerifyRunScriptPeriod),
+		Scanner:                  string(in.Scanner),
+		ScanSeverityThreshold:    string(in.ScanSeverityThreshold),
+		PolicyDir:                in.PolicyDir,
+		LockFile:                 in.LockFile,
+		Locked:                   in.Locked,
+		DiffReport:               in.DiffReport,
+		ReportsDir:               in.ReportsDir,
+		MaxBuildDuration:         durationFrom(in.MaxBuildDuration),
+		MaxOutputSize:            in.MaxOutputSize,
+		CommitRetries:            copyPointer(&in.CommitRetries),
+		CommitExportFallback:     in.CommitExportFallback,
+		ShutdownGracePeriod:      durationFrom(in.ShutdownGracePeriod),
+		PreserveWorkingDir:       in.PreserveWorkingDir,
+		SkipDiskCheck:            in.SkipDiskCheck,
+		CallbackEvents:           copySlice(in.CallbackEvents),
+		CallbackRetries:          copyPointer(&in.CallbackRetries),
+		CallbackTimeout:          durationFrom(in.CallbackTimeout),
+		CallbackHeaders:          copySlice(in.CallbackHeaders),
+		CallbackCertFile:         in.CallbackCertFile,
+		CallbackKeyFile:          in.CallbackKeyFile,
+		CallbackCAFile:           in.CallbackCAFile,
+		TelemetryEndpoint:        in.TelemetryEndpoint,
+		DockerCfgPath:            in.DockerCfgPath,
+		Executor:                 string(in.Executor),
+	}
+	if in.Source != nil {
+		out.Source = in.Source.String()
+	}
+	// the first callback URL is kept in callbackURL for the older versions of
+	// s2i
+	if len(in.CallbackURLs) > 0 {
+		out.CallbackURL = in.CallbackURLs[0]
+	}
+	if len(in.CallbackURLs) > 1 {
+		out.CallbackURLs = copySlice(in.CallbackURLs[1:])
+	}
+	for _, spec := range in.Sources {
+		s := SourceSpec{Directory: spec.Directory}
+		if spec.Source != nil {
+			s.Source = spec.Source.String()
+		}
+		out.Sources = append(out.Sources, s)
+	}
+	for _, cache := range in.Caches {
+		out.Caches = append(out.Caches, CacheSpec{Mount: cache.Mount, KeyFiles: copySlice(cache.KeyFiles)})
+	}
+	if in.ScriptDownloadProxyConfig != nil {
+		out.ScriptDownloadProxyConfig = &ProxyConfig{}
+		if in.ScriptDownloadProxyConfig.HTTPProxy != nil {
+			out.ScriptDownloadProxyConfig.HTTPProxy = in.ScriptDownloadProxyConfig.HTTPProxy.String()
+		}
+		if in.ScriptDownloadProxyConfig.HTTPSProxy != nil {
+			out.ScriptDownloadProxyConfig.HTTPSProxy = in.ScriptDownloadProxyConfig.HTTPSProxy.String()
+		}
+	}
+	if in.CGroupLimits != nil {
+		limits := CGroupLimits(*in.CGroupLimits)
+		out.CGroupLimits = &limits
+	}
+	if in.DockerConfig != nil {
+		d := in.DockerConfig
+		out.DockerConfig = &DockerConfig{
+			Engine:               string(d.Engine),
+			Endpoint:             d.Endpoint,
+			Context:              d.Context,
+			CertFile:             d.CertFile,
+			KeyFile:              d.KeyFile,
+			CAFile:               d.CAFile,
+			UseTLS:               d.UseTLS,
+			TLSVerify:            d.TLSVerify,
+			TLSMinVersion:        d.TLSMinVersion,
+			TLSCipherSuites:      copySlice(d.TLSCipherSuites),
+			SPIFFEEndpointSocket: d.SPIFFEEndpointSocket,
+			ContainerdAddress:    d.ContainerdAddress,
+			ContainerdNamespace:  d.ContainerdNamespace,
+			RegistriesConf:       d.RegistriesConf,
+			NamePrefix:           d.NamePrefix,
+			WorkerID:             d.WorkerID,
+			ResourceLabels:       copyMap(d.ResourceLabels),
+			BuildID:              d.BuildID,
+			Offline:              d.Offline,
+			ImageStore:           d.ImageStore,
+		}
+	}
+	if in.Kubernetes != (api.KubernetesConfig{}) {
+		kubernetes := KubernetesConfig(in.Kubernetes)
+		out.Kubernetes = &kubernetes
+	}
+	return out
+}
+
+// volumesToInternal converts the given volumes to the internal volumes.
+func volumesToInternal(in []VolumeSpec) api.VolumeList {
+	if in == nil {
+		return nil
+	}
+	out := make(api.VolumeList, 0, len(in))
+	for _, v := range in {
+		out = append(out, api.VolumeSpec(v))
+	}
+	return out
+}
+
+// volumesFromInternal converts the given internal volumes to the volumes.
+func volumesFromInternal(in api.VolumeList) []VolumeSpec {
+	if in == nil {
+		return nil
+	}
+	out := make([]VolumeSpec, 0, len(in))
+	for _, v := range in {
+		out = append(out, VolumeSpec(v))
+	}
+	return out
+}
+
+// environmentToInternal converts the given environment to the internal
+// environment.
+func environmentToInternal(in []EnvironmentSpec) api.EnvironmentList {
+	if in == nil {
+		return nil
+	}
+	out := make(api.EnvironmentList, 0, len(in))
+	for _, e := range in {
+		out = append(out, api.EnvironmentSpec(e))
+	}
+	return out
+}
+
+// environmentFromInternal converts the given internal environment to the
+// environment.
+func environmentFromInternal(in api.EnvironmentList) []EnvironmentSpec {
+	if in == nil {
+		return nil
+	}
+	out := make([]EnvironmentSpec, 0, len(in))
+	for _, e := range in {
+		out = append(out, EnvironmentSpec(e))
+	}
+	return out
+}
+
+// durationOf returns the duration of the given optional duration.
+func durationOf(d *Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.Duration
+}
+
+// durationFrom returns the optional duration of the given duration, which is
+// nil when it is zero.
+func durationFrom(d time.Duration) *Duration {
+	if d == 0 {
+		return nil
+	}
+	return &Duration{d}
+}
+
+// parseProxy parses the given proxy URL, which is nil when it is empty.
+func parseProxy(s string) (*url.URL, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return url.Parse(s)
+}
@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+// internalFields are the fields of the internal configuration which are not
+// part of the versioned configuration: the credentials, the settings of the
+// output of s2i and the state computed during the build.
+var internalFields = map[string]bool{
+	"BuilderImageVersion":       true,
+	"BuilderBaseImageVersion":   true,
+	"RuntimeAuthentication":     true,
+	"PullAuthentication":        true,
+	"IncrementalAuthentication": true,
+	"PullCredentials":           true,
+	"IncrementalCredentials":    true,
+	"RuntimeCredentials":        true,
+	"PushCredentials":           true,
+	"LiteralInjections":         true,
+	"BuilderImageLabels":        true,
+	"BuilderImageUser":          true,
+	"BuilderImageID":            true,
+	"BuildDeadline":             true,
+	"WorkingDir":                true,
+	"WorkingSourceDir":          true,
+	"LayeredBuild":              true,
+	"Quiet":                     true,
+	"Progress":                  true,
+	"Color":                     true,
+	"Usage":                     true,
+	"UsageMode":                 true,
+	"HasOnBuild":                true,
+	"SourceInfo":                true,
+}
+
+// fill sets the given value to a non-zero value.
+func fill(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("value")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(int64(time.Minute))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(reflect.ValueOf("key"), reflect.ValueOf("value"))
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				fill(v.Field(i))
+			}
+		}
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	in := &api.Config{}
+	fill(reflect.ValueOf(in).Elem())
+	in.Source = git.MustParse("https://github.com/openshift/source-to-image#v1.4.0")
+	in.Sources = api.SourceList{{Source: git.MustParse("file:///home/user/lib"), Directory: "lib"}}
+	in.AllowedUIDs = nil
+	if err := in.AllowedUIDs.Set("1-,1000-2000"); err != nil {
+		t.Fatal(err)
+	}
+	proxy, _ := url.Parse("http://proxy.example.com:3128")
+	in.ScriptDownloadProxyConfig = &api.ProxyConfig{HTTPProxy: proxy, HTTPSProxy: proxy}
+	in.BuildDeadline = time.Now()
+
+	out, err := ToInternal(FromInternal(in))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	inValue, outValue := reflect.ValueOf(in).Elem(), reflect.ValueOf(out).Elem()
+	for i := 0; i < inValue.NumField(); i++ {
+		name := inValue.Type().Field(i).Name
+		converted := reflect.DeepEqual(inValue.Field(i).Interface(), outValue.Field(i).Interface())
+		switch {
+		case internalFields[name] && converted:
+			t.Errorf("Expected the internal field %s not to be converted", name)
+		case !internalFields[name] && !converted:
+			t.Errorf("Expected %s to be converted, got %#v, expected %#v", name, outValue.Field(i).Interface(), inValue.Field(i).Interface())
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := `{
+	"apiVersion": "s2i.openshift.io/v1",
+	"kind": "BuildConfig",
+	"builderImage": "registry.access.redhat.com/ubi8/python-39",
+	"source": "https://github.com/sclorg/django-ex#main",
+	"sources": [{"source": "https://github.com/sclorg/common", "directory": "common"}],
+	"excludeRegExp": "",
+	"environment": [{"name": "DEBUG", "value": "true"}],
+	"hermetic": true,
+	"maxBuildDuration": "15m",
+	"callbackURL": "https://ci.example.com/hook",
+	"callbackURLs": ["https://chat.example.com/hook"],
+	"dockerConfig": {"endpoint": "unix:///var/run/docker.sock"}
+}`
+	c := &Config{}
+	if err := json.Unmarshal([]byte(data), c); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	SetDefaults(c)
+	config, err := ToInternal(c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := &api.Config{
+		BuilderImage:            "registry.access.redhat.com/ubi8/python-39",
+		BuilderPullPolicy:       api.DefaultBuilderPullPolicy,
+		PreviousImagePullPolicy: api.DefaultPreviousImagePullPolicy,
+		RuntimeImagePullPolicy:  api.DefaultRuntimeImagePullPolicy,
+		Source:                  git.MustParse("https://github.com/sclorg/django-ex#main"),
+		Sources:                 api.SourceList{{Source: git.MustParse("https://github.com/sclorg/common"), Directory: "common"}},
+		Environment:             api.EnvironmentList{{Name: "DEBUG", Value: "true"}},
+		Ignorers:                []string{api.IgnorerS2I},
+		ImageScriptsURL:         DefaultImageScriptsURL,
+		ShutdownGracePeriod:     api.DefaultShutdownGracePeriod,
+		CallbackRetries:         api.DefaultCallbackRetries,
+		CommitRetries:           api.DefaultCommitRetries,
+		InjectionParallelism:    api.DefaultInjectionParallelism,
+		RedactEnvPatterns:       api.DefaultRedactEnvPatterns,
+		Hermetic:                true,
+		DockerNetworkMode:       api.DockerNetworkModeNone,
+		MaxBuildDuration:        15 * time.Minute,
+		CallbackURLs:            []string{"https://ci.example.com/hook", "https://chat.example.com/hook"},
+		DockerConfig:            &api.DockerConfig{Endpoint: "unix:///var/run/docker.sock", Engine: api.EngineDocker},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, config)
+	}
+
+	for expected, data := range map[string]string{
+		"unsupported apiVersion": `{"apiVersion": "s2i.openshift.io/v2"}`,
+		"invalid source":         `{"source": "http://github.com/%"}`,
+		"invalid duration":       `{"maxBuildDuration": 900}`,
+	} {
+		c := &Config{}
+		err := json.Unmarshal([]byte(data), c)
+		if err == nil {
+			_, err = ToInternal(c)
+		}
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an %q error for %s, got %v", expected, data, err)
+		}
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	in := &Config{}
+	fill(reflect.ValueOf(in).Elem())
+	out := in.DeepCopy()
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Expected the copy to be equal, got %#v", out)
+	}
+	// the copy is filled again with other values, which must not change the
+	// original
+	var change func(v reflect.Value)
+	change = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString("changed")
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				change(v.Index(i))
+			}
+		case reflect.Map:
+			for _, k := range v.MapKeys() {
+				v.SetMapIndex(k, reflect.ValueOf("changed"))
+			}
+		case reflect.Ptr:
+			change(v.Elem())
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Field(i).CanSet() {
+					change(v.Field(i))
+				}
+			}
+		}
+	}
+	change(reflect.ValueOf(out).Elem())
+	expected := &Config{}
+	fill(reflect.ValueOf(expected).Elem())
+	if !reflect.DeepEqual(in, expected) {
+		t.Errorf("Expected the original not to be changed by the copy, got %#v", in)
+	}
+}
@@ -0,0 +1,125 @@
+package v1
+
+// DeepCopyInto copies the configuration into out, which shares no memory
+// with it.
+func (in *Config) DeepCopyInto(out *Config) {
+	*out = *in
+	out.RuntimeArtifacts = copySlice(in.RuntimeArtifacts)
+	out.RuntimeEnvironment = copySlice(in.RuntimeEnvironment)
+	out.Sources = copySlice(in.Sources)
+	out.ExcludeRegExp = copyPointer(in.ExcludeRegExp)
+	out.ExcludeGlobs = copySlice(in.ExcludeGlobs)
+	out.IncludeGlobs = copySlice(in.IncludeGlobs)
+	out.Ignorers = copySlice(in.Ignorers)
+	out.RenderTemplates = copySlice(in.RenderTemplates)
+	out.AdditionalTags = copySlice(in.AdditionalTags)
+	out.TagTemplates = copySlice(in.TagTemplates)
+	out.Labels = copyMap(in.Labels)
+	out.Environment = copySlice(in.Environment)
+	out.RedactEnvPatterns = copySlice(in.RedactEnvPatterns)
+	out.BuildArgs = copySlice(in.BuildArgs)
+	out.ScriptURLs = copyMap(in.ScriptURLs)
+	out.ScriptDownloadProxyConfig = copyPointer(in.ScriptDownloadProxyConfig)
+	out.DownloadTimeout = copyPointer(in.DownloadTimeout)
+	out.Injections = copySlice(in.Injections)
+	out.KeepInjections = copySlice(in.KeepInjections)
+	out.InjectionParallelism = copyPointer(in.InjectionParallelism)
+	out.OnBuildAllowlist = copySlice(in.OnBuildAllowlist)
+	out.BuildVolumes = copySlice(in.BuildVolumes)
+	if in.Caches != nil {
+		out.Caches = make([]CacheSpec, len(in.Caches))
+		for i := range in.Caches {
+			in.Caches[i].DeepCopyInto(&out.Caches[i])
+		}
+	}
+	out.AddHost = copySlice(in.AddHost)
+	out.CGroupLimits = copyPointer(in.CGroupLimits)
+	out.DropCapabilities = copySlice(in.DropCapabilities)
+	out.SecurityOpt = copySlice(in.SecurityOpt)
+	out.RunPublish = copySlice(in.RunPublish)
+	out.RunEnvironment = copySlice(in.RunEnvironment)
+	out.PostCommitTimeout = copyPointer(in.PostCommitTimeout)
+	out.VerifyRunScriptPeriod = copyPointer(in.VerifyRunScriptPeriod)
+	out.MaxBuildDuration = copyPointer(in.MaxBuildDuration)
+	out.ShutdownGracePeriod = copyPointer(in.ShutdownGracePeriod)
+	out.CallbackRetries = copyPointer(in.CallbackRetries)
+	out.CommitRetries = copyPointer(in.CommitRetries)
+	out.CallbackTimeout = copyPointer(in.CallbackTimeout)
+	out.CallbackURLs = copySlice(in.CallbackURLs)
+	out.CallbackEvents = copySlice(in.CallbackEvents)
+	out.CallbackHeaders = copySlice(in.CallbackHeaders)
+	if in.DockerConfig != nil {
+		out.DockerConfig = in.DockerConfig.DeepCopy()
+	}
+	out.Kubernetes = copyPointer(in.Kubernetes)
+}
+
+// DeepCopy returns a copy of the configuration which shares no memory with
+// it.
+func (in *Config) DeepCopy() *Config {
+	if in == nil {
+		return nil
+	}
+	out := &Config{}
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the Docker configuration into out, which shares no
+// memory with it.
+func (in *DockerConfig) DeepCopyInto(out *DockerConfig) {
+	*out = *in
+	out.TLSCipherSuites = copySlice(in.TLSCipherSuites)
+	out.ResourceLabels = copyMap(in.ResourceLabels)
+}
+
+// DeepCopy returns a copy of the Docker configuration which shares no memory
+// with it.
+func (in *DockerConfig) DeepCopy() *DockerConfig {
+	if in == nil {
+		return nil
+	}
+	out := &DockerConfig{}
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the cache into out, which shares no memory with it.
+func (in *CacheSpec) DeepCopyInto(out *CacheSpec) {
+	*out = *in
+	out.KeyFiles = copySlice(in.KeyFiles)
+}
+
+// copySlice returns a copy of the given slice of values, which is nil if the
+// slice is nil.
+func copySlice[T any](in []T) []T {
+	if in == nil {
+		return nil
+	}
+	out := make([]T, len(in))
+	copy(out, in)
+	return out
+}
+
+// copyMap returns a copy of the given map, which is nil if the map is nil.
+func copyMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// copyPointer returns a pointer to a copy of the value the given pointer
+// points to, which is nil if the pointer is nil. The value must not hold
+// references.
+func copyPointer[T any](in *T) *T {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	return &out
+}
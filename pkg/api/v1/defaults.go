@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/tar"
+)
+
+// DefaultImageScriptsURL is the default URL of the scripts of the builder
+// image.
+const DefaultImageScriptsURL = "image:///usr/libexec/s2i"
+
+// SetDefaults sets the fields of the given configuration which are not given
+// to the defaults of the flags of s2i build.
+func SetDefaults(c *Config) {
+	if len(c.APIVersion) == 0 {
+		c.APIVersion = APIVersion
+	}
+	if len(c.Kind) == 0 {
+		c.Kind = Kind
+	}
+	if len(c.BuilderPullPolicy) == 0 {
+		c.BuilderPullPolicy = string(api.DefaultBuilderPullPolicy)
+	}
+	if len(c.PreviousImagePullPolicy) == 0 {
+		c.PreviousImagePullPolicy = string(api.DefaultPreviousImagePullPolicy)
+	}
+	if len(c.RuntimeImagePullPolicy) == 0 {
+		c.RuntimeImagePullPolicy = string(api.DefaultRuntimeImagePullPolicy)
+	}
+	if c.ExcludeRegExp == nil {
+		exclude := tar.DefaultExclusionPattern.String()
+		c.ExcludeRegExp = &exclude
+	}
+	if c.Ignorers == nil {
+		c.Ignorers = []string{api.IgnorerS2I}
+	}
+	if len(c.ImageScriptsURL) == 0 {
+		c.ImageScriptsURL = DefaultImageScriptsURL
+	}
+	if c.ShutdownGracePeriod == nil {
+		c.ShutdownGracePeriod = &Duration{api.DefaultShutdownGracePeriod}
+	}
+	if c.CallbackRetries == nil {
+		retries := api.DefaultCallbackRetries
+		c.CallbackRetries = &retries
+	}
+	if c.CommitRetries == nil {
+		retries := api.DefaultCommitRetries
+		c.CommitRetries = &retries
+	}
+	if c.InjectionParallelism == nil {
+		parallelism := api.DefaultInjectionParallelism
+		c.InjectionParallelism = &parallelism
+	}
+	if c.RedactEnvPatterns == nil {
+		c.RedactEnvPatterns = copySlice(api.DefaultRedactEnvPatterns)
+	}
+	// hermetic builds run without network
+	if c.Hermetic && len(c.DockerNetworkMode) == 0 {
+		c.DockerNetworkMode = string(api.DockerNetworkModeNone)
+	}
+	if c.DockerConfig != nil && len(c.DockerConfig.Engine) == 0 {
+		c.DockerConfig.Engine = string(api.EngineDocker)
+	}
+}
@@ -0,0 +1,8 @@
+// Package v1 provides the versioned s2i.openshift.io/v1 schema of the build
+// configuration, which the configurations read and written by s2i and its
+// embedders are serialized with, and its conversion to the internal types of
+// the api package.
+//
+// The fields of the schema are only ever added; the internal types are free
+// to change, as long as the conversion of the existing fields is kept.
+package v1
@@ -0,0 +1,395 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// APIVersion is the version of the schema of the build configuration.
+	APIVersion = "s2i.openshift.io/v1"
+
+	// Kind is the kind of the build configuration.
+	Kind = "BuildConfig"
+)
+
+// Config is the versioned configuration of a build. It holds the settings of
+// the build given by the user, but neither the credentials, which are read
+// from the Docker configuration file, nor the state computed by s2i during the
+// build.
+type Config struct {
+	// APIVersion is s2i.openshift.io/v1. It may be omitted.
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind is BuildConfig. It may be omitted.
+	Kind string `json:"kind,omitempty"`
+
+	// DisplayName and Description are the display-name and description
+	// labels of the resulting image.
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// BuilderImage is the image the sources are built with.
+	BuilderImage string `json:"builderImage"`
+	// BuilderPullPolicy is when to pull the builder image: always, never,
+	// if-not-present or if-changed. Defaults to if-not-present.
+	BuilderPullPolicy string `json:"builderPullPolicy,omitempty"`
+
+	// RuntimeImage is the image the runtime artifacts are copied to, instead
+	// of running the builder image.
+	RuntimeImage string `json:"runtimeImage,omitempty"`
+	// RuntimeImagePullPolicy is when to pull the runtime image. Defaults to
+	// if-not-present.
+	RuntimeImagePullPolicy string `json:"runtimeImagePullPolicy,omitempty"`
+	// RuntimeArtifacts are the files and directories copied from the builder
+	// to the runtime image.
+	RuntimeArtifacts []VolumeSpec `json:"runtimeArtifacts,omitempty"`
+	// RuntimeEnvironment is the environment of the assemble-runtime script
+	// and the runtime image.
+	RuntimeEnvironment []EnvironmentSpec `json:"runtimeEnvironment,omitempty"`
+	// DryRun reports the runtime artifacts without building the image.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Source is the git URL, or the local directory, of the sources, with the
+	// ref to check out as its fragment.
+	Source string `json:"source,omitempty"`
+	// Sources are the additional sources downloaded into subdirectories of
+	// the sources.
+	Sources []SourceSpec `json:"sources,omitempty"`
+	// ContextDir is the subdirectory of the sources the build runs in.
+	ContextDir string `json:"contextDir,omitempty"`
+	// ArtifactPath is the binary artifact uploaded instead of sources.
+	ArtifactPath string `json:"artifactPath,omitempty"`
+	// IgnoreSubmodules, PartialClone, ForceCopy and KeepSymlinks tune how the
+	// sources are fetched.
+	IgnoreSubmodules bool `json:"ignoreSubmodules,omitempty"`
+	PartialClone     bool `json:"partialClone,omitempty"`
+	ForceCopy        bool `json:"forceCopy,omitempty"`
+	KeepSymlinks     bool `json:"keepSymlinks,omitempty"`
+	// VerifyCommitSignature verifies the signature of the commit built
+	// against the keys of the Keyring.
+	VerifyCommitSignature bool   `json:"verifyCommitSignature,omitempty"`
+	Keyring               string `json:"keyring,omitempty"`
+	// ExcludeRegExp is the regular expression of the files excluded from the
+	// sources. Defaults to the .git directory; an empty value excludes no
+	// files.
+	ExcludeRegExp *string `json:"excludeRegExp,omitempty"`
+	// ExcludeGlobs and IncludeGlobs are the gitignore style patterns of the
+	// files excluded from the sources, and included again.
+	ExcludeGlobs []string `json:"excludeGlobs,omitempty"`
+	IncludeGlobs []string `json:"includeGlobs,omitempty"`
+	// Ignorers are the ignore file processors applied to the sources:
+	// s2iignore and gitignore. Defaults to s2iignore.
+	Ignorers []string `json:"ignorers,omitempty"`
+	// RenderTemplates are the patterns of the sources rendered as Go
+	// templates.
+	RenderTemplates []string `json:"renderTemplates,omitempty"`
+	// SymlinkPolicy is how the symbolic links pointing outside of the sources
+	// are handled: preserve, rewrite or error.
+	SymlinkPolicy string `json:"symlinkPolicy,omitempty"`
+	// ConvertCRLF is which files have their CRLF line endings converted:
+	// off, scripts or all.
+	ConvertCRLF string `json:"convertCRLF,omitempty"`
+
+	// Tag is the tag of the resulting image, AdditionalTags and TagTemplates
+	// its other tags, and AutoTag how its tag is derived from the sources:
+	// git-describe, commit or branch.
+	Tag            string   `json:"tag,omitempty"`
+	AdditionalTags []string `json:"additionalTags,omitempty"`
+	TagTemplates   []string `json:"tagTemplates,omitempty"`
+	AutoTag        string   `json:"autoTag,omitempty"`
+	// Labels are the labels of the resulting image, and LabelNamespace the
+	// namespace of the labels generated by s2i.
+	Labels         map[string]string `json:"labels,omitempty"`
+	LabelNamespace string            `json:"labelNamespace,omitempty"`
+
+	// Incremental reuses the artifacts of the previous image, pulled as told
+	// by PreviousImagePullPolicy, or of IncrementalFromTag.
+	Incremental             bool   `json:"incremental,omitempty"`
+	IncrementalFromTag      string `json:"incrementalFromTag,omitempty"`
+	PreviousImagePullPolicy string `json:"previousImagePullPolicy,omitempty"`
+	RemovePreviousImage     bool   `json:"removePreviousImage,omitempty"`
+	// RestoreArtifacts is the archive of artifacts restored before assembling
+	// the sources, and SaveArtifactsCompression the compression requested
+	// from the save-artifacts script: none, gzip, zstd or auto.
+	RestoreArtifacts         string `json:"restoreArtifacts,omitempty"`
+	SaveArtifactsCompression string `json:"saveArtifactsCompression,omitempty"`
+
+	// Environment is the environment of the build, with the variables of
+	// EnvironmentFile, whose malformed lines fail the build with
+	// StrictEnvironmentFile. RedactEnvPatterns are the glob patterns of the
+	// names of the variables whose values are redacted, which default to
+	// *TOKEN*, *PASSWORD* and *SECRET*.
+	Environment           []EnvironmentSpec `json:"environment,omitempty"`
+	EnvironmentFile       string            `json:"environmentFile,omitempty"`
+	StrictEnvironmentFile bool              `json:"strictEnvironmentFile,omitempty"`
+	RedactEnvPatterns     []string          `json:"redactEnvPatterns,omitempty"`
+	// BuildArgs are the build-time variables of the layered and ONBUILD
+	// builds.
+	BuildArgs []EnvironmentSpec `json:"buildArgs,omitempty"`
+
+	// ScriptsURL is the URL of the scripts, ScriptURLs the URLs of the
+	// individual scripts by name, and ImageScriptsURL the URL of the scripts
+	// of the builder image, which defaults to image:///usr/libexec/s2i.
+	ScriptsURL      string            `json:"scriptsURL,omitempty"`
+	ScriptURLs      map[string]string `json:"scriptURLs,omitempty"`
+	ImageScriptsURL string            `json:"imageScriptsURL,omitempty"`
+	// Destination is the directory of the builder image the sources and
+	// scripts are uploaded to.
+	Destination string `json:"destination,omitempty"`
+	// ImageWorkDir is the working directory of the resulting image.
+	ImageWorkDir string `json:"imageWorkDir,omitempty"`
+	// ScriptDownloadProxyConfig is the proxy the scripts are downloaded
+	// through, DownloadCacheDir the cache of the downloaded scripts, and
+	// DownloadTimeout the time each download is allowed to take.
+	ScriptDownloadProxyConfig *ProxyConfig `json:"scriptDownloadProxyConfig,omitempty"`
+	DownloadCacheDir          string       `json:"downloadCacheDir,omitempty"`
+	DownloadTimeout           *Duration    `json:"downloadTimeout,omitempty"`
+
+	// AssembleUser and AssembleRuntimeUser are the users the assemble and
+	// assemble-runtime scripts run as, and AllowedUIDs the ranges of the user
+	// IDs the images are allowed to run as, e.g. 1-,1000-2000.
+	AssembleUser        string `json:"assembleUser,omitempty"`
+	AssembleRuntimeUser string `json:"assembleRuntimeUser,omitempty"`
+	AllowedUIDs         string `json:"allowedUIDs,omitempty"`
+	// Injections are the files injected into the assemble container,
+	// KeepInjections the injected paths kept in the resulting image, and
+	// InjectionParallelism the number of injections uploaded at the same time.
+	Injections           []VolumeSpec `json:"injections,omitempty"`
+	KeepInjections       []string     `json:"keepInjections,omitempty"`
+	InjectionParallelism *int         `json:"injectionParallelism,omitempty"`
+	// BuildVolumes are the volumes mounted into the assemble container, and
+	// Caches the dependency caches.
+	BuildVolumes []string    `json:"buildVolumes,omitempty"`
+	Caches       []CacheSpec `json:"caches,omitempty"`
+	// DockerNetworkMode is the network of the containers: none, bridge,
+	// host, container:<name|id> or netns:/proc/<pid>/ns/net, and AddHost the
+	// entries added to their /etc/hosts.
+	DockerNetworkMode string   `json:"dockerNetworkMode,omitempty"`
+	AddHost           []string `json:"addHost,omitempty"`
+	// CGroupLimits, DropCapabilities and SecurityOpt restrict the
+	// containers.
+	CGroupLimits     *CGroupLimits `json:"cgroupLimits,omitempty"`
+	DropCapabilities []string      `json:"dropCapabilities,omitempty"`
+	SecurityOpt      []string      `json:"securityOpt,omitempty"`
+	// Hermetic runs the build without network, auditing its scripts.
+	Hermetic bool `json:"hermetic,omitempty"`
+
+	// LayeredFallback is when to layer the scripts and sources on top of the
+	// builder image with a docker build: auto, never or always.
+	LayeredFallback string `json:"layeredFallback,omitempty"`
+	// Strategy is the strategy building the image: auto, source, onbuild or
+	// dockerfile.
+	Strategy string `json:"strategy,omitempty"`
+	// KeepLayeredImage keeps the intermediate image of layered builds.
+	KeepLayeredImage bool `json:"keepLayeredImage,omitempty"`
+	// BlockOnBuild fails the builds of builder images with ONBUILD
+	// instructions.
+	BlockOnBuild bool `json:"blockOnBuild,omitempty"`
+	// AllowOnBuild confirms that the ONBUILD instructions of the builder image
+	// are executed, and OnBuildAllowlist are the regular expressions these
+	// instructions must match.
+	AllowOnBuild     bool     `json:"allowOnBuild,omitempty"`
+	OnBuildAllowlist []string `json:"onBuildAllowlist,omitempty"`
+	// ContextCompression is the compression of the build context of the
+	// layered and ONBUILD builds: none, gzip, zstd or auto.
+	ContextCompression string `json:"contextCompression,omitempty"`
+	// AsDockerfile is the Dockerfile written instead of building the image.
+	AsDockerfile string `json:"asDockerfile,omitempty"`
+
+	// RunImage runs the resulting image, publishing RunPublish, with the
+	// RunEnvironment, detached with RunDetach.
+	RunImage       bool              `json:"runImage,omitempty"`
+	RunPublish     []string          `json:"runPublish,omitempty"`
+	RunEnvironment []EnvironmentSpec `json:"runEnvironment,omitempty"`
+	RunDetach      bool              `json:"runDetach,omitempty"`
+	// ComposeFile and ComposeService add the resulting image to a compose
+	// file.
+	ComposeFile    string `json:"composeFile,omitempty"`
+	ComposeService string `json:"composeService,omitempty"`
+	// PostCommitCommand is the command run in the resulting image, retried
+	// for PostCommitTimeout.
+	PostCommitCommand string    `json:"postCommitCommand,omitempty"`
+	PostCommitTimeout *Duration `json:"postCommitTimeout,omitempty"`
+	// VerifyRunScript runs a container of the resulting image, which must
+	// keep running for VerifyRunScriptPeriod or exit successfully.
+	VerifyRunScript       bool      `json:"verifyRunScript,omitempty"`
+	VerifyRunScriptPeriod *Duration `json:"verifyRunScriptPeriod,omitempty"`
+
+	// Scanner scans the resulting image for vulnerabilities: trivy or grype,
+	// failing the build on the vulnerabilities of ScanSeverityThreshold or
+	// higher: low, medium, high or critical.
+	Scanner               string `json:"scanner,omitempty"`
+	ScanSeverityThreshold string `json:"scanSeverityThreshold,omitempty"`
+	// PolicyDir is the directory of the policies the build is evaluated
+	// against.
+	PolicyDir string `json:"policyDir,omitempty"`
+	// LockFile pins the digests of the images, and Locked fails the build
+	// when they changed.
+	LockFile string `json:"lockFile,omitempty"`
+	Locked   bool   `json:"locked,omitempty"`
+	// DiffReport reports the differences with the previous image, and
+	// ReportsDir is the directory the test reports are extracted to.
+	DiffReport bool   `json:"diffReport,omitempty"`
+	ReportsDir string `json:"reportsDir,omitempty"`
+	// MaxBuildDuration and MaxOutputSize limit the build and the size of the
+	// resulting image.
+	MaxBuildDuration *Duration `json:"maxBuildDuration,omitempty"`
+	MaxOutputSize    int64     `json:"maxOutputSize,omitempty"`
+	// CommitRetries is the number of times the commit of the resulting image
+	// is retried, defaulting to 2, and CommitExportFallback exports and
+	// imports the container when it still fails.
+	CommitRetries        *int `json:"commitRetries,omitempty"`
+	CommitExportFallback bool `json:"commitExportFallback,omitempty"`
+	// ShutdownGracePeriod is the time given to the containers to exit when
+	// the build is interrupted. Defaults to 10s.
+	ShutdownGracePeriod *Duration `json:"shutdownGracePeriod,omitempty"`
+	// PreserveWorkingDir keeps the working directory of the build, and
+	// SkipDiskCheck skips the check of its free space.
+	PreserveWorkingDir bool `json:"preserveWorkingDir,omitempty"`
+	SkipDiskCheck      bool `json:"skipDiskCheck,omitempty"`
+	// CallbackURL is called with the result of the build, and
+	// TelemetryEndpoint receives its anonymized usage report.
+	CallbackURL       string `json:"callbackURL,omitempty"`
+	TelemetryEndpoint string `json:"telemetryEndpoint,omitempty"`
+	// CallbackURLs are called besides CallbackURL, and CallbackEvents are the
+	// lifecycle events of the build they are also called upon: started,
+	// assembled or pushed.
+	CallbackURLs   []string `json:"callbackURLs,omitempty"`
+	CallbackEvents []string `json:"callbackEvents,omitempty"`
+	// CallbackRetries is the number of times the callback is retried when it
+	// cannot be delivered, defaulting to 2, and CallbackTimeout the time each
+	// attempt is given.
+	CallbackRetries *int      `json:"callbackRetries,omitempty"`
+	CallbackTimeout *Duration `json:"callbackTimeout,omitempty"`
+	// CallbackHeaders are added to the callback requests in the "Name: value"
+	// form, CallbackCertFile and CallbackKeyFile are the client certificate
+	// authenticating them, and CallbackCAFile verifies the callback URL.
+	CallbackHeaders  []string `json:"callbackHeaders,omitempty"`
+	CallbackCertFile string   `json:"callbackCertFile,omitempty"`
+	CallbackKeyFile  string   `json:"callbackKeyFile,omitempty"`
+	CallbackCAFile   string   `json:"callbackCAFile,omitempty"`
+
+	// DockerConfig is how the container engine is reached, and DockerCfgPath
+	// the Docker configuration file holding the credentials of the
+	// registries.
+	DockerConfig  *DockerConfig `json:"dockerConfig,omitempty"`
+	DockerCfgPath string        `json:"dockerCfgPath,omitempty"`
+	// Executor is where the build runs: local, or kubernetes with the
+	// Kubernetes settings.
+	Executor   string            `json:"executor,omitempty"`
+	Kubernetes *KubernetesConfig `json:"kubernetes,omitempty"`
+}
+
+// DockerConfig is how the container engine is reached.
+type DockerConfig struct {
+	// Engine is docker or containerd. Defaults to docker.
+	Engine string `json:"engine,omitempty"`
+	// Endpoint is the endpoint of the Docker daemon, or Context the Docker
+	// context whose endpoint is used.
+	Endpoint string `json:"endpoint,omitempty"`
+	Context  string `json:"context,omitempty"`
+	// CertFile, KeyFile and CAFile are the files of the TLS connection.
+	CertFile        string   `json:"certFile,omitempty"`
+	KeyFile         string   `json:"keyFile,omitempty"`
+	CAFile          string   `json:"caFile,omitempty"`
+	UseTLS          bool     `json:"useTLS,omitempty"`
+	TLSVerify       bool     `json:"tlsVerify,omitempty"`
+	TLSMinVersion   string   `json:"tlsMinVersion,omitempty"`
+	TLSCipherSuites []string `json:"tlsCipherSuites,omitempty"`
+	// SPIFFEEndpointSocket is the SPIFFE Workload API socket the TLS
+	// certificates are fetched from.
+	SPIFFEEndpointSocket string `json:"spiffeEndpointSocket,omitempty"`
+	// ContainerdAddress, ContainerdNamespace and RegistriesConf configure
+	// the containerd engine.
+	ContainerdAddress   string `json:"containerdAddress,omitempty"`
+	ContainerdNamespace string `json:"containerdNamespace,omitempty"`
+	RegistriesConf      string `json:"registriesConf,omitempty"`
+	// NamePrefix, WorkerID, ResourceLabels and BuildID name and label the
+	// containers and temporary images created by s2i.
+	NamePrefix     string            `json:"namePrefix,omitempty"`
+	WorkerID       string            `json:"workerID,omitempty"`
+	ResourceLabels map[string]string `json:"resourceLabels,omitempty"`
+	BuildID        string            `json:"buildID,omitempty"`
+	// Offline forbids network access, loading the missing images from the
+	// ImageStore.
+	Offline    bool   `json:"offline,omitempty"`
+	ImageStore string `json:"imageStore,omitempty"`
+}
+
+// EnvironmentSpec is an environment variable or a build argument.
+type EnvironmentSpec struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// VolumeSpec is a file or directory copied, or injected, to a destination.
+type VolumeSpec struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination,omitempty"`
+	Keep        bool   `json:"keep,omitempty"`
+}
+
+// SourceSpec is an additional source downloaded into a subdirectory of the
+// sources.
+type SourceSpec struct {
+	Source    string `json:"source"`
+	Directory string `json:"directory"`
+}
+
+// CacheSpec is a dependency cache mounted into the assemble container, keyed
+// by the content of the KeyFiles of the sources.
+type CacheSpec struct {
+	Mount    string   `json:"mount"`
+	KeyFiles []string `json:"keyFiles,omitempty"`
+}
+
+// CGroupLimits are the resource limits of the containers.
+type CGroupLimits struct {
+	MemoryLimitBytes int64  `json:"memoryLimitBytes,omitempty"`
+	CPUShares        int64  `json:"cpuShares,omitempty"`
+	CPUPeriod        int64  `json:"cpuPeriod,omitempty"`
+	CPUQuota         int64  `json:"cpuQuota,omitempty"`
+	MemorySwap       int64  `json:"memorySwap,omitempty"`
+	Parent           string `json:"parent,omitempty"`
+}
+
+// ProxyConfig is the proxy of the downloads of the scripts.
+type ProxyConfig struct {
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+}
+
+// KubernetesConfig is the configuration of the kubernetes executor.
+type KubernetesConfig struct {
+	Kubeconfig   string `json:"kubeconfig,omitempty"`
+	Namespace    string `json:"namespace,omitempty"`
+	S2IImage     string `json:"s2iImage,omitempty"`
+	BuildahImage string `json:"buildahImage,omitempty"`
+	PushSecret   string `json:"pushSecret,omitempty"`
+}
+
+// Duration is a duration serialized as a string in the format of
+// time.ParseDuration, e.g. 1m30s.
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON serializes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// UnmarshalJSON parses the duration from a string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s, must be a string such as \"1m30s\"", data)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
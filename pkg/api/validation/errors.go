@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorType is a machine readable value providing more detail about why a field
+// is invalid.
+type ErrorType string
+
+const (
+	// ErrorTypeRequired is used to report required values that are not provided
+	// (e.g. empty strings, null values, or empty arrays).
+	ErrorTypeRequired ErrorType = "FieldValueRequired"
+
+	// ErrorInvalidValue is used to report values that do not conform to the
+	// expected schema.
+	ErrorInvalidValue ErrorType = "InvalidValue"
+
+	// ErrorTypeNotSupported is used to report values that are not one of the
+	// supported values of an enumeration.
+	ErrorTypeNotSupported ErrorType = "FieldValueNotSupported"
+
+	// ErrorTypeConflict is used to report values that are valid on their own,
+	// but cannot be used together with the values of other fields.
+	ErrorTypeConflict ErrorType = "FieldValueConflict"
+
+	// ErrorTypeForbidden is used to report values that are valid, but which
+	// the configuration forbids, e.g. network access with --offline.
+	ErrorTypeForbidden ErrorType = "FieldValueForbidden"
+)
+
+// Error is an implementation of the 'error' interface, which represents an
+// error of validation. Field is the JSON path of the invalid field, e.g.
+// sources[1].directory or scriptURLs[run].
+type Error struct {
+	Type   ErrorType `json:"type"`
+	Field  string    `json:"field"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (v Error) Error() string {
+	var msg string
+	switch v.Type {
+	case ErrorInvalidValue:
+		msg = fmt.Sprintf("Invalid value specified for %q", v.Field)
+	case ErrorTypeRequired:
+		msg = fmt.Sprintf("Required value not specified for %q", v.Field)
+	case ErrorTypeNotSupported:
+		msg = fmt.Sprintf("Unsupported value specified for %q", v.Field)
+	case ErrorTypeConflict:
+		msg = fmt.Sprintf("Conflicting value specified for %q", v.Field)
+	case ErrorTypeForbidden:
+		msg = fmt.Sprintf("Forbidden value specified for %q", v.Field)
+	default:
+		msg = fmt.Sprintf("%s: %s", v.Type, v.Field)
+	}
+	if len(v.Reason) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, v.Reason)
+	}
+	return msg
+}
+
+// NewFieldRequired returns a *ValidationError indicating "value required"
+func NewFieldRequired(field string) Error {
+	return Error{Type: ErrorTypeRequired, Field: field}
+}
+
+// NewFieldInvalidValue returns a ValidationError indicating "invalid value"
+func NewFieldInvalidValue(field string) Error {
+	return Error{Type: ErrorInvalidValue, Field: field}
+}
+
+// NewFieldInvalidValueWithReason returns a ValidationError indicating "invalid value" and a reason for the error
+func NewFieldInvalidValueWithReason(field, reason string) Error {
+	return Error{Type: ErrorInvalidValue, Field: field, Reason: reason}
+}
+
+// NewFieldNotSupported returns a ValidationError indicating "unsupported
+// value", listing the supported values
+func NewFieldNotSupported(field, value string, supported ...string) Error {
+	quoted := make([]string, 0, len(supported))
+	for _, s := range supported {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	reason := fmt.Sprintf("%q is not one of %s", value, strings.Join(quoted, ", "))
+	return Error{Type: ErrorTypeNotSupported, Field: field, Reason: reason}
+}
+
+// NewFieldConflict returns a ValidationError indicating "conflicting value"
+// and the other fields it conflicts with
+func NewFieldConflict(field, reason string) Error {
+	return Error{Type: ErrorTypeConflict, Field: field, Reason: reason}
+}
+
+// NewFieldForbidden returns a ValidationError indicating "forbidden value" and
+// a reason for the error
+func NewFieldForbidden(field, reason string) Error {
+	return Error{Type: ErrorTypeForbidden, Field: field, Reason: reason}
+}
+
+// fieldIndex returns the path of the element of the given index of a list
+// field, e.g. buildVolumes[1].
+func fieldIndex(field string, i int) string {
+	return fmt.Sprintf("%s[%d]", field, i)
+}
+
+// fieldKey returns the path of the value of the given key of a map field, e.g.
+// scriptURLs[run].
+func fieldKey(field, key string) string {
+	return fmt.Sprintf("%s[%s]", field, key)
+}
@@ -2,30 +2,309 @@ package validation
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/distribution/reference"
+	"github.com/docker/go-connections/nat"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/hermetic"
+	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
 )
 
-// ValidateConfig returns a list of error from validation.
+// buildArgNameRegexp matches the valid names of build arguments
+var buildArgNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// namePartRegexp matches the name prefix and the worker ID, which must be
+// valid in both container and image names.
+var namePartRegexp = regexp.MustCompile(`^[a-z0-9]+([._-][a-z0-9]+)*$`)
+
+// supported values of the enumerations of the configuration
+var (
+	pullPolicies    = []string{string(api.PullNever), string(api.PullAlways), string(api.PullIfNotPresent), string(api.PullIfChanged)}
+	engines         = []string{string(api.EngineDocker), string(api.EngineContainerd)}
+	compressions    = []string{string(api.CompressionNone), string(api.CompressionGzip), string(api.CompressionZstd), string(api.CompressionAuto)}
+	symlinkPolicies = []string{string(api.SymlinkPreserve), string(api.SymlinkRewrite), string(api.SymlinkError)}
+	crlfConversions = []string{string(api.CRLFConversionOff), string(api.CRLFConversionScripts), string(api.CRLFConversionAll)}
+	ignorers        = []string{api.IgnorerS2I, api.IgnorerGit}
+	callbackEvents  = []string{api.CallbackEventStarted, api.CallbackEventAssembled, api.CallbackEventPushed}
+	scripts         = []string{constants.Assemble, constants.AssembleRuntime, constants.Run, constants.SaveArtifacts, constants.Usage}
+	scanners        = []string{string(api.ScannerTrivy), string(api.ScannerGrype)}
+	severities      = []string{string(api.SeverityLow), string(api.SeverityMedium), string(api.SeverityHigh), string(api.SeverityCritical)}
+	autoTagModes    = []string{string(api.AutoTagGitDescribe), string(api.AutoTagCommit), string(api.AutoTagBranch)}
+	strategies      = []string{string(api.StrategyAuto), string(api.StrategySource), string(api.StrategyOnBuild), string(api.StrategyDockerfile)}
+)
+
+// ValidateConfig returns a list of error from validation. The fields of the
+// errors are the JSON paths of the invalid fields of the configuration.
 func ValidateConfig(config *api.Config) []Error {
 	allErrs := []Error{}
 	if len(config.BuilderImage) == 0 {
 		allErrs = append(allErrs, NewFieldRequired("builderImage"))
 	}
-	switch config.BuilderPullPolicy {
-	case api.PullNever, api.PullAlways, api.PullIfNotPresent:
-	default:
-		allErrs = append(allErrs, NewFieldInvalidValue("builderPullPolicy"))
+	if !oneOf(string(config.BuilderPullPolicy), pullPolicies) {
+		allErrs = append(allErrs, NewFieldNotSupported("builderPullPolicy", string(config.BuilderPullPolicy), pullPolicies...))
 	}
-	if config.DockerConfig == nil || len(config.DockerConfig.Endpoint) == 0 {
+	if config.DockerConfig == nil {
 		allErrs = append(allErrs, NewFieldRequired("dockerConfig.endpoint"))
+	} else {
+		switch config.DockerConfig.Engine {
+		case "", api.EngineDocker:
+			if len(config.DockerConfig.Endpoint) == 0 {
+				allErrs = append(allErrs, NewFieldRequired("dockerConfig.endpoint"))
+			}
+		case api.EngineContainerd:
+			if len(config.DockerConfig.ContainerdAddress) == 0 {
+				allErrs = append(allErrs, NewFieldRequired("dockerConfig.containerdAddress"))
+			}
+		default:
+			allErrs = append(allErrs, NewFieldNotSupported("dockerConfig.engine", string(config.DockerConfig.Engine), engines...))
+		}
+		if len(config.DockerConfig.NamePrefix) > 0 && !namePartRegexp.MatchString(config.DockerConfig.NamePrefix) {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason("dockerConfig.namePrefix", "must consist of lower case alphanumeric characters separated by '.', '_' or '-'"))
+		}
+		if len(config.DockerConfig.WorkerID) > 0 && !namePartRegexp.MatchString(config.DockerConfig.WorkerID) {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason("dockerConfig.workerID", "must consist of lower case alphanumeric characters separated by '.', '_' or '-'"))
+		}
+		if len(config.DockerConfig.ImageStore) > 0 && !config.DockerConfig.Offline {
+			allErrs = append(allErrs, NewFieldConflict("dockerConfig.imageStore", "the image store is only used with --offline"))
+		}
+		if config.DockerConfig.Offline {
+			allErrs = append(allErrs, validateOffline(config)...)
+		}
 	}
-	if config.DockerNetworkMode != "" && !validateDockerNetworkMode(config.DockerNetworkMode) {
+	if config.DockerNetworkMode != "" && !config.DockerNetworkMode.IsValid() {
 		allErrs = append(allErrs, NewFieldInvalidValue("dockerNetworkMode"))
 	}
+	if config.DockerNetworkMode.IsContainer() && len(config.AddHost) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("addHost", "hosts cannot be added to containers sharing the network of another container"))
+	}
+	if config.Hermetic && config.DockerNetworkMode != api.DockerNetworkModeNone {
+		allErrs = append(allErrs, NewFieldConflict("dockerNetworkMode", "hermetic builds run without network"))
+	}
+	if config.Hermetic && config.DockerConfig != nil && config.DockerConfig.Engine == api.EngineContainerd {
+		allErrs = append(allErrs, NewFieldConflict("hermetic", "hermetic builds require the docker engine"))
+	}
+	if config.Hermetic && config.DockerConfig != nil && config.DockerConfig.Engine != api.EngineContainerd && len(config.DockerConfig.Context) == 0 && !hermetic.LocalEndpoint(config.DockerConfig.Endpoint) {
+		allErrs = append(allErrs, NewFieldConflict("dockerConfig.endpoint", "hermetic builds require a container engine running on this host"))
+	}
+	if config.Hermetic && len(config.AsDockerfile) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("hermetic", "the image must be built to audit its scripts"))
+	}
+	if len(config.ContextCompression) > 0 && !oneOf(string(config.ContextCompression), compressions) {
+		allErrs = append(allErrs, NewFieldNotSupported("contextCompression", string(config.ContextCompression), compressions...))
+	}
+	if len(config.SaveArtifactsCompression) > 0 && !oneOf(string(config.SaveArtifactsCompression), compressions) {
+		allErrs = append(allErrs, NewFieldNotSupported("saveArtifactsCompression", string(config.SaveArtifactsCompression), compressions...))
+	}
+	switch {
+	case len(config.Strategy) > 0 && !oneOf(string(config.Strategy), strategies):
+		allErrs = append(allErrs, NewFieldNotSupported("strategy", string(config.Strategy), strategies...))
+	case config.Strategy == api.StrategyDockerfile && len(config.AsDockerfile) == 0:
+		allErrs = append(allErrs, NewFieldRequired("asDockerfile"))
+	case len(config.AsDockerfile) > 0 && (config.Strategy == api.StrategySource || config.Strategy == api.StrategyOnBuild):
+		allErrs = append(allErrs, NewFieldConflict("strategy", fmt.Sprintf("the %s strategy builds an image instead of writing a Dockerfile", config.Strategy)))
+	}
+	if len(config.Caches) > 0 && len(config.AsDockerfile) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("caches", "the caches are mounted in the container running the assemble script"))
+	}
+	if config.Incremental && len(config.RuntimeImage) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("incremental", "incremental builds with a runtime image are not supported"))
+	}
+	if len(config.AsDockerfile) > 0 && config.RunImage {
+		allErrs = append(allErrs, NewFieldConflict("runImage", "the image must be built to be run"))
+	}
+	if len(config.AsDockerfile) > 0 && len(config.RuntimeImage) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("runtimeImage", "builds with a runtime image cannot be written as a Dockerfile"))
+	}
+	if config.Usage && (config.Source != nil || len(config.Sources) > 0) {
+		allErrs = append(allErrs, NewFieldConflict("source", "the usage of the builder image is printed without building sources"))
+	}
+	if len(config.RestoreArtifacts) > 0 && config.Incremental {
+		allErrs = append(allErrs, NewFieldConflict("restoreArtifacts", "the restored artifacts replace the ones of the previous image of incremental builds"))
+	}
+	if len(config.RestoreArtifacts) > 0 && len(config.AsDockerfile) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("restoreArtifacts", "the image must be built to restore the artifacts"))
+	}
+	if config.Executor == api.ExecutorKubernetes {
+		allErrs = append(allErrs, validateKubernetesExecutor(config)...)
+	} else if len(config.PushCredentials.Username) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("pushCredentials", "only the kubernetes executor pushes the image it builds"))
+	}
+	if len(config.SymlinkPolicy) > 0 && !oneOf(string(config.SymlinkPolicy), symlinkPolicies) {
+		allErrs = append(allErrs, NewFieldNotSupported("symlinkPolicy", string(config.SymlinkPolicy), symlinkPolicies...))
+	}
+	for i, volume := range config.BuildVolumes {
+		if _, err := hostpath.TranslateBind(volume); err != nil {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("buildVolumes", i), err.Error()))
+		}
+	}
+	if len(config.ConvertCRLF) > 0 && !oneOf(string(config.ConvertCRLF), crlfConversions) {
+		allErrs = append(allErrs, NewFieldNotSupported("convertCRLF", string(config.ConvertCRLF), crlfConversions...))
+	}
+	if len(config.Keyring) > 0 && !config.VerifyCommitSignature {
+		allErrs = append(allErrs, NewFieldConflict("keyring", "the keyring is only used with --verify-commit-signature"))
+	}
+	for i, keep := range config.KeepInjections {
+		if !path.IsAbs(filepath.ToSlash(keep)) {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("keepInjections", i), fmt.Sprintf("path %q must be absolute", keep)))
+		}
+	}
+	if config.InjectionParallelism < 0 {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("injectionParallelism", "must not be negative"))
+	}
+	for i, ignorer := range config.Ignorers {
+		if !oneOf(ignorer, ignorers) {
+			allErrs = append(allErrs, NewFieldNotSupported(fieldIndex("ignorers", i), ignorer, ignorers...))
+		}
+	}
+	for i, pattern := range config.OnBuildAllowlist {
+		if _, err := regexp.Compile(pattern); err != nil {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("onBuildAllowlist", i), err.Error()))
+		}
+	}
+	if len(config.OnBuildAllowlist) > 0 && !config.AllowOnBuild {
+		allErrs = append(allErrs, NewFieldConflict("onBuildAllowlist", "the allowlist restricts the ONBUILD instructions executed with allowOnBuild"))
+	}
+	for i, pattern := range config.RedactEnvPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("redactEnvPatterns", i), err.Error()))
+		}
+	}
+	if _, err := ignore.NewGlobMatcher(config.ExcludeGlobs, config.IncludeGlobs); err != nil {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("excludeGlobs", err.Error()))
+	}
+	// the default expression, which excludes the .git directory, is kept with
+	// the globs
+	if len(config.ExcludeRegExp) > 0 && config.ExcludeRegExp != tar.DefaultExclusionPattern.String() && (len(config.ExcludeGlobs) > 0 || len(config.IncludeGlobs) > 0) {
+		allErrs = append(allErrs, NewFieldConflict("excludeRegExp", "the regular expression cannot be used with the exclude and include globs"))
+	}
+	directories := map[string]bool{}
+	for i, spec := range config.Sources {
+		directory := path.Clean(filepath.ToSlash(spec.Directory))
+		switch {
+		case spec.Source == nil:
+			allErrs = append(allErrs, NewFieldRequired(fieldIndex("sources", i)+".source"))
+		case len(spec.Directory) == 0 || directory == "." || path.IsAbs(directory) || filepath.IsAbs(spec.Directory) || directory == ".." || strings.HasPrefix(directory, "../"):
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("sources", i)+".directory", fmt.Sprintf("the directory %q of %s must be a subdirectory of the sources", spec.Directory, spec.Source)))
+		case directories[directory]:
+			allErrs = append(allErrs, NewFieldConflict(fieldIndex("sources", i)+".directory", fmt.Sprintf("the directory %q is given to several sources", spec.Directory)))
+		}
+		directories[directory] = true
+	}
+	if len(config.RuntimeEnvironment) > 0 && len(config.RuntimeImage) == 0 {
+		allErrs = append(allErrs, NewFieldConflict("runtimeEnvironment", "the runtime environment is the one of the runtime image"))
+	}
+	if config.DryRun && len(config.RuntimeImage) == 0 {
+		allErrs = append(allErrs, NewFieldConflict("dryRun", "dry runs preview the runtime artifacts of a build with a runtime image"))
+	}
+	if len(config.ArtifactPath) > 0 && config.Source != nil {
+		allErrs = append(allErrs, NewFieldConflict("artifactPath", "a binary build cannot have a source"))
+	}
+	if _, err := ignore.NewGlobMatcher(config.RenderTemplates, nil); err != nil {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("renderTemplates", err.Error()))
+	}
+	for _, script := range sortedKeys(config.ScriptURLs) {
+		field, scriptURL := fieldKey("scriptURLs", script), config.ScriptURLs[script]
+		if !oneOf(script, scripts) {
+			allErrs = append(allErrs, NewFieldNotSupported(field, script, scripts...))
+			continue
+		}
+		u, err := url.ParseRequestURI(scriptURL)
+		if err != nil {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(field, fmt.Sprintf("invalid URL %q of the %s script", scriptURL, script)))
+			continue
+		}
+		switch u.Scheme {
+		case "http", "https", "file":
+		default:
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(field, fmt.Sprintf("unsupported URL %q of the %s script, must be an http, https or file URL", scriptURL, script)))
+		}
+	}
+	for i, arg := range config.BuildArgs {
+		if !buildArgNameRegexp.MatchString(arg.Name) {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("buildArgs", i)+".name", fmt.Sprintf("invalid build argument name %q", arg.Name)))
+		}
+	}
+	if len(config.Scanner) > 0 && !oneOf(string(config.Scanner), scanners) {
+		allErrs = append(allErrs, NewFieldNotSupported("scanner", string(config.Scanner), scanners...))
+	}
+	switch {
+	case len(config.ScanSeverityThreshold) == 0:
+	case !oneOf(string(config.ScanSeverityThreshold), severities):
+		allErrs = append(allErrs, NewFieldNotSupported("scanSeverityThreshold", string(config.ScanSeverityThreshold), severities...))
+	case len(config.Scanner) == 0:
+		allErrs = append(allErrs, NewFieldConflict("scanSeverityThreshold", "a scanner must be set"))
+	}
+	for i, port := range config.RunPublish {
+		if _, _, err := nat.ParsePortSpecs([]string{port}); err != nil {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("runPublish", i), err.Error()))
+		}
+	}
+	if !config.RunImage && (config.RunDetach || len(config.ComposeFile) == 0 && (len(config.RunPublish) > 0 || len(config.RunEnvironment) > 0)) {
+		allErrs = append(allErrs, NewFieldConflict("runImage", "the image must be run to publish its ports, set its environment or detach it"))
+	}
+	if len(config.ComposeFile) > 0 && len(config.AsDockerfile) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("composeFile", "the image must be built to be added to a compose file"))
+	}
+	if len(config.TelemetryEndpoint) > 0 && !isRemoteURL(config.TelemetryEndpoint) {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("telemetryEndpoint", "must be an http or https URL"))
+	}
+	for i, event := range config.CallbackEvents {
+		if !oneOf(event, callbackEvents) {
+			allErrs = append(allErrs, NewFieldNotSupported(fieldIndex("callbackEvents", i), event, callbackEvents...))
+		} else if event == api.CallbackEventPushed && config.Executor != api.ExecutorKubernetes {
+			allErrs = append(allErrs, NewFieldConflict(fieldIndex("callbackEvents", i), "only the kubernetes executor pushes the image it builds"))
+		}
+	}
+	if len(config.CallbackEvents) > 0 && len(config.CallbackURLs) == 0 {
+		allErrs = append(allErrs, NewFieldConflict("callbackEvents", "the events are notified to the callbackURLs"))
+	}
+	if config.CallbackRetries < 0 {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("callbackRetries", "must not be negative"))
+	}
+	if config.CallbackTimeout < 0 {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("callbackTimeout", "must not be negative"))
+	}
+	for i, header := range config.CallbackHeaders {
+		if name, _, ok := strings.Cut(header, ":"); !ok || len(strings.TrimSpace(name)) == 0 {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("callbackHeaders", i), "must be in the Name: value form"))
+		}
+	}
+	if len(config.CallbackCertFile) > 0 && len(config.CallbackKeyFile) == 0 {
+		allErrs = append(allErrs, NewFieldRequired("callbackKeyFile"))
+	}
+	if len(config.CallbackKeyFile) > 0 && len(config.CallbackCertFile) == 0 {
+		allErrs = append(allErrs, NewFieldRequired("callbackCertFile"))
+	}
+	if config.MaxBuildDuration < 0 {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("maxBuildDuration", "must not be negative"))
+	}
+	if config.MaxOutputSize < 0 {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("maxOutputSize", "must not be negative"))
+	}
+	if config.CommitRetries < 0 {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("commitRetries", "must not be negative"))
+	}
+	if config.MaxOutputSize > 0 && len(config.AsDockerfile) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("maxOutputSize", "the image must be built to check its size"))
+	}
+	if config.VerifyRunScriptPeriod < 0 {
+		allErrs = append(allErrs, NewFieldInvalidValueWithReason("verifyRunScriptPeriod", "must not be negative"))
+	}
+	if config.VerifyRunScript && len(config.AsDockerfile) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("verifyRunScript", "the image must be built to run its run script"))
+	}
 	if config.Labels != nil {
 		for k := range config.Labels {
 			if len(k) == 0 {
@@ -37,80 +316,151 @@ func ValidateConfig(config *api.Config) []Error {
 		if err := validateDockerReference(config.Tag); err != nil {
 			allErrs = append(allErrs, NewFieldInvalidValueWithReason("tag", err.Error()))
 		}
+	} else if len(config.AdditionalTags) > 0 || len(config.TagTemplates) > 0 || len(config.AutoTag) > 0 {
+		allErrs = append(allErrs, NewFieldRequired("tag"))
+	}
+	if len(config.AutoTag) > 0 && !oneOf(string(config.AutoTag), autoTagModes) {
+		allErrs = append(allErrs, NewFieldNotSupported("autoTag", string(config.AutoTag), autoTagModes...))
+	}
+	for i, tag := range config.AdditionalTags {
+		if err := validateDockerReference(tag); err != nil {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("additionalTags", i), err.Error()))
+		}
+	}
+	for i, text := range config.TagTemplates {
+		if _, err := template.New("tag").Parse(text); err != nil {
+			allErrs = append(allErrs, NewFieldInvalidValueWithReason(fieldIndex("tagTemplates", i), err.Error()))
+		}
 	}
 	return allErrs
 }
 
-// validateDockerNetworkMode checks wether the network mode conforms to the docker remote API specification (v1.19)
-// Supported values are: bridge, host, container:<name|id>, and netns:/proc/<pid>/ns/net
-func validateDockerNetworkMode(mode api.DockerNetworkMode) bool {
-	switch mode {
-	case api.DockerNetworkModeBridge, api.DockerNetworkModeHost:
-		return true
-	}
-	if strings.HasPrefix(string(mode), api.DockerNetworkModeContainerPrefix) {
-		return true
-	}
-	if strings.HasPrefix(string(mode), api.DockerNetworkModeNetworkNamespacePrefix) {
-		return true
+// oneOf returns true if the given value is one of the supported values.
+func oneOf(value string, supported []string) bool {
+	for _, s := range supported {
+		if value == s {
+			return true
+		}
 	}
 	return false
 }
 
+// sortedKeys returns the keys of the given map in order, so that the errors
+// of its values are reported in a stable order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func validateDockerReference(ref string) error {
 	_, err := reference.Parse(ref)
 	return err
 }
 
-// NewFieldRequired returns a *ValidationError indicating "value required"
-func NewFieldRequired(field string) Error {
-	return Error{Type: ErrorTypeRequired, Field: field}
-}
+// offlineReason is the reason of the errors of the inputs of offline builds
+// which would be fetched over the network.
+const offlineReason = "requires network access, which --offline forbids"
 
-// NewFieldInvalidValue returns a ValidationError indicating "invalid value"
-func NewFieldInvalidValue(field string) Error {
-	return Error{Type: ErrorInvalidValue, Field: field}
+// validateOffline returns an error for each input of the build which would
+// require network access, so that all of them are reported at once.
+func validateOffline(config *api.Config) []Error {
+	allErrs := []Error{}
+	if config.Source != nil && !config.Source.IsLocal() {
+		allErrs = append(allErrs, NewFieldForbidden("source", fmt.Sprintf("fetching %s %s", config.Source, offlineReason)))
+	}
+	for i, spec := range config.Sources {
+		if spec.Source != nil && !spec.Source.IsLocal() {
+			allErrs = append(allErrs, NewFieldForbidden(fieldIndex("sources", i)+".source", fmt.Sprintf("fetching %s %s", spec.Source, offlineReason)))
+		}
+	}
+	if isRemoteURL(config.ScriptsURL) {
+		allErrs = append(allErrs, NewFieldForbidden("scriptsURL", fmt.Sprintf("downloading the scripts from %s %s", config.ScriptsURL, offlineReason)))
+	}
+	for _, script := range sortedKeys(config.ScriptURLs) {
+		if u := config.ScriptURLs[script]; isRemoteURL(u) {
+			allErrs = append(allErrs, NewFieldForbidden(fieldKey("scriptURLs", script), fmt.Sprintf("downloading the %s script from %s %s", script, u, offlineReason)))
+		}
+	}
+	if isRemoteURL(config.ImageScriptsURL) {
+		allErrs = append(allErrs, NewFieldForbidden("imageScriptsURL", fmt.Sprintf("downloading the scripts from %s %s", config.ImageScriptsURL, offlineReason)))
+	}
+	for i, callbackURL := range config.CallbackURLs {
+		allErrs = append(allErrs, NewFieldForbidden(fieldIndex("callbackURLs", i), fmt.Sprintf("calling %s %s", callbackURL, offlineReason)))
+	}
+	for _, p := range []struct {
+		field  string
+		policy api.PullPolicy
+	}{
+		{"builderPullPolicy", config.BuilderPullPolicy},
+		{"previousImagePullPolicy", config.PreviousImagePullPolicy},
+		{"runtimeImagePullPolicy", config.RuntimeImagePullPolicy},
+	} {
+		if p.policy == api.PullAlways || p.policy == api.PullIfChanged {
+			allErrs = append(allErrs, NewFieldForbidden(p.field, fmt.Sprintf("the %q pull policy %s", p.policy, offlineReason)))
+		}
+	}
+	if len(config.Scanner) > 0 {
+		allErrs = append(allErrs, NewFieldForbidden("scanner", fmt.Sprintf("updating the vulnerability database of %s %s", config.Scanner, offlineReason)))
+	}
+	if config.Executor == api.ExecutorKubernetes {
+		allErrs = append(allErrs, NewFieldForbidden("executor", fmt.Sprintf("building in a Kubernetes cluster %s", offlineReason)))
+	}
+	if config.DockerConfig.Engine != api.EngineContainerd && isRemoteEndpoint(config.DockerConfig.Endpoint) {
+		allErrs = append(allErrs, NewFieldForbidden("dockerConfig.endpoint", fmt.Sprintf("reaching the container engine at %s %s", config.DockerConfig.Endpoint, offlineReason)))
+	}
+	return allErrs
 }
 
-// NewFieldInvalidValueWithReason returns a ValidationError indicating "invalid value" and a reason for the error
-func NewFieldInvalidValueWithReason(field, reason string) Error {
-	return Error{Type: ErrorInvalidValue, Field: field, Reason: reason}
+// isRemoteURL returns true if the given URL is downloaded over the network.
+func isRemoteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
 }
 
-// ErrorType is a machine readable value providing more detail about why a field
-// is invalid.
-type ErrorType string
-
-const (
-	// ErrorTypeRequired is used to report required values that are not provided
-	// (e.g. empty strings, null values, or empty arrays).
-	ErrorTypeRequired ErrorType = "FieldValueRequired"
-
-	// ErrorInvalidValue is used to report values that do not conform to the
-	// expected schema.
-	ErrorInvalidValue ErrorType = "InvalidValue"
-)
-
-// Error is an implementation of the 'error' interface, which represents an
-// error of validation.
-type Error struct {
-	Type   ErrorType
-	Field  string
-	Reason string
+// isRemoteEndpoint returns true if the container engine of the given endpoint
+// is reached over the network, rather than through a socket or the loopback
+// interface.
+func isRemoteEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "ssh":
+		return true
+	case "tcp", "http", "https":
+		host := u.Hostname()
+		if host == "localhost" {
+			return false
+		}
+		ip := net.ParseIP(host)
+		return ip == nil || !ip.IsLoopback()
+	}
+	return false
 }
 
-func (v Error) Error() string {
-	var msg string
-	switch v.Type {
-	case ErrorInvalidValue:
-		msg = fmt.Sprintf("Invalid value specified for %q", v.Field)
-	case ErrorTypeRequired:
-		msg = fmt.Sprintf("Required value not specified for %q", v.Field)
-	default:
-		msg = fmt.Sprintf("%s: %s", v.Type, v.Field)
-	}
-	if len(v.Reason) > 0 {
-		msg = fmt.Sprintf("%s: %s", msg, v.Reason)
-	}
-	return msg
+// validateKubernetesExecutor checks that the build can run in the pod of the
+// kubernetes executor, which pushes the image built from the sources.
+func validateKubernetesExecutor(config *api.Config) []Error {
+	allErrs := []Error{}
+	if len(config.Tag) == 0 {
+		allErrs = append(allErrs, Error{Type: ErrorTypeRequired, Field: "tag", Reason: "the image built by the kubernetes executor is pushed to the registry of its tag"})
+	}
+	if config.Source == nil {
+		allErrs = append(allErrs, NewFieldConflict("executor", "the kubernetes executor builds the image from sources"))
+	}
+	if len(config.AsDockerfile) > 0 || config.RunImage || config.Incremental || len(config.RuntimeImage) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("executor", "the kubernetes executor does not support --as-dockerfile, --run, --incremental and --runtime-image"))
+	}
+	if len(config.AutoTag) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("autoTag", "the kubernetes executor pushes the image to its tag, which is not derived from the sources"))
+	}
+	if len(config.PushCredentials.Username) > 0 && len(config.Kubernetes.PushSecret) > 0 {
+		allErrs = append(allErrs, NewFieldConflict("pushCredentials", "the image is pushed with either the credentials or the push secret"))
+	}
+	return allErrs
 }
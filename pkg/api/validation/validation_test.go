@@ -3,9 +3,11 @@ package validation
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/tar"
 )
 
 func TestValidation(t *testing.T) {
@@ -32,6 +34,26 @@ func TestValidation(t *testing.T) {
 			},
 			[]Error{{Type: ErrorInvalidValue, Field: "dockerNetworkMode"}},
 		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ConvertCRLF:       "dos2unix",
+			},
+			[]Error{{Type: ErrorTypeNotSupported, Field: "convertCRLF", Reason: `"dos2unix" is not one of "off", "scripts", "all"`}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				BuildVolumes:      []string{`C:\cache:/cache`, `C:\cache`},
+			},
+			[]Error{{Type: ErrorInvalidValue, Field: "buildVolumes[1]", Reason: `invalid volume "C:\\cache", must be source:destination[:options]`}},
+		},
 		{
 			&api.Config{
 				Source:            git.MustParse("http://github.com/openshift/source"),
@@ -42,6 +64,455 @@ func TestValidation(t *testing.T) {
 			},
 			[]Error{},
 		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				DockerNetworkMode: api.DockerNetworkModeNone,
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				DockerNetworkMode: api.NewDockerNetworkModeContainer("8d873e496bc3"),
+				AddHost:           []string{"registry:10.0.0.1"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "addHost", Reason: "hosts cannot be added to containers sharing the network of another container"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				DockerNetworkMode: api.DockerNetworkModeNone,
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Hermetic:          true,
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				DockerNetworkMode: api.DockerNetworkModeHost,
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Hermetic:          true,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "dockerNetworkMode", Reason: "hermetic builds run without network"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Engine: api.EngineContainerd, ContainerdAddress: "/run/containerd/containerd.sock"},
+				DockerNetworkMode: api.DockerNetworkModeNone,
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Hermetic:          true,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "hermetic", Reason: "hermetic builds require the docker engine"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "tcp://build-host:2376"},
+				DockerNetworkMode: api.DockerNetworkModeNone,
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Hermetic:          true,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "dockerConfig.endpoint", Reason: "hermetic builds require a container engine running on this host"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket", NamePrefix: "ci", WorkerID: "Worker/1"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+			},
+			[]Error{{Type: ErrorInvalidValue, Field: "dockerConfig.workerID", Reason: "must consist of lower case alphanumeric characters separated by '.', '_' or '-'"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("file:///home/user/app"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "unix:///var/run/docker.sock", Offline: true, ImageStore: "/srv/images"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ScriptsURL:        "image:///usr/libexec/s2i",
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("file:///home/user/app"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "unix:///var/run/docker.sock", ImageStore: "/srv/images"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "dockerConfig.imageStore", Reason: "the image store is only used with --offline"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("https://github.com/openshift/source"),
+				Sources:           api.SourceList{{Source: git.MustParse("file:///home/user/lib"), Directory: "lib"}, {Source: git.MustParse("https://github.com/openshift/common"), Directory: "common"}},
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "tcp://docker.example.com:2376", Offline: true},
+				BuilderPullPolicy: api.PullIfChanged,
+				ScriptURLs:        map[string]string{"run": "https://example.com/run", "assemble": "file:///scripts/assemble"},
+				CallbackURLs:      []string{"https://example.com/callback"},
+				Scanner:           api.ScannerTrivy,
+			},
+			[]Error{
+				{Type: ErrorTypeForbidden, Field: "source", Reason: "fetching https://github.com/openshift/source requires network access, which --offline forbids"},
+				{Type: ErrorTypeForbidden, Field: "sources[1].source", Reason: "fetching https://github.com/openshift/common requires network access, which --offline forbids"},
+				{Type: ErrorTypeForbidden, Field: "scriptURLs[run]", Reason: "downloading the run script from https://example.com/run requires network access, which --offline forbids"},
+				{Type: ErrorTypeForbidden, Field: "callbackURLs[0]", Reason: "calling https://example.com/callback requires network access, which --offline forbids"},
+				{Type: ErrorTypeForbidden, Field: "builderPullPolicy", Reason: "the \"if-changed\" pull policy requires network access, which --offline forbids"},
+				{Type: ErrorTypeForbidden, Field: "scanner", Reason: "updating the vulnerability database of trivy requires network access, which --offline forbids"},
+				{Type: ErrorTypeForbidden, Field: "dockerConfig.endpoint", Reason: "reaching the container engine at tcp://docker.example.com:2376 requires network access, which --offline forbids"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("file:///home/user/app"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "tcp://127.0.0.1:2375", Offline: true},
+				BuilderPullPolicy: api.PullNever,
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:             git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:       "openshift/builder",
+				DockerConfig:       &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy:  api.DefaultBuilderPullPolicy,
+				ContextCompression: "lz4",
+			},
+			[]Error{{Type: ErrorTypeNotSupported, Field: "contextCompression", Reason: `"lz4" is not one of "none", "gzip", "zstd", "auto"`}},
+		},
+		{
+			&api.Config{
+				Source:                   git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:             "openshift/builder",
+				DockerConfig:             &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy:        api.DefaultBuilderPullPolicy,
+				SaveArtifactsCompression: "lz4",
+			},
+			[]Error{{Type: ErrorTypeNotSupported, Field: "saveArtifactsCompression", Reason: `"lz4" is not one of "none", "gzip", "zstd", "auto"`}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Incremental:       true,
+				RestoreArtifacts:  "artifacts.tar.zst",
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "restoreArtifacts", Reason: "the restored artifacts replace the ones of the previous image of incremental builds"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ExcludeRegExp:     `\.log$`,
+				ExcludeGlobs:      []string{"/build/"},
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "excludeRegExp", Reason: "the regular expression cannot be used with the exclude and include globs"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ExcludeRegExp:     tar.DefaultExclusionPattern.String(),
+				ExcludeGlobs:      []string{"/build/"},
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Executor:          api.ExecutorKubernetes,
+				RunImage:          true,
+			},
+			[]Error{
+				{Type: ErrorTypeRequired, Field: "tag", Reason: "the image built by the kubernetes executor is pushed to the registry of its tag"},
+				{Type: ErrorTypeConflict, Field: "executor", Reason: "the kubernetes executor does not support --as-dockerfile, --run, --incremental and --runtime-image"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Executor:          api.ExecutorKubernetes,
+				Tag:               "registry.example.com/app",
+				AutoTag:           api.AutoTagCommit,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "autoTag", Reason: "the kubernetes executor pushes the image to its tag, which is not derived from the sources"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Executor:          api.ExecutorKubernetes,
+				Tag:               "registry.example.com/app",
+				Kubernetes:        api.KubernetesConfig{PushSecret: "registry"},
+				PushCredentials:   api.RegistryCredentials{Username: "user", Password: "secret"},
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "pushCredentials", Reason: "the image is pushed with either the credentials or the push secret"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				PushCredentials:   api.RegistryCredentials{Username: "user", Password: "secret"},
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "pushCredentials", Reason: "only the kubernetes executor pushes the image it builds"}},
+		},
+		{
+			&api.Config{
+				Source:               git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:         "openshift/builder",
+				DockerConfig:         &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy:    api.DefaultBuilderPullPolicy,
+				KeepInjections:       []string{"/etc/pki/ca.crt", "certs"},
+				InjectionParallelism: -1,
+			},
+			[]Error{
+				{Type: ErrorInvalidValue, Field: "keepInjections[1]", Reason: `path "certs" must be absolute`},
+				{Type: ErrorInvalidValue, Field: "injectionParallelism", Reason: "must not be negative"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				OnBuildAllowlist:  []string{"RUN npm .*", "COPY ("},
+			},
+			[]Error{
+				{Type: ErrorInvalidValue, Field: "onBuildAllowlist[1]", Reason: "error parsing regexp: missing closing ): `COPY (`"},
+				{Type: ErrorTypeConflict, Field: "onBuildAllowlist", Reason: "the allowlist restricts the ONBUILD instructions executed with allowOnBuild"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				RedactEnvPatterns: []string{"*TOKEN*", "[A-"},
+			},
+			[]Error{{Type: ErrorInvalidValue, Field: "redactEnvPatterns[1]", Reason: "syntax error in pattern"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Strategy:          api.StrategyDockerfile,
+			},
+			[]Error{{Type: ErrorTypeRequired, Field: "asDockerfile"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				AsDockerfile:      "Dockerfile",
+				Strategy:          api.StrategyOnBuild,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "strategy", Reason: "the onbuild strategy builds an image instead of writing a Dockerfile"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ScriptURLs:        map[string]string{"assemble": "https://scripts.example.com/assemble", "run": "image:///usr/libexec/s2i/run"},
+			},
+			[]Error{{Type: ErrorInvalidValue, Field: "scriptURLs[run]", Reason: `unsupported URL "image:///usr/libexec/s2i/run" of the run script, must be an http, https or file URL`}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ScriptURLs:        map[string]string{"build": "https://scripts.example.com/build"},
+			},
+			[]Error{{Type: ErrorTypeNotSupported, Field: "scriptURLs[build]", Reason: `"build" is not one of "assemble", "assemble-runtime", "run", "save-artifacts", "usage"`}},
+		},
+		{
+			&api.Config{
+				Source:                git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:          "openshift/builder",
+				DockerConfig:          &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy:     api.DefaultBuilderPullPolicy,
+				ScanSeverityThreshold: api.SeverityHigh,
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "scanSeverityThreshold", Reason: "a scanner must be set"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				RunImage:          true,
+				RunPublish:        []string{"8080:8080", "127.0.0.1:9090:9090/udp"},
+				RunDetach:         true,
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				RunPublish:        []string{"8080:http"},
+			},
+			[]Error{
+				{Type: ErrorInvalidValue, Field: "runPublish[0]", Reason: "invalid containerPort: http"},
+				{Type: ErrorTypeConflict, Field: "runImage", Reason: "the image must be run to publish its ports, set its environment or detach it"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ComposeFile:       "docker-compose.yaml",
+				RunPublish:        []string{"8080:8080"},
+				RunEnvironment:    api.EnvironmentList{{Name: "MODE", Value: "dev"}},
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				ComposeFile:       "docker-compose.yaml",
+				AsDockerfile:      "Dockerfile",
+			},
+			[]Error{{Type: ErrorTypeConflict, Field: "composeFile", Reason: "the image must be built to be added to a compose file"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				TelemetryEndpoint: "telemetry.example.com",
+			},
+			[]Error{{Type: ErrorInvalidValue, Field: "telemetryEndpoint", Reason: "must be an http or https URL"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.PullIfChanged,
+				MaxBuildDuration:  -time.Minute,
+				MaxOutputSize:     512,
+				CommitRetries:     -1,
+				AsDockerfile:      "Dockerfile",
+				VerifyRunScript:   true,
+			},
+			[]Error{
+				{Type: ErrorInvalidValue, Field: "maxBuildDuration", Reason: "must not be negative"},
+				{Type: ErrorInvalidValue, Field: "commitRetries", Reason: "must not be negative"},
+				{Type: ErrorTypeConflict, Field: "maxOutputSize", Reason: "the image must be built to check its size"},
+				{Type: ErrorTypeConflict, Field: "verifyRunScript", Reason: "the image must be built to run its run script"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				CallbackEvents:    []string{api.CallbackEventStarted, "failed", api.CallbackEventPushed},
+				CallbackRetries:   -1,
+				CallbackTimeout:   -time.Second,
+				CallbackHeaders:   []string{"Authorization: Bearer token", "X-Token"},
+				CallbackCertFile:  "client.crt",
+			},
+			[]Error{
+				{Type: ErrorTypeNotSupported, Field: "callbackEvents[1]", Reason: `"failed" is not one of "started", "assembled", "pushed"`},
+				{Type: ErrorTypeConflict, Field: "callbackEvents[2]", Reason: "only the kubernetes executor pushes the image it builds"},
+				{Type: ErrorTypeConflict, Field: "callbackEvents", Reason: "the events are notified to the callbackURLs"},
+				{Type: ErrorInvalidValue, Field: "callbackRetries", Reason: "must not be negative"},
+				{Type: ErrorInvalidValue, Field: "callbackTimeout", Reason: "must not be negative"},
+				{Type: ErrorInvalidValue, Field: "callbackHeaders[1]", Reason: "must be in the Name: value form"},
+				{Type: ErrorTypeRequired, Field: "callbackKeyFile"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Tag:               "quay.io/user/app:latest",
+				AdditionalTags:    []string{"quay.io/user/app:v1"},
+				TagTemplates:      []string{"{{.Repo}}:{{.GitShortSHA}}"},
+			},
+			[]Error{},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				TagTemplates:      []string{"{{.Repo}}:{{.GitShortSHA}}"},
+			},
+			[]Error{{Type: ErrorTypeRequired, Field: "tag"}},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Tag:               "app",
+				AdditionalTags:    []string{"app:-v1"},
+				TagTemplates:      []string{"{{.Repo}:latest"},
+			},
+			[]Error{
+				{Type: ErrorInvalidValue, Field: "additionalTags[0]", Reason: "invalid reference format"},
+				{Type: ErrorInvalidValue, Field: "tagTemplates[0]", Reason: "template: tag:1: bad character U+007D '}'"},
+			},
+		},
 		{
 			&api.Config{
 				Source:            nil,
@@ -92,8 +563,60 @@ func TestValidation(t *testing.T) {
 			},
 			[]Error{{Type: ErrorInvalidValue, Field: "labels"}},
 		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Sources: api.SourceList{
+					{Source: git.MustParse("http://github.com/openshift/config#v1"), Directory: "config"},
+					{Source: git.MustParse("http://github.com/openshift/other"), Directory: "config/"},
+					{Source: git.MustParse("http://github.com/openshift/parent"), Directory: "../parent"},
+				},
+			},
+			[]Error{
+				{Type: ErrorTypeConflict, Field: "sources[1].directory", Reason: `the directory "config/" is given to several sources`},
+				{Type: ErrorInvalidValue, Field: "sources[2].directory", Reason: `the directory "../parent" of http://github.com/openshift/parent must be a subdirectory of the sources`},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: api.DefaultBuilderPullPolicy,
+				Incremental:       true,
+				RuntimeImage:      "openshift/runtime",
+				AsDockerfile:      "Dockerfile",
+				RunImage:          true,
+			},
+			[]Error{
+				{Type: ErrorTypeConflict, Field: "incremental", Reason: "incremental builds with a runtime image are not supported"},
+				{Type: ErrorTypeConflict, Field: "runImage", Reason: "the image must be built to be run"},
+				{Type: ErrorTypeConflict, Field: "runtimeImage", Reason: "builds with a runtime image cannot be written as a Dockerfile"},
+			},
+		},
+		{
+			&api.Config{
+				Source:            git.MustParse("http://github.com/openshift/source"),
+				BuilderImage:      "openshift/builder",
+				DockerConfig:      &api.DockerConfig{Endpoint: "/var/run/docker.socket"},
+				BuilderPullPolicy: "sometimes",
+				Usage:             true,
+				Ignorers:          []string{api.IgnorerS2I, "hgignore"},
+				BuildArgs:         api.BuildArgList{{Name: "VERSION", Value: "1"}, {Name: "1VERSION", Value: "1"}},
+			},
+			[]Error{
+				{Type: ErrorTypeNotSupported, Field: "builderPullPolicy", Reason: `"sometimes" is not one of "never", "always", "if-not-present", "if-changed"`},
+				{Type: ErrorTypeConflict, Field: "source", Reason: "the usage of the builder image is printed without building sources"},
+				{Type: ErrorTypeNotSupported, Field: "ignorers[1]", Reason: `"hgignore" is not one of "s2iignore", "gitignore"`},
+				{Type: ErrorInvalidValue, Field: "buildArgs[1].name", Reason: `invalid build argument name "1VERSION"`},
+			},
+		},
 	}
 	for _, test := range testCases {
+
 		result := ValidateConfig(test.value)
 		if !reflect.DeepEqual(result, test.expected) {
 			t.Errorf("got %+v, expected %+v", result, test.expected)
@@ -0,0 +1,157 @@
+package artifacts
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// Stdout is the output writing the archive to the standard output.
+const Stdout = "-"
+
+// Options are the options of a save.
+type Options struct {
+	// Image is the image whose save-artifacts script is run.
+	Image string
+	// Output is the archive the artifacts are written to, or Stdout.
+	Output string
+	// Compression is the compression of the archive.
+	Compression api.Compression
+	// User is the user running the save-artifacts script, defaulting to the
+	// user of the image.
+	User string
+	// PullPolicy specifies when to pull the image.
+	PullPolicy api.PullPolicy
+}
+
+// OutputCompression returns the compression of an archive given its name:
+// gzip for .tar.gz and .tgz, zstd for .tar.zst, none otherwise.
+func OutputCompression(output string) api.Compression {
+	switch {
+	case strings.HasSuffix(output, ".tar.gz"), strings.HasSuffix(output, ".tgz"):
+		return api.CompressionGzip
+	case strings.HasSuffix(output, ".tar.zst"):
+		return api.CompressionZstd
+	}
+	return api.CompressionNone
+}
+
+// Save runs the save-artifacts script of the image and writes the tar stream
+// it produces to the output, compressed as requested. The script is asked to
+// compress the stream itself when the image supports the compression, as
+// listed by its io.openshift.s2i.save-artifacts-compression label, and the
+// stream is compressed by Save otherwise.
+func Save(d docker.Docker, opts Options, stdout io.Writer) error {
+	compression := opts.Compression
+	switch compression {
+	case "":
+		compression = api.CompressionNone
+	case api.CompressionNone, api.CompressionGzip, api.CompressionZstd:
+	default:
+		return fmt.Errorf("unsupported compression %q of the archive, must be none, gzip or zstd", compression)
+	}
+
+	policy := opts.PullPolicy
+	if len(policy) == 0 {
+		policy = api.PullIfNotPresent
+	}
+	if _, err := docker.PullImage(opts.Image, d, policy); err != nil {
+		return err
+	}
+	user := opts.User
+	if len(user) == 0 {
+		var err error
+		if user, err = d.GetImageUser(opts.Image); err != nil {
+			return err
+		}
+	}
+
+	scriptCompresses := false
+	if compression != api.CompressionNone {
+		labels, err := d.GetLabels(opts.Image)
+		if err != nil {
+			return err
+		}
+		for _, c := range strings.Split(labels[constants.SaveArtifactsCompressionLabel], ",") {
+			if api.Compression(strings.ToLower(strings.TrimSpace(c))) == compression {
+				scriptCompresses = true
+			}
+		}
+	}
+
+	w := stdout
+	if opts.Output != Stdout {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	outReader, outWriter := io.Pipe()
+	errReader, errWriter := io.Pipe()
+	copyFunc := func(string) error {
+		streamCompression := compression
+		if scriptCompresses {
+			log.V(2).Infof("The save-artifacts script of %s compresses the artifacts using %s", opts.Image, compression)
+			streamCompression = api.CompressionNone
+		}
+		compressor, err := s2itar.NewCompressionWriter(w, streamCompression)
+		if err == nil {
+			if _, err = io.Copy(compressor, outReader); err == nil {
+				err = compressor.Close()
+			}
+		}
+		io.Copy(ioutil.Discard, outReader) // must ensure reader from container is drained
+		return err
+	}
+
+	runOpts := docker.RunContainerOptions{
+		Image:   opts.Image,
+		User:    user,
+		Command: constants.SaveArtifacts,
+		Stdout:  outWriter,
+		Stderr:  errWriter,
+		OnStart: copyFunc,
+	}
+	if scriptCompresses {
+		runOpts.Env = []string{constants.SaveArtifactsCompressionEnvironment + "=" + string(compression)}
+	}
+	docker.StreamContainerIO(errReader, nil, func(s string) { log.Stream(utillog.StreamStderr, s) })
+	err := d.RunContainer(runOpts)
+	if e, ok := err.(s2ierr.ContainerError); ok {
+		err = s2ierr.NewSaveArtifactsError(opts.Image, e.Output, err)
+	}
+	if err == nil && opts.Output != Stdout {
+		log.V(1).Infof("Saved the build artifacts of %s to %s", opts.Image, opts.Output)
+	}
+	return err
+}
+
+// Restore extracts the archive of build artifacts written by Save, whose
+// compression is detected from its content, to the directory.
+func Restore(t s2itar.Tar, archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := s2itar.NewDecompressedReader(f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return t.ExtractTarStream(dir, r)
+}
@@ -0,0 +1,109 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+func TestOutputCompression(t *testing.T) {
+	tests := map[string]api.Compression{
+		"artifacts.tar":     api.CompressionNone,
+		"artifacts.tar.gz":  api.CompressionGzip,
+		"artifacts.tgz":     api.CompressionGzip,
+		"artifacts.tar.zst": api.CompressionZstd,
+		Stdout:              api.CompressionNone,
+	}
+	for output, expected := range tests {
+		if actual := OutputCompression(output); actual != expected {
+			t.Errorf("%s: expected %s, got %s", output, expected, actual)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		labels      map[string]string
+		compression api.Compression
+		expectedEnv []string
+		gzipped     bool
+	}{
+		{compression: api.CompressionNone},
+		{compression: api.CompressionGzip, gzipped: true},
+		{
+			labels:      map[string]string{constants.SaveArtifactsCompressionLabel: "gzip,zstd"},
+			compression: api.CompressionGzip,
+			expectedEnv: []string{constants.SaveArtifactsCompressionEnvironment + "=gzip"},
+		},
+	}
+	for i, tc := range tests {
+		d := &docker.FakeDocker{LocalRegistryResult: true, GetImageUserResult: "1001", Labels: tc.labels}
+		out := &bytes.Buffer{}
+		opts := Options{Image: "app", Output: Stdout, Compression: tc.compression}
+		if err := Save(d, opts, out); err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		if d.RunContainerOpts.Command != constants.SaveArtifacts || d.RunContainerOpts.User != "1001" {
+			t.Errorf("%d: expected save-artifacts to be run as 1001, got %q as %q", i, d.RunContainerOpts.Command, d.RunContainerOpts.User)
+		}
+		if !reflect.DeepEqual(d.RunContainerOpts.Env, tc.expectedEnv) {
+			t.Errorf("%d: expected the environment %v, got %v", i, tc.expectedEnv, d.RunContainerOpts.Env)
+		}
+		if _, err := gzip.NewReader(bytes.NewReader(out.Bytes())); (err == nil) != tc.gzipped {
+			t.Errorf("%d: expected the output to be gzipped: %t, got %d bytes (%v)", i, tc.gzipped, out.Len(), err)
+		}
+	}
+
+	if err := Save(&docker.FakeDocker{}, Options{Image: "app", Output: Stdout, Compression: api.CompressionAuto}, ioutil.Discard); err == nil {
+		t.Errorf("expected an error for the auto compression")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	content := "gems"
+	if err := tw.WriteHeader(&tar.Header{Name: "bundle/Gemfile.lock", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tw, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := ioutil.ReadAll(s2itar.NewCompressedReader(buf, api.CompressionZstd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "artifacts.tar.zst")
+	if err := ioutil.WriteFile(archive, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := Restore(s2itar.New(fs.NewFileSystem()), archive, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored, err := ioutil.ReadFile(filepath.Join(dir, "bundle", "Gemfile.lock"))
+	if err != nil || string(restored) != content {
+		t.Errorf("expected the restored file to contain %q, got %q (%v)", content, restored, err)
+	}
+
+	if err := Restore(s2itar.New(fs.NewFileSystem()), filepath.Join(dir, "missing.tar"), dir); err == nil || !strings.Contains(err.Error(), "missing.tar") {
+		t.Errorf("expected an error for a missing archive, got %v", err)
+	}
+}
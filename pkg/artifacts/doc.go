@@ -0,0 +1,3 @@
+// Package artifacts saves the build artifacts of the images produced by S2I
+// to archives and restores them, independently of a build.
+package artifacts
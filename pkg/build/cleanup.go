@@ -39,6 +39,10 @@ func (c *DefaultCleaner) Cleanup(config *api.Config) {
 		// config.LayeredBuild is true only when layered build was finished successfully.
 		// Also in this case config.BuilderImage contains name of the new just built image,
 		// not the original one that was specified by the user.
+		if config.KeepLayeredImage {
+			log.V(0).Infof("Temporary image %s will be saved, not deleted", config.BuilderImage)
+			return
+		}
 		log.V(2).Infof("Removing temporary image %s", config.BuilderImage)
 		if err := c.docker.RemoveImage(config.BuilderImage); err != nil {
 			log.Warningf("Error removing temporary image %s: %v", config.BuilderImage, err)
@@ -8,6 +8,8 @@ import (
 	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/docker"
 	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
 // GenerateConfigFromLabels generates the S2I Config struct from the Docker
@@ -57,3 +59,32 @@ func GenerateConfigFromLabels(config *api.Config, metadata *docker.PullResult) e
 
 	return nil
 }
+
+// ApplyAutoTag replaces the tag of the given config with the one derived from
+// the given source information, if the config asks for it. The derived tag
+// replaces the tag part only, so that it can be applied again by each strategy
+// of the build.
+func ApplyAutoTag(config *api.Config, info *git.SourceInfo) error {
+	if len(config.AutoTag) == 0 {
+		return nil
+	}
+	tag, err := util.AutoTag(config.Tag, config.AutoTag, info)
+	if err != nil {
+		return utilstatus.NewFailureError(utilstatus.NewFailureReason(
+			utilstatus.ReasonTagImageFailed,
+			utilstatus.ReasonMessageTagImageFailed,
+		), err)
+	}
+	if tag != config.Tag {
+		log.V(1).Infof("Tagging the image %s from the %s of the sources", tag, config.AutoTag)
+	}
+	config.Tag = tag
+	return nil
+}
+
+// TagAfterChecks returns true when the image built with the given config is
+// checked before it is tagged, so that an image failing the checks never
+// replaces the image of the tag.
+func TagAfterChecks(config *api.Config) bool {
+	return len(config.Scanner) > 0 || len(config.PostCommitCommand) > 0 || config.VerifyRunScript || config.MaxOutputSize > 0
+}
@@ -0,0 +1,59 @@
+package build
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+// AuditOnBuild prints the ONBUILD instructions of the builder image, which the
+// onbuild strategy and the docker build of the layered image execute, and
+// returns an error unless the configuration confirms that they are executed
+// and each of them matches the ONBUILD allowlist, in full, when there is one.
+func AuditOnBuild(config *api.Config, instructions []string) error {
+	log.Infof("The builder image %s has %d ONBUILD instructions:", config.BuilderImage, len(instructions))
+	for _, instruction := range instructions {
+		log.Infof("  ONBUILD %s", instruction)
+	}
+	if !config.AllowOnBuild {
+		return s2ierr.NewOnBuildNotAllowedError(config.BuilderImage, "")
+	}
+	if len(config.OnBuildAllowlist) == 0 {
+		return nil
+	}
+	allowlist, err := compileAllowlist(config.OnBuildAllowlist)
+	if err != nil {
+		return err
+	}
+	for _, instruction := range instructions {
+		if !matchesAny(allowlist, instruction) {
+			return s2ierr.NewOnBuildNotAllowedError(config.BuilderImage, instruction)
+		}
+	}
+	return nil
+}
+
+// compileAllowlist compiles the patterns of the ONBUILD allowlist, anchored to
+// match the instructions in full.
+func compileAllowlist(patterns []string) ([]*regexp.Regexp, error) {
+	allowlist := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid ONBUILD allowlist pattern %q: %v", pattern, err)
+		}
+		allowlist = append(allowlist, re)
+	}
+	return allowlist, nil
+}
+
+func matchesAny(allowlist []*regexp.Regexp, instruction string) bool {
+	for _, re := range allowlist {
+		if re.MatchString(instruction) {
+			return true
+		}
+	}
+	return false
+}
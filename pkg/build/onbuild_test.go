@@ -0,0 +1,50 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+func TestAuditOnBuild(t *testing.T) {
+	instructions := []string{"COPY . /app", "RUN npm install"}
+	tests := []struct {
+		name        string
+		config      api.Config
+		expectedErr error
+	}{
+		{
+			name:        "not allowed",
+			config:      api.Config{BuilderImage: "node:onbuild"},
+			expectedErr: s2ierr.NewOnBuildNotAllowedError("node:onbuild", ""),
+		},
+		{
+			name:   "allowed",
+			config: api.Config{BuilderImage: "node:onbuild", AllowOnBuild: true},
+		},
+		{
+			name:   "allowlisted",
+			config: api.Config{BuilderImage: "node:onbuild", AllowOnBuild: true, OnBuildAllowlist: []string{`COPY \. /app`, "RUN npm (install|ci)"}},
+		},
+		{
+			name:        "not allowlisted",
+			config:      api.Config{BuilderImage: "node:onbuild", AllowOnBuild: true, OnBuildAllowlist: []string{"COPY .*", "RUN npm"}},
+			expectedErr: s2ierr.NewOnBuildNotAllowedError("node:onbuild", "RUN npm install"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := AuditOnBuild(&tc.config, instructions)
+			if tc.expectedErr == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr.Error() {
+				t.Errorf("expected error %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+)
+
+// CheckOutputSize returns an error when the layers the build added to the
+// image, those its base image does not have, are larger than
+// config.MaxOutputSize megabytes, or when their size cannot be determined.
+// When baseImage is empty, the build added the topmost layer only, which it
+// committed. The image, which is not tagged yet (see TagAfterChecks), is
+// removed when the check fails. The error carries the reason the build failed
+// for.
+func CheckOutputSize(d docker.Docker, config *api.Config, imageID, baseImage string) error {
+	maxSize := config.MaxOutputSize * 1024 * 1024
+	layers := 1
+	usage, err := d.GetResourceUsage(imageID)
+	if err == nil && len(baseImage) > 0 {
+		var base api.ResourceUsage
+		if base, err = d.GetResourceUsage(baseImage); err == nil {
+			layers = len(usage.Layers) - len(base.Layers)
+		}
+	}
+	if err == nil && (len(usage.Layers) == 0 || len(usage.Layers) < layers) {
+		err = fmt.Errorf("the container engine did not report the history of the image")
+	}
+	var size int64
+	if err == nil {
+		for _, layer := range usage.Layers[:layers] {
+			size += layer.SizeBytes
+		}
+		if size <= maxSize {
+			return nil
+		}
+	}
+
+	reason := utilstatus.NewFailureReason(utilstatus.ReasonQuotaExceeded, utilstatus.ReasonMessageQuotaExceeded)
+	if err != nil {
+		reason = utilstatus.NewFailureReason(utilstatus.ReasonGenericS2IBuildFailed, utilstatus.ReasonMessageGenericS2iBuildFailed)
+		err = fmt.Errorf("unable to determine the size of the layers of image %s, which --max-output-size limits: %v", imageID, err)
+	} else {
+		err = s2ierr.NewOutputSizeExceededError(size, maxSize)
+	}
+	log.V(1).Infof("Removing image %s which failed the output size check", imageID)
+	if removeErr := d.RemoveImage(imageID); removeErr != nil {
+		log.Warningf("Failed to remove image %s: %v", imageID, removeErr)
+	}
+	return utilstatus.NewFailureError(reason, err)
+}
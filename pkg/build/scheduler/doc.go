@@ -0,0 +1,4 @@
+// Package scheduler queues the S2I builds run by a long running process, such
+// as a build service using S2I as a library, and limits their concurrency so
+// that a burst of requests does not bring down the host.
+package scheduler
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/build"
+	"github.com/openshift/source-to-image/pkg/build/strategies"
+	"github.com/openshift/source-to-image/pkg/docker"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+)
+
+var log = utillog.StderrLog
+
+// Options are the limits enforced by a Scheduler.
+type Options struct {
+	// MaxConcurrentBuilds is the number of builds run at the same time. Zero
+	// means no limit.
+	MaxConcurrentBuilds int
+
+	// MaxConcurrentPullsPerRegistry is the number of images pulled at the same
+	// time from each registry, by all the builds of the process. Zero means no
+	// limit.
+	MaxConcurrentPullsPerRegistry int
+
+	// QueueTimeout is the time a build waits in the queue for a slot before it
+	// fails. Zero means the builds wait as long as their context allows. It
+	// also bounds the time a pull waits for a slot of its registry, which is
+	// docker.DefaultPullQueueTimeout when zero.
+	QueueTimeout time.Duration
+}
+
+// QueueTimeoutError is returned when a build waited longer than the queue
+// timeout for a slot.
+type QueueTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *QueueTimeoutError) Error() string {
+	return fmt.Sprintf("no build slot became available within %v", e.Timeout)
+}
+
+// waiter is a build waiting in the queue. Its channel is closed when it is
+// given a slot.
+type waiter struct {
+	ready chan struct{}
+}
+
+// A Scheduler runs the builds submitted concurrently, in the order they were
+// submitted, within the limits of its options. A slot freed by a build is
+// given to the build at the head of the queue, so that the builds waiting
+// longer are not overtaken by the builds submitted later.
+type Scheduler struct {
+	options Options
+
+	mu      sync.Mutex
+	running int
+	queue   []*waiter
+}
+
+// New creates a Scheduler enforcing the given limits. The limit of the
+// concurrent pulls applies to the whole process.
+func New(options Options) *Scheduler {
+	docker.SetMaxConcurrentPulls(options.MaxConcurrentPullsPerRegistry, options.QueueTimeout)
+	return &Scheduler{options: options}
+}
+
+// Acquire waits for a build slot, and returns the function releasing it. It
+// fails when the queue timeout expires or the context is done before a slot
+// is available.
+func (s *Scheduler) Acquire(ctx context.Context) (func(), error) {
+	s.mu.Lock()
+	if s.options.MaxConcurrentBuilds <= 0 || (s.running < s.options.MaxConcurrentBuilds && len(s.queue) == 0) {
+		s.running++
+		s.mu.Unlock()
+		return s.release, nil
+	}
+	w := &waiter{ready: make(chan struct{})}
+	s.queue = append(s.queue, w)
+	log.V(2).Infof("Build queued behind %d builds", len(s.queue)-1)
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if s.options.QueueTimeout > 0 {
+		timer := time.NewTimer(s.options.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return s.release, nil
+	case <-timeout:
+		err = &QueueTimeoutError{Timeout: s.options.QueueTimeout}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.queue {
+		if s.queue[i] == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return nil, err
+		}
+	}
+	// the slot was given to the waiter while it gave up
+	s.releaseLocked()
+	return nil, err
+}
+
+// release frees a build slot, giving it to the build at the head of the queue.
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+func (s *Scheduler) releaseLocked() {
+	if len(s.queue) > 0 {
+		w := s.queue[0]
+		s.queue = s.queue[1:]
+		close(w.ready)
+		return
+	}
+	s.running--
+}
+
+// Build runs the build of the given configuration once a build slot is
+// available.
+func (s *Scheduler) Build(ctx context.Context, client docker.Client, config *api.Config, overrides build.Overrides) (*api.Result, error) {
+	startTime := time.Now()
+	release, err := s.Acquire(ctx)
+	if err != nil {
+		result := &api.Result{}
+		// the builds canceled by their context did not time out in the queue
+		if _, ok := err.(*QueueTimeoutError); ok {
+			result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonBuildQueueTimeout,
+				utilstatus.ReasonMessageBuildQueueTimeout,
+			)
+		} else {
+			result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonBuildCanceled,
+				utilstatus.ReasonMessageBuildCanceled,
+			)
+		}
+		return result, err
+	}
+	defer release()
+	if waited := time.Since(startTime); waited > time.Second {
+		log.V(1).Infof("Build started after waiting %v in the queue", waited.Round(time.Second))
+	}
+
+	builder, buildInfo, err := strategies.Strategy(client, config, overrides)
+	if err != nil {
+		return &api.Result{BuildInfo: buildInfo}, err
+	}
+	result, err := builder.Build(config)
+	if result != nil {
+		// the builder image is pulled before the build starts
+		result.BuildInfo.Stages = api.MergeStageInfo(buildInfo.Stages, result.BuildInfo.Stages)
+	}
+	return result, err
+}
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/build"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+)
+
+func TestAcquireOrder(t *testing.T) {
+	s := New(Options{MaxConcurrentBuilds: 1})
+	release, err := s.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	started := make(chan int, 3)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := s.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			started <- i
+			r()
+		}()
+		// wait for the build to be queued before submitting the next one
+		for {
+			s.mu.Lock()
+			queued := len(s.queue)
+			s.mu.Unlock()
+			if queued == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	select {
+	case i := <-started:
+		t.Fatalf("Build %d started while the slot was taken", i)
+	default:
+	}
+	release()
+
+	order := []int{<-started, <-started, <-started}
+	if !reflect.DeepEqual(order, []int{0, 1, 2}) {
+		t.Errorf("Expected the builds to start in order, got %v", order)
+	}
+	wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != 0 || len(s.queue) != 0 {
+		t.Errorf("Expected no running or queued build, got %d running and %d queued", s.running, len(s.queue))
+	}
+}
+
+func TestAcquireQueueTimeout(t *testing.T) {
+	s := New(Options{MaxConcurrentBuilds: 1, QueueTimeout: 10 * time.Millisecond})
+	release, err := s.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.Acquire(context.Background()); err == nil {
+		t.Fatal("Expected the queue timeout to expire")
+	} else if _, ok := err.(*QueueTimeoutError); !ok {
+		t.Fatalf("Expected a QueueTimeoutError, got %v", err)
+	}
+	if len(s.queue) != 0 {
+		t.Errorf("Expected the build to leave the queue, got %d queued", len(s.queue))
+	}
+	release()
+	if s.running != 0 {
+		t.Errorf("Expected no running build, got %d", s.running)
+	}
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	s := New(Options{MaxConcurrentBuilds: 1})
+	release, err := s.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Acquire(ctx); err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestAcquireUnlimited(t *testing.T) {
+	s := New(Options{})
+	for i := 0; i < 10; i++ {
+		if _, err := s.Acquire(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestBuildNotStarted(t *testing.T) {
+	s := New(Options{MaxConcurrentBuilds: 1, QueueTimeout: 10 * time.Millisecond})
+	release, err := s.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer release()
+
+	result, err := s.Build(context.Background(), nil, &api.Config{}, build.Overrides{})
+	if err == nil || result.BuildInfo.FailureReason.Reason != utilstatus.ReasonBuildQueueTimeout {
+		t.Errorf("Expected the build to time out in the queue, got %v and %v", result.BuildInfo.FailureReason, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = s.Build(ctx, nil, &api.Config{}, build.Overrides{})
+	if err != context.Canceled || result.BuildInfo.FailureReason.Reason != utilstatus.ReasonBuildCanceled {
+		t.Errorf("Expected the build to be canceled, got %v and %v", result.BuildInfo.FailureReason, err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/openshift/source-to-image/pkg/api"
@@ -14,6 +16,8 @@ import (
 	"github.com/openshift/source-to-image/pkg/build"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/lock"
+	"github.com/openshift/source-to-image/pkg/render"
 	"github.com/openshift/source-to-image/pkg/scm"
 	"github.com/openshift/source-to-image/pkg/scm/downloaders/file"
 	"github.com/openshift/source-to-image/pkg/scm/git"
@@ -28,6 +32,15 @@ import (
 const (
 	defaultDestination = "/tmp"
 	defaultScriptsDir  = "/usr/libexec/s2i"
+
+	// defaultAssembleUser is the assemble user of the builder images which
+	// could not be inspected.
+	defaultAssembleUser = "1001"
+
+	// incrementalArg is the build argument of the incremental Dockerfiles
+	// which restores the artifacts of the previous image when true, and
+	// builds without them when false, e.g. when there is no previous image.
+	incrementalArg = "S2I_INCREMENTAL"
 )
 
 var (
@@ -40,6 +53,13 @@ var (
 		constants.DefaultScripts,
 		constants.UserScripts,
 	}
+
+	// defaultCapabilities are the capabilities the container engines grant
+	// the containers, and thus the RUN instructions, by default.
+	defaultCapabilities = []string{
+		"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+		"NET_BIND_SERVICE", "NET_RAW", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+	}
 )
 
 // Dockerfile builders produce a Dockerfile rather than an image.
@@ -52,10 +72,16 @@ type Dockerfile struct {
 	sourceInfo       *git.SourceInfo
 	result           *api.Result
 	ignorer          build.Ignorer
+	// installedScripts are the scripts installed by the build, by name
+	installedScripts map[string]api.InstallResult
 }
 
 // New creates a Dockerfile builder.
 func New(config *api.Config, fs fs.FileSystem) (*Dockerfile, error) {
+	ignorer, err := ignore.New(config.Ignorers)
+	if err != nil {
+		return nil, err
+	}
 	return &Dockerfile{
 		fs: fs,
 		// where we will get the assemble/run scripts from on the host machine,
@@ -63,7 +89,8 @@ func New(config *api.Config, fs fs.FileSystem) (*Dockerfile, error) {
 		uploadScriptsDir: constants.UploadScripts,
 		uploadSrcDir:     constants.Source,
 		result:           &api.Result{},
-		ignorer:          &ignore.DockerIgnorer{},
+		ignorer:          ignorer,
+		installedScripts: map[string]api.InstallResult{},
 	}, nil
 }
 
@@ -75,13 +102,12 @@ func (builder *Dockerfile) Build(config *api.Config) (*api.Result, error) {
 	if strings.HasSuffix(config.AsDockerfile, string(os.PathSeparator)) {
 		config.AsDockerfile = config.AsDockerfile + "Dockerfile"
 	}
-	if len(config.AssembleUser) == 0 {
-		config.AssembleUser = "1001"
-	}
-	if !user.IsUserAllowed(config.AssembleUser, &config.AllowedUIDs) {
+	assembleUser, err := getAssembleUser(config)
+	if err != nil {
 		builder.setFailureReason(utilstatus.ReasonAssembleUserForbidden, utilstatus.ReasonMessageAssembleUserForbidden)
-		return builder.result, s2ierr.NewUserNotAllowedError(config.AssembleUser, false)
+		return builder.result, err
 	}
+	config.AssembleUser = assembleUser
 
 	dir, _ := filepath.Split(config.AsDockerfile)
 	if len(dir) == 0 {
@@ -119,7 +145,9 @@ func (builder *Dockerfile) CreateDockerfile(config *api.Config) error {
 		config.ImageWorkDir = "/opt/app-root/src"
 	}
 
-	imageUser := config.AssembleUser
+	imageUser := sanitize(config.AssembleUser)
+	// the files copied into the image are owned by the assemble user
+	owner := ownerOf(imageUser)
 
 	// where files will land inside the new image.
 	scriptsDestDir := filepath.Join(getDestination(config), "scripts")
@@ -134,31 +162,39 @@ func (builder *Dockerfile) CreateDockerfile(config *api.Config) error {
 		if len(imageTag) == 0 {
 			return errors.New("Image tag is missing for incremental build")
 		}
-		// Incremental builds run via a multistage Dockerfile
-		buffer.WriteString(fmt.Sprintf("FROM %s as cached\n", imageTag))
+		// Incremental builds run via a multistage Dockerfile, whose cached stage
+		// saves the artifacts of the previous image, or provides none when the
+		// build argument disables it. Only BuildKit skips the stages which are
+		// not used, the legacy builder builds them all.
+		buffer.WriteString(fmt.Sprintf("# Build with --build-arg %s=false when there is no previous image, which\n", incrementalArg))
+		buffer.WriteString("# requires BuildKit: the legacy builder builds every stage, from the previous\n")
+		buffer.WriteString("# image too, so the first build uses a Dockerfile generated without --incremental\n")
+		buffer.WriteString(fmt.Sprintf("ARG %s=true\n", incrementalArg))
+		buffer.WriteString(fmt.Sprintf("FROM %s as cached-true\n", imageTag))
+		buffer.WriteString(scripts.ConvertBuildArgsToDocker(config.BuildArgs))
 		var artifactsScript string
 		if _, provided := providedScripts[constants.SaveArtifacts]; provided {
-			// switch to root to COPY and chown content
 			log.V(2).Infof("Override save-artifacts script is included in directory %q", builder.uploadScriptsDir)
 			buffer.WriteString("# Copying in override save-artifacts script\n")
-			buffer.WriteString("USER root\n")
 			artifactsScript = sanitize(filepath.ToSlash(filepath.Join(scriptsDestDir, "save-artifacts")))
 			uploadScript := sanitize(filepath.ToSlash(filepath.Join(builder.uploadScriptsDir, "save-artifacts")))
-			buffer.WriteString(fmt.Sprintf("COPY %s %s\n", uploadScript, artifactsScript))
-			buffer.WriteString(fmt.Sprintf("RUN chown %s:0 %s\n", sanitize(imageUser), artifactsScript))
+			buffer.WriteString(fmt.Sprintf("COPY --chown=%s %s %s\n", owner, uploadScript, artifactsScript))
 		} else {
 			buffer.WriteString(fmt.Sprintf("# Save-artifacts script sourced from builder image based on user input or image metadata.\n"))
 			artifactsScript = sanitize(filepath.ToSlash(filepath.Join(imageScriptsDir, "save-artifacts")))
 		}
-		// switch to the image user if it is not root
-		if len(imageUser) > 0 && imageUser != "root" {
-			buffer.WriteString(fmt.Sprintf("USER %s\n", imageUser))
-		}
-		buffer.WriteString(fmt.Sprintf("RUN if [ -s %[1]s ]; then %[1]s > %[2]s; else touch %[2]s; fi\n", artifactsScript, artifactsTar))
+		// save-artifacts runs as the assemble user, as it does in the STI strategy
+		buffer.WriteString(fmt.Sprintf("USER %s\n", imageUser))
+		runAs(&buffer, config.DropCapabilities, imageUser, fmt.Sprintf("if [ -s %[1]s ]; then %[1]s > %[2]s; else touch %[2]s; fi", artifactsScript, artifactsTar))
+		buffer.WriteString(fmt.Sprintf("FROM %s as cached-false\n", config.BuilderImage))
+		buffer.WriteString(fmt.Sprintf("RUN touch %s\n", artifactsTar))
+		buffer.WriteString(fmt.Sprintf("FROM cached-${%s} as cached\n", incrementalArg))
 	}
 
 	// main stage of the Dockerfile
 	buffer.WriteString(fmt.Sprintf("FROM %s\n", config.BuilderImage))
+	buffer.WriteString(scripts.ConvertBuildArgsToDocker(config.BuildArgs))
+	buffer.WriteString(scripts.ConvertBuildArgsToDockerEnv(config.BuildArgs))
 
 	imageLabels := util.GenerateOutputImageLabels(builder.sourceInfo, config)
 	for k, v := range config.Labels {
@@ -186,17 +222,12 @@ func (builder *Dockerfile) CreateDockerfile(config *api.Config) error {
 		buffer.WriteString("\n")
 	}
 
-	env := createBuildEnvironment(config.WorkingDir, config.Environment)
+	env := createBuildEnvironment(config.WorkingDir, builder.sourceInfo, config.Environment)
 	buffer.WriteString(fmt.Sprintf("%s", env))
 
-	// run as root to COPY and chown source content
-	buffer.WriteString("USER root\n")
-	chownList := make([]string, 0)
-
 	if config.Incremental {
 		// COPY artifacts.tar from the `cached` stage
-		buffer.WriteString(fmt.Sprintf("COPY --from=cached %[1]s %[1]s\n", artifactsTar))
-		chownList = append(chownList, artifactsTar)
+		buffer.WriteString(fmt.Sprintf("COPY --from=cached --chown=%[1]s %[2]s %[2]s\n", owner, artifactsTar))
 	}
 
 	if len(providedScripts) > 0 {
@@ -205,15 +236,13 @@ func (builder *Dockerfile) CreateDockerfile(config *api.Config) error {
 		log.V(2).Infof("Override scripts are included in directory %q", builder.uploadScriptsDir)
 		scriptsDest := sanitize(filepath.ToSlash(scriptsDestDir))
 		buffer.WriteString("# Copying in override assemble/run scripts\n")
-		buffer.WriteString(fmt.Sprintf("COPY %s %s\n", sanitize(filepath.ToSlash(builder.uploadScriptsDir)), scriptsDest))
-		chownList = append(chownList, scriptsDest)
+		buffer.WriteString(fmt.Sprintf("COPY --chown=%s %s %s\n", owner, sanitize(filepath.ToSlash(builder.uploadScriptsDir)), scriptsDest))
 	}
 
 	// copy in the user's source code.
 	buffer.WriteString("# Copying in source code\n")
 	sourceDest := sanitize(filepath.ToSlash(sourceDestDir))
-	buffer.WriteString(fmt.Sprintf("COPY %s %s\n", sanitize(filepath.ToSlash(builder.uploadSrcDir)), sourceDest))
-	chownList = append(chownList, sourceDest)
+	buffer.WriteString(fmt.Sprintf("COPY --chown=%s %s %s\n", owner, sanitize(filepath.ToSlash(builder.uploadSrcDir)), sourceDest))
 
 	// add injections
 	log.V(4).Infof("Processing injected inputs: %#v", config.Injections)
@@ -226,24 +255,11 @@ func (builder *Dockerfile) CreateDockerfile(config *api.Config) error {
 	for _, injection := range config.Injections {
 		src := sanitize(filepath.ToSlash(filepath.Join(constants.Injections, injection.Source)))
 		dest := sanitize(filepath.ToSlash(injection.Destination))
-		buffer.WriteString(fmt.Sprintf("COPY %s %s\n", src, dest))
-		chownList = append(chownList, dest)
-	}
-
-	// chown directories COPYed to image
-	if len(chownList) > 0 {
-		buffer.WriteString("# Change file ownership to the assemble user. Builder image must support chown command.\n")
-		buffer.WriteString(fmt.Sprintf("RUN chown -R %s:0", sanitize(imageUser)))
-		for _, dir := range chownList {
-			buffer.WriteString(fmt.Sprintf(" %s", dir))
-		}
-		buffer.WriteString("\n")
+		buffer.WriteString(fmt.Sprintf("COPY --chown=%s %s %s\n", owner, src, dest))
 	}
 
-	// run remaining commands as the image user
-	if len(imageUser) > 0 && imageUser != "root" {
-		buffer.WriteString(fmt.Sprintf("USER %s\n", imageUser))
-	}
+	// run remaining commands as the assemble user
+	buffer.WriteString(fmt.Sprintf("USER %s\n", imageUser))
 
 	if config.Incremental {
 		buffer.WriteString("# Extract artifact content\n")
@@ -252,14 +268,14 @@ func (builder *Dockerfile) CreateDockerfile(config *api.Config) error {
 	}
 
 	if _, provided := providedScripts[constants.Assemble]; provided {
-		buffer.WriteString(fmt.Sprintf("RUN %s\n", sanitize(filepath.ToSlash(filepath.Join(scriptsDestDir, "assemble")))))
+		runAs(&buffer, config.DropCapabilities, imageUser, sanitize(filepath.ToSlash(filepath.Join(scriptsDestDir, "assemble"))))
 	} else {
 		buffer.WriteString(fmt.Sprintf("# Assemble script sourced from builder image based on user input or image metadata.\n"))
 		buffer.WriteString(fmt.Sprintf("# If this file does not exist in the image, the build will fail.\n"))
-		buffer.WriteString(fmt.Sprintf("RUN %s\n", sanitize(filepath.ToSlash(filepath.Join(imageScriptsDir, "assemble")))))
+		runAs(&buffer, config.DropCapabilities, imageUser, sanitize(filepath.ToSlash(filepath.Join(imageScriptsDir, "assemble"))))
 	}
 
-	filesToDelete, err := util.ListFilesToTruncate(builder.fs, config.Injections)
+	filesToDelete, err := util.ListFilesToTruncate(builder.fs, config.Injections, config.KeepInjections)
 	if err != nil {
 		return err
 	}
@@ -324,8 +340,8 @@ func (builder *Dockerfile) Prepare(config *api.Config) error {
 	}
 
 	// Fetch sources, since their .s2i/bin might contain s2i scripts which override defaults.
-	if config.Source != nil {
-		downloader, err := scm.DownloaderForSource(builder.fs, config.Source, config.ForceCopy)
+	if config.Source != nil || len(config.ArtifactPath) > 0 {
+		downloader, err := scm.DownloaderForConfig(builder.fs, config)
 		if err != nil {
 			builder.setFailureReason(utilstatus.ReasonFetchSourceFailed, utilstatus.ReasonMessageFetchSourceFailed)
 			return err
@@ -342,6 +358,15 @@ func (builder *Dockerfile) Prepare(config *api.Config) error {
 			builder.sourceInfo = config.SourceInfo
 		}
 	}
+	if err := scm.DownloadSources(builder.fs, config); err != nil {
+		builder.setFailureReason(utilstatus.ReasonFetchSourceFailed, utilstatus.ReasonMessageFetchSourceFailed)
+		return err
+	}
+	// the labels of the Dockerfile record the derived tag
+	if err := build.ApplyAutoTag(config, builder.sourceInfo); err != nil {
+		builder.setFailureReason(utilstatus.ReasonTagImageFailed, utilstatus.ReasonMessageTagImageFailed)
+		return err
+	}
 
 	// Install scripts provided by user, overriding all others.
 	// This _could_ be an image:// URL, which would override any scripts above.
@@ -362,6 +387,22 @@ func (builder *Dockerfile) Prepare(config *api.Config) error {
 			return fmt.Errorf("could not download any scripts from URL %v", config.ScriptsURL)
 		}
 	}
+	// The scripts given a URL of their own must be downloaded from it
+	for _, result := range urlScripts {
+		if util.Includes(result.FailedSources, scripts.ScriptOverrideHandler) {
+			builder.setFailureReason(utilstatus.ReasonScriptsFetchFailed, utilstatus.ReasonMessageScriptsFetchFailed)
+			return fmt.Errorf("could not download the %s script from URL %v", result.Script, config.ScriptURLs[result.Script])
+		}
+	}
+
+	if len(config.LiteralInjections) > 0 {
+		injections, err := util.WriteLiteralInjections(builder.fs, filepath.Join(config.WorkingDir, constants.LiteralInjections), config.LiteralInjections)
+		if err != nil {
+			builder.setFailureReason(utilstatus.ReasonFSOperationFailed, utilstatus.ReasonMessageFSOperationFailed)
+			return err
+		}
+		config.Injections = append(config.Injections, injections...)
+	}
 
 	// Stage any injection(secrets) content into the working dir so the dockerfile can reference it.
 	for i, injection := range config.Injections {
@@ -370,7 +411,15 @@ func (builder *Dockerfile) Prepare(config *api.Config) error {
 		trimmedSrc := strings.TrimPrefix(injection.Source, filepath.VolumeName(injection.Source))
 		dst := filepath.Join(config.WorkingDir, constants.Injections, trimmedSrc)
 		log.V(4).Infof("Copying injection content from %s to %s", injection.Source, dst)
-		if err := builder.fs.CopyContents(injection.Source, dst, nil); err != nil {
+		copyContents := builder.fs.CopyContents
+		if info, err := builder.fs.Stat(injection.Source); err == nil && !info.IsDir() {
+			if err := builder.fs.MkdirAll(filepath.Dir(dst)); err != nil {
+				builder.setFailureReason(utilstatus.ReasonFSOperationFailed, utilstatus.ReasonMessageFSOperationFailed)
+				return err
+			}
+			copyContents = builder.fs.Copy
+		}
+		if err := copyContents(injection.Source, dst, nil); err != nil {
 			builder.setFailureReason(utilstatus.ReasonGenericS2IBuildFailed, utilstatus.ReasonMessageGenericS2iBuildFailed)
 			return err
 		}
@@ -384,7 +433,12 @@ func (builder *Dockerfile) Prepare(config *api.Config) error {
 		builder.setFailureReason(utilstatus.ReasonGenericS2IBuildFailed, utilstatus.ReasonMessageGenericS2iBuildFailed)
 		return err
 	}
-	return nil
+
+	if err := render.Sources(builder.fs, config, builder.sourceInfo); err != nil {
+		builder.setFailureReason(utilstatus.ReasonRenderTemplatesFailed, utilstatus.ReasonMessageRenderTemplatesFailed)
+		return err
+	}
+	return builder.lockInputs(config)
 }
 
 // installScripts installs scripts at the provided URL to the Dockerfile context
@@ -401,7 +455,87 @@ func (builder *Dockerfile) installScripts(scriptsURL string, config *api.Config)
 
 	// all scripts are optional, we trust the image contains scripts if we don't find them
 	// in the source repo.
-	return scriptInstaller.InstallOptional(append(scripts.RequiredScripts, scripts.OptionalScripts...), config.WorkingDir)
+	results := scriptInstaller.InstallOptional(append(scripts.RequiredScripts, scripts.OptionalScripts...), config.WorkingDir)
+	for _, r := range results {
+		if r.Error == nil {
+			builder.installedScripts[r.Script] = r
+		}
+	}
+	return results
+}
+
+// lockInputs writes the inputs resolved by the build to the lockfile, or
+// verifies them against it for locked builds, as the STI strategy does. The ID
+// of the builder image is the one inspected before the build.
+func (builder *Dockerfile) lockInputs(config *api.Config) error {
+	if len(config.LockFile) == 0 && !config.Locked {
+		return nil
+	}
+	resolved, err := builder.resolveInputs(config)
+	if err != nil {
+		builder.setFailureReason(utilstatus.ReasonGenericS2IBuildFailed, utilstatus.ReasonMessageGenericS2iBuildFailed)
+		return fmt.Errorf("unable to resolve the inputs of the build: %v", err)
+	}
+	path := lock.File(config)
+	if config.Locked {
+		log.V(1).Infof("Verifying the resolved inputs against %s", path)
+		if err := lock.Verify(path, resolved); err != nil {
+			builder.setFailureReason(utilstatus.ReasonLockfileMismatch, utilstatus.ReasonMessageLockfileMismatch)
+			return err
+		}
+		return nil
+	}
+	log.V(1).Infof("Writing the resolved inputs to %s", path)
+	if err := lock.Write(path, resolved); err != nil {
+		builder.setFailureReason(utilstatus.ReasonFSOperationFailed, utilstatus.ReasonMessageFSOperationFailed)
+		return err
+	}
+	return nil
+}
+
+// resolveInputs returns the inputs resolved by the build: the ID of the
+// builder image, the commit of the source, the scripts and the environment.
+func (builder *Dockerfile) resolveInputs(config *api.Config) (*lock.Lock, error) {
+	if len(config.BuilderImageID) == 0 {
+		return nil, fmt.Errorf("the ID of the builder image %q is unknown, it could not be inspected", config.BuilderImage)
+	}
+	resolved := &lock.Lock{
+		Version:      lock.Version,
+		BuilderImage: lock.Image{Name: config.BuilderImage, ID: config.BuilderImageID},
+		Environment:  lock.Environment(config.Environment),
+	}
+	if config.Source != nil {
+		resolved.Source = &lock.Source{
+			URL: config.Source.StringNoFragment(),
+			Ref: config.Source.URL.Fragment,
+		}
+		if builder.sourceInfo != nil {
+			resolved.Source.Commit = builder.sourceInfo.CommitID
+		}
+	}
+	names := make([]string, 0, len(builder.installedScripts))
+	for name := range builder.installedScripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		script := lock.Script{Name: name}
+		if r := builder.installedScripts[name]; r.Downloaded {
+			f, err := builder.fs.Open(filepath.Join(config.WorkingDir, constants.UploadScripts, name))
+			if err != nil {
+				return nil, err
+			}
+			script.SHA256, err = lock.Checksum(f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			script.URL = r.URL
+		}
+		resolved.Scripts = append(resolved.Scripts, script)
+	}
+	return resolved, nil
 }
 
 // setFailureReason sets the builder's failure reason with the given reason and message.
@@ -409,6 +543,114 @@ func (builder *Dockerfile) setFailureReason(reason api.StepFailureReason, messag
 	builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(reason, message)
 }
 
+// getAssembleUser returns the user the scripts run as, determined as the STI
+// strategy does: the assemble user of the configuration, the assemble user
+// label of the builder image, or the user of the builder image, which must be
+// within the allowed UIDs. The builder images which could not be inspected
+// are assumed to run their scripts as the default assemble user.
+func getAssembleUser(config *api.Config) (string, error) {
+	if len(config.AssembleUser) > 0 {
+		if !user.IsUserAllowed(config.AssembleUser, &config.AllowedUIDs) {
+			return "", s2ierr.NewAssembleUserNotAllowedError(config.BuilderImage, true)
+		}
+		return config.AssembleUser, nil
+	}
+	if config.BuilderImageLabels == nil {
+		log.V(2).Infof("The builder image %q was not inspected, running the scripts as user %q", config.BuilderImage, defaultAssembleUser)
+		if !user.IsUserAllowed(defaultAssembleUser, &config.AllowedUIDs) {
+			return "", s2ierr.NewUserNotAllowedError(config.BuilderImage, false)
+		}
+		return defaultAssembleUser, nil
+	}
+	if assembleUser := config.BuilderImageLabels[constants.AssembleUserLabel]; len(assembleUser) > 0 {
+		if !user.IsUserAllowed(userOf(assembleUser), &config.AllowedUIDs) {
+			return "", s2ierr.NewAssembleUserNotAllowedError(config.BuilderImage, false)
+		}
+		return assembleUser, nil
+	}
+	if !user.IsUserAllowed(userOf(config.BuilderImageUser), &config.AllowedUIDs) {
+		return "", s2ierr.NewUserNotAllowedError(config.BuilderImage, false)
+	}
+	if len(config.BuilderImageUser) == 0 {
+		// the images without a user run as root
+		return "root", nil
+	}
+	return config.BuilderImageUser, nil
+}
+
+// userOf returns the user of the user[:group] specification.
+func userOf(spec string) string {
+	return strings.TrimSpace(strings.SplitN(spec, ":", 2)[0])
+}
+
+// ownerOf returns the owner of the files copied into the image for the
+// user[:group] specification of the assemble user, which is in the root group
+// unless the specification tells its group.
+func ownerOf(spec string) string {
+	if strings.Contains(spec, ":") {
+		return spec
+	}
+	return spec + ":0"
+}
+
+// runAs writes the RUN instruction of the command run by the given user, set
+// by the preceding USER instruction. When capabilities are dropped, the
+// command runs as root through capsh, which drops them from its bounding set
+// and switches to the user, as the STI strategy runs the scripts in
+// containers without these capabilities; capsh must be installed in the
+// image then.
+func runAs(buffer *bytes.Buffer, dropCapabilities []string, user, command string) {
+	if len(dropCapabilities) == 0 {
+		buffer.WriteString(fmt.Sprintf("RUN %s\n", command))
+		return
+	}
+	args := []string{"capsh", "--drop=" + strings.Join(capabilityNames(dropCapabilities), ",")}
+	name, group := user, ""
+	if i := strings.Index(user, ":"); i >= 0 {
+		name, group = user[:i], user[i+1:]
+	}
+	switch {
+	case name == "root" || name == "0":
+	case isNumeric(name):
+		if !isNumeric(group) {
+			group = "0"
+		}
+		args = append(args, "--gid="+group, "--groups="+group, "--uid="+name)
+	default:
+		args = append(args, "--user="+name)
+	}
+	args = append(args, "--", "-c", shellQuote(command))
+	buffer.WriteString("# Run as root to drop the capabilities before switching to the assemble user\n")
+	buffer.WriteString("USER root\n")
+	buffer.WriteString(fmt.Sprintf("RUN %s\n", strings.Join(args, " ")))
+	buffer.WriteString(fmt.Sprintf("USER %s\n", user))
+}
+
+// capabilityNames returns the capsh names of the capabilities given as
+// --cap-drop does, where ALL stands for the default capabilities.
+func capabilityNames(capabilities []string) []string {
+	names := []string{}
+	for _, c := range capabilities {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "ALL" {
+			names = append(names, capabilityNames(defaultCapabilities)...)
+			continue
+		}
+		names = append(names, "cap_"+strings.ToLower(strings.TrimPrefix(c, "CAP_")))
+	}
+	return names
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// shellQuote quotes the command as a single argument of the shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 // getDestination returns the destination directory from the config.
 func getDestination(config *api.Config) string {
 	destination := config.Destination
@@ -489,11 +731,12 @@ func sanitize(s string) string {
 	return strings.Replace(s, "\n", "\\n", -1)
 }
 
-func createBuildEnvironment(sourcePath string, cfgEnv api.EnvironmentList) string {
+func createBuildEnvironment(sourcePath string, info *git.SourceInfo, cfgEnv api.EnvironmentList) string {
 	s2iEnv, err := scripts.GetEnvironment(filepath.Join(sourcePath, constants.Source))
 	if err != nil {
 		log.V(3).Infof("No user environment provided (%v)", err)
 	}
 
-	return scripts.ConvertEnvironmentToDocker(append(s2iEnv, cfgEnv...))
+	env := append(scripts.SourceInfoEnvironment(info), s2iEnv...)
+	return scripts.ConvertEnvironmentToDocker(append(env, cfgEnv...))
 }
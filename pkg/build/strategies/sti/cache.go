@@ -0,0 +1,58 @@
+package sti
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+)
+
+// cacheVolumePrefix prefixes the names of the cache volumes. Unlike the names
+// of the containers, it does not include the worker ID so that the builds of
+// all the workers of a host share the caches.
+const cacheVolumePrefix = "s2i-cache-"
+
+// cacheVolumeName returns the name of the volume of the cache, after the hash
+// of its mount directory and of the names and content of its key files in the
+// sources.
+func (builder *STI) cacheVolumeName(config *api.Config, cache api.CacheSpec) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, cache.Mount+"\x00")
+	for _, keyFile := range cache.KeyFiles {
+		io.WriteString(h, keyFile+"\x00")
+		f, err := builder.fs.Open(filepath.Join(config.WorkingDir, constants.Source, filepath.FromSlash(keyFile)))
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return cacheVolumePrefix + hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// prepareCaches creates the volumes of the caches of the build, unless they
+// exist, and returns their binds. A cache whose volume cannot be named or
+// created is not mounted, the build running without it.
+func (builder *STI) prepareCaches(config *api.Config) []string {
+	binds := []string{}
+	for _, cache := range config.Caches {
+		name, err := builder.cacheVolumeName(config, cache)
+		if err != nil {
+			log.Warningf("The cache of %s is not mounted: %v", cache.Mount, err)
+			continue
+		}
+		if err := builder.docker.CreateVolume(name, map[string]string{constants.CacheMountLabel: cache.Mount}); err != nil {
+			log.Warningf("The cache of %s is not mounted, unable to create volume %s: %v", cache.Mount, name, err)
+			continue
+		}
+		log.V(1).Infof("Mounting cache volume %s to %s", name, cache.Mount)
+		binds = append(binds, name+":"+cache.Mount)
+	}
+	return binds
+}
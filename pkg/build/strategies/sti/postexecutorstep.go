@@ -9,20 +9,34 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/build"
+	"github.com/openshift/source-to-image/pkg/diff"
 	dockerpkg "github.com/openshift/source-to-image/pkg/docker"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/run"
+	"github.com/openshift/source-to-image/pkg/scan"
 	s2itar "github.com/openshift/source-to-image/pkg/tar"
 	"github.com/openshift/source-to-image/pkg/util"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+	"github.com/openshift/source-to-image/pkg/util/progress"
 	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
-const maximumLabelSize = 10240
+const (
+	maximumLabelSize = 10240
+
+	// runtimeArtifactsList is the file of the builder container listing the
+	// files matched by the glob patterns of the runtime artifacts, one
+	// "<index of the artifact> <path>" line per file.
+	runtimeArtifactsList = "/tmp/.s2i-runtime-artifacts"
+)
 
 type postExecutorStepContext struct {
 	// id of the previous image that we're holding because after committing the image, we'll lose it.
@@ -47,6 +61,11 @@ type postExecutorStepContext struct {
 	// These labels are added to the image during commit.
 	// See also: commitImageStep and STI.Build()
 	labels map[string]string
+
+	// Runtime artifacts copied from the builder container: the paths in the
+	// container and the paths in the runtime artifacts directory.
+	// See also: downloadFilesFromBuilderImageStep and previewRuntimeArtifactsStep
+	runtimeArtifacts []api.VolumeSpec
 }
 
 type postExecutorStep interface {
@@ -64,6 +83,14 @@ func (step *storePreviousImageStep) execute(ctx *postExecutorStepContext) error
 		ctx.previousImageID = step.getPreviousImage()
 		return nil
 	}
+	if step.builder.config.DiffReport && len(step.builder.config.Tag) > 0 {
+		log.V(3).Info("Executing step: store previous image")
+		// the first build of the tag has no previous image to compare with
+		if found, err := step.docker.IsImageInLocalRegistry(step.builder.config.Tag); err == nil && found {
+			ctx.previousImageID = step.getPreviousImage()
+		}
+		return nil
+	}
 
 	log.V(3).Info("Skipping step: store previous image")
 	return nil
@@ -106,7 +133,10 @@ func (step *removePreviousImageStep) removePreviousImage(previousImageID string)
 }
 
 type commitImageStep struct {
-	image   string
+	image string
+	// runtime is true for the runtime image, which is committed with the
+	// runtime environment.
+	runtime bool
 	builder *STI
 	docker  dockerpkg.Docker
 	fs      fs.FileSystem
@@ -118,18 +148,30 @@ func (step *commitImageStep) execute(ctx *postExecutorStepContext) error {
 
 	user, err := step.docker.GetImageUser(step.image)
 	if err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonCommitContainerFailed,
+			utilstatus.ReasonMessageCommitContainerFailed,
+		)
 		return fmt.Errorf("could not get user of %q image: %v", step.image, err)
 	}
 
 	cmd := createCommandForExecutingRunScript(step.builder.scriptsURL, ctx.destination)
 
 	if err = checkAndGetNewLabels(step.builder, step.docker, step.tar, ctx.containerID); err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonInvalidImageLabels,
+			utilstatus.ReasonMessageInvalidImageLabels,
+		)
 		return fmt.Errorf("could not check for new labels for %q image: %v", step.image, err)
 	}
 
 	ctx.labels = createLabelsForResultingImage(step.builder, step.docker, step.image)
 
 	if err = checkLabelSize(ctx.labels); err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonInvalidImageLabels,
+			utilstatus.ReasonMessageInvalidImageLabels,
+		)
 		return fmt.Errorf("label validation failed for %q image: %v", step.image, err)
 	}
 
@@ -137,6 +179,10 @@ func (step *commitImageStep) execute(ctx *postExecutorStepContext) error {
 	// container has "env" as its entrypoint and we don't want to commit that.
 	entrypoint, err := step.docker.GetImageEntrypoint(step.image)
 	if err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonCommitContainerFailed,
+			utilstatus.ReasonMessageCommitContainerFailed,
+		)
 		return fmt.Errorf("could not get entrypoint of %q image: %v", step.image, err)
 	}
 	// If the image has no explicit entrypoint, set it to an empty array
@@ -144,14 +190,26 @@ func (step *commitImageStep) execute(ctx *postExecutorStepContext) error {
 	if entrypoint == nil {
 		entrypoint = []string{}
 	}
+	env := step.builder.env
+	if step.runtime {
+		env = step.builder.runtimeEnv
+	}
+	tag := step.builder.config.Tag
+	if build.TagAfterChecks(step.builder.config) {
+		// the image is tagged by tagImageStep once it passed its checks, so
+		// that the image of the tag is only replaced by a working one
+		tag = ""
+	}
+	progress.Step(api.StepCommitContainer)
 	startTime := time.Now()
 	ctx.imageID, err = commitContainer(
 		step.docker,
+		step.builder.config,
 		ctx.containerID,
 		cmd,
 		user,
-		step.builder.config.Tag,
-		step.builder.env,
+		tag,
+		env,
 		entrypoint,
 		ctx.labels,
 	)
@@ -164,9 +222,70 @@ func (step *commitImageStep) execute(ctx *postExecutorStepContext) error {
 		return err
 	}
 
+	if step.builder.config.MaxOutputSize > 0 {
+		if err := build.CheckOutputSize(step.docker, step.builder.config, ctx.imageID, ""); err != nil {
+			step.builder.result.BuildInfo.FailureReason, _ = utilstatus.FailureReasonOf(err)
+			return err
+		}
+	}
+	step.checkRunScript(ctx.imageID, cmd)
 	return nil
 }
 
+// checkRunScript warns when the entrypoint and command of the committed image
+// do not invoke the run script, e.g. because the Dockerfile of the builder
+// image set a shell form ENTRYPOINT, which ignores the command.
+func (step *commitImageStep) checkRunScript(imageID, runScript string) {
+	entrypoint, err := step.docker.GetImageEntrypoint(imageID)
+	if err != nil {
+		log.V(1).Infof("Unable to get the entrypoint of image %s, skipping the run script check: %v", imageID, err)
+		return
+	}
+	cmd, err := step.docker.GetImageCmd(imageID)
+	if err != nil {
+		log.V(1).Infof("Unable to get the command of image %s, skipping the run script check: %v", imageID, err)
+		return
+	}
+	if reason := runScriptOverride(entrypoint, cmd, runScript); len(reason) > 0 {
+		log.Warningf("The image %s may not run the run script %s: %s. Use --verify-run-script to check that it starts", imageID, runScript, reason)
+	} else if len(entrypoint) > 0 {
+		log.V(1).Infof("The run script %s is run by the entrypoint %q of image %s, which must execute its arguments", runScript, entrypoint, imageID)
+	}
+}
+
+// runScriptOverride returns why the given entrypoint and command do not invoke
+// the run script, or an empty string when they do.
+func runScriptOverride(entrypoint, cmd []string, runScript string) string {
+	invokes := func(args []string) bool {
+		for _, arg := range args {
+			if strings.Contains(arg, runScript) {
+				return true
+			}
+		}
+		return false
+	}
+	if invokes(entrypoint) {
+		return ""
+	}
+	if !invokes(cmd) {
+		return fmt.Sprintf("neither its entrypoint %q nor its command %q invoke it", entrypoint, cmd)
+	}
+	// the command is given as the positional parameters of a shell form
+	// ENTRYPOINT, e.g. ["/bin/sh", "-c", "exec app"], which does not run it
+	if len(entrypoint) >= 2 && entrypoint[1] == "-c" && isShell(entrypoint[0]) {
+		return fmt.Sprintf("its entrypoint %q is a shell command, which ignores the command %q", entrypoint, cmd)
+	}
+	return ""
+}
+
+func isShell(name string) bool {
+	switch path.Base(name) {
+	case "sh", "bash", "dash", "ash", "zsh":
+		return true
+	}
+	return false
+}
+
 type downloadFilesFromBuilderImageStep struct {
 	builder *STI
 	docker  dockerpkg.Docker
@@ -186,8 +305,33 @@ func (step *downloadFilesFromBuilderImageStep) execute(ctx *postExecutorStepCont
 		return fmt.Errorf("could not create directory %q: %v", artifactsDir, err)
 	}
 
-	for _, artifact := range step.builder.config.RuntimeArtifacts {
-		if err := step.downloadAndExtractFile(artifact.Source, artifactsDir, ctx.containerID); err != nil {
+	// the files matched by the glob patterns, when the builder container
+	// expanded them, by index of the runtime artifact
+	var globMatches map[int][]string
+	if step.builder.globsExpanded {
+		var err error
+		if globMatches, err = step.downloadGlobMatches(artifactsDir, ctx.containerID); err != nil {
+			step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonRuntimeArtifactsFetchFailed,
+				utilstatus.ReasonMessageRuntimeArtifactsFetchFailed,
+			)
+			return err
+		}
+	}
+
+	for i, artifact := range step.builder.config.RuntimeArtifacts {
+		// the paths of the artifact in the container, more than one for a glob
+		sources := []string{artifact.Source}
+		var err error
+		switch {
+		case isGlobPattern(artifact.Source) && globMatches != nil:
+			sources, err = step.downloadAndExtractMatches(artifact.Source, globMatches[i], artifactsDir, ctx.containerID)
+		case isGlobPattern(artifact.Source):
+			sources, err = step.downloadAndExtractGlob(artifact.Source, artifactsDir, ctx.containerID)
+		default:
+			err = step.downloadAndExtractFile(artifact.Source, artifactsDir, ctx.containerID)
+		}
+		if err != nil {
 			step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
 				utilstatus.ReasonRuntimeArtifactsFetchFailed,
 				utilstatus.ReasonMessageRuntimeArtifactsFetchFailed,
@@ -197,7 +341,10 @@ func (step *downloadFilesFromBuilderImageStep) execute(ctx *postExecutorStepCont
 
 		// for mapping like "/tmp/foo.txt -> app" we should create "app" and move "foo.txt" to that directory
 		dstSubDir := path.Clean(artifact.Destination)
-		if dstSubDir != "." && dstSubDir != "/" {
+		if dstSubDir == "/" {
+			dstSubDir = "."
+		}
+		if dstSubDir != "." {
 			dstDir := filepath.Join(artifactsDir, dstSubDir)
 			log.V(5).Infof("Creating directory %q", dstDir)
 			if err := step.fs.MkdirAll(dstDir); err != nil {
@@ -207,24 +354,181 @@ func (step *downloadFilesFromBuilderImageStep) execute(ctx *postExecutorStepCont
 				)
 				return fmt.Errorf("could not create directory %q: %v", dstDir, err)
 			}
+		}
 
-			currentFile := filepath.Base(artifact.Source)
-			oldFile := filepath.Join(artifactsDir, currentFile)
-			newFile := filepath.Join(artifactsDir, dstSubDir, currentFile)
-			log.V(5).Infof("Renaming %q to %q", oldFile, newFile)
-			if err := step.fs.Rename(oldFile, newFile); err != nil {
-				step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
-					utilstatus.ReasonFSOperationFailed,
-					utilstatus.ReasonMessageFSOperationFailed,
-				)
-				return fmt.Errorf("could not rename %q -> %q: %v", oldFile, newFile, err)
+		for _, source := range sources {
+			currentFile := filepath.Base(source)
+			if dstSubDir != "." {
+				oldFile := filepath.Join(artifactsDir, currentFile)
+				newFile := filepath.Join(artifactsDir, dstSubDir, currentFile)
+				log.V(5).Infof("Renaming %q to %q", oldFile, newFile)
+				if err := step.fs.Rename(oldFile, newFile); err != nil {
+					step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+						utilstatus.ReasonFSOperationFailed,
+						utilstatus.ReasonMessageFSOperationFailed,
+					)
+					return fmt.Errorf("could not rename %q -> %q: %v", oldFile, newFile, err)
+				}
 			}
+			ctx.runtimeArtifacts = append(ctx.runtimeArtifacts, api.VolumeSpec{
+				Source:      source,
+				Destination: path.Join(filepath.ToSlash(dstSubDir), currentFile),
+			})
 		}
 	}
 
 	return nil
 }
 
+// downloadGlobMatches downloads the list of the files matched by the glob
+// patterns of the runtime artifacts, written by the assemble command, and
+// returns the matched files by index of the runtime artifact.
+func (step *downloadFilesFromBuilderImageStep) downloadGlobMatches(artifactsDir, containerID string) (map[int][]string, error) {
+	tmpDir, err := ioutil.TempDir(artifactsDir, "s2i-runtime-artifact-globs")
+	if err != nil {
+		return nil, fmt.Errorf("could not create temporary directory for the runtime artifacts patterns: %v", err)
+	}
+	defer step.fs.RemoveDirectory(tmpDir)
+
+	if err := step.downloadAndExtractFile(runtimeArtifactsList, tmpDir, containerID); err != nil {
+		return nil, err
+	}
+	r, err := step.fs.Open(filepath.Join(tmpDir, path.Base(runtimeArtifactsList)))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	matches := map[int][]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		index, match, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %q of %s: %v", line, runtimeArtifactsList, err)
+		}
+		matches[i] = append(matches[i], match)
+	}
+	return matches, nil
+}
+
+// downloadAndExtractMatches downloads the files of the builder container
+// matched by the glob pattern, as expanded in the container, into the
+// artifacts directory, and returns their paths in the container.
+func (step *downloadFilesFromBuilderImageStep) downloadAndExtractMatches(pattern string, matches []string, artifactsDir, containerID string) ([]string, error) {
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("runtime artifact %q does not match any file in the builder container", pattern)
+	}
+	names := map[string]string{}
+	for _, match := range matches {
+		if other, ok := names[path.Base(match)]; ok {
+			return nil, fmt.Errorf("runtime artifact %q matches %q and %q, which have the same name", pattern, other, match)
+		}
+		names[path.Base(match)] = match
+		if step.fs.Exists(filepath.Join(artifactsDir, path.Base(match))) {
+			return nil, fmt.Errorf("runtime artifact %q matches %q, whose name is taken by another runtime artifact", pattern, match)
+		}
+	}
+	for _, match := range matches {
+		log.V(5).Infof("Runtime artifact %q matches %q", pattern, match)
+		if err := step.downloadAndExtractFile(match, artifactsDir, containerID); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// downloadAndExtractGlob downloads the files of the builder container matched
+// by the glob pattern into the artifacts directory, and returns their paths in
+// the container, for the layered builds which cannot expand the pattern in the
+// container. The directory the pattern starts in is downloaded, and the
+// topmost files and directories it contains which match the pattern are moved
+// to the artifacts directory.
+func (step *downloadFilesFromBuilderImageStep) downloadAndExtractGlob(pattern, artifactsDir, containerID string) ([]string, error) {
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	baseDir := globBaseDir(pattern)
+
+	tmpDir, err := ioutil.TempDir(artifactsDir, "s2i-runtime-artifact-glob")
+	if err != nil {
+		return nil, fmt.Errorf("could not create temporary directory for runtime artifact %q: %v", pattern, err)
+	}
+	defer step.fs.RemoveDirectory(tmpDir)
+
+	if err := step.downloadAndExtractFile(baseDir, tmpDir, containerID); err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	parentDir := path.Dir(baseDir)
+	err = step.fs.Walk(tmpDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(tmpDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		source := path.Join(parentDir, filepath.ToSlash(rel))
+		if ok, _ := path.Match(pattern, source); !ok {
+			return nil
+		}
+		target := filepath.Join(artifactsDir, path.Base(source))
+		if step.fs.Exists(target) {
+			return fmt.Errorf("runtime artifact %q matches %q, whose name is taken by another runtime artifact", pattern, source)
+		}
+		log.V(5).Infof("Runtime artifact %q matches %q", pattern, source)
+		if err := step.fs.Rename(p, target); err != nil {
+			return err
+		}
+		matches = append(matches, source)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("runtime artifact %q does not match any file in the builder container", pattern)
+	}
+	return matches, nil
+}
+
+type previewRuntimeArtifactsStep struct {
+	builder *STI
+	docker  dockerpkg.Docker
+}
+
+func (step *previewRuntimeArtifactsStep) execute(ctx *postExecutorStepContext) error {
+	log.V(3).Info("Executing step: preview runtime artifacts")
+
+	// the runtime artifacts are uploaded to the working directory of the image
+	image := step.builder.config.RuntimeImage
+	workDir, err := step.docker.GetImageWorkdir(image)
+	if err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonGenericS2IBuildFailed,
+			utilstatus.ReasonMessageGenericS2iBuildFailed,
+		)
+		return fmt.Errorf("could not get working dir of %q image: %v", image, err)
+	}
+
+	fmt.Fprintf(os.Stdout, "Runtime artifacts copied to the runtime image %s:\n", image)
+	for _, artifact := range ctx.runtimeArtifacts {
+		fmt.Fprintf(os.Stdout, "  %s -> %s\n", artifact.Source, path.Join(filepath.ToSlash(workDir), artifact.Destination))
+	}
+
+	step.builder.result.Success = true
+
+	return nil
+}
+
 func (step *downloadFilesFromBuilderImageStep) downloadAndExtractFile(artifactPath, artifactsDir, containerID string) error {
 	if res, err := downloadAndExtractFileFromContainer(step.docker, step.tar, artifactPath, artifactsDir, containerID); err != nil {
 		step.builder.result.BuildInfo.FailureReason = res
@@ -242,7 +546,7 @@ type startRuntimeImageAndUploadFilesStep struct {
 func (step *startRuntimeImageAndUploadFilesStep) execute(ctx *postExecutorStepContext) error {
 	log.V(3).Info("Executing step: start runtime image and upload files")
 
-	fd, err := ioutil.TempFile("", "s2i-upload-done")
+	fd, err := fs.CreateTemp("upload-done")
 	if err != nil {
 		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
 			utilstatus.ReasonGenericS2IBuildFailed,
@@ -318,17 +622,20 @@ func (step *startRuntimeImageAndUploadFilesStep) execute(ctx *postExecutorStepCo
 	)
 
 	opts := dockerpkg.RunContainerOptions{
-		Image:           image,
-		PullImage:       false, // The PullImage is false because we've already pulled the image
-		CommandExplicit: []string{"/bin/sh", "-c", cmd},
-		Stdout:          outWriter,
-		Stderr:          errWriter,
-		NetworkMode:     string(step.builder.config.DockerNetworkMode),
-		CGroupLimits:    step.builder.config.CGroupLimits,
-		CapDrop:         step.builder.config.DropCapabilities,
-		PostExec:        step.builder.postExecutor,
-		Env:             step.builder.env,
-		User:            step.builder.config.AssembleRuntimeUser,
+		Image:             image,
+		PullImage:         false, // The PullImage is false because we've already pulled the image
+		CommandExplicit:   []string{"/bin/sh", "-c", cmd},
+		Stdout:            outWriter,
+		Stderr:            errWriter,
+		NetworkMode:       string(step.builder.config.DockerNetworkMode),
+		CGroupLimits:      step.builder.config.CGroupLimits,
+		CapDrop:           step.builder.config.DropCapabilities,
+		PostExec:          step.builder.postExecutor,
+		Env:               step.builder.runtimeEnv,
+		User:              step.builder.config.AssembleRuntimeUser,
+		StopTimeout:       step.builder.config.ShutdownGracePeriod,
+		Deadline:          step.builder.config.BuildDeadline,
+		RedactEnvPatterns: step.builder.config.RedactEnvPatterns,
 	}
 
 	opts.OnStart = func(containerID string) error {
@@ -351,10 +658,10 @@ func (step *startRuntimeImageAndUploadFilesStep) execute(ctx *postExecutorStepCo
 		return onStartErr
 	}
 
-	dockerpkg.StreamContainerIO(outReader, nil, func(s string) { log.V(0).Info(s) })
+	dockerpkg.StreamContainerIO(outReader, nil, func(s string) { log.Stream(utillog.StreamStdout, s) })
 
 	errOutput := ""
-	c := dockerpkg.StreamContainerIO(errReader, &errOutput, func(s string) { log.Info(s) })
+	c := dockerpkg.StreamContainerIO(errReader, &errOutput, func(s string) { log.Stream(utillog.StreamStderr, s) })
 
 	// switch to the next stage of post executors steps
 	step.builder.postExecutorStage++
@@ -364,6 +671,10 @@ func (step *startRuntimeImageAndUploadFilesStep) execute(ctx *postExecutorStepCo
 		// Must wait for StreamContainerIO goroutine above to exit before reading errOutput.
 		<-c
 		err = s2ierr.NewContainerError(image, e.ErrorCode, errOutput+e.Output)
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonAssembleRuntimeFailed,
+			utilstatus.ReasonMessageAssembleRuntimeFailed,
+		)
 	}
 
 	return err
@@ -385,6 +696,168 @@ func (step *startRuntimeImageAndUploadFilesStep) copyScriptIfNeeded(script, dest
 	return nil
 }
 
+type scanImageStep struct {
+	builder *STI
+	docker  dockerpkg.Docker
+}
+
+func (step *scanImageStep) execute(ctx *postExecutorStepContext) error {
+	if len(step.builder.config.Scanner) == 0 {
+		log.V(3).Info("Skipping step: scan image")
+		return nil
+	}
+	log.V(3).Info("Executing step: scan image")
+
+	progress.Step(api.StepScanImage)
+	startTime := time.Now()
+	err := scan.Gate(step.builder.config, ctx.imageID)
+	step.builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(step.builder.result.BuildInfo.Stages, api.StageScan, api.StepScanImage, startTime, time.Now())
+	if err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonImageScanFailed,
+			utilstatus.ReasonMessageImageScanFailed,
+		)
+		// the image was committed untagged, and would otherwise be left dangling
+		// in the local storage of the engine
+		log.V(1).Infof("Removing image %s which failed the vulnerability scan", ctx.imageID)
+		if removeErr := step.docker.RemoveImage(ctx.imageID); removeErr != nil {
+			log.Warningf("Failed to remove image %s: %v", ctx.imageID, removeErr)
+		}
+		return err
+	}
+	return nil
+}
+
+type postCommitTestStep struct {
+	builder *STI
+	docker  dockerpkg.Docker
+}
+
+func (step *postCommitTestStep) execute(ctx *postExecutorStepContext) error {
+	if len(step.builder.config.PostCommitCommand) == 0 {
+		log.V(3).Info("Skipping step: post commit test")
+		return nil
+	}
+	log.V(3).Info("Executing step: post commit test")
+
+	progress.Step(api.StepPostCommitTest)
+	startTime := time.Now()
+	runner := &run.DockerRunner{ContainerClient: step.docker}
+	err := runner.SmokeTest(step.builder.config, ctx.imageID)
+	step.builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(step.builder.result.BuildInfo.Stages, api.StagePostCommit, api.StepPostCommitTest, startTime, time.Now())
+	if err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonPostCommitTestFailed,
+			utilstatus.ReasonMessagePostCommitTestFailed,
+		)
+		// a failed smoke test means the image is broken, so it is never tagged
+		// and the previous image of the tag is kept
+		log.V(1).Infof("Removing image %s which failed the smoke test", ctx.imageID)
+		if removeErr := step.docker.RemoveImage(ctx.imageID); removeErr != nil {
+			log.Warningf("Failed to remove image %s: %v", ctx.imageID, removeErr)
+		}
+		return err
+	}
+	return nil
+}
+
+type verifyRunScriptStep struct {
+	builder *STI
+	docker  dockerpkg.Docker
+}
+
+func (step *verifyRunScriptStep) execute(ctx *postExecutorStepContext) error {
+	if !step.builder.config.VerifyRunScript {
+		log.V(3).Info("Skipping step: verify run script")
+		return nil
+	}
+	log.V(3).Info("Executing step: verify run script")
+
+	progress.Step(api.StepVerifyRunScript)
+	startTime := time.Now()
+	runner := &run.DockerRunner{ContainerClient: step.docker}
+	err := runner.VerifyRunScript(step.builder.config, ctx.imageID)
+	step.builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(step.builder.result.BuildInfo.Stages, api.StagePostCommit, api.StepVerifyRunScript, startTime, time.Now())
+	if err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonRunScriptVerificationFailed,
+			utilstatus.ReasonMessageRunScriptVerificationFailed,
+		)
+		// the image committed with a run script which exits before it is ready
+		// would only fail again once deployed, so it is never tagged
+		log.V(1).Infof("Removing image %s whose run script failed to start", ctx.imageID)
+		if removeErr := step.docker.RemoveImage(ctx.imageID); removeErr != nil {
+			log.Warningf("Failed to remove image %s: %v", ctx.imageID, removeErr)
+		}
+		return err
+	}
+	return nil
+}
+
+type tagImageStep struct {
+	builder *STI
+	docker  dockerpkg.Docker
+}
+
+func (step *tagImageStep) execute(ctx *postExecutorStepContext) error {
+	config := step.builder.config
+	// the image committed untagged is given its tag once it passed its checks
+	var tags []string
+	if build.TagAfterChecks(config) && len(config.Tag) > 0 {
+		tags = append(tags, config.Tag)
+	}
+	if len(tags) == 0 && len(config.AdditionalTags) == 0 && len(config.TagTemplates) == 0 {
+		log.V(3).Info("Skipping step: tag image")
+		return nil
+	}
+	log.V(3).Info("Executing step: tag image")
+
+	additionalTags, err := util.AdditionalImageTags(config, step.builder.sourceInfo)
+	if err == nil {
+		for _, tag := range append(tags, additionalTags...) {
+			log.V(1).Infof("Tagging image %s as %s", ctx.imageID, tag)
+			if err = step.docker.TagImage(ctx.imageID, tag); err != nil {
+				err = fmt.Errorf("unable to tag image %s as %s: %v", ctx.imageID, tag, err)
+				break
+			}
+		}
+	}
+	if err != nil {
+		step.builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonTagImageFailed,
+			utilstatus.ReasonMessageTagImageFailed,
+		)
+		return err
+	}
+	return nil
+}
+
+type diffReportStep struct {
+	builder *STI
+	docker  dockerpkg.Docker
+}
+
+func (step *diffReportStep) execute(ctx *postExecutorStepContext) error {
+	if !step.builder.config.DiffReport || len(ctx.previousImageID) == 0 {
+		log.V(3).Info("Skipping step: diff report")
+		return nil
+	}
+	log.V(3).Info("Executing step: diff report")
+
+	// the application is installed in the working directory of the image
+	workdir, err := step.docker.GetImageWorkdir(ctx.imageID)
+	if err != nil || len(workdir) == 0 {
+		workdir = "/"
+	}
+	report, err := diff.Compare(step.docker, ctx.previousImageID, ctx.imageID, []string{workdir})
+	if err != nil {
+		log.Warningf("Unable to compare image %s with the previous image %s: %v", ctx.imageID, ctx.previousImageID, err)
+		return nil
+	}
+	log.V(0).Info(report.String())
+	return nil
+}
+
 type reportSuccessStep struct {
 	builder *STI
 }
@@ -402,15 +875,18 @@ func (step *reportSuccessStep) execute(ctx *postExecutorStepContext) error {
 
 // shared methods
 
-func commitContainer(docker dockerpkg.Docker, containerID, cmd, user, tag string, env, entrypoint []string, labels map[string]string) (string, error) {
+func commitContainer(docker dockerpkg.Docker, config *api.Config, containerID, cmd, user, tag string, env, entrypoint []string, labels map[string]string) (string, error) {
 	opts := dockerpkg.CommitContainerOptions{
-		Command:     []string{cmd},
-		Env:         env,
-		Entrypoint:  entrypoint,
-		ContainerID: containerID,
-		Repository:  tag,
-		User:        user,
-		Labels:      labels,
+		Command:           []string{cmd},
+		Env:               env,
+		Entrypoint:        entrypoint,
+		ContainerID:       containerID,
+		Repository:        tag,
+		User:              user,
+		Labels:            labels,
+		Retries:           config.CommitRetries,
+		ExportFallback:    config.CommitExportFallback,
+		RedactEnvPatterns: config.RedactEnvPatterns,
 	}
 
 	imageID, err := docker.CommitContainer(opts)
@@ -428,11 +904,23 @@ func createLabelsForResultingImage(builder *STI, docker dockerpkg.Docker, baseIm
 	if err != nil {
 		log.V(0).Infof("error: Unable to read existing labels from the base image %s", baseImage)
 	}
+	// the labels describing an intermediate layered image do not apply to the resulting image
+	inheritedLabels := map[string]string{}
+	for k, v := range existingLabels {
+		if !strings.HasPrefix(k, constants.LayeredNamespace) {
+			inheritedLabels[k] = v
+		}
+	}
+	// the engine copies the labels of the container into the committed
+	// image, the resulting image must not be removed as a temporary image
+	if _, ok := existingLabels[constants.TemporaryLabel]; ok {
+		inheritedLabels[constants.TemporaryLabel] = "false"
+	}
 
 	configLabels := builder.config.Labels
 	newLabels := builder.newLabels
 
-	return mergeLabels(existingLabels, generatedLabels, configLabels, newLabels)
+	return mergeLabels(inheritedLabels, generatedLabels, configLabels, newLabels)
 }
 
 func mergeLabels(labels ...map[string]string) map[string]string {
@@ -505,6 +993,64 @@ func downloadAndExtractFileFromContainer(docker dockerpkg.Docker, tar s2itar.Tar
 	return utilstatus.NewFailureReason("", ""), nil
 }
 
+// isGlobPattern returns true if the path of a runtime artifact is a glob
+// pattern.
+func isGlobPattern(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// isValidGlobPattern returns true if the path of a runtime artifact is a
+// valid glob pattern, which paths without wildcards are.
+func isValidGlobPattern(p string) bool {
+	_, err := path.Match(filepath.ToSlash(p), "")
+	return err == nil
+}
+
+// runtimeArtifactsExpansion returns the shell command listing the files
+// matched by the glob patterns of the given runtime artifacts to
+// runtimeArtifactsList, or an empty string when there is no pattern.
+func runtimeArtifactsExpansion(artifacts api.VolumeList) string {
+	var loops []string
+	for i, artifact := range artifacts {
+		if !isGlobPattern(artifact.Source) {
+			continue
+		}
+		pattern := shellPattern(path.Clean(filepath.ToSlash(artifact.Source)))
+		loops = append(loops, fmt.Sprintf(`for f in %s; do if [ -e "$f" ] || [ -L "$f" ]; then echo "%d $f"; fi; done`, pattern, i))
+	}
+	if len(loops) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("{ %s; } > %s", strings.Join(loops, "; "), runtimeArtifactsList)
+}
+
+// shellPattern quotes the characters of the glob pattern which the shell would
+// interpret, leaving its wildcards unquoted.
+func shellPattern(pattern string) string {
+	result := ""
+	for _, ch := range pattern {
+		switch {
+		case ch == '\'':
+			result += `"'"`
+		case strings.ContainsRune("*?[]!^-/._", ch), 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+			result += string(ch)
+		default:
+			result += "'" + string(ch) + "'"
+		}
+	}
+	return result
+}
+
+// globBaseDir returns the directory of the glob pattern without wildcards
+// which all the files the pattern matches are in.
+func globBaseDir(pattern string) string {
+	dir := pattern
+	for isGlobPattern(dir) {
+		dir = path.Dir(dir)
+	}
+	return dir
+}
+
 func checkLabelSize(labels map[string]string) error {
 	var sum = 0
 	for k, v := range labels {
@@ -1,11 +1,22 @@
 package sti
 
 import (
+	"archive/tar"
+	"bytes"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/docker"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
 func TestStorePreviousImageStep(t *testing.T) {
@@ -151,6 +162,7 @@ func TestCommitImageStep(t *testing.T) {
 		fakeDocker.CommitContainerResult = expectedImageID
 		fakeDocker.GetImageUserResult = expectedImageUser
 		fakeDocker.GetImageEntrypointResult = expectedEntrypoint
+		fakeDocker.GetImageCmdResult = []string{testCase.expectedImageCmd}
 		fakeDocker.Labels = baseImageLabels
 
 		ctx := &postExecutorStepContext{
@@ -209,14 +221,297 @@ func TestCommitImageStep(t *testing.T) {
 	}
 }
 
+func TestCommitImageStepMaxOutputSize(t *testing.T) {
+	testCases := []struct {
+		name           string
+		layerSize      int64
+		noHistory      bool
+		expectedErr    bool
+		expectedReason api.StepFailureReason
+	}{
+		{
+			name:      "within the limit",
+			layerSize: 1024 * 1024,
+		},
+		{
+			name:           "over the limit",
+			layerSize:      3 * 1024 * 1024,
+			expectedErr:    true,
+			expectedReason: utilstatus.ReasonQuotaExceeded,
+		},
+		{
+			name:           "unknown size",
+			noHistory:      true,
+			expectedErr:    true,
+			expectedReason: utilstatus.ReasonGenericS2IBuildFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := newFakeBaseSTI()
+			builder.config.Tag = "app:latest"
+			builder.config.MaxOutputSize = 2
+			fakeDocker := builder.docker.(*docker.FakeDocker)
+			fakeDocker.CommitContainerResult = "image-xxx"
+			fakeDocker.ResourceUsageResult = api.ResourceUsage{Layers: []api.LayerUsage{{CreatedBy: "assemble", SizeBytes: tc.layerSize}}}
+			if tc.noHistory {
+				fakeDocker.ResourceUsageResult = api.ResourceUsage{}
+			}
+
+			step := &commitImageStep{builder: builder, docker: fakeDocker}
+			err := step.execute(&postExecutorStepContext{containerID: "container-yyyy", destination: "/tmp"})
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if repository := fakeDocker.CommitContainerOpts.Repository; repository != "" {
+				t.Errorf("expected the image to be committed untagged, got %q", repository)
+			}
+			if len(fakeDocker.TagImageTags) > 0 {
+				t.Errorf("expected the image to be tagged after its checks, got %v", fakeDocker.TagImageTags)
+			}
+			if tc.expectedErr {
+				if fakeDocker.RemoveImageName != "image-xxx" {
+					t.Errorf("expected the image to be removed, got %q", fakeDocker.RemoveImageName)
+				}
+				if reason := builder.result.BuildInfo.FailureReason.Reason; reason != tc.expectedReason {
+					t.Errorf("expected failure reason %s, got %s", tc.expectedReason, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestTagAfterChecks(t *testing.T) {
+	for _, passed := range []bool{true, false} {
+		builder := newFakeBaseSTI()
+		builder.config.Tag = "app:latest"
+		builder.config.PostCommitCommand = "true"
+		fakeDocker := builder.docker.(*docker.FakeDocker)
+		fakeDocker.CommitContainerResult = "image-xxx"
+		if !passed {
+			fakeDocker.RunContainerError = fmt.Errorf("the container failed")
+			fakeDocker.RunContainerErrorBeforeStart = true
+		}
+
+		ctx := &postExecutorStepContext{containerID: "container-yyyy", destination: "/tmp"}
+		steps := []postExecutorStep{
+			&commitImageStep{builder: builder, docker: fakeDocker},
+			&postCommitTestStep{builder: builder, docker: fakeDocker},
+			&tagImageStep{builder: builder, docker: fakeDocker},
+		}
+		var err error
+		for _, step := range steps {
+			if err = step.execute(ctx); err != nil {
+				break
+			}
+		}
+		if passed != (err == nil) {
+			t.Fatalf("passed %v: unexpected error %v", passed, err)
+		}
+		if repository := fakeDocker.CommitContainerOpts.Repository; repository != "" {
+			t.Errorf("passed %v: expected the image to be committed untagged, got %q", passed, repository)
+		}
+		if passed {
+			if !reflect.DeepEqual(fakeDocker.TagImageTags, []string{"app:latest"}) || fakeDocker.TagImageName != "image-xxx" {
+				t.Errorf("expected image-xxx to be tagged app:latest, got %q tagged %v", fakeDocker.TagImageName, fakeDocker.TagImageTags)
+			}
+			continue
+		}
+		if len(fakeDocker.TagImageTags) > 0 {
+			t.Errorf("expected the image failing its test not to be tagged, got %v", fakeDocker.TagImageTags)
+		}
+		if fakeDocker.RemoveImageName != "image-xxx" {
+			t.Errorf("expected the untagged image to be removed, got %q", fakeDocker.RemoveImageName)
+		}
+	}
+}
+
+func TestRunScriptOverride(t *testing.T) {
+	runScript := "/usr/libexec/s2i/run"
+	tests := map[string]struct {
+		entrypoint []string
+		cmd        []string
+		overridden bool
+	}{
+		"command":                   {cmd: []string{runScript}},
+		"exec form entrypoint":      {entrypoint: []string{"container-entrypoint"}, cmd: []string{runScript}},
+		"shell form command":        {cmd: []string{"/bin/sh", "-c", runScript}},
+		"shell form entrypoint":     {entrypoint: []string{"/bin/sh", "-c", "exec node server.js"}, cmd: []string{runScript}, overridden: true},
+		"entrypoint running it":     {entrypoint: []string{"/bin/bash", "-c", runScript}},
+		"command without it":        {cmd: []string{"node", "server.js"}, overridden: true},
+		"no entrypoint nor command": {overridden: true},
+	}
+	for desc, tc := range tests {
+		if reason := runScriptOverride(tc.entrypoint, tc.cmd, runScript); (len(reason) > 0) != tc.overridden {
+			t.Errorf("%s: expected the run script to be overridden: %v, got %q", desc, tc.overridden, reason)
+		}
+	}
+}
+
 func TestDownloadFilesFromBuilderImageStep(t *testing.T) {
-	// FIXME
+	workingDir, err := os.MkdirTemp("", "s2i-runtime-artifacts-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workingDir)
+	if err := os.MkdirAll(filepath.Join(workingDir, "upload"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tar streams of the files of the builder container
+	tarStream := func(files ...string) string {
+		buf := &bytes.Buffer{}
+		w := tar.NewWriter(buf)
+		for _, name := range files {
+			w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name)), Typeflag: tar.TypeReg})
+			w.Write([]byte(name))
+		}
+		w.Close()
+		return buf.String()
+	}
+	builder := newFakeBaseSTI()
+	builder.config.WorkingDir = workingDir
+	builder.config.RuntimeArtifacts = api.VolumeList{
+		{Source: "/opt/app/libs/*.jar", Destination: "lib"},
+		{Source: "/opt/app/run.sh", Destination: "."},
+	}
+	fakeDocker := builder.docker.(*docker.FakeDocker)
+	fakeDocker.DownloadFromContainerResult = map[string]string{
+		"/opt/app/libs":   tarStream("libs/app.jar", "libs/deps.jar", "libs/README"),
+		"/opt/app/run.sh": tarStream("run.sh"),
+	}
+	fileSystem := fs.NewFileSystem()
+	step := &downloadFilesFromBuilderImageStep{builder: builder, docker: fakeDocker, fs: fileSystem, tar: s2itar.New(fileSystem)}
+	ctx := &postExecutorStepContext{containerID: "builder"}
+
+	if err := step.execute(ctx); err != nil {
+		t.Fatalf("should exit without error, but it returned %v", err)
+	}
+	expected := []api.VolumeSpec{
+		{Source: "/opt/app/libs/app.jar", Destination: "lib/app.jar"},
+		{Source: "/opt/app/libs/deps.jar", Destination: "lib/deps.jar"},
+		{Source: "/opt/app/run.sh", Destination: "run.sh"},
+	}
+	if !reflect.DeepEqual(ctx.runtimeArtifacts, expected) {
+		t.Errorf("should copy the runtime artifacts %v but copied %v", expected, ctx.runtimeArtifacts)
+	}
+	artifactsDir := filepath.Join(workingDir, constants.RuntimeArtifactsDir)
+	entries, err := os.ReadDir(artifactsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"lib", "run.sh"}) {
+		t.Errorf("should leave only the runtime artifacts in %s but found %v", artifactsDir, names)
+	}
+
+	// a pattern which does not match any file fails the build
+	builder.config.RuntimeArtifacts = api.VolumeList{{Source: "/opt/app/libs/*.war", Destination: "."}}
+	os.RemoveAll(artifactsDir)
+	if err := step.execute(ctx); err == nil {
+		t.Errorf("should return an error for a pattern which matches no file")
+	}
+	if builder.result.BuildInfo.FailureReason.Reason != utilstatus.ReasonRuntimeArtifactsFetchFailed {
+		t.Errorf("should set the failure reason %q but it's %q", utilstatus.ReasonRuntimeArtifactsFetchFailed, builder.result.BuildInfo.FailureReason.Reason)
+	}
+
+	// the patterns expanded in the builder container copy only the matches
+	builder.globsExpanded = true
+	builder.config.RuntimeArtifacts = api.VolumeList{{Source: "/opt/app/libs/*.jar", Destination: "lib"}}
+	fakeDocker.DownloadFromContainerPaths = nil
+	list := &bytes.Buffer{}
+	w := tar.NewWriter(list)
+	matches := "0 /opt/app/libs/app.jar\n0 /opt/app/libs/deps.jar\n"
+	w.WriteHeader(&tar.Header{Name: path.Base(runtimeArtifactsList), Mode: 0644, Size: int64(len(matches)), Typeflag: tar.TypeReg})
+	w.Write([]byte(matches))
+	w.Close()
+	fakeDocker.DownloadFromContainerResult = map[string]string{
+		runtimeArtifactsList:     list.String(),
+		"/opt/app/libs/app.jar":  tarStream("app.jar"),
+		"/opt/app/libs/deps.jar": tarStream("deps.jar"),
+	}
+	os.RemoveAll(artifactsDir)
+	ctx = &postExecutorStepContext{containerID: "builder"}
+	if err := step.execute(ctx); err != nil {
+		t.Fatalf("should exit without error, but it returned %v", err)
+	}
+	expected = []api.VolumeSpec{
+		{Source: "/opt/app/libs/app.jar", Destination: "lib/app.jar"},
+		{Source: "/opt/app/libs/deps.jar", Destination: "lib/deps.jar"},
+	}
+	if !reflect.DeepEqual(ctx.runtimeArtifacts, expected) {
+		t.Errorf("should copy the runtime artifacts %v but copied %v", expected, ctx.runtimeArtifacts)
+	}
+	expectedPaths := []string{runtimeArtifactsList, "/opt/app/libs/app.jar", "/opt/app/libs/deps.jar"}
+	if !reflect.DeepEqual(fakeDocker.DownloadFromContainerPaths, expectedPaths) {
+		t.Errorf("should download only %v but downloaded %v", expectedPaths, fakeDocker.DownloadFromContainerPaths)
+	}
+}
+
+func TestRuntimeArtifactsExpansion(t *testing.T) {
+	artifacts := api.VolumeList{
+		{Source: "/opt/app/run.sh", Destination: "."},
+		{Source: "/opt/my app/libs/*.jar", Destination: "lib"},
+	}
+	expected := `{ for f in /opt/my' 'app/libs/*.jar; do if [ -e "$f" ] || [ -L "$f" ]; then echo "1 $f"; fi; done; } > ` + runtimeArtifactsList
+	if expansion := runtimeArtifactsExpansion(artifacts); expansion != expected {
+		t.Errorf("runtimeArtifactsExpansion() = %q, expected %q", expansion, expected)
+	}
+	if expansion := runtimeArtifactsExpansion(artifacts[:1]); expansion != "" {
+		t.Errorf("runtimeArtifactsExpansion() = %q, expected no command without patterns", expansion)
+	}
+}
+
+func TestGlobBaseDir(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"/opt/app/libs/*.jar":    "/opt/app/libs",
+		"/opt/app/*/build/*.jar": "/opt/app",
+		"/opt/app/lib[0-9]":      "/opt/app",
+		"/*.jar":                 "/",
+	} {
+		if dir := globBaseDir(pattern); dir != expected {
+			t.Errorf("globBaseDir(%q) = %q, expected %q", pattern, dir, expected)
+		}
+	}
 }
 
 func TestStartRuntimeImageAndUploadFilesStep(t *testing.T) {
 	// FIXME
 }
 
+func TestTagImageStep(t *testing.T) {
+	builder := newFakeBaseSTI()
+	builder.config.Tag = "quay.io/user/app:latest"
+	builder.config.AdditionalTags = []string{"quay.io/user/app:v1"}
+	builder.config.TagTemplates = []string{"{{.Repo}}:{{.GitShortSHA}}", "{{.Repo}}:{{.GitBranch}}"}
+	builder.sourceInfo = &git.SourceInfo{CommitID: "0123456789abcdef", Ref: "release/1.0"}
+	fakeDocker := builder.docker.(*docker.FakeDocker)
+	step := &tagImageStep{builder: builder, docker: fakeDocker}
+	ctx := &postExecutorStepContext{imageID: "sha256:1234"}
+
+	if err := step.execute(ctx); err != nil {
+		t.Fatalf("should exit without error, but it returned %v", err)
+	}
+	if fakeDocker.TagImageName != ctx.imageID {
+		t.Errorf("should tag image %q but tagged %q", ctx.imageID, fakeDocker.TagImageName)
+	}
+	expected := []string{"quay.io/user/app:v1", "quay.io/user/app:0123456", "quay.io/user/app:release-1.0"}
+	if !reflect.DeepEqual(fakeDocker.TagImageTags, expected) {
+		t.Errorf("should apply tags %v but applied %v", expected, fakeDocker.TagImageTags)
+	}
+
+	fakeDocker.TagImageError = fmt.Errorf("fail")
+	if err := step.execute(ctx); err == nil {
+		t.Errorf("should return the tag error")
+	}
+	if builder.result.BuildInfo.FailureReason.Reason != utilstatus.ReasonTagImageFailed {
+		t.Errorf("should set the failure reason %q but it's %q", utilstatus.ReasonTagImageFailed, builder.result.BuildInfo.FailureReason.Reason)
+	}
+}
+
 func TestReportSuccessStep(t *testing.T) {
 	builder := newFakeBaseSTI()
 	step := &reportSuccessStep{builder: builder}
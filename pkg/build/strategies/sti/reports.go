@@ -0,0 +1,94 @@
+package sti
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+)
+
+// reportsDir returns the directory of the builder image the assemble script
+// writes its test reports to, as declared by its io.openshift.s2i.reports
+// label.
+func (builder *STI) reportsDir(config *api.Config) string {
+	labels, err := builder.docker.GetLabels(config.BuilderImage)
+	if err != nil {
+		log.V(1).Infof("Unable to read the labels of %s, test reports will not be extracted: %v", config.BuilderImage, err)
+		return ""
+	}
+	dir := labels[constants.ReportsLabel]
+	if len(dir) == 0 {
+		log.V(1).Infof("The image %s does not declare a test reports directory with the %s label", config.BuilderImage, constants.ReportsLabel)
+	}
+	return dir
+}
+
+// extractReports copies the content of the reportsDir directory of the
+// container to config.ReportsDir. Failing to do so does not fail the build,
+// as the directory does not exist when the assemble script failed before
+// writing any report.
+func (builder *STI) extractReports(config *api.Config, reportsDir, containerID string) {
+	destination, err := filepath.Abs(config.ReportsDir)
+	if err != nil {
+		log.Warningf("Unable to extract the test reports: %v", err)
+		return
+	}
+	if err := builder.fs.MkdirAll(destination); err != nil {
+		log.Warningf("Unable to extract the test reports: %v", err)
+		return
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(builder.docker.DownloadFromContainer(reportsDir, w, containerID))
+	}()
+	reader := &reportsReader{Reader: tar.NewReader(r)}
+	err = s2itar.NewParanoid(builder.fs).ExtractTarStreamFromTarReader(destination, reader, nil)
+	io.Copy(ioutil.Discard, r)
+	r.Close()
+	if err != nil {
+		log.Warningf("Unable to extract the test reports from %s: %v", reportsDir, err)
+		return
+	}
+	log.V(0).Infof("Extracted %d test reports to %s", reader.files, destination)
+}
+
+// reportsReader strips the reports directory from the names of the entries
+// of the archive downloaded from the container, which are prefixed by its
+// base name.
+type reportsReader struct {
+	*tar.Reader
+	files int
+}
+
+// Next returns the header of the next entry of the archive, skipping the
+// reports directory itself.
+func (r *reportsReader) Next() (*tar.Header, error) {
+	for {
+		header, err := r.Reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Clean(header.Name), "/")
+		i := strings.Index(name, "/")
+		if i < 0 {
+			continue
+		}
+		header.Name = name[i+1:]
+		if header.Typeflag == tar.TypeLink {
+			if link := strings.TrimPrefix(path.Clean(header.Linkname), "/"); strings.Contains(link, "/") {
+				header.Linkname = link[strings.Index(link, "/")+1:]
+			}
+		}
+		if header.Typeflag == tar.TypeReg {
+			r.files++
+		}
+		return header, nil
+	}
+}
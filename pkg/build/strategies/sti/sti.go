@@ -1,6 +1,7 @@
 package sti
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -9,16 +10,22 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/artifacts"
 	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/build/strategies/layered"
 	dockerpkg "github.com/openshift/source-to-image/pkg/docker"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/hermetic"
 	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/lock"
+	"github.com/openshift/source-to-image/pkg/render"
 	"github.com/openshift/source-to-image/pkg/scm"
 	"github.com/openshift/source-to-image/pkg/scm/git"
 	"github.com/openshift/source-to-image/pkg/scripts"
@@ -26,7 +33,10 @@ import (
 	"github.com/openshift/source-to-image/pkg/util"
 	"github.com/openshift/source-to-image/pkg/util/cmd"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+	"github.com/openshift/source-to-image/pkg/util/interrupt"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
+	"github.com/openshift/source-to-image/pkg/util/progress"
 	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
@@ -71,9 +81,18 @@ type STI struct {
 	installedScripts       map[string]bool
 	scriptsURL             map[string]string
 	incremental            bool
+	missingRequirements    bool
+	noBash                 bool
+	destination            string
 	sourceInfo             *git.SourceInfo
 	env                    []string
+	runtimeEnv             []string
+	cacheBinds             []string
+	volumeBinds            []string
 	newLabels              map[string]string
+	// globsExpanded is true when the assemble command lists the files matched
+	// by the glob patterns of the runtime artifacts to runtimeArtifactsList
+	globsExpanded bool
 
 	// Interfaces
 	preparer  build.Preparer
@@ -100,6 +119,10 @@ func New(client dockerpkg.Client, config *api.Config, fs fs.FileSystem, override
 	if err != nil {
 		return nil, err
 	}
+	excludeGlobs, err := ignore.NewGlobMatcher(config.ExcludeGlobs, config.IncludeGlobs)
+	if err != nil {
+		return nil, err
+	}
 
 	docker := dockerpkg.New(client, config.PullAuthentication)
 	var incrementalDocker dockerpkg.Docker
@@ -118,6 +141,21 @@ func New(client dockerpkg.Client, config *api.Config, fs fs.FileSystem, override
 	)
 	tarHandler := tar.NewParanoid(fs)
 	tarHandler.SetExclusionPattern(excludePattern)
+	// the upload directory which is archived holds the sources in src
+	tarHandler.SetExclusionGlobs(excludeGlobs.In("src"))
+	tarHandler.SetSymlinkPolicy(config.SymlinkPolicy)
+	// and the scripts in scripts and src/.s2i/bin
+	tarHandler.SetCRLFConversion(config.ConvertCRLF, "scripts", filepath.Join("src", ".s2i", "bin"))
+
+	// the volumes given on Windows hosts are mounted by Linux daemons
+	volumeBinds, err := hostpath.TranslateBinds(config.BuildVolumes)
+	if err != nil {
+		return nil, err
+	}
+	callbackInvoker, err := util.NewCallbackInvoker(config)
+	if err != nil {
+		return nil, err
+	}
 
 	builder := &STI{
 		installer:              inst,
@@ -127,7 +165,7 @@ func New(client dockerpkg.Client, config *api.Config, fs fs.FileSystem, override
 		git:                    git.New(fs, cmd.NewCommandRunner()),
 		fs:                     fs,
 		tar:                    tarHandler,
-		callbackInvoker:        util.NewCallbackInvoker(),
+		callbackInvoker:        callbackInvoker,
 		requiredScripts:        scripts.RequiredScripts,
 		optionalScripts:        scripts.OptionalScripts,
 		optionalRuntimeScripts: []string{constants.AssembleRuntime},
@@ -135,6 +173,7 @@ func New(client dockerpkg.Client, config *api.Config, fs fs.FileSystem, override
 		installedScripts:       map[string]bool{},
 		scriptsURL:             map[string]string{},
 		newLabels:              map[string]string{},
+		volumeBinds:            volumeBinds,
 	}
 
 	if len(config.RuntimeImage) > 0 {
@@ -158,7 +197,7 @@ func New(client dockerpkg.Client, config *api.Config, fs fs.FileSystem, override
 	// which would lead to replacing this quick short circuit (so this change is tactical)
 	builder.source = overrides.Downloader
 	if builder.source == nil && !config.Usage {
-		downloader, err := scm.DownloaderForSource(builder.fs, config.Source, config.ForceCopy)
+		downloader, err := scm.DownloaderForConfig(builder.fs, config)
 		if err != nil {
 			return nil, err
 		}
@@ -173,9 +212,10 @@ func New(client dockerpkg.Client, config *api.Config, fs fs.FileSystem, override
 
 	// Set interfaces
 	builder.preparer = builder
-	// later on, if we support say .gitignore func in addition to .dockerignore
-	// func, setting ignorer will be based on config setting
-	builder.ignorer = &ignore.DockerIgnorer{}
+	builder.ignorer, err = ignore.New(config.Ignorers)
+	if err != nil {
+		return nil, err
+	}
 	builder.artifacts = builder
 	builder.scripts = builder
 	builder.postExecutor = builder
@@ -191,24 +231,27 @@ func New(client dockerpkg.Client, config *api.Config, fs fs.FileSystem, override
 func (builder *STI) Build(config *api.Config) (*api.Result, error) {
 	builder.result = &api.Result{}
 
-	if len(builder.config.CallbackURL) > 0 {
+	if len(builder.config.CallbackURLs) > 0 {
+		util.FireCallbacks(builder.callbackInvoker, builder.config, api.CallbackEventStarted, builder.result, nil)
 		defer func() {
-			builder.result.Messages = builder.callbackInvoker.ExecuteCallback(
-				builder.config.CallbackURL,
-				builder.result.Success,
-				builder.postExecutorStepsContext.labels,
-				builder.result.Messages,
-			)
+			util.FireCallbacks(builder.callbackInvoker, builder.config, api.CallbackEventCompleted, builder.result, builder.postExecutorStepsContext.labels)
 		}()
 	}
 	defer builder.garbage.Cleanup(config)
+	defer builder.recordResourceUsage()
+	// the temporary directories are removed when s2i is interrupted too
+	defer interrupt.Register(func(os.Signal) { builder.garbage.Cleanup(config) })()
 
 	log.V(1).Infof("Preparing to build %s", config.Tag)
 	if err := builder.preparer.Prepare(config); err != nil {
 		return builder.result, err
 	}
 
-	if builder.incremental = builder.artifacts.Exists(config); builder.incremental {
+	builder.cacheBinds = builder.prepareCaches(config)
+
+	if len(config.RestoreArtifacts) > 0 {
+		log.V(1).Infof("Restoring build artifacts from %s", config.RestoreArtifacts)
+	} else if builder.incremental = builder.artifacts.Exists(config); builder.incremental {
 		tag := util.FirstNonEmpty(config.IncrementalFromTag, config.Tag)
 		log.V(1).Infof("Existing image for tag %s detected for incremental build", tag)
 	} else {
@@ -216,7 +259,17 @@ func (builder *STI) Build(config *api.Config) (*api.Result, error) {
 	}
 
 	log.V(2).Infof("Performing source build from %s", config.Source)
-	if builder.incremental {
+	if len(config.RestoreArtifacts) > 0 {
+		// the artifacts were asked for, so the build does not silently fall
+		// back to a clean build
+		if err := builder.restoreArtifacts(config); err != nil {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonRestoreArtifactsFailed,
+				utilstatus.ReasonMessageRestoreArtifactsFailed,
+			)
+			return builder.result, fmt.Errorf("unable to restore the build artifacts of %s: %v", config.RestoreArtifacts, err)
+		}
+	} else if builder.incremental {
 		if err := builder.artifacts.Save(config); err != nil {
 			log.Warning("Clean build will be performed because of error saving previous build artifacts")
 			log.V(2).Infof("error: %v", err)
@@ -228,23 +281,40 @@ func (builder *STI) Build(config *api.Config) (*api.Result, error) {
 	} else {
 		log.V(1).Infof("Running %q in %q", constants.Assemble, config.Tag)
 	}
+	if config.LayeredFallback == api.LayeredFallbackAlways || builder.missingRequirements {
+		log.V(1).Info("Layered build will be performed")
+		return builder.layeredBuild(config)
+	}
+	progress.Step(api.StepAssembleBuildScripts)
 	startTime := time.Now()
 	if err := builder.scripts.Execute(constants.Assemble, config.AssembleUser, config); err != nil {
-		if err == errMissingRequirements {
-			log.V(1).Info("Image is missing basic requirements (sh or tar), layered build will be performed")
-			return builder.layered.Build(config)
+		if e, ok := err.(s2ierr.ContainerError); ok && isMissingRequirements(e.Output) {
+			err = errMissingRequirements
 		}
-		if e, ok := err.(s2ierr.ContainerError); ok {
-			if !isMissingRequirements(e.Output) {
+		if err == errMissingRequirements {
+			builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StageAssemble, api.StepLayeredFallback, startTime, time.Now())
+			if config.LayeredFallback == api.LayeredFallbackNever {
 				builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
-					utilstatus.ReasonAssembleFailed,
-					utilstatus.ReasonMessageAssembleFailed,
+					utilstatus.ReasonBuilderImageMissingRequirements,
+					utilstatus.ReasonMessageBuilderImageMissingRequirements,
 				)
-				return builder.result, err
+				return builder.result, fmt.Errorf("image %q is missing basic requirements (sh or tar) and the fallback to a layered build is disabled", config.BuilderImage)
 			}
-			log.V(1).Info("Image is missing basic requirements (sh or tar), layered build will be performed")
-			buildResult, err := builder.layered.Build(config)
-			return buildResult, err
+			log.Warningf("Image %q is missing basic requirements (sh or tar), a layered build will be performed instead", config.BuilderImage)
+			return builder.layeredBuild(config)
+		}
+		if e, ok := err.(s2ierr.ContainerError); ok && isDestinationNotWritable(e.Output) {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonDestinationNotWritable,
+				utilstatus.ReasonMessageDestinationNotWritable,
+			)
+			return builder.result, builder.destinationNotWritableError(config)
+		}
+		if _, ok := err.(s2ierr.ContainerError); ok {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonAssembleFailed,
+				utilstatus.ReasonMessageAssembleFailed,
+			)
 		}
 
 		return builder.result, err
@@ -255,6 +325,26 @@ func (builder *STI) Build(config *api.Config) (*api.Result, error) {
 	return builder.result, nil
 }
 
+// layeredBuild performs a layered build, and reports the stages of the build
+// performed so far in its result.
+func (builder *STI) layeredBuild(config *api.Config) (*api.Result, error) {
+	result, err := builder.layered.Build(config)
+	if result != nil {
+		result.BuildInfo.Stages = api.MergeStageInfo(builder.result.BuildInfo.Stages, result.BuildInfo.Stages)
+	}
+	return result, err
+}
+
+// recordResourceUsage reports the resources consumed by the build in its
+// result.
+func (builder *STI) recordResourceUsage() {
+	usage, err := builder.docker.GetResourceUsage(builder.result.ImageID)
+	if err != nil {
+		log.V(1).Infof("Unable to determine the resources consumed by the build: %v", err)
+	}
+	builder.result.BuildInfo.Resources = usage
+}
+
 // Prepare prepares the source code and tar for build.
 // NOTE: this func serves both the sti and onbuild strategies, as the OnBuild
 // struct Build func leverages the STI struct Prepare func directly below.
@@ -277,6 +367,16 @@ func (builder *STI) Prepare(config *api.Config) error {
 	builder.result.WorkingDir = config.WorkingDir
 
 	if len(config.RuntimeImage) > 0 {
+		if !config.SkipDiskCheck && config.RuntimeImagePullPolicy != api.PullNever {
+			if err = dockerpkg.CheckPullDiskSpace(builder.runtimeDocker, config.RuntimeImage); err != nil {
+				builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+					utilstatus.ReasonInsufficientDiskSpace,
+					utilstatus.ReasonMessageInsufficientDiskSpace,
+				)
+				return err
+			}
+		}
+		progress.Step(api.StepPullRuntimeImage)
 		startTime := time.Now()
 		dockerpkg.GetRuntimeImage(builder.runtimeDocker, config)
 		builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StagePullImages, api.StepPullRuntimeImage, startTime, time.Now())
@@ -337,6 +437,10 @@ func (builder *STI) Prepare(config *api.Config) error {
 			var volumeErr error
 
 			switch {
+			case isGlobPattern(volumeSpec.Source) && globBaseDir(path.Clean(filepath.ToSlash(volumeSpec.Source))) == "/":
+				volumeErr = fmt.Errorf("invalid runtime artifacts mapping: %q -> %q: source pattern must start with a directory without wildcards", volumeSpec.Source, volumeSpec.Destination)
+			case !isValidGlobPattern(volumeSpec.Source):
+				volumeErr = fmt.Errorf("invalid runtime artifacts mapping: %q -> %q: %v", volumeSpec.Source, volumeSpec.Destination, path.ErrBadPattern)
 			case !path.IsAbs(filepath.ToSlash(volumeSpec.Source)):
 				volumeErr = fmt.Errorf("invalid runtime artifacts mapping: %q -> %q: source must be an absolute path", volumeSpec.Source, volumeSpec.Destination)
 			case path.IsAbs(volumeSpec.Destination):
@@ -367,9 +471,38 @@ func (builder *STI) Prepare(config *api.Config) error {
 		}
 	}
 
+	if len(config.LiteralInjections) > 0 {
+		injections, err := util.WriteLiteralInjections(builder.fs, filepath.Join(config.WorkingDir, constants.LiteralInjections), config.LiteralInjections)
+		if err != nil {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonFSOperationFailed,
+				utilstatus.ReasonMessageFSOperationFailed,
+			)
+			return err
+		}
+		config.Injections = append(config.Injections, injections...)
+	}
+
+	// probe the builder image before fetching the sources, which might be huge
+	if err = builder.probeBuilderImage(config); err != nil {
+		return err
+	}
+
+	if err = builder.checkSourceDiskSpace(config); err != nil {
+		builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonInsufficientDiskSpace,
+			utilstatus.ReasonMessageInsufficientDiskSpace,
+		)
+		return err
+	}
+
 	// fetch sources, for their .s2i/bin might contain s2i scripts
-	if config.Source != nil {
-		if builder.sourceInfo, err = builder.source.Download(config); err != nil {
+	if config.Source != nil || len(config.ArtifactPath) > 0 {
+		progress.Step(api.StepFetchSource)
+		startTime := time.Now()
+		builder.sourceInfo, err = builder.source.Download(config)
+		builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StageFetchInputs, api.StepFetchSource, startTime, time.Now())
+		if err != nil {
 			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
 				utilstatus.ReasonFetchSourceFailed,
 				utilstatus.ReasonMessageFetchSourceFailed,
@@ -380,6 +513,25 @@ func (builder *STI) Prepare(config *api.Config) error {
 			builder.sourceInfo = config.SourceInfo
 		}
 	}
+	if len(config.Sources) > 0 {
+		startTime := time.Now()
+		err = scm.DownloadSources(builder.fs, config)
+		builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StageFetchInputs, api.StepFetchSource, startTime, time.Now())
+		if err != nil {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonFetchSourceFailed,
+				utilstatus.ReasonMessageFetchSourceFailed,
+			)
+			return err
+		}
+	}
+
+	// the layered and onbuild strategies build with the config prepared here,
+	// and so with its derived tag
+	if err := build.ApplyAutoTag(config, builder.sourceInfo); err != nil {
+		builder.result.BuildInfo.FailureReason, _ = utilstatus.FailureReasonOf(err)
+		return err
+	}
 
 	// get the scripts
 	required, err := builder.installer.InstallRequired(builder.requiredScripts, config.WorkingDir)
@@ -416,6 +568,17 @@ func (builder *STI) Prepare(config *api.Config) error {
 		}
 	}
 
+	// The scripts given a URL of their own must be downloaded from it
+	for _, result := range requiredAndOptional {
+		if util.Includes(result.FailedSources, scripts.ScriptOverrideHandler) {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonScriptsFetchFailed,
+				utilstatus.ReasonMessageScriptsFetchFailed,
+			)
+			return fmt.Errorf("could not download the %s script from URL %v", result.Script, config.ScriptURLs[result.Script])
+		}
+	}
+
 	for _, r := range requiredAndOptional {
 		if r.Error != nil {
 			log.Warningf("Error getting %v from %s: %v", r.Script, r.URL, r.Error)
@@ -427,9 +590,255 @@ func (builder *STI) Prepare(config *api.Config) error {
 		builder.scriptsURL[r.Script] = r.URL
 	}
 
+	if err = builder.lintScripts(config); err != nil {
+		builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonInvalidScripts,
+			utilstatus.ReasonMessageInvalidScripts,
+		)
+		return err
+	}
+
 	// see if there is a .s2iignore file, and if so, read in the patterns an then
 	// search and delete on
-	return builder.ignorer.Ignore(config)
+	if err = builder.ignorer.Ignore(config); err != nil {
+		builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonFSOperationFailed,
+			utilstatus.ReasonMessageFSOperationFailed,
+		)
+		return err
+	}
+
+	if err = render.Sources(builder.fs, config, builder.sourceInfo); err != nil {
+		builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonRenderTemplatesFailed,
+			utilstatus.ReasonMessageRenderTemplatesFailed,
+		)
+		return err
+	}
+
+	return builder.lockInputs(config)
+}
+
+// probeBuilderImage checks that the builder image meets the requirements of
+// the build, so that it fails, or falls back to a layered build, before the
+// sources are fetched.
+func (builder *STI) probeBuilderImage(config *api.Config) error {
+	if config.LayeredFallback == api.LayeredFallbackAlways {
+		return nil
+	}
+	startTime := time.Now()
+	probe, err := builder.docker.ProbeImage(config.BuilderImage, config.AssembleUser, config.Destination)
+	builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StagePullImages, api.StepProbeBuilderImage, startTime, time.Now())
+	if err != nil {
+		log.V(1).Infof("Unable to probe image %q for the requirements of the build: %v", config.BuilderImage, err)
+		return nil
+	}
+	if probe == nil {
+		return nil
+	}
+	builder.destination = probe.Destination
+	builder.noBash = !probe.HasBash
+	if len(probe.MissingRequirements) > 0 {
+		if config.LayeredFallback == api.LayeredFallbackNever {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonBuilderImageMissingRequirements,
+				utilstatus.ReasonMessageBuilderImageMissingRequirements,
+			)
+			return fmt.Errorf("image %q is missing basic requirements (%s) and the fallback to a layered build is disabled", config.BuilderImage, strings.Join(probe.MissingRequirements, ", "))
+		}
+		log.Warningf("Image %q is missing basic requirements (%s), a layered build will be performed instead", config.BuilderImage, strings.Join(probe.MissingRequirements, ", "))
+		builder.missingRequirements = true
+		return nil
+	}
+	if !probe.DestinationWritable {
+		builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonDestinationNotWritable,
+			utilstatus.ReasonMessageDestinationNotWritable,
+		)
+		return builder.destinationNotWritableError(config)
+	}
+	if !probe.HasAssemble && len(config.ScriptsURL) == 0 {
+		log.V(1).Infof("Image %q does not provide an assemble script at %q, the sources must provide one in .s2i/bin", config.BuilderImage, probe.ScriptsURL)
+	}
+	return nil
+}
+
+// lintScripts checks the scripts downloaded from a URL or copied from the
+// sources for the problems which would otherwise make them fail deep inside
+// the container with cryptic errors, such as the CRLF line endings of the
+// Windows checkouts. The errors fail the build, the warnings are logged.
+func (builder *STI) lintScripts(config *api.Config) error {
+	names := []string{}
+	for script, external := range builder.externalScripts {
+		if external {
+			names = append(names, script)
+		}
+	}
+	sort.Strings(names)
+	failed := []string{}
+	for _, script := range names {
+		r, err := builder.fs.Open(filepath.Join(config.WorkingDir, constants.UploadScripts, script))
+		if err != nil {
+			log.V(2).Infof("Unable to read the %s script to check it: %v", script, err)
+			continue
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			log.V(2).Infof("Unable to read the %s script to check it: %v", script, err)
+			continue
+		}
+		if config.ConvertCRLF == api.CRLFConversionScripts || config.ConvertCRLF == api.CRLFConversionAll {
+			// the line endings are converted when the scripts are uploaded
+			content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		}
+		// only the builder image is probed for bash, the scripts running in
+		// the runtime image are assumed to have it
+		opts := scripts.LintOptions{NoBash: builder.noBash}
+		if len(config.RuntimeImage) > 0 && (script == constants.AssembleRuntime || script == constants.Run) {
+			opts.NoBash = false
+		}
+		for _, problem := range scripts.LintScript(script, content, opts) {
+			if problem.Severity == scripts.SeverityError {
+				failed = append(failed, problem.String())
+				continue
+			}
+			log.Warningf("%s (from %s)", problem, builder.scriptsURL[script])
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("the scripts would fail in image %q:\n%s", config.BuilderImage, strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// checkSourceDiskSpace checks that the file system holding the working
+// directory has room for the sources: the size of local sources, which are
+// copied, is known beforehand, while the size of remote ones is not.
+func (builder *STI) checkSourceDiskSpace(config *api.Config) error {
+	if config.SkipDiskCheck {
+		return nil
+	}
+	var size, files uint64
+	local := config.ArtifactPath
+	if len(local) == 0 && config.Source != nil && config.Source.IsLocal() {
+		local = config.Source.LocalPath()
+	}
+	if len(local) > 0 {
+		var err error
+		if size, files, err = fs.DirectorySize(builder.fs, local); err != nil {
+			log.V(1).Infof("Unable to determine the size of the sources in %q: %v", local, err)
+		}
+	}
+	return fs.CheckDiskSpace(config.WorkingDir, size, files)
+}
+
+// destinationNotWritableError returns the error of a destination directory
+// the assemble user cannot upload the scripts and sources to, suggesting how
+// to set a writable one.
+func (builder *STI) destinationNotWritableError(config *api.Config) error {
+	destination := util.FirstNonEmpty(config.Destination, builder.destination)
+	if len(config.Destination) > 0 {
+		return fmt.Errorf("the destination directory %q given by --destination is not writable by the assemble user of image %q", destination, config.BuilderImage)
+	}
+	if len(destination) == 0 {
+		return fmt.Errorf("the destination directory of image %q is not writable by the assemble user, use --destination to set a writable directory", config.BuilderImage)
+	}
+	return fmt.Errorf("the destination directory %q of image %q, set by its %s label or defaulting to %s, is not writable by the assemble user, use --destination to set a writable directory", destination, config.BuilderImage, constants.DestinationLabel, dockerpkg.DefaultDestination)
+}
+
+// lockInputs writes the inputs resolved by the build to the lockfile, or
+// verifies them against it for locked builds.
+func (builder *STI) lockInputs(config *api.Config) error {
+	if len(config.LockFile) == 0 && !config.Locked {
+		return nil
+	}
+	resolved, err := builder.resolveInputs(config)
+	if err != nil {
+		builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonGenericS2IBuildFailed,
+			utilstatus.ReasonMessageGenericS2iBuildFailed,
+		)
+		return fmt.Errorf("unable to resolve the inputs of the build: %v", err)
+	}
+	path := lock.File(config)
+	if config.Locked {
+		log.V(1).Infof("Verifying the resolved inputs against %s", path)
+		if err := lock.Verify(path, resolved); err != nil {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonLockfileMismatch,
+				utilstatus.ReasonMessageLockfileMismatch,
+			)
+			return err
+		}
+		return nil
+	}
+	log.V(1).Infof("Writing the resolved inputs to %s", path)
+	if err := lock.Write(path, resolved); err != nil {
+		builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonFSOperationFailed,
+			utilstatus.ReasonMessageFSOperationFailed,
+		)
+		return err
+	}
+	return nil
+}
+
+// resolveInputs returns the inputs resolved by the build: the IDs of the
+// builder and runtime images, the commit of the source, the scripts and the
+// environment.
+func (builder *STI) resolveInputs(config *api.Config) (*lock.Lock, error) {
+	resolved := &lock.Lock{
+		Version:      lock.Version,
+		BuilderImage: lock.Image{Name: config.BuilderImage},
+		Environment:  lock.Environment(config.Environment),
+	}
+	var err error
+	if resolved.BuilderImage.ID, err = builder.docker.GetImageID(config.BuilderImage); err != nil {
+		return nil, err
+	}
+	if len(config.RuntimeImage) > 0 {
+		resolved.RuntimeImage = &lock.Image{Name: config.RuntimeImage}
+		if resolved.RuntimeImage.ID, err = builder.runtimeDocker.GetImageID(config.RuntimeImage); err != nil {
+			return nil, err
+		}
+	}
+	if config.Source != nil {
+		resolved.Source = &lock.Source{
+			URL: config.Source.StringNoFragment(),
+			Ref: config.Source.URL.Fragment,
+		}
+		if builder.sourceInfo != nil {
+			resolved.Source.Commit = builder.sourceInfo.CommitID
+		}
+	}
+	names := make([]string, 0, len(builder.scriptsURL))
+	for name := range builder.scriptsURL {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		script := lock.Script{Name: name}
+		if builder.externalScripts[name] {
+			if script.SHA256, err = builder.scriptChecksum(filepath.Join(config.WorkingDir, constants.UploadScripts, name)); err != nil {
+				return nil, err
+			}
+		} else {
+			script.URL = builder.scriptsURL[name]
+		}
+		resolved.Scripts = append(resolved.Scripts, script)
+	}
+	return resolved, nil
+}
+
+// scriptChecksum returns the checksum of the given script.
+func (builder *STI) scriptChecksum(path string) (string, error) {
+	r, err := builder.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return lock.Checksum(r)
 }
 
 // SetScripts allows to override default required and optional scripts
@@ -460,14 +869,36 @@ func (builder *STI) PostExecute(containerID, destination string) error {
 }
 
 // CreateBuildEnvironment constructs the environment variables to be provided to the assemble
-// script and committed in the new image.
-func CreateBuildEnvironment(sourcePath string, cfgEnv api.EnvironmentList) []string {
+// script and committed in the new image. The variables describing the sources
+// come first, so that the environment of the sources and of the config can
+// override them.
+func CreateBuildEnvironment(sourcePath string, info *git.SourceInfo, cfgEnv api.EnvironmentList) []string {
 	s2iEnv, err := scripts.GetEnvironment(filepath.Join(sourcePath, constants.Source))
 	if err != nil {
 		log.V(3).Infof("No user environment provided (%v)", err)
 	}
 
-	return append(scripts.ConvertEnvironmentList(s2iEnv), scripts.ConvertEnvironmentList(cfgEnv)...)
+	env := scripts.ConvertEnvironmentList(scripts.SourceInfoEnvironment(info))
+	env = append(env, scripts.ConvertEnvironmentList(s2iEnv)...)
+	return append(env, scripts.ConvertEnvironmentList(cfgEnv)...)
+}
+
+// CreateRuntimeEnvironment constructs the environment variables to be provided
+// to the assemble-runtime script and committed in the runtime image: the ones
+// describing the sources, of the .s2i/environment.runtime file and of the
+// config. Without a runtime environment, the build environment is used.
+func CreateRuntimeEnvironment(sourcePath string, info *git.SourceInfo, cfgEnv api.EnvironmentList, buildEnv []string) []string {
+	s2iEnv, err := scripts.GetRuntimeEnvironment(filepath.Join(sourcePath, constants.Source))
+	if err != nil {
+		log.V(3).Infof("No user runtime environment provided (%v)", err)
+		if len(cfgEnv) == 0 {
+			return buildEnv
+		}
+	}
+
+	env := scripts.ConvertEnvironmentList(scripts.SourceInfoEnvironment(info))
+	env = append(env, scripts.ConvertEnvironmentList(s2iEnv)...)
+	return append(env, scripts.ConvertEnvironmentList(cfgEnv)...)
 }
 
 // Exists determines if the current build supports incremental workflow.
@@ -485,6 +916,7 @@ func (builder *STI) Exists(config *api.Config) bool {
 
 	tag := util.FirstNonEmpty(config.IncrementalFromTag, config.Tag)
 
+	progress.Step(api.StepPullPreviousImage)
 	startTime := time.Now()
 	result, err := dockerpkg.PullImage(tag, builder.incrementalDocker, policy)
 	builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StagePullImages, api.StepPullPreviousImage, startTime, time.Now())
@@ -501,6 +933,24 @@ func (builder *STI) Exists(config *api.Config) bool {
 	return result.Image != nil && builder.installedScripts[constants.SaveArtifacts]
 }
 
+// restoreArtifacts extracts the build artifacts of the archive written by s2i
+// save-artifacts to the directory uploaded with the sources, in place of the
+// artifacts of the previous image.
+func (builder *STI) restoreArtifacts(config *api.Config) error {
+	artifactTmpDir := filepath.Join(config.WorkingDir, "upload", "artifacts")
+	if err := builder.fs.Mkdir(artifactTmpDir); err != nil {
+		return err
+	}
+	progress.Step(api.StepRetrievePreviousArtifacts)
+	startTime := time.Now()
+	err := artifacts.Restore(builder.tar, config.RestoreArtifacts, artifactTmpDir)
+	builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StageRetrieve, api.StepRetrievePreviousArtifacts, startTime, time.Now())
+	if err != nil {
+		builder.fs.RemoveDirectory(artifactTmpDir)
+	}
+	return err
+}
+
 // Save extracts and restores the build artifacts from the previous build to
 // the current build.
 func (builder *STI) Save(config *api.Config) (err error) {
@@ -519,12 +969,32 @@ func (builder *STI) Save(config *api.Config) (err error) {
 
 	image := util.FirstNonEmpty(config.IncrementalFromTag, config.Tag)
 
+	compression := api.CompressionNone
+	if config.SaveArtifactsCompression != "" && config.SaveArtifactsCompression != api.CompressionNone {
+		labels, err := builder.docker.GetLabels(image)
+		if err != nil {
+			log.V(1).Infof("Unable to read the labels of image %s, saving the build artifacts uncompressed: %v", image, err)
+		}
+		compression = negotiateSaveArtifactsCompression(config.SaveArtifactsCompression, labels[constants.SaveArtifactsCompressionLabel])
+		log.V(2).Infof("Using %s compression for the build artifacts", compression)
+	}
+
 	outReader, outWriter := io.Pipe()
 	errReader, errWriter := io.Pipe()
 	log.V(1).Infof("Saving build artifacts from image %s to path %s", image, artifactTmpDir)
 	extractFunc := func(string) error {
+		progress.Step(api.StepRetrievePreviousArtifacts)
 		startTime := time.Now()
-		extractErr := builder.tar.ExtractTarStream(artifactTmpDir, outReader)
+		var extractErr error
+		if compression == api.CompressionNone {
+			extractErr = builder.tar.ExtractTarStream(artifactTmpDir, outReader)
+		} else {
+			var r io.ReadCloser
+			if r, extractErr = tar.NewDecompressedReader(outReader); extractErr == nil {
+				extractErr = builder.tar.ExtractTarStream(artifactTmpDir, r)
+				r.Close()
+			}
+		}
 		io.Copy(ioutil.Discard, outReader) // must ensure reader from container is drained
 		builder.result.BuildInfo.Stages = api.RecordStageAndStepInfo(builder.result.BuildInfo.Stages, api.StageRetrieve, api.StepRetrievePreviousArtifacts, startTime, time.Now())
 
@@ -564,12 +1034,17 @@ func (builder *STI) Save(config *api.Config) (err error) {
 		NetworkMode:     string(config.DockerNetworkMode),
 		CGroupLimits:    config.CGroupLimits,
 		CapDrop:         config.DropCapabilities,
-		Binds:           config.BuildVolumes,
+		Binds:           builder.volumeBinds,
 		SecurityOpt:     config.SecurityOpt,
 		AddHost:         config.AddHost,
+		StopTimeout:     config.ShutdownGracePeriod,
+		Deadline:        config.BuildDeadline,
+	}
+	if compression != api.CompressionNone {
+		opts.Env = append(opts.Env, constants.SaveArtifactsCompressionEnvironment+"="+string(compression))
 	}
 
-	dockerpkg.StreamContainerIO(errReader, nil, func(s string) { log.Info(s) })
+	dockerpkg.StreamContainerIO(errReader, nil, func(s string) { log.Stream(utillog.StreamStderr, s) })
 	err = builder.docker.RunContainer(opts)
 	if e, ok := err.(s2ierr.ContainerError); ok {
 		err = s2ierr.NewSaveArtifactsError(image, e.Output, err)
@@ -595,7 +1070,8 @@ func (builder *STI) Execute(command string, user string, config *api.Config) err
 
 	// we can't invoke this method before (for example in New() method)
 	// because of later initialization of config.WorkingDir
-	builder.env = CreateBuildEnvironment(config.WorkingDir, config.Environment)
+	builder.env = CreateBuildEnvironment(config.WorkingDir, builder.sourceInfo, config.Environment)
+	builder.runtimeEnv = CreateRuntimeEnvironment(config.WorkingDir, builder.sourceInfo, config.RuntimeEnvironment, builder.env)
 
 	errOutput := ""
 	outReader, outWriter := io.Pipe()
@@ -612,20 +1088,64 @@ func (builder *STI) Execute(command string, user string, config *api.Config) err
 		Stderr: errWriter,
 		// The PullImage is false because the PullImage function should be called
 		// before we run the container
-		PullImage:       false,
-		ExternalScripts: externalScripts,
-		ScriptsURL:      config.ScriptsURL,
-		Destination:     config.Destination,
-		Command:         command,
-		Env:             builder.env,
-		User:            user,
-		PostExec:        builder.postExecutor,
-		NetworkMode:     string(config.DockerNetworkMode),
-		CGroupLimits:    config.CGroupLimits,
-		CapDrop:         config.DropCapabilities,
-		Binds:           config.BuildVolumes,
-		SecurityOpt:     config.SecurityOpt,
-		AddHost:         config.AddHost,
+		PullImage:         false,
+		ExternalScripts:   externalScripts,
+		ScriptsURL:        config.ScriptsURL,
+		Destination:       config.Destination,
+		Command:           command,
+		Env:               builder.env,
+		User:              user,
+		PostExec:          builder.postExecutor,
+		NetworkMode:       string(config.DockerNetworkMode),
+		CGroupLimits:      config.CGroupLimits,
+		CapDrop:           config.DropCapabilities,
+		Binds:             builder.volumeBinds,
+		SecurityOpt:       config.SecurityOpt,
+		AddHost:           config.AddHost,
+		StopTimeout:       config.ShutdownGracePeriod,
+		Deadline:          config.BuildDeadline,
+		RedactEnvPatterns: config.RedactEnvPatterns,
+	}
+
+	// Hermetic builds audit the addresses reached by the assemble scripts, and
+	// refuse to commit the containers that tried to reach outside.
+	var auditor *hermetic.Auditor
+	if config.Hermetic && (command == constants.Assemble || command == constants.AssembleRuntime) {
+		var err error
+		if auditor, err = startAuditor(config); err != nil {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonGenericS2IBuildFailed,
+				utilstatus.ReasonMessageGenericS2iBuildFailed,
+			)
+			return err
+		}
+		defer auditor.Close()
+		profile, err := auditor.Profile()
+		if err != nil {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonGenericS2IBuildFailed,
+				utilstatus.ReasonMessageGenericS2iBuildFailed,
+			)
+			return err
+		}
+		opts.SecurityOpt = append(append([]string{}, opts.SecurityOpt...), "seccomp="+profile)
+		opts.PostExec = hermeticPostExecutor{auditor: auditor, postExecutor: opts.PostExec}
+	}
+
+	// The caches are only mounted while the assemble script installs the
+	// dependencies.
+	if len(builder.cacheBinds) > 0 && command == constants.Assemble {
+		opts.Binds = append(append([]string{}, opts.Binds...), builder.cacheBinds...)
+	}
+
+	// The test reports are extracted once the assemble script exits, even if it
+	// failed.
+	if len(config.ReportsDir) > 0 && command == constants.Assemble {
+		if reportsDir := builder.reportsDir(config); len(reportsDir) > 0 {
+			opts.OnExit = func(containerID string) {
+				builder.extractReports(config, reportsDir, containerID)
+			}
+		}
 	}
 
 	// If there are injections specified, override the original assemble script
@@ -642,7 +1162,7 @@ func (builder *STI) Execute(command string, user string, config *api.Config) err
 			return err
 		}
 		config.Injections = util.FixInjectionsWithRelativePath(workdir, config.Injections)
-		truncatedFiles, err := util.ListFilesToTruncate(builder.fs, config.Injections)
+		truncatedFiles, err := util.ListFilesToTruncate(builder.fs, config.Injections, config.KeepInjections)
 		if err != nil {
 			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
 				utilstatus.ReasonInstallScriptsFailed,
@@ -691,6 +1211,24 @@ func (builder *STI) Execute(command string, user string, config *api.Config) err
 		close(injectionError)
 	}
 
+	// the glob patterns of the runtime artifacts are expanded by the shell of
+	// the builder container once the assemble script succeeded, unless it is
+	// run by a layered build, whose command is not run by the shell
+	if command == constants.Assemble {
+		builder.globsExpanded = false
+		if expansion := runtimeArtifactsExpansion(config.RuntimeArtifacts); len(expansion) > 0 && !config.LayeredBuild && len(config.RuntimeImage) > 0 {
+			builder.globsExpanded = true
+			override := opts.CommandOverrides
+			opts.CommandOverrides = func(cmd string) string {
+				cmd = fmt.Sprintf("%s && %s", cmd, expansion)
+				if override != nil {
+					return override(cmd)
+				}
+				return cmd
+			}
+		}
+	}
+
 	if !config.LayeredBuild {
 		r, w := io.Pipe()
 		opts.Stdin = r
@@ -710,13 +1248,22 @@ func (builder *STI) Execute(command string, user string, config *api.Config) err
 
 	dockerpkg.StreamContainerIO(outReader, nil, func(s string) {
 		if !config.Quiet {
-			log.Info(strings.TrimSpace(s))
+			log.Stream(utillog.StreamStdout, strings.TrimSpace(s))
 		}
 	})
 
-	c := dockerpkg.StreamContainerIO(errReader, &errOutput, func(s string) { log.Info(s) })
+	c := dockerpkg.StreamContainerIO(errReader, &errOutput, func(s string) { log.Stream(utillog.StreamStderr, s) })
 
 	err := builder.docker.RunContainer(opts)
+	if auditor != nil {
+		if hermeticErr := auditor.Err(); hermeticErr != nil {
+			builder.result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonHermeticViolation,
+				utilstatus.ReasonMessageHermeticViolation,
+			)
+			return hermeticErr
+		}
+	}
 	if err != nil {
 		// Must wait for StreamContainerIO goroutine above to exit before reading errOutput.
 		<-c
@@ -727,25 +1274,142 @@ func (builder *STI) Execute(command string, user string, config *api.Config) err
 			err = s2ierr.NewContainerError(config.BuilderImage, e.ErrorCode, errOutput+e.Output)
 		}
 	}
+	if err == nil && command == constants.Assemble && len(builder.config.CallbackURLs) > 0 {
+		util.FireCallbacks(builder.callbackInvoker, builder.config, api.CallbackEventAssembled, builder.result, builder.postExecutorStepsContext.labels)
+	}
 
 	return err
 }
 
+// startAuditor starts the auditor of a hermetic build in its working
+// directory, which the container runtime connects to: the container engine
+// must run on this host.
+func startAuditor(config *api.Config) (*hermetic.Auditor, error) {
+	if config.DockerConfig != nil && !hermetic.LocalEndpoint(config.DockerConfig.Endpoint) {
+		return nil, fmt.Errorf("hermetic builds require a container engine running on this host, reached through a unix socket, to audit the network access: %q is not local", config.DockerConfig.Endpoint)
+	}
+	return hermetic.Start(config.WorkingDir)
+}
+
+// hermeticPostExecutor runs the post-build actions of the containers which did
+// not attempt outbound network access during a hermetic build.
+type hermeticPostExecutor struct {
+	auditor      *hermetic.Auditor
+	postExecutor dockerpkg.PostExecutor
+}
+
+// PostExecute fails when the container runtime did not let the auditor
+// inspect the network access of the container, or when the container
+// attempted outbound network access, before its image is committed.
+func (e hermeticPostExecutor) PostExecute(containerID, destination string) error {
+	if err := e.auditor.Verify(); err != nil {
+		return err
+	}
+	if err := e.auditor.Err(); err != nil {
+		return err
+	}
+	if e.postExecutor == nil {
+		return nil
+	}
+	return e.postExecutor.PostExecute(containerID, destination)
+}
+
 // uploadInjections uploads the injected volumes to the s2i container, along with the source
-// removal script to truncate volumes that should not be kept.
+// removal script to truncate volumes that should not be kept. The volumes are
+// uploaded concurrently, up to the injection parallelism of the configuration,
+// and the errors of all the failed uploads are reported. The volumes whose
+// destinations overlap are uploaded one after the other, in the order they are
+// declared, so that the later ones overwrite the files of the earlier ones.
 func (builder *STI) uploadInjections(config *api.Config, rmScript, containerID string) error {
 	log.V(2).Info("starting the injections uploading ...")
-	for _, s := range config.Injections {
-		if err := builder.docker.UploadToContainer(builder.fs, s.Source, s.Destination, containerID); err != nil {
-			return util.HandleInjectionError(s, err)
+	parallelism := config.InjectionParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	errs := make([]error, len(config.Injections))
+	slots := make(chan struct{}, parallelism)
+	wg := sync.WaitGroup{}
+	for _, group := range injectionGroups(config.Injections) {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(group []int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			for _, i := range group {
+				s := config.Injections[i]
+				errs[i] = util.HandleInjectionError(s, builder.docker.UploadToContainer(builder.fs, s.Source, s.Destination, containerID))
+			}
+		}(group)
+	}
+	wg.Wait()
+	var failed []error
+	var failures []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", config.Injections[i].Source, err))
 		}
 	}
+	if len(failed) == 1 {
+		return failed[0]
+	}
+	if len(failed) > 1 {
+		return fmt.Errorf("%d injections failed to upload: %s", len(failed), strings.Join(failures, "; "))
+	}
 	if err := builder.docker.UploadToContainer(builder.fs, rmScript, rmInjectionsScript, containerID); err != nil {
 		return util.HandleInjectionError(api.VolumeSpec{Source: rmScript, Destination: rmInjectionsScript}, err)
 	}
 	return nil
 }
 
+// injectionGroups groups the indexes of the injections whose destinations
+// overlap, directly or through other injections, each group listing them in
+// the order they are declared. The groups are ordered by their first
+// injection.
+func injectionGroups(injections api.VolumeList) [][]int {
+	group := make([]int, len(injections))
+	for i := range injections {
+		group[i] = i
+		for j := 0; j < i; j++ {
+			if group[j] != group[i] && destinationsOverlap(injections[i].Destination, injections[j].Destination) {
+				// merge the group of i into the earlier group of j
+				from, to := group[i], group[j]
+				if from < to {
+					from, to = to, from
+				}
+				for k := 0; k <= i; k++ {
+					if group[k] == from {
+						group[k] = to
+					}
+				}
+			}
+		}
+	}
+	var groups [][]int
+	index := map[int]int{}
+	for i, g := range group {
+		n, ok := index[g]
+		if !ok {
+			n = len(groups)
+			index[g] = n
+			groups = append(groups, nil)
+		}
+		groups[n] = append(groups[n], i)
+	}
+	return groups
+}
+
+// destinationsOverlap returns true when one of the destinations is the other
+// or is within it. The relative destinations are within the working directory
+// of the image, which is unknown here, so they may overlap any absolute one.
+func destinationsOverlap(a, b string) bool {
+	if path.IsAbs(a) != path.IsAbs(b) {
+		return true
+	}
+	a, b = path.Clean(a), path.Clean(b)
+	return a == b || a == "." || b == "." || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/") || a == "/" || b == "/"
+}
+
 func (builder *STI) initPostExecutorSteps() {
 	builder.postExecutorStepsContext = &postExecutorStepContext{}
 	if len(builder.config.RuntimeImage) == 0 {
@@ -761,6 +1425,26 @@ func (builder *STI) initPostExecutorSteps() {
 				fs:      builder.fs,
 				tar:     builder.tar,
 			},
+			&scanImageStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&verifyRunScriptStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&postCommitTestStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&tagImageStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&diffReportStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
 			&reportSuccessStep{
 				builder: builder,
 			},
@@ -769,8 +1453,25 @@ func (builder *STI) initPostExecutorSteps() {
 				docker:  builder.docker,
 			},
 		}
+	} else if builder.config.DryRun {
+		builder.postExecutorFirstStageSteps = []postExecutorStep{
+			&downloadFilesFromBuilderImageStep{
+				builder: builder,
+				docker:  builder.docker,
+				fs:      builder.fs,
+				tar:     builder.tar,
+			},
+			&previewRuntimeArtifactsStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+		}
 	} else {
 		builder.postExecutorFirstStageSteps = []postExecutorStep{
+			&storePreviousImageStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
 			&downloadFilesFromBuilderImageStep{
 				builder: builder,
 				docker:  builder.docker,
@@ -786,10 +1487,31 @@ func (builder *STI) initPostExecutorSteps() {
 		builder.postExecutorSecondStageSteps = []postExecutorStep{
 			&commitImageStep{
 				image:   builder.config.RuntimeImage,
+				runtime: true,
 				builder: builder,
 				docker:  builder.docker,
 				tar:     builder.tar,
 			},
+			&scanImageStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&verifyRunScriptStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&postCommitTestStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&tagImageStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
+			&diffReportStep{
+				builder: builder,
+				docker:  builder.docker,
+			},
 			&reportSuccessStep{
 				builder: builder,
 			},
@@ -820,3 +1542,38 @@ func isMissingRequirements(text string) bool {
 	shCommand, _ := regexp.MatchString(`.*/bin/sh.*no such file or directory`, text)
 	return tarCommand || shCommand
 }
+
+// isDestinationNotWritable returns true when the output of tar shows the
+// scripts and sources could not be extracted to the destination directory.
+func isDestinationNotWritable(text string) bool {
+	permission, _ := regexp.MatchString(`tar: .*(Cannot (open|mkdir|chdir)|can't (open|create|change)).*: Permission denied`, text)
+	missing, _ := regexp.MatchString(`tar: (.*: Cannot (open|chdir)|can't change directory to .*): No such file or directory`, text)
+	return permission || missing
+}
+
+// negotiateSaveArtifactsCompression returns the compression to request from
+// the save-artifacts script given the requested one and the comma-separated
+// compressions the image supports, as listed by its
+// io.openshift.s2i.save-artifacts-compression label. Auto picks zstd, then
+// gzip, and the build artifacts are saved uncompressed when the image does not
+// support the requested compression.
+func negotiateSaveArtifactsCompression(requested api.Compression, supported string) api.Compression {
+	supports := map[api.Compression]bool{}
+	for _, c := range strings.Split(supported, ",") {
+		supports[api.Compression(strings.ToLower(strings.TrimSpace(c)))] = true
+	}
+	switch requested {
+	case api.CompressionGzip, api.CompressionZstd:
+		if supports[requested] {
+			return requested
+		}
+		log.V(1).Infof("The builder image does not support %s compressed build artifacts, saving them uncompressed", requested)
+	case api.CompressionAuto:
+		for _, c := range []api.Compression{api.CompressionZstd, api.CompressionGzip} {
+			if supports[c] {
+				return c
+			}
+		}
+	}
+	return api.CompressionNone
+}
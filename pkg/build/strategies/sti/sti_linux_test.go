@@ -0,0 +1,74 @@
+package sti
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/docker"
+	"github.com/openshift/source-to-image/pkg/hermetic"
+)
+
+// sendSeccompListener sends a file descriptor to the hermetic listener at
+// path, as the container runtime sends the seccomp notifications.
+func sendSeccompListener(t *testing.T, path string) {
+	var conn *net.UnixConn
+	for i := 0; i < 100; i++ {
+		var err error
+		if conn, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"}); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if conn == nil {
+		t.Errorf("Unable to connect to the hermetic listener %s", path)
+		return
+	}
+	defer conn.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer r.Close()
+	defer w.Close()
+	if _, _, err := conn.WriteMsgUnix([]byte("{}"), unix.UnixRights(int(r.Fd())), nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExecuteHermetic(t *testing.T) {
+	rh := newFakeBaseSTI()
+	pe := &FakeSTI{}
+	rh.postExecutor = pe
+	rh.config.WorkingDir = t.TempDir()
+	rh.config.BuilderImage = "test/image"
+	rh.config.Hermetic = true
+	rh.config.SecurityOpt = []string{"no-new-privileges"}
+	fd := rh.docker.(*docker.FakeDocker)
+	fd.RunContainerContainerID = "1234"
+	go sendSeccompListener(t, filepath.Join(rh.config.WorkingDir, hermetic.ListenerName))
+
+	if err := rh.Execute(constants.Assemble, "", rh.config); err != nil {
+		t.Fatalf("Unexpected error returned: %v", err)
+	}
+	opts := fd.RunContainerOpts.SecurityOpt
+	if len(opts) != 2 || opts[0] != "no-new-privileges" || !strings.HasPrefix(opts[1], "seccomp=") {
+		t.Fatalf("Unexpected security options: %v", opts)
+	}
+	if !strings.Contains(opts[1], filepath.Join(rh.config.WorkingDir, hermetic.ListenerName)) {
+		t.Errorf("Expected the seccomp profile to notify the hermetic listener, got %s", opts[1])
+	}
+	if len(rh.config.SecurityOpt) != 1 {
+		t.Errorf("Unexpected change of the configured security options: %v", rh.config.SecurityOpt)
+	}
+	if pe.PostExecuteContainerID != "1234" {
+		t.Errorf("PostExecutor not called with expected ID: %s", pe.PostExecuteContainerID)
+	}
+}
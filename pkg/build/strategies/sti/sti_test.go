@@ -1,14 +1,20 @@
 package sti
 
 import (
+	"archive/tar"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
 	"regexp/syntax"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
@@ -16,6 +22,7 @@ import (
 	"github.com/openshift/source-to-image/pkg/docker"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/lock"
 	"github.com/openshift/source-to-image/pkg/scm/downloaders/empty"
 	"github.com/openshift/source-to-image/pkg/scm/downloaders/file"
 	gitdownloader "github.com/openshift/source-to-image/pkg/scm/downloaders/git"
@@ -23,6 +30,7 @@ import (
 	"github.com/openshift/source-to-image/pkg/test"
 	testfs "github.com/openshift/source-to-image/pkg/test/fs"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
 type FakeSTI struct {
@@ -291,6 +299,54 @@ func TestLayeredBuild(t *testing.T) {
 	}
 }
 
+func TestLayeredFallback(t *testing.T) {
+	tests := []struct {
+		mode          api.LayeredFallbackMode
+		executeError  error
+		expectLayered bool
+		expectError   bool
+	}{
+		{mode: api.LayeredFallbackAuto, executeError: errMissingRequirements, expectLayered: true},
+		{mode: api.LayeredFallbackAuto, executeError: s2ierr.NewContainerError("testimage", 127, "/bin/sh: tar: not found"), expectLayered: true},
+		{mode: api.LayeredFallbackNever, executeError: errMissingRequirements, expectError: true},
+		{mode: api.LayeredFallbackAlways, expectLayered: true},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			fh := &FakeSTI{
+				BuildRequest: &api.Config{BuilderImage: "testimage"},
+				BuildResult:  &api.Result{},
+				ExecuteError: tc.executeError,
+			}
+			builder := newFakeSTI(fh)
+			result, err := builder.Build(&api.Config{BuilderImage: "testimage", LayeredFallback: tc.mode})
+			if fh.LayeredBuildCalled != tc.expectLayered {
+				t.Errorf("Expected layered build to be called: %t, got %t", tc.expectLayered, fh.LayeredBuildCalled)
+			}
+			if (err != nil) != tc.expectError {
+				t.Fatalf("Expected error: %t, got %v", tc.expectError, err)
+			}
+			if tc.expectError && result.BuildInfo.FailureReason.Reason != utilstatus.ReasonBuilderImageMissingRequirements {
+				t.Errorf("Expected failure reason %s, got %s", utilstatus.ReasonBuilderImageMissingRequirements, result.BuildInfo.FailureReason.Reason)
+			}
+			if tc.executeError != nil && !hasStep(result.BuildInfo.Stages, api.StepLayeredFallback) {
+				t.Errorf("Expected the %s step to be recorded, got %+v", api.StepLayeredFallback, result.BuildInfo.Stages)
+			}
+		})
+	}
+}
+
+func hasStep(stages []api.StageInfo, name api.StepName) bool {
+	for _, stage := range stages {
+		for _, step := range stage.Steps {
+			if step.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func TestBuildErrorExecute(t *testing.T) {
 	fh := &FakeSTI{
 		BuildRequest: &api.Config{
@@ -340,6 +396,99 @@ func TestWasExpectedError(t *testing.T) {
 	}
 }
 
+func TestIsDestinationNotWritable(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected bool
+	}{
+		{text: "tar: scripts: Cannot mkdir: Permission denied", expected: true},
+		{text: "tar: src/app.py: Cannot open: Permission denied", expected: true},
+		{text: "tar: /opt/app: Cannot open: No such file or directory", expected: true},
+		{text: "tar: can't create directory 'scripts': Permission denied", expected: true},
+		{text: "tar: can't change directory to '/opt/app': No such file or directory", expected: true},
+		{text: "cp: cannot create regular file '/etc/app.conf': Permission denied", expected: false},
+		{text: "/bin/sh: tar: not found", expected: false},
+	}
+	for _, tc := range tests {
+		if result := isDestinationNotWritable(tc.text); result != tc.expected {
+			t.Errorf("%q: expected %t, got %t", tc.text, tc.expected, result)
+		}
+	}
+}
+
+func TestBuildDestinationNotWritable(t *testing.T) {
+	fh := &FakeSTI{
+		BuildRequest: &api.Config{BuilderImage: "testimage"},
+		BuildResult:  &api.Result{},
+		ExecuteError: s2ierr.NewContainerError("testimage", 2, "tar: scripts: Cannot mkdir: Permission denied"),
+	}
+	builder := newFakeSTI(fh)
+	builder.destination = "/opt/app"
+	result, err := builder.Build(&api.Config{BuilderImage: "testimage"})
+	if err == nil || !strings.Contains(err.Error(), "--destination") || !strings.Contains(err.Error(), "/opt/app") {
+		t.Errorf("Expected an error suggesting --destination, got %v", err)
+	}
+	if result.BuildInfo.FailureReason.Reason != utilstatus.ReasonDestinationNotWritable {
+		t.Errorf("Expected failure reason %s, got %s", utilstatus.ReasonDestinationNotWritable, result.BuildInfo.FailureReason.Reason)
+	}
+}
+
+func TestBuildRestoreArtifactsFailed(t *testing.T) {
+	fh := &FakeSTI{
+		BuildRequest: &api.Config{BuilderImage: "testimage"},
+		BuildResult:  &api.Result{},
+	}
+	builder := newFakeSTI(fh)
+	config := &api.Config{
+		BuilderImage:     "testimage",
+		WorkingDir:       "/working-dir",
+		RestoreArtifacts: filepath.Join(t.TempDir(), "missing.tar"),
+	}
+	result, err := builder.Build(config)
+	if err == nil || !strings.Contains(err.Error(), "missing.tar") {
+		t.Errorf("Expected an error restoring the missing archive, got %v", err)
+	}
+	if result.BuildInfo.FailureReason.Reason != utilstatus.ReasonRestoreArtifactsFailed {
+		t.Errorf("Expected failure reason %s, got %s", utilstatus.ReasonRestoreArtifactsFailed, result.BuildInfo.FailureReason.Reason)
+	}
+	if fh.ExecuteCommand != "" {
+		t.Errorf("Expected the assemble script not to run, got %q", fh.ExecuteCommand)
+	}
+}
+
+func TestBuildCallbacks(t *testing.T) {
+	builder := newFakeSTI(&FakeSTI{BuildRequest: &api.Config{}, BuildResult: &api.Result{}})
+	invoker := &test.FakeCallbackInvoker{Status: &api.CallbackStatus{Delivered: true, Attempts: 1}}
+	builder.callbackInvoker = invoker
+	builder.initPostExecutorSteps()
+	builder.config.CallbackURLs = []string{"https://ci.example.com/hook", "https://chat.example.com/hook"}
+	builder.config.CallbackEvents = []string{api.CallbackEventStarted, api.CallbackEventAssembled}
+
+	result, err := builder.Build(builder.config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{api.CallbackEventStarted, api.CallbackEventStarted, api.CallbackEventCompleted, api.CallbackEventCompleted}
+	if !reflect.DeepEqual(invoker.Events, expected) {
+		t.Errorf("Expected the events %v, got %v", expected, invoker.Events)
+	}
+	if len(result.Callbacks) != 4 || result.Callbacks[3].URL != "https://chat.example.com/hook" || result.Callbacks[3].Event != api.CallbackEventCompleted {
+		t.Errorf("Expected the delivery of the callbacks to be recorded, got %+v", result.Callbacks)
+	}
+
+	invoker.Events = nil
+	builder.docker.(*docker.FakeDocker).RunContainerContainerID = "1234"
+	if err := builder.Execute(constants.Assemble, "", builder.config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := builder.Execute(constants.Usage, "", builder.config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{api.CallbackEventAssembled, api.CallbackEventAssembled}; !reflect.DeepEqual(invoker.Events, expected) {
+		t.Errorf("Expected the events %v, got %v", expected, invoker.Events)
+	}
+}
+
 func testBuildHandler() *STI {
 	s := &STI{
 		docker:            &docker.FakeDocker{},
@@ -703,6 +852,124 @@ func TestPrepareErrorOptionalDownloadAndInstall(t *testing.T) {
 	}
 }
 
+func TestPrepareProbeBuilderImage(t *testing.T) {
+	tests := []struct {
+		name               string
+		mode               api.LayeredFallbackMode
+		probe              *docker.ImageProbe
+		probeError         error
+		expectReason       api.StepFailureReason
+		expectMissing      bool
+		expectProbeSkipped bool
+	}{
+		{
+			name:  "ok",
+			probe: &docker.ImageProbe{DestinationWritable: true, HasAssemble: true},
+		},
+		{
+			name:       "probe error",
+			probeError: errors.New("probe failed"),
+		},
+		{
+			name:          "missing requirements",
+			mode:          api.LayeredFallbackAuto,
+			probe:         &docker.ImageProbe{MissingRequirements: []string{"tar"}},
+			expectMissing: true,
+		},
+		{
+			name:         "missing requirements without fallback",
+			mode:         api.LayeredFallbackNever,
+			probe:        &docker.ImageProbe{MissingRequirements: []string{"tar"}},
+			expectReason: utilstatus.ReasonBuilderImageMissingRequirements,
+		},
+		{
+			name:         "destination not writable",
+			probe:        &docker.ImageProbe{Destination: "/tmp", HasAssemble: true},
+			expectReason: utilstatus.ReasonDestinationNotWritable,
+		},
+		{
+			name:               "always layered",
+			mode:               api.LayeredFallbackAlways,
+			probe:              &docker.ImageProbe{MissingRequirements: []string{"sh"}},
+			expectProbeSkipped: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rh := newFakeSTI(&FakeSTI{})
+			rh.config.BuilderImage = "testimage"
+			rh.config.LayeredFallback = tc.mode
+			fd := rh.docker.(*docker.FakeDocker)
+			fd.ProbeImageResult = tc.probe
+			fd.ProbeImageError = tc.probeError
+			err := rh.Prepare(rh.config)
+			if (err != nil) != (len(tc.expectReason) > 0) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rh.result.BuildInfo.FailureReason.Reason != tc.expectReason {
+				t.Errorf("Expected failure reason %q, got %q", tc.expectReason, rh.result.BuildInfo.FailureReason.Reason)
+			}
+			if rh.missingRequirements != tc.expectMissing {
+				t.Errorf("Expected missing requirements: %t, got %t", tc.expectMissing, rh.missingRequirements)
+			}
+			if probed := fd.ProbeImageName == "testimage"; probed == tc.expectProbeSkipped {
+				t.Errorf("Expected image to be probed: %t, got %t", !tc.expectProbeSkipped, probed)
+			}
+		})
+	}
+}
+
+func TestLintScripts(t *testing.T) {
+	tests := []struct {
+		name        string
+		assemble    string
+		noBash      bool
+		expectError string
+	}{
+		{
+			name:     "valid script",
+			assemble: "#!/bin/sh\necho hi\n",
+		},
+		{
+			name:        "CRLF line endings",
+			assemble:    "#!/bin/sh\r\necho hi\r\n",
+			expectError: "assemble: error: the script has CRLF (Windows) line endings",
+		},
+		{
+			name:     "bashisms with bash",
+			assemble: "#!/bin/sh\n[[ -n $A ]]\n",
+		},
+		{
+			name:        "bashisms without bash",
+			assemble:    "#!/bin/sh\n[[ -n $A ]]\n",
+			noBash:      true,
+			expectError: "assemble:2: error: the sh script uses [[ tests",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			workingDir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(workingDir, constants.UploadScripts), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(workingDir, constants.UploadScripts, constants.Assemble), []byte(tc.assemble), 0755); err != nil {
+				t.Fatal(err)
+			}
+			builder := newFakeSTI(&FakeSTI{})
+			builder.fs = fs.NewFileSystem()
+			builder.noBash = tc.noBash
+			builder.externalScripts = map[string]bool{constants.Assemble: true, constants.Run: false}
+			err := builder.lintScripts(&api.Config{WorkingDir: workingDir, BuilderImage: "testimage"})
+			if len(tc.expectError) == 0 && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if len(tc.expectError) > 0 && (err == nil || !strings.Contains(err.Error(), tc.expectError)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
 func TestPrepareUseCustomRuntimeArtifacts(t *testing.T) {
 	expectedMapping := filepath.FromSlash("/src") + ":dst"
 
@@ -833,6 +1100,66 @@ func TestPrepareDownloadAssembleRuntime(t *testing.T) {
 	}
 }
 
+func TestLockInputs(t *testing.T) {
+	rh := newFakeBaseSTI()
+	rh.runtimeDocker = &docker.FakeDocker{GetImageIDResult: "sha256:2222"}
+	rh.docker.(*docker.FakeDocker).GetImageIDResult = "sha256:1111"
+	rh.fs.(*testfs.FakeFileSystem).OpenContent = "#!/bin/sh"
+	rh.sourceInfo = &git.SourceInfo{CommitID: "abcdef"}
+	rh.externalScripts = map[string]bool{constants.Assemble: true}
+	rh.scriptsURL = map[string]string{
+		constants.Assemble: "<source-dir>/.s2i/bin/assemble",
+		constants.Run:      "image:///usr/libexec/s2i/run",
+	}
+	source, err := git.Parse("https://github.com/openshift/ruby-hello-world#beta4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &api.Config{
+		BuilderImage: "quay.io/org/ruby",
+		RuntimeImage: "quay.io/org/runtime",
+		Source:       source,
+		WorkingDir:   "/working-dir",
+		LockFile:     filepath.Join(t.TempDir(), lock.DefaultFile),
+	}
+	if err := rh.lockInputs(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	locked, err := lock.Read(config.LockFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checksum, _ := lock.Checksum(strings.NewReader("#!/bin/sh"))
+	expected := &lock.Lock{
+		Version:      lock.Version,
+		BuilderImage: lock.Image{Name: "quay.io/org/ruby", ID: "sha256:1111"},
+		RuntimeImage: &lock.Image{Name: "quay.io/org/runtime", ID: "sha256:2222"},
+		Source:       &lock.Source{URL: "https://github.com/openshift/ruby-hello-world", Ref: "beta4", Commit: "abcdef"},
+		Scripts: []lock.Script{
+			{Name: constants.Assemble, SHA256: checksum},
+			{Name: constants.Run, URL: "image:///usr/libexec/s2i/run"},
+		},
+	}
+	if !reflect.DeepEqual(locked, expected) {
+		t.Errorf("expected %#v, got %#v", expected, locked)
+	}
+	if opened := rh.fs.(*testfs.FakeFileSystem).OpenFile; opened != filepath.Join("/working-dir", constants.UploadScripts, constants.Assemble) {
+		t.Errorf("unexpected script checksummed: %s", opened)
+	}
+
+	config.Locked = true
+	if err := rh.lockInputs(config); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	rh.sourceInfo.CommitID = "123456"
+	if err := rh.lockInputs(config); err == nil {
+		t.Errorf("expected an error for a different commit")
+	}
+	if rh.result.BuildInfo.FailureReason.Reason != utilstatus.ReasonLockfileMismatch {
+		t.Errorf("unexpected failure reason: %v", rh.result.BuildInfo.FailureReason)
+	}
+}
+
 func TestExecuteOK(t *testing.T) {
 	rh := newFakeBaseSTI()
 	pe := &FakeSTI{}
@@ -909,6 +1236,36 @@ func TestExecuteOK(t *testing.T) {
 	}
 }
 
+func TestExecuteReports(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	tw.WriteHeader(&tar.Header{Name: "reports/", Mode: 0755, Typeflag: tar.TypeDir})
+	for name, content := range map[string]string{"reports/junit.xml": "<testsuite/>", "reports/unit/TEST-app.xml": "<testsuite/>"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		io.WriteString(tw, content)
+	}
+	tw.Close()
+
+	rh := newFakeSTI(&FakeSTI{})
+	rh.fs = fs.NewFileSystem()
+	rh.config.ReportsDir = t.TempDir()
+	fd := rh.docker.(*docker.FakeDocker)
+	fd.RunContainerContainerID = "1234"
+	fd.RunContainerError = s2ierr.NewContainerError("test/image", 1, "")
+	fd.Labels = map[string]string{constants.ReportsLabel: "/opt/app-root/src/reports"}
+	fd.DownloadFromContainerResult = map[string]string{"/opt/app-root/src/reports": buf.String()}
+
+	if err := rh.Execute(constants.Assemble, "", rh.config); err == nil {
+		t.Errorf("Expected the assemble error to be returned")
+	}
+	for _, name := range []string{"junit.xml", "unit/TEST-app.xml"} {
+		content, err := ioutil.ReadFile(filepath.Join(rh.config.ReportsDir, name))
+		if err != nil || string(content) != "<testsuite/>" {
+			t.Errorf("Expected the report %s to be extracted, got %q (%v)", name, content, err)
+		}
+	}
+}
+
 func TestExecuteRunContainerError(t *testing.T) {
 	rh := newFakeSTI(&FakeSTI{})
 	fd := rh.docker.(*docker.FakeDocker)
@@ -957,3 +1314,325 @@ func TestNewWithInvalidExcludeRegExp(t *testing.T) {
 		t.Errorf("expected regexp compilation error, got %v", err)
 	}
 }
+
+func TestUploadExclusionGlobs(t *testing.T) {
+	config := &api.Config{
+		DockerConfig: &api.DockerConfig{Endpoint: "unix:///var/run/docker.sock"},
+		ExcludeGlobs: []string{"/build/", "*.log"},
+		IncludeGlobs: []string{"build/keep.txt"},
+	}
+	client, err := docker.NewEngineAPIClient(config.DockerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder, err := New(client, config, fs.NewFileSystem(), build.Overrides{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uploadDir := filepath.Join(t.TempDir(), "upload")
+	for _, name := range []string{"scripts/assemble", "scripts/assemble.log", "src/build/out.bin", "src/build/keep.txt", "src/main.go", "src/debug.log", "src/lib/build/lib.go"} {
+		path := filepath.Join(uploadDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := builder.tar.CreateTarStream(uploadDir, false, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := []string{}
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			files = append(files, hdr.Name)
+		}
+	}
+	expected := []string{"scripts/assemble", "scripts/assemble.log", "src/build/keep.txt", "src/lib/build/lib.go", "src/main.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected the uploaded files %v, got %v", expected, files)
+	}
+}
+
+func TestCreateRuntimeEnvironment(t *testing.T) {
+	workingDir, err := ioutil.TempDir("", "s2i-runtime-env-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workingDir)
+	s2iDir := filepath.Join(workingDir, constants.Source, ".s2i")
+	if err := os.MkdirAll(s2iDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(s2iDir, constants.Environment), []byte("BUILD_TOKEN=secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	info := &git.SourceInfo{CommitID: "0123456789abcdef"}
+	buildEnv := CreateBuildEnvironment(workingDir, info, api.EnvironmentList{{Name: "MAVEN_OPTS", Value: "-Xmx1g"}})
+
+	// without a runtime environment, the runtime image gets the build environment
+	if env := CreateRuntimeEnvironment(workingDir, info, nil, buildEnv); !reflect.DeepEqual(env, buildEnv) {
+		t.Errorf("expected the build environment %v, got %v", buildEnv, env)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(s2iDir, constants.RuntimeEnvironment), []byte("# runtime\nAPP_ENV=production\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"S2I_GIT_COMMIT=0123456789abcdef", "APP_ENV=production", "JAVA_OPTS=-Xmx512m"}
+	env := CreateRuntimeEnvironment(workingDir, info, api.EnvironmentList{{Name: "JAVA_OPTS", Value: "-Xmx512m"}}, buildEnv)
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("expected the runtime environment %v, got %v", expected, env)
+	}
+}
+
+func TestSaveArtifactsCompression(t *testing.T) {
+	bh := testBuildHandler()
+	bh.config.SaveArtifactsCompression = api.CompressionAuto
+	fd := bh.docker.(*docker.FakeDocker)
+	fd.Labels = map[string]string{constants.SaveArtifactsCompressionLabel: "gzip"}
+	th := bh.tar.(*test.FakeTar)
+	if err := bh.Save(bh.config); err != nil {
+		t.Fatalf("Unexpected error when saving artifacts: %v", err)
+	}
+	expected := constants.SaveArtifactsCompressionEnvironment + "=gzip"
+	found := false
+	for _, env := range fd.RunContainerOpts.Env {
+		if env == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected %q in the environment of save-artifacts, got %v", expected, fd.RunContainerOpts.Env)
+	}
+	if th.ExtractTarReader == nil {
+		t.Errorf("ExtractTar was not called")
+	}
+}
+
+func TestNegotiateSaveArtifactsCompression(t *testing.T) {
+	tests := []struct {
+		requested api.Compression
+		supported string
+		expected  api.Compression
+	}{
+		{requested: "", supported: "gzip,zstd", expected: api.CompressionNone},
+		{requested: api.CompressionNone, supported: "gzip,zstd", expected: api.CompressionNone},
+		{requested: api.CompressionGzip, supported: "gzip", expected: api.CompressionGzip},
+		{requested: api.CompressionZstd, supported: "gzip", expected: api.CompressionNone},
+		{requested: api.CompressionZstd, supported: "", expected: api.CompressionNone},
+		{requested: api.CompressionAuto, supported: "gzip, zstd", expected: api.CompressionZstd},
+		{requested: api.CompressionAuto, supported: "GZIP", expected: api.CompressionGzip},
+		{requested: api.CompressionAuto, supported: "", expected: api.CompressionNone},
+	}
+	for _, tc := range tests {
+		if actual := negotiateSaveArtifactsCompression(tc.requested, tc.supported); actual != tc.expected {
+			t.Errorf("%s with %q supported: expected %s, got %s", tc.requested, tc.supported, tc.expected, actual)
+		}
+	}
+}
+
+func TestRestoreArtifacts(t *testing.T) {
+	bh := testBuildHandler()
+	bh.config.WorkingDir = "/working-dir"
+	bh.config.RestoreArtifacts = filepath.Join(t.TempDir(), "artifacts.tar")
+	if err := ioutil.WriteFile(bh.config.RestoreArtifacts, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fakeFS := bh.fs.(*testfs.FakeFileSystem)
+	th := bh.tar.(*test.FakeTar)
+	if err := bh.restoreArtifacts(bh.config); err != nil {
+		t.Fatalf("Unexpected error when restoring artifacts: %v", err)
+	}
+	expectedArtifactDir := "/working-dir/upload/artifacts"
+	if filepath.ToSlash(fakeFS.MkdirDir) != expectedArtifactDir {
+		t.Errorf("Mkdir was not called with the expected directory: %s", fakeFS.MkdirDir)
+	}
+	if filepath.ToSlash(th.ExtractTarDir) != expectedArtifactDir || th.ExtractTarReader == nil {
+		t.Errorf("ExtractTar was not called with the expected parameters.")
+	}
+
+	bh.config.RestoreArtifacts = filepath.Join(t.TempDir(), "missing.tar")
+	if err := bh.restoreArtifacts(bh.config); err == nil {
+		t.Errorf("Expected an error restoring a missing archive")
+	}
+}
+
+func TestPrepareCaches(t *testing.T) {
+	bh := testBuildHandler()
+	bh.config.WorkingDir = "/working-dir"
+	bh.config.Caches = api.CacheList{
+		{Mount: "/opt/app-root/src/.m2", KeyFiles: []string{"pom.xml"}},
+		{Mount: "/opt/app-root/src/.npm", KeyFiles: []string{"package-lock.json"}},
+	}
+	fakeFS := bh.fs.(*testfs.FakeFileSystem)
+	fakeFS.OpenContent = "<project/>"
+	fd := bh.docker.(*docker.FakeDocker)
+
+	binds := bh.prepareCaches(bh.config)
+	if len(binds) != 2 || !strings.HasPrefix(binds[0], cacheVolumePrefix) || !strings.HasSuffix(binds[0], ":/opt/app-root/src/.m2") {
+		t.Fatalf("Unexpected cache binds %v", binds)
+	}
+	if !reflect.DeepEqual(fd.CreateVolumeNames, []string{strings.Split(binds[0], ":")[0], strings.Split(binds[1], ":")[0]}) {
+		t.Errorf("Expected the volumes of %v to be created, got %v", binds, fd.CreateVolumeNames)
+	}
+	if fd.CreateVolumeLabels[constants.CacheMountLabel] != "/opt/app-root/src/.npm" {
+		t.Errorf("Unexpected labels of the cache volume %v", fd.CreateVolumeLabels)
+	}
+	if filepath.ToSlash(fakeFS.OpenFile) != "/working-dir/upload/src/package-lock.json" {
+		t.Errorf("Unexpected key file %q", fakeFS.OpenFile)
+	}
+	if again := bh.prepareCaches(bh.config); !reflect.DeepEqual(again, binds) {
+		t.Errorf("Expected the same key files to give the same volumes, got %v and %v", binds, again)
+	}
+	fakeFS.OpenContent = "<project><dependencies/></project>"
+	if changed := bh.prepareCaches(bh.config); changed[0] == binds[0] {
+		t.Errorf("Expected changed key files to give another volume, got %v", changed)
+	}
+
+	fakeFS.OpenError = fmt.Errorf("no such file")
+	if binds := bh.prepareCaches(bh.config); len(binds) != 0 {
+		t.Errorf("Expected the caches without key files not to be mounted, got %v", binds)
+	}
+}
+
+func TestInjectionGroups(t *testing.T) {
+	injections := api.VolumeList{
+		{Source: "/certs", Destination: "/etc/pki"},
+		{Source: "/src", Destination: "/opt/app-root/src"},
+		{Source: "/secrets", Destination: "/run/secrets"},
+		{Source: "/m2", Destination: "/opt/app-root/src/.m2/"},
+		{Source: "/anchors", Destination: "/etc/pki/ca-trust"},
+		{Source: "/npmrc", Destination: "/opt/app-root/src/.npmrc"},
+		{Source: "/tokens", Destination: "/run/secrets-tokens"},
+	}
+	expected := [][]int{{0, 4}, {1, 3, 5}, {2}, {6}}
+	if groups := injectionGroups(injections); !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected the groups %v, got %v", expected, groups)
+	}
+
+	// a relative destination is within the unknown working directory, and
+	// the injections overlapping through it are merged
+	injections = api.VolumeList{
+		{Source: "/a", Destination: "/a"},
+		{Source: "/b", Destination: "/b"},
+		{Source: "/config", Destination: ""},
+		{Source: "/c", Destination: "config"},
+	}
+	expected = [][]int{{0, 1, 2, 3}}
+	if groups := injectionGroups(injections); !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected the groups %v, got %v", expected, groups)
+	}
+}
+
+// uploadDocker records the injections uploaded concurrently, and fails the
+// uploads of the sources in fail.
+type uploadDocker struct {
+	*docker.FakeDocker
+	fail map[string]error
+
+	mu       sync.Mutex
+	active   int
+	maxLoad  int
+	uploaded []string
+}
+
+func (d *uploadDocker) UploadToContainer(fs fs.FileSystem, src, dest, container string) error {
+	d.mu.Lock()
+	d.active++
+	if d.active > d.maxLoad {
+		d.maxLoad = d.active
+	}
+	d.uploaded = append(d.uploaded, src)
+	d.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	d.mu.Lock()
+	d.active--
+	d.mu.Unlock()
+	return d.fail[src]
+}
+
+func TestUploadInjections(t *testing.T) {
+	injections := api.VolumeList{
+		{Source: "/certs", Destination: "/etc/pki"},
+		{Source: "/m2", Destination: "/opt/app-root/src/.m2"},
+		{Source: "/npm", Destination: "/opt/app-root/src/.npm"},
+		{Source: "/secrets", Destination: "/run/secrets"},
+	}
+	tests := map[string]struct {
+		parallelism int
+		fail        map[string]error
+		expectedErr string
+	}{
+		"sequential": {
+			parallelism: 0,
+		},
+		"parallel": {
+			parallelism: 2,
+		},
+		"one failure": {
+			parallelism: 4,
+			fail:        map[string]error{"/npm": errors.New("no space left on device")},
+			expectedErr: "no space left on device",
+		},
+		"several failures": {
+			parallelism: 4,
+			fail:        map[string]error{"/m2": errors.New("no space left on device"), "/secrets": errors.New("permission denied")},
+			expectedErr: "2 injections failed to upload: /m2: no space left on device; /secrets: permission denied",
+		},
+	}
+	for desc, tc := range tests {
+		bh := testBuildHandler()
+		fd := &uploadDocker{FakeDocker: bh.docker.(*docker.FakeDocker), fail: tc.fail}
+		bh.docker = fd
+		bh.config.Injections = injections
+		bh.config.InjectionParallelism = tc.parallelism
+
+		err := bh.uploadInjections(bh.config, "/tmp/rm-script", "container")
+		if len(tc.expectedErr) == 0 && err != nil || len(tc.expectedErr) > 0 && (err == nil || err.Error() != tc.expectedErr) {
+			t.Errorf("%s: expected the error %q, got %v", desc, tc.expectedErr, err)
+		}
+		limit := tc.parallelism
+		if limit < 1 {
+			limit = 1
+		}
+		if fd.maxLoad > limit || limit > 1 && fd.maxLoad < 2 {
+			t.Errorf("%s: expected up to %d concurrent uploads, got %d", desc, limit, fd.maxLoad)
+		}
+		expectedUploads := len(injections)
+		if err == nil {
+			expectedUploads++
+		}
+		if len(fd.uploaded) != expectedUploads {
+			t.Errorf("%s: expected %d uploads, got %v", desc, expectedUploads, fd.uploaded)
+		}
+	}
+
+	// the overlapping injections are uploaded one after the other, in order
+	bh := testBuildHandler()
+	fd := &uploadDocker{FakeDocker: bh.docker.(*docker.FakeDocker)}
+	bh.docker = fd
+	bh.config.Injections = api.VolumeList{
+		{Source: "/settings", Destination: "/opt/app-root/src/.m2/settings.xml"},
+		{Source: "/m2", Destination: "/opt/app-root/src/.m2"},
+		{Source: "/src", Destination: "/opt/app-root/src"},
+	}
+	bh.config.InjectionParallelism = 4
+	if err := bh.uploadInjections(bh.config, "/tmp/rm-script", "container"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"/settings", "/m2", "/src", "/tmp/rm-script"}
+	if fd.maxLoad != 1 || !reflect.DeepEqual(fd.uploaded, expected) {
+		t.Errorf("expected the uploads %v one after the other, got %v with up to %d concurrent uploads", expected, fd.uploaded, fd.maxLoad)
+	}
+}
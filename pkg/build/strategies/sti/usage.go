@@ -1,10 +1,22 @@
 package sti
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/extract"
+	"github.com/openshift/source-to-image/pkg/scripts"
+	"github.com/openshift/source-to-image/pkg/tar"
 	"github.com/openshift/source-to-image/pkg/util/fs"
 )
 
@@ -17,9 +29,13 @@ type usageHandler interface {
 
 // Usage display usage information about a particular build image
 type Usage struct {
-	handler usageHandler
-	garbage build.Cleaner
-	config  *api.Config
+	handler   usageHandler
+	garbage   build.Cleaner
+	config    *api.Config
+	docker    docker.Docker
+	installer scripts.Installer
+	fs        fs.FileSystem
+	out       io.Writer
 }
 
 // NewUsage creates a new instance of the default Usage implementation
@@ -29,16 +45,25 @@ func NewUsage(client docker.Client, config *api.Config) (*Usage, error) {
 		return nil, err
 	}
 	usage := Usage{
-		handler: b,
-		config:  config,
-		garbage: b.garbage,
+		handler:   b,
+		config:    config,
+		garbage:   b.garbage,
+		docker:    b.docker,
+		installer: b.installer,
+		fs:        b.fs,
+		out:       os.Stdout,
 	}
 	return &usage, nil
 }
 
 // Show starts the builder container and invokes the usage script on it
-// to print usage information for the script.
+// to print usage information for the script. Depending on the usage mode,
+// the usage script is printed instead.
 func (u *Usage) Show() error {
+	if u.config.UsageMode == api.UsageModePrint {
+		return u.printScript()
+	}
+
 	b := u.handler
 	defer u.garbage.Cleanup(u.config)
 
@@ -48,5 +73,74 @@ func (u *Usage) Show() error {
 		return err
 	}
 
-	return b.Execute(constants.Usage, "", u.config)
+	err := b.Execute(constants.Usage, "", u.config)
+	if u.config.UsageMode == api.UsageModeAuto && isExecFormatError(err) {
+		log.Warningf("Image %q cannot run on this host, printing its usage script instead", u.config.BuilderImage)
+		return u.printScript()
+	}
+	return err
+}
+
+// printScript prints the usage script, fetched without running a container of
+// the image. The script of the image is never run on the host.
+func (u *Usage) printScript() error {
+	workingDir, err := u.fs.CreateWorkingDirectory()
+	if err != nil {
+		return err
+	}
+	defer u.fs.RemoveDirectory(workingDir)
+
+	script, err := u.fetchScript(workingDir)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(script)
+	if err != nil {
+		return err
+	}
+	_, err = u.out.Write(data)
+	return err
+}
+
+// fetchScript downloads the usage script to the working directory, from the
+// scripts URL or from the builder image, creating a container which is never
+// started to extract it. The path of the script is returned.
+func (u *Usage) fetchScript(workingDir string) (string, error) {
+	results, err := u.installer.InstallRequired([]string{constants.Usage}, workingDir)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", fmt.Errorf("the %s script was not installed", constants.Usage)
+	}
+	result := results[0]
+	if result.Downloaded {
+		return filepath.Join(workingDir, constants.UploadScripts, constants.Usage), nil
+	}
+	if !strings.HasPrefix(result.URL, "image://") {
+		return "", fmt.Errorf("unable to fetch the %s script from %q", constants.Usage, result.URL)
+	}
+	scriptPath := strings.TrimPrefix(result.URL, "image://")
+	opts := extract.Options{
+		Image:      u.config.BuilderImage,
+		Paths:      []string{scriptPath},
+		Output:     workingDir,
+		PullPolicy: u.config.BuilderPullPolicy,
+	}
+	if err := extract.Extract(u.docker, tar.New(u.fs), opts, nil); err != nil {
+		return "", err
+	}
+	return filepath.Join(workingDir, path.Base(scriptPath)), nil
+}
+
+// isExecFormatError returns true when the container could not run, as the
+// image is built for another architecture.
+func isExecFormatError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if e, ok := err.(s2ierr.ContainerError); ok && strings.Contains(e.Output, "exec format error") {
+		return true
+	}
+	return strings.Contains(err.Error(), "exec format error")
 }
@@ -1,12 +1,17 @@
 package sti
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/util/fs"
 )
 
 type FakeUsageHandler struct {
@@ -49,9 +54,31 @@ func (f *FakeUsageHandler) Download(*api.Config) error {
 func newTestUsage() *Usage {
 	return &Usage{
 		handler: &FakeUsageHandler{},
+		config:  &api.Config{},
 	}
 }
 
+// fakeUsageInstaller installs a usage script with the given content.
+type fakeUsageInstaller struct {
+	script string
+}
+
+func (f *fakeUsageInstaller) InstallRequired(scripts []string, dstDir string) ([]api.InstallResult, error) {
+	dst := filepath.Join(dstDir, constants.UploadScripts, constants.Usage)
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(dst, []byte(f.script), 0700); err != nil {
+		return nil, err
+	}
+	return []api.InstallResult{{Script: constants.Usage, URL: "http://the.scripts.url/usage", Installed: true, Downloaded: true}}, nil
+}
+
+func (f *fakeUsageInstaller) InstallOptional(scripts []string, dstDir string) []api.InstallResult {
+	results, _ := f.InstallRequired(scripts, dstDir)
+	return results
+}
+
 func TestUsage(t *testing.T) {
 	u := newTestUsage()
 	g := &FakeCleaner{}
@@ -99,3 +126,51 @@ func TestUsageExecuteError(t *testing.T) {
 		t.Errorf("Unexpected error returned from Usage: %v", err)
 	}
 }
+
+func TestUsageOffline(t *testing.T) {
+	script := "#!/bin/sh\necho \"$HOME\" \"$PWD\"\n"
+	tests := []struct {
+		mode     api.UsageMode
+		expected func(out string) bool
+	}{
+		{mode: api.UsageModePrint, expected: func(out string) bool { return out == script }},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			out := &bytes.Buffer{}
+			u := newTestUsage()
+			u.garbage = &FakeCleaner{}
+			u.config.UsageMode = tc.mode
+			u.installer = &fakeUsageInstaller{script: script}
+			u.fs = fs.NewFileSystem()
+			u.out = out
+			if err := u.Show(); err != nil {
+				t.Fatalf("Unexpected error returned from Usage: %v", err)
+			}
+			if fh := u.handler.(*FakeUsageHandler); fh.executeCommand != "" {
+				t.Errorf("Execute called for the %s usage mode", tc.mode)
+			}
+			if !tc.expected(out.String()) {
+				t.Errorf("Unexpected output %q", out.String())
+			}
+		})
+	}
+}
+
+func TestUsageAutoExecFormatError(t *testing.T) {
+	out := &bytes.Buffer{}
+	u := newTestUsage()
+	u.garbage = &FakeCleaner{}
+	u.config.UsageMode = api.UsageModeAuto
+	u.installer = &fakeUsageInstaller{script: "usage"}
+	u.fs = fs.NewFileSystem()
+	u.out = out
+	fh := u.handler.(*FakeUsageHandler)
+	fh.executeError = fmt.Errorf("exec /usr/libexec/s2i/usage: exec format error")
+	if err := u.Show(); err != nil {
+		t.Fatalf("Unexpected error returned from Usage: %v", err)
+	}
+	if out.String() != "usage" {
+		t.Errorf("Expected the usage script to be printed, got %q", out.String())
+	}
+}
@@ -1,21 +1,88 @@
 package strategies
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/build/strategies/dockerfile"
 	"github.com/openshift/source-to-image/pkg/build/strategies/onbuild"
 	"github.com/openshift/source-to-image/pkg/build/strategies/sti"
 	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/policy"
+	"github.com/openshift/source-to-image/pkg/util"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+	"github.com/openshift/source-to-image/pkg/util/progress"
 	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
 )
 
+var log = utillog.StderrLog
+
 // Strategy creates the appropriate build strategy for the provided config, using
 // the overrides provided. Not all strategies support all overrides.
+// The errors returned by the strategy, and by its builds, carry the reason the
+// build failed for (see utilstatus.FailureReasonOf). The build starts with the
+// strategy, which sets its deadline from config.MaxBuildDuration.
 func Strategy(client docker.Client, config *api.Config, overrides build.Overrides) (build.Builder, api.BuildInfo, error) {
+	config.BuildDeadline = time.Time{}
+	if config.MaxBuildDuration > 0 {
+		config.BuildDeadline = time.Now().Add(config.MaxBuildDuration)
+	}
+	builder, buildInfo, err := strategy(client, config, overrides)
+	if err != nil {
+		return nil, buildInfo, failed(&buildInfo, err)
+	}
+	return &failureReasonBuilder{builder}, buildInfo, nil
+}
+
+// failureReasonBuilder attaches the reason its builds failed for to their
+// errors.
+type failureReasonBuilder struct {
+	build.Builder
+}
+
+// Build executes the build and attaches the reason it failed for to its error.
+func (b *failureReasonBuilder) Build(config *api.Config) (*api.Result, error) {
+	result, err := b.Builder.Build(config)
+	if err == nil {
+		return result, nil
+	}
+	if result == nil {
+		result = &api.Result{}
+	}
+	// the containers of the build are stopped at its deadline, which fails the
+	// step running them
+	if !config.BuildDeadline.IsZero() && !time.Now().Before(config.BuildDeadline) {
+		log.V(1).Infof("The build failed after its deadline: %v", err)
+		err = utilstatus.NewFailureError(utilstatus.NewFailureReason(
+			utilstatus.ReasonQuotaExceeded,
+			utilstatus.ReasonMessageQuotaExceeded,
+		), s2ierr.NewBuildDurationExceededError(config.MaxBuildDuration))
+	}
+	return result, failed(&result.BuildInfo, err)
+}
+
+// failed attaches the reason the build failed for to its error, defaulting to a
+// generic failure when the strategy did not report any.
+func failed(info *api.BuildInfo, err error) error {
+	if reason, ok := utilstatus.FailureReasonOf(err); ok {
+		info.FailureReason = reason
+		return err
+	}
+	if len(info.FailureReason.Reason) == 0 {
+		info.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonGenericS2IBuildFailed,
+			utilstatus.ReasonMessageGenericS2iBuildFailed,
+		)
+	}
+	return utilstatus.NewFailureError(info.FailureReason, err)
+}
+
+func strategy(client docker.Client, config *api.Config, overrides build.Overrides) (build.Builder, api.BuildInfo, error) {
 	var builder build.Builder
 	var buildInfo api.BuildInfo
 	var err error
@@ -25,6 +92,9 @@ func Strategy(client docker.Client, config *api.Config, overrides build.Override
 	startTime := time.Now()
 
 	if len(config.AsDockerfile) != 0 {
+		selected, reason := selectStrategy(config, nil, false)
+		log.Infof("Using the %s strategy: %s", selected, reason)
+		config.Strategy = selected
 		builder, err = dockerfile.New(config, fileSystem)
 		if err != nil {
 			buildInfo.FailureReason = utilstatus.NewFailureReason(
@@ -36,7 +106,17 @@ func Strategy(client docker.Client, config *api.Config, overrides build.Override
 		return builder, buildInfo, nil
 	}
 
+	progress.Step(api.StepPullBuilderImage)
 	dkr := docker.New(client, config.PullAuthentication)
+	if !config.SkipDiskCheck && config.BuilderPullPolicy != api.PullNever {
+		if err = docker.CheckPullDiskSpace(dkr, config.BuilderImage); err != nil {
+			buildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonInsufficientDiskSpace,
+				utilstatus.ReasonMessageInsufficientDiskSpace,
+			)
+			return nil, buildInfo, err
+		}
+	}
 	image, err := docker.GetBuilderImage(dkr, config)
 	buildInfo.Stages = api.RecordStageAndStepInfo(buildInfo.Stages, api.StagePullImages, api.StepPullBuilderImage, startTime, time.Now())
 	if err != nil {
@@ -56,9 +136,37 @@ func Strategy(client docker.Client, config *api.Config, overrides build.Override
 		return nil, buildInfo, err
 	}
 
-	// if we're blocking onbuild, just do a normal s2i build flow
-	// which won't do a docker build and invoke the onbuild commands
-	if image.OnBuild && !config.BlockOnBuild {
+	if err = policy.Admit(config, image.Image, image.OnBuild); err != nil {
+		buildInfo.FailureReason = utilstatus.NewFailureReason(
+			utilstatus.ReasonPolicyDenied,
+			utilstatus.ReasonMessagePolicyDenied,
+		)
+		return nil, buildInfo, err
+	}
+
+	selected, reason := selectStrategy(config, image.Image, image.OnBuild)
+	log.Infof("Using the %s strategy: %s", selected, reason)
+	config.Strategy = selected
+
+	if selected == api.StrategyOnBuild {
+		instructions, err := dkr.GetOnBuild(config.BuilderImage)
+		if err != nil {
+			buildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonGenericS2IBuildFailed,
+				utilstatus.ReasonMessageGenericS2iBuildFailed,
+			)
+			return nil, buildInfo, err
+		}
+		// the ONBUILD instructions are executed once confirmed and audited
+		if len(instructions) == 0 {
+			log.V(1).Infof("The builder image %s has no ONBUILD instructions", config.BuilderImage)
+		} else if err = build.AuditOnBuild(config, instructions); err != nil {
+			buildInfo.FailureReason = utilstatus.NewFailureReason(
+				utilstatus.ReasonOnBuildForbidden,
+				utilstatus.ReasonMessageOnBuildForbidden,
+			)
+			return nil, buildInfo, err
+		}
 		builder, err = onbuild.New(client, config, fileSystem, overrides)
 		if err != nil {
 			buildInfo.FailureReason = utilstatus.NewFailureReason(
@@ -80,3 +188,40 @@ func Strategy(client docker.Client, config *api.Config, overrides build.Override
 	}
 	return builder, buildInfo, err
 }
+
+// selectStrategy returns the strategy building the given configuration with
+// the given builder image, which has ONBUILD instructions when onBuild is
+// true, and the reason it is selected for.
+func selectStrategy(config *api.Config, image *api.Image, onBuild bool) (api.BuildStrategy, string) {
+	if len(config.Strategy) > 0 && config.Strategy != api.StrategyAuto {
+		return config.Strategy, "selected with --strategy"
+	}
+	var labels map[string]string
+	if image != nil && image.Config != nil {
+		labels = image.Config.Labels
+	}
+	scriptsLabel := util.FirstNonEmpty(labelName(labels, constants.ScriptsURLLabel), labelName(labels, constants.DeprecatedScriptsURLLabel))
+	switch {
+	case len(config.AsDockerfile) > 0:
+		return api.StrategyDockerfile, "--as-dockerfile is set"
+	case onBuild && config.BlockOnBuild:
+		return api.StrategySource, fmt.Sprintf("the ONBUILD instructions of the builder image %s are blocked", config.BuilderImage)
+	case onBuild && len(scriptsLabel) > 0:
+		return api.StrategyOnBuild, fmt.Sprintf("the builder image %s has ONBUILD instructions, which take precedence over its %s label (use --strategy source to run its S2I scripts)", config.BuilderImage, scriptsLabel)
+	case onBuild:
+		return api.StrategyOnBuild, fmt.Sprintf("the builder image %s has ONBUILD instructions", config.BuilderImage)
+	case len(config.ScriptsURL) > 0:
+		return api.StrategySource, "the S2I scripts are given by --scripts-url"
+	case len(scriptsLabel) > 0:
+		return api.StrategySource, fmt.Sprintf("the builder image %s has the %s label", config.BuilderImage, scriptsLabel)
+	}
+	return api.StrategySource, fmt.Sprintf("the builder image %s has no ONBUILD instructions", config.BuilderImage)
+}
+
+// labelName returns the name of the label when it is set.
+func labelName(labels map[string]string, name string) string {
+	if len(labels[name]) == 0 {
+		return ""
+	}
+	return name
+}
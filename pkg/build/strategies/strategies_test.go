@@ -0,0 +1,168 @@
+package strategies
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+)
+
+type fakeBuilder struct {
+	result *api.Result
+	err    error
+}
+
+func (f *fakeBuilder) Build(*api.Config) (*api.Result, error) {
+	return f.result, f.err
+}
+
+func TestFailureReasonBuilder(t *testing.T) {
+	assembleFailed := utilstatus.NewFailureReason(utilstatus.ReasonAssembleFailed, utilstatus.ReasonMessageAssembleFailed)
+	genericFailure := utilstatus.NewFailureReason(utilstatus.ReasonGenericS2IBuildFailed, utilstatus.ReasonMessageGenericS2iBuildFailed)
+	tests := []struct {
+		name     string
+		builder  *fakeBuilder
+		expected api.FailureReason
+	}{
+		{
+			name:    "success",
+			builder: &fakeBuilder{result: &api.Result{Success: true}},
+		},
+		{
+			name: "reported reason",
+			builder: &fakeBuilder{
+				result: &api.Result{BuildInfo: api.BuildInfo{FailureReason: assembleFailed}},
+				err:    errors.New("assemble failed"),
+			},
+			expected: assembleFailed,
+		},
+		{
+			name:     "unreported reason",
+			builder:  &fakeBuilder{result: &api.Result{}, err: errors.New("build failed")},
+			expected: genericFailure,
+		},
+		{
+			name:     "no result",
+			builder:  &fakeBuilder{err: errors.New("build failed")},
+			expected: genericFailure,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := (&failureReasonBuilder{tc.builder}).Build(&api.Config{})
+			if tc.builder.err == nil {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			reason, ok := utilstatus.FailureReasonOf(err)
+			if !ok || reason != tc.expected {
+				t.Errorf("Expected the error to carry the reason %v, got %v", tc.expected, reason)
+			}
+			if result.BuildInfo.FailureReason != tc.expected {
+				t.Errorf("Expected the result to report the reason %v, got %v", tc.expected, result.BuildInfo.FailureReason)
+			}
+			if err.Error() != tc.builder.err.Error() {
+				t.Errorf("Expected the error %q, got %q", tc.builder.err, err)
+			}
+		})
+	}
+}
+
+func TestFailureReasonBuilderDeadline(t *testing.T) {
+	builder := &fakeBuilder{
+		result: &api.Result{BuildInfo: api.BuildInfo{FailureReason: utilstatus.NewFailureReason(utilstatus.ReasonAssembleFailed, utilstatus.ReasonMessageAssembleFailed)}},
+		err:    errors.New("container stopped at the deadline of the build"),
+	}
+	config := &api.Config{MaxBuildDuration: time.Minute, BuildDeadline: time.Now().Add(-time.Second)}
+	result, err := (&failureReasonBuilder{builder}).Build(config)
+	if s2ierr.ExitCode(err) != s2ierr.ExitCodeQuotaExceeded {
+		t.Errorf("Expected the build to fail with its maximum duration, got %v", err)
+	}
+	if reason := result.BuildInfo.FailureReason.Reason; reason != utilstatus.ReasonQuotaExceeded {
+		t.Errorf("Expected the failure reason %s, got %s", utilstatus.ReasonQuotaExceeded, reason)
+	}
+}
+
+func TestSelectStrategy(t *testing.T) {
+	labeled := &api.Image{Config: &api.ContainerConfig{Labels: map[string]string{constants.ScriptsURLLabel: "image:///usr/libexec/s2i"}}}
+	tests := []struct {
+		name     string
+		config   api.Config
+		image    *api.Image
+		onBuild  bool
+		expected api.BuildStrategy
+		reason   string
+	}{
+		{
+			name:     "as dockerfile",
+			config:   api.Config{AsDockerfile: "Dockerfile"},
+			expected: api.StrategyDockerfile,
+			reason:   "--as-dockerfile is set",
+		},
+		{
+			name:     "onbuild",
+			config:   api.Config{BuilderImage: "node:onbuild", Strategy: api.StrategyAuto},
+			onBuild:  true,
+			expected: api.StrategyOnBuild,
+			reason:   "the builder image node:onbuild has ONBUILD instructions",
+		},
+		{
+			name:     "onbuild with scripts",
+			config:   api.Config{BuilderImage: "builder"},
+			image:    labeled,
+			onBuild:  true,
+			expected: api.StrategyOnBuild,
+			reason:   "the builder image builder has ONBUILD instructions, which take precedence over its io.openshift.s2i.scripts-url label (use --strategy source to run its S2I scripts)",
+		},
+		{
+			name:     "blocked onbuild",
+			config:   api.Config{BuilderImage: "node:onbuild", BlockOnBuild: true},
+			onBuild:  true,
+			expected: api.StrategySource,
+			reason:   "the ONBUILD instructions of the builder image node:onbuild are blocked",
+		},
+		{
+			name:     "forced",
+			config:   api.Config{BuilderImage: "builder", Strategy: api.StrategySource},
+			image:    labeled,
+			onBuild:  true,
+			expected: api.StrategySource,
+			reason:   "selected with --strategy",
+		},
+		{
+			name:     "scripts url",
+			config:   api.Config{BuilderImage: "builder", ScriptsURL: "https://example.com/scripts"},
+			image:    labeled,
+			expected: api.StrategySource,
+			reason:   "the S2I scripts are given by --scripts-url",
+		},
+		{
+			name:     "scripts label",
+			config:   api.Config{BuilderImage: "builder"},
+			image:    labeled,
+			expected: api.StrategySource,
+			reason:   "the builder image builder has the io.openshift.s2i.scripts-url label",
+		},
+		{
+			name:     "no label",
+			config:   api.Config{BuilderImage: "builder"},
+			image:    &api.Image{},
+			expected: api.StrategySource,
+			reason:   "the builder image builder has no ONBUILD instructions",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, reason := selectStrategy(&tc.config, tc.image, tc.onBuild)
+			if selected != tc.expected || reason != tc.reason {
+				t.Errorf("expected the %s strategy because %s, got the %s strategy because %s", tc.expected, tc.reason, selected, reason)
+			}
+		})
+	}
+}
@@ -38,13 +38,38 @@ func NewCmdCLI() *cobra.Command {
 	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.CAFile), "ca", cfg.DockerConfig.CAFile, "Set the path of the docker TLS ca file")
 	s2iCmd.PersistentFlags().BoolVar(&(cfg.DockerConfig.UseTLS), "tls", cfg.DockerConfig.UseTLS, "Use TLS to connect to docker; implied by --tlsverify")
 	s2iCmd.PersistentFlags().BoolVar(&(cfg.DockerConfig.TLSVerify), "tlsverify", cfg.DockerConfig.TLSVerify, "Use TLS to connect to docker and verify the remote")
-	s2iCmd.AddCommand(cmd.NewCmdVersion())
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.TLSMinVersion), "tls-min-version", "", "Set the minimum TLS version used to connect to docker (1.2 or 1.3)")
+	s2iCmd.PersistentFlags().StringSliceVar(&(cfg.DockerConfig.TLSCipherSuites), "tls-cipher-suites", nil, "Comma-separated list of the TLS cipher suites allowed to connect to docker, as named by Go")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.SPIFFEEndpointSocket), "tls-spiffe-socket", cfg.DockerConfig.SPIFFEEndpointSocket, "Set the address of the SPIFFE Workload API socket the docker TLS certificate and CA bundle are fetched from")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.Context), "docker-context", "", "Set the name of the Docker context to use; overrides --url and the TLS flags")
+	s2iCmd.PersistentFlags().Var(&(cfg.DockerConfig.Engine), "engine", "Set the container engine used to run the builds (docker or containerd)")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.ContainerdAddress), "containerd-address", cfg.DockerConfig.ContainerdAddress, "Set the address of the containerd socket to use with the containerd engine")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.ContainerdNamespace), "containerd-namespace", cfg.DockerConfig.ContainerdNamespace, "Set the containerd namespace to use with the containerd engine")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.RegistriesConf), "registries-conf", cfg.DockerConfig.RegistriesConf, "Set the registries.conf file resolving the images pulled by the containerd engine")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.NamePrefix), "name-prefix", "", "Set the prefix of the names of the containers and temporary images created by s2i (defaults to s2i)")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.WorkerID), "worker-id", "", "Set the worker ID included in the names and labels of the containers and temporary images created by s2i")
+	s2iCmd.PersistentFlags().StringToStringVar(&(cfg.DockerConfig.ResourceLabels), "resource-label", nil, "Set a label (key=value) on the containers and temporary images created by s2i; can be repeated")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.BuildID), "build-id", "", "Set the build ID recorded in the labels of the containers, temporary images and volumes created by s2i")
+	s2iCmd.PersistentFlags().BoolVar(&(cfg.DockerConfig.Offline), "offline", false, "Forbid all network access by s2i: the images are not pulled and the sources and scripts must be local")
+	s2iCmd.PersistentFlags().StringVar(&(cfg.DockerConfig.ImageStore), "image-store", "", "Set the OCI image layout directory the images missing locally are loaded from with --offline")
+	s2iCmd.AddCommand(cmd.NewCmdVersion(cfg))
 	s2iCmd.AddCommand(cmd.NewCmdBuild(cfg))
 	s2iCmd.AddCommand(cmd.NewCmdRebuild(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdDev(cfg))
 	s2iCmd.AddCommand(cmd.NewCmdUsage(cfg))
 	s2iCmd.AddCommand(cmd.NewCmdCreate())
 	s2iCmd.AddCommand(cmd.NewCmdGenerate(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdExtract(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdCleanup(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdPrefetch(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdImage(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdLintScripts(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdSaveArtifacts(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdValidate())
+	s2iCmd.AddCommand(cmd.NewCmdDoctor(cfg))
 	cmdutil.SetupLogger(s2iCmd.PersistentFlags())
+	cmdutil.SetupLogFile(s2iCmd)
+	cmdutil.SetupTempDir(s2iCmd.PersistentFlags())
 	basename := filepath.Base(os.Args[0])
 	// Make case-insensitive and strip executable suffix if present
 	if runtime.GOOS == "windows" {
@@ -55,6 +80,8 @@ func NewCmdCLI() *cobra.Command {
 		log.Warning("sti binary is deprecated, use s2i instead")
 	}
 
+	s2iCmd.AddCommand(cmd.NewCmdSelfUpdate(cfg))
+	s2iCmd.AddCommand(cmd.NewCmdConfig(s2iCmd))
 	s2iCmd.AddCommand(cmd.NewCmdCompletion(s2iCmd))
 
 	return s2iCmd
@@ -2,12 +2,18 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/api/describe"
 	"github.com/openshift/source-to-image/pkg/api/validation"
 	"github.com/openshift/source-to-image/pkg/build"
@@ -16,11 +22,21 @@ import (
 	"github.com/openshift/source-to-image/pkg/config"
 	"github.com/openshift/source-to-image/pkg/docker"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/generate"
+	"github.com/openshift/source-to-image/pkg/kubernetes"
+	"github.com/openshift/source-to-image/pkg/lock"
 	"github.com/openshift/source-to-image/pkg/run"
+	"github.com/openshift/source-to-image/pkg/scm"
 	"github.com/openshift/source-to-image/pkg/scm/git"
 	"github.com/openshift/source-to-image/pkg/tar"
 	"github.com/openshift/source-to-image/pkg/util"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+	"github.com/openshift/source-to-image/pkg/util/interrupt"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
+	"github.com/openshift/source-to-image/pkg/util/progress"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+	"github.com/openshift/source-to-image/pkg/util/telemetry"
+	"github.com/openshift/source-to-image/pkg/util/tracing"
 	"github.com/openshift/source-to-image/pkg/version"
 )
 
@@ -34,11 +50,14 @@ func NewCmdBuild(cfg *api.Config) *cobra.Command {
 	useConfig := false
 	oldScriptsFlag := ""
 	oldDestination := ""
+	scriptURLs := map[string]*string{}
 
-	var networkMode string
+	var resultFile string
+	var sourcesFile string
+	var explain string
 
 	buildCmd := &cobra.Command{
-		Use:   "build <source> <image> [<tag>]",
+		Use:   "build <source> <image> [<tag>...]",
 		Short: "Build a new image",
 		Long:  "Build a new Docker image named <tag> (if provided) from a source repository and base image.",
 		Example: `
@@ -47,45 +66,90 @@ $ s2i build https://github.com/openshift/ruby-hello-world centos/ruby-22-centos7
 
 # Build from a local directory.  If this directory is a git repo then the current commit will be built.
 $ s2i build . centos/ruby-22-centos7 hello-world-app
+
+# Build a Docker image from an artifact built beforehand, without the sources
+$ s2i build --from-artifact target/app.jar fabric8/s2i-java hello-world-app
+
+# Tag the image with the abbreviated commit of the sources as well
+$ s2i build . centos/ruby-22-centos7 quay.io/user/hello-world-app --tag-template '{{.Repo}}:{{.GitShortSHA}}'
+
+# Print the effective configuration of the build as YAML, without building
+$ s2i build . centos/ruby-22-centos7 hello-world-app --explain=yaml
 `,
 		Run: func(cmd *cobra.Command, args []string) {
 			log.V(1).Infof("Running S2I version %q\n", version.Get())
 
+			// Flags not given on the command line may be set by S2I_* environment
+			// variables, which take precedence over the configuration file
+			if err := cmdutil.BindEnvironmentVariables(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+
 			// Attempt to restore the build command from the configuration file
 			if useConfig {
-				config.Restore(cfg, cmd)
+				if err := config.Restore(cfg, cmd); err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+					return
+				}
+			}
+
+			// The user configuration file sets the flags given nowhere else
+			if err := cmdutil.BindUserConfig(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
 			}
 
-			// If user specifies the arguments, then we override the stored ones
-			if len(args) >= 2 {
-				source, err := git.Parse(args[0])
+			// If user specifies the arguments, then we override the stored ones.
+			// The arguments may also be given by S2I_SOURCE, S2I_BUILDER_IMAGE
+			// and S2I_TAG. Binary builds have no source, so their arguments start
+			// with the builder image.
+			sourceArg := cmdutil.EnvArg(args, 0, "source")
+			builderArg := cmdutil.EnvArg(args, 1, "builder-image")
+			if len(cfg.ArtifactPath) > 0 {
+				if builderArg := cmdutil.EnvArg(args, 0, "builder-image"); len(builderArg) > 0 {
+					cfg.Source = nil
+					cfg.BuilderImage = builderArg
+					if tagArg := cmdutil.EnvArg(args, 1, "tag"); len(tagArg) > 0 {
+						cfg.Tag = tagArg
+					}
+					if len(args) > 2 {
+						cfg.AdditionalTags = append(cfg.AdditionalTags, args[2:]...)
+					}
+				}
+			} else if len(sourceArg) > 0 && len(builderArg) > 0 {
+				source, err := git.Parse(sourceArg)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "ERROR: couldn't parse %q: %v\n", args[0], err)
+					fmt.Fprintf(os.Stderr, "ERROR: couldn't parse %q: %v\n", sourceArg, err)
 					return
 				}
 				cfg.Source = source
 				cfg.Source.URL.Fragment = ref
-				cfg.BuilderImage = args[1]
-				if len(args) >= 3 {
-					cfg.Tag = args[2]
+				cfg.BuilderImage = builderArg
+				if tagArg := cmdutil.EnvArg(args, 2, "tag"); len(tagArg) > 0 {
+					cfg.Tag = tagArg
+				}
+				if len(args) > 3 {
+					cfg.AdditionalTags = append(cfg.AdditionalTags, args[3:]...)
 				}
 			}
 
-			if len(cfg.AsDockerfile) > 0 {
-				if cfg.RunImage {
-					fmt.Fprintln(os.Stderr, "ERROR: --run cannot be used with --as-dockerfile")
+			if len(sourcesFile) > 0 {
+				sources, err := scm.ReadSourcesFile(sourcesFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 					return
 				}
-				if len(cfg.RuntimeImage) > 0 {
-					fmt.Fprintln(os.Stderr, "ERROR: --runtime-image cannot be used with --as-dockerfile")
+				cfg.Sources = append(cfg.Sources, sources...)
+			}
+
+			if len(explain) > 0 {
+				if err := describe.ValidateFormat(explain); err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 					return
 				}
 			}
 
-			if cfg.Incremental && len(cfg.RuntimeImage) > 0 {
-				fmt.Fprintln(os.Stderr, "ERROR: Incremental build with runtime image isn't supported")
-				return
-			}
 			//set default image pull policy
 			if len(cfg.BuilderPullPolicy) == 0 {
 				cfg.BuilderPullPolicy = api.DefaultBuilderPullPolicy
@@ -96,6 +160,25 @@ $ s2i build . centos/ruby-22-centos7 hello-world-app
 			if len(cfg.RuntimeImagePullPolicy) == 0 {
 				cfg.RuntimeImagePullPolicy = api.DefaultRuntimeImagePullPolicy
 			}
+			// hermetic builds run without network
+			if cfg.Hermetic && len(cfg.DockerNetworkMode) == 0 {
+				cfg.DockerNetworkMode = api.DockerNetworkModeNone
+			}
+
+			for script, scriptURL := range scriptURLs {
+				if len(*scriptURL) == 0 {
+					continue
+				}
+				if cfg.ScriptURLs == nil {
+					cfg.ScriptURLs = map[string]string{}
+				}
+				cfg.ScriptURLs[script] = *scriptURL
+			}
+
+			if err := readLiteralInjections(cfg.LiteralInjections, os.Stdin); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
 
 			if errs := validation.ValidateConfig(cfg); len(errs) > 0 {
 				for _, e := range errs {
@@ -103,17 +186,18 @@ $ s2i build . centos/ruby-22-centos7 hello-world-app
 				}
 				fmt.Println()
 				cmd.Help()
-				return
+				os.Exit(s2ierr.ExitCodeValidation)
 			}
 
-			// Persists the current command line options and config into .s2ifile
-			if useConfig {
+			// Persists the current command line options and config into .s2ifile,
+			// unless the build is only explained
+			if useConfig && len(explain) == 0 {
 				config.Save(cfg, cmd)
 			}
 
 			// Attempt to read the .dockercfg and extract the authentication for
 			// docker pull
-			if r, err := os.Open(cfg.DockerCfgPath); err == nil {
+			if r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath)); err == nil {
 				defer r.Close()
 				auths := docker.LoadImageRegistryAuth(r)
 				cfg.PullAuthentication = docker.GetImageRegistryAuth(auths, cfg.BuilderImage)
@@ -124,10 +208,15 @@ $ s2i build . centos/ruby-22-centos7 hello-world-app
 					cfg.RuntimeAuthentication = docker.GetImageRegistryAuth(auths, cfg.RuntimeImage)
 				}
 			}
+			cfg.PullAuthentication = docker.OverrideImageRegistryAuth(cfg.PullAuthentication, cfg.PullCredentials, cfg.BuilderImage, "--pull-auth")
+			cfg.IncrementalAuthentication = docker.OverrideImageRegistryAuth(cfg.IncrementalAuthentication, cfg.IncrementalCredentials, cfg.Tag, "--incremental-auth")
+			cfg.RuntimeAuthentication = docker.OverrideImageRegistryAuth(cfg.RuntimeAuthentication, cfg.RuntimeCredentials, cfg.RuntimeImage, "--runtime-auth")
 
 			if len(cfg.EnvironmentFile) > 0 {
-				result, err := util.ReadEnvironmentFile(cfg.EnvironmentFile)
-				if err != nil {
+				result, err := util.ReadEnvironmentFile(cfg.EnvironmentFile, cfg.StrictEnvironmentFile)
+				if err != nil && cfg.StrictEnvironmentFile {
+					log.Fatalf("Unable to read environment file %q: %v", cfg.EnvironmentFile, err)
+				} else if err != nil {
 					log.Warningf("Unable to read environment file %q: %v", cfg.EnvironmentFile, err)
 				} else {
 					for name, value := range result {
@@ -145,14 +234,21 @@ $ s2i build . centos/ruby-22-centos7 hello-world-app
 				cfg.Destination = oldDestination
 			}
 
-			if networkMode != "" {
-				cfg.DockerNetworkMode = api.DockerNetworkMode(networkMode)
+			if len(explain) > 0 {
+				explainConfig(cfg, explain)
+				return
+			}
+
+			if cfg.Executor == api.ExecutorKubernetes {
+				buildInKubernetes(cfg, resultFile)
+				return
 			}
 
-			client, err := docker.NewEngineAPIClient(cfg.DockerConfig)
+			client, err := docker.NewClient(cfg.DockerConfig)
 			if err != nil {
 				log.Fatal(err)
 			}
+			log.V(1).Infof("Invocation ID: %s", docker.InvocationID())
 
 			if len(cfg.AsDockerfile) == 0 {
 				d := docker.New(client, cfg.PullAuthentication)
@@ -162,22 +258,75 @@ $ s2i build . centos/ruby-22-centos7 hello-world-app
 				}
 			}
 
-			err = manageConfigImageLabelsBuildImageName(context.Background(), cfg)
-			if err != nil {
-				log.Warningf("could not inspect the builder image for labels: %s", err.Error())
+			// the labels are read from the registry of the builder image
+			if !cfg.DockerConfig.Offline {
+				err = manageConfigImageLabelsBuildImageName(context.Background(), cfg)
+				if err != nil {
+					log.Warningf("could not inspect the builder image for labels: %s", err.Error())
+				}
+			}
+			// the Dockerfile runs the scripts as the user given by the labels of
+			// the builder image, which may only exist in the container engine
+			if len(cfg.AsDockerfile) > 0 && cfg.BuilderImageLabels == nil {
+				if err := inspectLocalBuilderImage(client, cfg); err != nil {
+					log.Warningf("could not inspect the builder image %s, the scripts of the Dockerfile run as the default assemble user: %v", cfg.BuilderImage, err)
+				}
 			}
 
 			log.V(2).Infof("\n%s\n", describe.Config(client, cfg))
 
-			builder, _, err := strategies.Strategy(client, cfg, build.Overrides{})
-			s2ierr.CheckError(err)
-			result, err := builder.Build(cfg)
+			tty := progress.Enable(cfg.Progress, os.Stderr)
+			if tty {
+				// the status lines of the steps replace the output of the scripts
+				cfg.Quiet = true
+			}
+			if l, ok := log.(*utillog.FileLogger); ok {
+				l.SetPrefixes(useColor(cfg.Color, os.Stderr))
+				if tty {
+					l.SetOutput(progress.Output(os.Stderr))
+				}
+			}
+
+			startTime := time.Now()
+			builder, buildInfo, err := strategies.Strategy(client, cfg, build.Overrides{})
+			if err != nil {
+				progress.Finish(err)
+				exportTrace(cfg, &api.Result{BuildInfo: buildInfo}, startTime)
+				reportUsage(cfg, buildInfo, startTime)
+				s2ierr.CheckError(classifyBuildError(err))
+			}
+			// on SIGTERM or SIGINT, the signal is forwarded to the build
+			// containers and the temporary resources are removed before exiting
+			defer interrupt.Register(func(s os.Signal) {
+				log.V(0).Infof("Build interrupted by %v", s)
+			})()
+			var result *api.Result
+			err = interrupt.New(interrupt.Terminate).Run(func() error {
+				var buildErr error
+				result, buildErr = builder.Build(cfg)
+				return buildErr
+			})
+			progress.Finish(err)
+			if result != nil {
+				// the builder image is pulled before the build starts
+				result.BuildInfo.Stages = api.MergeStageInfo(buildInfo.Stages, result.BuildInfo.Stages)
+				exportTrace(cfg, result, startTime)
+				reportUsage(cfg, result.BuildInfo, startTime)
+			}
+			if len(resultFile) > 0 && result != nil {
+				if err := writeResult(resultFile, result); err != nil {
+					log.Warningf("Unable to write the result of the build to %s: %v", resultFile, err)
+				}
+			}
 			if err != nil {
 				log.V(0).Infof("Build failed")
-				s2ierr.CheckError(err)
+				log.V(1).Infof("The resources left by the build can be removed with: s2i cleanup --invocation %s", docker.InvocationID())
+				s2ierr.CheckError(classifyBuildError(err))
 			} else {
 				if len(cfg.AsDockerfile) > 0 {
 					log.V(0).Infof("Application dockerfile generated in %s", cfg.AsDockerfile)
+				} else if cfg.DryRun {
+					log.V(0).Infof("Dry run completed, the runtime image was not built")
 				} else {
 					log.V(0).Infof("Build completed successfully")
 
@@ -188,6 +337,11 @@ $ s2i build . centos/ruby-22-centos7 hello-world-app
 				log.V(1).Infof(message)
 			}
 
+			if len(cfg.ComposeFile) > 0 {
+				err = updateComposeFile(client, cfg)
+				s2ierr.CheckError(err)
+			}
+
 			if cfg.RunImage {
 				runner := run.New(client, cfg)
 				err = runner.Run(cfg)
@@ -199,32 +353,269 @@ $ s2i build . centos/ruby-22-centos7 hello-world-app
 	cmdutil.AddCommonFlags(buildCmd, cfg)
 
 	buildCmd.Flags().BoolVar(&(cfg.RunImage), "run", false, "Run resulting image as part of invocation of this command")
+	buildCmd.Flags().StringArrayVar(&(cfg.RunPublish), "run-publish", []string{}, "Publish a port of the container running the resulting image on the host, in [ip:][hostPort:]containerPort[/protocol] format, can be used multiple times")
+	buildCmd.Flags().Var(&(cfg.RunEnvironment), "run-env", "Specify an environment variable of the container running the resulting image in NAME=VALUE format, can be used multiple times")
+	buildCmd.Flags().BoolVar(&(cfg.RunDetach), "run-detach", false, "Leave the container running the resulting image in the background")
 	buildCmd.Flags().BoolVar(&(cfg.IgnoreSubmodules), "ignore-submodules", false, "Ignore all git submodules when cloning application repository")
+	buildCmd.Flags().BoolVar(&(cfg.VerifyCommitSignature), "verify-commit-signature", false, "Verify the GPG signature of the commit, or of the annotated tag, checked out from the application repository, failing the build when it is invalid")
+	buildCmd.Flags().StringVar(&(cfg.Keyring), "keyring", "", "Specify the file of the public keys trusted to sign the commit with --verify-commit-signature (defaults to the GPG keyring of the user)")
+	buildCmd.Flags().BoolVar(&(cfg.PartialClone), "partial-clone", false, "Clone the application repository without the file contents of its history, using the Git protocol v2, when the Git server supports it")
 	buildCmd.Flags().VarP(&(cfg.Environment), "env", "e", "Specify an single environment variable in NAME=VALUE format")
+	buildCmd.Flags().Var(&(cfg.RuntimeEnvironment), "runtime-env", "Specify an environment variable in NAME=VALUE format passed only to the assemble-runtime script and the runtime image, instead of the build environment")
 	buildCmd.Flags().StringVarP(&(ref), "ref", "r", "", "Specify a ref to check-out")
 	buildCmd.Flags().StringVarP(&(cfg.AssembleUser), "assemble-user", "", "", "Specify the user to run assemble with")
 	buildCmd.Flags().StringVarP(&(cfg.AssembleRuntimeUser), "assemble-runtime-user", "", "", "Specify the user to run assemble-runtime with")
 	buildCmd.Flags().StringVarP(&(cfg.ContextDir), "context-dir", "", "", "Specify the sub-directory inside the repository with the application sources")
 	buildCmd.Flags().StringVarP(&(cfg.ExcludeRegExp), "exclude", "", tar.DefaultExclusionPattern.String(), "Regular expression for selecting files from the source tree to exclude from the build, where the default excludes the '.git' directory (see https://golang.org/pkg/regexp for syntax, but note that \"\" will be interpreted as allow all files and exclude no files)")
+	buildCmd.Flags().StringArrayVar(&(cfg.ExcludeGlobs), "exclude-glob", []string{}, "Specify a gitignore style pattern of files from the source tree to exclude from the build, can be used multiple times")
+	buildCmd.Flags().StringArrayVar(&(cfg.RenderTemplates), "render-templates", []string{}, "Specify a gitignore style pattern of source files rendered as Go templates with the environment of the build before the upload, can be used multiple times")
+	buildCmd.Flags().StringArrayVar(&(cfg.IncludeGlobs), "include-glob", []string{}, "Specify a gitignore style pattern of files excluded by --exclude-glob to include in the build again, can be used multiple times")
 	buildCmd.Flags().StringVar(&(cfg.ImageScriptsURL), "image-scripts-url", "image:///usr/libexec/s2i", "Specify a URL containing the default assemble and run scripts for the builder image")
 	buildCmd.Flags().StringVarP(&(cfg.ScriptsURL), "scripts-url", "s", "", "Specify a URL for the assemble, assemble-runtime and run scripts")
+	buildCmd.Flags().StringVar(&(cfg.DownloadCacheDir), "download-cache-dir", "", "Specify a directory to cache the scripts downloaded over http(s) in, revalidating them with their ETag and resuming interrupted downloads")
+	buildCmd.Flags().DurationVar(&(cfg.DownloadTimeout), "download-timeout", 0, "Specify the time each attempt to download a script over http(s) is allowed to take, e.g. 5m (0 for no timeout)")
+	for _, script := range []string{constants.Assemble, constants.Run, constants.SaveArtifacts, constants.AssembleRuntime} {
+		scriptURLs[script] = buildCmd.Flags().String(script+"-script", "", fmt.Sprintf("Specify a URL for the %s script, taking precedence over --scripts-url, the sources and the builder image", script))
+	}
 	buildCmd.Flags().StringVar(&(oldScriptsFlag), "scripts", "", "DEPRECATED: Specify a URL for the assemble and run scripts")
 	buildCmd.Flags().BoolVar(&(useConfig), "use-config", false, "Store command line options to .s2ifile")
-	buildCmd.Flags().StringVarP(&(cfg.EnvironmentFile), "environment-file", "E", "", "Specify the path to the file with environment")
+	buildCmd.Flags().StringVarP(&(cfg.EnvironmentFile), "environment-file", "E", "", "Specify the path to the file with environment, in the dotenv format or, with the .yaml or .yml extension, as a YAML map")
+	buildCmd.Flags().BoolVar(&(cfg.StrictEnvironmentFile), "strict-environment-file", false, "Fail when the environment file has a malformed line, instead of skipping it with a warning")
 	buildCmd.Flags().StringVarP(&(cfg.DisplayName), "application-name", "n", "", "Specify the display name for the application (default: output image name)")
 	buildCmd.Flags().StringVarP(&(cfg.Description), "description", "", "", "Specify the description of the application")
 	buildCmd.Flags().VarP(&(cfg.AllowedUIDs), "allowed-uids", "u", "Specify a range of allowed user ids for the builder and runtime images")
-	buildCmd.Flags().VarP(&(cfg.Injections), "inject", "i", "Specify a directory to inject into the assemble container")
+	buildCmd.Flags().BoolVar(&(cfg.AllowOnBuild), "allow-onbuild", false, "Allow the ONBUILD instructions of the builder image to be executed, which are printed before the build")
+	buildCmd.Flags().StringArrayVar(&(cfg.OnBuildAllowlist), "onbuild-allowlist", []string{}, "Specify a regular expression the ONBUILD instructions of the builder image must match in full to be executed with --allow-onbuild, can be used multiple times")
+	buildCmd.Flags().VarP(&(cfg.Injections), "inject", "i", "Specify a directory or a file to inject into the assemble container")
+	buildCmd.Flags().StringArrayVar(&(cfg.KeepInjections), "keep-injection", []string{}, "Specify the path of an injected file, or directory, in the assemble container to keep in the resulting image instead of truncating it, can be used multiple times")
+	buildCmd.Flags().IntVar(&(cfg.InjectionParallelism), "inject-parallelism", api.DefaultInjectionParallelism, "Specify the number of injections uploaded at the same time into the assemble container")
+	buildCmd.Flags().Var(&(cfg.LiteralInjections), "inject-literal", "Specify a file to inject into the assemble container, as name=VALUE:destination, VALUE - reads it from stdin")
 	buildCmd.Flags().StringArrayVarP(&(cfg.BuildVolumes), "volume", "v", []string{}, "Specify a volume to mount into the assemble container")
+	buildCmd.Flags().Var(&(cfg.Caches), "cache", "Specify a dependency cache mounted into the assemble container, in the mount=dir[,key=sha256(file[,file...])] form, reusing the volume of the builds whose key files have the same content. Can be used multiple times")
 	buildCmd.Flags().StringSliceVar(&(cfg.DropCapabilities), "cap-drop", []string{}, "Specify a comma-separated list of capabilities to drop when running Docker containers")
 	buildCmd.Flags().StringVarP(&(oldDestination), "location", "l", "",
 		"DEPRECATED: Specify a destination location for untar operation")
+	buildCmd.Flags().Var(&(cfg.Sources), "source", "Specify an additional source, url[#ref]:directory, downloaded into the directory of the sources, can be used multiple times")
+	buildCmd.Flags().SetAnnotation("source", cmdutil.NoEnvAnnotation, []string{"true"})
+	buildCmd.Flags().StringVar(&sourcesFile, "sources-file", "", "Specify the path of a YAML file listing additional sources downloaded into directories of the sources")
+	buildCmd.Flags().StringVar(&(cfg.ArtifactPath), "from-artifact", "", "Specify the path of a prebuilt artifact, a file or a directory, to upload for the assemble script instead of the sources, in which case the <source> argument is omitted")
 	buildCmd.Flags().BoolVarP(&(cfg.ForceCopy), "copy", "c", false, "Use local file system copy instead of git cloning the source url")
 	buildCmd.Flags().StringVar(&(cfg.RuntimeImage), "runtime-image", "", "Image that will be used as the base for the runtime image")
-	buildCmd.Flags().VarP(&(cfg.RuntimeArtifacts), "runtime-artifact", "a", "Specify a file or directory to be copied from the builder to the runtime image")
-	buildCmd.Flags().StringVar(&(networkMode), "network", "", "Specify the default Docker Network name to be used in build process")
+	buildCmd.Flags().VarP(&(cfg.RuntimeArtifacts), "runtime-artifact", "a", "Specify a file or directory, or a glob pattern matching them, to be copied from the builder to the runtime image")
+	buildCmd.Flags().BoolVar(&(cfg.DryRun), "dry-run", false, "Run the assemble script and print the runtime artifacts which would be copied to the runtime image, without building it")
+	buildCmd.Flags().StringVar(&explain, "explain", "", "Print the effective configuration of the build, including the settings taken from the labels of the builder and runtime images, with the secrets redacted, in this format (table, json or yaml) without building")
+	buildCmd.Flags().Lookup("explain").NoOptDefVal = describe.FormatTable
+	buildCmd.Flags().StringArrayVar(&(cfg.AdditionalTags), "additional-tag", nil, "Specify an additional tag of the resulting image, can be used multiple times")
+	buildCmd.Flags().StringArrayVar(&(cfg.TagTemplates), "tag-template", nil, "Specify a Go template of an additional tag of the resulting image, e.g. '{{.Repo}}:{{.GitShortSHA}}', can be used multiple times")
+	buildCmd.Flags().Var(&(cfg.AutoTag), "auto-tag", "Derive the tag of the resulting image from the sources: their git describe output (git-describe), abbreviated commit (commit) or branch (branch)")
+	buildCmd.Flags().StringVar(&(cfg.ReportsDir), "reports-dir", "", "Extract the test reports written by the assemble script, in the directory named by the "+constants.ReportsLabel+" label of the builder image, to this directory")
+	buildCmd.Flags().Var(&(cfg.DockerNetworkMode), "network", "Specify the network of the containers running the S2I scripts (none, bridge, host, container:<name|id> or netns:/proc/<pid>/ns/net); with none, the build runs offline")
+	buildCmd.Flags().BoolVar(&(cfg.Hermetic), "hermetic", false, "Run the assemble scripts without network and fail the build if they attempt outbound network access")
 	buildCmd.Flags().StringVarP(&(cfg.AsDockerfile), "as-dockerfile", "", "", "EXPERIMENTAL: Output a Dockerfile to this path instead of building a new image")
+	buildCmd.Flags().Var(&(cfg.Progress), "progress", "Specify how the progress of the build is displayed: a live status line for each step (tty), line-based logs (plain), or tty when the output is a terminal (auto)")
+	buildCmd.Flags().Var(&(cfg.Color), "color", "Specify whether the prefixes telling the messages of s2i and the output of the containers apart are colored: always, never, or when the output is a terminal and NO_COLOR is not set (auto)")
+	buildCmd.Flags().BoolVar(&(cfg.SkipDiskCheck), "skip-disk-check", false, "Skip checking, before pulling the images and fetching the sources, that the file systems have room for them")
+	buildCmd.Flags().Var(&(cfg.Strategy), "strategy", "Specify the strategy building the image: the S2I scripts of the builder image (source), a docker build executing its ONBUILD instructions (onbuild), a Dockerfile written to --as-dockerfile (dockerfile), or the one selected from the builder image and --as-dockerfile (auto)")
+	buildCmd.Flags().Var(&(cfg.LayeredFallback), "layered-fallback", "Specify when to layer the scripts and sources on top of the builder image with a docker build: when the builder image is missing sh or tar (auto), never, failing the build instead (never), or always (always)")
+	buildCmd.Flags().BoolVar(&(cfg.KeepLayeredImage), "keep-layered-image", false, "Keep the intermediate image produced by a layered build instead of removing it after the build")
 	buildCmd.Flags().BoolVarP(&(cfg.KeepSymlinks), "keep-symlinks", "", false, "When using '--copy', copy symlinks as symlinks. Default behavior is to follow symlinks and copy files by content")
+	buildCmd.Flags().Var(&(cfg.ContextCompression), "context-compression", "Specify the compression of the build context sent to the container engine for layered and ONBUILD builds (none, gzip, zstd or auto)")
+	buildCmd.Flags().StringVar(&(cfg.RestoreArtifacts), "restore-artifacts", "", "Provide the build artifacts of this archive, written by s2i save-artifacts, to the assemble script instead of the ones of the previous image")
+	buildCmd.Flags().Var(&(cfg.SaveArtifactsCompression), "save-artifacts-compression", "Specify the compression requested from the save-artifacts script of incremental builds, among the ones the image supports (none, gzip, zstd or auto)")
+	buildCmd.Flags().Var(&(cfg.SymlinkPolicy), "symlink-policy", "Specify how symbolic links pointing outside of the source tree are handled (preserve, rewrite or error)")
+	buildCmd.Flags().Var(&(cfg.ConvertCRLF), "convert-crlf", "Specify the text files whose CRLF line endings are converted to LF when they are uploaded (scripts, all or off)")
+	buildCmd.Flags().StringSliceVar(&(cfg.Ignorers), "ignorers", []string{api.IgnorerS2I}, "Specify a comma-separated list of ignore file processors applied to the source tree (s2iignore, gitignore)")
+	buildCmd.Flags().Var(&(cfg.BuildArgs), "build-arg", "Specify a build-time variable in NAME=VALUE format passed to the layered and ONBUILD docker builds and declared and set in the environment of the generated Dockerfile, can be used multiple times")
+	buildCmd.Flags().Var(&(cfg.Scanner), "scan", "Scan the resulting image for vulnerabilities using this scanner (trivy or grype)")
+	buildCmd.Flags().Var(&(cfg.ScanSeverityThreshold), "scan-severity-threshold", "Fail the build when the vulnerability scan finds vulnerabilities of this severity or higher (low, medium, high or critical)")
+	buildCmd.Flags().StringVar(&(cfg.PostCommitCommand), "post-commit-cmd", "", "Run this shell command in a container of the resulting image, e.g. \"curl -f localhost:8080/health\", and fail the build, removing the image, when it does not succeed")
+	buildCmd.Flags().DurationVar(&(cfg.PostCommitTimeout), "post-commit-timeout", run.DefaultSmokeTestTimeout, "Specify the time the --post-commit-cmd command is retried for while the application starts")
+	buildCmd.Flags().BoolVar(&(cfg.VerifyRunScript), "verify-run-script", false, "Run a container of the resulting image briefly, and fail the build, removing the image, when it does not start or exits with an error")
+	buildCmd.Flags().DurationVar(&(cfg.VerifyRunScriptPeriod), "verify-run-script-period", run.DefaultVerifyRunScriptPeriod, "Specify the time the container of --verify-run-script has to keep running")
+	buildCmd.Flags().BoolVar(&(cfg.DiffReport), "diff-report", false, "Print the files added, removed and changed in the working directory of the image and the size of its layers compared to the previous image of the tag")
+	buildCmd.Flags().DurationVar(&(cfg.MaxBuildDuration), "max-build-duration", 0, "Abort the build when it runs longer than the specified duration, e.g. 30m (0 means no limit)")
+	buildCmd.Flags().Int64Var(&(cfg.MaxOutputSize), "max-output-size", 0, "Fail the build, before the image is tagged, when the layers it adds are larger than the specified size in megabytes (0 means no limit)")
+	buildCmd.Flags().IntVar(&(cfg.CommitRetries), "commit-retries", api.DefaultCommitRetries, "Specify the number of times a failed commit of the build container is retried, with an exponential backoff")
+	buildCmd.Flags().BoolVar(&(cfg.CommitExportFallback), "commit-export-fallback", false, "When the commit of the build container keeps failing, create the image by exporting and importing the file system of the container instead, flattened to a single layer")
+	buildCmd.Flags().DurationVar(&(cfg.ShutdownGracePeriod), "shutdown-grace-period", api.DefaultShutdownGracePeriod, "Specify the time given to the build containers to exit after a SIGTERM or SIGINT received by s2i is forwarded to them, before they are killed")
+	buildCmd.Flags().StringVar(&(cfg.PolicyDir), "policy-dir", "", "Evaluate the OPA Rego policies of this directory against the build configuration and the builder image, and reject the build when their data.s2i.deny set is not empty")
+	buildCmd.Flags().StringVar(&(cfg.LockFile), "lockfile", "", "Write the inputs resolved by the build (image IDs, source commit, scripts checksums and environment variable names) to this lockfile, or verify them against it with --locked (defaults to "+lock.DefaultFile+")")
+	buildCmd.Flags().BoolVar(&(cfg.Locked), "locked", false, "Fail the build if the resolved inputs differ from the ones recorded in the lockfile")
+	buildCmd.Flags().StringVar(&(cfg.ComposeFile), "compose-file", "", "Add a service running the resulting image, with its exposed ports and the --run-publish and --run-env options, to this Docker Compose file")
+	buildCmd.Flags().StringVar(&(cfg.ComposeService), "compose-service", "", "Specify the name of the service added to the --compose-file (defaults to the last directory of --context-dir, or the name of the image)")
+	buildCmd.Flags().StringVar(&(resultFile), "result-file", "", "Write the result of the build, including the resources it consumed, as JSON to this file")
+	buildCmd.Flags().Var(&(cfg.Executor), "executor", "Specify where the build is executed: by the local container engine (local), or in a pod of a Kubernetes cluster pushing the resulting image (kubernetes)")
+	buildCmd.Flags().StringVar(&(cfg.Kubernetes.Kubeconfig), "kubeconfig", "", "Specify the kubeconfig file of the cluster of the kubernetes executor (defaults to the in-cluster configuration, $KUBECONFIG or ~/.kube/config)")
+	buildCmd.Flags().StringVar(&(cfg.Kubernetes.Namespace), "kubernetes-namespace", "", "Specify the namespace of the pods of the kubernetes executor (defaults to the namespace of the current context)")
+	buildCmd.Flags().StringVar(&(cfg.Kubernetes.S2IImage), "kubernetes-s2i-image", generate.DefaultS2IImage, "Specify the image running s2i in the pods of the kubernetes executor")
+	buildCmd.Flags().StringVar(&(cfg.Kubernetes.BuildahImage), "kubernetes-buildah-image", generate.DefaultBuildahImage, "Specify the image building and pushing the image with buildah in the pods of the kubernetes executor")
+	buildCmd.Flags().StringVar(&(cfg.Kubernetes.PushSecret), "push-secret", "", "Specify the name of the kubernetes.io/dockerconfigjson secret holding the credentials of the registry the kubernetes executor pushes the image to")
+	buildCmd.Flags().Var(&(cfg.PushCredentials), "push-auth", "Specify the credentials the kubernetes executor pushes the image with, in the user:password[@registry] form, instead of a --push-secret secret")
+	buildCmd.Flags().StringVar(&(cfg.TelemetryEndpoint), "telemetry-endpoint", "", "Opt in to telemetry: post an anonymized report of the build (strategy, engine, result and duration bucket) to this URL")
 	buildCmd.Flags().StringArrayVar(&cfg.AddHost, "add-host", []string{}, "Specify additional entries to add to the /etc/hosts in the assemble container, multiple --add-host can be used to add multiple entries")
 	return buildCmd
 }
+
+// explainConfig prints the effective configuration of the build in the given
+// format, with the settings left to the labels of the builder and runtime
+// images resolved from them, without building. The labels are not resolved
+// for the kubernetes executor, which has no local container engine.
+func explainConfig(cfg *api.Config, format string) {
+	var client docker.Client
+	if cfg.Executor != api.ExecutorKubernetes && len(cfg.AsDockerfile) == 0 {
+		c, err := docker.NewClient(cfg.DockerConfig)
+		s2ierr.CheckError(err)
+		client = c
+	}
+	resolved, fromLabels, err := describe.Resolve(client, cfg)
+	s2ierr.CheckError(err)
+	out, err := describe.ConfigAs(client, resolved, fromLabels, format)
+	s2ierr.CheckError(err)
+	fmt.Print(out)
+}
+
+// updateComposeFile adds the service running the built image to the compose
+// file of the configuration.
+// classifyBuildError classifies the error of a build which does not tell its
+// class of failures with the reason the build failed for.
+func classifyBuildError(err error) error {
+	if s2ierr.ExitCode(err) != s2ierr.ExitCodeFailure {
+		return err
+	}
+	reason, _ := utilstatus.FailureReasonOf(err)
+	return s2ierr.NewExitCodeError(utilstatus.ExitCode(reason.Reason), err)
+}
+
+// readLiteralInjections reads the value of the literal injection given as "-"
+// from r. Only one literal injection can be read from r.
+func readLiteralInjections(literals api.LiteralInjectionList, r io.Reader) error {
+	read := false
+	for i := range literals {
+		if literals[i].Value != "-" {
+			continue
+		}
+		if read {
+			return errors.New("only one --inject-literal can read its value from stdin")
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("unable to read the value of --inject-literal %q from stdin: %v", literals[i].Name, err)
+		}
+		literals[i].Value = string(data)
+		read = true
+	}
+	return nil
+}
+
+// useColor returns whether the output written to w is colored in the given
+// mode, honoring the NO_COLOR convention (https://no-color.org) in auto mode.
+func useColor(mode api.ColorMode, w io.Writer) bool {
+	switch mode {
+	case api.ColorAlways:
+		return true
+	case api.ColorNever:
+		return false
+	}
+	return len(os.Getenv("NO_COLOR")) == 0 && utillog.IsTerminal(w)
+}
+
+func updateComposeFile(client docker.Client, cfg *api.Config) error {
+	ports, err := docker.New(client, cfg.PullAuthentication).GetImageExposedPorts(cfg.Tag)
+	if err != nil {
+		return err
+	}
+	name := generate.ComposeServiceName(cfg)
+	if err := generate.UpdateComposeFile(cfg.ComposeFile, name, generate.NewComposeService(cfg, ports)); err != nil {
+		return err
+	}
+	log.V(0).Infof("Service %s added to %s", name, cfg.ComposeFile)
+	return nil
+}
+
+// buildInKubernetes runs the build of the given configuration in a pod of the
+// Kubernetes cluster of the kubernetes executor, and exits when it fails.
+func buildInKubernetes(cfg *api.Config, resultFile string) {
+	executor, err := kubernetes.New(cfg.Kubernetes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Quiet {
+		executor.Out = ioutil.Discard
+	}
+	result, err := executor.Build(cfg)
+	if len(resultFile) > 0 && result != nil {
+		if err := writeResult(resultFile, result); err != nil {
+			log.Warningf("Unable to write the result of the build to %s: %v", resultFile, err)
+		}
+	}
+	if err != nil {
+		log.V(0).Infof("Build failed")
+		s2ierr.CheckError(err)
+	}
+	log.V(0).Infof("Build completed successfully")
+}
+
+// writeResult writes the given build result as JSON to the given file.
+func writeResult(path string, result *api.Result) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// exportTrace exports the stages of the given build, started at the given
+// time, as an OpenTelemetry trace if an OTLP endpoint is configured.
+func exportTrace(config *api.Config, result *api.Result, startTime time.Time) {
+	if !tracing.Enabled() {
+		return
+	}
+	if config.DockerConfig.Offline {
+		log.V(1).Infof("Not exporting the trace of the build, --offline forbids network access")
+		return
+	}
+	if err := tracing.ExportBuild(config, result, startTime, time.Now()); err != nil {
+		log.Warningf("Unable to export the trace of the build: %v", err)
+	}
+}
+
+// reportUsage sends the anonymized usage report of the given build, started
+// at the given time, if the user opted in to telemetry.
+func reportUsage(config *api.Config, info api.BuildInfo, startTime time.Time) {
+	if len(config.TelemetryEndpoint) == 0 {
+		return
+	}
+	if config.DockerConfig.Offline {
+		log.V(1).Infof("Not reporting the usage of the build, --offline forbids network access")
+		return
+	}
+	report := telemetry.NewReport(config, info, time.Since(startTime))
+	if err := telemetry.Send(config.TelemetryEndpoint, report); err != nil {
+		log.V(1).Infof("Unable to report the usage of the build: %v", err)
+		return
+	}
+	log.V(2).Infof("Reported the usage of the build to %s: %+v", config.TelemetryEndpoint, *report)
+}
+
+// inspectLocalBuilderImage sets the labels, user and ID of the builder image
+// from its inspection by the container engine, for the Dockerfiles generated
+// from builder images which could not be inspected in their registry, e.g.
+// which were never pushed.
+func inspectLocalBuilderImage(client docker.Client, cfg *api.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), docker.DefaultDockerTimeout)
+	defer cancel()
+	inspect, _, err := client.ImageInspectWithRaw(ctx, cfg.BuilderImage)
+	if err != nil {
+		return err
+	}
+	// the labels of an inspected image are never nil
+	cfg.BuilderImageLabels = map[string]string{}
+	if inspect.Config != nil {
+		for k, v := range inspect.Config.Labels {
+			cfg.BuilderImageLabels[k] = v
+		}
+		cfg.BuilderImageUser = inspect.Config.User
+	}
+	cfg.BuilderImageID = inspect.ID
+	return nil
+}
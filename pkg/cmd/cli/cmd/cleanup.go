@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+// NewCmdCleanup implements the S2I cli cleanup command.
+func NewCmdCleanup(cfg *api.Config) *cobra.Command {
+	invocationID := ""
+
+	cleanupCmd := &cobra.Command{
+		Use:   "cleanup --invocation <id>",
+		Short: "Remove the resources left by an aborted build",
+		Long: "Remove the containers, the temporary images and the volumes created by an s2i invocation, " +
+			"as recorded in their " + constants.InvocationIDLabel + " label. The images built by the invocation are kept.",
+		Example: `
+# Remove the resources left by the build whose invocation ID was logged with --loglevel 1
+$ s2i cleanup --invocation 3f2a9c0d1e4b5a67
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(invocationID) == 0 {
+				cmd.Help()
+				return
+			}
+			client, err := docker.NewClient(cfg.DockerConfig)
+			s2ierr.CheckError(err)
+			result, err := docker.Cleanup(client, invocationID)
+			if result != nil {
+				for _, name := range result.Containers {
+					fmt.Fprintf(os.Stdout, "Removed container %s\n", name)
+				}
+				for _, id := range result.Images {
+					fmt.Fprintf(os.Stdout, "Removed image %s\n", id)
+				}
+				for _, name := range result.Volumes {
+					fmt.Fprintf(os.Stdout, "Removed volume %s\n", name)
+				}
+			}
+			s2ierr.CheckError(err)
+		},
+	}
+	cleanupCmd.Flags().StringVar(&invocationID, "invocation", "", "ID of the s2i invocation whose resources are removed")
+	return cleanupCmd
+}
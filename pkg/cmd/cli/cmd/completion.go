@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/spf13/cobra"
 
@@ -12,8 +13,10 @@ import (
 
 var (
 	completionShells = map[string]func(out io.Writer, cmd *cobra.Command) error{
-		"bash": runCompletionBash,
-		"zsh":  runCompletionZsh,
+		"bash":       runCompletionBash,
+		"zsh":        runCompletionZsh,
+		"fish":       runCompletionFish,
+		"powershell": runCompletionPowerShell,
 	}
 )
 
@@ -23,11 +26,19 @@ func NewCmdCompletion(root *cobra.Command) *cobra.Command {
 	for s := range completionShells {
 		shells = append(shells, s)
 	}
+	sort.Strings(shells)
 
 	return &cobra.Command{
 		Use:   "completion SHELL",
-		Short: "Generate completion for the s2i command (bash or zsh)",
-		Long:  "Generate completion for the s2i command into standard output  (bash or zsh)",
+		Short: "Generate completion for the s2i command (bash, zsh, fish or powershell)",
+		Long:  "Generate completion for the s2i command into standard output  (bash, zsh, fish or powershell)",
+		Example: `
+# Load the completion in the current fish session
+$ s2i completion fish | source
+
+# Load the completion in the current PowerShell session
+PS> s2i completion powershell | Out-String | Invoke-Expression
+`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// TODO: The version of cobra we vendor takes a
 			// *bytes.Buffer, while newer versions take an
@@ -46,7 +57,7 @@ func NewCmdCompletion(root *cobra.Command) *cobra.Command {
 	}
 }
 
-// RunCompletion first checks args[0] to decide compose zsh or bash
+// RunCompletion first checks args[0] to decide the shell to compose for
 // then write the content into the out bytes.Buffer
 // if command input error will call UsageError with `cmd cobra.Command`
 // `root cobra.Command` mainly for GenBashCompletion function
@@ -73,6 +84,19 @@ func runCompletionBash(out io.Writer, root *cobra.Command) error {
 	return root.GenBashCompletion(out)
 }
 
+// runCompletionFish writes the fish completion, with the descriptions of the
+// commands and flags, which calls the hidden __complete command of cobra.
+func runCompletionFish(out io.Writer, root *cobra.Command) error {
+	return root.GenFishCompletion(out, true)
+}
+
+// runCompletionPowerShell writes the PowerShell completion, with the
+// descriptions of the commands and flags, which calls the hidden __complete
+// command of cobra.
+func runCompletionPowerShell(out io.Writer, root *cobra.Command) error {
+	return root.GenPowerShellCompletionWithDesc(out)
+}
+
 func runCompletionZsh(out io.Writer, root *cobra.Command) error {
 	zshInitialization := `# Copyright 2016 The Kubernetes Authors.
 #
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/source-to-image/pkg/config"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+// credentialFlags are the flags which are not stored in the user
+// configuration, as they hold secrets.
+var credentialFlags = map[string]bool{"pull-auth": true, "incremental-auth": true, "runtime-auth": true, "inject-literal": true}
+
+// NewCmdConfig implements the S2I cli config command.
+func NewCmdConfig(root *cobra.Command) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "View and set the defaults of the flags for the user",
+		Long: "View and set the defaults of the flags of the s2i commands for the user, stored in " +
+			"$XDG_CONFIG_HOME/s2i/config.yaml (~/.config/s2i/config.yaml by default). The command line " +
+			"flags, the S2I_* environment variables and the .s2ifile of the directory take precedence over them.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	configCmd.AddCommand(newCmdConfigView())
+	configCmd.AddCommand(newCmdConfigSet(root))
+	configCmd.AddCommand(newCmdConfigUnset())
+	return configCmd
+}
+
+// newCmdConfigView implements the S2I cli config view command.
+func newCmdConfigView() *cobra.Command {
+	return &cobra.Command{
+		Use:   "view",
+		Short: "Display the user configuration",
+		Run: func(cmd *cobra.Command, args []string) {
+			userConfig, err := config.LoadUserConfig(config.UserConfigPath())
+			s2ierr.CheckError(err)
+			data, err := yaml.Marshal(userConfig)
+			s2ierr.CheckError(err)
+			os.Stdout.Write(data)
+		},
+	}
+}
+
+// newCmdConfigSet implements the S2I cli config set command.
+func newCmdConfigSet(root *cobra.Command) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <flag> <value>",
+		Short: "Set the default of a flag for the user",
+		Example: `
+# Pull the builder images only when they are missing
+$ s2i config set pull-policy if-not-present
+
+# Run the builds with containerd
+$ s2i config set engine containerd
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 2 {
+				cmd.Help()
+				return
+			}
+			name, value := args[0], args[1]
+			if credentialFlags[name] {
+				s2ierr.CheckError(fmt.Errorf("the --%s secrets are not stored in the user configuration", name))
+			}
+			f := lookupFlag(root, name)
+			if f == nil || name == "help" {
+				s2ierr.CheckError(fmt.Errorf("unknown flag %q", name))
+			}
+			// the flag is only set to validate the value, as the command does
+			// not use it
+			if err := f.Value.Set(value); err != nil {
+				s2ierr.CheckError(fmt.Errorf("invalid value %q of %s: %v", value, name, err))
+			}
+
+			path := config.UserConfigPath()
+			userConfig, err := config.LoadUserConfig(path)
+			s2ierr.CheckError(err)
+			userConfig.Defaults[name] = value
+			s2ierr.CheckError(config.SaveUserConfig(userConfig, path))
+		},
+	}
+}
+
+// newCmdConfigUnset implements the S2I cli config unset command.
+func newCmdConfigUnset() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset <flag>",
+		Short: "Remove the default of a flag for the user",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 1 {
+				cmd.Help()
+				return
+			}
+			path := config.UserConfigPath()
+			userConfig, err := config.LoadUserConfig(path)
+			s2ierr.CheckError(err)
+			if _, ok := userConfig.Defaults[args[0]]; !ok {
+				return
+			}
+			delete(userConfig.Defaults, args[0])
+			s2ierr.CheckError(config.SaveUserConfig(userConfig, path))
+		},
+	}
+}
+
+// lookupFlag returns the flag of the given name of the given command or of
+// any of its subcommands, or nil if there is none.
+func lookupFlag(c *cobra.Command, name string) *pflag.Flag {
+	if f := c.Flags().Lookup(name); f != nil {
+		return f
+	}
+	if f := c.PersistentFlags().Lookup(name); f != nil {
+		return f
+	}
+	for _, sub := range c.Commands() {
+		if f := lookupFlag(sub, name); f != nil {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/validation"
+	"github.com/openshift/source-to-image/pkg/build"
+	"github.com/openshift/source-to-image/pkg/build/strategies"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/run"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+	"github.com/openshift/source-to-image/pkg/util/watch"
+)
+
+// NewCmdDev implements the S2I cli dev command.
+func NewCmdDev(cfg *api.Config) *cobra.Command {
+	interval := time.Second
+	sync := false
+	syncDir := ""
+	rebuildGlobs := []string{}
+
+	devCmd := &cobra.Command{
+		Use:   "dev <source-dir> <image> <tag>",
+		Short: "Rebuild and rerun an image whenever its source changes",
+		Long: "Build a Docker image named <tag> from a local source directory and base image and run it, " +
+			"then incrementally rebuild and restart it whenever the files of the source directory change.",
+		Example: `
+# Build and run the application of the current directory, and publish its port 8080
+$ s2i dev . centos/ruby-22-centos7 hello-world-app --run-publish 8080:8080
+
+# Sync the changed sources into the running application instead of rebuilding it,
+# unless its dependencies change
+$ s2i dev . centos/ruby-22-centos7 hello-world-app --sync --rebuild-glob Gemfile --rebuild-glob Gemfile.lock
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(args) < 3 {
+				cmd.Help()
+				return
+			}
+			if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+				fmt.Fprintf(os.Stderr, "ERROR: %q is not a local directory\n", args[0])
+				return
+			}
+			source, err := git.Parse(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: couldn't parse %q: %v\n", args[0], err)
+				return
+			}
+			cfg.Source = source
+			cfg.BuilderImage = args[1]
+			cfg.Tag = args[2]
+			// the working copy is built, including the changes which were not
+			// committed yet
+			cfg.ForceCopy = true
+			cfg.RunImage = true
+
+			if len(cfg.BuilderPullPolicy) == 0 {
+				cfg.BuilderPullPolicy = api.DefaultBuilderPullPolicy
+			}
+			if len(cfg.PreviousImagePullPolicy) == 0 {
+				cfg.PreviousImagePullPolicy = api.DefaultPreviousImagePullPolicy
+			}
+			if len(cfg.RuntimeImagePullPolicy) == 0 {
+				cfg.RuntimeImagePullPolicy = api.DefaultRuntimeImagePullPolicy
+			}
+			if errs := validation.ValidateConfig(cfg); len(errs) > 0 {
+				for _, e := range errs {
+					fmt.Fprintf(os.Stderr, "ERROR: %s\n", e)
+				}
+				fmt.Println()
+				cmd.Help()
+				os.Exit(s2ierr.ExitCodeValidation)
+			}
+
+			if r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath)); err == nil {
+				defer r.Close()
+				auths := docker.LoadImageRegistryAuth(r)
+				cfg.PullAuthentication = docker.GetImageRegistryAuth(auths, cfg.BuilderImage)
+				cfg.IncrementalAuthentication = docker.GetImageRegistryAuth(auths, cfg.Tag)
+				if len(cfg.RuntimeImage) > 0 {
+					cfg.RuntimeAuthentication = docker.GetImageRegistryAuth(auths, cfg.RuntimeImage)
+				}
+			}
+			cfg.PullAuthentication = docker.OverrideImageRegistryAuth(cfg.PullAuthentication, cfg.PullCredentials, cfg.BuilderImage, "--pull-auth")
+			cfg.IncrementalAuthentication = docker.OverrideImageRegistryAuth(cfg.IncrementalAuthentication, cfg.IncrementalCredentials, cfg.Tag, "--incremental-auth")
+			cfg.RuntimeAuthentication = docker.OverrideImageRegistryAuth(cfg.RuntimeAuthentication, cfg.RuntimeCredentials, cfg.RuntimeImage, "--runtime-auth")
+			if len(cfg.EnvironmentFile) > 0 {
+				result, err := util.ReadEnvironmentFile(cfg.EnvironmentFile, cfg.StrictEnvironmentFile)
+				if err != nil && cfg.StrictEnvironmentFile {
+					log.Fatalf("Unable to read environment file %q: %v", cfg.EnvironmentFile, err)
+				} else if err != nil {
+					log.Warningf("Unable to read environment file %q: %v", cfg.EnvironmentFile, err)
+				} else {
+					for name, value := range result {
+						cfg.Environment = append(cfg.Environment, api.EnvironmentSpec{Name: name, Value: value})
+					}
+				}
+			}
+
+			client, err := docker.NewClient(cfg.DockerConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := docker.New(client, cfg.PullAuthentication).CheckReachable(); err != nil {
+				log.Fatal(err)
+			}
+
+			matcher, err := ignore.NewGlobMatcher(cfg.ExcludeGlobs, cfg.IncludeGlobs)
+			if err != nil {
+				log.Fatal(err)
+			}
+			watcher, err := watch.New(args[0], interval, matcher.Match)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer watcher.Close()
+			rebuildMatcher, err := ignore.NewGlobMatcher(rebuildGlobs, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			runner := run.New(client, cfg)
+			var container *run.Container
+			rebuild := true
+			for {
+				if rebuild {
+					if err := devBuild(client, cfg); err != nil {
+						log.Errorf("Build failed: %v", err)
+					} else {
+						log.V(0).Infof("Build completed successfully")
+						if container != nil {
+							stopRun(container)
+						}
+						container = runner.Start(cfg)
+						// the next builds reuse the artifacts of the previous one
+						if len(cfg.RuntimeImage) == 0 {
+							cfg.Incremental = true
+						}
+					}
+				}
+
+				log.V(0).Infof("Watching %s for changes ...", args[0])
+				changed, err := watcher.Wait()
+				if err != nil {
+					log.Fatal(err)
+				}
+				rebuild = true
+				if sync && container != nil {
+					if err := devSync(client, cfg, container, args[0], changed, syncDir, rebuildMatcher); err != nil {
+						log.V(0).Infof("Unable to sync the changes to %s: %v", strings.Join(changed, ", "), err)
+					} else {
+						log.V(0).Infof("Synced the changes to %s", strings.Join(changed, ", "))
+						rebuild = false
+						continue
+					}
+				}
+				log.V(0).Infof("Rebuilding after changes to %s", strings.Join(changed, ", "))
+			}
+		},
+	}
+
+	cmdutil.AddCommonFlags(devCmd, cfg)
+
+	devCmd.Flags().VarP(&(cfg.Environment), "env", "e", "Specify an single environment variable in NAME=VALUE format")
+	devCmd.Flags().StringVarP(&(cfg.EnvironmentFile), "environment-file", "E", "", "Specify the path to the file with environment, in the dotenv format or, with the .yaml or .yml extension, as a YAML map")
+	devCmd.Flags().BoolVar(&(cfg.StrictEnvironmentFile), "strict-environment-file", false, "Fail when the environment file has a malformed line, instead of skipping it with a warning")
+	devCmd.Flags().StringVar(&(cfg.RuntimeImage), "runtime-image", "", "Image that will be used as the base for the runtime image")
+	devCmd.Flags().StringSliceVar(&(cfg.ExcludeGlobs), "exclude-glob", []string{}, "Specify a gitignore style pattern of files excluded from the build and not watched, can be used multiple times")
+	devCmd.Flags().StringSliceVar(&(cfg.IncludeGlobs), "include-glob", []string{}, "Specify a gitignore style pattern of files included in the build and watched even though they match an --exclude-glob, can be used multiple times")
+	devCmd.Flags().StringArrayVar(&(cfg.RunPublish), "run-publish", []string{}, "Publish a port of the container running the image on the host, in [ip:][hostPort:]containerPort[/protocol] format, can be used multiple times")
+	devCmd.Flags().Var(&(cfg.RunEnvironment), "run-env", "Specify an environment variable of the container running the image in NAME=VALUE format, can be used multiple times")
+	devCmd.Flags().DurationVar(&interval, "poll-interval", interval, "Specify how often the source directory is checked for changes when file system notifications are unavailable, and how long to wait for more changes")
+	devCmd.Flags().BoolVar(&sync, "sync", false, "Upload the changed files into the running container and run the reload script of the image instead of rebuilding it, when possible")
+	devCmd.Flags().StringVar(&syncDir, "sync-dir", "", "Specify the directory of the container the changed files are uploaded to (defaults to the working directory of the image)")
+	devCmd.Flags().StringSliceVar(&rebuildGlobs, "rebuild-glob", []string{}, "Specify a gitignore style pattern of files whose changes are never synced but rebuild the image, can be used multiple times")
+	return devCmd
+}
+
+// devBuild builds the image of the dev command.
+func devBuild(client docker.Client, cfg *api.Config) error {
+	// each build works in a new temporary directory
+	cfg.WorkingDir = ""
+	builder, _, err := strategies.Strategy(client, cfg, build.Overrides{})
+	if err != nil {
+		return err
+	}
+	result, err := builder.Build(cfg)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New("the build did not succeed")
+	}
+	return nil
+}
+
+// devSync syncs the changed files of the source directory into the container
+// running the image of the dev command, unless they must be rebuilt.
+func devSync(client docker.Client, cfg *api.Config, container *run.Container, source string, changed []string, destination string, rebuild *ignore.GlobMatcher) error {
+	for _, path := range changed {
+		info, err := os.Stat(filepath.Join(source, filepath.FromSlash(path)))
+		if rebuild.Match(path, err == nil && info.IsDir()) {
+			return fmt.Errorf("%s must be rebuilt", path)
+		}
+	}
+	if len(destination) == 0 {
+		workdir, err := docker.New(client, cfg.PullAuthentication).GetImageWorkdir(cfg.Tag)
+		if err != nil {
+			return err
+		}
+		destination = workdir
+	}
+	return container.Sync(source, changed, destination)
+}
+
+// stopRun stops the container running the previous image of the dev command.
+func stopRun(container *run.Container) {
+	if err := container.Stop(); err != nil {
+		log.Warningf("The application exited: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+)
+
+// doctorReport is the JSON output of the doctor command.
+type doctorReport struct {
+	Healthy bool           `json:"healthy"`
+	Checks  []docker.Check `json:"checks"`
+}
+
+// NewCmdDoctor implements the S2I cli doctor command.
+func NewCmdDoctor(cfg *api.Config) *cobra.Command {
+	output := ""
+	doctorCmd := &cobra.Command{
+		Use:   "doctor [<image>...]",
+		Short: "Diagnose the container engine and the registries the builds use",
+		Long: "Check that builds can run with the container engine: its socket is accessible, its API version is " +
+			"supported, it has disk space for the images, and how user namespaces and cgroups are set up. The " +
+			"registries of the given builder and runtime images are checked to be reachable with the credentials of " +
+			"the Docker configuration file. Each check passes or fails with a hint at how to fix the problem, and " +
+			"the command exits with 1 when a check fails.",
+		Example: `
+# Diagnose the container engine and the registry of a builder image
+$ s2i doctor registry.access.redhat.com/ubi8/python-39
+
+# Report the checks as JSON
+$ s2i doctor -o json
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if output != "" && output != "json" {
+				s2ierr.CheckError(fmt.Errorf("invalid output format %q, valid values are: json", output))
+			}
+			var auths *docker.AuthConfigurations
+			if r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath)); err == nil {
+				auths = docker.LoadImageRegistryAuth(r)
+				r.Close()
+			}
+
+			report := doctorReport{Healthy: true, Checks: docker.Diagnose(cfg.DockerConfig, args, auths)}
+			for _, check := range report.Checks {
+				if check.Status == docker.CheckFail {
+					report.Healthy = false
+				}
+			}
+			if output == "json" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				s2ierr.CheckError(err)
+				fmt.Println(string(data))
+			} else {
+				printChecks(os.Stdout, report.Checks)
+			}
+			if !report.Healthy {
+				os.Exit(s2ierr.ExitCodeFailure)
+			}
+		},
+	}
+	doctorCmd.Flags().StringVarP(&output, "output", "o", "", "Output format of the checks: json, or text when empty")
+	doctorCmd.Flags().StringVar(&(cfg.DockerCfgPath), "dockercfg-path", docker.DefaultDockerCfgPath(), "Specify the path to the Docker configuration file (defaults to $DOCKER_CONFIG/config.json, ~/.docker/config.json or ~/.dockercfg, whichever exists first)")
+	return doctorCmd
+}
+
+// printChecks writes the status of the checks, with the hints of the ones
+// which found a problem, as text.
+func printChecks(w io.Writer, checks []docker.Check) {
+	for _, check := range checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", check.Status, check.Name, check.Message)
+		if len(check.Hint) > 0 {
+			fmt.Fprintf(w, "       %s\n", check.Hint)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/extract"
+	"github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+)
+
+// NewCmdExtract implements the S2I cli extract command.
+func NewCmdExtract(cfg *api.Config) *cobra.Command {
+	opts := extract.Options{Output: "."}
+
+	extractCmd := &cobra.Command{
+		Use:   "extract <image> <path> [<path>...]",
+		Short: "Copy build artifacts out of an image",
+		Long: "Copy the files or directories at the given paths of an image, such as the artifacts produced " +
+			"by its assemble script, to a local directory or a tar archive. The image is not run: its " +
+			"filesystem is read from a container created, and removed, by the command.",
+		Example: `
+# Copy the JAR built by the assemble script to the current directory
+$ s2i extract hello-world-app /opt/app-root/src/target/app.jar
+
+# Write the binaries and the coverage report to a tar archive
+$ s2i extract hello-world-app /opt/app-root/bin /opt/app-root/src/coverage --output artifacts.tar
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(args) < 2 {
+				cmd.Help()
+				return
+			}
+			opts.Image = args[0]
+			opts.Paths = args[1:]
+			opts.PullPolicy = cfg.BuilderPullPolicy
+
+			var auth api.AuthConfig
+			if r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath)); err == nil {
+				defer r.Close()
+				auth = docker.GetImageRegistryAuth(docker.LoadImageRegistryAuth(r), opts.Image)
+			}
+			client, err := docker.NewClient(cfg.DockerConfig)
+			s2ierr.CheckError(err)
+			err = extract.Extract(docker.New(client, auth), tar.New(fs.NewFileSystem()), opts, os.Stdout)
+			s2ierr.CheckError(err)
+		},
+	}
+	extractCmd.Flags().StringVarP(&(opts.Output), "output", "o", opts.Output, "Directory the artifacts are copied to, or tar archive they are written to when it ends with .tar, or - for the standard output")
+	extractCmd.Flags().VarP(&(cfg.BuilderPullPolicy), "pull-policy", "p", "Specify when to pull the image (always, never, if-not-present or if-changed)")
+	extractCmd.Flags().StringVar(&(cfg.DockerCfgPath), "dockercfg-path", docker.DefaultDockerCfgPath(), "Specify the path to the Docker configuration file (defaults to $DOCKER_CONFIG/config.json, ~/.docker/config.json or ~/.dockercfg, whichever exists first)")
+	return extractCmd
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"strings"
 
 	"github.com/containers/image/v5/transports/alltransports"
@@ -10,23 +12,32 @@ import (
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/build/strategies/dockerfile"
+	"github.com/openshift/source-to-image/pkg/generate"
+	"github.com/openshift/source-to-image/pkg/scm/git"
 	"github.com/openshift/source-to-image/pkg/util"
 	"github.com/openshift/source-to-image/pkg/util/fs"
 )
 
-// getImageLabels attempts to inspect an image existing in a remote registry.
-func getImageLabels(ctx context.Context, ref types.ImageReference) (map[string]string, error) {
+// getImageMetadata attempts to inspect an image existing in a remote registry,
+// returning its labels, its user and its ID.
+func getImageMetadata(ctx context.Context, ref types.ImageReference) (map[string]string, string, string, error) {
 	img, err := ref.NewImage(ctx, &types.SystemContext{})
 	if err != nil {
-		return nil, err
+		return nil, "", "", err
 	}
+	defer img.Close()
 
 	imageMetadata, err := img.Inspect(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", "", err
+	}
+	imageConfig, err := img.OCIConfig(ctx)
+	if err != nil {
+		return nil, "", "", err
 	}
 
-	return imageMetadata.Labels, nil
+	// the ID of an image is the digest of its configuration
+	return imageMetadata.Labels, imageConfig.Config.User, img.ConfigInfo().Digest.String(), nil
 }
 
 // generateDockerfile generates a Dockerfile with the given configuration.
@@ -78,9 +89,12 @@ $ s2i generate docker.io/centos/nodejs-10-centos7 Dockerfile.gen
 			cfg.AsDockerfile = cmd.Flags().Arg(1)
 			ctx := context.Background()
 
-			err := manageConfigImageLabelsBuildImageName(ctx, cfg)
-			if err != nil {
-				log.Warningf("could not inspect the builder image for labels: %s", err.Error())
+			// the labels are read from the registry of the builder image
+			if !cfg.DockerConfig.Offline {
+				err := manageConfigImageLabelsBuildImageName(ctx, cfg)
+				if err != nil {
+					log.Warningf("could not inspect the builder image for labels: %s", err.Error())
+				}
 			}
 			// for generate we go ahead and modify the config scripts url and destination field since we do not have specific arg
 			// overrides for those 2 fields
@@ -94,10 +108,184 @@ $ s2i generate docker.io/centos/nodejs-10-centos7 Dockerfile.gen
 	generateCmd.Flags().StringVarP(&(cfg.AssembleUser), "assemble-user", "", "", "Specify the user to run assemble with")
 	generateCmd.Flags().StringVarP(&(cfg.AssembleRuntimeUser), "assemble-runtime-user", "", "", "Specify the user to run assemble-runtime with")
 
+	generateCmd.AddCommand(newCmdGenerateTekton(cfg))
+	generateCmd.AddCommand(newCmdGenerateJob(cfg))
+	generateCmd.AddCommand(newCmdGenerateCI(cfg))
 	return generateCmd
 }
 
-// manageConfigImageLabelsBuildImageName extracts the image labels from builder image in the provided config.
+// newCmdGenerateTekton implements the S2I cli generate tekton command.
+func newCmdGenerateTekton(cfg *api.Config) *cobra.Command {
+	opts := generate.Options{}
+	output := ""
+	pipeline := false
+	tektonCmd := &cobra.Command{
+		Use:   "tekton <source> <builder image> <tag>",
+		Short: "Generate a Tekton Task building and pushing an image like s2i build",
+		Long: "Generate a Tekton Task building the image <tag> from the sources of its \"source\" workspace " +
+			"and the <builder image> with s2i and buildah, and pushing it. With --pipeline, a Pipeline cloning " +
+			"the <source> Git repository with the git-clone Task of the Tekton catalog and running the Task is " +
+			"generated as well.",
+		Example: `
+# Generate a Tekton Pipeline building the application of a Git repository
+$ s2i generate tekton https://github.com/sclorg/ruby-ex.git centos/ruby-25-centos7 quay.io/user/ruby-ex --pipeline --push-secret quay
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				return cmd.Help()
+			}
+			if err := setManifestArgs(cfg, args); err != nil {
+				return err
+			}
+			warnRedactedEnvironment(cfg, "Task")
+			objects := []interface{}{generate.TektonTask(cfg, opts)}
+			if pipeline {
+				p, err := generate.TektonPipeline(cfg, opts)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, p)
+			}
+			return writeManifests(output, objects...)
+		},
+	}
+	addManifestFlags(tektonCmd, cfg, &opts, &output)
+	tektonCmd.Flags().BoolVar(&pipeline, "pipeline", false, "Generate a Pipeline cloning the Git repository and running the Task as well")
+	return tektonCmd
+}
+
+// newCmdGenerateJob implements the S2I cli generate k8s-job command.
+func newCmdGenerateJob(cfg *api.Config) *cobra.Command {
+	opts := generate.Options{}
+	output := ""
+	jobCmd := &cobra.Command{
+		Use:   "k8s-job <source> <builder image> <tag>",
+		Short: "Generate a Kubernetes Job building and pushing an image like s2i build",
+		Long: "Generate a Kubernetes Job building the image <tag> from the <source> Git repository and " +
+			"the <builder image> with s2i and buildah, and pushing it.",
+		Example: `
+# Generate a Job building the application of a Git repository and run it
+$ s2i generate k8s-job https://github.com/sclorg/ruby-ex.git centos/ruby-25-centos7 quay.io/user/ruby-ex --push-secret quay | kubectl create -f -
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				return cmd.Help()
+			}
+			if err := setManifestArgs(cfg, args); err != nil {
+				return err
+			}
+			warnRedactedEnvironment(cfg, "Job")
+			job, err := generate.KubernetesJob(cfg, opts)
+			if err != nil {
+				return err
+			}
+			return writeManifests(output, job)
+		},
+	}
+	addManifestFlags(jobCmd, cfg, &opts, &output)
+	return jobCmd
+}
+
+// newCmdGenerateCI implements the S2I cli generate ci command.
+func newCmdGenerateCI(cfg *api.Config) *cobra.Command {
+	opts := generate.CIOptions{}
+	provider := generate.GitHub
+	output := ""
+	ciCmd := &cobra.Command{
+		Use:   "ci <builder image> <tag>",
+		Short: "Generate a CI job building the image of the repository with s2i",
+		Long: "Generate a GitHub Actions workflow or a GitLab CI job building the image <tag> from the " +
+			"sources of the repository and the <builder image> with s2i. The image is cached between the " +
+			"runs of the job, so that the builds are incremental.",
+		Example: `
+# Generate a GitHub Actions workflow building and pushing the image
+$ s2i generate ci centos/ruby-25-centos7 quay.io/user/ruby-ex --push -o .github/workflows/s2i.yml
+
+# Generate a GitLab CI job to add to .gitlab-ci.yml
+$ s2i generate ci --provider gitlab centos/ruby-25-centos7 quay.io/user/ruby-ex
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return cmd.Help()
+			}
+			cfg.BuilderImage = args[0]
+			cfg.Tag = args[1]
+			ci, err := generate.CI(provider, cfg, opts)
+			if err != nil {
+				return err
+			}
+			return writeManifests(output, ci)
+		},
+	}
+	addGeneratedBuildFlags(ciCmd, cfg)
+	ciCmd.Flags().StringVar(&provider, "provider", provider, "Specify the CI provider: github or gitlab")
+	ciCmd.Flags().StringVar(&(opts.Name), "name", "", "Specify the name of the generated job (defaults to the name of the image followed by -build)")
+	ciCmd.Flags().StringVar(&(opts.S2IVersion), "s2i-version", "latest", "Specify the version of s2i installed by the job")
+	ciCmd.Flags().BoolVar(&(opts.Push), "push", false, "Push the image with the credentials of the REGISTRY_USERNAME and REGISTRY_PASSWORD secrets or variables")
+	ciCmd.Flags().StringVarP(&output, "output", "o", "", "Write the configuration to this file instead of the standard output")
+	return ciCmd
+}
+
+// setManifestArgs sets the source, builder image and tag of the configuration
+// of the generated manifests.
+func setManifestArgs(cfg *api.Config, args []string) error {
+	source, err := git.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't parse %q: %v", args[0], err)
+	}
+	cfg.Source = source
+	cfg.BuilderImage = args[1]
+	cfg.Tag = args[2]
+	return nil
+}
+
+// warnRedactedEnvironment warns that the environment variables matching the
+// redaction patterns are omitted from the generated resource.
+func warnRedactedEnvironment(cfg *api.Config, resource string) {
+	if names := generate.RedactedEnvironment(cfg); len(names) > 0 {
+		log.Warningf("The environment variables %s hold secrets and are not written to the %s, add them to it from a Secret", strings.Join(names, ", "), resource)
+	}
+}
+
+// addGeneratedBuildFlags adds the flags of the builds run by the generated
+// manifests and CI jobs.
+func addGeneratedBuildFlags(cmd *cobra.Command, cfg *api.Config) {
+	cmd.Flags().VarP(&(cfg.Environment), "env", "e", "Specify an single environment variable in NAME=VALUE format")
+	cmd.Flags().StringVar(&(cfg.ContextDir), "context-dir", "", "Specify the sub-directory inside the repository with the application sources")
+	cmd.Flags().StringVarP(&(cfg.ScriptsURL), "scripts-url", "s", "", "Specify a URL for the assemble, assemble-runtime and run scripts")
+	cmd.Flags().StringVar(&(cfg.AssembleUser), "assemble-user", "", "Specify the user to run assemble with")
+	cmd.Flags().StringVar(&(cfg.AssembleRuntimeUser), "assemble-runtime-user", "", "Specify the user to run assemble-runtime with")
+}
+
+// addManifestFlags adds the flags of the commands generating manifests.
+func addManifestFlags(cmd *cobra.Command, cfg *api.Config, opts *generate.Options, output *string) {
+	addGeneratedBuildFlags(cmd, cfg)
+	cmd.Flags().StringVar(&(opts.Name), "name", "", "Specify the name of the generated resources (defaults to the name of the image followed by -build)")
+	cmd.Flags().StringVar(&(opts.Namespace), "namespace", "", "Specify the namespace of the generated resources")
+	cmd.Flags().StringVar(&(opts.S2IImage), "s2i-image", generate.DefaultS2IImage, "Specify the image running s2i")
+	cmd.Flags().StringVar(&(opts.BuildahImage), "buildah-image", generate.DefaultBuildahImage, "Specify the image building and pushing the image with buildah")
+	cmd.Flags().StringVar(&(opts.PushSecret), "push-secret", "", "Specify the name of the kubernetes.io/dockerconfigjson secret holding the credentials of the registry the image is pushed to")
+	cmd.Flags().StringVarP(output, "output", "o", "", "Write the manifests to this file instead of the standard output")
+}
+
+// writeManifests writes the given manifests to the output file, or to the
+// standard output if it is empty.
+func writeManifests(output string, objects ...interface{}) error {
+	if len(output) == 0 {
+		return generate.Write(os.Stdout, objects...)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := generate.Write(f, objects...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// manageConfigImageLabelsBuildImageName extracts the image labels and the user from builder image in the provided config.
 // Returns an error if the builder image name is invalid or there is an error extracting the image labels.
 func manageConfigImageLabelsBuildImageName(ctx context.Context, cfg *api.Config) error {
 	builderImageName := CanonizeBuilderImageArg(cfg.BuilderImage)
@@ -108,7 +296,7 @@ func manageConfigImageLabelsBuildImageName(ctx context.Context, cfg *api.Config)
 
 	cfg.BuilderImage = ref.DockerReference().String()
 
-	if cfg.BuilderImageLabels, err = getImageLabels(ctx, ref); err != nil {
+	if cfg.BuilderImageLabels, cfg.BuilderImageUser, cfg.BuilderImageID, err = getImageMetadata(ctx, ref); err != nil {
 		return err
 	}
 	return nil
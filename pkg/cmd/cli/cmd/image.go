@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/imagebundle"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+)
+
+// NewCmdImage implements the S2I cli image command.
+func NewCmdImage(cfg *api.Config) *cobra.Command {
+	imageCmd := &cobra.Command{
+		Use:   "image",
+		Short: "Carry builder images to air-gapped hosts",
+		Long: "Save a builder image with its S2I scripts to a single archive, and load it back on a " +
+			"disconnected host.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	imageCmd.AddCommand(newCmdImageSave(cfg))
+	imageCmd.AddCommand(newCmdImageLoad(cfg))
+	return imageCmd
+}
+
+// newCmdImageSave implements the S2I cli image save command.
+func newCmdImageSave(cfg *api.Config) *cobra.Command {
+	opts := imagebundle.SaveOptions{}
+
+	saveCmd := &cobra.Command{
+		Use:   "save <builder image> <archive>",
+		Short: "Save a builder image with its scripts to an archive",
+		Long: "Save the builder image, in the format of docker save, followed by its labels and its S2I " +
+			"scripts, fetched from the image or from its scripts URL, to a tar archive, or - for the " +
+			"standard output.",
+		Example: `
+# Save a builder image and the scripts downloaded from its scripts URL
+$ s2i image save centos/ruby-25-centos7 ruby.tar
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(args) != 2 {
+				cmd.Help()
+				return
+			}
+			opts.Image = args[0]
+			opts.PullPolicy = cfg.BuilderPullPolicy
+			opts.ProxyConfig = cfg.ScriptDownloadProxyConfig
+
+			var auth api.AuthConfig
+			if r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath)); err == nil {
+				defer r.Close()
+				auth = docker.GetImageRegistryAuth(docker.LoadImageRegistryAuth(r), opts.Image)
+			}
+			client, err := docker.NewClient(cfg.DockerConfig)
+			s2ierr.CheckError(err)
+
+			w := os.Stdout
+			if args[1] != "-" {
+				f, err := os.Create(args[1])
+				s2ierr.CheckError(err)
+				defer f.Close()
+				w = f
+			}
+			metadata, err := imagebundle.Save(docker.New(client, auth), opts, w)
+			if err != nil && args[1] != "-" {
+				os.Remove(args[1])
+			}
+			s2ierr.CheckError(err)
+			log.V(0).Infof("Saved image %s (%s) with the scripts %v to %s", metadata.Image, metadata.ID, metadata.Scripts, args[1])
+		},
+	}
+	saveCmd.Flags().StringVarP(&(opts.ScriptsURL), "scripts-url", "s", "", "Specify a URL the scripts are fetched from instead of the scripts URL label of the image (image, file, http or https)")
+	saveCmd.Flags().VarP(&(cfg.BuilderPullPolicy), "pull-policy", "p", "Specify when to pull the image (always, never, if-not-present or if-changed)")
+	saveCmd.Flags().StringVar(&(cfg.DockerCfgPath), "dockercfg-path", docker.DefaultDockerCfgPath(), "Specify the path to the Docker configuration file (defaults to $DOCKER_CONFIG/config.json, ~/.docker/config.json or ~/.dockercfg, whichever exists first)")
+	return saveCmd
+}
+
+// newCmdImageLoad implements the S2I cli image load command.
+func newCmdImageLoad(cfg *api.Config) *cobra.Command {
+	opts := imagebundle.LoadOptions{}
+
+	loadCmd := &cobra.Command{
+		Use:   "load <archive>",
+		Short: "Load a builder image saved with s2i image save",
+		Long: "Load the builder image of an archive saved with s2i image save, verify that its labels " +
+			"were preserved, and restore its scripts to a directory.",
+		Example: `
+# Load a builder image and restore its scripts, then build with them
+$ s2i image load ruby.tar --scripts-dir ./ruby-scripts
+$ s2i build ./app centos/ruby-25-centos7 app --offline --scripts-url file://$PWD/ruby-scripts
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(args) != 1 {
+				cmd.Help()
+				return
+			}
+			opts.Archive = args[0]
+
+			client, err := docker.NewClient(cfg.DockerConfig)
+			s2ierr.CheckError(err)
+			metadata, err := imagebundle.Load(docker.New(client, api.AuthConfig{}), opts)
+			s2ierr.CheckError(err)
+			log.V(0).Infof("Loaded image %s (%s)", metadata.Image, metadata.ID)
+			if len(opts.ScriptsDir) > 0 && len(metadata.Scripts) > 0 {
+				log.V(0).Infof("Restored the scripts %v, fetched from %s, to %s", metadata.Scripts, metadata.ScriptsURL, opts.ScriptsDir)
+			}
+		},
+	}
+	loadCmd.Flags().StringVar(&(opts.ScriptsDir), "scripts-dir", "", "Directory the scripts of the archive are restored to")
+	return loadCmd
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/scripts"
+)
+
+// NewCmdLintScripts implements the S2I cli lint-scripts command.
+func NewCmdLintScripts(cfg *api.Config) *cobra.Command {
+	opts := scripts.LintOptions{}
+	image := ""
+
+	lintCmd := &cobra.Command{
+		Use:   "lint-scripts <dir>",
+		Short: "Check the S2I scripts of a directory",
+		Long: "Check the S2I scripts of a directory, such as the .s2i/bin directory of the sources, for the " +
+			"problems which make them fail inside the container: CRLF line endings, a missing shebang, a " +
+			"missing executable bit, and bash constructs when the image only has sh. Exits with a non-zero " +
+			"code when any problem is an error.",
+		Example: `
+# Check the scripts of the sources for an image which only has sh
+$ s2i lint-scripts .s2i/bin --no-bash
+
+# Check the scripts of the sources for the shells of the builder image
+$ s2i lint-scripts .s2i/bin --image centos/ruby-25-centos7
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(args) != 1 {
+				cmd.Help()
+				return
+			}
+			if len(image) > 0 {
+				client, err := docker.NewClient(cfg.DockerConfig)
+				s2ierr.CheckError(err)
+				d := docker.New(client, api.AuthConfig{})
+				_, err = docker.PullImage(image, d, api.PullIfNotPresent)
+				s2ierr.CheckError(err)
+				probe, err := d.ProbeImage(image, "", "")
+				s2ierr.CheckError(err)
+				opts.NoBash = !probe.HasBash
+			}
+			problems, err := scripts.LintDir(args[0], opts)
+			s2ierr.CheckError(err)
+			for _, problem := range problems {
+				fmt.Fprintln(os.Stdout, problem)
+			}
+			if scripts.HasErrors(problems) {
+				os.Exit(s2ierr.ExitCodeFailure)
+			}
+		},
+	}
+	lintCmd.Flags().BoolVar(&(opts.NoBash), "no-bash", false, "Check the scripts for an image which has no bash, so that the bash scripts and the bashisms of the sh scripts are errors")
+	lintCmd.Flags().StringVar(&image, "image", "", "Check the scripts for the shells of the given image instead of --no-bash")
+	return lintCmd
+}
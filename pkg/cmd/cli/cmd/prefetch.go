@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/prefetch"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+)
+
+// NewCmdPrefetch implements the S2I cli prefetch command.
+func NewCmdPrefetch(cfg *api.Config) *cobra.Command {
+	opts := prefetch.Options{Parallel: prefetch.DefaultParallel}
+
+	prefetchCmd := &cobra.Command{
+		Use:   "prefetch <file> [<image>...]",
+		Short: "Pull a list of builder and runtime images ahead of the builds",
+		Long: "Pull in parallel the builder and runtime images listed one per line in a file, or - for the " +
+			"standard input, and verify that they are available locally. An image pinned by digest is pulled " +
+			"by digest and tagged with its tag. Meant to be run when a node starts, so that its first builds " +
+			"do not wait for the pulls.",
+		Example: `
+# Pull the images listed in images.txt, 8 at a time
+$ s2i prefetch images.txt --parallel 8
+
+# Pull a builder image pinned by digest, tagging it centos/ruby-25-centos7:latest
+$ echo centos/ruby-25-centos7:latest@sha256:4d6a... | s2i prefetch -
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(args) == 0 {
+				cmd.Help()
+				return
+			}
+			var r io.Reader = os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				s2ierr.CheckError(err)
+				defer f.Close()
+				r = f
+			}
+			images, err := prefetch.ReadList(r)
+			if err != nil {
+				s2ierr.CheckError(fmt.Errorf("unable to read the images of %s: %v", args[0], err))
+			}
+			opts.Images = images
+			opts.PullPolicy = cfg.BuilderPullPolicy
+
+			var auths *docker.AuthConfigurations
+			if r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath)); err == nil {
+				auths = docker.LoadImageRegistryAuth(r)
+				r.Close()
+			}
+			client, err := docker.NewClient(cfg.DockerConfig)
+			s2ierr.CheckError(err)
+			newDocker := func(image string) docker.Docker {
+				return docker.New(client, docker.GetImageRegistryAuth(auths, image))
+			}
+
+			var failed error
+			failures := 0
+			for _, result := range prefetch.Prefetch(newDocker, opts) {
+				if result.Err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to prefetch %s: %v\n", result.Image, result.Err)
+					if failed == nil {
+						failed = result.Err
+					}
+					failures++
+					continue
+				}
+				fmt.Fprintf(os.Stdout, "Prefetched %s (%s) in %s\n", result.Image, result.ID, result.Duration.Round(time.Millisecond))
+			}
+			if failed != nil {
+				s2ierr.CheckError(fmt.Errorf("unable to prefetch %d of %d images: %w", failures, len(opts.Images), failed))
+			}
+		},
+	}
+	prefetchCmd.Flags().IntVar(&(opts.Parallel), "parallel", opts.Parallel, "Number of images pulled at the same time")
+	prefetchCmd.Flags().VarP(&(cfg.BuilderPullPolicy), "pull-policy", "p", "Specify when to pull the images (always, never, if-not-present or if-changed)")
+	prefetchCmd.Flags().StringVar(&(cfg.DockerCfgPath), "dockercfg-path", docker.DefaultDockerCfgPath(), "Specify the path to the Docker configuration file (defaults to $DOCKER_CONFIG/config.json, ~/.docker/config.json or ~/.dockercfg, whichever exists first)")
+	return prefetchCmd
+}
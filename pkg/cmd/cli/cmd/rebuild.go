@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -12,6 +13,7 @@ import (
 	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
 	"github.com/openshift/source-to-image/pkg/docker"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
 )
 
 // NewCmdRebuild implements the S2i cli rebuild command.
@@ -21,6 +23,11 @@ func NewCmdRebuild(cfg *api.Config) *cobra.Command {
 		Short: "Rebuild an existing image",
 		Long:  "Rebuild an existing application image that was built by S2I previously.",
 		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+
 			// If user specifies the arguments, then we override the stored ones
 			if len(args) >= 1 {
 				cfg.Tag = args[0]
@@ -30,13 +37,13 @@ func NewCmdRebuild(cfg *api.Config) *cobra.Command {
 			}
 
 			var auths *docker.AuthConfigurations
-			r, err := os.Open(cfg.DockerCfgPath)
+			r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath))
 			if err == nil {
 				defer r.Close()
 				auths = docker.LoadImageRegistryAuth(r)
 			}
 
-			cfg.PullAuthentication = docker.GetImageRegistryAuth(auths, cfg.Tag)
+			cfg.PullAuthentication = docker.OverrideImageRegistryAuth(docker.GetImageRegistryAuth(auths, cfg.Tag), cfg.IncrementalCredentials, cfg.Tag, "--incremental-auth")
 
 			if len(cfg.BuilderPullPolicy) == 0 {
 				cfg.BuilderPullPolicy = api.DefaultBuilderPullPolicy
@@ -45,7 +52,7 @@ func NewCmdRebuild(cfg *api.Config) *cobra.Command {
 				cfg.PreviousImagePullPolicy = api.DefaultPreviousImagePullPolicy
 			}
 
-			client, err := docker.NewEngineAPIClient(cfg.DockerConfig)
+			client, err := docker.NewClient(cfg.DockerConfig)
 			s2ierr.CheckError(err)
 			dkr := docker.New(client, cfg.PullAuthentication)
 			pr, err := docker.GetRebuildImage(dkr, cfg)
@@ -57,7 +64,7 @@ func NewCmdRebuild(cfg *api.Config) *cobra.Command {
 				cfg.Tag = args[1]
 			}
 
-			cfg.PullAuthentication = docker.GetImageRegistryAuth(auths, cfg.BuilderImage)
+			cfg.PullAuthentication = docker.OverrideImageRegistryAuth(docker.GetImageRegistryAuth(auths, cfg.BuilderImage), cfg.PullCredentials, cfg.BuilderImage, "--pull-auth")
 
 			log.V(2).Infof("\n%s\n", describe.Config(client, cfg))
 
@@ -73,5 +80,6 @@ func NewCmdRebuild(cfg *api.Config) *cobra.Command {
 	}
 
 	cmdutil.AddCommonFlags(buildCmd, cfg)
+	buildCmd.Flags().BoolVar(&(cfg.DiffReport), "diff-report", false, "Print the files added, removed and changed in the working directory of the image and the size of its layers compared to the previous image of the tag")
 	return buildCmd
 }
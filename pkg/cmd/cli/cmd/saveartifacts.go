@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/artifacts"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+)
+
+// NewCmdSaveArtifacts implements the S2I cli save-artifacts command.
+func NewCmdSaveArtifacts(cfg *api.Config) *cobra.Command {
+	opts := artifacts.Options{Output: artifacts.Stdout}
+
+	saveArtifactsCmd := &cobra.Command{
+		Use:   "save-artifacts <image>",
+		Short: "Save the build artifacts of an image to an archive",
+		Long: "Run the save-artifacts script of an image, as incremental builds do, and write the tar archive " +
+			"of the build artifacts it produces, such as dependency caches, to a file or the standard output. " +
+			"The archive is restored by the --restore-artifacts option of s2i build, so that external caching " +
+			"systems can snapshot the artifacts on their own schedule.",
+		Example: `
+# Save the artifacts of hello-world-app to a zstd compressed archive
+$ s2i save-artifacts hello-world-app --output artifacts.tar.zst
+
+# Build using the saved artifacts
+$ s2i build . centos/ruby-22-centos7 hello-world-app --restore-artifacts artifacts.tar.zst
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(args) != 1 {
+				cmd.Help()
+				return
+			}
+			opts.Image = args[0]
+			opts.PullPolicy = cfg.BuilderPullPolicy
+			if !cmd.Flags().Changed("compression") {
+				opts.Compression = artifacts.OutputCompression(opts.Output)
+			}
+
+			var auth api.AuthConfig
+			if r, err := os.Open(hostpath.ExpandHome(cfg.DockerCfgPath)); err == nil {
+				defer r.Close()
+				auth = docker.GetImageRegistryAuth(docker.LoadImageRegistryAuth(r), opts.Image)
+			}
+			client, err := docker.NewClient(cfg.DockerConfig)
+			s2ierr.CheckError(err)
+			err = artifacts.Save(docker.New(client, auth), opts, os.Stdout)
+			s2ierr.CheckError(err)
+		},
+	}
+	saveArtifactsCmd.Flags().StringVarP(&(opts.Output), "output", "o", opts.Output, "Archive the artifacts are written to, compressed using gzip when it ends with .tar.gz or .tgz and zstd when it ends with .tar.zst, or - for the standard output")
+	saveArtifactsCmd.Flags().Var(&(opts.Compression), "compression", "Specify the compression of the archive (none, gzip or zstd), overriding the one of its extension")
+	saveArtifactsCmd.Flags().StringVar(&(opts.User), "assemble-user", "", "Specify the user to run the save-artifacts script with, defaulting to the user of the image")
+	saveArtifactsCmd.Flags().VarP(&(cfg.BuilderPullPolicy), "pull-policy", "p", "Specify when to pull the image (always, never, if-not-present or if-changed)")
+	saveArtifactsCmd.Flags().StringVar(&(cfg.DockerCfgPath), "dockercfg-path", docker.DefaultDockerCfgPath(), "Specify the path to the Docker configuration file (defaults to $DOCKER_CONFIG/config.json, ~/.docker/config.json or ~/.dockercfg, whichever exists first)")
+	return saveArtifactsCmd
+}
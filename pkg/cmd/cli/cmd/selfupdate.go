@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/selfupdate"
+	"github.com/openshift/source-to-image/pkg/version"
+)
+
+// NewCmdSelfUpdate implements the S2I cli self-update command.
+func NewCmdSelfUpdate(cfg *api.Config) *cobra.Command {
+	checkOnly := false
+	selfUpdateCmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update s2i to the latest release",
+		Long: "Check the latest release of s2i published on GitHub and, when it is newer than the running " +
+			"binary, download its archive, verify the signature of the checksums of the release against " +
+			"the key pinned in the binary and the SHA-512 checksum of the archive against them, and " +
+			"atomically replace the binary with the one of the archive.",
+		Example: `
+# Check whether a newer release is available
+$ s2i self-update --check-only
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if cfg.DockerConfig.Offline {
+				s2ierr.CheckError(fmt.Errorf("self-update requires network access, which --offline forbids"))
+			}
+			current := version.Get()
+			updater := selfupdate.NewUpdater()
+			release, err := updater.LatestRelease()
+			s2ierr.CheckError(err)
+			newer, err := selfupdate.IsNewer(release.TagName, current.GitVersion)
+			s2ierr.CheckError(err)
+			if !newer {
+				log.V(0).Infof("s2i %s is up to date, the latest release is %s", current, release.TagName)
+				return
+			}
+			if checkOnly {
+				log.V(0).Infof("s2i %s is available, the running version is %s: %s", release.TagName, current, release.HTMLURL)
+				return
+			}
+
+			path, err := selfupdate.Executable()
+			s2ierr.CheckError(err)
+			binary, err := updater.Download(release)
+			s2ierr.CheckError(err)
+			if err := selfupdate.Replace(path, binary); err != nil {
+				s2ierr.CheckError(fmt.Errorf("unable to replace %s, which might require elevated privileges: %v", path, err))
+			}
+			log.V(0).Infof("Updated s2i %s at %s to %s", current, path, release.TagName)
+		},
+	}
+	selfUpdateCmd.Flags().BoolVar(&checkOnly, "check-only", false, "Only report whether a newer release is available, without updating")
+	return selfUpdateCmd
+}
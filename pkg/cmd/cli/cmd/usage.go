@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
 
 	"github.com/openshift/source-to-image/pkg/api"
@@ -18,15 +21,21 @@ func NewCmdUsage(cfg *api.Config) *cobra.Command {
 	usageCmd := &cobra.Command{
 		Use:   "usage <image>",
 		Short: "Print usage of the assemble script associated with the image",
-		Long:  "Create and start a container from the image and invoke its usage script.",
+		Long:  "Create and start a container from the image and invoke its usage script, or print the script with --mode.",
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 0 {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+
+			builderArg := cmdutil.EnvArg(args, 0, "builder-image")
+			if len(builderArg) == 0 {
 				cmd.Help()
 				return
 			}
 
 			cfg.Usage = true
-			cfg.BuilderImage = args[0]
+			cfg.BuilderImage = builderArg
 
 			if len(oldScriptsFlag) != 0 {
 				log.Warning("DEPRECATED: Flag --scripts is deprecated, use --scripts-url instead")
@@ -40,7 +49,9 @@ func NewCmdUsage(cfg *api.Config) *cobra.Command {
 				cfg.PreviousImagePullPolicy = api.DefaultPreviousImagePullPolicy
 			}
 
-			client, err := docker.NewEngineAPIClient(cfg.DockerConfig)
+			cfg.PullAuthentication = docker.OverrideImageRegistryAuth(cfg.PullAuthentication, cfg.PullCredentials, cfg.BuilderImage, "--pull-auth")
+
+			client, err := docker.NewClient(cfg.DockerConfig)
 			s2ierr.CheckError(err)
 			uh, err := sti.NewUsage(client, cfg)
 			s2ierr.CheckError(err)
@@ -48,6 +59,7 @@ func NewCmdUsage(cfg *api.Config) *cobra.Command {
 			s2ierr.CheckError(err)
 		},
 	}
+	usageCmd.Flags().Var(&(cfg.UsageMode), "mode", "Specify how to show the usage script: container (run it in a container of the image), auto (print it when the image cannot run on this host)) or print (print it without running a container)")
 	usageCmd.Flags().StringVarP(&(oldDestination), "location", "l", "",
 		"Specify a destination location for untar operation")
 	cmdutil.AddCommonFlags(usageCmd, cfg)
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	v1 "github.com/openshift/source-to-image/pkg/api/v1"
+	"github.com/openshift/source-to-image/pkg/api/validation"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+// validationReport is the JSON output of the validate command.
+type validationReport struct {
+	Valid  bool               `json:"valid"`
+	Errors []validation.Error `json:"errors"`
+}
+
+// NewCmdValidate implements the S2I cli validate command.
+func NewCmdValidate() *cobra.Command {
+	filename := ""
+	output := ""
+	validateCmd := &cobra.Command{
+		Use:   "validate -f <config.json>",
+		Short: "Validate a build configuration without building",
+		Long: "Read a build configuration from a JSON file, in the s2i.openshift.io/v1 schema of the pkg/api/v1 package, " +
+			"and report its invalid fields without starting a build. The errors have the JSON path of their " +
+			"field and a machine readable type, and the command exits with 2 when the configuration is invalid.",
+		Example: `
+# Validate a configuration and print the errors as JSON
+$ s2i validate -f config.json -o json
+
+# Validate a configuration read from the standard input
+$ cat config.json | s2i validate -f -
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if len(filename) == 0 {
+				cmd.Help()
+				return
+			}
+			if output != "" && output != "json" {
+				s2ierr.CheckError(fmt.Errorf("invalid output format %q, valid values are: json", output))
+			}
+			r, name := io.Reader(os.Stdin), "the standard input"
+			if filename != "-" {
+				f, err := os.Open(filename)
+				s2ierr.CheckError(err)
+				defer f.Close()
+				r, name = f, filename
+			}
+			cfg, err := readValidationConfig(r)
+			if err != nil {
+				s2ierr.CheckError(fmt.Errorf("unable to read the configuration of %s: %v", name, err))
+			}
+
+			report := validationReport{Errors: validation.ValidateConfig(cfg)}
+			report.Valid = len(report.Errors) == 0
+			if output == "json" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				s2ierr.CheckError(err)
+				fmt.Println(string(data))
+			} else if report.Valid {
+				log.V(0).Infof("The configuration of %s is valid", name)
+			}
+			if !report.Valid {
+				if output != "json" {
+					for _, e := range report.Errors {
+						fmt.Fprintf(os.Stderr, "ERROR: %s\n", e)
+					}
+				}
+				os.Exit(s2ierr.ExitCodeValidation)
+			}
+		},
+	}
+	validateCmd.Flags().StringVarP(&filename, "filename", "f", "", "JSON file of the build configuration, or - to read it from the standard input")
+	validateCmd.Flags().StringVarP(&output, "output", "o", "", "Output format of the errors: json, or text when empty")
+	return validateCmd
+}
+
+// readValidationConfig decodes the versioned build configuration of the given
+// reader, rejecting the unknown fields, and converts it to the internal
+// configuration with the defaults of the build command.
+func readValidationConfig(r io.Reader) (*api.Config, error) {
+	versioned := &v1.Config{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(versioned); err != nil {
+		return nil, err
+	}
+	v1.SetDefaults(versioned)
+	cfg, err := v1.ToInternal(versioned)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DockerConfig == nil {
+		cfg.DockerConfig = docker.GetDefaultDockerConfig()
+	}
+	return cfg, nil
+}
@@ -1,21 +1,119 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/spf13/cobra"
 
+	"github.com/openshift/source-to-image/pkg/api"
+	cmdutil "github.com/openshift/source-to-image/pkg/cmd/cli/util"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 	"github.com/openshift/source-to-image/pkg/version"
 )
 
+// versionReport is the JSON output of the version command.
+type versionReport struct {
+	version.Info
+	// Engine describes the container engine, with --verbose.
+	Engine *docker.EngineReport `json:"engine,omitempty"`
+	// EngineError is the reason the container engine could not be queried.
+	EngineError string `json:"engineError,omitempty"`
+}
+
 // NewCmdVersion implements the S2i cli version command.
-func NewCmdVersion() *cobra.Command {
-	return &cobra.Command{
+func NewCmdVersion(cfg *api.Config) *cobra.Command {
+	output := ""
+	verbose := false
+	versionCmd := &cobra.Command{
 		Use:   "version",
 		Short: "Display version",
-		Long:  "Display version",
+		Long: "Display the version of s2i and, with --verbose, the build metadata of the binary and the " +
+			"version and features of the container engine, to include in bug reports.",
+		Example: `
+# Report the version of s2i and of the container engine
+$ s2i version --verbose --output json
+`,
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("s2i %v\n", version.Get())
+			if err := cmdutil.BindEnvironment(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return
+			}
+			if output != "" && output != "json" {
+				s2ierr.CheckError(fmt.Errorf("invalid output format %q, valid values are: json", output))
+			}
+			report := versionReport{Info: version.Get()}
+			if verbose {
+				report.Engine, report.EngineError = engineReport(cfg)
+			}
+			if output == "json" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				s2ierr.CheckError(err)
+				fmt.Println(string(data))
+				return
+			}
+			fmt.Printf("s2i %v\n", report.Info)
+			if verbose {
+				printVersionReport(os.Stdout, report)
+			}
 		},
 	}
+	versionCmd.Flags().StringVarP(&output, "output", "o", "", "Output format of the version: json, or text when empty")
+	versionCmd.Flags().BoolVar(&verbose, "verbose", false, "Also report the build metadata of s2i and query the container engine for its version and features")
+	return versionCmd
+}
+
+// engineReport queries the container engine of the given configuration,
+// returning the reason it could not be queried instead of an error.
+func engineReport(cfg *api.Config) (*docker.EngineReport, string) {
+	client, err := docker.NewClient(cfg.DockerConfig)
+	if err != nil {
+		return nil, err.Error()
+	}
+	report, err := docker.GetEngineReport(client)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if cfg.DockerConfig.Engine != api.EngineContainerd {
+		report.Endpoint = cfg.DockerConfig.Endpoint
+	} else {
+		report.Endpoint = cfg.DockerConfig.ContainerdAddress
+	}
+	return report, ""
+}
+
+// printVersionReport writes the build metadata and the engine of the given
+// report as text.
+func printVersionReport(w io.Writer, report versionReport) {
+	field := func(name string, value interface{}) {
+		fmt.Fprintf(w, "  %-13s %v\n", name+":", value)
+	}
+	field("commit", valueOrUnknown(report.GitCommit))
+	field("build date", valueOrUnknown(report.BuildDate))
+	field("go version", report.GoVersion+" "+report.Platform)
+	if report.Engine == nil {
+		fmt.Fprintf(w, "engine: unavailable: %s\n", report.EngineError)
+		return
+	}
+	engine := report.Engine
+	fmt.Fprintf(w, "engine: %s %s\n", engine.Name, engine.Version)
+	field("endpoint", engine.Endpoint)
+	if len(engine.APIVersion) > 0 {
+		field("api version", engine.APIVersion)
+	}
+	field("platform", engine.OS+"/"+engine.Arch)
+	field("buildkit", engine.Features.BuildKit)
+	field("multi-arch", engine.Features.MultiArch)
+	field("zstd context", engine.Features.ZstdBuildContext)
+}
+
+// valueOrUnknown returns the given value, or unknown if it is empty.
+func valueOrUnknown(value string) string {
+	if len(value) == 0 {
+		return "unknown"
+	}
+	return value
 }
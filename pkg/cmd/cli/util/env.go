@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/openshift/source-to-image/pkg/config"
+)
+
+// EnvPrefix is the prefix of the environment variables which configure the
+// flags of s2i commands.
+const EnvPrefix = "S2I_"
+
+// NoEnvAnnotation is the annotation of the flags which are not bound to an
+// environment variable, because the name of the variable is taken, e.g. the one
+// of --source, S2I_SOURCE, by the source argument of s2i build.
+const NoEnvAnnotation = "s2i/no-env"
+
+// FlagEnvName returns the name of the environment variable bound to the flag
+// with the given name, e.g. S2I_PULL_POLICY for --pull-policy.
+func FlagEnvName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// BindEnvironment sets the flags of the given command, which were not set on
+// the command line, from their S2I_* environment variables, and then the
+// remaining flags from the user configuration file, see BindEnvironmentVariables
+// and BindUserConfig. The commands restoring a configuration file bind them
+// separately instead.
+func BindEnvironment(c *cobra.Command) error {
+	if err := BindEnvironmentVariables(c); err != nil {
+		return err
+	}
+	return BindUserConfig(c)
+}
+
+// BindEnvironmentVariables sets the flags of the given command, which were not
+// set on the command line, from their S2I_* environment variables. The value
+// of an environment variable is applied as if it was given once on the command
+// line, but the flag is annotated so that it is not saved to the configuration
+// file. It has to be called before any configuration file is restored, so that
+// the environment variables take precedence over it.
+func BindEnvironmentVariables(c *cobra.Command) error {
+	var err error
+	c.Flags().VisitAll(func(f *pflag.Flag) {
+		if _, noEnv := f.Annotations[NoEnvAnnotation]; err != nil || f.Changed || noEnv {
+			return
+		}
+		name := FlagEnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if setErr := c.Flags().Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q of environment variable %s: %v", value, name, setErr)
+			return
+		}
+		c.Flags().SetAnnotation(f.Name, config.EnvironmentAnnotation, []string{name})
+	})
+	return err
+}
+
+// BindUserConfig sets the flags of the given command, which were not set on
+// the command line, by an environment variable or by a restored configuration
+// file, from the user configuration file. It has to be called after any
+// configuration file is restored: the flags are left unchanged, so that they
+// are not saved to the configuration file, and a restored flag set after them
+// would be appended to their value.
+func BindUserConfig(c *cobra.Command) error {
+	path := config.UserConfigPath()
+	userConfig, err := config.LoadUserConfig(path)
+	if err != nil {
+		return err
+	}
+	for name, value := range userConfig.Defaults {
+		f := c.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid value %q of %s in %s: %v", value, name, path, err)
+		}
+	}
+	return nil
+}
+
+// EnvArg returns the value of the given positional argument, if it was
+// passed on the command line, or of its S2I_* environment variable otherwise.
+func EnvArg(args []string, index int, name string) string {
+	if index < len(args) {
+		return args[index]
+	}
+	return os.Getenv(FlagEnvName(name))
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/config"
+)
+
+func TestBindEnvironment(t *testing.T) {
+	cfg := &api.Config{}
+	c := &cobra.Command{}
+	AddCommonFlags(c, cfg)
+	c.Flags().StringArrayVar(&(cfg.ExcludeGlobs), "exclude-glob", []string{}, "")
+
+	os.Setenv("S2I_PULL_POLICY", "never")
+	os.Setenv("S2I_INCREMENTAL", "true")
+	os.Setenv("S2I_DESTINATION", "/from/env")
+	os.Setenv("S2I_EXCLUDE_GLOB", "*.log")
+	defer func() {
+		for _, name := range []string{"S2I_PULL_POLICY", "S2I_INCREMENTAL", "S2I_DESTINATION", "S2I_EXCLUDE_GLOB"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	if err := c.Flags().Parse([]string{"--destination", "/from/flag"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := BindEnvironment(c); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.BuilderPullPolicy != api.PullNever {
+		t.Errorf("Expected pull policy from environment, got %q", cfg.BuilderPullPolicy)
+	}
+	if !cfg.Incremental {
+		t.Errorf("Expected incremental to be set from environment")
+	}
+	if cfg.Destination != "/from/flag" {
+		t.Errorf("Expected the command line flag to take precedence, got %q", cfg.Destination)
+	}
+	if len(cfg.ExcludeGlobs) != 1 || cfg.ExcludeGlobs[0] != "*.log" {
+		t.Errorf("Expected exclude glob from environment, got %v", cfg.ExcludeGlobs)
+	}
+	if !c.Flag("pull-policy").Changed {
+		t.Errorf("Expected flags set from environment to be marked as changed")
+	}
+	if _, ok := c.Flag("pull-policy").Annotations[config.EnvironmentAnnotation]; !ok {
+		t.Errorf("Expected flags set from environment to be annotated")
+	}
+	if _, ok := c.Flag("destination").Annotations[config.EnvironmentAnnotation]; ok {
+		t.Errorf("Expected flags set on the command line not to be annotated")
+	}
+
+	os.Setenv("S2I_RUNTIME_PULL_POLICY", "sometimes")
+	defer os.Unsetenv("S2I_RUNTIME_PULL_POLICY")
+	if err := BindEnvironment(c); err == nil {
+		t.Errorf("Expected an error for an invalid environment value")
+	}
+}
+
+func TestFlagEnvName(t *testing.T) {
+	if name := FlagEnvName("builder-image"); name != "S2I_BUILDER_IMAGE" {
+		t.Errorf("Unexpected environment variable name %q", name)
+	}
+}
+
+func TestBindUserConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	userConfig := &config.UserConfig{
+		APIVersion: config.ConfigAPIVersion,
+		Kind:       config.UserConfigKind,
+		Defaults:   map[string]string{"pull-policy": "never", "incremental": "true", "destination": "/from/config", "no-such-flag": "value"},
+	}
+	if err := config.SaveUserConfig(userConfig, config.UserConfigPath()); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &api.Config{}
+	c := &cobra.Command{}
+	AddCommonFlags(c, cfg)
+	t.Setenv("S2I_INCREMENTAL", "false")
+	if err := c.Flags().Parse([]string{"--destination", "/from/flag"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := BindEnvironment(c); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.BuilderPullPolicy != api.PullNever {
+		t.Errorf("Expected pull policy from the user configuration, got %q", cfg.BuilderPullPolicy)
+	}
+	if cfg.Incremental {
+		t.Errorf("Expected the environment to take precedence over the user configuration")
+	}
+	if cfg.Destination != "/from/flag" {
+		t.Errorf("Expected the command line flag to take precedence, got %q", cfg.Destination)
+	}
+	if c.Flag("pull-policy").Changed {
+		t.Errorf("Expected flags set from the user configuration not to be marked as changed")
+	}
+
+	userConfig.Defaults = map[string]string{"pull-policy": "sometimes"}
+	if err := config.SaveUserConfig(userConfig, config.UserConfigPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindEnvironment(&cobra.Command{}); err != nil {
+		t.Errorf("Unexpected error for a command without the flag: %v", err)
+	}
+	c = &cobra.Command{}
+	AddCommonFlags(c, &api.Config{})
+	if err := BindEnvironment(c); err == nil {
+		t.Errorf("Expected an error for an invalid value of the user configuration")
+	}
+}
+
+func TestBindUserConfigAfterRestore(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	userConfig := &config.UserConfig{
+		APIVersion: config.ConfigAPIVersion,
+		Kind:       config.UserConfigKind,
+		Defaults:   map[string]string{"build-arg": "FROM_USER=1", "pull-policy": "never"},
+	}
+	if err := config.SaveUserConfig(userConfig, config.UserConfigPath()); err != nil {
+		t.Fatal(err)
+	}
+
+	var buildArgs []string
+	c := &cobra.Command{}
+	AddCommonFlags(c, &api.Config{})
+	c.Flags().StringArrayVar(&buildArgs, "build-arg", []string{}, "")
+	if err := BindEnvironmentVariables(c); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// a flag restored from the configuration file
+	if err := c.Flags().Set("build-arg", "FROM_FILE=1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindUserConfig(c); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(buildArgs, []string{"FROM_FILE=1"}) {
+		t.Errorf("Expected the restored build arguments only, got %q", buildArgs)
+	}
+	if c.Flag("pull-policy").Changed {
+		t.Errorf("Expected flags set from the user configuration not to be marked as changed")
+	}
+}
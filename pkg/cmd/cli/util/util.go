@@ -2,8 +2,7 @@ package cmd
 
 import (
 	"flag"
-	"os"
-	"path/filepath"
+	"fmt"
 
 	log "k8s.io/klog/v2"
 
@@ -11,6 +10,9 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
 )
 
 // AddCommonFlags adds the common flags for usage, build and rebuild commands
@@ -21,18 +23,40 @@ func AddCommonFlags(c *cobra.Command, cfg *api.Config) {
 		"Perform an incremental build")
 	c.Flags().BoolVar(&(cfg.RemovePreviousImage), "rm", false,
 		"Remove the previous image during incremental builds")
-	c.Flags().StringVar(&(cfg.CallbackURL), "callback-url", "",
-		"Specify a URL to invoke via HTTP POST upon build completion")
+	c.Flags().StringArrayVar(&(cfg.CallbackURLs), "callback-url", []string{},
+		"Specify a URL to invoke via HTTP POST upon build completion, can be used multiple times")
+	c.Flags().StringSliceVar(&(cfg.CallbackEvents), "callback-events", []string{},
+		"Specify a comma-separated list of lifecycle events the callback URLs are also invoked upon (started, assembled, pushed)")
+	c.Flags().IntVar(&(cfg.CallbackRetries), "callback-retries", api.DefaultCallbackRetries,
+		"Specify the number of times the callback is retried, with an exponential backoff, when the URL cannot be reached or answers with a server error")
+	c.Flags().DurationVar(&(cfg.CallbackTimeout), "callback-timeout", 0,
+		"Specify the time each attempt to invoke the callback URL is given, or 0 for no timeout")
+	c.Flags().StringArrayVar(&(cfg.CallbackHeaders), "callback-header", []string{},
+		"Specify a header, in the 'Name: value' form, added to the callback requests, e.g. to authenticate them with a token, can be used multiple times")
+	c.Flags().StringVar(&(cfg.CallbackCertFile), "callback-cert", "",
+		"Specify the client certificate authenticating the callback requests")
+	c.Flags().StringVar(&(cfg.CallbackKeyFile), "callback-key", "",
+		"Specify the key of the client certificate authenticating the callback requests")
+	c.Flags().StringVar(&(cfg.CallbackCAFile), "callback-ca", "",
+		"Specify the certificate authority verifying the server of the callback URL, instead of the system ones")
+	c.Flags().StringSliceVar(&(cfg.RedactEnvPatterns), "redact-env", api.DefaultRedactEnvPatterns,
+		"Specify a comma-separated list of glob patterns, matched case insensitively, of the environment variables whose values are redacted from the logs, the described configuration and the saved .s2ifile")
 	c.Flags().VarP(&(cfg.BuilderPullPolicy), "pull-policy", "p",
-		"Specify when to pull the builder image (always, never or if-not-present)")
+		"Specify when to pull the builder image (always, never, if-not-present or if-changed)")
 	c.Flags().Var(&(cfg.PreviousImagePullPolicy), "incremental-pull-policy",
-		"Specify when to pull the previous image for incremental builds (always, never or if-not-present)")
+		"Specify when to pull the previous image for incremental builds (always, never, if-not-present or if-changed)")
 	c.Flags().Var(&(cfg.RuntimeImagePullPolicy), "runtime-pull-policy",
-		"Specify when to pull the runtime image (always, never or if-not-present)")
+		"Specify when to pull the runtime image (always, never, if-not-present or if-changed)")
 	c.Flags().BoolVar(&(cfg.PreserveWorkingDir), "save-temp-dir", false,
 		"Save the temporary directory used by S2I instead of deleting it")
-	c.Flags().StringVarP(&(cfg.DockerCfgPath), "dockercfg-path", "", filepath.Join(os.Getenv("HOME"), ".docker/config.json"),
-		"Specify the path to the Docker configuration file")
+	c.Flags().StringVarP(&(cfg.DockerCfgPath), "dockercfg-path", "", docker.DefaultDockerCfgPath(),
+		"Specify the path to the Docker configuration file (defaults to $DOCKER_CONFIG/config.json, ~/.docker/config.json or ~/.dockercfg, whichever exists first)")
+	c.Flags().Var(&(cfg.PullCredentials), "pull-auth",
+		"Specify the credentials pulling the builder image, in the user:password[@registry] form, overriding the Docker configuration file")
+	c.Flags().Var(&(cfg.IncrementalCredentials), "incremental-auth",
+		"Specify the credentials pulling the previous image for incremental builds, in the user:password[@registry] form, overriding the Docker configuration file")
+	c.Flags().Var(&(cfg.RuntimeCredentials), "runtime-auth",
+		"Specify the credentials pulling the runtime image, in the user:password[@registry] form, overriding the Docker configuration file")
 	c.Flags().StringVarP(&(cfg.Destination), "destination", "d", "",
 		"Specify a destination location for untar operation")
 }
@@ -47,7 +71,57 @@ func SetupLogger(flags *pflag.FlagSet) {
 		flags.Int32Var(loglevelPtr, "loglevel", 0, "Set the level of log output (0-5)")
 	}
 
-	// FIXME glog had only option to redirect output to stderr
-	// the preferred for S2I would be to redirect to stdout - klog can support via `klog.SetOutput()`
+	// klog only provides the verbosity of the messages, which are written to
+	// stderr, and to the file given by --log-file
 	flag.CommandLine.Set("logtostderr", "true")
 }
+
+// SetupTempDir adds the --tmpdir flag, setting the directory under which s2i
+// creates its working directories and temporary files.
+func SetupTempDir(flags *pflag.FlagSet) {
+	flags.Var(tempDir{}, "tmpdir", "Set the directory under which s2i creates its working directories and temporary files (defaults to $TMPDIR or /tmp)")
+}
+
+// tempDir is the value of the --tmpdir flag, held by the default working
+// directory manager.
+type tempDir struct{}
+
+// String implements the String() function of pflags.Value interface
+func (tempDir) String() string {
+	return fs.TempDir()
+}
+
+// Set implements the Set() function of pflags.Value interface
+func (tempDir) Set(dir string) error {
+	fs.SetTempDir(dir)
+	return nil
+}
+
+// Type implements the Type() function of pflags.Value interface
+func (tempDir) Type() string {
+	return "string"
+}
+
+// SetupLogFile adds the --log-file and --log-max-size flags to the command,
+// logging the messages of s2i to the rotated log file before the command and
+// its subcommands run.
+func SetupLogFile(c *cobra.Command) {
+	var logFile string
+	var logMaxSize int64
+	c.PersistentFlags().StringVar(&logFile, "log-file", "", "Write the messages of s2i, without the output of the containers, to the specified file as well")
+	c.PersistentFlags().Int64Var(&logMaxSize, "log-max-size", 0, fmt.Sprintf("Rotate the log file when it grows beyond the specified size in megabytes, keeping %d rotated files (0 disables the rotation)", utillog.RotatedFiles))
+	c.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if len(logFile) == 0 {
+			return nil
+		}
+		if logMaxSize < 0 {
+			return fmt.Errorf("invalid --log-max-size %d, must not be negative", logMaxSize)
+		}
+		file, err := utillog.NewRotatingFile(logFile, logMaxSize*1024*1024)
+		if err != nil {
+			return fmt.Errorf("unable to open the log file: %v", err)
+		}
+		utillog.StderrLog.(*utillog.FileLogger).SetFile(file)
+		return nil
+	}
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
 )
 
@@ -21,8 +24,22 @@ var savedEnvMatcher = regexp.MustCompile("env-[0-9]+")
 // DefaultConfigPath specifies the default location of the S2I config file
 const DefaultConfigPath = ".s2ifile"
 
+// EnvironmentAnnotation is the annotation of the flags set from an S2I_*
+// environment variable, which are not saved to the config file.
+const EnvironmentAnnotation = "s2i/from-env"
+
+const (
+	// ConfigAPIVersion is the current version of the S2I config file schema
+	ConfigAPIVersion = "s2i.openshift.io/v1"
+
+	// ConfigKind is the kind of the S2I config file
+	ConfigKind = "Config"
+)
+
 // Config represents a basic serialization for the S2I build options.
 type Config struct {
+	APIVersion   string            `json:"apiVersion" yaml:"apiVersion"`
+	Kind         string            `json:"kind" yaml:"kind"`
 	Source       string            `json:"source" yaml:"source"`
 	BuilderImage string            `json:"builderImage" yaml:"builderImage"`
 	Tag          string            `json:"tag,omitempty" yaml:"tag,omitempty"`
@@ -32,53 +49,109 @@ type Config struct {
 // Save persists the S2I command line arguments to disk.
 func Save(config *api.Config, cmd *cobra.Command) {
 	c := Config{
+		APIVersion:   ConfigAPIVersion,
+		Kind:         ConfigKind,
 		BuilderImage: config.BuilderImage,
-		Source:       config.Source.String(),
 		Tag:          config.Tag,
 		Flags:        make(map[string]string),
 	}
+	// the builds of --from-artifact have no source
+	if config.Source != nil {
+		c.Source = config.Source.String()
+	}
+	// the variables of these flags may hold secrets too
+	envFlags := map[string][]api.EnvironmentSpec{
+		"build-arg":   config.BuildArgs,
+		"run-env":     config.RunEnvironment,
+		"runtime-env": config.RuntimeEnvironment,
+	}
 	cmd.Flags().Visit(func(f *pflag.Flag) {
-		if f.Name == "env" {
+		if _, fromEnv := f.Annotations[EnvironmentAnnotation]; fromEnv {
+			log.V(1).Infof("Not saving --%s, set from the environment, to %s", f.Name, DefaultConfigPath)
+		} else if f.Name == "env" {
 			for i, env := range config.Environment {
+				// the variables matching the redaction patterns hold secrets,
+				// which are not persisted
+				if util.IsRedactedEnv(env.Name, config.RedactEnvPatterns) {
+					log.V(1).Infof("Not saving the environment variable %s to %s", env.Name, DefaultConfigPath)
+					continue
+				}
 				c.Flags[fmt.Sprintf("%s-%d", f.Name, i)] = fmt.Sprintf("%s=%s", env.Name, env.Value)
 			}
+		} else if list, ok := envFlags[f.Name]; ok {
+			saved := api.EnvironmentList{}
+			for _, env := range list {
+				if util.IsRedactedEnv(env.Name, config.RedactEnvPatterns) {
+					log.V(1).Infof("Not saving the --%s variable %s to %s", f.Name, env.Name, DefaultConfigPath)
+					continue
+				}
+				saved = append(saved, env)
+			}
+			if len(saved) > 0 {
+				c.Flags[f.Name] = saved.String()
+			}
+		} else if f.Name == "inject-literal" {
+			// the literal injections usually hold secrets, which are not persisted
+			log.V(1).Infof("Not saving the literal injections to %s", DefaultConfigPath)
+		} else if f.Name == "pull-auth" || f.Name == "incremental-auth" || f.Name == "runtime-auth" || f.Name == "push-auth" {
+			log.V(1).Infof("Not saving the --%s credentials to %s", f.Name, DefaultConfigPath)
 		} else {
 			c.Flags[f.Name] = f.Value.String()
 		}
 	})
-	data, err := json.Marshal(c)
-	if err != nil {
-		log.V(1).Infof("Unable to serialize to %s: %v", DefaultConfigPath, err)
-		return
-	}
-	if err := ioutil.WriteFile(DefaultConfigPath, data, 0644); err != nil {
+	if err := write(&c, DefaultConfigPath); err != nil {
 		log.V(1).Infof("Unable to save %s: %v", DefaultConfigPath, err)
 	}
 	return
 }
 
-// Restore loads the arguments from disk and prefills the Request
-func Restore(config *api.Config, cmd *cobra.Command) {
-	data, err := ioutil.ReadFile(DefaultConfigPath)
+// write stores the given config to the file of the given path.
+func write(c *Config, path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Restore loads the arguments from disk and prefills the Request. A missing or
+// unparsable config file is not an error, while an invalid one is. A config
+// file of the legacy format is stored again once migrated.
+func Restore(config *api.Config, cmd *cobra.Command) error {
+	path := DefaultConfigPath
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		data, err = ioutil.ReadFile(".stifile")
+		path = ".stifile"
+		data, err = ioutil.ReadFile(path)
 		if err != nil {
 			log.V(1).Infof("Unable to restore %s: %v", DefaultConfigPath, err)
-			return
+			return nil
 		}
 		log.Infof("DEPRECATED: Use %s instead of .stifile", DefaultConfigPath)
 	}
 
-	c := Config{}
-	if err := json.Unmarshal(data, &c); err != nil {
-		log.V(1).Infof("Unable to parse %s: %v", DefaultConfigPath, err)
-		return
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		log.Warningf("Unable to parse %s: %v", path, err)
+		return nil
 	}
-
-	source, err := git.Parse(c.Source)
+	migrated, err := upgrade(c, cmd)
 	if err != nil {
-		log.V(1).Infof("Unable to parse %s: %v", c.Source, err)
-		return
+		return fmt.Errorf("invalid %s: %v", path, err)
+	}
+	if migrated {
+		if err := write(c, path); err != nil {
+			log.Warningf("Unable to store the migrated %s: %v", path, err)
+		} else {
+			log.V(1).Infof("Stored %s migrated to %s", path, ConfigAPIVersion)
+		}
+	}
+
+	var source *git.URL
+	if len(c.Source) > 0 {
+		if source, err = git.Parse(c.Source); err != nil {
+			return fmt.Errorf("invalid %s: unable to parse source %q: %v", path, c.Source, err)
+		}
 	}
 
 	config.BuilderImage = c.BuilderImage
@@ -108,7 +181,80 @@ func Restore(config *api.Config, cmd *cobra.Command) {
 			if cmd.Flag(name).Changed {
 				continue
 			}
-			cmd.Flags().Set(name, value)
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return fmt.Errorf("invalid %s: flag %q: %v", path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Load parses the given S2I config file, migrating it from the legacy
+// unversioned format if needed, and validates it against the flags of the given
+// command.
+func Load(data []byte, cmd *cobra.Command) (*Config, error) {
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("unable to parse: %v", err)
+	}
+	if _, err := upgrade(c, cmd); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// upgrade migrates the given config to the current schema version and
+// validates it against the flags of the given command. It returns true if the
+// config was migrated.
+func upgrade(c *Config, cmd *cobra.Command) (bool, error) {
+	migrated := c.APIVersion != ConfigAPIVersion
+	if err := migrate(c); err != nil {
+		return false, err
+	}
+	if errs := validate(c, cmd); len(errs) > 0 {
+		return false, errors.New(strings.Join(errs, ", "))
+	}
+	return migrated, nil
+}
+
+// migrate converts the given config to the current schema version.
+func migrate(c *Config) error {
+	switch c.APIVersion {
+	case ConfigAPIVersion:
+	case "":
+		// configs saved before the schema was versioned do not record the
+		// version, but are otherwise equal to v1
+		log.V(1).Infof("Migrating unversioned %s to %s", DefaultConfigPath, ConfigAPIVersion)
+		c.APIVersion = ConfigAPIVersion
+		if len(c.Kind) == 0 {
+			c.Kind = ConfigKind
+		}
+	default:
+		return fmt.Errorf("unsupported apiVersion %q, this version of s2i supports %q; upgrade s2i or remove the file and use --use-config to create it again", c.APIVersion, ConfigAPIVersion)
+	}
+	return nil
+}
+
+// validate returns the list of problems found in the given config.
+func validate(c *Config, cmd *cobra.Command) []string {
+	errs := []string{}
+	if c.Kind != ConfigKind {
+		errs = append(errs, fmt.Sprintf("kind must be %q, got %q", ConfigKind, c.Kind))
+	}
+	if len(c.Source) == 0 && len(c.Flags["from-artifact"]) == 0 {
+		errs = append(errs, "source is required")
+	}
+	if len(c.BuilderImage) == 0 {
+		errs = append(errs, "builderImage is required")
+	}
+	for name := range c.Flags {
+		if savedEnvMatcher.MatchString(name) {
+			name = "env"
+		}
+		if cmd.Flag(name) == nil {
+			errs = append(errs, fmt.Sprintf("unknown flag %q", name))
 		}
 	}
+	sort.Strings(errs)
+	return errs
 }
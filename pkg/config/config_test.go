@@ -0,0 +1,253 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+func newTestCommand() *cobra.Command {
+	c := &cobra.Command{}
+	c.Flags().String("pull-policy", "", "")
+	c.Flags().StringArray("env", []string{}, "")
+	c.Flags().StringArray("build-arg", []string{}, "")
+	c.Flags().StringArray("run-env", []string{}, "")
+	c.Flags().String("from-artifact", "", "")
+	return c
+}
+
+func TestLoad(t *testing.T) {
+	tests := map[string]struct {
+		data        string
+		expectedErr string
+	}{
+		"current": {
+			data: `{"apiVersion":"s2i.openshift.io/v1","kind":"Config","source":".","builderImage":"builder","flags":{"pull-policy":"never","env-0":"A=B"}}`,
+		},
+		"legacy": {
+			data: `{"source":".","builderImage":"builder","flags":{"pull-policy":"never"}}`,
+		},
+		"future version": {
+			data:        `{"apiVersion":"s2i.openshift.io/v2","kind":"Config","source":".","builderImage":"builder"}`,
+			expectedErr: `unsupported apiVersion "s2i.openshift.io/v2"`,
+		},
+		"wrong kind": {
+			data:        `{"apiVersion":"s2i.openshift.io/v1","kind":"Pod","source":".","builderImage":"builder"}`,
+			expectedErr: `kind must be "Config"`,
+		},
+		"missing builder image": {
+			data:        `{"source":"."}`,
+			expectedErr: "builderImage is required",
+		},
+		"unknown flag": {
+			data:        `{"source":".","builderImage":"builder","flags":{"no-such-flag":"true"}}`,
+			expectedErr: `unknown flag "no-such-flag"`,
+		},
+		"invalid json": {
+			data:        `{"source":`,
+			expectedErr: "unable to parse",
+		},
+	}
+	for desc, tc := range tests {
+		c, err := Load([]byte(tc.data), newTestCommand())
+		if len(tc.expectedErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("%s: expected error containing %q, got %v", desc, tc.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+			continue
+		}
+		if c.APIVersion != ConfigAPIVersion || c.Kind != ConfigKind {
+			t.Errorf("%s: expected config to be migrated to %s, got %s %s", desc, ConfigAPIVersion, c.APIVersion, c.Kind)
+		}
+	}
+}
+
+func TestLoadUserConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s2i", "config.yaml")
+	c, err := LoadUserConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error for a missing user configuration: %v", err)
+	}
+	c.Defaults["pull-policy"] = "if-not-present"
+	if err := SaveUserConfig(c, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := LoadUserConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, c) {
+		t.Errorf("expected %+v, got %+v", c, loaded)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("apiVersion: s2i.openshift.io/v1\nkind: Config\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadUserConfig(path); err == nil || !strings.Contains(err.Error(), `kind must be "UserConfig"`) {
+		t.Errorf("expected an error for the wrong kind, got %v", err)
+	}
+}
+
+func TestSaveRedactedEnvironment(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newTestCommand()
+	cmd.Flags().Set("env", "DEBUG=true")
+	cmd.Flags().Set("env", "GITHUB_TOKEN=s3cr3t")
+	cmd.Flags().Set("build-arg", "NPM_TOKEN=s3cr3t")
+	cmd.Flags().Set("build-arg", "NODE_ENV=production")
+	cmd.Flags().Set("run-env", "DB_PASSWORD=s3cr3t")
+	config := &api.Config{
+		BuilderImage:      "builder",
+		Source:            git.MustParse("."),
+		Environment:       api.EnvironmentList{{Name: "DEBUG", Value: "true"}, {Name: "GITHUB_TOKEN", Value: "s3cr3t"}},
+		BuildArgs:         api.BuildArgList{{Name: "NPM_TOKEN", Value: "s3cr3t"}, {Name: "NODE_ENV", Value: "production"}},
+		RunEnvironment:    api.EnvironmentList{{Name: "DB_PASSWORD", Value: "s3cr3t"}},
+		RedactEnvPatterns: api.DefaultRedactEnvPatterns,
+	}
+	Save(config, cmd)
+
+	data, err := ioutil.ReadFile(DefaultConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(data, newTestCommand())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"env-0": "DEBUG=true", "build-arg": "NODE_ENV=production"}
+	if !reflect.DeepEqual(c.Flags, expected) {
+		t.Errorf("expected the flags %v, got %v", expected, c.Flags)
+	}
+}
+
+func TestSaveFlagsFromEnvironment(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newTestCommand()
+	cmd.Flags().Set("pull-policy", "never")
+	cmd.Flags().SetAnnotation("pull-policy", EnvironmentAnnotation, []string{"S2I_PULL_POLICY"})
+	cmd.Flags().Set("env", "DEBUG=true")
+	config := &api.Config{
+		BuilderImage: "builder",
+		Source:       git.MustParse("."),
+		Environment:  api.EnvironmentList{{Name: "DEBUG", Value: "true"}},
+	}
+	Save(config, cmd)
+
+	data, err := ioutil.ReadFile(DefaultConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(data, newTestCommand())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"env-0": "DEBUG=true"}
+	if !reflect.DeepEqual(c.Flags, expected) {
+		t.Errorf("expected the flags %v, got %v", expected, c.Flags)
+	}
+}
+
+func TestSaveWithoutSource(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newTestCommand()
+	cmd.Flags().Set("from-artifact", "target/app.jar")
+	Save(&api.Config{BuilderImage: "builder", ArtifactPath: "target/app.jar"}, cmd)
+
+	data, err := ioutil.ReadFile(DefaultConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(data, newTestCommand())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Source) != 0 || c.Flags["from-artifact"] != "target/app.jar" {
+		t.Errorf("expected no source and the artifact flag, got %q and %v", c.Source, c.Flags)
+	}
+
+	restored := &api.Config{}
+	if err := Restore(restored, newTestCommand()); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Source != nil || restored.BuilderImage != "builder" {
+		t.Errorf("expected the builder image without a source, got %q and %v", restored.BuilderImage, restored.Source)
+	}
+}
+
+func TestRestoreMigrate(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := `{"source":".","builderImage":"builder","flags":{"pull-policy":"never"}}`
+	if err := ioutil.WriteFile(DefaultConfigPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	restored := &api.Config{}
+	if err := Restore(restored, newTestCommand()); err != nil {
+		t.Fatal(err)
+	}
+	if restored.BuilderImage != "builder" {
+		t.Errorf("expected the builder image to be restored, got %q", restored.BuilderImage)
+	}
+	data, err := ioutil.ReadFile(DefaultConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"apiVersion":"s2i.openshift.io/v1"`) || !strings.Contains(string(data), `"kind":"Config"`) {
+		t.Errorf("expected the migrated config to be stored, got %s", data)
+	}
+
+	// an unparsable file is ignored
+	if err := ioutil.WriteFile(DefaultConfigPath, []byte(`{"source":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	restored = &api.Config{}
+	if err := Restore(restored, newTestCommand()); err != nil {
+		t.Errorf("expected the unparsable file to be ignored, got %v", err)
+	}
+	if len(restored.BuilderImage) > 0 {
+		t.Errorf("expected nothing to be restored, got %q", restored.BuilderImage)
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserConfigKind is the kind of the user configuration file
+const UserConfigKind = "UserConfig"
+
+// UserConfig holds the defaults of the flags of the s2i commands for the
+// user, which apply beneath the command line flags, the S2I_* environment
+// variables and the .s2ifile of the directory.
+type UserConfig struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string `json:"kind" yaml:"kind"`
+	// Defaults are the default values of the flags, by flag name.
+	Defaults map[string]string `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+}
+
+// UserConfigPath returns the path of the user configuration file,
+// $XDG_CONFIG_HOME/s2i/config.yaml, which defaults to ~/.config/s2i/config.yaml,
+// or an empty string if the home directory of the user is unknown.
+func UserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if len(dir) == 0 {
+		var err error
+		if runtime.GOOS == "windows" {
+			dir, err = os.UserConfigDir()
+		} else {
+			dir, err = os.UserHomeDir()
+			dir = filepath.Join(dir, ".config")
+		}
+		if err != nil {
+			return ""
+		}
+	}
+	return filepath.Join(dir, "s2i", "config.yaml")
+}
+
+// LoadUserConfig reads the user configuration file of the given path. A
+// missing file is an empty configuration, while an invalid one is an error.
+func LoadUserConfig(path string) (*UserConfig, error) {
+	c := &UserConfig{APIVersion: ConfigAPIVersion, Kind: UserConfigKind, Defaults: map[string]string{}}
+	if len(path) == 0 {
+		return c, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid %s: unable to parse: %v", path, err)
+	}
+	if c.APIVersion != ConfigAPIVersion {
+		return nil, fmt.Errorf("invalid %s: unsupported apiVersion %q, this version of s2i supports %q", path, c.APIVersion, ConfigAPIVersion)
+	}
+	if c.Kind != UserConfigKind {
+		return nil, fmt.Errorf("invalid %s: kind must be %q, got %q", path, UserConfigKind, c.Kind)
+	}
+	if c.Defaults == nil {
+		c.Defaults = map[string]string{}
+	}
+	return c, nil
+}
+
+// SaveUserConfig writes the user configuration to the file of the given
+// path, creating its directory.
+func SaveUserConfig(c *UserConfig, path string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("unable to locate the user configuration file, the home directory is unknown")
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,302 @@
+package containerd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+// newTar returns a tar handler which does not exclude any file.
+func newTar() s2itar.Tar {
+	t := s2itar.New(fs.NewFileSystem())
+	t.SetExclusionPattern(nil)
+	return t
+}
+
+// CopyToContainer extracts the given tar stream into the path of the
+// container, which is created by containerd if it was not started yet.
+func (c *Client) CopyToContainer(ctx context.Context, id, path string, content io.Reader, options dockertypes.CopyToContainerOptions) error {
+	if err := c.ensureCreated(ctx, id); err != nil {
+		return err
+	}
+	dir, err := fs.MkdirTemp("containerd-upload")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := newTar().ExtractTarStream(dir, content); err != nil {
+		return err
+	}
+	_, err = c.run(ctx, "cp", dir+string(filepath.Separator)+".", id+":"+path)
+	return err
+}
+
+// CopyFromContainer returns a tar stream with the content of the path of the
+// container, whose entries are prefixed with the base name of the path.
+func (c *Client) CopyFromContainer(ctx context.Context, id, path string) (io.ReadCloser, dockertypes.ContainerPathStat, error) {
+	if err := c.ensureCreated(ctx, id); err != nil {
+		return nil, dockertypes.ContainerPathStat{}, err
+	}
+	dir, err := fs.MkdirTemp("containerd-download")
+	if err != nil {
+		return nil, dockertypes.ContainerPathStat{}, err
+	}
+	target := filepath.Join(dir, filepath.Base(path))
+	if _, err := c.run(ctx, "cp", id+":"+path, target); err != nil {
+		os.RemoveAll(dir)
+		return nil, dockertypes.ContainerPathStat{}, err
+	}
+	info, err := os.Lstat(target)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, dockertypes.ContainerPathStat{}, err
+	}
+	stat := dockertypes.ContainerPathStat{
+		Name:  info.Name(),
+		Size:  info.Size(),
+		Mode:  info.Mode(),
+		Mtime: info.ModTime(),
+	}
+	return &removeOnClose{ReadCloser: newTar().CreateTarStreamReader(target, true), dir: dir}, stat, nil
+}
+
+// ContainerCommit creates an image from the given container. nerdctl only
+// applies CMD and ENTRYPOINT changes when committing, so the remaining
+// configuration of the image is applied by building a new image on top of the
+// committed one.
+func (c *Client) ContainerCommit(ctx context.Context, id string, options dockercontainer.CommitOptions) (dockertypes.IDResponse, error) {
+	ref := options.Reference
+	if len(ref) == 0 {
+		ref = "s2i-commit-" + strings.ToLower(id)
+	}
+	args := []string{"commit"}
+	if config := options.Config; config != nil {
+		if len(config.Cmd) > 0 {
+			args = append(args, "--change", "CMD "+jsonArray(config.Cmd))
+		}
+		if len(config.Entrypoint) > 0 {
+			args = append(args, "--change", "ENTRYPOINT "+jsonArray(config.Entrypoint))
+		}
+	}
+	if len(options.Comment) > 0 {
+		args = append(args, "--message", options.Comment)
+	}
+	if len(options.Author) > 0 {
+		args = append(args, "--author", options.Author)
+	}
+	if _, err := c.run(ctx, append(args, id, ref)...); err != nil {
+		return dockertypes.IDResponse{}, err
+	}
+	if dockerfile := commitDockerfile(ref, options.Config); len(dockerfile) > 0 {
+		if err := c.buildDockerfile(ctx, ref, dockerfile); err != nil {
+			return dockertypes.IDResponse{}, err
+		}
+	}
+	inspect, _, err := c.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return dockertypes.IDResponse{}, err
+	}
+	return dockertypes.IDResponse{ID: inspect.ID}, nil
+}
+
+// ContainerExport is not supported, so the containerd engine cannot fall back
+// to exporting and importing the containers whose commit fails.
+func (c *Client) ContainerExport(ctx context.Context, id string) (io.ReadCloser, error) {
+	return nil, errdefs.NotImplemented(fmt.Errorf("the containerd engine cannot export container %q", id))
+}
+
+// ImageImport is not supported, see ContainerExport.
+func (c *Client) ImageImport(ctx context.Context, source image.ImportSource, ref string, options image.ImportOptions) (io.ReadCloser, error) {
+	return nil, errdefs.NotImplemented(errors.New("the containerd engine cannot import the file system of containers"))
+}
+
+// commitDockerfile returns the Dockerfile applying the user, environment and
+// labels of the given configuration to the image, or an empty string if there
+// is nothing to apply.
+func commitDockerfile(image string, config *dockercontainer.Config) string {
+	if config == nil || (len(config.User) == 0 && len(config.Env) == 0 && len(config.Labels) == 0) {
+		return ""
+	}
+	buffer := bytes.Buffer{}
+	fmt.Fprintf(&buffer, "FROM %s\n", image)
+	if len(config.User) > 0 {
+		fmt.Fprintf(&buffer, "USER %s\n", config.User)
+	}
+	for _, env := range config.Env {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fmt.Fprintf(&buffer, "ENV %s=\"%s\"\n", parts[0], util.EscapeDockerfileValue(parts[1]))
+	}
+	keys := make([]string, 0, len(config.Labels))
+	for k := range config.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buffer, "LABEL \"%s\"=\"%s\"\n", util.EscapeDockerfileValue(k), util.EscapeDockerfileValue(config.Labels[k]))
+	}
+	return buffer.String()
+}
+
+// buildDockerfile builds the given Dockerfile, without any context, into the
+// given image.
+func (c *Client) buildDockerfile(ctx context.Context, image, dockerfile string) error {
+	dir, err := fs.MkdirTemp("containerd-commit")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0600); err != nil {
+		return err
+	}
+	_, err = c.run(ctx, "build", "--tag", image, dir)
+	return err
+}
+
+// ImageBuild builds an image from the given build context using BuildKit.
+// The output of the build is returned as plain text.
+func (c *Client) ImageBuild(ctx context.Context, buildContext io.Reader, options dockertypes.ImageBuildOptions) (dockertypes.ImageBuildResponse, error) {
+	dir, err := fs.MkdirTemp("containerd-build")
+	if err != nil {
+		return dockertypes.ImageBuildResponse{}, err
+	}
+	contextReader, err := decompress(buildContext)
+	if err == nil {
+		err = newTar().ExtractTarStream(dir, contextReader)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return dockertypes.ImageBuildResponse{}, err
+	}
+	if options.Memory != 0 || options.MemorySwap != 0 || len(options.CgroupParent) > 0 {
+		log.V(2).Infof("The containerd engine ignores the resource limits of image builds")
+	}
+
+	// the ID of the image is reported like the Docker engine does, in the aux
+	// message ending the output of the build
+	iidFile := dir + ".iid"
+	cmd := c.command(ctx, append([]string{"build", "--iidfile", iidFile}, buildArgs(options, dir)...)...)
+	outReader, outWriter := io.Pipe()
+	cmd.Stdout = outWriter
+	cmd.Stderr = outWriter
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return dockertypes.ImageBuildResponse{}, err
+	}
+	go func() {
+		defer os.RemoveAll(dir)
+		defer os.Remove(iidFile)
+		if err := cmd.Wait(); err != nil {
+			outWriter.CloseWithError(fmt.Errorf("%s build: %v", nerdctlBinary, err))
+			return
+		}
+		if id, err := ioutil.ReadFile(iidFile); err == nil {
+			aux, _ := json.Marshal(map[string]interface{}{"aux": map[string]string{"ID": strings.TrimSpace(string(id))}})
+			outWriter.Write(append([]byte("\n"), append(aux, '\n')...))
+		}
+		outWriter.Close()
+	}()
+	return dockertypes.ImageBuildResponse{Body: outReader}, nil
+}
+
+// buildArgs returns the arguments of "nerdctl build" building the context
+// extracted into dir with the given options.
+func buildArgs(options dockertypes.ImageBuildOptions, dir string) []string {
+	args := []string{}
+	for _, tag := range options.Tags {
+		args = append(args, "--tag", tag)
+	}
+	if len(options.Dockerfile) > 0 {
+		args = append(args, "--file", filepath.Join(dir, options.Dockerfile))
+	}
+	if options.NoCache {
+		args = append(args, "--no-cache")
+	}
+	if len(options.NetworkMode) > 0 {
+		args = append(args, "--network", options.NetworkMode)
+	}
+	names := make([]string, 0, len(options.BuildArgs))
+	for name := range options.BuildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := options.BuildArgs[name]; value != nil {
+			args = append(args, "--build-arg", name+"="+*value)
+		} else {
+			args = append(args, "--build-arg", name)
+		}
+	}
+	keys := make([]string, 0, len(options.Labels))
+	for k := range options.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--label", k+"="+options.Labels[k])
+	}
+	return append(args, dir)
+}
+
+// decompress returns a reader of the uncompressed content of the given gzip
+// or zstd compressed, or uncompressed, stream.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		decoder, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return br, nil
+}
+
+// jsonArray formats the given list in the JSON form of Dockerfile
+// instructions.
+func jsonArray(list []string) string {
+	data, _ := json.Marshal(list)
+	return string(data)
+}
+
+// removeOnClose removes a directory once the wrapped reader is closed.
+type removeOnClose struct {
+	io.ReadCloser
+	dir string
+}
+
+func (r *removeOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	os.RemoveAll(r.dir)
+	return err
+}
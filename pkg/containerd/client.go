@@ -0,0 +1,443 @@
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/types"
+	"github.com/distribution/reference"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/openshift/source-to-image/pkg/util"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+const (
+	// DefaultAddress is the default address of the containerd socket.
+	DefaultAddress = "/run/containerd/containerd.sock"
+
+	// DefaultNamespace is the default containerd namespace holding the images
+	// and containers used by S2I.
+	DefaultNamespace = "default"
+
+	// nerdctlBinary is the name of the nerdctl executable.
+	nerdctlBinary = "nerdctl"
+
+	// dockerHubConfigKey is the key of the Docker Hub credentials in a Docker
+	// configuration file.
+	dockerHubConfigKey = "https://index.docker.io/v1/"
+)
+
+// Client talks to containerd using nerdctl. It implements the subset of the
+// Docker engine API used by S2I, so that it can be used wherever a Docker
+// client is expected.
+type Client struct {
+	binary     string
+	address    string
+	namespace  string
+	registries *types.SystemContext
+
+	mu         sync.Mutex
+	containers map[string]*container
+	execs      map[string]*execution
+}
+
+// NewClient creates a new containerd client for the containerd instance
+// listening on the given address, which manages its images and containers in
+// the given namespace. The images are resolved with the given registries.conf
+// file, or the default one when empty.
+func NewClient(address, namespace, registriesConf string) (*Client, error) {
+	if len(address) == 0 {
+		address = DefaultAddress
+	}
+	if len(namespace) == 0 {
+		namespace = DefaultNamespace
+	}
+	binary, err := exec.LookPath(nerdctlBinary)
+	if err != nil {
+		return nil, fmt.Errorf("the containerd engine requires %s to be installed: %v", nerdctlBinary, err)
+	}
+	return &Client{
+		binary:     binary,
+		address:    address,
+		namespace:  namespace,
+		registries: registriesSystemContext(registriesConf),
+		containers: map[string]*container{},
+		execs:      map[string]*execution{},
+	}, nil
+}
+
+// command returns the nerdctl command running the given arguments against the
+// configured containerd address and namespace.
+func (c *Client) command(ctx context.Context, args ...string) *exec.Cmd {
+	args = append([]string{"--address", c.address, "--namespace", c.namespace}, args...)
+	log.V(5).Infof("Running %s %s", c.binary, strings.Join(safeForLoggingArgs(args), " "))
+	return exec.CommandContext(ctx, c.binary, args...)
+}
+
+// safeForLoggingArgs returns a copy of the nerdctl arguments where the values
+// of the environment variables and of the build arguments, which may hold
+// secrets, are masked.
+func safeForLoggingArgs(args []string) []string {
+	result := make([]string, len(args))
+	copy(result, args)
+	for i := 1; i < len(result); i++ {
+		if result[i-1] != "--env" && result[i-1] != "--build-arg" {
+			continue
+		}
+		if name, _, ok := strings.Cut(result[i], "="); ok {
+			result[i] = name + "=" + util.RedactedEnvValue
+		}
+	}
+	return result
+}
+
+// run runs nerdctl with the given arguments and returns its standard output.
+func (c *Client) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := c.command(ctx, args...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, commandError(args, stderr.String(), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// commandError converts a nerdctl failure into an error. Failures caused by
+// missing images or containers are reported as not found errors, so that they
+// can be told apart like the errors returned by the Docker engine API.
+func commandError(args []string, stderr string, err error) error {
+	msg := strings.TrimSpace(stderr)
+	if len(msg) == 0 {
+		msg = err.Error()
+	}
+	err = fmt.Errorf("%s %s: %s", nerdctlBinary, args[0], msg)
+	lower := strings.ToLower(msg)
+	if strings.Contains(lower, "not found") || strings.Contains(lower, "no such") {
+		return errdefs.NotFound(err)
+	}
+	return err
+}
+
+// ImageInspectWithRaw returns the image information and its raw representation.
+func (c *Client) ImageInspectWithRaw(ctx context.Context, name string) (dockertypes.ImageInspect, []byte, error) {
+	out, err := c.run(ctx, "image", "inspect", "--mode=dockercompat", name)
+	if err != nil {
+		return dockertypes.ImageInspect{}, nil, err
+	}
+	inspects := []json.RawMessage{}
+	if err := json.Unmarshal(out, &inspects); err != nil {
+		return dockertypes.ImageInspect{}, nil, fmt.Errorf("unable to parse the inspection of image %q: %v", name, err)
+	}
+	if len(inspects) == 0 {
+		return dockertypes.ImageInspect{}, nil, errdefs.NotFound(fmt.Errorf("no such image: %s", name))
+	}
+	inspect := dockertypes.ImageInspect{}
+	if err := json.Unmarshal(inspects[0], &inspect); err != nil {
+		return dockertypes.ImageInspect{}, nil, fmt.Errorf("unable to parse the inspection of image %q: %v", name, err)
+	}
+	return inspect, inspects[0], nil
+}
+
+// ImagePull pulls the given image. nerdctl does not report the progress of
+// the pull in the Docker JSON message format, so its output is logged and the
+// returned stream only reports the outcome of the pull.
+//
+// The image is resolved with the registries configuration (see pullSources)
+// and pulled from the first of its mirrors or its registry which serves it.
+// An image pulled from a mirror, or through a short-name alias, is tagged
+// with the requested reference, as the following inspections expect.
+func (c *Client) ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := pullSources(c.registries, ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range sources {
+		err = c.pull(ctx, ref, source, options.RegistryAuth)
+		if err != nil {
+			log.V(2).Infof("Unable to pull image %q from %s: %v", ref, source.reference, err)
+			continue
+		}
+		if source.reference != named.String() {
+			if err := c.ImageTag(ctx, source.reference, ref); err != nil {
+				return nil, err
+			}
+			if _, err := c.run(ctx, "image", "rm", source.reference); err != nil {
+				log.V(3).Infof("Unable to remove the reference %q to image %q: %v", source.reference, ref, err)
+			}
+		}
+		return ioutil.NopCloser(&bytes.Buffer{}), nil
+	}
+	return nil, err
+}
+
+// pull pulls the image of the requested reference from the given source. The
+// credentials passed along the pull request are only used when the source is
+// hosted by the registry of the requested reference, the mirrors are pulled
+// from with the credentials of the Docker configuration file of the user.
+func (c *Client) pull(ctx context.Context, ref string, source pullSource, encodedAuth string) error {
+	args := []string{"pull"}
+	if source.insecure {
+		args = append(args, "--insecure-registry")
+	}
+	cmd := c.command(ctx, append(args, source.reference)...)
+	if len(encodedAuth) > 0 && sameRegistry(ref, source.reference) {
+		configDir, err := writeAuthConfig(ref, encodedAuth)
+		if err != nil {
+			return err
+		}
+		if len(configDir) > 0 {
+			defer os.RemoveAll(configDir)
+			cmd.Env = append(os.Environ(), "DOCKER_CONFIG="+configDir)
+		}
+	}
+	out, err := cmd.CombinedOutput()
+	log.V(4).Infof("pulling image %s: %s", source.reference, out)
+	if err != nil {
+		return commandError([]string{"pull"}, string(out), err)
+	}
+	return nil
+}
+
+// sameRegistry returns true if both image references are hosted by the same
+// registry.
+func sameRegistry(ref, other string) bool {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return false
+	}
+	otherNamed, err := reference.ParseNormalizedNamed(other)
+	if err != nil {
+		return false
+	}
+	return reference.Domain(named) == reference.Domain(otherNamed)
+}
+
+// writeAuthConfig writes the base64 encoded credentials passed along a pull
+// request to a temporary Docker configuration directory, which nerdctl picks
+// up through the DOCKER_CONFIG environment variable. An empty directory name
+// is returned when no credentials are provided.
+func writeAuthConfig(ref, encodedAuth string) (string, error) {
+	config, err := authConfig(ref, encodedAuth)
+	if err != nil || config == nil {
+		return "", err
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	dir, err := fs.MkdirTemp("containerd-auth")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), data, 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// dockerConfigFile is the subset of the Docker configuration file holding
+// registry credentials.
+type dockerConfigFile struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// authConfig returns the Docker configuration file providing the given base64
+// encoded credentials for the registry hosting ref, or nil when the credentials
+// are empty.
+func authConfig(ref, encodedAuth string) (*dockerConfigFile, error) {
+	data, err := base64.URLEncoding.DecodeString(encodedAuth)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the registry credentials: %v", err)
+	}
+	auth := registry.AuthConfig{}
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return nil, fmt.Errorf("unable to decode the registry credentials: %v", err)
+	}
+	if len(auth.Username) == 0 && len(auth.Password) == 0 && len(auth.IdentityToken) == 0 {
+		return nil, nil
+	}
+	server := auth.ServerAddress
+	if len(server) == 0 {
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err != nil {
+			return nil, err
+		}
+		server = reference.Domain(named)
+	}
+	if server == "docker.io" || server == "index.docker.io" {
+		server = dockerHubConfigKey
+	}
+	return &dockerConfigFile{
+		Auths: map[string]dockerConfigAuth{
+			server: {
+				Auth:          base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password)),
+				IdentityToken: auth.IdentityToken,
+			},
+		},
+	}, nil
+}
+
+// DistributionInspect is not supported, nerdctl does not resolve the digests of
+// the images in their registries.
+func (c *Client) DistributionInspect(ctx context.Context, name, encodedRegistryAuth string) (registry.DistributionInspect, error) {
+	return registry.DistributionInspect{}, errdefs.NotImplemented(fmt.Errorf("the containerd engine does not resolve the digest of image %q", name))
+}
+
+// ImageHistory is not supported, nerdctl only reports the sizes of the layers
+// of an image in a human readable form.
+func (c *Client) ImageHistory(ctx context.Context, name string) ([]image.HistoryResponseItem, error) {
+	return nil, errdefs.NotImplemented(fmt.Errorf("the containerd engine does not report the layers of image %q", name))
+}
+
+// ImageRemove removes the given image.
+func (c *Client) ImageRemove(ctx context.Context, name string, options image.RemoveOptions) ([]image.DeleteResponse, error) {
+	args := []string{"rmi"}
+	if options.Force {
+		args = append(args, "--force")
+	}
+	if _, err := c.run(ctx, append(args, name)...); err != nil {
+		return nil, err
+	}
+	return []image.DeleteResponse{{Untagged: name}}, nil
+}
+
+// ImageLoad loads the images of the given archive, in the format of docker
+// save or of an OCI image layout.
+func (c *Client) ImageLoad(ctx context.Context, input io.Reader, quiet bool) (image.LoadResponse, error) {
+	args := []string{"load"}
+	cmd := c.command(ctx, args...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdin = input
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return image.LoadResponse{}, commandError(args, stderr.String(), err)
+	}
+	return image.LoadResponse{Body: ioutil.NopCloser(stdout)}, nil
+}
+
+// ImageSave returns the given images as a tar archive in the format of docker
+// save, written to a temporary file removed once the archive is closed.
+func (c *Client) ImageSave(ctx context.Context, images []string) (io.ReadCloser, error) {
+	dir, err := fs.MkdirTemp("containerd-save")
+	if err != nil {
+		return nil, err
+	}
+	archive := filepath.Join(dir, "images.tar")
+	if _, err := c.run(ctx, append([]string{"save", "--output", archive}, images...)...); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &removeOnClose{ReadCloser: f, dir: dir}, nil
+}
+
+// ImageTag creates the target tag referring to the source image.
+func (c *Client) ImageTag(ctx context.Context, source, target string) error {
+	_, err := c.run(ctx, "tag", source, target)
+	return err
+}
+
+// Info returns information about containerd. The directory containerd stores
+// its images in is not reported, as nerdctl does not tell it.
+func (c *Client) Info(ctx context.Context) (system.Info, error) {
+	return system.Info{Name: "containerd"}, nil
+}
+
+// nerdctlVersion is the output of "nerdctl version".
+type nerdctlVersion struct {
+	Client struct {
+		Version    string
+		GoVersion  string
+		Os         string
+		Arch       string
+		Components []struct {
+			Name    string
+			Version string
+		}
+	}
+	Server *struct {
+		Components []struct {
+			Name    string
+			Version string
+			Details map[string]string
+		}
+	}
+}
+
+// BuildKitComponent is the name of the component of the version of containerd
+// reporting the BuildKit client the builds are performed with.
+const BuildKitComponent = "buildctl"
+
+// ServerVersion returns the version of containerd. containerd does not serve
+// the Docker engine API, so the returned API version is empty.
+func (c *Client) ServerVersion(ctx context.Context) (dockertypes.Version, error) {
+	out, err := c.run(ctx, "version", "--format", "{{json .}}")
+	if err != nil {
+		return dockertypes.Version{}, err
+	}
+	v := nerdctlVersion{}
+	if err := json.Unmarshal(out, &v); err != nil {
+		return dockertypes.Version{}, fmt.Errorf("unable to parse the version of containerd: %v", err)
+	}
+	if v.Server == nil {
+		return dockertypes.Version{}, fmt.Errorf("containerd is not reachable at %s", c.address)
+	}
+	version := dockertypes.Version{
+		GoVersion: v.Client.GoVersion,
+		Os:        v.Client.Os,
+		Arch:      v.Client.Arch,
+	}
+	version.Platform.Name = "containerd"
+	for _, component := range v.Server.Components {
+		version.Components = append(version.Components, dockertypes.ComponentVersion{
+			Name:    component.Name,
+			Version: component.Version,
+			Details: component.Details,
+		})
+		if component.Name == "containerd" {
+			version.Version = component.Version
+		}
+	}
+	// the builds require buildctl, which nerdctl reports as a component of
+	// the client when it is installed
+	for _, component := range v.Client.Components {
+		if component.Name == BuildKitComponent {
+			version.Components = append(version.Components, dockertypes.ComponentVersion{Name: component.Name, Version: component.Version})
+		}
+	}
+	return version, nil
+}
@@ -0,0 +1,186 @@
+package containerd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestRunArgs(t *testing.T) {
+	ctr := &container{
+		name: "s2i_test",
+		config: &dockercontainer.Config{
+			Image:      "builder:latest",
+			User:       "1001",
+			Env:        []string{"FOO=bar"},
+			Labels:     map[string]string{"b": "2", "a": "1"},
+			Entrypoint: []string{"/usr/bin/env", "-i"},
+			Cmd:        []string{"/bin/sh", "-c", "assemble"},
+		},
+		hostConfig: &dockercontainer.HostConfig{
+			NetworkMode: "host",
+			CapDrop:     []string{"KILL"},
+			ShmSize:     65536,
+			Resources:   dockercontainer.Resources{Memory: 1024},
+			PortBindings: nat.PortMap{
+				"8080/tcp": {{HostPort: "8080"}, {HostIP: "127.0.0.1", HostPort: "9090"}},
+				"53/udp":   {{}},
+			},
+		},
+	}
+	expected := []string{
+		"--name", "s2i_test", "--interactive", "--user", "1001", "--env", "FOO=bar",
+		"--label", "a=1", "--label", "b=2", "--network", "host", "--cap-drop", "KILL",
+		"--publish", "53/udp", "--publish", "8080:8080/tcp", "--publish", "127.0.0.1:9090:8080/tcp",
+		"--memory", "1024", "--shm-size", "65536", "--entrypoint", "/usr/bin/env",
+		"builder:latest", "-i", "/bin/sh", "-c", "assemble",
+	}
+	if args := runArgs(ctr, true); !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected arguments\n%q\ngot\n%q", expected, args)
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	value := "http://proxy:3128"
+	options := dockertypes.ImageBuildOptions{
+		Tags:      []string{"app:latest"},
+		NoCache:   true,
+		BuildArgs: map[string]*string{"HTTP_PROXY": &value, "EMPTY": nil},
+		Labels:    map[string]string{"io.openshift.s2i.layered.tag": "app"},
+	}
+	expected := []string{
+		"--tag", "app:latest", "--no-cache", "--build-arg", "EMPTY",
+		"--build-arg", "HTTP_PROXY=http://proxy:3128", "--label", "io.openshift.s2i.layered.tag=app", "/context",
+	}
+	if args := buildArgs(options, "/context"); !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected arguments\n%q\ngot\n%q", expected, args)
+	}
+}
+
+func TestCommitDockerfile(t *testing.T) {
+	if dockerfile := commitDockerfile("app", &dockercontainer.Config{Cmd: []string{"run"}}); len(dockerfile) != 0 {
+		t.Errorf("Expected no Dockerfile, got %q", dockerfile)
+	}
+	config := &dockercontainer.Config{
+		User:   "1001",
+		Env:    []string{"PATH=/opt/$app/bin"},
+		Labels: map[string]string{"io.k8s.display-name": `my "app"`},
+	}
+	expected := `FROM app
+USER 1001
+ENV PATH="/opt/\$app/bin"
+LABEL "io.k8s.display-name"="my \"app\""
+`
+	if dockerfile := commitDockerfile("app", config); dockerfile != expected {
+		t.Errorf("Expected Dockerfile\n%s\ngot\n%s", expected, dockerfile)
+	}
+}
+
+func TestAuthConfig(t *testing.T) {
+	encode := func(s string) string {
+		return base64.URLEncoding.EncodeToString([]byte(s))
+	}
+	tests := []struct {
+		ref      string
+		auth     string
+		expected *dockerConfigFile
+	}{
+		{
+			ref:  "centos/ruby-25-centos7",
+			auth: encode(`{}`),
+		},
+		{
+			ref:  "centos/ruby-25-centos7",
+			auth: encode(`{"username":"user","password":"pass"}`),
+			expected: &dockerConfigFile{Auths: map[string]dockerConfigAuth{
+				dockerHubConfigKey: {Auth: "dXNlcjpwYXNz"},
+			}},
+		},
+		{
+			ref:  "quay.io/user/image:tag",
+			auth: encode(`{"username":"user","password":"pass"}`),
+			expected: &dockerConfigFile{Auths: map[string]dockerConfigAuth{
+				"quay.io": {Auth: "dXNlcjpwYXNz"},
+			}},
+		},
+		{
+			ref:  "quay.io/user/image:tag",
+			auth: encode(`{"username":"user","password":"pass","serveraddress":"registry.example.com"}`),
+			expected: &dockerConfigFile{Auths: map[string]dockerConfigAuth{
+				"registry.example.com": {Auth: "dXNlcjpwYXNz"},
+			}},
+		},
+		{
+			ref:  "myregistry.azurecr.io/app",
+			auth: encode(`{"username":"<token>","identitytoken":"refresh-token"}`),
+			expected: &dockerConfigFile{Auths: map[string]dockerConfigAuth{
+				"myregistry.azurecr.io": {Auth: "PHRva2VuPjo=", IdentityToken: "refresh-token"},
+			}},
+		},
+	}
+	for _, tc := range tests {
+		config, err := authConfig(tc.ref, tc.auth)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.ref, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, tc.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tc.ref, tc.expected, config)
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	content := []byte("uncompressed content")
+	compressed := &bytes.Buffer{}
+	w := gzip.NewWriter(compressed)
+	w.Write(content)
+	w.Close()
+
+	for name, input := range map[string][]byte{"plain": content, "gzip": compressed.Bytes()} {
+		r, err := decompress(bytes.NewReader(input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		output, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(output, content) {
+			t.Errorf("%s: expected %q, got %q", name, content, output)
+		}
+	}
+}
+
+func TestSafeForLoggingArgs(t *testing.T) {
+	args := []string{"run", "--env", "DEBUG=true", "--env", "PORT", "--build-arg", "NPM_TOKEN=s3cr3t", "--label", "a=b", "app"}
+	expected := []string{"run", "--env", "DEBUG=REDACTED", "--env", "PORT", "--build-arg", "NPM_TOKEN=REDACTED", "--label", "a=b", "app"}
+	if result := safeForLoggingArgs(args); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+	if args[2] != "DEBUG=true" {
+		t.Errorf("Expected the arguments to be left unchanged, got %v", args)
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	err := commandError([]string{"image"}, "FATA[0000] no such image: app\n", errors.New("exit status 1"))
+	if !errdefs.IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	err = commandError([]string{"pull"}, "", errors.New("exit status 1"))
+	if errdefs.IsNotFound(err) {
+		t.Errorf("Unexpected not found error %v", err)
+	}
+}
@@ -0,0 +1,459 @@
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	dockernetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// container is a container created through the client. nerdctl cannot attach
+// to the standard input of a container created beforehand, so the container
+// is only created by containerd when it is started, either attached or in the
+// background, or when files are copied to or from it before.
+type container struct {
+	name       string
+	config     *dockercontainer.Config
+	hostConfig *dockercontainer.HostConfig
+	// created is true once the container is created by containerd
+	created bool
+	started bool
+}
+
+// execution is a command run in a container through the client.
+type execution struct {
+	container string
+	options   dockercontainer.ExecOptions
+	done      chan struct{}
+	exitCode  int
+}
+
+// ContainerCreate registers a new container. The container is created and
+// started by containerd when ContainerAttach or ContainerStart is called.
+func (c *Client) ContainerCreate(ctx context.Context, config *dockercontainer.Config, hostConfig *dockercontainer.HostConfig, networkingConfig *dockernetwork.NetworkingConfig, platform *v1.Platform, containerName string) (dockercontainer.CreateResponse, error) {
+	if config == nil || len(config.Image) == 0 {
+		return dockercontainer.CreateResponse{}, errors.New("the image of the container must be specified")
+	}
+	if len(containerName) == 0 {
+		containerName = fmt.Sprintf("s2i_%d", time.Now().UnixNano())
+	}
+	if hostConfig == nil {
+		hostConfig = &dockercontainer.HostConfig{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.containers[containerName]; exists {
+		return dockercontainer.CreateResponse{}, fmt.Errorf("container %q already exists", containerName)
+	}
+	c.containers[containerName] = &container{
+		name:       containerName,
+		config:     config,
+		hostConfig: hostConfig,
+	}
+	return dockercontainer.CreateResponse{ID: containerName}, nil
+}
+
+// startContainer marks the given container as started, and returns it along
+// with whether it was already created by containerd.
+func (c *Client) startContainer(id string) (*container, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.containers[id]
+	if !ok {
+		return nil, false, errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+	}
+	if ctr.started {
+		return nil, false, nil
+	}
+	created := ctr.created
+	ctr.created, ctr.started = true, true
+	return ctr, created, nil
+}
+
+// ensureCreated creates the given container with containerd, unless it is
+// already created, so that files can be copied to or from it before it is
+// started. The containers which were not created through the client are left
+// as they are.
+func (c *Client) ensureCreated(ctx context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.containers[id]
+	if !ok || ctr.created {
+		return nil
+	}
+	args := append([]string{"create"}, runArgs(ctr, false)...)
+	if _, err := c.run(ctx, args...); err != nil {
+		return err
+	}
+	ctr.created = true
+	return nil
+}
+
+// ContainerAttach starts the given container with its standard streams
+// attached. The returned connection carries the multiplexed standard output
+// and error of the container, as a Docker daemon would return them.
+func (c *Client) ContainerAttach(ctx context.Context, id string, options dockercontainer.AttachOptions) (dockertypes.HijackedResponse, error) {
+	ctr, created, err := c.startContainer(id)
+	if err != nil {
+		return dockertypes.HijackedResponse{}, err
+	}
+	if ctr == nil {
+		return dockertypes.HijackedResponse{}, fmt.Errorf("container %q is already running", id)
+	}
+
+	args := append([]string{"run"}, runArgs(ctr, options.Stdin)...)
+	if created {
+		if options.Stdin {
+			return dockertypes.HijackedResponse{}, errdefs.NotImplemented(fmt.Errorf("the containerd engine cannot attach to the standard input of container %q, which was created to copy files into it", id))
+		}
+		args = []string{"start", "--attach", id}
+	}
+	// the exit code of the container is reported by ContainerWait
+	return c.attach(args, options.Stdin, nil)
+}
+
+// attach runs nerdctl with the given arguments, and returns the connection to
+// its standard streams. The given function, if any, is called with the exit
+// code of nerdctl once it exits.
+func (c *Client) attach(args []string, interactive bool, exited func(code int)) (dockertypes.HijackedResponse, error) {
+	// the command must outlive the context of the attach request
+	cmd := c.command(context.Background(), args...)
+	outReader, outWriter := io.Pipe()
+	cmd.Stdout = stdcopy.NewStdWriter(outWriter, stdcopy.Stdout)
+	cmd.Stderr = stdcopy.NewStdWriter(outWriter, stdcopy.Stderr)
+	var stdin io.WriteCloser = nopWriteCloser{io.Discard}
+	if interactive {
+		var err error
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return dockertypes.HijackedResponse{}, err
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return dockertypes.HijackedResponse{}, err
+	}
+	go func() {
+		err := cmd.Wait()
+		code := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code, err = exitErr.ExitCode(), nil
+		}
+		if exited != nil {
+			exited(code)
+		}
+		outWriter.CloseWithError(err)
+	}()
+	return dockertypes.NewHijackedResponse(&attachConn{stdin: stdin, stdout: outReader}, "application/vnd.docker.multiplexed-stream"), nil
+}
+
+// ContainerStart starts the given container in the background, unless it was
+// already started by ContainerAttach.
+func (c *Client) ContainerStart(ctx context.Context, id string, options dockercontainer.StartOptions) error {
+	ctr, created, err := c.startContainer(id)
+	if err != nil || ctr == nil {
+		return err
+	}
+	args := append([]string{"run", "--detach"}, runArgs(ctr, false)...)
+	if created {
+		args = []string{"start", id}
+	}
+	_, err = c.run(ctx, args...)
+	return err
+}
+
+// runArgs returns the arguments of "nerdctl run" creating the given container.
+func runArgs(ctr *container, interactive bool) []string {
+	config, hostConfig := ctr.config, ctr.hostConfig
+	args := []string{"--name", ctr.name}
+	if interactive {
+		args = append(args, "--interactive")
+	}
+	if len(config.User) > 0 {
+		args = append(args, "--user", config.User)
+	}
+	for _, env := range config.Env {
+		args = append(args, "--env", env)
+	}
+	if len(config.WorkingDir) > 0 {
+		args = append(args, "--workdir", config.WorkingDir)
+	}
+	labels := make([]string, 0, len(config.Labels))
+	for k, v := range config.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		args = append(args, "--label", label)
+	}
+	if mode := string(hostConfig.NetworkMode); len(mode) > 0 && mode != "default" {
+		args = append(args, "--network", mode)
+	}
+	for _, bind := range hostConfig.Binds {
+		args = append(args, "--volume", bind)
+	}
+	for _, host := range hostConfig.ExtraHosts {
+		args = append(args, "--add-host", host)
+	}
+	for _, opt := range hostConfig.SecurityOpt {
+		args = append(args, "--security-opt", opt)
+	}
+	for _, capability := range hostConfig.CapDrop {
+		args = append(args, "--cap-drop", capability)
+	}
+	if hostConfig.PublishAllPorts {
+		args = append(args, "--publish-all")
+	}
+	ports := make([]string, 0, len(hostConfig.PortBindings))
+	for port := range hostConfig.PortBindings {
+		ports = append(ports, string(port))
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		for _, binding := range hostConfig.PortBindings[nat.Port(port)] {
+			publish := port
+			if len(binding.HostIP) > 0 || len(binding.HostPort) > 0 {
+				publish = binding.HostPort + ":" + port
+			}
+			if len(binding.HostIP) > 0 {
+				publish = binding.HostIP + ":" + publish
+			}
+			args = append(args, "--publish", publish)
+		}
+	}
+	if hostConfig.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(hostConfig.Memory, 10))
+	}
+	if hostConfig.MemorySwap != 0 {
+		args = append(args, "--memory-swap", strconv.FormatInt(hostConfig.MemorySwap, 10))
+	}
+	if len(hostConfig.CgroupParent) > 0 {
+		args = append(args, "--cgroup-parent", hostConfig.CgroupParent)
+	}
+	if hostConfig.ShmSize > 0 {
+		args = append(args, "--shm-size", strconv.FormatInt(hostConfig.ShmSize, 10))
+	}
+	cmd := []string(config.Cmd)
+	if len(config.Entrypoint) > 0 {
+		// nerdctl only accepts the executable as entrypoint, its arguments
+		// are passed in front of the command
+		args = append(args, "--entrypoint", config.Entrypoint[0])
+		cmd = append(append([]string{}, config.Entrypoint[1:]...), cmd...)
+	}
+	args = append(args, config.Image)
+	return append(args, cmd...)
+}
+
+// ContainerWait waits until the given container stops, and reports its exit
+// code. Only the WaitConditionNotRunning condition is supported.
+func (c *Client) ContainerWait(ctx context.Context, id string, condition dockercontainer.WaitCondition) (<-chan dockercontainer.WaitResponse, <-chan error) {
+	resultC := make(chan dockercontainer.WaitResponse, 1)
+	errC := make(chan error, 1)
+	go func() {
+		out, err := c.run(ctx, "wait", id)
+		if err != nil {
+			errC <- err
+			return
+		}
+		code, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			errC <- fmt.Errorf("unable to parse the exit code of container %q: %v", id, err)
+			return
+		}
+		resultC <- dockercontainer.WaitResponse{StatusCode: code}
+	}()
+	return resultC, errC
+}
+
+// ContainerInspect returns the container information.
+func (c *Client) ContainerInspect(ctx context.Context, id string) (dockertypes.ContainerJSON, error) {
+	out, err := c.run(ctx, "container", "inspect", "--mode=dockercompat", id)
+	if err != nil {
+		return dockertypes.ContainerJSON{}, err
+	}
+	inspects := []dockertypes.ContainerJSON{}
+	if err := json.Unmarshal(out, &inspects); err != nil {
+		return dockertypes.ContainerJSON{}, fmt.Errorf("unable to parse the inspection of container %q: %v", id, err)
+	}
+	if len(inspects) == 0 {
+		return dockertypes.ContainerJSON{}, errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+	}
+	return inspects[0], nil
+}
+
+// ContainerStats is not supported, nerdctl only reports the statistics of a
+// container in a human readable form.
+func (c *Client) ContainerStats(ctx context.Context, id string, stream bool) (dockercontainer.StatsResponseReader, error) {
+	return dockercontainer.StatsResponseReader{}, errdefs.NotImplemented(fmt.Errorf("the containerd engine does not report the statistics of container %q", id))
+}
+
+// ContainerExecCreate registers a command to run in the given container. The
+// command is run by "nerdctl exec" when ContainerExecAttach is called.
+func (c *Client) ContainerExecCreate(ctx context.Context, id string, options dockercontainer.ExecOptions) (dockertypes.IDResponse, error) {
+	if len(options.Cmd) == 0 {
+		return dockertypes.IDResponse{}, errors.New("the command to run must be specified")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	execID := fmt.Sprintf("s2i_exec_%d", time.Now().UnixNano())
+	c.execs[execID] = &execution{
+		container: id,
+		options:   options,
+		done:      make(chan struct{}),
+	}
+	return dockertypes.IDResponse{ID: execID}, nil
+}
+
+// ContainerExecAttach runs the given command with its standard streams
+// attached, as ContainerAttach does.
+func (c *Client) ContainerExecAttach(ctx context.Context, execID string, options dockercontainer.ExecAttachOptions) (dockertypes.HijackedResponse, error) {
+	c.mu.Lock()
+	e, ok := c.execs[execID]
+	c.mu.Unlock()
+	if !ok {
+		return dockertypes.HijackedResponse{}, errdefs.NotFound(fmt.Errorf("no such exec: %s", execID))
+	}
+	args := append([]string{"exec"}, execArgs(e)...)
+	return c.attach(args, e.options.AttachStdin, func(code int) {
+		e.exitCode = code
+		close(e.done)
+	})
+}
+
+// execArgs returns the arguments of "nerdctl exec" running the given command.
+func execArgs(e *execution) []string {
+	options := e.options
+	args := []string{}
+	if options.AttachStdin {
+		args = append(args, "--interactive")
+	}
+	if options.Tty {
+		args = append(args, "--tty")
+	}
+	if options.Privileged {
+		args = append(args, "--privileged")
+	}
+	if len(options.User) > 0 {
+		args = append(args, "--user", options.User)
+	}
+	for _, env := range options.Env {
+		args = append(args, "--env", env)
+	}
+	if len(options.WorkingDir) > 0 {
+		args = append(args, "--workdir", options.WorkingDir)
+	}
+	args = append(args, e.container)
+	return append(args, options.Cmd...)
+}
+
+// ContainerExecInspect reports whether the given command is running, and its
+// exit code once it exited.
+func (c *Client) ContainerExecInspect(ctx context.Context, execID string) (dockercontainer.ExecInspect, error) {
+	c.mu.Lock()
+	e, ok := c.execs[execID]
+	c.mu.Unlock()
+	if !ok {
+		return dockercontainer.ExecInspect{}, errdefs.NotFound(fmt.Errorf("no such exec: %s", execID))
+	}
+	inspect := dockercontainer.ExecInspect{ExecID: execID, ContainerID: e.container}
+	select {
+	case <-e.done:
+		inspect.ExitCode = e.exitCode
+		c.mu.Lock()
+		delete(c.execs, execID)
+		c.mu.Unlock()
+	default:
+		inspect.Running = true
+	}
+	return inspect, nil
+}
+
+// ContainerKill sends the given signal to the container.
+func (c *Client) ContainerKill(ctx context.Context, id, signal string) error {
+	_, err := c.run(ctx, "kill", "--signal", signal, id)
+	return err
+}
+
+// ContainerRemove removes the given container.
+func (c *Client) ContainerRemove(ctx context.Context, id string, options dockercontainer.RemoveOptions) error {
+	c.mu.Lock()
+	ctr, ok := c.containers[id]
+	delete(c.containers, id)
+	c.mu.Unlock()
+	if ok && !ctr.created {
+		// the container was never created by containerd
+		return nil
+	}
+	args := []string{"rm"}
+	if options.Force {
+		args = append(args, "--force")
+	}
+	if options.RemoveVolumes {
+		args = append(args, "--volumes")
+	}
+	_, err := c.run(ctx, append(args, id)...)
+	return err
+}
+
+// attachConn is the connection to the standard streams of an attached
+// container.
+type attachConn struct {
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+	closeOnce sync.Once
+}
+
+func (a *attachConn) Read(b []byte) (int, error) {
+	return a.stdout.Read(b)
+}
+
+func (a *attachConn) Write(b []byte) (int, error) {
+	return a.stdin.Write(b)
+}
+
+// CloseWrite closes the standard input of the container.
+func (a *attachConn) CloseWrite() error {
+	var err error
+	a.closeOnce.Do(func() {
+		err = a.stdin.Close()
+	})
+	return err
+}
+
+func (a *attachConn) Close() error {
+	a.CloseWrite()
+	return a.stdout.Close()
+}
+
+func (a *attachConn) LocalAddr() net.Addr                { return attachAddr{} }
+func (a *attachConn) RemoteAddr() net.Addr               { return attachAddr{} }
+func (a *attachConn) SetDeadline(t time.Time) error      { return nil }
+func (a *attachConn) SetReadDeadline(t time.Time) error  { return nil }
+func (a *attachConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type attachAddr struct{}
+
+func (attachAddr) Network() string { return "nerdctl" }
+func (attachAddr) String() string  { return "nerdctl" }
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
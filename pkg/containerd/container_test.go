@@ -0,0 +1,129 @@
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// newFakeNerdctlClient returns a client running a fake nerdctl, which records
+// its arguments to the returned file, prints "output" and exits with code 3
+// when running a command in a container.
+func newFakeNerdctlClient(t *testing.T) (*Client, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake nerdctl is a shell script")
+	}
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := `#!/bin/sh
+shift 4
+echo "$@" >> ` + calls + `
+if [ "$1" = "exec" ]; then
+  echo output
+  exit 3
+fi
+`
+	binary := filepath.Join(dir, "nerdctl")
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Client{
+		binary:     binary,
+		address:    DefaultAddress,
+		namespace:  DefaultNamespace,
+		containers: map[string]*container{},
+		execs:      map[string]*execution{},
+	}, calls
+}
+
+func readCalls(t *testing.T, calls string) []string {
+	data, err := ioutil.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestEnsureCreated(t *testing.T) {
+	c, calls := newFakeNerdctlClient(t)
+	ctx := context.Background()
+	config := &dockercontainer.Config{Image: "builder:latest", Cmd: []string{"/bin/true"}}
+	if _, err := c.ContainerCreate(ctx, config, nil, nil, nil, "s2i_test"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.ensureCreated(ctx, "s2i_test"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := c.ContainerStart(ctx, "s2i_test", dockercontainer.StartOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.ContainerRemove(ctx, "s2i_test", dockercontainer.RemoveOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"create --name s2i_test builder:latest /bin/true",
+		"start s2i_test",
+		"rm s2i_test",
+	}
+	if got := readCalls(t, calls); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected calls %q, got %q", expected, got)
+	}
+
+	if _, err := c.ContainerCreate(ctx, config, nil, nil, nil, "s2i_attached"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ensureCreated(ctx, "s2i_attached"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ContainerAttach(ctx, "s2i_attached", dockercontainer.AttachOptions{Stdin: true}); err == nil {
+		t.Errorf("expected an error attaching to the standard input of a created container")
+	}
+}
+
+func TestContainerExec(t *testing.T) {
+	c, calls := newFakeNerdctlClient(t)
+	ctx := context.Background()
+	exec, err := c.ContainerExecCreate(ctx, "s2i_test", dockercontainer.ExecOptions{
+		User:         "1001",
+		Env:          []string{"FOO=bar"},
+		Cmd:          []string{"/bin/sh", "-c", "ls"},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := c.ContainerExecAttach(ctx, exec.ID, dockercontainer.ExecAttachOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	if _, err := stdcopy.StdCopy(stdout, ioutil.Discard, resp.Reader); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Close()
+	if stdout.String() != "output\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+	inspect, err := c.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inspect.Running || inspect.ExitCode != 3 {
+		t.Errorf("expected the command to exit with code 3, got %#v", inspect)
+	}
+	expected := []string{"exec --user 1001 --env FOO=bar s2i_test /bin/sh -c ls"}
+	if got := readCalls(t, calls); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected calls %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,5 @@
+// Package containerd implements a container engine client on top of
+// containerd, for hosts that run containerd without a Docker daemon. The
+// client drives containerd through the nerdctl command line tool and exposes
+// the subset of the Docker engine API used by the S2I builder.
+package containerd
@@ -0,0 +1,92 @@
+package containerd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/types"
+)
+
+// pullSource is a location an image is pulled from: a mirror or the registry
+// hosting it.
+type pullSource struct {
+	reference string
+	insecure  bool
+}
+
+// pullSources resolves the given image reference with the registries
+// configuration of containers/image (registries.conf), as Podman and Buildah
+// do, since nerdctl ignores it. Short names are resolved with the short-name
+// aliases, or else on Docker Hub like the Docker engine does, and the images
+// of blocked registries are refused. The mirrors of the registry are listed
+// first, in their configured order, and the registry itself last.
+func pullSources(sys *types.SystemContext, ref string) ([]pullSource, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, err
+	}
+	if isShortName(ref) {
+		alias, origin, err := sysregistriesv2.ResolveShortNameAlias(sys, reference.FamiliarName(named))
+		if err != nil {
+			return nil, err
+		}
+		if alias != nil {
+			resolved, err := withTagOrDigest(alias, named)
+			if err != nil {
+				return nil, err
+			}
+			log.V(2).Infof("Resolved short name %q to %q with the alias of %s", ref, resolved.String(), origin)
+			named = resolved
+		}
+	}
+	registry, err := sysregistriesv2.FindRegistry(sys, named.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the registries configuration: %v", err)
+	}
+	if registry == nil {
+		return []pullSource{{reference: named.String()}}, nil
+	}
+	if registry.Blocked {
+		return nil, fmt.Errorf("pulling image %q is blocked by the registries configuration in %s", named.String(), sysregistriesv2.ConfigurationSourceDescription(sys))
+	}
+	sources, err := registry.PullSourcesFromReference(named)
+	if err != nil {
+		return nil, err
+	}
+	result := []pullSource{}
+	for _, source := range sources {
+		result = append(result, pullSource{reference: source.Reference.String(), insecure: source.Endpoint.Insecure})
+	}
+	return result, nil
+}
+
+// isShortName returns true if the image reference does not name its registry.
+func isShortName(ref string) bool {
+	i := strings.Index(ref, "/")
+	if i == -1 {
+		return true
+	}
+	domain := ref[:i]
+	return !strings.ContainsAny(domain, ".:") && domain != "localhost"
+}
+
+// withTagOrDigest returns the alias with the tag or the digest of the named
+// reference.
+func withTagOrDigest(alias, named reference.Named) (reference.Named, error) {
+	if digested, ok := named.(reference.Digested); ok {
+		return reference.WithDigest(reference.TrimNamed(alias), digested.Digest())
+	}
+	if tagged, ok := named.(reference.NamedTagged); ok {
+		return reference.WithTag(alias, tagged.Tag())
+	}
+	return reference.TagNameOnly(alias), nil
+}
+
+// registriesSystemContext returns the context used to read the registries
+// configuration, from the given registries.conf file or else from the default
+// locations.
+func registriesSystemContext(registriesConf string) *types.SystemContext {
+	return &types.SystemContext{SystemRegistriesConfPath: registriesConf}
+}
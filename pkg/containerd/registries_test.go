@@ -0,0 +1,96 @@
+package containerd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+)
+
+func TestPullSources(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "registries.conf")
+	config := `
+[aliases]
+"ubi8" = "registry.access.redhat.com/ubi8"
+
+[[registry]]
+location = "docker.io"
+[[registry.mirror]]
+location = "mirror.example.com/hub"
+[[registry.mirror]]
+location = "localhost:5000/hub"
+insecure = true
+
+[[registry]]
+location = "quay.io/blocked"
+blocked = true
+`
+	if err := ioutil.WriteFile(conf, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    conf,
+		SystemRegistriesConfDirPath: filepath.Join(dir, "registries.conf.d"),
+		UserShortNameAliasConfPath:  filepath.Join(dir, "short-name-aliases.conf"),
+	}
+	tests := []struct {
+		ref      string
+		expected []pullSource
+	}{
+		{
+			ref: "centos/ruby-25-centos7:latest",
+			expected: []pullSource{
+				{reference: "mirror.example.com/hub/centos/ruby-25-centos7:latest"},
+				{reference: "localhost:5000/hub/centos/ruby-25-centos7:latest", insecure: true},
+				{reference: "docker.io/centos/ruby-25-centos7:latest"},
+			},
+		},
+		{
+			ref:      "ubi8:8.9",
+			expected: []pullSource{{reference: "registry.access.redhat.com/ubi8:8.9"}},
+		},
+		{
+			ref:      "ubi8",
+			expected: []pullSource{{reference: "registry.access.redhat.com/ubi8:latest"}},
+		},
+		{
+			ref:      "quay.io/team/builder:latest",
+			expected: []pullSource{{reference: "quay.io/team/builder:latest"}},
+		},
+	}
+	for _, tc := range tests {
+		sources, err := pullSources(sys, tc.ref)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.ref, err)
+			continue
+		}
+		if !reflect.DeepEqual(sources, tc.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.ref, tc.expected, sources)
+		}
+	}
+
+	if _, err := pullSources(sys, "quay.io/blocked/builder"); err == nil || !strings.Contains(err.Error(), "blocked") {
+		t.Errorf("expected the image of a blocked registry to be refused, got %v", err)
+	}
+}
+
+func TestIsShortName(t *testing.T) {
+	tests := map[string]bool{
+		"ruby":                        true,
+		"centos/ruby-25-centos7":      true,
+		"docker.io/library/ruby":      false,
+		"localhost/builder":           false,
+		"localhost:5000/builder":      false,
+		"registry.redhat.io/ubi8/ubi": false,
+		"ruby:2.7":                    true,
+	}
+	for ref, expected := range tests {
+		if actual := isShortName(ref); actual != expected {
+			t.Errorf("%s: expected %t, got %t", ref, expected, actual)
+		}
+	}
+}
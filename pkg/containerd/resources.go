@@ -0,0 +1,159 @@
+package containerd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// labelFilters converts the label filters into nerdctl arguments. Only the
+// label filters are supported.
+func labelFilters(f filters.Args) []string {
+	args := []string{}
+	for _, label := range f.Get("label") {
+		args = append(args, "--filter", "label="+label)
+	}
+	return args
+}
+
+// decodeLines decodes the JSON objects printed one per line by nerdctl.
+func decodeLines(out []byte, decode func(line []byte) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := decode(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ContainerList lists the containers matching the label filters.
+func (c *Client) ContainerList(ctx context.Context, options dockercontainer.ListOptions) ([]dockertypes.Container, error) {
+	args := append([]string{"ps", "--format", "{{json .}}"}, labelFilters(options.Filters)...)
+	if options.All {
+		args = append(args, "--all")
+	}
+	out, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	containers := []dockertypes.Container{}
+	err = decodeLines(out, func(line []byte) error {
+		ctr := struct {
+			ID    string
+			Names string
+			Image string
+		}{}
+		if err := json.Unmarshal(line, &ctr); err != nil {
+			return fmt.Errorf("unable to parse the list of containers: %v", err)
+		}
+		containers = append(containers, dockertypes.Container{ID: ctr.ID, Names: strings.Split(ctr.Names, ","), Image: ctr.Image})
+		return nil
+	})
+	return containers, err
+}
+
+// ImageList lists the images matching the label filters.
+func (c *Client) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	out, err := c.run(ctx, append([]string{"images", "--format", "{{json .}}"}, labelFilters(options.Filters)...)...)
+	if err != nil {
+		return nil, err
+	}
+	images := []image.Summary{}
+	seen := map[string]bool{}
+	err = decodeLines(out, func(line []byte) error {
+		img := struct {
+			ID         string
+			Repository string
+			Tag        string
+		}{}
+		if err := json.Unmarshal(line, &img); err != nil {
+			return fmt.Errorf("unable to parse the list of images: %v", err)
+		}
+		// nerdctl lists the images once per tag
+		if !seen[img.ID] {
+			seen[img.ID] = true
+			images = append(images, image.Summary{ID: img.ID})
+		}
+		if len(img.Repository) > 0 && img.Repository != "<none>" {
+			images[len(images)-1].RepoTags = append(images[len(images)-1].RepoTags, img.Repository+":"+img.Tag)
+		}
+		return nil
+	})
+	return images, err
+}
+
+// VolumeCreate creates a volume.
+func (c *Client) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	args := []string{"volume", "create"}
+	for k, v := range options.Labels {
+		args = append(args, "--label", k+"="+v)
+	}
+	if _, err := c.run(ctx, append(args, options.Name)...); err != nil {
+		return volume.Volume{}, err
+	}
+	return volume.Volume{Name: options.Name, Labels: options.Labels}, nil
+}
+
+// VolumeInspect returns the volume information.
+func (c *Client) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	out, err := c.run(ctx, "volume", "inspect", volumeID)
+	if err != nil {
+		return volume.Volume{}, err
+	}
+	volumes := []volume.Volume{}
+	if err := json.Unmarshal(out, &volumes); err != nil {
+		return volume.Volume{}, fmt.Errorf("unable to parse the inspection of volume %q: %v", volumeID, err)
+	}
+	if len(volumes) == 0 {
+		return volume.Volume{}, errdefs.NotFound(fmt.Errorf("no such volume: %s", volumeID))
+	}
+	return volumes[0], nil
+}
+
+// VolumeList lists the volumes matching the label filters.
+func (c *Client) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	out, err := c.run(ctx, append([]string{"volume", "ls", "--format", "{{json .}}"}, labelFilters(options.Filters)...)...)
+	if err != nil {
+		return volume.ListResponse{}, err
+	}
+	resp := volume.ListResponse{}
+	err = decodeLines(out, func(line []byte) error {
+		// the labels are listed as a string
+		v := struct {
+			Name       string
+			Driver     string
+			Mountpoint string
+		}{}
+		if err := json.Unmarshal(line, &v); err != nil {
+			return fmt.Errorf("unable to parse the list of volumes: %v", err)
+		}
+		resp.Volumes = append(resp.Volumes, &volume.Volume{Name: v.Name, Driver: v.Driver, Mountpoint: v.Mountpoint})
+		return nil
+	})
+	return resp, err
+}
+
+// VolumeRemove removes the given volume.
+func (c *Client) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	args := []string{"volume", "rm"}
+	if force {
+		args = append(args, "--force")
+	}
+	_, err := c.run(ctx, append(args, volumeID)...)
+	return err
+}
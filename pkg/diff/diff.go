@@ -0,0 +1,258 @@
+package diff
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// File is a file of an image.
+type File struct {
+	// Path is the path of the file in the image.
+	Path string
+	// Size is the size of the file.
+	Size int64
+	// Digest is the digest of the content of the file, or the target of a
+	// link.
+	Digest string
+}
+
+// Change is a file changed from the previous image to the new one.
+type Change struct {
+	// Path is the path of the file in the images.
+	Path string
+	// OldSize is the size of the file in the previous image.
+	OldSize int64
+	// NewSize is the size of the file in the new image.
+	NewSize int64
+}
+
+// Layer is the size of a layer in the previous image and the new one. The
+// layers are matched by their position from the base of the images.
+type Layer struct {
+	// CreatedBy is the instruction which created the layer of the new image,
+	// or of the previous image when the new image has fewer layers.
+	CreatedBy string
+	// OldSize is the size of the layer in the previous image, zero when the
+	// previous image has fewer layers.
+	OldSize int64
+	// NewSize is the size of the layer in the new image, zero when the new
+	// image has fewer layers.
+	NewSize int64
+}
+
+// Report is the difference between the previous image and the new one.
+type Report struct {
+	// OldImage is the previous image.
+	OldImage string
+	// NewImage is the new image.
+	NewImage string
+	// Paths are the paths of the images whose files are compared.
+	Paths []string
+	// Added are the files of the new image missing from the previous image.
+	Added []File
+	// Removed are the files of the previous image missing from the new image.
+	Removed []File
+	// Changed are the files whose content changed.
+	Changed []Change
+	// Layers are the sizes of the layers of the images, from their base.
+	Layers []Layer
+}
+
+// SizeDelta returns the difference of size between the new image and the
+// previous one.
+func (r *Report) SizeDelta() int64 {
+	var delta int64
+	for _, layer := range r.Layers {
+		delta += layer.NewSize - layer.OldSize
+	}
+	return delta
+}
+
+// String returns a summary of the report.
+func (r *Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Changes from image %s to image %s in %s: %d added, %d removed, %d changed files\n", r.OldImage, r.NewImage, strings.Join(r.Paths, ", "), len(r.Added), len(r.Removed), len(r.Changed))
+	for _, f := range r.Added {
+		fmt.Fprintf(b, "  + %s (%s)\n", f.Path, formatSize(f.Size, false))
+	}
+	for _, f := range r.Removed {
+		fmt.Fprintf(b, "  - %s (%s)\n", f.Path, formatSize(f.Size, false))
+	}
+	for _, c := range r.Changed {
+		fmt.Fprintf(b, "  ~ %s (%s)\n", c.Path, formatSize(c.NewSize-c.OldSize, true))
+	}
+	fmt.Fprintf(b, "Size of the layers, from the base of the images (%s):\n", formatSize(r.SizeDelta(), true))
+	for _, layer := range r.Layers {
+		if layer.OldSize == layer.NewSize {
+			continue
+		}
+		fmt.Fprintf(b, "  %s -> %s (%s) %s\n", formatSize(layer.OldSize, false), formatSize(layer.NewSize, false), formatSize(layer.NewSize-layer.OldSize, true), layer.CreatedBy)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Compare compares the files under the given paths and the layers of the
+// previous image and the new one. The files are read from containers created,
+// but never started, from the images.
+func Compare(d docker.Docker, oldImage, newImage string, paths []string) (*Report, error) {
+	report := &Report{OldImage: oldImage, NewImage: newImage, Paths: paths}
+
+	oldUsage, err := d.GetResourceUsage(oldImage)
+	if err != nil {
+		return nil, err
+	}
+	newUsage, err := d.GetResourceUsage(newImage)
+	if err != nil {
+		return nil, err
+	}
+	report.Layers = compareLayers(oldUsage.Layers, newUsage.Layers)
+
+	oldFiles, err := listFiles(d, oldImage, paths)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := listFiles(d, newImage, paths)
+	if err != nil {
+		return nil, err
+	}
+	report.Added, report.Removed, report.Changed = compareFiles(oldFiles, newFiles)
+	return report, nil
+}
+
+// compareLayers matches the layers of the images, listed from the most recent
+// one, by their position from the base of the images.
+func compareLayers(oldLayers, newLayers []api.LayerUsage) []Layer {
+	count := len(oldLayers)
+	if len(newLayers) > count {
+		count = len(newLayers)
+	}
+	layers := make([]Layer, count)
+	for i := range layers {
+		if j := len(oldLayers) - 1 - i; j >= 0 {
+			layers[i].CreatedBy = oldLayers[j].CreatedBy
+			layers[i].OldSize = oldLayers[j].SizeBytes
+		}
+		if j := len(newLayers) - 1 - i; j >= 0 {
+			layers[i].CreatedBy = newLayers[j].CreatedBy
+			layers[i].NewSize = newLayers[j].SizeBytes
+		}
+	}
+	return layers
+}
+
+// compareFiles returns the files added, removed and changed from the old files
+// to the new ones, sorted by their path.
+func compareFiles(oldFiles, newFiles map[string]File) ([]File, []File, []Change) {
+	added, removed, changed := []File{}, []File{}, []Change{}
+	for p, f := range newFiles {
+		old, ok := oldFiles[p]
+		switch {
+		case !ok:
+			added = append(added, f)
+		case old.Digest != f.Digest:
+			changed = append(changed, Change{Path: p, OldSize: old.Size, NewSize: f.Size})
+		}
+	}
+	for p, f := range oldFiles {
+		if _, ok := newFiles[p]; !ok {
+			removed = append(removed, f)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Path < added[j].Path })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Path < removed[j].Path })
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+	return added, removed, changed
+}
+
+// listFiles returns the files under the paths of the image, by their path.
+// The directories are not listed.
+func listFiles(d docker.Docker, image string, paths []string) (map[string]File, error) {
+	containerID, err := d.CreateContainer(image)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a container from %s: %v", image, err)
+	}
+	defer func() {
+		if err := d.RemoveContainer(containerID); err != nil {
+			log.Warningf("Unable to remove container %s: %v", containerID, err)
+		}
+	}()
+
+	files := map[string]File{}
+	for _, p := range paths {
+		r, w := io.Pipe()
+		go func(p string) {
+			w.CloseWithError(d.DownloadFromContainer(p, w, containerID))
+		}(p)
+		err := readFiles(tar.NewReader(r), path.Dir(path.Clean(p)), files)
+		io.Copy(ioutil.Discard, r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the files of %s in %s: %v", p, image, err)
+		}
+	}
+	return files, nil
+}
+
+// readFiles adds the files of the tar archive, whose entries are relative to
+// dir, to files.
+func readFiles(tr *tar.Reader, dir string, files map[string]File) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f := File{Path: path.Join("/", dir, header.Name)}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeSymlink, tar.TypeLink:
+			f.Digest = "link:" + header.Linkname
+		default:
+			h := sha256.New()
+			if f.Size, err = io.Copy(h, tr); err != nil {
+				return err
+			}
+			f.Digest = hex.EncodeToString(h.Sum(nil))
+		}
+		files[f.Path] = f
+	}
+}
+
+// formatSize returns the size in a human readable unit, with its sign when
+// signed is true.
+func formatSize(size int64, signed bool) string {
+	sign := ""
+	if size < 0 {
+		sign = "-"
+		size = -size
+	} else if signed {
+		sign = "+"
+	}
+	units := []string{"B", "kB", "MB", "GB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%s%d %s", sign, size, units[unit])
+	}
+	return fmt.Sprintf("%s%.1f %s", sign, value, units[unit])
+}
@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+)
+
+func archive(t *testing.T, files map[string]string) string {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "src/", Mode: 0755, Typeflag: tar.TypeDir}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCompareFiles(t *testing.T) {
+	oldFiles := map[string]File{
+		"/opt/app/a": {Path: "/opt/app/a", Size: 1, Digest: "1"},
+		"/opt/app/b": {Path: "/opt/app/b", Size: 2, Digest: "2"},
+		"/opt/app/c": {Path: "/opt/app/c", Size: 3, Digest: "3"},
+	}
+	newFiles := map[string]File{
+		"/opt/app/a": {Path: "/opt/app/a", Size: 1, Digest: "1"},
+		"/opt/app/c": {Path: "/opt/app/c", Size: 5, Digest: "5"},
+		"/opt/app/d": {Path: "/opt/app/d", Size: 4, Digest: "4"},
+	}
+	added, removed, changed := compareFiles(oldFiles, newFiles)
+	if expected := []File{newFiles["/opt/app/d"]}; !reflect.DeepEqual(added, expected) {
+		t.Errorf("expected added files %v, got %v", expected, added)
+	}
+	if expected := []File{oldFiles["/opt/app/b"]}; !reflect.DeepEqual(removed, expected) {
+		t.Errorf("expected removed files %v, got %v", expected, removed)
+	}
+	if expected := []Change{{Path: "/opt/app/c", OldSize: 3, NewSize: 5}}; !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected changed files %v, got %v", expected, changed)
+	}
+}
+
+func TestCompareLayers(t *testing.T) {
+	oldLayers := []api.LayerUsage{{CreatedBy: "assemble", SizeBytes: 100}, {CreatedBy: "base", SizeBytes: 200}}
+	newLayers := []api.LayerUsage{{CreatedBy: "assemble-runtime", SizeBytes: 10}, {CreatedBy: "assemble", SizeBytes: 150}, {CreatedBy: "base", SizeBytes: 200}}
+	expected := []Layer{
+		{CreatedBy: "base", OldSize: 200, NewSize: 200},
+		{CreatedBy: "assemble", OldSize: 100, NewSize: 150},
+		{CreatedBy: "assemble-runtime", NewSize: 10},
+	}
+	layers := compareLayers(oldLayers, newLayers)
+	if !reflect.DeepEqual(layers, expected) {
+		t.Errorf("expected layers %v, got %v", expected, layers)
+	}
+	report := &Report{Layers: layers}
+	if delta := report.SizeDelta(); delta != 60 {
+		t.Errorf("expected a size delta of 60, got %d", delta)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	d := &docker.FakeDocker{
+		CreateContainerID: "diff",
+		DownloadFromContainerResult: map[string]string{
+			"/opt/app/src": archive(t, map[string]string{"src/Gemfile": "gems", "src/app.rb": "app"}),
+		},
+		ResourceUsageResult: api.ResourceUsage{Layers: []api.LayerUsage{{CreatedBy: "assemble", SizeBytes: 2000}}},
+	}
+	report, err := Compare(d, "old", "new", []string{"/opt/app/src"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Added) != 0 || len(report.Removed) != 0 || len(report.Changed) != 0 {
+		t.Errorf("expected no changes between identical images, got %+v", report)
+	}
+	if d.RemoveContainerID != "diff" {
+		t.Errorf("expected the container to be removed, got %q", d.RemoveContainerID)
+	}
+	if summary := report.String(); !strings.Contains(summary, "0 added, 0 removed, 0 changed files") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+
+	files := map[string]File{}
+	if err := readFiles(tar.NewReader(strings.NewReader(d.DownloadFromContainerResult["/opt/app/src"])), "/opt/app", files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f, ok := files["/opt/app/src/Gemfile"]; !ok || f.Size != 4 {
+		t.Errorf("expected /opt/app/src/Gemfile of 4 bytes, got %v", files)
+	}
+	if _, ok := files["/opt/app/src"]; ok {
+		t.Errorf("expected the directories not to be listed, got %v", files)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{0: "+0 B", 999: "+999 B", 1500: "+1.5 kB", -2500000: "-2.5 MB"}
+	for size, expected := range tests {
+		if actual := formatSize(size, true); actual != expected {
+			t.Errorf("expected %d to be formatted as %q, got %q", size, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package diff compares the image produced by a build with the image it
+// replaces, to help understand how the images grow from one build to the next.
+package diff
@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	dockermessage "github.com/docker/docker/pkg/jsonmessage"
+)
+
+// SaveImage writes the given image to w as a tar archive in the format of
+// docker save.
+func (d *stiDocker) SaveImage(name string, w io.Writer) error {
+	name = getImageName(name)
+	archive, err := d.client.ImageSave(context.Background(), []string{name})
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	_, err = io.Copy(w, archive)
+	return err
+}
+
+// LoadImage loads the images of the given tar archive, in the format of
+// docker save, and reports the errors of the load.
+func (d *stiDocker) LoadImage(r io.Reader) error {
+	defer d.cache.invalidate()
+	resp, err := d.client.ImageLoad(context.Background(), r, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !resp.JSON {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg dockermessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		log.V(4).Infof("loading image: %s", msg.Stream)
+	}
+}
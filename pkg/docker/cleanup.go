@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/openshift/source-to-image/pkg/api/constants"
+)
+
+// volumeNameRegexp matches the names of the Docker volumes, as opposed to the
+// host paths of the bind mounts.
+var volumeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// namedVolume returns the name of the volume mounted by the given bind, in the
+// src:dst[:options] form, or an empty string if a host path is mounted.
+func namedVolume(bind string) string {
+	parts := strings.SplitN(bind, ":", 2)
+	if len(parts) != 2 || !volumeNameRegexp.MatchString(parts[0]) {
+		return ""
+	}
+	return parts[0]
+}
+
+// createNamedVolumes creates the missing volumes mounted by the binds with the
+// labels of the naming policy, instead of leaving the engine create them
+// implicitly without labels.
+func (d *stiDocker) createNamedVolumes(binds []string) {
+	for _, bind := range binds {
+		name := namedVolume(bind)
+		if len(name) == 0 {
+			continue
+		}
+		ctx, cancel := getDefaultContext()
+		_, err := d.client.VolumeInspect(ctx, name)
+		if errdefs.IsNotFound(err) {
+			log.V(2).Infof("Creating volume %s", name)
+			_, err = d.client.VolumeCreate(ctx, volume.CreateOptions{Name: name, Labels: d.naming.Labels()})
+		}
+		cancel()
+		if err != nil {
+			log.Warningf("Unable to create volume %s, it is created by the engine if missing: %v", name, err)
+		}
+	}
+}
+
+// CreateVolume creates the volume with the given labels, unless it exists.
+// Unlike the volumes mounted by the binds of the containers, it is not labeled
+// with the ownership labels of the naming policy, so that it outlives the build
+// and is not removed by Cleanup.
+func (d *stiDocker) CreateVolume(name string, labels map[string]string) error {
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	_, err := d.client.VolumeInspect(ctx, name)
+	if errdefs.IsNotFound(err) {
+		log.V(2).Infof("Creating volume %s", name)
+		_, err = d.client.VolumeCreate(ctx, volume.CreateOptions{Name: name, Labels: labels})
+	}
+	return err
+}
+
+// CleanupResult lists the resources removed by Cleanup.
+type CleanupResult struct {
+	Containers []string
+	Images     []string
+	Volumes    []string
+}
+
+// Cleanup removes the containers, the temporary images and the volumes
+// created by the given s2i invocation, which are left behind when the build
+// is aborted. The images built by the invocation are kept.
+func Cleanup(client Client, invocationID string) (*CleanupResult, error) {
+	result := &CleanupResult{}
+	errs := []string{}
+	owned := filters.NewArgs(filters.Arg("label", constants.InvocationIDLabel+"="+invocationID))
+
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+
+	containers, err := client.ContainerList(ctx, dockercontainer.ListOptions{All: true, Filters: owned})
+	if err != nil {
+		return result, err
+	}
+	for _, c := range containers {
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if err := client.ContainerRemove(ctx, c.ID, dockercontainer.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil && !errdefs.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("container %s: %v", name, err))
+			continue
+		}
+		result.Containers = append(result.Containers, name)
+	}
+
+	temporary := owned.Clone()
+	temporary.Add("label", constants.TemporaryLabel+"=true")
+	images, err := client.ImageList(ctx, image.ListOptions{Filters: temporary})
+	if err != nil {
+		return result, err
+	}
+	for _, i := range images {
+		if _, err := client.ImageRemove(ctx, i.ID, image.RemoveOptions{Force: true, PruneChildren: true}); err != nil && !errdefs.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("image %s: %v", i.ID, err))
+			continue
+		}
+		result.Images = append(result.Images, i.ID)
+	}
+
+	volumes, err := client.VolumeList(ctx, volume.ListOptions{Filters: owned})
+	if err != nil {
+		return result, err
+	}
+	for _, v := range volumes.Volumes {
+		if err := client.VolumeRemove(ctx, v.Name, false); err != nil && !errdefs.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("volume %s: %v", v.Name, err))
+			continue
+		}
+		result.Volumes = append(result.Volumes, v.Name)
+	}
+
+	if len(errs) > 0 {
+		return result, fmt.Errorf("unable to remove %s", strings.Join(errs, ", "))
+	}
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+	dockermessage "github.com/docker/docker/pkg/jsonmessage"
+
+	"github.com/openshift/source-to-image/pkg/util"
+)
+
+// commitBackoff is the time waited before the first retry of a failed commit,
+// doubled before each of the following ones.
+var commitBackoff = 5 * time.Second
+
+// isTransientCommitError returns true when the failed commit may succeed if
+// retried, that is unless the daemon reported an error that retrying will not
+// fix, such as a missing container or an invalid parameter.
+func isTransientCommitError(err error) bool {
+	switch {
+	case errdefs.IsNotFound(err), errdefs.IsInvalidParameter(err), errdefs.IsConflict(err),
+		errdefs.IsUnauthorized(err), errdefs.IsForbidden(err), errdefs.IsNotImplemented(err),
+		errdefs.IsCancelled(err):
+		return false
+	}
+	return true
+}
+
+// reportCommitProgress periodically reports that the given container is still
+// being committed, until the returned function is called.
+func reportCommitProgress(containerID string) func() {
+	return reportProgress(func(elapsed time.Duration) {
+		log.Infof("Still committing container %s after %v ...", containerID, elapsed.Round(time.Second))
+	})
+}
+
+// exportImportContainer creates an image from the exported file system of the
+// container, with the configuration of the container and of the commit, when
+// committing it fails. The image is flattened to a single layer.
+func (d *stiDocker) exportImportContainer(opts CommitContainerOptions, config *dockercontainer.Config) (string, error) {
+	ctx := context.Background()
+	container, err := d.client.ContainerInspect(ctx, opts.ContainerID)
+	if err != nil {
+		return "", err
+	}
+	export, err := d.client.ContainerExport(ctx, opts.ContainerID)
+	if err != nil {
+		return "", err
+	}
+	defer export.Close()
+
+	source := newCountingReader(export)
+	stop := reportProgress(func(elapsed time.Duration) {
+		log.Infof("Exported %.1f MiB of container %s after %v ...", source.mebibytes(), opts.ContainerID, elapsed.Round(time.Second))
+	})
+	defer stop()
+	importOpts := image.ImportOptions{
+		Message: fmt.Sprintf("Imported from the file system of container %s", opts.ContainerID),
+		Changes: importChanges(container.Config, config),
+	}
+	log.V(2).Infof("Importing container %s with changes: %v", opts.ContainerID, importOpts.Changes)
+	resp, err := d.client.ImageImport(ctx, image.ImportSource{Source: source, SourceName: "-"}, opts.Repository, importOpts)
+	d.cache.invalidate()
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+
+	var imageID string
+	decoder := json.NewDecoder(resp)
+	for {
+		var msg dockermessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if msg.Error != nil {
+			return "", msg.Error
+		}
+		if len(msg.Status) > 0 {
+			imageID = msg.Status
+		}
+	}
+	if len(imageID) == 0 {
+		return "", fmt.Errorf("the import of container %s did not return an image ID", opts.ContainerID)
+	}
+	log.V(1).Infof("Imported %.1f MiB of container %s as image %s", source.mebibytes(), opts.ContainerID, imageID)
+	return imageID, nil
+}
+
+// importChanges returns the Dockerfile instructions applying the configuration
+// of the container to the imported image, overridden by the configuration of
+// the commit, the way docker commit merges them.
+func importChanges(base, config *dockercontainer.Config) []string {
+	if base == nil {
+		base = &dockercontainer.Config{}
+	}
+	merged := *base
+	if config != nil {
+		if len(config.User) > 0 {
+			merged.User = config.User
+		}
+		merged.Env = mergeEnv(base.Env, config.Env)
+		merged.Labels = map[string]string{}
+		for k, v := range base.Labels {
+			merged.Labels[k] = v
+		}
+		for k, v := range config.Labels {
+			merged.Labels[k] = v
+		}
+		if len(config.Cmd) > 0 || len(config.Entrypoint) > 0 {
+			merged.Cmd, merged.Entrypoint = config.Cmd, config.Entrypoint
+		}
+	}
+
+	var changes []string
+	for _, env := range merged.Env {
+		name, value, _ := strings.Cut(env, "=")
+		changes = append(changes, fmt.Sprintf("ENV %s=%s", name, quoteChange(value)))
+	}
+	labels := make([]string, 0, len(merged.Labels))
+	for k := range merged.Labels {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	for _, k := range labels {
+		changes = append(changes, fmt.Sprintf("LABEL %s=%s", quoteChange(k), quoteChange(merged.Labels[k])))
+	}
+	ports := make([]string, 0, len(merged.ExposedPorts))
+	for port := range merged.ExposedPorts {
+		ports = append(ports, string(port))
+	}
+	sort.Strings(ports)
+	if len(ports) > 0 {
+		changes = append(changes, "EXPOSE "+strings.Join(ports, " "))
+	}
+	volumes := make([]string, 0, len(merged.Volumes))
+	for volume := range merged.Volumes {
+		volumes = append(volumes, volume)
+	}
+	sort.Strings(volumes)
+	if len(volumes) > 0 {
+		changes = append(changes, "VOLUME "+jsonArray(volumes))
+	}
+	if len(merged.User) > 0 {
+		changes = append(changes, "USER "+merged.User)
+	}
+	if len(merged.WorkingDir) > 0 {
+		changes = append(changes, "WORKDIR "+merged.WorkingDir)
+	}
+	if len(merged.StopSignal) > 0 {
+		changes = append(changes, "STOPSIGNAL "+merged.StopSignal)
+	}
+	changes = append(changes, "ENTRYPOINT "+jsonArray(merged.Entrypoint))
+	if len(merged.Cmd) > 0 {
+		changes = append(changes, "CMD "+jsonArray(merged.Cmd))
+	}
+	return changes
+}
+
+// mergeEnv returns the environment variables of base, overridden by those of
+// the same name in env, followed by the other variables of env.
+func mergeEnv(base, env []string) []string {
+	merged := append([]string{}, base...)
+	index := map[string]int{}
+	for i, e := range merged {
+		name, _, _ := strings.Cut(e, "=")
+		index[name] = i
+	}
+	for _, e := range env {
+		name, _, _ := strings.Cut(e, "=")
+		if i, ok := index[name]; ok {
+			merged[i] = e
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// quoteChange double quotes the value of an instruction, escaping the
+// characters the Dockerfile parser would interpret. Each change is parsed as a
+// single line, so the line breaks of the value are written as \n and \r,
+// which the parser keeps literally.
+func quoteChange(value string) string {
+	value = strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(util.EscapeDockerfileValue(value))
+	return `"` + value + `"`
+}
+
+// jsonArray returns the exec form of an instruction, without escaping the
+// characters of its arguments that are special in HTML.
+func jsonArray(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(values)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
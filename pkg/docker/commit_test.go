@@ -0,0 +1,145 @@
+package docker
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+
+	dockertest "github.com/openshift/source-to-image/pkg/docker/test"
+)
+
+func TestCommitContainerRetries(t *testing.T) {
+	defer func(backoff time.Duration) { commitBackoff = backoff }(commitBackoff)
+	commitBackoff = 0
+
+	failure := fmt.Errorf("Error response from daemon: unexpected EOF")
+	tests := map[string]struct {
+		fails          []error
+		retries        int
+		exportFallback bool
+		importOutput   string
+		expectedID     string
+		expectedErr    bool
+		expectedCalls  []string
+	}{
+		"committed after a retry": {
+			fails:         []error{failure},
+			retries:       2,
+			expectedID:    "sha256:commit",
+			expectedCalls: []string{"commit", "commit"},
+		},
+		"retries exhausted": {
+			fails:         []error{failure, failure, failure},
+			retries:       1,
+			expectedErr:   true,
+			expectedCalls: []string{"commit", "commit"},
+		},
+		"container not found": {
+			fails:          []error{errdefs.NotFound(fmt.Errorf("No such container: container"))},
+			retries:        2,
+			exportFallback: true,
+			expectedErr:    true,
+			expectedCalls:  []string{"commit"},
+		},
+		"invalid parameter": {
+			fails:         []error{failure, errdefs.InvalidParameter(fmt.Errorf("invalid reference format"))},
+			retries:       2,
+			expectedErr:   true,
+			expectedCalls: []string{"commit", "commit"},
+		},
+		"export and import": {
+			fails:          []error{failure, failure},
+			retries:        1,
+			exportFallback: true,
+			importOutput:   `{"status":"sha256:import"}`,
+			expectedID:     "sha256:import",
+			expectedCalls:  []string{"commit", "commit", "inspect_container", "export", "import"},
+		},
+		"failed import": {
+			fails:          []error{failure},
+			exportFallback: true,
+			importOutput:   `{"errorDetail":{"message":"no space left on device"},"error":"no space left on device"}`,
+			expectedErr:    true,
+			expectedCalls:  []string{"commit", "inspect_container", "export", "import"},
+		},
+	}
+	for desc, tc := range tests {
+		fakeDocker := &dockertest.FakeDockerClient{
+			ContainerCommitFails:    tc.fails,
+			ContainerCommitResponse: dockertypes.IDResponse{ID: "sha256:commit"},
+			ExportOutput:            []byte("file system"),
+			ImportOutput:            []byte(tc.importOutput),
+		}
+		dh := getDocker(fakeDocker)
+		imageID, err := dh.CommitContainer(CommitContainerOptions{
+			ContainerID:    "container",
+			Repository:     "app",
+			Command:        []string{"/usr/libexec/s2i/run"},
+			Retries:        tc.retries,
+			ExportFallback: tc.exportFallback,
+		})
+		if tc.expectedErr != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+		}
+		if imageID != tc.expectedID {
+			t.Errorf("%s: expected the image %q, got %q", desc, tc.expectedID, imageID)
+		}
+		if !reflect.DeepEqual(fakeDocker.Calls, tc.expectedCalls) {
+			t.Errorf("%s: expected the calls %v, got %v", desc, tc.expectedCalls, fakeDocker.Calls)
+		}
+		if tc.exportFallback && tc.expectedCalls[len(tc.expectedCalls)-1] == "import" {
+			if string(fakeDocker.ImportSource) != "file system" || fakeDocker.ImportRef != "app" {
+				t.Errorf("%s: expected the file system to be imported as app, got %q as %q", desc, fakeDocker.ImportSource, fakeDocker.ImportRef)
+			}
+		}
+	}
+}
+
+func TestImportChanges(t *testing.T) {
+	base := &dockercontainer.Config{
+		Env:          []string{"PATH=/usr/bin", "HOME=/opt/app-root"},
+		Labels:       map[string]string{"io.openshift.s2i.scripts-url": "image:///usr/libexec/s2i", "summary": `the "base" image`},
+		User:         "root",
+		WorkingDir:   "/opt/app-root/src",
+		ExposedPorts: nat.PortSet{"8080/tcp": {}, "8443/tcp": {}},
+		Volumes:      map[string]struct{}{"/data": {}},
+		Entrypoint:   []string{"container-entrypoint"},
+		Cmd:          []string{"/bin/sh", "-c", "tar -C /tmp -xf - && /usr/libexec/s2i/assemble"},
+	}
+	commit := &dockercontainer.Config{
+		Env:        []string{"HOME=/home/$USER", "APP=ruby"},
+		Labels:     map[string]string{"io.k8s.display-name": "app", "description": "first line\nsecond line"},
+		User:       "1001",
+		Entrypoint: []string{},
+		Cmd:        []string{"/usr/libexec/s2i/run"},
+	}
+	expected := []string{
+		`ENV PATH="/usr/bin"`,
+		`ENV HOME="/home/\$USER"`,
+		`ENV APP="ruby"`,
+		`LABEL "description"="first line\nsecond line"`,
+		`LABEL "io.k8s.display-name"="app"`,
+		`LABEL "io.openshift.s2i.scripts-url"="image:///usr/libexec/s2i"`,
+		`LABEL "summary"="the \"base\" image"`,
+		`EXPOSE 8080/tcp 8443/tcp`,
+		`VOLUME ["/data"]`,
+		`USER 1001`,
+		`WORKDIR /opt/app-root/src`,
+		`ENTRYPOINT []`,
+		`CMD ["/usr/libexec/s2i/run"]`,
+	}
+	if changes := importChanges(base, commit); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected the changes\n%v\ngot\n%v", expected, changes)
+	}
+
+	expected = []string{`ENTRYPOINT ["container-entrypoint"]`, `CMD ["/bin/sh","-c","tar -C /tmp -xf - && /usr/libexec/s2i/assemble"]`}
+	if changes := importChanges(&dockercontainer.Config{Entrypoint: base.Entrypoint, Cmd: base.Cmd}, nil); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected the changes\n%v\ngot\n%v", expected, changes)
+	}
+}
@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"os"
+
+	imagedocker "github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/types"
+
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+// imageExtractionFactor is the factor the size of the compressed layers of an
+// image is multiplied by to estimate the space its extraction takes.
+const imageExtractionFactor = 2
+
+// GetImagePullSize returns the compressed size of the layers of the image, as
+// listed by its manifest in the registry, or in the image store offline, or 0
+// if the image is present locally.
+func (d *stiDocker) GetImagePullSize(name string) (int64, error) {
+	name = getImageName(name)
+	if found, err := d.IsImageInLocalRegistry(name); err != nil || found {
+		return 0, err
+	}
+	if d.offline != nil {
+		return d.offline.imageSize(name)
+	}
+	ref, err := imagedocker.ParseReference("//" + name)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	img, err := ref.NewImage(ctx, d.registrySystemContext())
+	if err != nil {
+		return 0, err
+	}
+	defer img.Close()
+	var size int64
+	for _, layer := range img.LayerInfos() {
+		if layer.Size > 0 {
+			size += layer.Size
+		}
+	}
+	return size, nil
+}
+
+// registrySystemContext returns the context of the requests sent to the
+// registries directly, rather than through the daemon, authenticated with the
+// pull credentials.
+func (d *stiDocker) registrySystemContext() *types.SystemContext {
+	sys := &types.SystemContext{}
+	if len(d.pullAuth.Username) > 0 || len(d.pullAuth.IdentityToken) > 0 {
+		sys.DockerAuthConfig = &types.DockerAuthConfig{
+			Username:      d.pullAuth.Username,
+			Password:      d.pullAuth.Password,
+			IdentityToken: d.pullAuth.IdentityToken,
+		}
+	}
+	return sys
+}
+
+// GetDataRoot returns the directory the daemon stores its images in, or an
+// empty string when the daemon does not tell it.
+func (d *stiDocker) GetDataRoot() (string, error) {
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	info, err := d.client.Info(ctx)
+	if err != nil {
+		return "", err
+	}
+	return info.DockerRootDir, nil
+}
+
+// CheckPullDiskSpace checks, before the image is pulled, that the file system
+// holding the images of the daemon has room for it. The check is skipped when
+// the image is present locally, when the daemon runs on another host, or when
+// the size of the image cannot be determined.
+func CheckPullDiskSpace(d Docker, name string) error {
+	root, err := d.GetDataRoot()
+	if err != nil || len(root) == 0 {
+		log.V(1).Infof("Unable to determine where the images are stored, skipping the disk space check: %v", err)
+		return nil
+	}
+	if _, err := os.Stat(root); err != nil {
+		log.V(3).Infof("The images are stored in %q, which is not reachable from this host, skipping the disk space check", root)
+		return nil
+	}
+	size, err := d.GetImagePullSize(name)
+	if err != nil {
+		log.V(1).Infof("Unable to determine the size of image %q, skipping the disk space check: %v", name, err)
+		return nil
+	}
+	if size == 0 {
+		return nil
+	}
+	return fs.CheckDiskSpace(root, uint64(size)*imageExtractionFactor, 0)
+}
@@ -9,13 +9,15 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math/rand"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -24,19 +26,25 @@ import (
 	"github.com/docker/docker/api/types/image"
 	dockernetwork "github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/api/types/volume"
 	dockerapi "github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	dockermessage "github.com/docker/docker/pkg/jsonmessage"
 	dockerstdcopy "github.com/docker/docker/pkg/stdcopy"
-	"github.com/docker/go-connections/tlsconfig"
+	"github.com/docker/go-connections/nat"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/net/context"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/containerd"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 	s2itar "github.com/openshift/source-to-image/pkg/tar"
 	"github.com/openshift/source-to-image/pkg/util"
 	"github.com/openshift/source-to-image/pkg/util/fs"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
 	"github.com/openshift/source-to-image/pkg/util/interrupt"
 )
 
@@ -68,6 +76,15 @@ var (
 		"transport closed before response was received",
 		"connection refused",
 	}
+
+	// UnauthorizedErrors is a set of strings that indicate that the registry
+	// rejected the credentials.
+	UnauthorizedErrors = []string{
+		"unauthorized",
+		"authentication required",
+		"pull access denied",
+		"no basic auth credentials",
+	}
 )
 
 // containerNamePrefix prefixes the name of containers launched by S2I. We
@@ -75,20 +92,10 @@ var (
 // managed by a kubelet.
 const containerNamePrefix = "s2i"
 
-// containerName creates names for Docker containers launched by S2I. It is
-// meant to resemble Kubernetes' pkg/kubelet/dockertools.BuildDockerName.
+// containerName creates names for Docker containers launched by S2I with the
+// default naming policy.
 func containerName(image string) string {
-	//Initialize seed
-	rand.Seed(time.Now().UnixNano())
-	uid := fmt.Sprintf("%08x", rand.Uint32())
-	// Replace invalid characters for container name with underscores.
-	image = strings.Map(func(r rune) rune {
-		if ('0' <= r && r <= '9') || ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z') {
-			return r
-		}
-		return '_'
-	}, image)
-	return fmt.Sprintf("%s_%s_%s", containerNamePrefix, image, uid)
+	return DefaultNamingPolicy{}.ContainerName(image)
 }
 
 // Docker is the interface between STI and the docker engine-api.
@@ -99,60 +106,106 @@ type Docker interface {
 	IsImageOnBuild(string) bool
 	GetOnBuild(string) ([]string, error)
 	RemoveContainer(id string) error
+	StopContainer(id string, timeout time.Duration) error
 	GetScriptsURL(name string) (string, error)
 	GetAssembleInputFiles(string) (string, error)
 	GetAssembleRuntimeUser(string) (string, error)
 	RunContainer(opts RunContainerOptions) error
+	CreateContainer(image string) (string, error)
+	ProbeImage(name, user, destination string) (*ImageProbe, error)
+	ExecContainer(id string, cmd []string, stdout, stderr io.Writer) error
 	GetImageID(name string) (string, error)
 	GetImageWorkdir(name string) (string, error)
 	CommitContainer(opts CommitContainerOptions) (string, error)
 	RemoveImage(name string) error
+	TagImage(name, tag string) error
 	CheckImage(name string) (*api.Image, error)
 	PullImage(name string) (*api.Image, error)
+	SaveImage(name string, w io.Writer) error
+	LoadImage(r io.Reader) error
 	CheckAndPullImage(name string) (*api.Image, error)
-	BuildImage(opts BuildImageOptions) error
+	CheckAndPullChangedImage(name string) (*api.Image, error)
+	BuildImage(opts BuildImageOptions) (string, error)
 	GetImageUser(name string) (string, error)
 	GetImageEntrypoint(name string) ([]string, error)
+	GetImageCmd(name string) ([]string, error)
+	GetImageExposedPorts(name string) ([]string, error)
 	GetLabels(name string) (map[string]string, error)
 	UploadToContainer(fs fs.FileSystem, srcPath, destPath, container string) error
 	UploadToContainerWithTarWriter(fs fs.FileSystem, srcPath, destPath, container string, makeTarWriter func(io.Writer) s2itar.Writer) error
 	DownloadFromContainer(containerPath string, w io.Writer, container string) error
 	Version() (dockertypes.Version, error)
 	CheckReachable() error
+	GetResourceUsage(name string) (api.ResourceUsage, error)
+	GetImagePullSize(name string) (int64, error)
+	GetDataRoot() (string, error)
+	CreateVolume(name string, labels map[string]string) error
 }
 
 // Client contains all methods used when interacting directly with docker engine-api
 type Client interface {
 	ContainerAttach(ctx context.Context, container string, options dockercontainer.AttachOptions) (dockertypes.HijackedResponse, error)
 	ContainerCommit(ctx context.Context, container string, options dockercontainer.CommitOptions) (dockertypes.IDResponse, error)
+	ContainerExport(ctx context.Context, container string) (io.ReadCloser, error)
 	ContainerCreate(ctx context.Context, config *dockercontainer.Config, hostConfig *dockercontainer.HostConfig, networkingConfig *dockernetwork.NetworkingConfig, platform *v1.Platform, containerName string) (dockercontainer.CreateResponse, error)
 	ContainerInspect(ctx context.Context, container string) (dockertypes.ContainerJSON, error)
+	ContainerList(ctx context.Context, options dockercontainer.ListOptions) ([]dockertypes.Container, error)
 	ContainerRemove(ctx context.Context, container string, options dockercontainer.RemoveOptions) error
 	ContainerStart(ctx context.Context, container string, options dockercontainer.StartOptions) error
+	ContainerStats(ctx context.Context, container string, stream bool) (dockercontainer.StatsResponseReader, error)
 	ContainerKill(ctx context.Context, container, signal string) error
+	ContainerExecCreate(ctx context.Context, container string, options dockercontainer.ExecOptions) (dockertypes.IDResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, options dockercontainer.ExecAttachOptions) (dockertypes.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (dockercontainer.ExecInspect, error)
 	ContainerWait(ctx context.Context, container string, condition dockercontainer.WaitCondition) (<-chan dockercontainer.WaitResponse, <-chan error)
 	CopyToContainer(ctx context.Context, container, path string, content io.Reader, opts dockertypes.CopyToContainerOptions) error
 	CopyFromContainer(ctx context.Context, container, srcPath string) (io.ReadCloser, dockertypes.ContainerPathStat, error)
+	DistributionInspect(ctx context.Context, image, encodedRegistryAuth string) (registry.DistributionInspect, error)
 	ImageBuild(ctx context.Context, buildContext io.Reader, options dockertypes.ImageBuildOptions) (dockertypes.ImageBuildResponse, error)
+	ImageHistory(ctx context.Context, image string) ([]image.HistoryResponseItem, error)
+	ImageImport(ctx context.Context, source image.ImportSource, ref string, options image.ImportOptions) (io.ReadCloser, error)
 	ImageInspectWithRaw(ctx context.Context, image string) (dockertypes.ImageInspect, []byte, error)
+	ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error)
+	ImageLoad(ctx context.Context, input io.Reader, quiet bool) (image.LoadResponse, error)
+	ImageSave(ctx context.Context, images []string) (io.ReadCloser, error)
 	ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error)
 	ImageRemove(ctx context.Context, image string, options image.RemoveOptions) ([]image.DeleteResponse, error)
+	ImageTag(ctx context.Context, source, target string) error
 	ServerVersion(ctx context.Context) (dockertypes.Version, error)
+	Info(ctx context.Context) (system.Info, error)
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+	VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error)
+	VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
 }
 
 type stiDocker struct {
 	client   Client
 	pullAuth registry.AuthConfig
+	// authSource describes the credentials of pullAuth in the errors
+	authSource string
+	cache      *inspectCache
+	usage      *usageRecorder
+	naming     NamingPolicy
+	// offline forbids the pulls when it is not nil
+	offline *offlineMode
 }
 
 // InspectImage returns the image information and its raw representation.
+// The results are cached for the lifetime of the process, until an image is
+// pulled, built, committed or removed.
 func (d stiDocker) InspectImage(name string) (*dockertypes.ImageInspect, error) {
+	if resp, ok := d.cache.get(name); ok {
+		log.V(5).Infof("Using cached inspection of image %s", name)
+		return resp, nil
+	}
 	ctx, cancel := getDefaultContext()
 	defer cancel()
 	resp, _, err := d.client.ImageInspectWithRaw(ctx, name)
 	if err != nil {
 		return nil, err
 	}
+	d.cache.put(name, resp)
 	return &resp, nil
 }
 
@@ -180,11 +233,14 @@ type RunContainerOptions struct {
 	// Entrypoint will be used to override the default entrypoint
 	// for the image if it has one.  If the image has no entrypoint,
 	// this value is ignored.
-	Entrypoint       []string
-	Stdin            io.ReadCloser
-	Stdout           io.WriteCloser
-	Stderr           io.WriteCloser
-	OnStart          func(containerID string) error
+	Entrypoint []string
+	Stdin      io.ReadCloser
+	Stdout     io.WriteCloser
+	Stderr     io.WriteCloser
+	OnStart    func(containerID string) error
+	// OnExit is called once the container exits, whatever its exit code,
+	// before it is removed.
+	OnExit           func(containerID string)
 	PostExec         PostExecutor
 	TargetImage      bool
 	NetworkMode      string
@@ -205,6 +261,23 @@ type RunContainerOptions struct {
 	CommandExplicit []string
 	// SecurityOpt is passed through as security options to the underlying container.
 	SecurityOpt []string
+	// PortBindings lists the ports of the container published on the host, in
+	// the ip:hostPort:containerPort/protocol format of docker run --publish.
+	// They replace the publication of all the exposed ports of TargetImage.
+	PortBindings []string
+	// Detach starts the container and returns without waiting for it to exit
+	// or removing it.
+	Detach bool
+	// StopTimeout is the time given to the container to exit after SIGTERM
+	// when it is stopped before it exits, before killing it. The container is
+	// killed right away when zero.
+	StopTimeout time.Duration
+	// Deadline is the time the container is stopped at, failing the run with
+	// an error wrapping context.DeadlineExceeded. Zero means no deadline.
+	Deadline time.Time
+	// RedactEnvPatterns are the patterns of the names of the variables of Env
+	// whose values are not logged (see util.IsRedactedEnv).
+	RedactEnvPatterns []string
 }
 
 // asDockerConfig converts a RunContainerOptions into a Config understood by the
@@ -249,11 +322,12 @@ type configWrapper struct {
 
 // asDockerCreateContainerOptions converts a RunContainerOptions into a
 // ContainerCreateConfig understood by the docker client
-func (rco RunContainerOptions) asDockerCreateContainerOptions() configWrapper {
+func (rco RunContainerOptions) asDockerCreateContainerOptions(naming NamingPolicy) configWrapper {
 	config := rco.asDockerConfig()
+	config.Labels = naming.Labels()
 	hostConfig := rco.asDockerHostConfig()
 	return configWrapper{
-		Name:       containerName(rco.Image),
+		Name:       naming.ContainerName(rco.Image),
 		Config:     &config,
 		HostConfig: &hostConfig,
 	}
@@ -279,6 +353,15 @@ type CommitContainerOptions struct {
 	Env         []string
 	Entrypoint  []string
 	Labels      map[string]string
+	// Retries is the number of times the commit is retried, with an
+	// exponential backoff, when it fails.
+	Retries int
+	// ExportFallback creates the image by exporting the file system of the
+	// container and importing it when the commit still fails.
+	ExportFallback bool
+	// RedactEnvPatterns are the patterns of the names of the variables of Env
+	// whose values are not logged (see util.IsRedactedEnv).
+	RedactEnvPatterns []string
 }
 
 // BuildImageOptions are options passed in to the BuildImage method
@@ -287,28 +370,95 @@ type BuildImageOptions struct {
 	Stdin        io.Reader
 	Stdout       io.WriteCloser
 	CGroupLimits *api.CGroupLimits
+	// Compression is the compression requested for the build context read
+	// from Stdin. It is negotiated with the daemon before the upload.
+	Compression api.Compression
+	// BuildArgs are the build-time variables of the build.
+	BuildArgs api.BuildArgList
+	// Labels are set on the resulting image.
+	Labels map[string]string
+	// NetworkMode is the network of the RUN instructions of the build.
+	NetworkMode string
+	// RedactEnvPatterns are the patterns of the names of the BuildArgs whose
+	// values are not logged (see util.IsRedactedEnv).
+	RedactEnvPatterns []string
+	// Deadline is the time the build is canceled at, failing it with an error
+	// wrapping context.DeadlineExceeded. Zero means no deadline.
+	Deadline time.Time
+}
+
+// engineClient is the Client returned by NewClient. It holds the state shared
+// by the Docker instances created with the client, so that the many instances
+// of a build use the same settings and account their resources together.
+type engineClient struct {
+	Client
+	usage  *usageRecorder
+	naming NamingPolicy
+	// offline forbids the pulls when it is not nil
+	offline *offlineMode
+}
+
+// NewClient creates a client for the container engine selected in the given
+// configuration.
+func NewClient(config *api.DockerConfig) (Client, error) {
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	c := &engineClient{Client: client, usage: &usageRecorder{}, naming: NewNamingPolicy(config)}
+	if config.Offline {
+		c.offline = &offlineMode{imageStore: config.ImageStore}
+	}
+	return c, nil
+}
+
+// newClient creates the client of the container engine, which NewClient wraps.
+func newClient(config *api.DockerConfig) (Client, error) {
+	if config.Engine == api.EngineContainerd {
+		return containerd.NewClient(config.ContainerdAddress, config.ContainerdNamespace, config.RegistriesConf)
+	}
+	if len(config.Context) > 0 {
+		if err := UseDockerContext(config, config.Context); err != nil {
+			return nil, err
+		}
+		log.V(1).Infof("Using the container engine at %s, selected from docker context %q", config.Endpoint, config.Context)
+	} else if endpoint, source := defaultEndpoint(); endpoint.Host == config.Endpoint {
+		log.V(1).Infof("Using the container engine at %s, selected from the %s", config.Endpoint, source)
+	} else {
+		log.V(1).Infof("Using the container engine at %s", config.Endpoint)
+	}
+	return NewEngineAPIClient(config)
 }
 
 // NewEngineAPIClient creates a new Docker engine API client
 func NewEngineAPIClient(config *api.DockerConfig) (*dockerapi.Client, error) {
 	var httpClient *http.Client
 
-	if config.UseTLS || config.TLSVerify {
-		tlscOptions := tlsconfig.Options{
-			InsecureSkipVerify: !config.TLSVerify,
+	if isSSHEndpoint(config.Endpoint) {
+		// the connections are tunneled through ssh, which encrypts them
+		dialer, err := sshDialer(config.Endpoint)
+		if err != nil {
+			return nil, err
 		}
+		return dockerapi.NewClientWithOpts(
+			dockerapi.WithHost(sshDialHost),
+			dockerapi.WithDialContext(dialer),
+			dockerapi.WithAPIVersionNegotiation(),
+			dockerapi.WithVersionFromEnv(),
+		)
+	}
 
-		if _, err := os.Stat(config.CAFile); !os.IsNotExist(err) {
-			tlscOptions.CAFile = config.CAFile
-		}
-		if _, err := os.Stat(config.CertFile); !os.IsNotExist(err) {
-			tlscOptions.CertFile = config.CertFile
-		}
-		if _, err := os.Stat(config.KeyFile); !os.IsNotExist(err) {
-			tlscOptions.KeyFile = config.KeyFile
-		}
+	if len(config.SPIFFEEndpointSocket) > 0 && !strings.HasPrefix(config.Endpoint, "tcp://") {
+		// the SVIDs authenticate s2i to the engines reached over the network,
+		// the local sockets are protected by their permissions
+		log.V(1).Infof("Not using the SPIFFE Workload API for the endpoint %s, which is not a tcp:// endpoint", config.Endpoint)
+		withoutSPIFFE := *config
+		withoutSPIFFE.SPIFFEEndpointSocket = ""
+		config = &withoutSPIFFE
+	}
 
-		tlsc, err := tlsconfig.Client(tlscOptions)
+	if config.UseTLS || config.TLSVerify || len(config.SPIFFEEndpointSocket) > 0 {
+		tlsc, err := clientTLSConfig(config)
 		if err != nil {
 			return nil, err
 		}
@@ -334,20 +484,30 @@ func NewEngineAPIClient(config *api.DockerConfig) (*dockerapi.Client, error) {
 
 // New creates a new implementation of the STI Docker interface
 func New(client Client, auth api.AuthConfig) Docker {
-	return &stiDocker{
+	d := &stiDocker{
 		client: client,
+		cache:  getInspectCache(client),
+		naming: NewNamingPolicy(nil),
 		pullAuth: registry.AuthConfig{
 			Username:      auth.Username,
 			Password:      auth.Password,
 			Email:         auth.Email,
 			ServerAddress: auth.ServerAddress,
+			IdentityToken: auth.IdentityToken,
 		},
+		authSource: describeCredentials(auth),
+	}
+	if c, ok := client.(*engineClient); ok {
+		d.usage = c.usage
+		d.naming = c.naming
+		d.offline = c.offline
 	}
+	return d
 }
 
 func getDefaultContext() (context.Context, context.CancelFunc) {
 	// the intention is: all docker API calls with the exception of known long-
-	// running calls (ContainerWait, ImagePull, ImageBuild, ImageCommit) must complete within a
+	// running calls (ContainerWait, ImagePull, ImageBuild, ImageCommit, CopyToContainer) must complete within a
 	// certain timeout otherwise we bail.
 	return context.WithTimeout(context.Background(), DefaultDockerTimeout)
 }
@@ -378,6 +538,32 @@ func (d *stiDocker) GetImageEntrypoint(name string) ([]string, error) {
 	return image.Config.Entrypoint, nil
 }
 
+// GetImageCmd returns the CMD property for the given image name.
+func (d *stiDocker) GetImageCmd(name string) ([]string, error) {
+	image, err := d.InspectImage(name)
+	if err != nil {
+		return nil, err
+	}
+	return image.Config.Cmd, nil
+}
+
+// GetImageExposedPorts returns the sorted ports exposed by the given image, in
+// port/protocol format.
+func (d *stiDocker) GetImageExposedPorts(name string) ([]string, error) {
+	image, err := d.InspectImage(getImageName(name))
+	if err != nil {
+		return nil, err
+	}
+	ports := []string{}
+	if image.Config != nil {
+		for port := range image.Config.ExposedPorts {
+			ports = append(ports, string(port))
+		}
+	}
+	sort.Strings(ports)
+	return ports, nil
+}
+
 // UploadToContainer uploads artifacts to the container.
 func (d *stiDocker) UploadToContainer(fs fs.FileSystem, src, dest, container string) error {
 	makeWorldWritable := func(writer io.Writer) s2itar.Writer {
@@ -393,11 +579,13 @@ func (d *stiDocker) UploadToContainer(fs fs.FileSystem, src, dest, container str
 // If the source is a single file, then the file copied into destination (which
 // has to be full path to a file inside the container).
 func (d *stiDocker) UploadToContainerWithTarWriter(fs fs.FileSystem, src, dest, container string, makeTarWriter func(io.Writer) s2itar.Writer) error {
-	destPath := filepath.Dir(dest)
+	// the destination is a path of the Linux container, even on Windows hosts
+	dest = hostpath.ContainerPath(dest)
+	destPath := path.Dir(dest)
 	r, w := io.Pipe()
 	go func() {
 		tarWriter := makeTarWriter(w)
-		tarWriter = s2itar.RenameAdapter{Writer: tarWriter, Old: filepath.Base(src), New: filepath.Base(dest)}
+		tarWriter = s2itar.RenameAdapter{Writer: tarWriter, Old: filepath.Base(src), New: path.Base(dest)}
 
 		err := s2itar.New(fs).CreateTarStreamToTarWriter(src, true, tarWriter, nil)
 		if err == nil {
@@ -407,9 +595,26 @@ func (d *stiDocker) UploadToContainerWithTarWriter(fs fs.FileSystem, src, dest,
 		w.CloseWithError(err)
 	}()
 	log.V(3).Infof("Uploading %q to %q ...", src, destPath)
-	ctx, cancel := getDefaultContext()
+	// the upload of large directories may take longer than the default
+	// timeout, so it is only canceled when it stalls for as long
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	err := d.client.CopyToContainer(ctx, container, destPath, r, dockertypes.CopyToContainerOptions{})
+	defer r.Close()
+	var stalled int32
+	source := newCountingReader(r)
+	stop := reportProgress(func(elapsed time.Duration) {
+		if source.idle() > uploadStallTimeout {
+			atomic.StoreInt32(&stalled, 1)
+			cancel()
+			return
+		}
+		log.Infof("Still uploading %q to %q, %.1f MiB uploaded after %v ...", src, destPath, source.mebibytes(), elapsed.Round(time.Second))
+	})
+	err := d.client.CopyToContainer(ctx, container, destPath, source, dockertypes.CopyToContainerOptions{})
+	stop()
+	if err != nil && atomic.LoadInt32(&stalled) == 1 {
+		err = fmt.Errorf("the upload of %q to %q stalled for %v: %v", src, destPath, uploadStallTimeout, err)
+	}
 	if err != nil {
 		log.V(0).Infof("error: Uploading to container failed: %v", err)
 	}
@@ -455,6 +660,28 @@ func (d *stiDocker) GetImageUser(name string) (string, error) {
 	return user, nil
 }
 
+// minZstdAPIVersion is the first Docker API version whose daemon accepts
+// zstd compressed build contexts (Docker 23.0).
+const minZstdAPIVersion = "1.42"
+
+// negotiateCompression returns the compression to use for a build context
+// given the requested one and the capabilities of the daemon. Daemons which
+// do not support zstd fall back to gzip, which all daemons accept.
+func (d *stiDocker) negotiateCompression(requested api.Compression) api.Compression {
+	switch requested {
+	case api.CompressionGzip:
+		return api.CompressionGzip
+	case api.CompressionZstd, api.CompressionAuto:
+		version, err := d.Version()
+		if err == nil && versions.GreaterThanOrEqualTo(version.APIVersion, minZstdAPIVersion) {
+			return api.CompressionZstd
+		}
+		log.V(2).Infof("The container engine does not support zstd compressed build contexts (API version %q), using gzip", version.APIVersion)
+		return api.CompressionGzip
+	}
+	return api.CompressionNone
+}
+
 // Version returns information of the docker client and server host
 func (d *stiDocker) Version() (dockertypes.Version, error) {
 	ctx, cancel := getDefaultContext()
@@ -537,17 +764,62 @@ func base64EncodeAuth(auth registry.AuthConfig) (string, error) {
 	return base64.URLEncoding.EncodeToString(buf.Bytes()), nil
 }
 
-// PullImage pulls an image into the local registry
+// PullImage pulls an image into the local registry. Offline, the image is
+// loaded from the image store instead.
 func (d *stiDocker) PullImage(name string) (*api.Image, error) {
 	name = getImageName(name)
+	if d.offline != nil {
+		return d.loadImage(name)
+	}
 
 	// RegistryAuth is the base64 encoded credentials for the registry
 	base64Auth, err := base64EncodeAuth(d.pullAuth)
 	if err != nil {
 		return nil, s2ierr.NewPullImageError(name, err)
 	}
-	var retriableError = false
+	progress := pullProgress{}
+
+	release, err := acquirePull(name)
+	if err != nil {
+		return nil, s2ierr.NewPullImageError(name, err)
+	}
+	defer release()
+
+	err = d.pull(name, base64Auth, &progress)
+	if err != nil && isUnauthorized(err) && hasCredentials(d.pullAuth) {
+		// public images can be pulled anonymously by registries rejecting
+		// stale credentials
+		log.V(0).Infof("Pulling image %q with %s failed, retrying anonymously ...", name, d.authSource)
+		anonymousAuth, _ := base64EncodeAuth(registry.AuthConfig{})
+		if anonymousErr := d.pull(name, anonymousAuth, &progress); anonymousErr == nil || !isUnauthorized(anonymousErr) {
+			err = anonymousErr
+		}
+	}
+	if err != nil {
+		if isUnauthorized(err) {
+			return nil, s2ierr.NewRegistryAuthenticationError(name, d.authSource, imageRegistry(name), err)
+		}
+		return nil, s2ierr.NewPullImageError(name, err)
+	}
 
+	d.usage.recordPull(progress.total())
+	d.cache.invalidate()
+	inspectResp, err := d.InspectImage(name)
+	if err != nil {
+		return nil, s2ierr.NewPullImageError(name, err)
+	}
+	if inspectResp != nil {
+		image := &api.Image{}
+		updateImageWithInspect(image, inspectResp)
+		return image, nil
+	}
+	return nil, nil
+}
+
+// pull pulls the image with the given base64 encoded credentials, retrying
+// on the retriable errors.
+func (d *stiDocker) pull(name, base64Auth string, progress *pullProgress) error {
+	var err error
 	for retries := 0; retries <= DefaultPullRetryCount; retries++ {
 		err = util.TimeoutAfter(DefaultDockerTimeout, fmt.Sprintf("pulling image %q", name), func(timer *time.Timer) error {
 			resp, pullErr := d.client.ImagePull(context.Background(), name, image.PullOptions{RegistryAuth: base64Auth})
@@ -578,38 +850,44 @@ func (d *stiDocker) PullImage(name string) (*api.Image, error) {
 				if msg.Progress != nil {
 					log.V(4).Infof("pulling image %s: %s", name, msg.Progress.String())
 				}
+				progress.update(&msg)
 			}
 		})
 		if err == nil {
-			break
+			return nil
 		}
 		log.V(0).Infof("pulling image error : %v", err)
 		errMsg := fmt.Sprintf("%s", err)
+		retriableError := false
 		for _, errorString := range RetriableErrors {
 			if strings.Contains(errMsg, errorString) {
 				retriableError = true
 				break
 			}
 		}
-
 		if !retriableError {
-			return nil, s2ierr.NewPullImageError(name, err)
+			return err
 		}
 
 		log.V(0).Infof("retrying in %s ...", DefaultPullRetryDelay)
 		time.Sleep(DefaultPullRetryDelay)
 	}
+	return err
+}
 
-	inspectResp, err := d.InspectImage(name)
-	if err != nil {
-		return nil, s2ierr.NewPullImageError(name, err)
+// isUnauthorized returns whether err indicates that the registry rejected the
+// credentials.
+func isUnauthorized(err error) bool {
+	if errdefs.IsUnauthorized(err) {
+		return true
 	}
-	if inspectResp != nil {
-		image := &api.Image{}
-		updateImageWithInspect(image, inspectResp)
-		return image, nil
+	errMsg := err.Error()
+	for _, errorString := range UnauthorizedErrors {
+		if strings.Contains(errMsg, errorString) {
+			return true
+		}
 	}
-	return nil, nil
+	return false
 }
 
 func updateImageWithInspect(image *api.Image, inspect *dockertypes.ImageInspect) {
@@ -638,6 +916,113 @@ func (d *stiDocker) RemoveContainer(id string) error {
 	return d.client.ContainerRemove(ctx, id, opts)
 }
 
+// CreateContainer creates a container from the image without starting it, so
+// that its filesystem can be read with DownloadFromContainer. The caller is
+// responsible for removing the container.
+func (d *stiDocker) CreateContainer(image string) (string, error) {
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	config := &dockercontainer.Config{
+		Image: getImageName(image),
+		// The container is never started, but the engine requires a command.
+		Entrypoint: DefaultEntrypoint,
+		Labels:     d.naming.Labels(),
+	}
+	container, err := d.client.ContainerCreate(ctx, config, &dockercontainer.HostConfig{}, nil, nil, d.naming.ContainerName(image))
+	if err != nil {
+		return "", err
+	}
+	return container.ID, nil
+}
+
+// ImageProbe reports whether an image meets the requirements of the builds
+// uploading the scripts and sources into its containers.
+type ImageProbe struct {
+	// MissingRequirements lists the basic requirements (sh or tar) the image
+	// is missing.
+	MissingRequirements []string
+	// Destination is the directory the scripts and sources are uploaded to.
+	Destination string
+	// DestinationWritable is true when the user can write to Destination.
+	DestinationWritable bool
+	// ScriptsURL is the URL of the scripts of the image.
+	ScriptsURL string
+	// HasAssemble is true when the image provides an assemble script at
+	// ScriptsURL.
+	HasAssemble bool
+	// HasBash is true when the image provides bash to the scripts.
+	HasBash bool
+}
+
+// ProbeImage runs a container of the image as the user to check, before the
+// sources are fetched, that it meets the requirements of the builds uploading
+// the scripts and sources into its containers. The destination defaults to the
+// one of the image.
+func (d *stiDocker) ProbeImage(name, user, destination string) (*ImageProbe, error) {
+	image, err := d.CheckImage(name)
+	if err != nil {
+		return nil, err
+	}
+	probe := &ImageProbe{
+		Destination: util.FirstNonEmpty(destination, getDestination(image)),
+		ScriptsURL:  getScriptsURL(image),
+	}
+	checks := []string{
+		"command -v tar >/dev/null 2>&1 || echo missing tar",
+		"command -v bash >/dev/null 2>&1 || echo nobash",
+		fmt.Sprintf("mkdir -p %[1]q 2>/dev/null; [ -d %[1]q ] && [ -w %[1]q ] || echo unwritable", probe.Destination),
+	}
+	scriptsDir := strings.TrimPrefix(probe.ScriptsURL, "image://")
+	if strings.HasPrefix(probe.ScriptsURL, "image://") {
+		checks = append(checks, fmt.Sprintf("[ -f %q ] || echo noassemble", path.Join(scriptsDir, constants.Assemble)))
+	}
+
+	outReader, outWriter := io.Pipe()
+	output := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(outReader)
+		output <- string(data)
+	}()
+	err = d.RunContainer(RunContainerOptions{
+		Image:           name,
+		User:            user,
+		NetworkMode:     string(api.DockerNetworkModeNone),
+		CommandExplicit: []string{"/bin/sh", "-c", strings.Join(checks, "; ")},
+		Stdout:          outWriter,
+	})
+	result := <-output
+	if err != nil {
+		if !isMissingShell(err) {
+			return nil, err
+		}
+		probe.MissingRequirements = []string{"sh"}
+		return probe, nil
+	}
+	probe.DestinationWritable = true
+	probe.HasBash = true
+	probe.HasAssemble = strings.HasPrefix(probe.ScriptsURL, "image://")
+	for _, line := range strings.Split(result, "\n") {
+		switch strings.TrimSpace(line) {
+		case "missing tar":
+			probe.MissingRequirements = append(probe.MissingRequirements, "tar")
+		case "unwritable":
+			probe.DestinationWritable = false
+		case "noassemble":
+			probe.HasAssemble = false
+		case "nobash":
+			probe.HasBash = false
+		}
+	}
+	return probe, nil
+}
+
+// isMissingShell returns whether err indicates that the container could not
+// start /bin/sh, or the env entrypoint running it.
+func isMissingShell(err error) bool {
+	errMsg := err.Error()
+	return strings.Contains(errMsg, "executable file not found") || strings.Contains(errMsg, "no such file or directory")
+}
+
 // KillContainer kills a container.
 func (d *stiDocker) KillContainer(id string) error {
 	ctx, cancel := getDefaultContext()
@@ -645,6 +1030,96 @@ func (d *stiDocker) KillContainer(id string) error {
 	return d.client.ContainerKill(ctx, id, "SIGKILL")
 }
 
+// ExecContainer runs the given command in the given running container,
+// streaming its output to the given writers. A ContainerError is returned if
+// the command exits with a non-zero code.
+func (d *stiDocker) ExecContainer(id string, cmd []string, stdout, stderr io.Writer) error {
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	exec, err := d.client.ContainerExecCreate(ctx, id, dockercontainer.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+	log.V(2).Infof("Running %q in container %q ...", strings.Join(cmd, " "), id)
+	// the command may run longer than the default timeout
+	resp, err := d.client.ContainerExecAttach(context.Background(), exec.ID, dockercontainer.ExecAttachOptions{})
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+	if _, err := dockerstdcopy.StdCopy(stdout, stderr, resp.Reader); err != nil {
+		return err
+	}
+	ctx, cancel = getDefaultContext()
+	defer cancel()
+	inspect, err := d.client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return s2ierr.NewContainerError(id, inspect.ExitCode, "")
+	}
+	return nil
+}
+
+// StopContainer stops a container, giving it the given time to exit after
+// SIGTERM before killing it.
+func (d *stiDocker) StopContainer(id string, timeout time.Duration) error {
+	return d.stopContainer(id, "SIGTERM", timeout)
+}
+
+// stopContainer stops a container, giving it the given time to exit after the
+// signal before killing it.
+func (d *stiDocker) stopContainer(id, signal string, timeout time.Duration) error {
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		waitC, errC := d.client.ContainerWait(ctx, id, dockercontainer.WaitConditionNotRunning)
+		if err := d.client.ContainerKill(ctx, id, signal); err == nil {
+			log.V(2).Infof("Waiting up to %v for container %q to stop ...", timeout, id)
+			select {
+			case <-waitC:
+				return nil
+			case <-errC:
+			}
+		}
+	}
+	return d.KillContainer(id)
+}
+
+// signalName returns the name of a termination signal understood by the
+// engine, which defaults to SIGTERM.
+func signalName(signal os.Signal) string {
+	switch signal {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	}
+	return "SIGTERM"
+}
+
+// dumpStack writes the stacks of all the goroutines to
+// /var/log/s2i_docker_stack_trace.log.
+func dumpStack() {
+	buf := make([]byte, 1<<16)
+	runtime.Stack(buf, true)
+	f, err := os.Create("/var/log/s2i_docker_stack_trace.log")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	w.Write(buf)
+	w.Flush()
+}
+
 // GetLabels retrieves the labels of the given image.
 func (d *stiDocker) GetLabels(name string) (map[string]string, error) {
 	name = getImageName(name)
@@ -935,7 +1410,7 @@ func (d *stiDocker) RunContainer(opts RunContainerOptions) error {
 		}
 	}()
 
-	createOpts := opts.asDockerCreateContainerOptions()
+	createOpts := opts.asDockerCreateContainerOptions(d.naming)
 
 	// get info about the specified image
 	image := createOpts.Config.Image
@@ -987,8 +1462,20 @@ func (d *stiDocker) RunContainer(opts RunContainerOptions) error {
 		createOpts.HostConfig.ShmSize = DefaultShmSize
 	}
 
+	if len(opts.PortBindings) > 0 {
+		exposedPorts, portBindings, err := nat.ParsePortSpecs(opts.PortBindings)
+		if err != nil {
+			return err
+		}
+		createOpts.Config.ExposedPorts = exposedPorts
+		createOpts.HostConfig.PortBindings = portBindings
+		createOpts.HostConfig.PublishAllPorts = false
+	}
+
+	d.createNamedVolumes(opts.Binds)
+
 	// Create a new container.
-	log.V(2).Infof("Creating container with options {Name:%q Config:%+v HostConfig:%+v} ...", createOpts.Name, *util.SafeForLoggingContainerConfig(createOpts.Config), createOpts.HostConfig)
+	log.V(2).Infof("Creating container with options {Name:%q Config:%+v HostConfig:%+v} ...", createOpts.Name, *util.SafeForLoggingContainerConfig(createOpts.Config, opts.RedactEnvPatterns), createOpts.HostConfig)
 	ctx, cancel := getDefaultContext()
 	defer cancel()
 	container, err := d.client.ContainerCreate(ctx, createOpts.Config, createOpts.HostConfig, createOpts.NetworkingConfig, nil, createOpts.Name)
@@ -996,37 +1483,59 @@ func (d *stiDocker) RunContainer(opts RunContainerOptions) error {
 		return err
 	}
 
-	// Container was created, so we defer its removal, and also remove it if we get a SIGINT/SIGTERM/SIGQUIT/SIGHUP.
-	removeContainer := func() {
-		log.V(4).Infof("Removing container %q ...", container.ID)
+	if opts.Detach {
+		log.V(2).Infof("Starting container %q ...", container.ID)
+		ctx, cancel := getDefaultContext()
+		defer cancel()
+		if err := d.client.ContainerStart(ctx, container.ID, dockercontainer.StartOptions{}); err != nil {
+			return err
+		}
+		if opts.TargetImage {
+			dumpContainerInfo(container, d, image)
+		}
+		log.V(0).Infof("Container %s is running in the background", container.ID)
+		return nil
+	}
+
+	// Container was created, so we defer its removal, and also remove it if we
+	// get a SIGINT/SIGTERM/SIGQUIT/SIGHUP, after forwarding the signal to it.
+	var removeOnce sync.Once
+	removeContainer := func(signal string) {
+		removeOnce.Do(func() {
+			log.V(4).Infof("Removing container %q ...", container.ID)
 
-		killErr := d.KillContainer(container.ID)
+			killErr := d.stopContainer(container.ID, signal, opts.StopTimeout)
 
-		if removeErr := d.RemoveContainer(container.ID); removeErr != nil {
-			if killErr != nil {
-				log.V(0).Infof("warning: Failed to kill container %q: %v", container.ID, killErr)
+			if removeErr := d.RemoveContainer(container.ID); removeErr != nil {
+				if killErr != nil {
+					log.V(0).Infof("warning: Failed to kill container %q: %v", container.ID, killErr)
+				}
+				log.V(0).Infof("warning: Failed to remove container %q: %v", container.ID, removeErr)
+			} else {
+				log.V(4).Infof("Removed container %q", container.ID)
 			}
-			log.V(0).Infof("warning: Failed to remove container %q: %v", container.ID, removeErr)
-		} else {
-			log.V(4).Infof("Removed container %q", container.ID)
-		}
+		})
 	}
-	dumpStack := func(signal os.Signal) {
+	defer removeContainer("SIGTERM")
+	unregister := interrupt.Register(func(signal os.Signal) {
 		if signal == syscall.SIGQUIT {
-			buf := make([]byte, 1<<16)
-			runtime.Stack(buf, true)
-			f, err := os.Create("/var/log/s2i_docker_stack_trace.log")
-			if err != nil {
-				return
-			}
-			defer f.Close()
-			w := bufio.NewWriter(f)
-			w.Write(buf)
-			w.Flush()
+			dumpStack()
 		}
-		os.Exit(2)
+		log.V(0).Infof("Received %v, stopping container %q ...", signal, container.ID)
+		removeContainer(signalName(signal))
+	})
+	defer unregister()
+	// the container is stopped at the deadline, which fails the run
+	var exceeded int32
+	if !opts.Deadline.IsZero() {
+		deadline := time.AfterFunc(time.Until(opts.Deadline), func() {
+			atomic.StoreInt32(&exceeded, 1)
+			log.V(0).Infof("The build reached its deadline, stopping container %q ...", container.ID)
+			removeContainer("SIGTERM")
+		})
+		defer deadline.Stop()
 	}
-	return interrupt.New(dumpStack, removeContainer).Run(func() error {
+	err = interrupt.New(interrupt.Terminate).Run(func() error {
 		log.V(2).Infof("Attaching to container %q ...", container.ID)
 		ctx, cancel := getDefaultContext()
 		defer cancel()
@@ -1045,6 +1554,17 @@ func (d *stiDocker) RunContainer(opts RunContainerOptions) error {
 		if err != nil {
 			return err
 		}
+		// the OnExit hook also runs when the container cannot be waited for,
+		// before the container is removed
+		var exitOnce sync.Once
+		onExit := func() {
+			if opts.OnExit != nil {
+				exitOnce.Do(func() { opts.OnExit(container.ID) })
+			}
+		}
+		defer onExit()
+		stopStats := d.collectStats(container.ID)
+		defer stopStats()
 
 		// Run OnStart hook if defined. OnStart might block, so we run it in a
 		// new goroutine, and wait for it to be done later on.
@@ -1073,6 +1593,7 @@ func (d *stiDocker) RunContainer(opts RunContainerOptions) error {
 		waitC, errC := d.client.ContainerWait(context.Background(), container.ID, dockercontainer.WaitConditionNotRunning)
 		select {
 		case result := <-waitC:
+			onExit()
 			if result.StatusCode != 0 {
 				var output string
 				jsonOutput, _ := d.client.ContainerInspect(ctx, container.ID)
@@ -1101,6 +1622,10 @@ func (d *stiDocker) RunContainer(opts RunContainerOptions) error {
 		}
 		return nil
 	})
+	if atomic.LoadInt32(&exceeded) == 1 {
+		return fmt.Errorf("container %q was stopped at the deadline of the build: %w", container.ID, context.DeadlineExceeded)
+	}
+	return err
 }
 
 // GetImageID retrieves the ID of the image identified by name
@@ -1113,7 +1638,9 @@ func (d *stiDocker) GetImageID(name string) (string, error) {
 	return image.ID, nil
 }
 
-// CommitContainer commits a container to an image with a specific tag.
+// CommitContainer commits a container to an image with a specific tag,
+// retrying the commit when it fails and falling back to exporting and
+// importing the container when the options ask for it.
 // The new image ID is returned
 func (d *stiDocker) CommitContainer(opts CommitContainerOptions) (string, error) {
 	dockerOpts := dockercontainer.CommitOptions{
@@ -1128,14 +1655,41 @@ func (d *stiDocker) CommitContainer(opts CommitContainerOptions) (string, error)
 			User:       opts.User,
 		}
 		dockerOpts.Config = &config
-		log.V(2).Infof("Committing container with dockerOpts: %+v, config: %+v", dockerOpts, *util.SafeForLoggingContainerConfig(&config))
+		log.V(2).Infof("Committing container with dockerOpts: %+v, config: %+v", dockerOpts, *util.SafeForLoggingContainerConfig(&config, opts.RedactEnvPatterns))
 	}
 
-	resp, err := d.client.ContainerCommit(context.Background(), opts.ContainerID, dockerOpts)
-	if err == nil {
-		return resp.ID, nil
+	stop := reportCommitProgress(opts.ContainerID)
+	defer stop()
+	backoff := commitBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := d.client.ContainerCommit(context.Background(), opts.ContainerID, dockerOpts)
+		d.cache.invalidate()
+		if err == nil {
+			return resp.ID, nil
+		}
+		if !isTransientCommitError(err) {
+			return "", err
+		}
+		if attempt > opts.Retries {
+			if !opts.ExportFallback {
+				return "", err
+			}
+			log.Warningf("Unable to commit container %s, exporting and importing its file system instead: %v", opts.ContainerID, err)
+			return d.exportImportContainer(opts, dockerOpts.Config)
+		}
+		log.Warningf("Unable to commit container %s, retrying in %v: %v", opts.ContainerID, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	return "", err
+}
+
+// TagImage tags the image with specified name or ID
+func (d *stiDocker) TagImage(name, tag string) error {
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	err := d.client.ImageTag(ctx, name, tag)
+	d.cache.invalidate()
+	return err
 }
 
 // RemoveImage removes the image with specified ID
@@ -1143,34 +1697,112 @@ func (d *stiDocker) RemoveImage(imageID string) error {
 	ctx, cancel := getDefaultContext()
 	defer cancel()
 	_, err := d.client.ImageRemove(ctx, imageID, image.RemoveOptions{})
+	d.cache.invalidate()
 	return err
 }
 
-// BuildImage builds the image according to specified options
-func (d *stiDocker) BuildImage(opts BuildImageOptions) error {
+// BuildImage builds the image according to specified options, and returns the
+// ID of the image built, empty when the engine did not report it.
+func (d *stiDocker) BuildImage(opts BuildImageOptions) (string, error) {
 	dockerOpts := dockertypes.ImageBuildOptions{
-		Tags:           []string{opts.Name},
 		NoCache:        true,
 		SuppressOutput: false,
 		Remove:         true,
 		ForceRemove:    true,
 	}
+	if len(opts.Name) > 0 {
+		dockerOpts.Tags = []string{opts.Name}
+	}
+	if len(opts.BuildArgs) > 0 {
+		dockerOpts.BuildArgs = opts.BuildArgs.AsMap()
+	}
+	if len(opts.Labels) > 0 {
+		dockerOpts.Labels = opts.Labels
+	}
+	if len(opts.NetworkMode) > 0 {
+		dockerOpts.NetworkMode = opts.NetworkMode
+	}
 	if opts.CGroupLimits != nil {
 		dockerOpts.Memory = opts.CGroupLimits.MemoryLimitBytes
 		dockerOpts.MemorySwap = opts.CGroupLimits.MemorySwap
 		dockerOpts.CgroupParent = opts.CGroupLimits.Parent
 	}
-	log.V(2).Infof("Building container using config: %+v", dockerOpts)
-	resp, err := d.client.ImageBuild(context.Background(), opts.Stdin, dockerOpts)
+	// the build arguments are logged apart, with the values matching the
+	// redaction patterns redacted
+	logged := dockerOpts
+	logged.BuildArgs = nil
+	buildArgs := api.BuildArgList(util.RedactEnvironment(api.EnvironmentList(opts.BuildArgs), opts.RedactEnvPatterns))
+	log.V(2).Infof("Building container using config: %+v, build args: %s", logged, buildArgs.String())
+	buildContext := opts.Stdin
+	if compression := d.negotiateCompression(opts.Compression); compression != api.CompressionNone && buildContext != nil {
+		log.V(2).Infof("Compressing build context using %s", compression)
+		compressed := s2itar.NewCompressedReader(buildContext, compression)
+		defer compressed.Close()
+		buildContext = compressed
+	}
+	ctx := context.Background()
+	if !opts.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, opts.Deadline)
+		defer cancel()
+	}
+	resp, err := d.client.ImageBuild(ctx, buildContext, dockerOpts)
+	defer d.cache.invalidate()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("the image build was canceled at the deadline of the build: %w", ctx.Err())
+	}
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 	// since can't pass in output stream to engine-api, need to copy contents of
 	// the output stream they create into our output stream
-	_, err = io.Copy(opts.Stdout, resp.Body)
+	imageID := &imageIDWriter{}
+	var out io.Writer = imageID
+	if opts.Stdout != nil {
+		out = io.MultiWriter(opts.Stdout, imageID)
+	}
+	_, err = io.Copy(out, resp.Body)
 	if opts.Stdout != nil {
 		opts.Stdout.Close()
 	}
-	return err
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("the image build was canceled at the deadline of the build: %w", ctx.Err())
+	}
+	if err != nil {
+		return "", err
+	}
+	imageID.flush()
+	return imageID.id, nil
+}
+
+// imageIDWriter records the ID of the image reported by the aux message of the
+// output of an image build.
+type imageIDWriter struct {
+	line []byte
+	id   string
+}
+
+func (w *imageIDWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b != '\n' {
+			w.line = append(w.line, b)
+			continue
+		}
+		w.flush()
+	}
+	return len(p), nil
+}
+
+// flush parses the line written so far.
+func (w *imageIDWriter) flush() {
+	var msg struct {
+		Aux struct {
+			ID string
+		}
+	}
+	if bytes.Contains(w.line, []byte(`"aux"`)) && json.Unmarshal(w.line, &msg) == nil && len(msg.Aux.ID) > 0 {
+		w.id = msg.Aux.ID
+	}
+	w.line = w.line[:0]
 }
@@ -2,23 +2,35 @@ package docker
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/distribution/reference"
 	dockertypes "github.com/docker/docker/api/types"
 	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/registry"
 	dockerstrslice "github.com/docker/docker/api/types/strslice"
+	"github.com/docker/docker/api/types/volume"
+	dockerstdcopy "github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/opencontainers/go-digest"
 
+	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
 	dockertest "github.com/openshift/source-to-image/pkg/docker/test"
 	"github.com/openshift/source-to-image/pkg/errors"
 	testfs "github.com/openshift/source-to-image/pkg/test/fs"
+	"github.com/openshift/source-to-image/pkg/util"
 )
 
 func TestContainerName(t *testing.T) {
@@ -29,10 +41,109 @@ func TestContainerName(t *testing.T) {
 	}
 }
 
+func TestNamingPolicy(t *testing.T) {
+	policy := NewNamingPolicy(&api.DockerConfig{
+		NamePrefix:     "ci",
+		WorkerID:       "worker-3",
+		ResourceLabels: map[string]string{"team": "web"},
+	})
+	if got := policy.ContainerName("ruby:2.7"); !strings.HasPrefix(got, "ci_worker-3_ruby_2_7_") {
+		t.Errorf("unexpected container name %q", got)
+	}
+	if got := policy.TemporaryImageName("layered-temp-image-abc"); got != "ci-worker-3-layered-temp-image-abc" {
+		t.Errorf("unexpected image name %q", got)
+	}
+	want := map[string]string{
+		"team":                        "web",
+		constants.WorkerIDLabel:       "worker-3",
+		constants.InvocationIDLabel:   InvocationID(),
+		constants.CreatorVersionLabel: "unknown",
+	}
+	if got := policy.Labels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected labels %v, got %v", want, got)
+	}
+	if got := NewNamingPolicy(nil).TemporaryImageName("x"); got != "s2i-x" {
+		t.Errorf("unexpected default image name %q", got)
+	}
+}
+
+func TestNamedVolume(t *testing.T) {
+	for bind, want := range map[string]string{
+		"cache:/opt/cache":      "cache",
+		"m2-repo:/root/.m2:ro":  "m2-repo",
+		"/host/dir:/opt/cache":  "",
+		`C:\cache:C:\opt\cache`: "",
+		"./cache:/opt/cache":    "",
+	} {
+		if got := namedVolume(bind); got != want {
+			t.Errorf("namedVolume(%q) = %q, want %q", bind, got, want)
+		}
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	owned := map[string]string{constants.InvocationIDLabel: "abc"}
+	fakeDocker.Containers["s2i_builder_1"] = dockercontainer.Config{Labels: owned}
+	fakeDocker.Containers["other"] = dockercontainer.Config{Labels: map[string]string{constants.InvocationIDLabel: "def"}}
+	fakeDocker.Images["temp"] = dockertypes.ImageInspect{Config: &dockercontainer.Config{Labels: map[string]string{constants.InvocationIDLabel: "abc", constants.TemporaryLabel: "true"}}}
+	fakeDocker.Images["output"] = dockertypes.ImageInspect{Config: &dockercontainer.Config{Labels: map[string]string{constants.InvocationIDLabel: "abc", constants.TemporaryLabel: "false"}}}
+	fakeDocker.Volumes["cache"] = volume.Volume{Name: "cache", Labels: owned}
+
+	result, err := Cleanup(fakeDocker, "abc")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := &CleanupResult{Containers: []string{"s2i_builder_1"}, Images: []string{"temp"}, Volumes: []string{"cache"}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Expected %+v, got %+v", want, result)
+	}
+	if _, ok := fakeDocker.Containers["other"]; !ok {
+		t.Errorf("The container of another invocation was removed")
+	}
+	if _, ok := fakeDocker.Images["output"]; !ok {
+		t.Errorf("The output image was removed")
+	}
+}
+
+func TestRunContainerCreatesNamedVolumes(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	dh := getDocker(fakeDocker)
+	dh.naming = DefaultNamingPolicy{InvocationID: "abc"}
+	fakeDocker.Images["builder:latest"] = dockertypes.ImageInspect{Config: &dockercontainer.Config{}}
+	fakeDocker.Volumes["existing"] = volume.Volume{Name: "existing"}
+	err := dh.RunContainer(RunContainerOptions{
+		Image:   "builder",
+		Command: "assemble",
+		Binds:   []string{"cache:/opt/cache", "existing:/opt/existing", "/host:/opt/host"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if labels := fakeDocker.Volumes["cache"].Labels; labels[constants.InvocationIDLabel] != "abc" {
+		t.Errorf("Expected the volume cache to be created with the invocation label, got %v", labels)
+	}
+	if labels := fakeDocker.Volumes["existing"].Labels; len(labels) > 0 {
+		t.Errorf("Expected the volume existing to be left alone, got labels %v", labels)
+	}
+}
+
+func TestStopContainerForwardsSignal(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	dh := getDocker(fakeDocker)
+	if err := dh.stopContainer("test", signalName(syscall.SIGINT), time.Minute); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fakeDocker.KillSignals, []string{"SIGINT"}) {
+		t.Errorf("Expected the container to receive SIGINT only, got %v", fakeDocker.KillSignals)
+	}
+}
+
 func getDocker(client Client) *stiDocker {
 	return &stiDocker{
 		client:   client,
 		pullAuth: registry.AuthConfig{},
+		naming:   DefaultNamingPolicy{},
 	}
 }
 
@@ -51,6 +162,31 @@ func TestRemoveContainer(t *testing.T) {
 	}
 }
 
+func TestCreateContainer(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	dh := getDocker(fakeDocker)
+	if _, err := dh.CreateContainer("app"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fakeDocker.Containers) != 1 {
+		t.Fatalf("Expected one container, got %v", fakeDocker.Containers)
+	}
+	var config dockercontainer.Config
+	for name, c := range fakeDocker.Containers {
+		if !strings.HasPrefix(name, "s2i_app_") {
+			t.Errorf("Unexpected container name %q", name)
+		}
+		config = c
+	}
+	if config.Image != "app:latest" || !reflect.DeepEqual([]string(config.Entrypoint), DefaultEntrypoint) {
+		t.Errorf("Unexpected container config %+v", config)
+	}
+	expectedCalls := []string{"create"}
+	if !reflect.DeepEqual(fakeDocker.Calls, expectedCalls) {
+		t.Errorf("Expected fakeDocker.Calls %v, got %v", expectedCalls, fakeDocker.Calls)
+	}
+}
+
 func TestCommitContainer(t *testing.T) {
 	type commitTest struct {
 		containerID     string
@@ -176,6 +312,33 @@ func TestCopyToContainer(t *testing.T) {
 	}
 }
 
+// stalledClient never reads the content copied into containers.
+type stalledClient struct {
+	*dockertest.FakeDockerClient
+}
+
+func (c stalledClient) CopyToContainer(ctx context.Context, container, path string, content io.Reader, opts dockertypes.CopyToContainerOptions) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCopyToContainerStalled(t *testing.T) {
+	defer func(interval, timeout time.Duration) {
+		progressInterval, uploadStallTimeout = interval, timeout
+	}(progressInterval, uploadStallTimeout)
+	progressInterval, uploadStallTimeout = 10*time.Millisecond, 0
+
+	fileName := filepath.Join(t.TempDir(), "secret")
+	if err := ioutil.WriteFile(fileName, []byte("asdf"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dh := getDocker(stalledClient{&dockertest.FakeDockerClient{}})
+	err := dh.UploadToContainer(&testfs.FakeFileSystem{}, fileName, "/run/secrets/secret", "test-container-id")
+	if err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Errorf("expected the stalled upload to be canceled, got %v", err)
+	}
+}
+
 func TestCopyFromContainer(t *testing.T) {
 	type copyFromTest struct {
 		containerID   string
@@ -240,7 +403,7 @@ func TestImageBuild(t *testing.T) {
 			Name: tst.imageID,
 		}
 
-		err := dh.BuildImage(opts)
+		_, err := dh.BuildImage(opts)
 		if err != tst.expectedError {
 			t.Errorf("test case %s: Unexpected error returned: %v", desc, err)
 		}
@@ -250,6 +413,118 @@ func TestImageBuild(t *testing.T) {
 	}
 }
 
+func TestImageBuildImageID(t *testing.T) {
+	tests := map[string]struct {
+		output   string
+		expected string
+	}{
+		"classic builder": {
+			output:   "{\"stream\":\"Step 1/2 : FROM builder\\n\"}\r\n{\"aux\":{\"ID\":\"sha256:1234\"}}\r\n{\"stream\":\"Successfully built 1234\\n\"}\r\n",
+			expected: "sha256:1234",
+		},
+		"containerd": {
+			output:   "#1 DONE 0.1s\n{\"aux\":{\"ID\":\"sha256:5678\"}}",
+			expected: "sha256:5678",
+		},
+		"not reported": {
+			output: "{\"stream\":\"Successfully built 1234\\n\"}\n",
+		},
+	}
+	for desc, tc := range tests {
+		fakeDocker := &dockertest.FakeDockerClient{BuildImageOutput: []byte(tc.output)}
+		dh := getDocker(fakeDocker)
+		out := &bytes.Buffer{}
+		id, err := dh.BuildImage(BuildImageOptions{Stdout: nopWriteCloser{out}})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+			continue
+		}
+		if id != tc.expected {
+			t.Errorf("%s: expected the image ID %q, got %q", desc, tc.expected, id)
+		}
+		if out.String() != tc.output {
+			t.Errorf("%s: expected the output of the build to be copied, got %q", desc, out.String())
+		}
+		if len(fakeDocker.BuildImageOpts.Tags) != 0 {
+			t.Errorf("%s: expected an untagged build, got the tags %v", desc, fakeDocker.BuildImageOpts.Tags)
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestImageBuildArgs(t *testing.T) {
+	fakeDocker := &dockertest.FakeDockerClient{}
+	dh := getDocker(fakeDocker)
+	opts := BuildImageOptions{
+		Name:      "test-image",
+		BuildArgs: api.BuildArgList{{Name: "HTTP_PROXY", Value: "http://proxy:3128"}},
+	}
+	if _, err := dh.BuildImage(opts); err != nil {
+		t.Errorf("Unexpected error returned: %v", err)
+	}
+	value, ok := fakeDocker.BuildImageOpts.BuildArgs["HTTP_PROXY"]
+	if !ok || value == nil || *value != "http://proxy:3128" {
+		t.Errorf("Unexpected build arguments: %+v", fakeDocker.BuildImageOpts.BuildArgs)
+	}
+}
+
+func TestImageBuildCompression(t *testing.T) {
+	tests := map[string]struct {
+		compression api.Compression
+		apiVersion  string
+		magic       []byte
+	}{
+		"none": {
+			compression: api.CompressionNone,
+			apiVersion:  "1.41",
+			magic:       []byte("build"),
+		},
+		"gzip": {
+			compression: api.CompressionGzip,
+			apiVersion:  "1.41",
+			magic:       []byte{0x1f, 0x8b},
+		},
+		"zstd": {
+			compression: api.CompressionZstd,
+			apiVersion:  "1.42",
+			magic:       []byte{0x28, 0xb5, 0x2f, 0xfd},
+		},
+		"zstd unsupported": {
+			compression: api.CompressionZstd,
+			apiVersion:  "1.41",
+			magic:       []byte{0x1f, 0x8b},
+		},
+		"auto": {
+			compression: api.CompressionAuto,
+			apiVersion:  "1.43",
+			magic:       []byte{0x28, 0xb5, 0x2f, 0xfd},
+		},
+	}
+
+	for desc, tst := range tests {
+		fakeDocker := &dockertest.FakeDockerClient{
+			ServerVersionInfo: dockertypes.Version{APIVersion: tst.apiVersion},
+		}
+		dh := getDocker(fakeDocker)
+		opts := BuildImageOptions{
+			Name:        "test-image",
+			Stdin:       strings.NewReader("build context"),
+			Compression: tst.compression,
+		}
+		if _, err := dh.BuildImage(opts); err != nil {
+			t.Errorf("test case %s: Unexpected error returned: %v", desc, err)
+		}
+		if !bytes.HasPrefix(fakeDocker.BuildImageContext, tst.magic) {
+			t.Errorf("test case %s: Unexpected build context: %v", desc, fakeDocker.BuildImageContext)
+		}
+	}
+}
+
 func TestGetScriptsURL(t *testing.T) {
 	type urltest struct {
 		image      dockertypes.ImageInspect
@@ -580,6 +855,129 @@ func TestRunContainer(t *testing.T) {
 	}
 }
 
+func TestRunContainerOnExit(t *testing.T) {
+	for _, waitErr := range []error{nil, fmt.Errorf("the connection was closed")} {
+		fakeDocker := dockertest.NewFakeDockerClient()
+		dh := getDocker(fakeDocker)
+		fakeDocker.Images = map[string]dockertypes.ImageInspect{"test/image:latest": {
+			ID:              "test/image:latest",
+			ContainerConfig: &dockercontainer.Config{},
+			Config:          &dockercontainer.Config{},
+		}}
+		fakeDocker.WaitContainerErr = waitErr
+
+		exited := 0
+		err := dh.RunContainer(RunContainerOptions{
+			Image:           "test/image",
+			ExternalScripts: true,
+			Command:         constants.Assemble,
+			Stdin:           ioutil.NopCloser(strings.NewReader("")),
+			OnExit: func(string) {
+				if util.Includes(fakeDocker.Calls, "remove") {
+					t.Errorf("wait error %v: the container was removed before the hook ran", waitErr)
+				}
+				exited++
+			},
+		})
+		if (waitErr != nil) != (err != nil) {
+			t.Errorf("wait error %v: unexpected error %v", waitErr, err)
+		}
+		if exited != 1 {
+			t.Errorf("wait error %v: expected the hook to run once, ran %d times", waitErr, exited)
+		}
+	}
+}
+
+func TestRunContainerDetach(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	dh := getDocker(fakeDocker)
+	fakeDocker.Images = map[string]dockertypes.ImageInspect{"test/image:latest": {
+		ID:              "test/image:latest",
+		ContainerConfig: &dockercontainer.Config{},
+		Config:          &dockercontainer.Config{},
+	}}
+	fakeDocker.WaitContainerErrInspectJSON = dockertypes.ContainerJSON{NetworkSettings: &dockertypes.NetworkSettings{}}
+
+	err := dh.RunContainer(RunContainerOptions{
+		Image:        "test/image",
+		TargetImage:  true,
+		PortBindings: []string{"8080:8080"},
+		Detach:       true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, call := range []string{"attach", "remove"} {
+		if util.Includes(fakeDocker.Calls, call) {
+			t.Errorf("Unexpected %s of the detached container: %v", call, fakeDocker.Calls)
+		}
+	}
+	if !util.Includes(fakeDocker.Calls, "start") {
+		t.Errorf("The container was not started: %v", fakeDocker.Calls)
+	}
+	for _, container := range fakeDocker.Containers {
+		if _, ok := container.ExposedPorts["8080/tcp"]; !ok {
+			t.Errorf("The published port is not exposed: %v", container.ExposedPorts)
+		}
+	}
+
+	err = dh.RunContainer(RunContainerOptions{
+		Image:        "test/image",
+		TargetImage:  true,
+		PortBindings: []string{"8080:http"},
+	})
+	if err == nil {
+		t.Errorf("Expected an error for an invalid port")
+	}
+}
+
+func TestExecContainer(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	dh := getDocker(fakeDocker)
+	output := &bytes.Buffer{}
+	dockerstdcopy.NewStdWriter(output, dockerstdcopy.Stdout).Write([]byte("reloaded\n"))
+	fakeDocker.ExecOutput = output.Bytes()
+
+	stdout := &bytes.Buffer{}
+	err := dh.ExecContainer("app", []string{"/usr/libexec/s2i/reload"}, stdout, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stdout.String() != "reloaded\n" {
+		t.Errorf("Unexpected output %q", stdout.String())
+	}
+	if !reflect.DeepEqual(fakeDocker.ExecCmd, []string{"/usr/libexec/s2i/reload"}) {
+		t.Errorf("Unexpected command %v", fakeDocker.ExecCmd)
+	}
+	expectedCalls := []string{"exec_create", "exec_attach", "exec_inspect"}
+	if !reflect.DeepEqual(fakeDocker.Calls, expectedCalls) {
+		t.Errorf("Expected fakeDocker.Calls %v, got %v", expectedCalls, fakeDocker.Calls)
+	}
+
+	fakeDocker.ExecOutput = nil
+	fakeDocker.ExecExitCode = 127
+	err = dh.ExecContainer("app", []string{"/usr/libexec/s2i/reload"}, ioutil.Discard, ioutil.Discard)
+	if e, ok := err.(errors.ContainerError); !ok || e.ExitCode != 127 {
+		t.Errorf("Expected a container error with code 127, got %#v", err)
+	}
+}
+
+func TestGetImageExposedPorts(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	dh := getDocker(fakeDocker)
+	fakeDocker.Images = map[string]dockertypes.ImageInspect{"web:latest": {
+		ID:     "web:latest",
+		Config: &dockercontainer.Config{ExposedPorts: nat.PortSet{"8080/tcp": {}, "53/udp": {}}},
+	}}
+	ports, err := dh.GetImageExposedPorts("web")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"53/udp", "8080/tcp"}; !reflect.DeepEqual(ports, expected) {
+		t.Errorf("Expected ports %v, got %v", expected, ports)
+	}
+}
+
 func TestGetImageID(t *testing.T) {
 	fakeDocker := dockertest.NewFakeDockerClient()
 	dh := getDocker(fakeDocker)
@@ -597,6 +995,303 @@ func TestGetImageID(t *testing.T) {
 	}
 }
 
+func TestInspectImageCache(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	dh := New(fakeDocker, api.AuthConfig{})
+	image := dockertypes.ImageInspect{
+		ID:     "test-abcd:latest",
+		Config: &dockercontainer.Config{User: "1001", Labels: map[string]string{"foo": "bar"}},
+	}
+	fakeDocker.Images = map[string]dockertypes.ImageInspect{image.ID: image}
+
+	if _, err := dh.GetImageID("test-abcd"); err != nil {
+		t.Fatalf("Unexpected error returned: %v", err)
+	}
+	if user, err := dh.GetImageUser("test-abcd"); err != nil || user != "1001" {
+		t.Errorf("Unexpected image user %q returned: %v", user, err)
+	}
+	if labels, err := dh.GetLabels("test-abcd"); err != nil || labels["foo"] != "bar" {
+		t.Errorf("Unexpected labels %v returned: %v", labels, err)
+	}
+	// other instances created for the same client share the cache
+	if _, err := New(fakeDocker, api.AuthConfig{}).GetImageID("test-abcd"); err != nil {
+		t.Errorf("Unexpected error returned: %v", err)
+	}
+	expectedCalls := []string{"inspect_image"}
+	if !reflect.DeepEqual(fakeDocker.Calls, expectedCalls) {
+		t.Errorf("Expected fakeDocker.Calls %v, got %v", expectedCalls, fakeDocker.Calls)
+	}
+
+	if err := dh.RemoveImage("test-abcd:latest"); err != nil {
+		t.Fatalf("Unexpected error removing image: %v", err)
+	}
+	if _, err := dh.GetImageID("test-abcd"); err == nil {
+		t.Errorf("Expected the removed image not to be served from the cache")
+	}
+}
+
+func TestInspectCacheEviction(t *testing.T) {
+	first := dockertest.NewFakeDockerClient()
+	cache := getInspectCache(first)
+	if getInspectCache(first) != cache {
+		t.Errorf("Expected the cache of the client to be kept")
+	}
+	for i := 0; i < maxInspectCaches; i++ {
+		getInspectCache(dockertest.NewFakeDockerClient())
+	}
+	if getInspectCache(first) == cache {
+		t.Errorf("Expected the cache of the oldest client to be dropped")
+	}
+	inspectCaches.Lock()
+	defer inspectCaches.Unlock()
+	if len(inspectCaches.caches) > maxInspectCaches || len(inspectCaches.clients) != len(inspectCaches.caches) {
+		t.Errorf("Expected at most %d caches, got %d for %d clients", maxInspectCaches, len(inspectCaches.caches), len(inspectCaches.clients))
+	}
+}
+
+func TestNewClientSettings(t *testing.T) {
+	client, err := NewClient(&api.DockerConfig{Endpoint: "unix:///var/run/s2i-test.sock", NamePrefix: "ci", Offline: true, ImageStore: "/var/lib/s2i/images"})
+	if err != nil {
+		t.Fatalf("Unexpected error creating the client: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		d := New(client, api.AuthConfig{}).(*stiDocker)
+		if name := d.naming.TemporaryImageName("image"); name != "ci-image" {
+			t.Errorf("Expected the naming policy of the configuration, got the temporary image name %q", name)
+		}
+		if d.offline == nil || d.offline.imageStore != "/var/lib/s2i/images" {
+			t.Errorf("Expected the offline mode of the configuration, got %#v", d.offline)
+		}
+	}
+	d := New(dockertest.NewFakeDockerClient(), api.AuthConfig{}).(*stiDocker)
+	if name := d.naming.TemporaryImageName("image"); name != "s2i-image" {
+		t.Errorf("Expected the default naming policy, got the temporary image name %q", name)
+	}
+	if d.offline != nil {
+		t.Errorf("Expected the client to be online, got %#v", d.offline)
+	}
+}
+
+func TestGetResourceUsage(t *testing.T) {
+	fakeDocker := dockertest.NewFakeDockerClient()
+	client := &engineClient{Client: fakeDocker, usage: &usageRecorder{}}
+	dh := New(client, api.AuthConfig{}).(*stiDocker)
+	fakeDocker.PullOutput = []byte(`{"status":"Pulling fs layer","id":"a"}
+{"status":"Downloading","id":"a","progressDetail":{"current":10,"total":100}}
+{"status":"Downloading","id":"a","progressDetail":{"current":100,"total":100}}
+{"status":"Downloading","id":"b","progressDetail":{"current":5,"total":50}}
+{"status":"Pull complete","id":"a"}
+`)
+	fakeDocker.Images = map[string]dockertypes.ImageInspect{
+		"builder:latest": {ID: "builder"},
+		"app:latest":     {ID: "app", Size: 300},
+	}
+	fakeDocker.History = map[string][]image.HistoryResponseItem{
+		"app": {{CreatedBy: "assemble", Size: 100}, {CreatedBy: "base", Size: 200}},
+	}
+	if _, err := dh.PullImage("builder"); err != nil {
+		t.Fatalf("Unexpected error pulling image: %v", err)
+	}
+	for _, memory := range []uint64{2048, 1024} {
+		stats := dockercontainer.StatsResponse{}
+		stats.MemoryStats.Usage = memory
+		stats.CPUStats.CPUUsage.TotalUsage = 1000 + memory
+		fakeDocker.Stats = append(fakeDocker.Stats, stats)
+	}
+	dh.collectStats("assemble")()
+	dh.collectStats("save-artifacts")()
+
+	// the resources are accounted for all the Docker instances of the client
+	usage, err := New(client, api.AuthConfig{}).GetResourceUsage("app:latest")
+	if err != nil {
+		t.Fatalf("Unexpected error returned: %v", err)
+	}
+	expected := api.ResourceUsage{
+		PeakMemoryBytes:    2048,
+		CPUTimeNanoseconds: 2 * 3048,
+		PulledBytes:        150,
+		ImageSizeBytes:     300,
+		Layers:             []api.LayerUsage{{CreatedBy: "assemble", SizeBytes: 100}, {CreatedBy: "base", SizeBytes: 200}},
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Errorf("Expected resource usage %+v, got %+v", expected, usage)
+	}
+}
+
+func TestPullImageErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		pullErr  error
+		pullMsg  string
+		expected int
+	}{
+		{
+			name:     "not found",
+			pullErr:  fmt.Errorf("manifest for builder:latest not found"),
+			expected: errors.PullImageError,
+		},
+		{
+			name:     "unauthorized",
+			pullErr:  fmt.Errorf("Error response from daemon: pull access denied for builder, repository does not exist or may require 'docker login'"),
+			expected: errors.AuthenticationError,
+		},
+		{
+			name:     "unauthorized in the pull progress",
+			pullMsg:  `{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}`,
+			expected: errors.AuthenticationError,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeDocker := dockertest.NewFakeDockerClient()
+			fakeDocker.PullFail = tc.pullErr
+			fakeDocker.PullOutput = []byte(tc.pullMsg)
+			_, err := New(fakeDocker, api.AuthConfig{}).PullImage("builder")
+			if e, ok := err.(errors.Error); !ok || e.ErrorCode != tc.expected {
+				t.Errorf("Expected an error with code %d, got %#v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestPullImageAnonymousFallback(t *testing.T) {
+	unauthorized := fmt.Errorf("unauthorized: authentication required")
+	auth := api.AuthConfig{Username: "user", Password: "expired", Source: "/home/user/.docker/config.json", Key: "quay.io"}
+	tests := []struct {
+		name     string
+		auth     api.AuthConfig
+		fails    []error
+		pulls    int
+		expected string
+	}{
+		{
+			name:  "public image",
+			auth:  auth,
+			fails: []error{unauthorized, nil},
+			pulls: 2,
+		},
+		{
+			name:     "private image",
+			auth:     auth,
+			fails:    []error{unauthorized, unauthorized},
+			pulls:    2,
+			expected: `authentication failed with the credentials of user for "quay.io" in /home/user/.docker/config.json`,
+		},
+		{
+			name:     "no credentials",
+			fails:    []error{unauthorized},
+			pulls:    1,
+			expected: "authentication required, no credentials were found for quay.io",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeDocker := dockertest.NewFakeDockerClient()
+			fakeDocker.PullFails = tc.fails
+			fakeDocker.Images["quay.io/team/builder:latest"] = dockertypes.ImageInspect{ID: "builder"}
+			_, err := New(fakeDocker, tc.auth).PullImage("quay.io/team/builder")
+			if len(tc.expected) == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tc.expected) > 0 {
+				e, ok := err.(errors.Error)
+				if !ok || e.ErrorCode != errors.AuthenticationError || !strings.Contains(e.Message, tc.expected) {
+					t.Errorf("expected an authentication error containing %q, got %#v", tc.expected, err)
+				}
+			}
+			if len(fakeDocker.PullAuths) != tc.pulls {
+				t.Fatalf("expected %d pulls, got %d", tc.pulls, len(fakeDocker.PullAuths))
+			}
+			if tc.pulls > 1 && fakeDocker.PullAuths[1] == fakeDocker.PullAuths[0] {
+				t.Errorf("expected the image to be pulled anonymously after the credentials were rejected")
+			}
+		})
+	}
+}
+
+func TestCheckAndPullChangedImage(t *testing.T) {
+	pinned := "builder@sha256:4d6a5f1b7e0c3a2d9b8e7f6a5c4d3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c"
+	local := "sha256:4d6a5f1b7e0c3a2d9b8e7f6a5c4d3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c"
+	changed := "sha256:0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9"
+	tests := []struct {
+		name     string
+		images   []string
+		image    string
+		digest   string
+		expected []string
+	}{
+		{
+			name:     "unchanged",
+			images:   []string{"builder:latest"},
+			image:    "builder",
+			digest:   local,
+			expected: []string{"inspect_image", "distribution_inspect"},
+		},
+		{
+			name:     "changed",
+			images:   []string{"builder:latest"},
+			image:    "builder",
+			digest:   changed,
+			expected: []string{"inspect_image", "distribution_inspect", "pull", "inspect_image"},
+		},
+		{
+			name:     "not resolved",
+			images:   []string{"builder:latest"},
+			image:    "builder",
+			expected: []string{"inspect_image", "distribution_inspect"},
+		},
+		{
+			name:     "not present",
+			image:    "builder",
+			expected: []string{"inspect_image", "pull", "inspect_image"},
+		},
+		{
+			name:     "pinned by digest",
+			images:   []string{pinned},
+			image:    pinned,
+			expected: []string{"inspect_image"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeDocker := dockertest.NewFakeDockerClient()
+			for _, name := range tc.images {
+				fakeDocker.Images[name] = dockertypes.ImageInspect{ID: name, RepoDigests: []string{"builder@" + local}}
+			}
+			if len(tc.digest) > 0 {
+				fakeDocker.Digests = map[string]digest.Digest{"builder:latest": digest.Digest(tc.digest)}
+			}
+			New(fakeDocker, api.AuthConfig{}).CheckAndPullChangedImage(tc.image)
+			if !reflect.DeepEqual(fakeDocker.Calls, tc.expected) {
+				t.Errorf("Expected fakeDocker.Calls %v, got %v", tc.expected, fakeDocker.Calls)
+			}
+		})
+	}
+}
+
+func TestHasRepoDigest(t *testing.T) {
+	digest := "sha256:4d6a5f1b7e0c3a2d9b8e7f6a5c4d3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c"
+	named, err := reference.ParseNormalizedNamed("centos/ruby-25-centos7:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		repoDigests []string
+		expected    bool
+	}{
+		{repoDigests: []string{"centos/ruby-25-centos7@" + digest}, expected: true},
+		{repoDigests: []string{"other@" + digest, "docker.io/centos/ruby-25-centos7@" + digest}, expected: true},
+		{repoDigests: []string{"other@" + digest}, expected: false},
+		{repoDigests: []string{"centos/ruby-25-centos7@sha256:0e3f5d1b7e0c3a2d9b8e7f6a5c4d3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c"}, expected: false},
+		{expected: false},
+	}
+	for _, tc := range tests {
+		if actual := hasRepoDigest(tc.repoDigests, named, digest); actual != tc.expected {
+			t.Errorf("Expected %v for %v, got %v", tc.expected, tc.repoDigests, actual)
+		}
+	}
+}
+
 func TestRemoveImage(t *testing.T) {
 	fakeDocker := dockertest.NewFakeDockerClient()
 	dh := getDocker(fakeDocker)
@@ -628,3 +1323,48 @@ func TestGetImageName(t *testing.T) {
 		}
 	}
 }
+
+func TestAcquirePull(t *testing.T) {
+	SetMaxConcurrentPulls(1, 0)
+	defer SetMaxConcurrentPulls(0, 0)
+
+	release, err := acquirePull("quay.io/app/builder")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// another registry is not limited by the pulls from quay.io
+	releaseOther, err := acquirePull("docker.io/library/ruby")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	releaseOther()
+
+	acquired := make(chan struct{})
+	go func() {
+		if release, err := acquirePull("quay.io/app/runtime"); err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second pull from quay.io to wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	<-acquired
+}
+
+func TestAcquirePullTimeout(t *testing.T) {
+	SetMaxConcurrentPulls(1, 10*time.Millisecond)
+	defer SetMaxConcurrentPulls(0, 0)
+
+	release, err := acquirePull("quay.io/app/builder")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer release()
+	if _, err := acquirePull("quay.io/app/runtime"); err == nil || !strings.Contains(err.Error(), "quay.io") {
+		t.Errorf("Expected the pull to time out waiting for quay.io, got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	imagedocker "github.com/containers/image/v5/docker"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/versions"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+// CheckStatus is the outcome of a diagnostic check.
+type CheckStatus string
+
+const (
+	// CheckPass is the status of a check which found no problem.
+	CheckPass CheckStatus = "pass"
+	// CheckWarn is the status of a check which found a problem builds may run
+	// into.
+	CheckWarn CheckStatus = "warn"
+	// CheckFail is the status of a check which found a problem preventing
+	// the builds.
+	CheckFail CheckStatus = "fail"
+	// CheckSkip is the status of a check which could not be performed.
+	CheckSkip CheckStatus = "skip"
+)
+
+// Check is the result of a diagnostic check of the container engine, with a
+// hint at how to remedy the problem it found.
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+	Hint    string      `json:"hint,omitempty"`
+}
+
+const (
+	// minAPIVersion is the oldest Docker API version s2i builds with.
+	minAPIVersion = "1.24"
+
+	// minDoctorDiskSpace is the space available to the images of the engine
+	// below which the diagnostics warn.
+	minDoctorDiskSpace = 5 * 1024 * 1024 * 1024
+
+	// socketDialTimeout is the time a connection to the socket of the engine
+	// is given to be established.
+	socketDialTimeout = 5 * time.Second
+)
+
+// Diagnose checks that the container engine of the given configuration can
+// run builds: its socket is accessible, its API version is supported, it has
+// disk space for the images, and how user namespaces and cgroups are set up.
+// The registries of the given images are checked to be reachable, with the
+// credentials auths gives them. The checks of the engine are skipped once it
+// cannot be reached.
+func Diagnose(config *api.DockerConfig, images []string, auths *AuthConfigurations) []Check {
+	checks := []Check{checkSocket(config)}
+	client, err := NewClient(config)
+	if err != nil {
+		checks = append(checks, Check{
+			Name:    "engine",
+			Status:  CheckFail,
+			Message: fmt.Sprintf("unable to create a client of the container engine: %v", err),
+			Hint:    "check the --url and TLS options, or the DOCKER_HOST and DOCKER_CERT_PATH environment variables",
+		})
+	} else {
+		_, local := socketPath(config)
+		checks = append(checks, DiagnoseEngine(client, local)...)
+	}
+	for _, image := range images {
+		checks = append(checks, checkRegistry(config, image, GetImageRegistryAuth(auths, image)))
+	}
+	return checks
+}
+
+// DiagnoseEngine checks the API version of the container engine of the given
+// client, the disk space available to its images, and its user namespace and
+// cgroup setup. The disk space is only checked when the engine is local, that
+// is reached through a unix socket of this host, as the directory it reports
+// is otherwise on another host.
+func DiagnoseEngine(client Client, local bool) []Check {
+	report, check := checkAPIVersion(client)
+	if check.Status == CheckFail {
+		return []Check{check}
+	}
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	info, err := client.Info(ctx)
+	if err != nil {
+		return []Check{check, {
+			Name:    "info",
+			Status:  CheckFail,
+			Message: fmt.Sprintf("unable to query %s for its settings: %v", report.Name, err),
+		}}
+	}
+	disk := Check{Name: "disk space", Status: CheckSkip, Message: "the container engine is not reached through a unix socket of this host"}
+	if local {
+		disk = checkDiskSpace(info)
+	}
+	return []Check{check, disk, checkUserNamespaces(info), checkCgroups(info)}
+}
+
+// socketPath returns the path of the unix socket of the engine, and false when
+// the engine is reached over TCP or SSH.
+func socketPath(config *api.DockerConfig) (string, bool) {
+	endpoint := config.Endpoint
+	if config.Engine == api.EngineContainerd {
+		endpoint = "unix://" + config.ContainerdAddress
+	}
+	path := strings.TrimPrefix(endpoint, "unix://")
+	return path, path != endpoint
+}
+
+// checkSocket checks that the unix socket of the engine exists and that the
+// user is allowed to connect to it. The engines reached over TCP or SSH are
+// not checked.
+func checkSocket(config *api.DockerConfig) Check {
+	check := Check{Name: "socket"}
+	path, ok := socketPath(config)
+	if !ok {
+		check.Status, check.Message = CheckSkip, fmt.Sprintf("the endpoint %s is not a unix socket", path)
+		return check
+	}
+	if _, err := os.Stat(path); err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("the socket %s does not exist", path)
+		check.Hint = "start the container engine, or set DOCKER_HOST or --url to its socket, e.g. unix://$XDG_RUNTIME_DIR/docker.sock for rootless Docker"
+		return check
+	}
+	conn, err := net.DialTimeout("unix", path, socketDialTimeout)
+	if err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("unable to connect to %s: %v", path, err)
+		if errors.Is(err, os.ErrPermission) {
+			check.Hint = "add the user to the group owning the socket, e.g. sudo usermod -aG docker $USER, and log in again, or use a rootless engine"
+		} else {
+			check.Hint = "check that the container engine is running, e.g. systemctl status docker"
+		}
+		return check
+	}
+	conn.Close()
+	check.Status, check.Message = CheckPass, fmt.Sprintf("%s is accessible", path)
+	return check
+}
+
+// checkAPIVersion checks that the engine can be reached and serves a Docker
+// API version s2i builds with, warning about the features missing from the
+// older versions.
+func checkAPIVersion(client Client) (*EngineReport, Check) {
+	check := Check{Name: "api version"}
+	report, err := GetEngineReport(client)
+	if err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("unable to reach the container engine: %v", err)
+		check.Hint = "check that the container engine is running and that its endpoint is correct"
+		return nil, check
+	}
+	engine := fmt.Sprintf("%s %s", report.Name, report.Version)
+	switch {
+	case len(report.APIVersion) == 0:
+		check.Status, check.Message = CheckPass, fmt.Sprintf("%s does not serve the Docker engine API", engine)
+	case versions.LessThan(report.APIVersion, minAPIVersion):
+		check.Status, check.Message = CheckFail, fmt.Sprintf("%s serves API version %s, older than %s", engine, report.APIVersion, minAPIVersion)
+		check.Hint = "upgrade the container engine"
+	case !report.Features.MultiArch:
+		check.Status, check.Message = CheckWarn, fmt.Sprintf("%s serves API version %s, without multi-arch builds and zstd build contexts", engine, report.APIVersion)
+		check.Hint = "upgrade to Docker 23.0 or later"
+	case !report.Features.ZstdBuildContext:
+		check.Status, check.Message = CheckWarn, fmt.Sprintf("%s serves API version %s, without zstd build contexts", engine, report.APIVersion)
+		check.Hint = "upgrade to Docker 23.0 or later"
+	default:
+		check.Status, check.Message = CheckPass, fmt.Sprintf("%s serves API version %s", engine, report.APIVersion)
+	}
+	return report, check
+}
+
+// checkDiskSpace checks the space available on the file system the engine
+// stores its images in, which must be on this host.
+func checkDiskSpace(info system.Info) Check {
+	check := Check{Name: "disk space"}
+	root := info.DockerRootDir
+	if len(root) == 0 {
+		check.Status, check.Message = CheckSkip, "the container engine does not tell where it stores its images"
+		return check
+	}
+	space, err := fs.GetDiskSpace(root)
+	if err != nil {
+		check.Status, check.Message = CheckSkip, fmt.Sprintf("unable to determine the space available in %s, which may be on another host: %v", root, err)
+		return check
+	}
+	available := fmt.Sprintf("%.1f GiB available in %s", float64(space.Bytes)/(1<<30), root)
+	if space.Bytes < minDoctorDiskSpace {
+		check.Status, check.Message = CheckWarn, available
+		check.Hint = "remove the unused images and containers with s2i cleanup or docker system prune"
+		return check
+	}
+	check.Status, check.Message = CheckPass, available
+	return check
+}
+
+// checkUserNamespaces reports whether the engine remaps the users of the
+// containers, which changes the owners of the files mounted into them.
+func checkUserNamespaces(info system.Info) Check {
+	check := Check{Name: "user namespaces", Status: CheckPass, Message: "the users of the containers are not remapped"}
+	options, err := system.DecodeSecurityOptions(info.SecurityOptions)
+	if err != nil {
+		check.Status, check.Message = CheckSkip, fmt.Sprintf("unable to decode the security options of the engine: %v", err)
+		return check
+	}
+	for _, option := range options {
+		switch option.Name {
+		case "rootless":
+			check.Message = "the container engine runs rootless, in the user namespace of its user"
+		case "userns":
+			check.Status, check.Message = CheckWarn, "the users of the containers are remapped to subordinate IDs"
+			check.Hint = "make the directories given to --volume and --inject readable by all users, as the remapped users do not own them"
+		}
+	}
+	return check
+}
+
+// checkCgroups reports the cgroup version of the host of the engine, as the
+// peak memory of the builds is measured differently on cgroup v1.
+func checkCgroups(info system.Info) Check {
+	check := Check{Name: "cgroups"}
+	switch info.CgroupVersion {
+	case "":
+		check.Status, check.Message = CheckSkip, "the container engine does not tell its cgroup version"
+	case "1":
+		check.Status, check.Message = CheckWarn, fmt.Sprintf("cgroup v1 with the %s driver", info.CgroupDriver)
+		check.Hint = "cgroup v1 is deprecated by the container engines; boot the host with systemd.unified_cgroup_hierarchy=1 to use cgroup v2"
+	default:
+		check.Status, check.Message = CheckPass, fmt.Sprintf("cgroup v%s with the %s driver", info.CgroupVersion, info.CgroupDriver)
+	}
+	return check
+}
+
+// checkRegistry checks that the manifest of the image can be read from its
+// registry with the given credentials.
+func checkRegistry(config *api.DockerConfig, image string, auth api.AuthConfig) Check {
+	check := Check{Name: "registry " + image}
+	if config.Offline {
+		check.Status, check.Message = CheckSkip, "the registries are not reached offline"
+		return check
+	}
+	name := getImageName(image)
+	ref, err := imagedocker.ParseReference("//" + name)
+	if err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("invalid image name: %v", err)
+		return check
+	}
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	d := &stiDocker{pullAuth: registry.AuthConfig{Username: auth.Username, Password: auth.Password, IdentityToken: auth.IdentityToken}}
+	digest, err := imagedocker.GetDigest(ctx, d.registrySystemContext(), ref)
+	if err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("unable to read the manifest of %s: %v", name, err)
+		if isUnauthorized(err) {
+			check.Hint = "log in to the registry with docker login, or check that the image exists"
+		} else {
+			check.Hint = "check the network and the HTTPS_PROXY and NO_PROXY environment variables"
+		}
+		return check
+	}
+	check.Status, check.Message = CheckPass, fmt.Sprintf("%s is reachable (%s)", name, digest)
+	return check
+}
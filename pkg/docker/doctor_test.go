@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/system"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	dockertest "github.com/openshift/source-to-image/pkg/docker/test"
+)
+
+func TestDiagnoseEngine(t *testing.T) {
+	tests := map[string]struct {
+		version dockertypes.Version
+		info    system.Info
+		remote  bool
+		status  map[string]CheckStatus
+	}{
+		"docker 24.0 on cgroup v2": {
+			version: dockertypes.Version{Version: "24.0.7", APIVersion: "1.43"},
+			info:    system.Info{DockerRootDir: t.TempDir(), CgroupVersion: "2", CgroupDriver: "systemd", SecurityOptions: []string{"name=seccomp,profile=builtin"}},
+			status: map[string]CheckStatus{
+				"api version":     CheckPass,
+				"user namespaces": CheckPass,
+				"cgroups":         CheckPass,
+			},
+		},
+		"docker 19.03 with user namespaces on cgroup v1": {
+			version: dockertypes.Version{Version: "19.03.15", APIVersion: "1.40"},
+			info:    system.Info{CgroupVersion: "1", CgroupDriver: "cgroupfs", SecurityOptions: []string{"name=userns"}},
+			status: map[string]CheckStatus{
+				"api version":     CheckWarn,
+				"disk space":      CheckSkip,
+				"user namespaces": CheckWarn,
+				"cgroups":         CheckWarn,
+			},
+		},
+		"docker 20.10": {
+			version: dockertypes.Version{Version: "20.10.24", APIVersion: "1.41"},
+			info:    system.Info{DockerRootDir: t.TempDir(), CgroupVersion: "2", CgroupDriver: "systemd"},
+			status: map[string]CheckStatus{
+				"api version": CheckWarn,
+			},
+		},
+		"remote docker 24.0": {
+			version: dockertypes.Version{Version: "24.0.7", APIVersion: "1.43"},
+			info:    system.Info{DockerRootDir: t.TempDir(), CgroupVersion: "2", CgroupDriver: "systemd"},
+			remote:  true,
+			status: map[string]CheckStatus{
+				"api version": CheckPass,
+				"disk space":  CheckSkip,
+			},
+		},
+		"unsupported api version": {
+			version: dockertypes.Version{Version: "1.11.2", APIVersion: "1.23"},
+			status: map[string]CheckStatus{
+				"api version": CheckFail,
+			},
+		},
+	}
+	for desc, tc := range tests {
+		fakeDocker := dockertest.NewFakeDockerClient()
+		fakeDocker.ServerVersionInfo = tc.version
+		fakeDocker.SystemInfo = tc.info
+		checks := map[string]Check{}
+		for _, check := range DiagnoseEngine(fakeDocker, !tc.remote) {
+			checks[check.Name] = check
+		}
+		for name, status := range tc.status {
+			if check, ok := checks[name]; !ok || check.Status != status {
+				t.Errorf("%s: expected the %s check to %s, got %+v", desc, name, status, check)
+			}
+		}
+		if tc.status["api version"] == CheckFail && len(checks) != 1 {
+			t.Errorf("%s: expected the other checks to be skipped, got %+v", desc, checks)
+		}
+	}
+}
+
+func TestCheckSocket(t *testing.T) {
+	check := checkSocket(&api.DockerConfig{Endpoint: "tcp://127.0.0.1:2376"})
+	if check.Status != CheckSkip {
+		t.Errorf("expected the check of a TCP endpoint to be skipped, got %+v", check)
+	}
+	check = checkSocket(&api.DockerConfig{Endpoint: "unix://" + t.TempDir() + "/docker.sock"})
+	if check.Status != CheckFail || len(check.Hint) == 0 {
+		t.Errorf("expected the check of a missing socket to fail with a hint, got %+v", check)
+	}
+}
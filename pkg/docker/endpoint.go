@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/client"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+// defaultContextName is the name of the implicit Docker context, which uses
+// the DOCKER_HOST environment variable or the default Docker socket.
+const defaultContextName = "default"
+
+// contextMetadata is the subset of the metadata of a Docker context, stored by
+// the docker CLI in contexts/meta/<sha256 of the name>/meta.json of its
+// configuration directory.
+type contextMetadata struct {
+	Name      string
+	Endpoints map[string]contextEndpoint
+}
+
+// contextEndpoint is the docker endpoint of a Docker context.
+type contextEndpoint struct {
+	Host          string
+	SkipTLSVerify bool
+
+	// tlsDir is the directory holding the TLS material of the endpoint, if
+	// any. It is not part of the metadata.
+	tlsDir string
+}
+
+// contextDir returns the name of the directories holding the metadata and the
+// TLS material of the Docker context with the given name.
+func contextDir(name string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+}
+
+// contextMetadataPath returns the path of the metadata file of the Docker
+// context with the given name.
+func contextMetadataPath(name string) string {
+	return filepath.Join(Dir(), "contexts", "meta", contextDir(name), "meta.json")
+}
+
+// contextTLSDir returns the directory holding the TLS material of the docker
+// endpoint of the Docker context with the given name.
+func contextTLSDir(name string) string {
+	return filepath.Join(Dir(), "contexts", "tls", contextDir(name), "docker")
+}
+
+// loadContextEndpoint returns the docker endpoint of the Docker context with
+// the given name.
+func loadContextEndpoint(name string) (*contextEndpoint, error) {
+	if name == defaultContextName {
+		host := os.Getenv("DOCKER_HOST")
+		if len(host) == 0 {
+			host = client.DefaultDockerHost
+		}
+		return &contextEndpoint{Host: host}, nil
+	}
+	data, err := ioutil.ReadFile(contextMetadataPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("docker context %q does not exist", name)
+		}
+		return nil, err
+	}
+	meta := contextMetadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("unable to parse docker context %q: %v", name, err)
+	}
+	endpoint, ok := meta.Endpoints["docker"]
+	if !ok || len(endpoint.Host) == 0 {
+		return nil, fmt.Errorf("docker context %q has no docker endpoint", name)
+	}
+	if info, err := os.Stat(contextTLSDir(name)); err == nil && info.IsDir() {
+		endpoint.tlsDir = contextTLSDir(name)
+	}
+	return &endpoint, nil
+}
+
+// currentContext returns the name of the Docker context selected by the
+// DOCKER_CONTEXT environment variable or, when unset, by the "docker context
+// use" command, and a description of where it was found.
+func currentContext() (string, string) {
+	if name := os.Getenv("DOCKER_CONTEXT"); len(name) > 0 {
+		return name, "DOCKER_CONTEXT environment variable"
+	}
+	data, err := ioutil.ReadFile(filepath.Join(Dir(), "config.json"))
+	if err != nil {
+		return "", ""
+	}
+	config := struct {
+		CurrentContext string `json:"currentContext"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", ""
+	}
+	return config.CurrentContext, "docker configuration file"
+}
+
+// UseDockerContext configures the given Docker configuration to connect to the
+// docker endpoint of the Docker context with the given name, the same way the
+// docker CLI does.
+func UseDockerContext(config *api.DockerConfig, name string) error {
+	endpoint, err := loadContextEndpoint(name)
+	if err != nil {
+		return err
+	}
+	applyContextEndpoint(config, endpoint)
+	return nil
+}
+
+// applyContextEndpoint sets the endpoint and the TLS configuration of the
+// given context endpoint to the Docker configuration.
+func applyContextEndpoint(config *api.DockerConfig, endpoint *contextEndpoint) {
+	config.Endpoint = endpoint.Host
+	if len(endpoint.tlsDir) == 0 {
+		return
+	}
+	config.CAFile = filepath.Join(endpoint.tlsDir, "ca.pem")
+	config.CertFile = filepath.Join(endpoint.tlsDir, "cert.pem")
+	config.KeyFile = filepath.Join(endpoint.tlsDir, "key.pem")
+	config.UseTLS = true
+	config.TLSVerify = !endpoint.SkipTLSVerify
+}
+
+// isSocket returns true if the given path is a unix socket. Sockets are only
+// looked for, and not connected to, so that socket activated daemons are not
+// started by the detection.
+func isSocket(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// defaultEndpoint returns the endpoint of the container engine used when none
+// is given on the command line, along with a description of how it was
+// selected. In order of precedence, it is:
+//
+//  1. the DOCKER_HOST environment variable,
+//  2. the docker endpoint of the current Docker context, named by the
+//     DOCKER_CONTEXT environment variable or the docker configuration file,
+//  3. the rootless Docker socket, $XDG_RUNTIME_DIR/docker.sock,
+//  4. the rootless Podman socket, $XDG_RUNTIME_DIR/podman/podman.sock,
+//  5. the default Docker socket.
+//
+// The endpoint of a Docker context is returned along with its TLS material.
+func defaultEndpoint() (*contextEndpoint, string) {
+	if host := os.Getenv("DOCKER_HOST"); len(host) > 0 {
+		return &contextEndpoint{Host: host}, "DOCKER_HOST environment variable"
+	}
+	if name, from := currentContext(); len(name) > 0 && name != defaultContextName {
+		endpoint, err := loadContextEndpoint(name)
+		if err == nil {
+			return endpoint, fmt.Sprintf("docker context %q", name)
+		}
+		log.Warningf("Ignoring the Docker context set in the %s: %v", from, err)
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); len(runtimeDir) > 0 {
+		if path := filepath.Join(runtimeDir, "docker.sock"); isSocket(path) {
+			return &contextEndpoint{Host: "unix://" + path}, "rootless Docker socket"
+		}
+		if path := filepath.Join(runtimeDir, "podman", "podman.sock"); isSocket(path) {
+			return &contextEndpoint{Host: "unix://" + path}, "rootless Podman socket"
+		}
+	}
+	return &contextEndpoint{Host: client.DefaultDockerHost}, "default Docker socket"
+}
@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/versions"
+
+	"github.com/openshift/source-to-image/pkg/containerd"
+)
+
+const (
+	// minBuildKitAPIVersion is the first Docker API version whose daemon
+	// builds images with BuildKit (Docker 18.09).
+	minBuildKitAPIVersion = "1.39"
+
+	// minMultiArchAPIVersion is the first Docker API version whose daemon
+	// creates the containers of the platform requested (Docker 20.10).
+	minMultiArchAPIVersion = "1.41"
+)
+
+// EngineReport describes the container engine the builds run with, for bug
+// reports.
+type EngineReport struct {
+	// Name is the name of the engine, as reported by its platform.
+	Name string `json:"name"`
+	// Endpoint is the address the engine was reached at.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Version is the version of the engine.
+	Version string `json:"version"`
+	// APIVersion is the version of the Docker engine API of the engine, empty
+	// for containerd.
+	APIVersion string `json:"apiVersion,omitempty"`
+	// OS and Arch are the platform the engine runs on.
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Features are the features of the engine s2i relies on.
+	Features EngineFeatures `json:"features"`
+}
+
+// EngineFeatures are the features of a container engine s2i relies on.
+type EngineFeatures struct {
+	// BuildKit is true when the engine builds images with BuildKit.
+	BuildKit bool `json:"buildkit"`
+	// MultiArch is true when the engine runs the containers of the platform
+	// requested, rather than only the one of the host.
+	MultiArch bool `json:"multiArch"`
+	// ZstdBuildContext is true when the engine accepts zstd compressed build
+	// contexts.
+	ZstdBuildContext bool `json:"zstdBuildContext"`
+}
+
+// GetEngineReport queries the engine of the given client for its version and
+// the features s2i relies on.
+func GetEngineReport(client Client) (*EngineReport, error) {
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	version, err := client.ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &EngineReport{
+		Name:       version.Platform.Name,
+		Version:    version.Version,
+		APIVersion: version.APIVersion,
+		OS:         version.Os,
+		Arch:       version.Arch,
+	}
+	if len(report.Name) == 0 {
+		report.Name = "Docker Engine"
+	}
+
+	if c, ok := client.(*engineClient); ok {
+		client = c.Client
+	}
+	if _, ok := client.(*containerd.Client); ok {
+		// nerdctl builds with buildctl, and pulls and runs the images of the
+		// platform requested with emulation
+		for _, component := range version.Components {
+			if component.Name == containerd.BuildKitComponent {
+				report.Features.BuildKit = true
+			}
+		}
+		report.Features.MultiArch = true
+		report.Features.ZstdBuildContext = true
+		return report, nil
+	}
+	// Podman serves the Docker engine API, but builds with Buildah
+	podman := strings.Contains(strings.ToLower(report.Name), "podman")
+	report.Features.BuildKit = !podman && versions.GreaterThanOrEqualTo(report.APIVersion, minBuildKitAPIVersion)
+	report.Features.MultiArch = versions.GreaterThanOrEqualTo(report.APIVersion, minMultiArchAPIVersion)
+	report.Features.ZstdBuildContext = versions.GreaterThanOrEqualTo(report.APIVersion, minZstdAPIVersion)
+	return report, nil
+}
@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+
+	dockertest "github.com/openshift/source-to-image/pkg/docker/test"
+)
+
+func TestGetEngineReport(t *testing.T) {
+	tests := map[string]struct {
+		version  dockertypes.Version
+		name     string
+		features EngineFeatures
+	}{
+		"docker 18.06": {
+			version:  dockertypes.Version{Version: "18.06.3-ce", APIVersion: "1.38"},
+			name:     "Docker Engine",
+			features: EngineFeatures{},
+		},
+		"docker 19.03": {
+			version:  dockertypes.Version{Version: "19.03.15", APIVersion: "1.40"},
+			name:     "Docker Engine",
+			features: EngineFeatures{BuildKit: true},
+		},
+		"docker 24.0": {
+			version:  dockertypes.Version{Platform: struct{ Name string }{"Docker Engine - Community"}, Version: "24.0.7", APIVersion: "1.43"},
+			name:     "Docker Engine - Community",
+			features: EngineFeatures{BuildKit: true, MultiArch: true, ZstdBuildContext: true},
+		},
+		"podman": {
+			version:  dockertypes.Version{Platform: struct{ Name string }{"Podman Engine"}, Version: "4.9.3", APIVersion: "1.41"},
+			name:     "Podman Engine",
+			features: EngineFeatures{MultiArch: true},
+		},
+	}
+	for desc, tc := range tests {
+		fakeDocker := dockertest.NewFakeDockerClient()
+		fakeDocker.ServerVersionInfo = tc.version
+		report, err := GetEngineReport(fakeDocker)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+			continue
+		}
+		if report.Name != tc.name || report.Version != tc.version.Version || report.APIVersion != tc.version.APIVersion {
+			t.Errorf("%s: unexpected engine %s %s (API %s)", desc, report.Name, report.Version, report.APIVersion)
+		}
+		if report.Features != tc.features {
+			t.Errorf("%s: expected the features %+v, got %+v", desc, tc.features, report.Features)
+		}
+	}
+}
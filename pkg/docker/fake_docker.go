@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"time"
 
 	dockertypes "github.com/docker/docker/api/types"
 
@@ -19,6 +20,8 @@ type FakeDocker struct {
 	LocalRegistryError           error
 	RemoveContainerID            string
 	RemoveContainerError         error
+	StopContainerID              string
+	StopContainerError           error
 	DefaultURLImage              string
 	DefaultURLResult             string
 	DefaultURLError              error
@@ -31,6 +34,16 @@ type FakeDocker struct {
 	RunContainerErrorBeforeStart bool
 	RunContainerContainerID      string
 	RunContainerCmd              []string
+	CreateContainerImage         string
+	CreateContainerID            string
+	CreateContainerError         error
+	DownloadFromContainerPaths   []string
+	DownloadFromContainerResult  map[string]string
+	ExecContainerID              string
+	ExecContainerCmd             []string
+	ExecContainerError           error
+	UploadDestinations           []string
+	UploadError                  error
 	GetImageIDImage              string
 	GetImageIDResult             string
 	GetImageIDError              error
@@ -39,12 +52,23 @@ type FakeDocker struct {
 	GetImageUserError            error
 	GetImageEntrypointResult     []string
 	GetImageEntrypointError      error
+	GetImageCmdResult            []string
+	GetImageCmdError             error
+	GetImageExposedPortsResult   []string
+	GetImageExposedPortsError    error
 	CommitContainerOpts          CommitContainerOptions
 	CommitContainerResult        string
 	CommitContainerError         error
 	RemoveImageName              string
 	RemoveImageError             error
+	ProbeImageName               string
+	ProbeImageResult             *ImageProbe
+	ProbeImageError              error
+	TagImageName                 string
+	TagImageTags                 []string
+	TagImageError                error
 	BuildImageOpts               BuildImageOptions
+	BuildImageID                 string
 	BuildImageError              error
 	PullResult                   bool
 	PullError                    error
@@ -55,6 +79,22 @@ type FakeDocker struct {
 	IsOnBuildImage               string
 	Labels                       map[string]string
 	LabelsError                  error
+	ResourceUsageImage           string
+	ResourceUsageResult          api.ResourceUsage
+	ResourceUsageError           error
+	PullSizeImage                string
+	PullSizeResult               int64
+	PullSizeError                error
+	DataRootResult               string
+	DataRootError                error
+	CreateVolumeNames            []string
+	CreateVolumeLabels           map[string]string
+	CreateVolumeError            error
+	SaveImageName                string
+	SaveImageResult              []byte
+	SaveImageError               error
+	LoadImageArchive             []byte
+	LoadImageError               error
 }
 
 // IsImageInLocalRegistry checks if the image exists in the fake local registry
@@ -91,6 +131,12 @@ func (f *FakeDocker) RemoveContainer(id string) error {
 	return f.RemoveContainerError
 }
 
+// StopContainer stops a fake container
+func (f *FakeDocker) StopContainer(id string, timeout time.Duration) error {
+	f.StopContainerID = id
+	return f.StopContainerError
+}
+
 // KillContainer kills a fake container
 func (f *FakeDocker) KillContainer(id string) error {
 	return nil
@@ -112,6 +158,18 @@ func (f *FakeDocker) GetAssembleRuntimeUser(image string) (string, error) {
 	return f.AssembleRuntimeUserResult, f.AssembleRuntimeUserError
 }
 
+// CreateContainer creates a fake Docker container
+func (f *FakeDocker) CreateContainer(image string) (string, error) {
+	f.CreateContainerImage = image
+	return f.CreateContainerID, f.CreateContainerError
+}
+
+// ProbeImage probes a fake image
+func (f *FakeDocker) ProbeImage(name, user, destination string) (*ImageProbe, error) {
+	f.ProbeImageName = name
+	return f.ProbeImageResult, f.ProbeImageError
+}
+
 // RunContainer runs a fake Docker container
 func (f *FakeDocker) RunContainer(opts RunContainerOptions) error {
 	f.RunContainerOpts = opts
@@ -135,12 +193,22 @@ func (f *FakeDocker) RunContainer(opts RunContainerOptions) error {
 			return err
 		}
 	}
+	if opts.OnExit != nil {
+		opts.OnExit(f.RunContainerContainerID)
+	}
 	if opts.PostExec != nil {
 		opts.PostExec.PostExecute(f.RunContainerContainerID, string(opts.Command))
 	}
 	return f.RunContainerError
 }
 
+// ExecContainer runs a command in a fake container
+func (f *FakeDocker) ExecContainer(id string, cmd []string, stdout, stderr io.Writer) error {
+	f.ExecContainerID = id
+	f.ExecContainerCmd = cmd
+	return f.ExecContainerError
+}
+
 // UploadToContainer uploads artifacts to the container.
 func (f *FakeDocker) UploadToContainer(fs fs.FileSystem, srcPath, destPath, container string) error {
 	return nil
@@ -148,12 +216,19 @@ func (f *FakeDocker) UploadToContainer(fs fs.FileSystem, srcPath, destPath, cont
 
 // UploadToContainerWithTarWriter uploads artifacts to the container.
 func (f *FakeDocker) UploadToContainerWithTarWriter(fs fs.FileSystem, srcPath, destPath, container string, makeTarWriter func(io.Writer) tar.Writer) error {
-	return errors.New("not implemented")
+	f.UploadDestinations = append(f.UploadDestinations, destPath)
+	return f.UploadError
 }
 
 // DownloadFromContainer downloads file (or directory) from the container.
 func (f *FakeDocker) DownloadFromContainer(containerPath string, w io.Writer, container string) error {
-	return errors.New("not implemented")
+	f.DownloadFromContainerPaths = append(f.DownloadFromContainerPaths, containerPath)
+	content, ok := f.DownloadFromContainerResult[containerPath]
+	if !ok {
+		return errors.New("not implemented")
+	}
+	_, err := io.WriteString(w, content)
+	return err
 }
 
 // GetImageID returns a fake Docker image ID
@@ -173,6 +248,16 @@ func (f *FakeDocker) GetImageEntrypoint(image string) ([]string, error) {
 	return f.GetImageEntrypointResult, f.GetImageEntrypointError
 }
 
+// GetImageCmd returns the fake command
+func (f *FakeDocker) GetImageCmd(image string) ([]string, error) {
+	return f.GetImageCmdResult, f.GetImageCmdError
+}
+
+// GetImageExposedPorts returns the fake exposed ports
+func (f *FakeDocker) GetImageExposedPorts(image string) ([]string, error) {
+	return f.GetImageExposedPortsResult, f.GetImageExposedPortsError
+}
+
 // CommitContainer commits a fake Docker container
 func (f *FakeDocker) CommitContainer(opts CommitContainerOptions) (string, error) {
 	f.CommitContainerOpts = opts
@@ -185,6 +270,13 @@ func (f *FakeDocker) RemoveImage(name string) error {
 	return f.RemoveImageError
 }
 
+// TagImage tags a fake image
+func (f *FakeDocker) TagImage(name, tag string) error {
+	f.TagImageName = name
+	f.TagImageTags = append(f.TagImageTags, tag)
+	return f.TagImageError
+}
+
 // CheckImage checks image in local registry
 func (f *FakeDocker) CheckImage(name string) (*api.Image, error) {
 	return nil, nil
@@ -198,6 +290,26 @@ func (f *FakeDocker) PullImage(imageName string) (*api.Image, error) {
 	return nil, f.PullError
 }
 
+// SaveImage writes the fake archive of an image
+func (f *FakeDocker) SaveImage(name string, w io.Writer) error {
+	f.SaveImageName = name
+	if f.SaveImageError != nil {
+		return f.SaveImageError
+	}
+	_, err := w.Write(f.SaveImageResult)
+	return err
+}
+
+// LoadImage records the archive of the loaded images
+func (f *FakeDocker) LoadImage(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.LoadImageArchive = data
+	return f.LoadImageError
+}
+
 // CheckAndPullImage pulls a fake docker image
 func (f *FakeDocker) CheckAndPullImage(name string) (*api.Image, error) {
 	if f.PullResult {
@@ -206,16 +318,24 @@ func (f *FakeDocker) CheckAndPullImage(name string) (*api.Image, error) {
 	return nil, f.PullError
 }
 
+// CheckAndPullChangedImage pulls a fake docker image
+func (f *FakeDocker) CheckAndPullChangedImage(name string) (*api.Image, error) {
+	if f.PullResult {
+		return &api.Image{}, nil
+	}
+	return nil, f.PullError
+}
+
 // BuildImage builds image
-func (f *FakeDocker) BuildImage(opts BuildImageOptions) error {
+func (f *FakeDocker) BuildImage(opts BuildImageOptions) (string, error) {
 	f.BuildImageOpts = opts
 	if opts.Stdin != nil {
 		_, err := io.Copy(ioutil.Discard, opts.Stdin)
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
-	return f.BuildImageError
+	return f.BuildImageID, f.BuildImageError
 }
 
 // GetLabels returns the labels of the image
@@ -227,3 +347,27 @@ func (f *FakeDocker) GetLabels(name string) (map[string]string, error) {
 func (f *FakeDocker) CheckReachable() error {
 	return nil
 }
+
+// GetResourceUsage returns the resources consumed through the fake docker
+func (f *FakeDocker) GetResourceUsage(name string) (api.ResourceUsage, error) {
+	f.ResourceUsageImage = name
+	return f.ResourceUsageResult, f.ResourceUsageError
+}
+
+// GetImagePullSize returns the size of a fake image to pull
+func (f *FakeDocker) GetImagePullSize(name string) (int64, error) {
+	f.PullSizeImage = name
+	return f.PullSizeResult, f.PullSizeError
+}
+
+// GetDataRoot returns the directory the fake docker stores its images in
+func (f *FakeDocker) GetDataRoot() (string, error) {
+	return f.DataRootResult, f.DataRootError
+}
+
+// CreateVolume records the names of the volumes created
+func (f *FakeDocker) CreateVolume(name string, labels map[string]string) error {
+	f.CreateVolumeNames = append(f.CreateVolumeNames, name)
+	f.CreateVolumeLabels = labels
+	return f.CreateVolumeError
+}
@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"reflect"
+	"sync"
+
+	dockertypes "github.com/docker/docker/api/types"
+)
+
+// maxInspectCaches is the number of clients whose inspection caches are kept.
+// The cache of the oldest client is dropped beyond it, so that a process
+// running many builds, each with its own client, does not keep them all.
+const maxInspectCaches = 8
+
+// inspectCaches holds the image inspection caches of the process, one for each
+// Docker client, so that the many Docker instances created during a build share
+// the results of the inspections made against the same daemon. clients lists
+// the clients in the order their caches were created.
+var inspectCaches = struct {
+	sync.Mutex
+	caches  map[Client]*inspectCache
+	clients []Client
+}{caches: map[Client]*inspectCache{}}
+
+// inspectCache remembers the results of image inspections. As image names are
+// mutable references, the whole cache is dropped whenever an image is pulled,
+// built, committed or removed through the client.
+type inspectCache struct {
+	mu     sync.Mutex
+	images map[string]dockertypes.ImageInspect
+}
+
+// getInspectCache returns the inspection cache of the given client. Clients that
+// cannot be used as a map key get no cache, in which case nil is returned; all
+// the inspectCache methods are safe to call on a nil cache.
+func getInspectCache(client Client) *inspectCache {
+	if client == nil || !reflect.TypeOf(client).Comparable() {
+		return nil
+	}
+	inspectCaches.Lock()
+	defer inspectCaches.Unlock()
+	cache, ok := inspectCaches.caches[client]
+	if !ok {
+		if len(inspectCaches.clients) == maxInspectCaches {
+			delete(inspectCaches.caches, inspectCaches.clients[0])
+			inspectCaches.clients = inspectCaches.clients[1:]
+		}
+		cache = &inspectCache{images: map[string]dockertypes.ImageInspect{}}
+		inspectCaches.caches[client] = cache
+		inspectCaches.clients = append(inspectCaches.clients, client)
+	}
+	return cache
+}
+
+// get returns the cached inspection of the image with the given name.
+func (c *inspectCache) get(name string) (*dockertypes.ImageInspect, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	inspect, ok := c.images[name]
+	if !ok {
+		return nil, false
+	}
+	return &inspect, true
+}
+
+// put records the inspection of the image with the given name.
+func (c *inspectCache) put(name string, inspect dockertypes.ImageInspect) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.images[name] = inspect
+}
+
+// invalidate drops all the cached inspections.
+func (c *inspectCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.images = map[string]dockertypes.ImageInspect{}
+}
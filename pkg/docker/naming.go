@@ -0,0 +1,138 @@
+package docker
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/version"
+)
+
+var (
+	invocationIDOnce sync.Once
+	invocationID     string
+)
+
+// InvocationID returns the ID of this s2i invocation, recorded in the labels
+// of the resources it creates so that they can be removed with "s2i cleanup"
+// if the build is aborted.
+func InvocationID() string {
+	invocationIDOnce.Do(func() {
+		b := make([]byte, 8)
+		if _, err := crand.Read(b); err != nil {
+			rand.Seed(time.Now().UnixNano())
+			rand.Read(b)
+		}
+		invocationID = hex.EncodeToString(b)
+	})
+	return invocationID
+}
+
+// NamingPolicy names and labels the containers and the temporary images
+// created by S2I, so that the resources of concurrent builds sharing a host
+// can be attributed to their build and filtered.
+type NamingPolicy interface {
+	// ContainerName returns the name of a new container of the image.
+	ContainerName(image string) string
+	// TemporaryImageName returns the name of a temporary image, given the
+	// base name identifying it.
+	TemporaryImageName(name string) string
+	// Labels returns the labels set on the containers and temporary images.
+	Labels() map[string]string
+}
+
+// DefaultNamingPolicy is the NamingPolicy configured by the docker
+// configuration of the build.
+type DefaultNamingPolicy struct {
+	// Prefix replaces containerNamePrefix at the beginning of the names.
+	Prefix string
+	// WorkerID identifies the worker running the build; it follows the
+	// prefix in the names and is recorded in the WorkerIDLabel label.
+	WorkerID string
+	// ExtraLabels are additional labels of the containers and temporary
+	// images.
+	ExtraLabels map[string]string
+	// InvocationID, BuildID and Version are recorded in the ownership labels
+	// of the containers, temporary images and volumes.
+	InvocationID string
+	BuildID      string
+	Version      string
+}
+
+// NewNamingPolicy returns the naming policy described by the docker
+// configuration.
+func NewNamingPolicy(config *api.DockerConfig) NamingPolicy {
+	policy := DefaultNamingPolicy{
+		InvocationID: InvocationID(),
+		Version:      version.Get().String(),
+	}
+	if config != nil {
+		policy.Prefix = config.NamePrefix
+		policy.WorkerID = config.WorkerID
+		policy.ExtraLabels = config.ResourceLabels
+		policy.BuildID = config.BuildID
+	}
+	return policy
+}
+
+// prefix returns the prefix of the names, including the worker ID.
+func (p DefaultNamingPolicy) prefix(separator string) string {
+	prefix := p.Prefix
+	if len(prefix) == 0 {
+		prefix = containerNamePrefix
+	}
+	if len(p.WorkerID) > 0 {
+		prefix += separator + p.WorkerID
+	}
+	return prefix
+}
+
+// ContainerName creates names for Docker containers launched by S2I. It is
+// meant to resemble Kubernetes' pkg/kubelet/dockertools.BuildDockerName.
+func (p DefaultNamingPolicy) ContainerName(image string) string {
+	//Initialize seed
+	rand.Seed(time.Now().UnixNano())
+	uid := fmt.Sprintf("%08x", rand.Uint32())
+	// Replace invalid characters for container name with underscores.
+	image = strings.Map(func(r rune) rune {
+		if ('0' <= r && r <= '9') || ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z') {
+			return r
+		}
+		return '_'
+	}, image)
+	return fmt.Sprintf("%s_%s_%s", p.prefix("_"), image, uid)
+}
+
+// TemporaryImageName prefixes the name of a temporary image.
+func (p DefaultNamingPolicy) TemporaryImageName(name string) string {
+	return p.prefix("-") + "-" + name
+}
+
+// Labels returns the extra labels, the worker ID label and the ownership
+// labels.
+func (p DefaultNamingPolicy) Labels() map[string]string {
+	labels := map[string]string{}
+	for k, v := range p.ExtraLabels {
+		labels[k] = v
+	}
+	for k, v := range map[string]string{
+		constants.WorkerIDLabel:       p.WorkerID,
+		constants.InvocationIDLabel:   p.InvocationID,
+		constants.BuildIDLabel:        p.BuildID,
+		constants.CreatorVersionLabel: p.Version,
+	} {
+		if len(v) > 0 {
+			labels[k] = v
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
@@ -0,0 +1,56 @@
+package docker
+
+import (
+	"github.com/openshift/source-to-image/pkg/api"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/imagestore"
+)
+
+// offlineMode forbids the pulls of the images, which are loaded from the
+// image store instead when there is one.
+type offlineMode struct {
+	// imageStore is the OCI image layout directory the images missing locally
+	// are loaded from.
+	imageStore string
+}
+
+// imageSize returns the compressed size of the layers of the given image of
+// the image store, or 0 when there is no image store.
+func (m *offlineMode) imageSize(name string) (int64, error) {
+	if len(m.imageStore) == 0 {
+		return 0, nil
+	}
+	s, err := imagestore.Open(m.imageStore)
+	if err != nil {
+		return 0, err
+	}
+	img, err := s.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return img.Size(), nil
+}
+
+// loadImage makes the given image available locally without pulling it, by
+// loading it from the image store.
+func (d *stiDocker) loadImage(name string) (*api.Image, error) {
+	store := d.offline.imageStore
+	if len(store) == 0 {
+		return nil, s2ierr.NewOfflineImageError(name, "", nil)
+	}
+	s, err := imagestore.Open(store)
+	if err != nil {
+		return nil, s2ierr.NewOfflineImageError(name, store, err)
+	}
+	img, err := s.Lookup(name)
+	if err != nil {
+		return nil, s2ierr.NewOfflineImageError(name, store, err)
+	}
+	log.V(1).Infof("Loading image %q from the image store %s ...", name, store)
+	archive := s.Archive(img)
+	defer archive.Close()
+	if err := d.LoadImage(archive); err != nil {
+		return nil, s2ierr.NewOfflineImageError(name, store, err)
+	}
+	return d.CheckImage(name)
+}
@@ -0,0 +1,100 @@
+package docker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	dockertest "github.com/openshift/source-to-image/pkg/docker/test"
+	"github.com/openshift/source-to-image/pkg/errors"
+)
+
+// writeImageStore writes an OCI image layout holding a single image with the
+// given name.
+func writeImageStore(t *testing.T, name string) string {
+	dir := t.TempDir()
+	writeBlob := func(mediaType string, content []byte) v1.Descriptor {
+		d := digest.FromBytes(content)
+		path := filepath.Join(dir, "blobs", "sha256")
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, d.Encoded()), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return v1.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(content))}
+	}
+	config := writeBlob(v1.MediaTypeImageConfig, []byte(`{"os": "linux"}`))
+	layer := writeBlob(v1.MediaTypeImageLayerGzip, []byte("layer"))
+	data, _ := json.Marshal(v1.Manifest{MediaType: v1.MediaTypeImageManifest, Config: config, Layers: []v1.Descriptor{layer}})
+	manifest := writeBlob(v1.MediaTypeImageManifest, data)
+	manifest.Annotations = map[string]string{v1.AnnotationRefName: name}
+	data, _ = json.Marshal(v1.Index{Manifests: []v1.Descriptor{manifest}})
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPullImageOffline(t *testing.T) {
+	store := writeImageStore(t, "quay.io/team/builder:latest")
+	tests := []struct {
+		name     string
+		image    string
+		store    string
+		loaded   []string
+		expected string
+	}{
+		{
+			name:   "in the image store",
+			image:  "quay.io/team/builder",
+			store:  store,
+			loaded: []string{"quay.io/team/builder:latest"},
+		},
+		{
+			name:     "not in the image store",
+			image:    "quay.io/team/runtime",
+			store:    store,
+			expected: "not available locally nor in the image store " + store,
+		},
+		{
+			name:     "no image store",
+			image:    "quay.io/team/builder",
+			expected: "not available locally and --offline forbids pulling it",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeDocker := dockertest.NewFakeDockerClient()
+			client := &engineClient{Client: fakeDocker, offline: &offlineMode{imageStore: tc.store}}
+			_, err := New(client, api.AuthConfig{}).CheckAndPullImage(tc.image)
+			if len(tc.expected) == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tc.expected) > 0 {
+				e, ok := err.(errors.Error)
+				if !ok || e.ErrorCode != errors.PullImageError || !strings.Contains(e.Message, tc.expected) {
+					t.Errorf("expected a pull error containing %q, got %#v", tc.expected, err)
+				}
+			}
+			if !reflect.DeepEqual(fakeDocker.LoadedImages, tc.loaded) {
+				t.Errorf("expected the images %v to be loaded, got %v", tc.loaded, fakeDocker.LoadedImages)
+			}
+			for _, call := range fakeDocker.Calls {
+				if call == "pull" {
+					t.Errorf("expected no pull offline")
+				}
+			}
+		})
+	}
+}
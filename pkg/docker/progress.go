@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// progressInterval is the interval at which the progress of the long
+	// running commits and uploads is reported.
+	progressInterval = 30 * time.Second
+	// uploadStallTimeout is the time after which an upload that does not
+	// progress is canceled.
+	uploadStallTimeout = DefaultDockerTimeout
+)
+
+// reportProgress calls report with the elapsed time at each progress interval,
+// until the returned function is called.
+func reportProgress(report func(elapsed time.Duration)) func() {
+	start := time.Now()
+	ticker := time.NewTicker(progressInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				report(time.Since(start))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// countingReader counts the bytes read through it, and records when they were
+// last read.
+type countingReader struct {
+	io.Reader
+	count    int64
+	lastRead int64
+}
+
+func newCountingReader(r io.Reader) *countingReader {
+	return &countingReader{Reader: r, lastRead: time.Now().UnixNano()}
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		atomic.AddInt64(&r.count, int64(n))
+		atomic.StoreInt64(&r.lastRead, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// idle returns the time elapsed since bytes were last read.
+func (r *countingReader) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&r.lastRead)))
+}
+
+// mebibytes returns the number of MiB read through the reader.
+func (r *countingReader) mebibytes() float64 {
+	return float64(atomic.LoadInt64(&r.count)) / (1 << 20)
+}
@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"strings"
+
+	imagedocker "github.com/containers/image/v5/docker"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+// CheckAndPullChangedImage pulls the image when it is not available locally,
+// or when the digest of its manifest in the registry is not one of the digests
+// the local image was pulled with. Only the digest is requested from the
+// registry, so an unchanged image costs a round trip instead of a pull. The
+// local image is used when the registry cannot be reached, or offline.
+func (d *stiDocker) CheckAndPullChangedImage(name string) (*api.Image, error) {
+	name = getImageName(name)
+
+	image, err := d.CheckImage(name)
+	if err != nil && !strings.Contains(err.(s2ierr.Error).Details.Error(), "No such image") {
+		return nil, err
+	}
+	if image == nil {
+		log.V(1).Infof("Image %q not available locally, pulling ...", name)
+		return d.PullImage(name)
+	}
+	if d.offline != nil {
+		log.V(3).Infof("Using locally available image %q, its registry is not queried offline", name)
+		return image, nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, s2ierr.NewPullImageError(name, err)
+	}
+	if _, ok := named.(reference.Digested); ok {
+		log.V(3).Infof("Using locally available image %q, pinned by digest", name)
+		return image, nil
+	}
+
+	remote, err := d.getRemoteImageDigest(name)
+	if err != nil {
+		log.V(1).Infof("Unable to get the digest of image %q from its registry, using the locally available image: %v", name, err)
+		return image, nil
+	}
+	inspect, err := d.InspectImage(name)
+	if err != nil {
+		return nil, s2ierr.NewInspectImageError(name, err)
+	}
+	if hasRepoDigest(inspect.RepoDigests, named, remote) {
+		log.V(3).Infof("Using locally available image %q, unchanged in its registry", name)
+		return image, nil
+	}
+	log.V(1).Infof("Image %q changed in its registry (%s), pulling ...", name, remote)
+	return d.PullImage(name)
+}
+
+// getRemoteImageDigest returns the digest of the manifest of the image in its
+// registry. The digest is resolved by the daemon, so that its registry mirrors
+// and insecure registries apply as they do to the pulls, or requested from the
+// registry with a HEAD request when the engine cannot resolve it.
+func (d *stiDocker) getRemoteImageDigest(name string) (string, error) {
+	base64Auth, err := base64EncodeAuth(d.pullAuth)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	inspect, err := d.client.DistributionInspect(ctx, name, base64Auth)
+	if err == nil {
+		return inspect.Descriptor.Digest.String(), nil
+	}
+	if !errdefs.IsNotImplemented(err) {
+		return "", err
+	}
+	log.V(3).Infof("The engine does not resolve the digest of image %q, requesting it from the registry: %v", name, err)
+	ref, err := imagedocker.ParseReference("//" + name)
+	if err != nil {
+		return "", err
+	}
+	digest, err := imagedocker.GetDigest(ctx, d.registrySystemContext(), ref)
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// hasRepoDigest returns true if one of the repository digests of a local image
+// is the given digest in the repository of named.
+func hasRepoDigest(repoDigests []string, named reference.Named, digest string) bool {
+	for _, repoDigest := range repoDigests {
+		ref, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil {
+			continue
+		}
+		digested, ok := ref.(reference.Digested)
+		if ok && ref.Name() == named.Name() && digested.Digest().String() == digest {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// DefaultPullQueueTimeout is the time a pull waits for a slot of its registry
+// when SetMaxConcurrentPulls is not given a timeout.
+const DefaultPullQueueTimeout = 10 * time.Minute
+
+// pullLimits holds the slots of the images pulled concurrently from each
+// registry by the process.
+var pullLimits = struct {
+	sync.Mutex
+	max        int
+	timeout    time.Duration
+	registries map[string]chan struct{}
+}{registries: map[string]chan struct{}{}}
+
+// SetMaxConcurrentPulls limits the number of images pulled concurrently from
+// each registry by the process, so that a burst of builds does not exhaust the
+// bandwidth of the host or hit the rate limits of the registries. Zero removes
+// the limit. A pull fails when it waits longer than timeout for a slot, or than
+// DefaultPullQueueTimeout when timeout is zero.
+func SetMaxConcurrentPulls(max int, timeout time.Duration) {
+	pullLimits.Lock()
+	defer pullLimits.Unlock()
+	pullLimits.timeout = timeout
+	if max == pullLimits.max {
+		return
+	}
+	pullLimits.max = max
+	// the pulls in progress release the slots of the previous limit
+	pullLimits.registries = map[string]chan struct{}{}
+}
+
+// registryOf returns the registry the image is pulled from.
+func registryOf(name string) string {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return ""
+	}
+	return reference.Domain(named)
+}
+
+// acquirePull waits for a slot to pull the image from its registry, and
+// returns the function releasing it. It fails when no slot is available
+// within the timeout of the limit.
+func acquirePull(name string) (func(), error) {
+	pullLimits.Lock()
+	if pullLimits.max <= 0 {
+		pullLimits.Unlock()
+		return func() {}, nil
+	}
+	timeout := pullLimits.timeout
+	if timeout <= 0 {
+		timeout = DefaultPullQueueTimeout
+	}
+	registry := registryOf(name)
+	slots, ok := pullLimits.registries[registry]
+	if !ok {
+		slots = make(chan struct{}, pullLimits.max)
+		pullLimits.registries[registry] = slots
+	}
+	pullLimits.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		log.V(2).Infof("Waiting for a concurrent pull from %s to complete before pulling image %s", registry, name)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case slots <- struct{}{}:
+		case <-timer.C:
+			return nil, fmt.Errorf("no pull from %s completed within %v", registry, timeout)
+		}
+	}
+	return func() { <-slots }, nil
+}
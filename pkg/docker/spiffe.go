@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// workloadAPIHeader is the metadata the SPIFFE Workload API requires on every
+// request.
+const workloadAPIHeader = "workload.spiffe.io"
+
+// x509SVID is an X.509 SPIFFE Verifiable Identity Document, along with the CA
+// bundle of its trust domain.
+type x509SVID struct {
+	id          string
+	certificate tls.Certificate
+	bundle      *x509.CertPool
+}
+
+// workloadAPIDialAddress returns the network and the address to dial for the
+// given SPIFFE Workload API socket, e.g. unix:///run/spire/sockets/agent.sock
+// or tcp://127.0.0.1:8081.
+func workloadAPIDialAddress(socket string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(socket, "unix://"):
+		return "unix", strings.TrimPrefix(socket, "unix://"), nil
+	case strings.HasPrefix(socket, "unix:"):
+		return "unix", strings.TrimPrefix(socket, "unix:"), nil
+	case strings.HasPrefix(socket, "tcp://"):
+		return "tcp", strings.TrimPrefix(socket, "tcp://"), nil
+	case strings.HasPrefix(socket, "/"):
+		return "unix", socket, nil
+	}
+	return "", "", fmt.Errorf("invalid SPIFFE Workload API socket address %q, it must start with unix:// or tcp://", socket)
+}
+
+// parseX509SVIDResponse returns the first X.509 SVID of the given protobuf
+// encoded X509SVIDResponse message of the SPIFFE Workload API.
+func parseX509SVIDResponse(data []byte) (*x509SVID, error) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		if num == 1 && typ == protowire.BytesType {
+			svid, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			return parseX509SVID(svid)
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil, errors.New("the response holds no X.509 SVID")
+}
+
+// parseX509SVID parses the given protobuf encoded X509SVID message.
+func parseX509SVID(data []byte) (*x509SVID, error) {
+	var id string
+	var chain, key, bundle []byte
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		if typ != protowire.BytesType || num < 1 || num > 4 {
+			if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch num {
+		case 1:
+			id = string(value)
+		case 2:
+			chain = value
+		case 3:
+			key = value
+		case 4:
+			bundle = value
+		}
+	}
+
+	certs, err := x509.ParseCertificates(chain)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the certificates of %s: %v", id, err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("the X.509 SVID of %s holds no certificate", id)
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the private key of %s: %v", id, err)
+	}
+	roots, err := x509.ParseCertificates(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the CA bundle of %s: %v", id, err)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("the CA bundle of %s holds no certificate", id)
+	}
+
+	svid := &x509SVID{
+		id: id,
+		certificate: tls.Certificate{
+			PrivateKey: privateKey,
+			Leaf:       certs[0],
+		},
+		bundle: x509.NewCertPool(),
+	}
+	for _, cert := range certs {
+		svid.certificate.Certificate = append(svid.certificate.Certificate, cert.Raw)
+	}
+	for _, root := range roots {
+		svid.bundle.AddCert(root)
+	}
+	return svid, nil
+}
@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// workloadAPITimeout is the maximum time spent fetching an X.509 SVID.
+	workloadAPITimeout = 30 * time.Second
+
+	// fetchX509SVIDMethod is the gRPC method of the SPIFFE Workload API
+	// streaming the X.509 SVIDs of the workload.
+	fetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+)
+
+// rawCodec passes the messages of the SPIFFE Workload API through as their
+// protobuf encoding, which parseX509SVIDResponse decodes, so that the code
+// generated for the Workload API is not needed.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	data, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *data, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*message = append((*message)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// fetchX509SVID fetches the X.509 SVID of the workload from the SPIFFE
+// Workload API listening on the given socket.
+func fetchX509SVID(socket string) (*x509SVID, error) {
+	network, address, err := workloadAPIDialAddress(socket)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient("passthrough:///spiffe-workload-api",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// FetchX509SVID streams the SVIDs as they are rotated, only the first
+	// message is read before the stream is cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), workloadAPITimeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, workloadAPIHeader, "true")
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fetchX509SVIDMethod)
+	if err != nil {
+		return nil, err
+	}
+	// an empty X509SVIDRequest message
+	request := []byte{}
+	if err := stream.SendMsg(&request); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	var response []byte
+	if err := stream.RecvMsg(&response); err != nil {
+		return nil, err
+	}
+	return parseX509SVIDResponse(response)
+}
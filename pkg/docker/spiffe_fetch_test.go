@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestFetchX509SVID(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported on Windows")
+	}
+	response, _, _ := newTestX509SVIDResponse(t, "spiffe://example.org/s2i")
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			if method != fetchX509SVIDMethod || len(md.Get(workloadAPIHeader)) == 0 {
+				return status.Error(codes.InvalidArgument, "security header missing from request")
+			}
+			var request []byte
+			if err := stream.RecvMsg(&request); err != nil {
+				return err
+			}
+			return stream.SendMsg(&response)
+		}),
+	)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	svid, err := fetchX509SVID("unix://" + socket)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if svid.id != "spiffe://example.org/s2i" {
+		t.Errorf("Expected SVID of spiffe://example.org/s2i, got %s", svid.id)
+	}
+}
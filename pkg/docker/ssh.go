@@ -0,0 +1,205 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sshScheme is the scheme of the endpoints of the container engines reached
+// through SSH, e.g. ssh://user@host.
+const sshScheme = "ssh://"
+
+// sshDialHost is the host of the requests sent to the container engines
+// reached through SSH. It is never resolved, the connections are established
+// by the ssh command.
+const sshDialHost = "http://docker.example.com"
+
+// isSSHEndpoint returns true if the container engine of the endpoint is
+// reached through SSH.
+func isSSHEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, sshScheme)
+}
+
+// sshArgs returns the arguments of the ssh command connecting to the
+// container engine of the given ssh://[user@]host[:port] endpoint, which runs
+// docker system dial-stdio on the remote host, as the docker CLI does.
+func sshArgs(endpoint string) ([]string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH endpoint %q: %v", endpoint, err)
+	}
+	if len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("invalid SSH endpoint %q: no host", endpoint)
+	}
+	if len(strings.Trim(u.Path, "/")) > 0 || len(u.RawQuery) > 0 {
+		return nil, fmt.Errorf("invalid SSH endpoint %q: it must not have a path or a query", endpoint)
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("invalid SSH endpoint %q: it must not have a password, use an SSH key or agent instead", endpoint)
+	}
+	var args []string
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if len(u.Port()) > 0 {
+		args = append(args, "-p", u.Port())
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio"), nil
+}
+
+// sshDialer returns the function dialing the container engine of the given
+// SSH endpoint, each connection running its own ssh command.
+func sshDialer(endpoint string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	args, err := sshArgs(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return nil, fmt.Errorf("reaching the container engine at %s requires ssh to be installed: %v", endpoint, err)
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialCommand("ssh", args...)
+	}, nil
+}
+
+// dialCommand starts the given command and returns a connection writing to
+// its standard input and reading from its standard output. Closing the
+// connection kills the command.
+func dialCommand(name string, args ...string) (net.Conn, error) {
+	log.V(5).Infof("Running %s %s", name, strings.Join(args, " "))
+	cmd := exec.Command(name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}
+	cmd.Stderr = &c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// commandConn is a connection to the standard input and output of a command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr lockedBuffer
+
+	waitOnce sync.Once
+}
+
+// Read reads from the standard output of the command. The standard error of
+// the command is reported when it exits before the connection is closed.
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		// the standard error is fully copied once the command is waited for
+		c.wait()
+		if msg := strings.TrimSpace(c.stderr.String()); len(msg) > 0 {
+			return n, fmt.Errorf("%s: %s", c.cmd.Path, msg)
+		}
+	}
+	return n, err
+}
+
+// Write writes to the standard input of the command.
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// CloseWrite closes the standard input of the command, which is used for the
+// hijacked connections attached to the containers.
+func (c *commandConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+// Close kills the command.
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	c.wait()
+	return nil
+}
+
+// wait waits for the command to exit.
+func (c *commandConn) wait() {
+	c.waitOnce.Do(func() {
+		c.cmd.Wait()
+	})
+}
+
+// LocalAddr returns the address of the local end of the connection.
+func (c *commandConn) LocalAddr() net.Addr {
+	return commandAddr{}
+}
+
+// RemoteAddr returns the address of the remote end of the connection.
+func (c *commandConn) RemoteAddr() net.Addr {
+	return commandAddr{}
+}
+
+// SetDeadline is not supported, the deadlines are enforced by the contexts
+// of the requests.
+func (c *commandConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetReadDeadline is not supported.
+func (c *commandConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+// SetWriteDeadline is not supported.
+func (c *commandConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// lockedBuffer is a buffer safe for concurrent use, holding the standard
+// error of a command written while the connection is read.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write appends p to the buffer.
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns the contents of the buffer.
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// commandAddr is the address of both ends of a commandConn.
+type commandAddr struct{}
+
+// Network returns the name of the network.
+func (commandAddr) Network() string {
+	return "command"
+}
+
+// String returns the address.
+func (commandAddr) String() string {
+	return "command"
+}
@@ -1,8 +1,10 @@
 package test
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +16,11 @@ import (
 	dockercontainer "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	dockernetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/net/context"
 )
@@ -83,16 +90,61 @@ type FakeDockerClient struct {
 	ContainerCommitOptions  dockercontainer.CommitOptions
 	ContainerCommitResponse dockertypes.IDResponse
 	ContainerCommitErr      error
-
-	BuildImageOpts dockertypes.ImageBuildOptions
-	BuildImageErr  error
-	Images         map[string]dockertypes.ImageInspect
+	// ContainerCommitFails are returned by the successive commits before
+	// ContainerCommitErr
+	ContainerCommitFails []error
+
+	// ExportOutput is the file system of the exported containers
+	ExportOutput []byte
+	ExportErr    error
+	// ImportSource is the content of the last image import, whose output is
+	// ImportOutput
+	ImportSource  []byte
+	ImportRef     string
+	ImportOptions image.ImportOptions
+	ImportOutput  []byte
+	ImportErr     error
+
+	ExecCmd      []string
+	ExecOutput   []byte
+	ExecExitCode int
+
+	BuildImageOpts    dockertypes.ImageBuildOptions
+	BuildImageContext []byte
+	BuildImageErr     error
+	// BuildImageOutput is the output of the image builds.
+	BuildImageOutput []byte
+	Images           map[string]dockertypes.ImageInspect
 
 	Containers map[string]dockercontainer.Config
+	Volumes    map[string]volume.Volume
+
+	KillSignals []string
+
+	PullFail   error
+	PullOutput []byte
+	// PullFails are returned by the successive pulls before PullFail
+	PullFails []error
+	PullAuths []string
 
-	PullFail error
+	// LoadedImages are the tags of the images loaded from archives
+	LoadedImages []string
+	LoadFail     error
+	// SavedImages are the images saved to archives, whose content is
+	// SaveOutput
+	SavedImages []string
+	SaveOutput  []byte
 
 	Calls []string
+
+	ServerVersionInfo dockertypes.Version
+	SystemInfo        system.Info
+
+	Stats   []dockercontainer.StatsResponse
+	History map[string][]image.HistoryResponseItem
+	// Digests are the digests of the images in their registries, resolved by
+	// DistributionInspect
+	Digests map[string]digest.Digest
 }
 
 // NewFakeDockerClient returns a new FakeDockerClient
@@ -100,10 +152,27 @@ func NewFakeDockerClient() *FakeDockerClient {
 	return &FakeDockerClient{
 		Images:     make(map[string]dockertypes.ImageInspect),
 		Containers: make(map[string]dockercontainer.Config),
+		Volumes:    make(map[string]volume.Volume),
 		Calls:      make([]string, 0),
 	}
 }
 
+// DistributionInspect returns the digest of an image in its registry.
+func (d *FakeDockerClient) DistributionInspect(ctx context.Context, name, encodedRegistryAuth string) (registry.DistributionInspect, error) {
+	d.Calls = append(d.Calls, "distribution_inspect")
+	dgst, ok := d.Digests[name]
+	if !ok {
+		return registry.DistributionInspect{}, errdefs.NotFound(fmt.Errorf("manifest for %s not found", name))
+	}
+	return registry.DistributionInspect{Descriptor: v1.Descriptor{Digest: dgst}}, nil
+}
+
+// ImageHistory returns the layers of an image.
+func (d *FakeDockerClient) ImageHistory(ctx context.Context, imageID string) ([]image.HistoryResponseItem, error) {
+	d.Calls = append(d.Calls, "image_history")
+	return d.History[imageID], nil
+}
+
 // ImageInspectWithRaw returns the image information and its raw representation.
 func (d *FakeDockerClient) ImageInspectWithRaw(ctx context.Context, imageID string) (dockertypes.ImageInspect, []byte, error) {
 	d.Calls = append(d.Calls, "inspect_image")
@@ -152,9 +221,42 @@ func (d *FakeDockerClient) ContainerWait(ctx context.Context, containerID string
 func (d *FakeDockerClient) ContainerCommit(ctx context.Context, container string, options dockercontainer.CommitOptions) (dockertypes.IDResponse, error) {
 	d.ContainerCommitID = container
 	d.ContainerCommitOptions = options
+	d.Calls = append(d.Calls, "commit")
+	if len(d.ContainerCommitFails) > 0 {
+		err := d.ContainerCommitFails[0]
+		d.ContainerCommitFails = d.ContainerCommitFails[1:]
+		if err != nil {
+			return dockertypes.IDResponse{}, err
+		}
+	}
 	return d.ContainerCommitResponse, d.ContainerCommitErr
 }
 
+// ContainerExport returns the file system of a container as a tar archive.
+func (d *FakeDockerClient) ContainerExport(ctx context.Context, container string) (io.ReadCloser, error) {
+	d.Calls = append(d.Calls, "export")
+	if d.ExportErr != nil {
+		return nil, d.ExportErr
+	}
+	return ioutil.NopCloser(bytes.NewReader(d.ExportOutput)), nil
+}
+
+// ImageImport creates an image from the content of a tar archive.
+func (d *FakeDockerClient) ImageImport(ctx context.Context, source image.ImportSource, ref string, options image.ImportOptions) (io.ReadCloser, error) {
+	d.Calls = append(d.Calls, "import")
+	d.ImportRef = ref
+	d.ImportOptions = options
+	if d.ImportErr != nil {
+		return nil, d.ImportErr
+	}
+	content, err := ioutil.ReadAll(source.Source)
+	if err != nil {
+		return nil, err
+	}
+	d.ImportSource = content
+	return ioutil.NopCloser(bytes.NewReader(d.ImportOutput)), nil
+}
+
 // ContainerAttach attaches a connection to a container in the server.
 func (d *FakeDockerClient) ContainerAttach(ctx context.Context, container string, options dockercontainer.AttachOptions) (dockertypes.HijackedResponse, error) {
 	d.Calls = append(d.Calls, "attach")
@@ -164,8 +266,11 @@ func (d *FakeDockerClient) ContainerAttach(ctx context.Context, container string
 // ImageBuild sends request to the daemon to build images.
 func (d *FakeDockerClient) ImageBuild(ctx context.Context, buildContext io.Reader, options dockertypes.ImageBuildOptions) (dockertypes.ImageBuildResponse, error) {
 	d.BuildImageOpts = options
+	if buildContext != nil {
+		d.BuildImageContext, _ = ioutil.ReadAll(buildContext)
+	}
 	return dockertypes.ImageBuildResponse{
-		Body: ioutil.NopCloser(bytes.NewReader([]byte(""))),
+		Body: ioutil.NopCloser(bytes.NewReader(d.BuildImageOutput)),
 	}, d.BuildImageErr
 }
 
@@ -177,6 +282,18 @@ func (d *FakeDockerClient) ContainerCreate(ctx context.Context, config *dockerco
 	return dockercontainer.CreateResponse{}, nil
 }
 
+// ContainerList lists the containers matching the label filters.
+func (d *FakeDockerClient) ContainerList(ctx context.Context, options dockercontainer.ListOptions) ([]dockertypes.Container, error) {
+	d.Calls = append(d.Calls, "list_containers")
+	containers := []dockertypes.Container{}
+	for name, config := range d.Containers {
+		if options.Filters.MatchKVList("label", config.Labels) {
+			containers = append(containers, dockertypes.Container{ID: name, Names: []string{"/" + name}, Labels: config.Labels})
+		}
+	}
+	return containers, nil
+}
+
 // ContainerInspect returns the container information.
 func (d *FakeDockerClient) ContainerInspect(ctx context.Context, containerID string) (dockertypes.ContainerJSON, error) {
 	d.Calls = append(d.Calls, "inspect_container")
@@ -196,24 +313,63 @@ func (d *FakeDockerClient) ContainerRemove(ctx context.Context, containerID stri
 
 // ContainerKill terminates the container process but does not remove the container from the docker host.
 func (d *FakeDockerClient) ContainerKill(ctx context.Context, containerID, signal string) error {
+	d.KillSignals = append(d.KillSignals, signal)
 	return nil
 }
 
+// ContainerExecCreate creates a new exec configuration to run an exec process.
+func (d *FakeDockerClient) ContainerExecCreate(ctx context.Context, container string, options dockercontainer.ExecOptions) (dockertypes.IDResponse, error) {
+	d.Calls = append(d.Calls, "exec_create")
+	d.ExecCmd = options.Cmd
+	return dockertypes.IDResponse{ID: "exec"}, nil
+}
+
+// ContainerExecAttach attaches a connection to an exec process in the server.
+func (d *FakeDockerClient) ContainerExecAttach(ctx context.Context, execID string, options dockercontainer.ExecAttachOptions) (dockertypes.HijackedResponse, error) {
+	d.Calls = append(d.Calls, "exec_attach")
+	return dockertypes.HijackedResponse{Conn: FakeConn{}, Reader: bufio.NewReader(bytes.NewReader(d.ExecOutput))}, nil
+}
+
+// ContainerExecInspect returns information about a specific exec process on the docker host.
+func (d *FakeDockerClient) ContainerExecInspect(ctx context.Context, execID string) (dockercontainer.ExecInspect, error) {
+	d.Calls = append(d.Calls, "exec_inspect")
+	return dockercontainer.ExecInspect{ExecID: execID, ExitCode: d.ExecExitCode}, nil
+}
+
 // ContainerStart sends a request to the docker daemon to start a container.
 func (d *FakeDockerClient) ContainerStart(ctx context.Context, containerID string, options dockercontainer.StartOptions) error {
 	d.Calls = append(d.Calls, "start")
 	return nil
 }
 
+// ContainerStats returns a stream of the statistics of a container.
+func (d *FakeDockerClient) ContainerStats(ctx context.Context, containerID string, stream bool) (dockercontainer.StatsResponseReader, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, stats := range d.Stats {
+		encoder.Encode(stats)
+	}
+	return dockercontainer.StatsResponseReader{Body: ioutil.NopCloser(buf), OSType: "linux"}, nil
+}
+
 // ImagePull requests the docker host to pull an image from a remote registry.
 func (d *FakeDockerClient) ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error) {
 	d.Calls = append(d.Calls, "pull")
+	d.PullAuths = append(d.PullAuths, options.RegistryAuth)
 
+	if len(d.PullFails) > 0 {
+		err := d.PullFails[0]
+		d.PullFails = d.PullFails[1:]
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(d.PullOutput)), nil
+	}
 	if d.PullFail != nil {
 		return nil, d.PullFail
 	}
 
-	return ioutil.NopCloser(bytes.NewReader([]byte{})), nil
+	return ioutil.NopCloser(bytes.NewReader(d.PullOutput)), nil
 }
 
 // ImageRemove removes an image from the docker host.
@@ -227,7 +383,121 @@ func (d *FakeDockerClient) ImageRemove(ctx context.Context, imageID string, opti
 	return []image.DeleteResponse{}, errors.New("image does not exist")
 }
 
+// ImageLoad loads the images of an archive in the format of docker save,
+// which are added to the images of the client with an empty configuration.
+func (d *FakeDockerClient) ImageLoad(ctx context.Context, input io.Reader, quiet bool) (image.LoadResponse, error) {
+	d.Calls = append(d.Calls, "load")
+	if d.LoadFail != nil {
+		return image.LoadResponse{}, d.LoadFail
+	}
+	tr := tar.NewReader(input)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return image.LoadResponse{}, err
+		}
+		if hdr.Name != "manifest.json" {
+			continue
+		}
+		var manifests []struct{ RepoTags []string }
+		if err := json.NewDecoder(tr).Decode(&manifests); err != nil {
+			return image.LoadResponse{}, err
+		}
+		for _, m := range manifests {
+			for _, tag := range m.RepoTags {
+				d.Images[tag] = dockertypes.ImageInspect{Config: &dockercontainer.Config{}}
+				d.LoadedImages = append(d.LoadedImages, tag)
+			}
+		}
+	}
+	return image.LoadResponse{Body: ioutil.NopCloser(&bytes.Buffer{}), JSON: true}, nil
+}
+
+// ImageSave returns the archive of the given images.
+func (d *FakeDockerClient) ImageSave(ctx context.Context, images []string) (io.ReadCloser, error) {
+	d.Calls = append(d.Calls, "save")
+	d.SavedImages = append(d.SavedImages, images...)
+	return ioutil.NopCloser(bytes.NewReader(d.SaveOutput)), nil
+}
+
+// ImageList lists the images matching the label filters.
+func (d *FakeDockerClient) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	d.Calls = append(d.Calls, "list_images")
+	images := []image.Summary{}
+	for id, inspect := range d.Images {
+		var labels map[string]string
+		if inspect.Config != nil {
+			labels = inspect.Config.Labels
+		}
+		if options.Filters.MatchKVList("label", labels) {
+			images = append(images, image.Summary{ID: id, Labels: labels})
+		}
+	}
+	return images, nil
+}
+
+// ImageTag tags an image in the docker host.
+func (d *FakeDockerClient) ImageTag(ctx context.Context, source, target string) error {
+	d.Calls = append(d.Calls, "tag_image")
+
+	image, exists := d.Images[source]
+	if !exists {
+		return errors.New("image does not exist")
+	}
+	d.Images[target] = image
+	return nil
+}
+
 // ServerVersion returns information of the docker client and server host.
 func (d *FakeDockerClient) ServerVersion(ctx context.Context) (dockertypes.Version, error) {
-	return dockertypes.Version{}, nil
+	return d.ServerVersionInfo, nil
+}
+
+// Info returns information about the docker host.
+func (d *FakeDockerClient) Info(ctx context.Context) (system.Info, error) {
+	return d.SystemInfo, nil
+}
+
+// VolumeCreate creates a volume.
+func (d *FakeDockerClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	d.Calls = append(d.Calls, "create_volume")
+	v := volume.Volume{Name: options.Name, Labels: options.Labels}
+	d.Volumes[options.Name] = v
+	return v, nil
+}
+
+// VolumeInspect returns the volume information.
+func (d *FakeDockerClient) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	d.Calls = append(d.Calls, "inspect_volume")
+	v, exists := d.Volumes[volumeID]
+	if !exists {
+		return volume.Volume{}, errdefs.NotFound(errors.New("volume does not exist"))
+	}
+	return v, nil
+}
+
+// VolumeList lists the volumes matching the label filters.
+func (d *FakeDockerClient) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	d.Calls = append(d.Calls, "list_volumes")
+	resp := volume.ListResponse{}
+	for _, v := range d.Volumes {
+		if options.Filters.MatchKVList("label", v.Labels) {
+			v := v
+			resp.Volumes = append(resp.Volumes, &v)
+		}
+	}
+	return resp, nil
+}
+
+// VolumeRemove removes a volume.
+func (d *FakeDockerClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	d.Calls = append(d.Calls, "remove_volume")
+	if _, exists := d.Volumes[volumeID]; !exists {
+		return errdefs.NotFound(errors.New("volume does not exist"))
+	}
+	delete(d.Volumes, volumeID)
+	return nil
 }
@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/go-connections/tlsconfig"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+// tlsVersions maps the TLS versions accepted by --tls-min-version to their
+// identifiers. Versions older than TLS 1.2 are not supported by the docker
+// client.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion returns the identifier of the given TLS version.
+func parseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(version), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q, valid values are: 1.2 or 1.3", version)
+	}
+	return v, nil
+}
+
+// parseCipherSuites returns the identifiers of the given cipher suites, named
+// as in the crypto/tls package (e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256).
+// Insecure cipher suites are rejected.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	secure := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite.ID
+	}
+	insecure := map[string]bool{}
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+	ids := []uint16{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		id, ok := secure[name]
+		if !ok {
+			if insecure[name] {
+				return nil, fmt.Errorf("cipher suite %s is insecure", name)
+			}
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// clientTLSConfig returns the TLS configuration used to connect to the
+// container engine. When a SPIFFE Workload API socket is configured, the
+// client certificate and the trusted CA bundle are fetched from it instead of
+// being read from files.
+func clientTLSConfig(config *api.DockerConfig) (*tls.Config, error) {
+	options := tlsconfig.Options{
+		InsecureSkipVerify: !config.TLSVerify,
+	}
+	if len(config.SPIFFEEndpointSocket) == 0 {
+		if _, err := os.Stat(config.CAFile); !os.IsNotExist(err) {
+			options.CAFile = config.CAFile
+		}
+		if _, err := os.Stat(config.CertFile); !os.IsNotExist(err) {
+			options.CertFile = config.CertFile
+		}
+		if _, err := os.Stat(config.KeyFile); !os.IsNotExist(err) {
+			options.KeyFile = config.KeyFile
+		}
+	}
+	tlsc, err := tlsconfig.Client(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.TLSMinVersion) > 0 {
+		if tlsc.MinVersion, err = parseTLSVersion(config.TLSMinVersion); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.TLSCipherSuites) > 0 {
+		if tlsc.CipherSuites, err = parseCipherSuites(config.TLSCipherSuites); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.SPIFFEEndpointSocket) > 0 {
+		svid, err := fetchX509SVID(config.SPIFFEEndpointSocket)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch the X.509 SVID from the SPIFFE Workload API at %s: %v", config.SPIFFEEndpointSocket, err)
+		}
+		log.V(2).Infof("Using the X.509 SVID of %s to connect to the container engine", svid.id)
+		tlsc.Certificates = []tls.Certificate{svid.certificate}
+		// SVIDs identify workloads by URI SAN rather than by host name, so the
+		// certificate of the engine is only verified against the trust bundle
+		tlsc.InsecureSkipVerify = true
+		tlsc.VerifyPeerCertificate = verifyWithBundle(svid.bundle)
+	}
+	return tlsc, nil
+}
+
+// verifyWithBundle returns a function verifying that the certificate presented
+// by the peer chains up to one of the certificates of the given bundle.
+func verifyWithBundle(bundle *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("the container engine presented no certificate")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         bundle,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		return err
+	}
+}
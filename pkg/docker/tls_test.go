@@ -0,0 +1,220 @@
+package docker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+func TestClientTLSConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		minVersion   string
+		cipherSuites []string
+		expectErr    bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:         "hardened",
+			minVersion:   "1.3",
+			cipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", " TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		},
+		{
+			name:       "old version",
+			minVersion: "1.0",
+			expectErr:  true,
+		},
+		{
+			name:         "insecure cipher suite",
+			cipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"},
+			expectErr:    true,
+		},
+		{
+			name:         "unknown cipher suite",
+			cipherSuites: []string{"TLS_UNKNOWN"},
+			expectErr:    true,
+		},
+	}
+	for _, tc := range tests {
+		config := &api.DockerConfig{
+			TLSVerify:       true,
+			TLSMinVersion:   tc.minVersion,
+			TLSCipherSuites: tc.cipherSuites,
+		}
+		tlsc, err := clientTLSConfig(config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if tc.minVersion == "" && tlsc.MinVersion != tls.VersionTLS12 {
+			t.Errorf("%s: expected TLS 1.2 as minimum version, got %x", tc.name, tlsc.MinVersion)
+		}
+		if tc.minVersion == "1.3" && tlsc.MinVersion != tls.VersionTLS13 {
+			t.Errorf("%s: expected TLS 1.3 as minimum version, got %x", tc.name, tlsc.MinVersion)
+		}
+		if tc.cipherSuites != nil {
+			expected := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}
+			if !reflect.DeepEqual(tlsc.CipherSuites, expected) {
+				t.Errorf("%s: expected cipher suites %v, got %v", tc.name, expected, tlsc.CipherSuites)
+			}
+		}
+	}
+}
+
+func TestSPIFFEEndpoints(t *testing.T) {
+	t.Setenv("SPIFFE_ENDPOINT_SOCKET", "unix:///run/spire/sockets/agent.sock")
+	if socket := GetDefaultDockerConfig().SPIFFEEndpointSocket; len(socket) > 0 {
+		t.Errorf("expected the SPIFFE Workload API to be only set by its flag, got %q", socket)
+	}
+
+	socket := "unix://" + filepath.Join(t.TempDir(), "agent.sock")
+	if _, err := NewEngineAPIClient(&api.DockerConfig{Endpoint: "unix:///var/run/docker.sock", SPIFFEEndpointSocket: socket}); err != nil {
+		t.Errorf("expected the SPIFFE Workload API not to be used for a unix endpoint, got %v", err)
+	}
+	if _, err := NewEngineAPIClient(&api.DockerConfig{Endpoint: "tcp://engine.example.com:2376", SPIFFEEndpointSocket: socket}); err == nil {
+		t.Errorf("expected the SVID of a tcp endpoint to be fetched from the missing SPIFFE Workload API")
+	}
+}
+
+func TestWorkloadAPIDialAddress(t *testing.T) {
+	tests := map[string][]string{
+		"unix:///run/spire/agent.sock": {"unix", "/run/spire/agent.sock"},
+		"unix:/run/spire/agent.sock":   {"unix", "/run/spire/agent.sock"},
+		"/run/spire/agent.sock":        {"unix", "/run/spire/agent.sock"},
+		"tcp://127.0.0.1:8081":         {"tcp", "127.0.0.1:8081"},
+		"http://127.0.0.1:8081":        nil,
+	}
+	for socket, expected := range tests {
+		network, address, err := workloadAPIDialAddress(socket)
+		if expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", socket)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", socket, err)
+			continue
+		}
+		if network != expected[0] || address != expected[1] {
+			t.Errorf("%s: expected %v, got %s %s", socket, expected, network, address)
+		}
+	}
+}
+
+// newTestCertificate returns a DER encoded certificate, and its PKCS8 encoded
+// key, signed by the given parent or self-signed.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) ([]byte, []byte, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der, pkcs8, key
+}
+
+// newTestX509SVIDResponse returns a protobuf encoded X509SVIDResponse holding
+// an SVID of the given ID, the DER encoded CA certificate of its bundle, and
+// the DER encoded certificate of a server signed by the CA.
+func newTestX509SVIDResponse(t *testing.T, id string) ([]byte, []byte, []byte) {
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, _, caKey := newTestCertificate(t, caTemplate, nil, nil)
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, _ := url.Parse(id)
+	svidDER, svidKey, _ := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		URIs:         []*url.URL{uri},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	serverDER, _, _ := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		URIs:         []*url.URL{uri},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+
+	svid := protowire.AppendTag(nil, 1, protowire.BytesType)
+	svid = protowire.AppendString(svid, id)
+	svid = protowire.AppendTag(svid, 2, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, svidDER)
+	svid = protowire.AppendTag(svid, 3, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, svidKey)
+	svid = protowire.AppendTag(svid, 4, protowire.BytesType)
+	svid = protowire.AppendBytes(svid, caDER)
+	svid = protowire.AppendTag(svid, 5, protowire.BytesType)
+	svid = protowire.AppendString(svid, "hint")
+
+	response := protowire.AppendTag(nil, 1, protowire.BytesType)
+	response = protowire.AppendBytes(response, svid)
+	return response, caDER, serverDER
+}
+
+func TestParseX509SVIDResponse(t *testing.T) {
+	response, _, serverDER := newTestX509SVIDResponse(t, "spiffe://example.org/s2i")
+	svid, err := parseX509SVIDResponse(response)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if svid.id != "spiffe://example.org/s2i" {
+		t.Errorf("Expected SVID of spiffe://example.org/s2i, got %s", svid.id)
+	}
+	if len(svid.certificate.Certificate) != 1 || svid.certificate.PrivateKey == nil {
+		t.Errorf("Expected a certificate and its private key, got %#v", svid.certificate)
+	}
+	verify := verifyWithBundle(svid.bundle)
+	if err := verify([][]byte{serverDER}, nil); err != nil {
+		t.Errorf("Expected the server certificate to be trusted, got %v", err)
+	}
+	if err := verify([][]byte{svid.certificate.Certificate[0]}, nil); err == nil {
+		t.Errorf("Expected a client certificate to be rejected as server certificate")
+	}
+
+	if _, err := parseX509SVIDResponse(nil); err == nil {
+		t.Errorf("Expected an error for an empty response")
+	}
+}
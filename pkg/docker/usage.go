@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	dockermessage "github.com/docker/docker/pkg/jsonmessage"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+// usageRecorder accounts the resources consumed by the containers run and the
+// images pulled through a Docker client. All its methods are safe to call on a
+// nil recorder, which the Docker instances of the clients not created by
+// NewClient have.
+type usageRecorder struct {
+	mu    sync.Mutex
+	usage api.ResourceUsage
+}
+
+// recordContainer accounts the peak memory usage and the CPU time of a
+// container.
+func (r *usageRecorder) recordContainer(peakMemory, cpuTime uint64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if peakMemory > r.usage.PeakMemoryBytes {
+		r.usage.PeakMemoryBytes = peakMemory
+	}
+	r.usage.CPUTimeNanoseconds += cpuTime
+}
+
+// recordPull accounts the bytes downloaded by an image pull.
+func (r *usageRecorder) recordPull(bytes int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.PulledBytes += bytes
+}
+
+// get returns the resources accounted so far.
+func (r *usageRecorder) get() api.ResourceUsage {
+	if r == nil {
+		return api.ResourceUsage{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage
+}
+
+// pullProgress tracks the size of the layers downloaded by an image pull from
+// its progress messages.
+type pullProgress map[string]int64
+
+// update records the size of the layer reported by the given message.
+func (p pullProgress) update(msg *dockermessage.JSONMessage) {
+	if msg.Status != "Downloading" || len(msg.ID) == 0 || msg.Progress == nil || msg.Progress.Total <= 0 {
+		return
+	}
+	p[msg.ID] = msg.Progress.Total
+}
+
+// total returns the size of the downloaded layers.
+func (p pullProgress) total() int64 {
+	var total int64
+	for _, size := range p {
+		total += size
+	}
+	return total
+}
+
+// collectStats streams the statistics of the given running container until
+// the returned function is called, and then accounts its peak memory usage
+// and CPU time. Container engines which do not report statistics are ignored.
+func (d *stiDocker) collectStats(id string) func() {
+	if d.usage == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var peakMemory, cpuTime uint64
+	go func() {
+		defer close(done)
+		stats, err := d.client.ContainerStats(ctx, id, true)
+		if err != nil {
+			log.V(2).Infof("Unable to collect the statistics of container %q: %v", id, err)
+			return
+		}
+		defer stats.Body.Close()
+		decoder := json.NewDecoder(stats.Body)
+		for {
+			var s dockercontainer.StatsResponse
+			if err := decoder.Decode(&s); err != nil {
+				return
+			}
+			// max_usage is only reported on cgroup v1 hosts
+			for _, memory := range []uint64{s.MemoryStats.Usage, s.MemoryStats.MaxUsage} {
+				if memory > peakMemory {
+					peakMemory = memory
+				}
+			}
+			if s.CPUStats.CPUUsage.TotalUsage > cpuTime {
+				cpuTime = s.CPUStats.CPUUsage.TotalUsage
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		d.usage.recordContainer(peakMemory, cpuTime)
+	}
+}
+
+// GetResourceUsage returns the resources consumed through the client, along
+// with the size and the layers of the given image if one is given.
+func (d *stiDocker) GetResourceUsage(name string) (api.ResourceUsage, error) {
+	usage := d.usage.get()
+	if len(name) == 0 {
+		return usage, nil
+	}
+	inspect, err := d.InspectImage(name)
+	if err != nil {
+		return usage, err
+	}
+	usage.ImageSizeBytes = inspect.Size
+	ctx, cancel := getDefaultContext()
+	defer cancel()
+	history, err := d.client.ImageHistory(ctx, inspect.ID)
+	if errdefs.IsNotImplemented(err) {
+		return usage, nil
+	}
+	if err != nil {
+		return usage, err
+	}
+	for _, layer := range history {
+		usage.Layers = append(usage.Layers, api.LayerUsage{CreatedBy: layer.CreatedBy, SizeBytes: layer.Size})
+	}
+	return usage, nil
+}
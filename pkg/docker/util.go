@@ -14,12 +14,14 @@ import (
 	"strings"
 
 	"github.com/distribution/reference"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/pkg/homedir"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/containerd"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
 	"github.com/openshift/source-to-image/pkg/util/user"
 )
@@ -37,6 +39,8 @@ var (
 // example in the .dockercfg file
 type AuthConfigurations struct {
 	Configs map[string]api.AuthConfig
+	// Source is the path of the file the configurations were loaded from.
+	Source string
 }
 
 // Dir returns the path to the configuration directory as specified by the DOCKER_CONFIG environment variable.
@@ -44,16 +48,35 @@ type AuthConfigurations struct {
 // Dir ignores XDG_CONFIG_HOME (same as the docker client).
 // TODO: this was copied from github.com/docker/docker/cli/config@v23.0.6
 func Dir() string {
-	configDir := os.Getenv("DOCKER_CONFIG")
+	configDir := hostpath.ExpandHome(os.Getenv("DOCKER_CONFIG"))
 	if len(configDir) == 0 {
 		configDir = filepath.Join(homedir.Get(), ".docker")
 	}
 	return configDir
 }
 
+// DefaultDockerCfgPath returns the Docker configuration file providing the
+// registry credentials: the config.json file of Dir(), ~/.docker/config.json,
+// or the legacy ~/.dockercfg file, whichever exists first. The config.json
+// file of Dir() is returned when none of them exists.
+func DefaultDockerCfgPath() string {
+	paths := []string{
+		filepath.Join(Dir(), "config.json"),
+		filepath.Join(homedir.Get(), ".docker", "config.json"),
+		filepath.Join(homedir.Get(), ".dockercfg"),
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return paths[0]
+}
+
 type dockerConfig struct {
-	Auth  string `json:"auth"`
-	Email string `json:"email"`
+	Auth          string `json:"auth"`
+	Email         string `json:"email"`
+	IdentityToken string `json:"identitytoken"`
 }
 
 const (
@@ -77,18 +100,88 @@ func GetImageRegistryAuth(auths *AuthConfigurations, imageName string) api.AuthC
 		return api.AuthConfig{}
 	}
 	if ref.Registry != "" {
-		if auth, ok := auths.Configs[ref.Registry]; ok {
-			log.V(5).Infof("Using %s[%s] credentials for pulling %s", auth.Email, ref.Registry, imageName)
-			return auth
+		// the credentials of a repository, or of its namespace, take precedence
+		// over the ones of its registry
+		scopes := []string{ref.Registry}
+		if ref.Namespace != "" {
+			scopes = append(scopes, ref.Registry+"/"+ref.Namespace, ref.Registry+"/"+ref.Namespace+"/"+ref.Name)
+		} else {
+			scopes = append(scopes, ref.Registry+"/"+ref.Name)
+		}
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if auth, ok := auths.Configs[normalizeRegistry(scopes[i])]; ok {
+				log.V(5).Infof("Using %s[%s] credentials for pulling %s", auth.Email, scopes[i], imageName)
+				auth.Source = auths.Source
+				return auth
+			}
 		}
 	}
 	if auth, ok := auths.Configs[defaultRegistry]; ok {
 		log.V(5).Infof("Using %s credentials for pulling %s", auth.Email, imageName)
+		auth.Source = auths.Source
 		return auth
 	}
 	return api.AuthConfig{}
 }
 
+// OverrideImageRegistryAuth returns the credentials given on the command line
+// by flag for the image, when they are set and their registry is the one of
+// the image, or auth otherwise.
+func OverrideImageRegistryAuth(auth api.AuthConfig, credentials api.RegistryCredentials, imageName, flag string) api.AuthConfig {
+	if len(credentials.Username) == 0 {
+		return auth
+	}
+	serverAddress := ""
+	if len(credentials.Registry) > 0 {
+		ref, err := parseNamedDockerImageReference(imageName)
+		if err != nil {
+			log.V(0).Infof("error: Failed to parse docker reference %s", imageName)
+			return auth
+		}
+		registry := normalizeRegistry(credentials.Registry)
+		if registry != normalizeRegistry(ref.Registry) {
+			return auth
+		}
+		serverAddress = registry
+	}
+	log.V(5).Infof("Using the %s credentials given on the command line for pulling %s", credentials.Username, imageName)
+	return api.AuthConfig{
+		Username:      credentials.Username,
+		Password:      credentials.Password,
+		ServerAddress: serverAddress,
+		Source:        flag,
+	}
+}
+
+// describeCredentials describes where the credentials come from, for the
+// errors reported when the registry rejects them, or returns an empty string
+// when there are no credentials.
+func describeCredentials(auth api.AuthConfig) string {
+	switch {
+	case len(auth.Username) == 0 && len(auth.Password) == 0 && len(auth.IdentityToken) == 0:
+		return ""
+	case len(auth.Source) == 0:
+		return fmt.Sprintf("the credentials of %s", auth.Username)
+	case len(auth.Key) == 0:
+		return fmt.Sprintf("the credentials of %s given by %s", auth.Username, auth.Source)
+	}
+	return fmt.Sprintf("the credentials of %s for %q in %s", auth.Username, auth.Key, auth.Source)
+}
+
+// hasCredentials returns true if the credentials are not empty.
+func hasCredentials(auth registry.AuthConfig) bool {
+	return len(auth.Username) > 0 || len(auth.Password) > 0 || len(auth.IdentityToken) > 0
+}
+
+// imageRegistry returns the registry hosting the image.
+func imageRegistry(name string) string {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return ""
+	}
+	return reference.Domain(named)
+}
+
 // namedDockerImageReference points to a Docker image.
 type namedDockerImageReference struct {
 	Registry  string
@@ -141,6 +234,9 @@ func LoadImageRegistryAuth(dockerCfg io.Reader) *AuthConfigurations {
 		log.V(0).Infof("error: Unable to load docker config: %v", err)
 		return nil
 	}
+	if f, ok := dockerCfg.(*os.File); ok {
+		auths.Source = f.Name()
+	}
 	return auths
 }
 
@@ -190,29 +286,62 @@ func authConfigs(confs map[string]dockerConfig) (*AuthConfigurations, error) {
 		Configs: make(map[string]api.AuthConfig),
 	}
 	for reg, conf := range confs {
-		if len(conf.Auth) == 0 {
+		if len(conf.Auth) == 0 && len(conf.IdentityToken) == 0 {
 			continue
 		}
-		data, err := base64.StdEncoding.DecodeString(conf.Auth)
-		if err != nil {
-			return nil, err
+		auth := api.AuthConfig{
+			Email:         conf.Email,
+			IdentityToken: conf.IdentityToken,
+			Key:           reg,
 		}
-		userpass := strings.SplitN(string(data), ":", 2)
-		if len(userpass) != 2 {
-			return nil, fmt.Errorf("cannot parse username/password from %s", userpass)
+		if len(conf.Auth) > 0 {
+			data, err := base64.StdEncoding.DecodeString(conf.Auth)
+			if err != nil {
+				return nil, err
+			}
+			userpass := strings.SplitN(string(data), ":", 2)
+			if len(userpass) != 2 {
+				return nil, fmt.Errorf("cannot parse username/password from %s", userpass)
+			}
+			auth.Username, auth.Password = userpass[0], userpass[1]
 		}
-		c.Configs[reg] = api.AuthConfig{
-			Email:         conf.Email,
-			Username:      userpass[0],
-			Password:      userpass[1],
-			ServerAddress: reg,
+		key := normalizeRegistry(reg)
+		auth.ServerAddress = strings.SplitN(key, "/", 2)[0]
+		if key == defaultRegistry || strings.HasPrefix(key, "docker.io/") {
+			auth.ServerAddress = defaultRegistry
+		}
+		if _, ok := c.Configs[key]; ok && key != reg {
+			// the entry written without a scheme or a path takes precedence
+			continue
 		}
+		c.Configs[key] = auth
 	}
 	return c, nil
 }
 
 // end block of 3 methods borrowed from go-dockerclient
 
+// normalizeRegistry returns the registry, or the repository, of a key of the
+// Docker configuration file, which may be written as a URL, with a scheme and
+// an API version path, e.g. https://registry.redhat.io/v2/. Docker Hub keys
+// are normalized to the key of its index server.
+func normalizeRegistry(key string) string {
+	r := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	r = strings.TrimSuffix(r, "/")
+	for _, suffix := range []string{"/v1", "/v2"} {
+		r = strings.TrimSuffix(r, suffix)
+	}
+	registry := strings.SplitN(r, "/", 2)[0]
+	switch registry {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		if registry == r {
+			return defaultRegistry
+		}
+		return "docker.io" + strings.TrimPrefix(r, registry)
+	}
+	return r
+}
+
 // StreamContainerIO starts a goroutine to take data from the reader and
 // redirect it to the log function (typically we pass in glog.Error for stderr
 // and glog.Info for stdout. The caller should wrap glog functions in a closure
@@ -278,6 +407,8 @@ func PullImage(name string, d Docker, policy api.PullPolicy) (*PullResult, error
 	case api.PullAlways:
 		log.Infof("Pulling image %q ...", name)
 		image, err = d.PullImage(name)
+	case api.PullIfChanged:
+		image, err = d.CheckAndPullChangedImage(name)
 	case api.PullNever:
 		log.Infof("Checking if image %q is available locally ...", name)
 		image, err = d.CheckImage(name)
@@ -428,14 +559,14 @@ func GetRuntimeImage(docker Docker, config *api.Config) error {
 	return err
 }
 
-// GetDefaultDockerConfig checks relevant Docker environment variables to
-// provide defaults for our command line flags
+// GetDefaultDockerConfig checks relevant Docker environment variables and the
+// rootless Docker and Podman sockets to provide defaults for our command line
+// flags
 func GetDefaultDockerConfig() *api.DockerConfig {
 	cfg := &api.DockerConfig{}
 
-	if cfg.Endpoint = os.Getenv("DOCKER_HOST"); cfg.Endpoint == "" {
-		cfg.Endpoint = client.DefaultDockerHost
-	}
+	endpoint, _ := defaultEndpoint()
+	cfg.Endpoint = endpoint.Host
 
 	certPath := os.Getenv("DOCKER_CERT_PATH")
 	if certPath == "" {
@@ -454,6 +585,19 @@ func GetDefaultDockerConfig() *api.DockerConfig {
 		cfg.UseTLS = true
 	}
 
+	// the TLS material of Docker contexts is used unless overridden
+	if len(endpoint.tlsDir) > 0 && os.Getenv("DOCKER_CERT_PATH") == "" {
+		applyContextEndpoint(cfg, endpoint)
+	}
+
+	if cfg.ContainerdAddress = os.Getenv("CONTAINERD_ADDRESS"); cfg.ContainerdAddress == "" {
+		cfg.ContainerdAddress = containerd.DefaultAddress
+	}
+	if cfg.ContainerdNamespace = os.Getenv("CONTAINERD_NAMESPACE"); cfg.ContainerdNamespace == "" {
+		cfg.ContainerdNamespace = containerd.DefaultNamespace
+	}
+	cfg.RegistriesConf = os.Getenv("CONTAINERS_REGISTRIES_CONF")
+
 	return cfg
 }
 
@@ -1,7 +1,13 @@
 package docker
 
 import (
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/openshift/source-to-image/pkg/api"
@@ -199,6 +205,7 @@ func TestCheckAllowedUser(t *testing.T) {
 }
 
 func TestGetDefaultDockerConfig(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
 	tests := []struct {
 		envHost           string
 		envCertPath       string
@@ -232,6 +239,8 @@ func TestGetDefaultDockerConfig(t *testing.T) {
 			expectedTLS:       false,
 		},
 	}
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("XDG_RUNTIME_DIR", "")
 	for _, tc := range tests {
 		oldHost := os.Getenv("DOCKER_HOST")
 		oldCertPath := os.Getenv("DOCKER_CERT_PATH")
@@ -262,6 +271,153 @@ func TestGetDefaultDockerConfig(t *testing.T) {
 	}
 }
 
+func TestDefaultEndpoint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("rootless sockets are not used on Windows")
+	}
+	runtimeDir, err := ioutil.TempDir("", "s2i-runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(runtimeDir)
+	configDir, err := ioutil.TempDir("", "s2i-docker-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+	t.Setenv("DOCKER_CONFIG", configDir)
+
+	meta := contextMetadataPath("remote")
+	if err := os.MkdirAll(filepath.Dir(meta), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(meta, []byte(`{"Name":"remote","Endpoints":{"docker":{"Host":"ssh://builder@remote","SkipTLSVerify":false}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	listen := func(path string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Skipf("unix sockets are not supported: %v", err)
+		}
+		t.Cleanup(func() { l.Close() })
+	}
+
+	tests := []struct {
+		name           string
+		host           string
+		context        string
+		sockets        []string
+		expectedHost   string
+		expectedSource string
+	}{
+		{
+			name:           "default",
+			expectedHost:   "unix:///var/run/docker.sock",
+			expectedSource: "default Docker socket",
+		},
+		{
+			name:           "podman",
+			sockets:        []string{"podman/podman.sock"},
+			expectedHost:   "unix://" + filepath.Join(runtimeDir, "podman", "podman.sock"),
+			expectedSource: "rootless Podman socket",
+		},
+		{
+			name:           "rootless docker over podman",
+			sockets:        []string{"docker.sock"},
+			expectedHost:   "unix://" + filepath.Join(runtimeDir, "docker.sock"),
+			expectedSource: "rootless Docker socket",
+		},
+		{
+			name:           "context over sockets",
+			context:        "remote",
+			expectedHost:   "ssh://builder@remote",
+			expectedSource: `docker context "remote"`,
+		},
+		{
+			name:           "missing context",
+			context:        "missing",
+			expectedHost:   "unix://" + filepath.Join(runtimeDir, "docker.sock"),
+			expectedSource: "rootless Docker socket",
+		},
+		{
+			name:           "DOCKER_HOST over context",
+			host:           "tcp://docker:2376",
+			context:        "remote",
+			expectedHost:   "tcp://docker:2376",
+			expectedSource: "DOCKER_HOST environment variable",
+		},
+	}
+	for _, tc := range tests {
+		t.Setenv("DOCKER_HOST", tc.host)
+		t.Setenv("DOCKER_CONTEXT", tc.context)
+		t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+		for _, socket := range tc.sockets {
+			listen(filepath.Join(runtimeDir, socket))
+		}
+		endpoint, source := defaultEndpoint()
+		if endpoint.Host != tc.expectedHost || source != tc.expectedSource {
+			t.Errorf("%s: expected %q from the %s, got %q from the %s", tc.name, tc.expectedHost, tc.expectedSource, endpoint.Host, source)
+		}
+	}
+}
+
+func TestUseDockerContext(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "s2i-docker-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	meta := contextMetadataPath("secure")
+	if err := os.MkdirAll(filepath.Dir(meta), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(meta, []byte(`{"Name":"secure","Endpoints":{"docker":{"Host":"tcp://remote:2376","SkipTLSVerify":false}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tlsDir := contextTLSDir("secure")
+	if err := os.MkdirAll(tlsDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &api.DockerConfig{Endpoint: "unix:///var/run/docker.sock"}
+	if err := UseDockerContext(cfg, "secure"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := &api.DockerConfig{
+		Endpoint:  "tcp://remote:2376",
+		CAFile:    filepath.Join(tlsDir, "ca.pem"),
+		CertFile:  filepath.Join(tlsDir, "cert.pem"),
+		KeyFile:   filepath.Join(tlsDir, "key.pem"),
+		UseTLS:    true,
+		TLSVerify: true,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, cfg)
+	}
+
+	if err := UseDockerContext(cfg, "default"); err != nil || cfg.Endpoint != "unix:///var/run/docker.sock" {
+		t.Errorf("Unexpected endpoint %q for the default context: %v", cfg.Endpoint, err)
+	}
+	if err := UseDockerContext(cfg, "missing"); err == nil {
+		t.Errorf("Expected an error for a missing context")
+	}
+
+	// the current context of the docker CLI is used by default
+	if err := ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"secure"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := GetDefaultDockerConfig(); cfg.Endpoint != "tcp://remote:2376" || cfg.CAFile != filepath.Join(tlsDir, "ca.pem") || !cfg.TLSVerify {
+		t.Errorf("Expected the current docker context to be used, got %#v", cfg)
+	}
+}
+
 func TestGetAssembleUser(t *testing.T) {
 	testCases := []struct {
 		name              string
@@ -336,3 +492,198 @@ func TestGetAssembleUser(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckPullDiskSpace(t *testing.T) {
+	root := t.TempDir()
+	testCases := []struct {
+		name     string
+		docker   *FakeDocker
+		expected bool
+	}{
+		{
+			name:   "image fits",
+			docker: &FakeDocker{DataRootResult: root, PullSizeResult: 1024},
+		},
+		{
+			name:     "image too large",
+			docker:   &FakeDocker{DataRootResult: root, PullSizeResult: 1 << 61},
+			expected: true,
+		},
+		{
+			name:   "remote daemon",
+			docker: &FakeDocker{DataRootResult: filepath.Join(root, "missing"), PullSizeResult: 1 << 61},
+		},
+		{
+			name:   "unknown data root",
+			docker: &FakeDocker{PullSizeResult: 1 << 61},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckPullDiskSpace(tc.docker, "image")
+			if (err != nil) != tc.expected {
+				t.Errorf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestDefaultDockerCfgPath(t *testing.T) {
+	home := t.TempDir()
+	configDir := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DOCKER_CONFIG", configDir)
+
+	if path := DefaultDockerCfgPath(); path != filepath.Join(configDir, "config.json") {
+		t.Errorf("expected the config.json file of $DOCKER_CONFIG when none exists, got %q", path)
+	}
+	legacy := filepath.Join(home, ".dockercfg")
+	if err := ioutil.WriteFile(legacy, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path := DefaultDockerCfgPath(); path != legacy {
+		t.Errorf("expected %q, got %q", legacy, path)
+	}
+	if err := os.Mkdir(filepath.Join(home, ".docker"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	homeConfig := filepath.Join(home, ".docker", "config.json")
+	if err := ioutil.WriteFile(homeConfig, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path := DefaultDockerCfgPath(); path != homeConfig {
+		t.Errorf("expected %q, got %q", homeConfig, path)
+	}
+	config := filepath.Join(configDir, "config.json")
+	if err := ioutil.WriteFile(config, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if path := DefaultDockerCfgPath(); path != config {
+		t.Errorf("expected %q, got %q", config, path)
+	}
+}
+
+func TestLoadImageRegistryAuth(t *testing.T) {
+	// "user:pass" and "<token>:" encoded in base64
+	config := `{
+	"auths": {
+		"https://registry.redhat.io/v2/": {"auth": "dXNlcjpwYXNz", "email": "user@example.com"},
+		"https://index.docker.io/v1/": {"auth": "aHViOnBhc3M="},
+		"myregistry.azurecr.io": {"auth": "PHRva2VuPjo=", "identitytoken": "refresh-token"},
+		"quay.io": {"auth": "cXVheTpwYXNz"},
+		"quay.io/team": {"auth": "dGVhbTpwYXNz"}
+	}
+}`
+	auths := LoadImageRegistryAuth(strings.NewReader(config))
+	tests := []struct {
+		image    string
+		expected api.AuthConfig
+	}{
+		{
+			image:    "registry.redhat.io/ubi8/python-39",
+			expected: api.AuthConfig{Username: "user", Password: "pass", Email: "user@example.com", ServerAddress: "registry.redhat.io", Key: "https://registry.redhat.io/v2/"},
+		},
+		{
+			image:    "centos/ruby-25-centos7",
+			expected: api.AuthConfig{Username: "hub", Password: "pass", ServerAddress: defaultRegistry, Key: "https://index.docker.io/v1/"},
+		},
+		{
+			image:    "myregistry.azurecr.io/app:latest",
+			expected: api.AuthConfig{Username: "<token>", IdentityToken: "refresh-token", ServerAddress: "myregistry.azurecr.io", Key: "myregistry.azurecr.io"},
+		},
+		{
+			image:    "quay.io/team/builder",
+			expected: api.AuthConfig{Username: "team", Password: "pass", ServerAddress: "quay.io", Key: "quay.io/team"},
+		},
+		{
+			image:    "quay.io/other/builder",
+			expected: api.AuthConfig{Username: "quay", Password: "pass", ServerAddress: "quay.io", Key: "quay.io"},
+		},
+	}
+	for _, tc := range tests {
+		if auth := GetImageRegistryAuth(auths, tc.image); !reflect.DeepEqual(auth, tc.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tc.image, tc.expected, auth)
+		}
+	}
+
+	// the legacy .dockercfg file has no auths key
+	legacy := LoadImageRegistryAuth(strings.NewReader(`{"https://registry.redhat.io": {"auth": "dXNlcjpwYXNz"}}`))
+	if auth := GetImageRegistryAuth(legacy, "registry.redhat.io/ubi8/python-39"); auth.Username != "user" {
+		t.Errorf("expected the credentials of the legacy file, got %#v", auth)
+	}
+}
+
+func TestNormalizeRegistry(t *testing.T) {
+	tests := map[string]string{
+		"registry.redhat.io":             "registry.redhat.io",
+		"https://registry.redhat.io":     "registry.redhat.io",
+		"https://registry.redhat.io/v2/": "registry.redhat.io",
+		"http://localhost:5000/v1/":      "localhost:5000",
+		"https://index.docker.io/v1/":    defaultRegistry,
+		"docker.io":                      defaultRegistry,
+		"registry-1.docker.io":           defaultRegistry,
+		"docker.io/library":              "docker.io/library",
+		"quay.io/team/":                  "quay.io/team",
+	}
+	for key, expected := range tests {
+		if actual := normalizeRegistry(key); actual != expected {
+			t.Errorf("%s: expected %q, got %q", key, expected, actual)
+		}
+	}
+}
+
+func TestOverrideImageRegistryAuth(t *testing.T) {
+	fromFile := api.AuthConfig{Username: "file", Password: "pass", ServerAddress: "registry.redhat.io"}
+	tests := []struct {
+		credentials api.RegistryCredentials
+		image       string
+		expected    api.AuthConfig
+	}{
+		{
+			image:    "registry.redhat.io/ubi8/python-39",
+			expected: fromFile,
+		},
+		{
+			credentials: api.RegistryCredentials{Username: "ci", Password: "secret"},
+			image:       "registry.redhat.io/ubi8/python-39",
+			expected:    api.AuthConfig{Username: "ci", Password: "secret", Source: "--pull-auth"},
+		},
+		{
+			credentials: api.RegistryCredentials{Username: "ci", Password: "secret", Registry: "https://registry.redhat.io"},
+			image:       "registry.redhat.io/ubi8/python-39",
+			expected:    api.AuthConfig{Username: "ci", Password: "secret", ServerAddress: "registry.redhat.io", Source: "--pull-auth"},
+		},
+		{
+			credentials: api.RegistryCredentials{Username: "ci", Password: "secret", Registry: "quay.io"},
+			image:       "registry.redhat.io/ubi8/python-39",
+			expected:    fromFile,
+		},
+		{
+			credentials: api.RegistryCredentials{Username: "ci", Password: "secret", Registry: "docker.io"},
+			image:       "centos/ruby-25-centos7",
+			expected:    api.AuthConfig{Username: "ci", Password: "secret", ServerAddress: defaultRegistry, Source: "--pull-auth"},
+		},
+	}
+	for _, tc := range tests {
+		if auth := OverrideImageRegistryAuth(fromFile, tc.credentials, tc.image, "--pull-auth"); !reflect.DeepEqual(auth, tc.expected) {
+			t.Errorf("%s with %s: expected %#v, got %#v", tc.image, tc.credentials.String(), tc.expected, auth)
+		}
+	}
+}
+
+func TestDescribeCredentials(t *testing.T) {
+	tests := []struct {
+		auth     api.AuthConfig
+		expected string
+	}{
+		{auth: api.AuthConfig{}, expected: ""},
+		{auth: api.AuthConfig{Username: "ci", Password: "secret"}, expected: "the credentials of ci"},
+		{auth: api.AuthConfig{Username: "ci", Password: "secret", Source: "--pull-auth"}, expected: "the credentials of ci given by --pull-auth"},
+		{auth: api.AuthConfig{Username: "ci", Password: "secret", Source: "config.json", Key: "quay.io"}, expected: `the credentials of ci for "quay.io" in config.json`},
+	}
+	for _, tc := range tests {
+		if actual := describeCredentials(tc.auth); actual != tc.expected {
+			t.Errorf("%#v: expected %q, got %q", tc.auth, tc.expected, actual)
+		}
+	}
+}
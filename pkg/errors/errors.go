@@ -1,8 +1,10 @@
 package errors
 
 import (
+	goerrors "errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/openshift/source-to-image/pkg/api/constants"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
@@ -26,8 +28,52 @@ const (
 	SourcePathError
 	UserNotAllowedError
 	EmptyGitRepositoryError
+	SymlinkPolicyError
+	CommitError
+	AuthenticationError
+	InsufficientDiskSpaceError
+	QuotaExceededError
+	OnBuildNotAllowedError
 )
 
+// Exit codes of the s2i commands, telling the classes of failures apart so that
+// scripts can branch on the kind of failure instead of parsing the logs.
+const (
+	// ExitCodeFailure is the exit code of the failures of any other class.
+	ExitCodeFailure = 1
+	// ExitCodeValidation is the exit code when the configuration is invalid.
+	ExitCodeValidation = 2
+	// ExitCodeAuthentication is the exit code when the registry rejects the
+	// credentials.
+	ExitCodeAuthentication = 3
+	// ExitCodePull is the exit code when an image cannot be pulled.
+	ExitCodePull = 4
+	// ExitCodeClone is the exit code when the sources cannot be fetched.
+	ExitCodeClone = 5
+	// ExitCodeAssemble is the exit code when the assemble script fails.
+	ExitCodeAssemble = 6
+	// ExitCodeCommit is the exit code when the image cannot be committed.
+	ExitCodeCommit = 7
+	// ExitCodeInterrupted is the exit code when the build is interrupted by a
+	// termination signal.
+	ExitCodeInterrupted = 8
+	// ExitCodeQuotaExceeded is the exit code when the build exceeds its maximum
+	// duration or the image its maximum size.
+	ExitCodeQuotaExceeded = 9
+)
+
+// exitCodes are the exit codes of the errors, by error code.
+var exitCodes = map[int]int{
+	InspectImageError:       ExitCodePull,
+	PullImageError:          ExitCodePull,
+	AuthenticationError:     ExitCodeAuthentication,
+	SourcePathError:         ExitCodeClone,
+	EmptyGitRepositoryError: ExitCodeClone,
+	AssembleError:           ExitCodeAssemble,
+	CommitError:             ExitCodeCommit,
+	QuotaExceededError:      ExitCodeQuotaExceeded,
+}
+
 // Error represents an error thrown during S2I execution
 type Error struct {
 	Message    string
@@ -46,6 +92,12 @@ type ContainerError struct {
 	ExitCode   int
 }
 
+// ExitCodeError is an error of a given class of failures.
+type ExitCodeError struct {
+	Err      error
+	ExitCode int
+}
+
 // Error returns a string for a given error
 func (s Error) Error() string {
 	return s.Message
@@ -56,6 +108,41 @@ func (s ContainerError) Error() string {
 	return s.Message
 }
 
+// Error returns a string for the given error
+func (s ExitCodeError) Error() string {
+	return s.Err.Error()
+}
+
+// Unwrap returns the classified error
+func (s ExitCodeError) Unwrap() error {
+	return s.Err
+}
+
+// NewExitCodeError returns a new error which classifies err with the given exit
+// code
+func NewExitCodeError(exitCode int, err error) error {
+	return ExitCodeError{
+		Err:      err,
+		ExitCode: exitCode,
+	}
+}
+
+// ExitCode returns the exit code of the class of failures of err, or of the
+// errors it wraps.
+func ExitCode(err error) int {
+	var exitCodeErr ExitCodeError
+	if goerrors.As(err, &exitCodeErr) {
+		return exitCodeErr.ExitCode
+	}
+	var e Error
+	if goerrors.As(err, &e) {
+		if exitCode, ok := exitCodes[e.ErrorCode]; ok {
+			return exitCode
+		}
+	}
+	return ExitCodeFailure
+}
+
 // NewInspectImageError returns a new error which indicates there was a problem
 // inspecting the image
 func NewInspectImageError(name string, err error) error {
@@ -78,6 +165,26 @@ func NewPullImageError(name string, err error) error {
 	}
 }
 
+// NewOfflineImageError returns a new error which indicates that the image is
+// not available locally, nor in the image store when there is one, and that
+// pulling it is forbidden by --offline
+func NewOfflineImageError(name, store string, err error) error {
+	if len(store) == 0 {
+		return Error{
+			Message:    fmt.Sprintf("unable to get %s: the image is not available locally and --offline forbids pulling it", name),
+			Details:    err,
+			ErrorCode:  PullImageError,
+			Suggestion: "load the image with docker load, or give an OCI image layout holding it with --image-store",
+		}
+	}
+	return Error{
+		Message:    fmt.Sprintf("unable to get %s: the image is not available locally nor in the image store %s, and --offline forbids pulling it", name, store),
+		Details:    err,
+		ErrorCode:  PullImageError,
+		Suggestion: fmt.Sprintf("add the image to the image store, e.g. with skopeo copy docker://%s oci:%s:%s", name, store, name),
+	}
+}
+
 // NewSaveArtifactsError returns a new error which indicates there was a problem
 // calling save-artifacts script
 func NewSaveArtifactsError(name, output string, err error) error {
@@ -100,6 +207,38 @@ func NewAssembleError(name, output string, err error) error {
 	}
 }
 
+// NewAuthenticationError returns a new error which indicates the registry
+// rejected the credentials used to pull the image
+func NewAuthenticationError(name string, err error) error {
+	return Error{
+		Message:    fmt.Sprintf("unable to get %s: authentication failed", name),
+		Details:    err,
+		ErrorCode:  AuthenticationError,
+		Suggestion: "check the credentials of the registry in the Docker configuration file, or that the image exists",
+	}
+}
+
+// NewRegistryAuthenticationError returns a new error which indicates the
+// registry rejected the given credentials, described by where they come from,
+// as well as anonymous pulls of the image. The credentials are empty when the
+// image was only pulled anonymously.
+func NewRegistryAuthenticationError(name, credentials, registry string, err error) error {
+	if len(credentials) == 0 {
+		return Error{
+			Message:    fmt.Sprintf("unable to get %s: authentication required, no credentials were found for %s", name, registry),
+			Details:    err,
+			ErrorCode:  AuthenticationError,
+			Suggestion: fmt.Sprintf("log in to %s with docker login, or give the credentials with --pull-auth, --runtime-auth or --incremental-auth, and check that the image exists", registry),
+		}
+	}
+	return Error{
+		Message:    fmt.Sprintf("unable to get %s: authentication failed with %s", name, credentials),
+		Details:    err,
+		ErrorCode:  AuthenticationError,
+		Suggestion: fmt.Sprintf("renew the credentials with docker login %s, or give them with --pull-auth, --runtime-auth or --incremental-auth, and check that they grant access to the image and that it exists", registry),
+	}
+}
+
 // NewWorkDirError returns a new error which indicates there was a problem
 // when creating working directory
 func NewWorkDirError(dir string, err error) error {
@@ -128,7 +267,7 @@ func NewCommitError(name string, err error) error {
 	return Error{
 		Message:    fmt.Sprintf("building %s failed when committing the image due to error: %v", name, err),
 		Details:    err,
-		ErrorCode:  BuildError,
+		ErrorCode:  CommitError,
 		Suggestion: "check the build output for errors",
 	}
 }
@@ -257,6 +396,25 @@ func NewAssembleUserNotAllowedError(image string, usesConfig bool) error {
 	}
 }
 
+// NewOnBuildNotAllowedError returns a new error that indicates that the build
+// could not run because it would execute ONBUILD instructions of the image
+// which are not allowed: without confirmation when instruction is empty, or
+// the given instruction which is not in the allowlist.
+func NewOnBuildNotAllowedError(image, instruction string) error {
+	if len(instruction) == 0 {
+		return Error{
+			Message:    fmt.Sprintf("image %q has ONBUILD instructions, which are executed only with confirmation", image),
+			ErrorCode:  OnBuildNotAllowedError,
+			Suggestion: "review the ONBUILD instructions of the image and build with --allow-onbuild to execute them",
+		}
+	}
+	return Error{
+		Message:    fmt.Sprintf("image %q has the ONBUILD instruction %q, which does not match the ONBUILD allowlist", image, instruction),
+		ErrorCode:  OnBuildNotAllowedError,
+		Suggestion: "review the ONBUILD instruction and add a pattern matching it with --onbuild-allowlist, or use another builder image",
+	}
+}
+
 // NewEmptyGitRepositoryError returns a new error which indicates that a found
 // .git directory has no tracking information, e.g. if the user simply used
 // `git init` and forgot about the repository
@@ -268,6 +426,46 @@ func NewEmptyGitRepositoryError(source string) error {
 	}
 }
 
+// NewSymlinkPolicyError returns a new error which indicates that a symbolic
+// link in the source tree cannot be archived with the configured symlink policy
+func NewSymlinkPolicyError(path, target string) error {
+	return Error{
+		Message:    fmt.Sprintf("symbolic link %q points to %q, which is outside of the source tree", path, target),
+		ErrorCode:  SymlinkPolicyError,
+		Suggestion: "remove the symbolic link, replace it with the file it points to, or use --symlink-policy=preserve to keep it as-is",
+	}
+}
+
+// NewInsufficientDiskSpaceError returns a new error which indicates that the
+// file system holding path lacks the space or inodes the build needs
+func NewInsufficientDiskSpaceError(path, resource string, required, available uint64) error {
+	return Error{
+		Message:    fmt.Sprintf("not enough %s on the file system holding %q: the build needs %d, only %d are available", resource, path, required, available),
+		ErrorCode:  InsufficientDiskSpaceError,
+		Suggestion: "free some disk space, point the build to another file system, or use --skip-disk-check to skip this check",
+	}
+}
+
+// NewBuildDurationExceededError returns a new error which indicates that the
+// build ran longer than its maximum duration
+func NewBuildDurationExceededError(limit time.Duration) error {
+	return Error{
+		Message:    fmt.Sprintf("the build exceeded its maximum duration of %s", limit),
+		ErrorCode:  QuotaExceededError,
+		Suggestion: "check why the build is slower than usual, or raise --max-build-duration",
+	}
+}
+
+// NewOutputSizeExceededError returns a new error which indicates that the layer
+// committed by the build is larger than its maximum size
+func NewOutputSizeExceededError(size, limit int64) error {
+	return Error{
+		Message:    fmt.Sprintf("the layer committed by the build takes %d bytes, more than the maximum of %d bytes", size, limit),
+		ErrorCode:  QuotaExceededError,
+		Suggestion: "remove the caches and the build dependencies from the image in the assemble script, or raise --max-output-size",
+	}
+}
+
 // log is a placeholder until the builders pass an output stream down
 // client facing libraries should not be using log
 var log = utillog.StderrLog
@@ -276,13 +474,16 @@ var log = utillog.StderrLog
 // 1. if the input error is nil, the function does nothing but return.
 // 2. if the input error is a kind of Error which is thrown during S2I execution,
 // the function handle it with Suggestion and Details.
-// 3. if the input error is a kind of system Error which is unknown, the function exit with 1.
+// 3. if the input error is a kind of system Error which is unknown, the function only logs it.
+// In both cases, the function exits with the exit code of the class of failures of the error.
 func CheckError(err error) {
 	if err == nil {
 		return
 	}
 
-	if e, ok := err.(Error); ok {
+	exitCode := ExitCode(err)
+	var e Error
+	if goerrors.As(err, &e) {
 		log.Errorf("An error occurred: %v", e)
 		log.Errorf("Suggested solution: %v", e.Suggestion)
 		if e.Details != nil {
@@ -291,11 +492,10 @@ func CheckError(err error) {
 		log.Error("If the problem persists consult the docs at https://github.com/openshift/source-to-image/tree/master/docs. " +
 			"Eventually reach us on freenode #openshift or file an issue at https://github.com/openshift/source-to-image/issues " +
 			"providing us with a log from your build using log output level 3.")
-		os.Exit(e.ErrorCode)
 	} else {
 		log.Errorf("An error occurred: %v", err)
-		os.Exit(1)
 	}
+	os.Exit(exitCode)
 }
 
 // UsageError checks command usage error.
@@ -0,0 +1,30 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{NewPullImageError("builder", nil), ExitCodePull},
+		{NewOfflineImageError("builder", "/srv/images", nil), ExitCodePull},
+		{NewAuthenticationError("builder", nil), ExitCodeAuthentication},
+		{NewRegistryAuthenticationError("builder", "", "docker.io", nil), ExitCodeAuthentication},
+		{NewEmptyGitRepositoryError("."), ExitCodeClone},
+		{NewAssembleError("app", "", nil), ExitCodeAssemble},
+		{NewCommitError("app", fmt.Errorf("no space left on device")), ExitCodeCommit},
+		{NewTarTimeoutError(), ExitCodeFailure},
+		{NewContainerError("builder", 1, ""), ExitCodeFailure},
+		{NewExitCodeError(ExitCodeAssemble, NewContainerError("builder", 1, "")), ExitCodeAssemble},
+		{fmt.Errorf("unknown"), ExitCodeFailure},
+	}
+	for _, tc := range tests {
+		if exitCode := ExitCode(tc.err); exitCode != tc.expected {
+			t.Errorf("Expected exit code %d for %v, got %d", tc.expected, tc.err, exitCode)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package extract copies build artifacts out of the images produced by S2I.
+package extract
@@ -0,0 +1,108 @@
+package extract
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// Stdout is the output writing the tar archive to the standard output.
+const Stdout = "-"
+
+// Options are the options of an extraction.
+type Options struct {
+	// Image is the image the artifacts are copied from.
+	Image string
+	// Paths are the paths of the artifacts in the image.
+	Paths []string
+	// Output is the directory the artifacts are copied to, or the tar archive
+	// they are written to when it ends with .tar, or is Stdout.
+	Output string
+	// PullPolicy specifies when to pull the image.
+	PullPolicy api.PullPolicy
+}
+
+// IsArchive returns true when the output is a tar archive rather than a
+// directory.
+func IsArchive(output string) bool {
+	return output == Stdout || strings.HasSuffix(output, ".tar")
+}
+
+// Extract copies the paths of the image to the output, from a container
+// created, but never started, from the image. As with docker cp, the content
+// of each path is copied under its base name.
+func Extract(d docker.Docker, t s2itar.Tar, opts Options, stdout io.Writer) error {
+	if len(opts.Paths) == 0 {
+		return fmt.Errorf("at least one path must be extracted")
+	}
+	policy := opts.PullPolicy
+	if len(policy) == 0 {
+		policy = api.PullIfNotPresent
+	}
+	if _, err := docker.PullImage(opts.Image, d, policy); err != nil {
+		return err
+	}
+	containerID, err := d.CreateContainer(opts.Image)
+	if err != nil {
+		return fmt.Errorf("unable to create a container from %s: %v", opts.Image, err)
+	}
+	defer func() {
+		if err := d.RemoveContainer(containerID); err != nil {
+			log.Warningf("Unable to remove container %s: %v", containerID, err)
+		}
+	}()
+
+	if !IsArchive(opts.Output) {
+		if err := os.MkdirAll(opts.Output, 0755); err != nil {
+			return err
+		}
+		for _, path := range opts.Paths {
+			err := download(d, containerID, path, func(r io.Reader) error {
+				return t.ExtractTarStream(opts.Output, r)
+			})
+			if err != nil {
+				return err
+			}
+			log.V(1).Infof("Extracted %s from %s to %s", path, opts.Image, opts.Output)
+		}
+		return nil
+	}
+
+	w := stdout
+	if opts.Output != Stdout {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	tw := tar.NewWriter(w)
+	for _, path := range opts.Paths {
+		if err := download(d, containerID, path, func(r io.Reader) error {
+			return s2itar.CopyArchive(tw, tar.NewReader(r))
+		}); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// download streams the tar archive of the path of the container to fn.
+func download(d docker.Docker, containerID, path string, fn func(io.Reader) error) error {
+	return s2itar.StreamArchive(func(w io.Writer) error {
+		if err := d.DownloadFromContainer(path, w, containerID); err != nil {
+			return fmt.Errorf("unable to copy %s: %v", path, err)
+		}
+		return nil
+	}, fn)
+}
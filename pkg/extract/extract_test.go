@@ -0,0 +1,112 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+func archive(t *testing.T, files map[string]string) string {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func fakeDocker(t *testing.T) *docker.FakeDocker {
+	return &docker.FakeDocker{
+		LocalRegistryResult: true,
+		CreateContainerID:   "extract",
+		DownloadFromContainerResult: map[string]string{
+			"/opt/app-root/src/target/app.jar": archive(t, map[string]string{"app.jar": "jar"}),
+			"/opt/app-root/src/coverage":       archive(t, map[string]string{"coverage/index.html": "report"}),
+		},
+	}
+}
+
+func TestExtractDirectory(t *testing.T) {
+	d := fakeDocker(t)
+	output := t.TempDir()
+	opts := Options{
+		Image:  "app",
+		Paths:  []string{"/opt/app-root/src/target/app.jar", "/opt/app-root/src/coverage"},
+		Output: output,
+	}
+	if err := Extract(d, s2itar.New(fs.NewFileSystem()), opts, ioutil.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, expected := range map[string]string{"app.jar": "jar", "coverage/index.html": "report"} {
+		content, err := ioutil.ReadFile(filepath.Join(output, name))
+		if err != nil || string(content) != expected {
+			t.Errorf("expected %s to contain %q, got %q (%v)", name, expected, content, err)
+		}
+	}
+	if d.CreateContainerImage != "app" || d.RemoveContainerID != "extract" {
+		t.Errorf("expected the container of app to be created and removed, got %q and %q", d.CreateContainerImage, d.RemoveContainerID)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	d := fakeDocker(t)
+	opts := Options{
+		Image:  "app",
+		Paths:  []string{"/opt/app-root/src/target/app.jar", "/opt/app-root/src/coverage"},
+		Output: Stdout,
+	}
+	out := &bytes.Buffer{}
+	if err := Extract(d, s2itar.New(fs.NewFileSystem()), opts, out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := []string{}
+	tr := tar.NewReader(out)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	if expected := []string{"app.jar", "coverage/index.html"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected entries %v, got %v", expected, names)
+	}
+}
+
+func TestExtractMissingPath(t *testing.T) {
+	d := fakeDocker(t)
+	opts := Options{Image: "app", Paths: []string{"/missing"}, Output: t.TempDir()}
+	if err := Extract(d, s2itar.New(fs.NewFileSystem()), opts, ioutil.Discard); err == nil {
+		t.Errorf("expected an error extracting a missing path")
+	}
+	if d.RemoveContainerID != "extract" {
+		t.Errorf("expected the container to be removed")
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	for output, expected := range map[string]bool{"-": true, "artifacts.tar": true, "artifacts": false, ".": false} {
+		if IsArchive(output) != expected {
+			t.Errorf("IsArchive(%q) = %v, expected %v", output, !expected, expected)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package generate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+const (
+	// GitHub is the CI provider generating GitHub Actions workflows.
+	GitHub = "github"
+	// GitLab is the CI provider generating GitLab CI jobs.
+	GitLab = "gitlab"
+)
+
+// cacheDir is the directory of the CI workspace caching the image built by
+// the previous run, so that the next build is incremental.
+const cacheDir = ".s2i-cache"
+
+// CIOptions are the settings of the generated CI configurations which are not
+// part of the build configuration.
+type CIOptions struct {
+	// Name is the name of the generated job. It defaults to the name of the
+	// application image.
+	Name string
+	// S2IVersion is the version of s2i installed by the job.
+	S2IVersion string
+	// Push pushes the image once it is built, with the credentials of the
+	// REGISTRY_USERNAME and REGISTRY_PASSWORD secrets or variables.
+	Push bool
+}
+
+// GitHubWorkflow is a GitHub Actions workflow.
+type GitHubWorkflow struct {
+	Name string               `yaml:"name"`
+	On   []string             `yaml:"on"`
+	Jobs map[string]GitHubJob `yaml:"jobs"`
+}
+
+// GitHubJob is a job of a GitHub Actions workflow.
+type GitHubJob struct {
+	RunsOn string       `yaml:"runs-on"`
+	Steps  []GitHubStep `yaml:"steps"`
+}
+
+// GitHubStep is a step of a GitHub Actions job.
+type GitHubStep struct {
+	Name string            `yaml:"name,omitempty"`
+	Uses string            `yaml:"uses,omitempty"`
+	With map[string]string `yaml:"with,omitempty"`
+	Env  map[string]string `yaml:"env,omitempty"`
+	Run  string            `yaml:"run,omitempty"`
+}
+
+// GitLabJob is a job of a GitLab CI pipeline.
+type GitLabJob struct {
+	Image     string            `yaml:"image"`
+	Services  []string          `yaml:"services,omitempty"`
+	Variables map[string]string `yaml:"variables,omitempty"`
+	Cache     GitLabCache       `yaml:"cache"`
+	Script    []string          `yaml:"script"`
+}
+
+// GitLabCache is the cache of a GitLab CI job.
+type GitLabCache struct {
+	Key   string   `yaml:"key"`
+	Paths []string `yaml:"paths"`
+}
+
+// CI returns the configuration of the given CI provider running a job which
+// builds the application image of the given configuration from the sources of
+// the repository with s2i. The built image is cached between the runs, so that
+// the builds are incremental when the builder image supports it.
+func CI(provider string, config *api.Config, opts CIOptions) (interface{}, error) {
+	if len(opts.Name) == 0 {
+		opts.Name = Name(config.Tag)
+	}
+	if len(opts.S2IVersion) == 0 {
+		opts.S2IVersion = "latest"
+	}
+	registry := ""
+	if opts.Push {
+		named, err := reference.ParseNormalizedNamed(config.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image name %q: %v", config.Tag, err)
+		}
+		// the credentials of Docker Hub are stored without a registry
+		if domain := reference.Domain(named); domain != "docker.io" {
+			registry = domain
+		}
+	}
+	switch provider {
+	case GitHub:
+		return gitHubWorkflow(config, opts, registry), nil
+	case GitLab:
+		return gitLabJob(config, opts, registry), nil
+	}
+	return nil, fmt.Errorf("unsupported CI provider %q, must be %s or %s", provider, GitHub, GitLab)
+}
+
+// buildScript returns the commands loading the cached image, building the
+// application image and caching it. The environment variables matching the
+// redaction patterns take their values from the variables of the same name of
+// the job, set from the CI secrets, instead of the configuration.
+func buildScript(config *api.Config) []string {
+	args := append([]string{"s2i", "build", ".", config.BuilderImage, config.Tag, "--incremental"}, buildFlags(config)...)
+	build := shellJoin(args)
+	for _, name := range RedactedEnvironment(config) {
+		build += fmt.Sprintf(` --env "%s=$%s"`, name, name)
+	}
+	cache := cacheDir + "/image.tar"
+	return []string{
+		fmt.Sprintf("if [ -f %s ]; then docker load -i %s; fi", cache, cache),
+		build,
+		fmt.Sprintf("mkdir -p %s && docker save -o %s %s", cacheDir, cache, shellQuote(config.Tag)),
+	}
+}
+
+// gitHubWorkflow returns a GitHub Actions workflow building the image on
+// every push and pull request.
+func gitHubWorkflow(config *api.Config, opts CIOptions, registry string) *GitHubWorkflow {
+	cacheKey := "s2i-" + opts.Name + "-${{ github.ref_name }}-"
+	steps := []GitHubStep{
+		{Uses: "actions/checkout@v4"},
+		{Uses: "actions/setup-go@v5", With: map[string]string{"go-version": "stable"}},
+		{Name: "Install s2i", Run: "go install github.com/openshift/source-to-image/cmd/s2i@" + opts.S2IVersion},
+		{
+			Name: "Cache the previous image",
+			Uses: "actions/cache@v4",
+			// the caches are immutable, each run saves a new one restored by the
+			// next run through the key prefixes
+			With: map[string]string{
+				"path":         cacheDir,
+				"key":          cacheKey + "${{ github.sha }}",
+				"restore-keys": cacheKey + "\ns2i-" + opts.Name + "-",
+			},
+		},
+		{Name: "Build the image", Env: secretEnvironment(config), Run: strings.Join(buildScript(config), "\n")},
+	}
+	if opts.Push {
+		login := map[string]string{
+			"username": "${{ secrets.REGISTRY_USERNAME }}",
+			"password": "${{ secrets.REGISTRY_PASSWORD }}",
+		}
+		if len(registry) > 0 {
+			login["registry"] = registry
+		}
+		steps = append(steps,
+			GitHubStep{Name: "Log in to the registry", Uses: "docker/login-action@v3", With: login},
+			GitHubStep{Name: "Push the image", Run: "docker push " + shellQuote(config.Tag)},
+		)
+	}
+	return &GitHubWorkflow{
+		Name: "s2i build",
+		On:   []string{"push", "pull_request"},
+		Jobs: map[string]GitHubJob{opts.Name: {RunsOn: "ubuntu-latest", Steps: steps}},
+	}
+}
+
+// secretEnvironment returns the environment of the GitHub Actions step
+// building the image, setting the variables matching the redaction patterns
+// from the secrets of the same name.
+func secretEnvironment(config *api.Config) map[string]string {
+	names := RedactedEnvironment(config)
+	if len(names) == 0 {
+		return nil
+	}
+	env := map[string]string{}
+	for _, name := range names {
+		env[name] = fmt.Sprintf("${{ secrets.%s }}", name)
+	}
+	return env
+}
+
+// gitLabJob returns a GitLab CI job building the image with a Docker-in-Docker
+// service. The returned stanza is added to the .gitlab-ci.yml file.
+func gitLabJob(config *api.Config, opts CIOptions, registry string) map[string]GitLabJob {
+	script := []string{
+		"apk add --no-cache go",
+		"go install github.com/openshift/source-to-image/cmd/s2i@" + opts.S2IVersion,
+		`export PATH="$PATH:$(go env GOPATH)/bin"`,
+	}
+	script = append(script, buildScript(config)...)
+	if opts.Push {
+		login := `echo "$REGISTRY_PASSWORD" | docker login -u "$REGISTRY_USERNAME" --password-stdin`
+		if len(registry) > 0 {
+			login += " " + registry
+		}
+		script = append(script, login, "docker push "+shellQuote(config.Tag))
+	}
+	return map[string]GitLabJob{
+		opts.Name: {
+			Image:     "docker:27",
+			Services:  []string{"docker:27-dind"},
+			Variables: map[string]string{"DOCKER_HOST": "tcp://docker:2375", "DOCKER_TLS_CERTDIR": ""},
+			Cache:     GitLabCache{Key: "s2i-" + opts.Name + "-$CI_COMMIT_REF_SLUG", Paths: []string{cacheDir + "/"}},
+			Script:    script,
+		},
+	}
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes the given word for a POSIX shell, if needed.
+func shellQuote(word string) string {
+	if shellSafe.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'"'"'`) + "'"
+}
+
+// shellJoin quotes the given words and joins them into a shell command.
+func shellJoin(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
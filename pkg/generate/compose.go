@@ -0,0 +1,120 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+)
+
+// ComposeService is a service of a Docker Compose file.
+type ComposeService struct {
+	Image       string            `yaml:"image"`
+	Ports       []string          `yaml:"ports,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+}
+
+// ComposeServiceName returns the name of the service running the image of the
+// given configuration: the ComposeService of the configuration, or the last
+// directory of its context directory, or the name of the image.
+func ComposeServiceName(config *api.Config) string {
+	if len(config.ComposeService) > 0 {
+		return config.ComposeService
+	}
+	if dir := path.Base(strings.Trim(hostpath.ToSlash(config.ContextDir), "/")); dir != "." && dir != "/" {
+		if name := sanitizeName(dir); len(name) > 0 {
+			return name
+		}
+	}
+	if name := imageName(config.Tag); len(name) > 0 {
+		return name
+	}
+	return "app"
+}
+
+// NewComposeService returns the service running the image of the given
+// configuration with its run environment. The ports published by the run
+// options are published the same way, otherwise the given ports exposed by
+// the image are published on random host ports, so that the services of the
+// same file do not conflict.
+func NewComposeService(config *api.Config, exposedPorts []string) *ComposeService {
+	service := &ComposeService{Image: config.Tag}
+	if len(config.RunPublish) > 0 {
+		service.Ports = append([]string{}, config.RunPublish...)
+	} else {
+		for _, port := range exposedPorts {
+			service.Ports = append(service.Ports, strings.TrimSuffix(port, "/tcp"))
+		}
+	}
+	sort.Strings(service.Ports)
+	if len(config.RunEnvironment) > 0 {
+		service.Environment = map[string]string{}
+		for _, env := range config.RunEnvironment {
+			service.Environment[env.Name] = env.Value
+		}
+	}
+	return service
+}
+
+// UpdateComposeFile adds the given service to the Docker Compose file, or
+// replaces the service of the same name, and creates the file if it does not
+// exist. The other services and settings of the file are kept.
+func UpdateComposeFile(file, name string, service *ComposeService) error {
+	doc := &yaml.Node{}
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, doc); err != nil {
+			return fmt.Errorf("invalid compose file %s: %v", file, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid compose file %s: not a mapping", file)
+	}
+	services := mappingValue(root, "services")
+	if services.Kind != yaml.MappingNode {
+		// e.g. an empty "services:" key
+		*services = yaml.Node{Kind: yaml.MappingNode}
+	}
+	value := mappingValue(services, name)
+	if err := value.Encode(service); err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), 0644)
+}
+
+// mappingValue returns the value of the given key of the mapping node, which
+// is added to the mapping if it is missing.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
@@ -0,0 +1,3 @@
+// Package generate renders the configuration of S2I builds as the manifests of
+// other build systems, so that they can run in a cluster or a CI service.
+package generate
@@ -0,0 +1,225 @@
+package generate
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/util"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+)
+
+const (
+	// DefaultS2IImage is the image running s2i in the generated manifests.
+	DefaultS2IImage = "quay.io/openshift-pipeline/s2i:latest"
+	// DefaultBuildahImage is the image building and pushing the application
+	// image in the generated manifests.
+	DefaultBuildahImage = "quay.io/buildah/stable:latest"
+)
+
+const (
+	// dockerfile is the Dockerfile generated by s2i, built by buildah.
+	dockerfile = "/gen-source/Dockerfile.gen"
+	// authFile is the file of the push secret mounted in the containers.
+	authFile = ".dockerconfigjson"
+)
+
+// Options are the settings of the generated manifests which are not part of
+// the build configuration.
+type Options struct {
+	// Name is the name of the generated resources. It defaults to the name of
+	// the application image.
+	Name string
+	// Namespace is the namespace of the generated resources.
+	Namespace string
+	// S2IImage is the image running s2i.
+	S2IImage string
+	// BuildahImage is the image running buildah.
+	BuildahImage string
+	// PushSecret is the name of the kubernetes.io/dockerconfigjson secret
+	// holding the credentials of the registry the image is pushed to.
+	PushSecret string
+	// EnvironmentSecret is the name of the secret of EnvironmentSecret holding
+	// the environment of the build, which s2i reads instead of its --env flags
+	// so that the values are not written in the manifest of the pod.
+	EnvironmentSecret string
+}
+
+// withDefaults returns the options with the defaults of the given tag.
+func (o Options) withDefaults(tag string) Options {
+	if len(o.Name) == 0 {
+		o.Name = Name(tag)
+	}
+	if len(o.S2IImage) == 0 {
+		o.S2IImage = DefaultS2IImage
+	}
+	if len(o.BuildahImage) == 0 {
+		o.BuildahImage = DefaultBuildahImage
+	}
+	return o
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Name returns a valid resource name for the builds of the given image, e.g.
+// "ruby-app-build" for "quay.io/user/ruby_app:latest".
+func Name(tag string) string {
+	name := imageName(tag)
+	// the suffix is appended to a name at most 63 characters long
+	if len(name) > 57 {
+		name = strings.TrimRight(name[:57], "-")
+	}
+	if len(name) == 0 {
+		return "s2i-build"
+	}
+	return name + "-build"
+}
+
+// imageName returns the name of the repository of the given image, without
+// its registry, namespace, tag or digest, lowercased with the characters which
+// are not letters or digits replaced by dashes.
+func imageName(tag string) string {
+	name := tag
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexAny(name, ":@"); i >= 0 {
+		name = name[:i]
+	}
+	return sanitizeName(name)
+}
+
+// sanitizeName lowercases the given name and replaces the characters which are
+// not letters or digits by dashes.
+func sanitizeName(name string) string {
+	return strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// s2iArgs returns the arguments of the s2i command generating the Dockerfile
+// of the build of the given source.
+func s2iArgs(config *api.Config, source string) []string {
+	args := []string{"build", source, config.BuilderImage, "--as-dockerfile", dockerfile}
+	return append(args, buildFlags(config)...)
+}
+
+// buildFlags returns the flags of the s2i build command applying the given
+// configuration. The environment variables matching the redaction patterns,
+// whose values are secrets, are omitted (see RedactedEnvironment).
+func buildFlags(config *api.Config) []string {
+	var args []string
+	if len(config.ContextDir) > 0 {
+		args = append(args, "--context-dir", hostpath.ToSlash(config.ContextDir))
+	}
+	if len(config.ScriptsURL) > 0 {
+		args = append(args, "--scripts-url", config.ScriptsURL)
+	}
+	if len(config.AssembleUser) > 0 {
+		args = append(args, "--assemble-user", config.AssembleUser)
+	}
+	if len(config.AssembleRuntimeUser) > 0 {
+		args = append(args, "--assemble-runtime-user", config.AssembleRuntimeUser)
+	}
+	for _, env := range config.Environment {
+		if util.IsRedactedEnv(env.Name, config.RedactEnvPatterns) {
+			continue
+		}
+		args = append(args, "--env", fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+	return args
+}
+
+// RedactedEnvironment returns the names of the environment variables of the
+// given configuration which match the redaction patterns, and whose values are
+// therefore not written to the generated manifests.
+func RedactedEnvironment(config *api.Config) []string {
+	var names []string
+	for _, env := range config.Environment {
+		if util.IsRedactedEnv(env.Name, config.RedactEnvPatterns) {
+			names = append(names, env.Name)
+		}
+	}
+	return names
+}
+
+// buildahArgs returns the arguments of the buildah commands building and
+// pushing the given image.
+func buildahArgs(image, tlsVerify string) (build, push []string) {
+	build = []string{"bud", "--storage-driver=vfs", "--tls-verify=" + tlsVerify, "--layers", "-f", dockerfile, "-t", image, "."}
+	push = []string{"push", "--storage-driver=vfs", "--tls-verify=" + tlsVerify, image, "docker://" + image}
+	return build, push
+}
+
+// Write writes the given objects to w as a stream of YAML documents.
+func Write(w io.Writer, objects ...interface{}) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, object := range objects {
+		if err := encoder.Encode(object); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+// ObjectMeta is the metadata of a resource.
+type ObjectMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// Container is a container of a pod, or a step of a Tekton Task.
+type Container struct {
+	Name            string           `yaml:"name"`
+	Image           string           `yaml:"image"`
+	Command         []string         `yaml:"command,omitempty"`
+	Args            []string         `yaml:"args,omitempty"`
+	WorkingDir      string           `yaml:"workingDir,omitempty"`
+	Env             []EnvVar         `yaml:"env,omitempty"`
+	VolumeMounts    []VolumeMount    `yaml:"volumeMounts,omitempty"`
+	SecurityContext *SecurityContext `yaml:"securityContext,omitempty"`
+}
+
+// EnvVar is an environment variable of a container.
+type EnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// VolumeMount is a volume mounted in a container.
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+// SecurityContext is the security context of a container.
+type SecurityContext struct {
+	Privileged bool `yaml:"privileged"`
+}
+
+// Volume is a volume of a pod.
+type Volume struct {
+	Name     string        `yaml:"name"`
+	EmptyDir *struct{}     `yaml:"emptyDir,omitempty"`
+	Secret   *SecretVolume `yaml:"secret,omitempty"`
+}
+
+// SecretVolume is a volume holding the keys of a secret.
+type SecretVolume struct {
+	SecretName string `yaml:"secretName"`
+}
+
+// buildVolumes returns the volumes shared by the containers building the image.
+func buildVolumes() ([]Volume, []VolumeMount) {
+	return []Volume{
+		{Name: "gen-source", EmptyDir: &struct{}{}},
+		{Name: "varlibcontainers", EmptyDir: &struct{}{}},
+	}, []VolumeMount{
+		{Name: "gen-source", MountPath: "/gen-source"},
+		{Name: "varlibcontainers", MountPath: "/var/lib/containers"},
+	}
+}
@@ -0,0 +1,300 @@
+package generate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+func testConfig(t *testing.T, source string) *api.Config {
+	url, err := git.Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &api.Config{
+		Source:       url,
+		BuilderImage: "registry.access.redhat.com/ubi8/ruby-27",
+		Tag:          "quay.io/user/ruby_app:latest",
+		ContextDir:   "app",
+		Environment:  api.EnvironmentList{{Name: "RACK_ENV", Value: "production"}},
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"quay.io/user/ruby_app:latest":   "ruby-app-build",
+		"app@sha256:abcd":                "app-build",
+		"localhost:5000/App":             "app-build",
+		"_":                              "s2i-build",
+		strings.Repeat("a", 70) + ":tag": strings.Repeat("a", 57) + "-build",
+	}
+	for tag, expected := range tests {
+		if name := Name(tag); name != expected {
+			t.Errorf("%s: expected %s, got %s", tag, expected, name)
+		}
+	}
+}
+
+func TestTektonTask(t *testing.T) {
+	task := TektonTask(testConfig(t, "."), Options{PushSecret: "quay"})
+	if task.Metadata.Name != "ruby-app-build" {
+		t.Errorf("unexpected name %s", task.Metadata.Name)
+	}
+	expectedArgs := []string{"build", "$(workspaces.source.path)", "registry.access.redhat.com/ubi8/ruby-27",
+		"--as-dockerfile", "/gen-source/Dockerfile.gen", "--context-dir", "app", "--env", "RACK_ENV=production"}
+	if args := task.Spec.Steps[0].Args; !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("expected s2i arguments %v, got %v", expectedArgs, args)
+	}
+	config := testConfig(t, ".")
+	config.Environment = append(config.Environment, api.EnvironmentSpec{Name: "GITHUB_TOKEN", Value: "ghp_secret"})
+	if args := TektonTask(config, Options{}).Spec.Steps[0].Args; !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("expected the secrets to be omitted from the s2i arguments %v, got %v", expectedArgs, args)
+	}
+	if names := RedactedEnvironment(config); !reflect.DeepEqual(names, []string{"GITHUB_TOKEN"}) {
+		t.Errorf("expected the redacted variable GITHUB_TOKEN, got %v", names)
+	}
+	if len(task.Spec.Workspaces) != 2 || task.Spec.Workspaces[1].Name != "dockerconfig" {
+		t.Errorf("expected a dockerconfig workspace, got %v", task.Spec.Workspaces)
+	}
+	for _, step := range task.Spec.Steps[1:] {
+		if len(step.Env) != 1 || step.Env[0].Value != "$(workspaces.dockerconfig.path)/.dockerconfigjson" {
+			t.Errorf("expected the registry credentials in step %s, got %v", step.Name, step.Env)
+		}
+	}
+
+	if _, err := TektonPipeline(testConfig(t, "."), Options{}); err == nil {
+		t.Errorf("expected an error for a local source")
+	}
+	pipeline, err := TektonPipeline(testConfig(t, "https://github.com/user/app.git#v1"), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedParams := []string{"https://github.com/user/app.git", "v1", "quay.io/user/ruby_app:latest"}
+	for i, param := range pipeline.Spec.Params {
+		if param.Default != expectedParams[i] {
+			t.Errorf("expected %s to default to %s, got %s", param.Name, expectedParams[i], param.Default)
+		}
+	}
+	if ref := pipeline.Spec.Tasks[1].TaskRef.Name; ref != task.Metadata.Name {
+		t.Errorf("expected the pipeline to run %s, got %s", task.Metadata.Name, ref)
+	}
+}
+
+func TestKubernetesJob(t *testing.T) {
+	if _, err := KubernetesJob(testConfig(t, "."), Options{}); err == nil {
+		t.Errorf("expected an error for a local source")
+	}
+	job, err := KubernetesJob(testConfig(t, "https://github.com/user/app.git#v1"), Options{Name: "build", Namespace: "ci", PushSecret: "quay"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Write(buf, job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: build\n  namespace: ci\n",
+		"    - https://github.com/user/app.git#v1\n",
+		"- name: gen-source\n          emptyDir: {}\n",
+		"secret:\n            secretName: quay\n",
+		"value: /var/run/secrets/s2i-push/.dockerconfigjson\n",
+		"- docker://quay.io/user/ruby_app:latest\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the manifest to contain %q:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestKubernetesPod(t *testing.T) {
+	pod, err := KubernetesPod(testConfig(t, "."), Options{Name: "build"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := pod.Spec.InitContainers[0]; c.Name != UploadContainer || c.VolumeMounts[0].MountPath != UploadDir {
+		t.Errorf("expected the first init container to wait for the upload of the sources, got %+v", c)
+	}
+	if args := pod.Spec.InitContainers[1].Args; args[1] != "/workspace/src" {
+		t.Errorf("expected s2i to build the uploaded sources, got %q", args)
+	}
+	if len(pod.Spec.InitContainers[1].VolumeMounts) != 2 {
+		t.Errorf("expected the uploaded sources to be mounted in the generate container, got %+v", pod.Spec.InitContainers[1].VolumeMounts)
+	}
+
+	if mounts := pod.Spec.InitContainers[2].VolumeMounts; mounts[1].Name != "varlibcontainers" {
+		t.Errorf("expected the mounts of the build container to be left unchanged, got %+v", mounts)
+	}
+	for _, arg := range pod.Spec.InitContainers[1].Args {
+		if arg == "--environment-file" {
+			t.Errorf("expected the environment to be passed with --env, got %q", pod.Spec.InitContainers[1].Args)
+		}
+	}
+
+	// the environment is read from the secret instead of the manifest
+	opts := Options{Name: "build", EnvironmentSecret: "build-env"}
+	pod, err = KubernetesPod(testConfig(t, "."), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Write(buf, pod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(buf.String(), "RACK_ENV") || !strings.Contains(buf.String(), "--environment-file") || !strings.Contains(buf.String(), "secretName: build-env") {
+		t.Errorf("expected the environment to be read from the secret:\n%s", buf.String())
+	}
+	secret, err := EnvironmentSecret(testConfig(t, "."), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if secret.Metadata.Name != "build-env" || secret.StringData["environment.yaml"] != "RACK_ENV: production\n" {
+		t.Errorf("unexpected secret %+v", secret)
+	}
+
+	// the push credentials are held by a secret of the registry of the tag
+	config := testConfig(t, ".")
+	config.PushCredentials = api.RegistryCredentials{Username: "user", Password: "p@ss"}
+	secret, err = PushSecret(config, Options{Name: "build", PushSecret: "build-push"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"auths":{"quay.io":{"auth":"dXNlcjpwQHNz"}}}`
+	if secret.Metadata.Name != "build-push" || secret.Type != "kubernetes.io/dockerconfigjson" || secret.StringData[".dockerconfigjson"] != expected {
+		t.Errorf("unexpected push secret %+v", secret)
+	}
+	config.PushCredentials.Registry = "registry.example.com"
+	if secret, err = PushSecret(config, Options{PushSecret: "build-push"}); err != nil || !strings.Contains(secret.StringData[".dockerconfigjson"], `"registry.example.com"`) {
+		t.Errorf("expected the credentials of their registry, got %+v, %v", secret, err)
+	}
+
+	pod, err = KubernetesPod(testConfig(t, "https://github.com/user/app.git#v1"), Options{Name: "build"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := pod.Spec.InitContainers[0]; c.Name != "generate" || c.Args[1] != "https://github.com/user/app.git#v1" {
+		t.Errorf("expected the pod to build the remote repository, got %+v", c)
+	}
+}
+
+func TestCI(t *testing.T) {
+	config := &api.Config{
+		BuilderImage: "centos/ruby-25-centos7",
+		Tag:          "quay.io/user/ruby-ex",
+		Environment:  api.EnvironmentList{{Name: "MSG", Value: "it's"}},
+	}
+	build := `s2i build . centos/ruby-25-centos7 quay.io/user/ruby-ex --incremental --env 'MSG=it'"'"'s'`
+
+	workflow, err := CI(GitHub, config, CIOptions{Push: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	job := workflow.(*GitHubWorkflow).Jobs["ruby-ex-build"]
+	if step := job.Steps[4]; !strings.Contains(step.Run, "\n"+build+"\n") {
+		t.Errorf("expected the build command %q, got %q", build, step.Run)
+	}
+	if step := job.Steps[5]; step.With["registry"] != "quay.io" {
+		t.Errorf("expected a login to quay.io, got %v", step.With)
+	}
+
+	stanza, err := CI(GitLab, config, CIOptions{Name: "image", S2IVersion: "v1.4.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gitlab := stanza.(map[string]GitLabJob)["image"]
+	if gitlab.Script[1] != "go install github.com/openshift/source-to-image/cmd/s2i@v1.4.0" || gitlab.Script[4] != build {
+		t.Errorf("unexpected script %v", gitlab.Script)
+	}
+	if len(gitlab.Script) != 6 || gitlab.Cache.Paths[0] != ".s2i-cache/" {
+		t.Errorf("expected the image to be cached and not pushed, got %#v", gitlab)
+	}
+
+	// the secrets are read from the secrets or variables of the CI provider
+	config.Environment = append(config.Environment, api.EnvironmentSpec{Name: "NPM_TOKEN", Value: "npm_secret"})
+	workflow, err = CI(GitHub, config, CIOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step := workflow.(*GitHubWorkflow).Jobs["ruby-ex-build"].Steps[4]
+	if expected := build + ` --env "NPM_TOKEN=$NPM_TOKEN"`; !strings.Contains(step.Run, "\n"+expected+"\n") || strings.Contains(step.Run, "npm_secret") {
+		t.Errorf("expected the build command %q, got %q", expected, step.Run)
+	}
+	if !reflect.DeepEqual(step.Env, map[string]string{"NPM_TOKEN": "${{ secrets.NPM_TOKEN }}"}) {
+		t.Errorf("expected NPM_TOKEN to be set from the secrets, got %v", step.Env)
+	}
+
+	if _, err := CI("jenkins", config, CIOptions{}); err == nil {
+		t.Errorf("expected an error for an unsupported provider")
+	}
+}
+
+func TestComposeService(t *testing.T) {
+	tests := []struct {
+		config   *api.Config
+		name     string
+		expected *ComposeService
+	}{
+		{
+			config:   &api.Config{Tag: "quay.io/user/web:latest", ContextDir: "services/Front_End/"},
+			name:     "front-end",
+			expected: &ComposeService{Image: "quay.io/user/web:latest", Ports: []string{"53/udp", "8080"}},
+		},
+		{
+			config: &api.Config{
+				Tag:            "quay.io/user/api",
+				ComposeService: "backend",
+				RunPublish:     []string{"9090:8080"},
+				RunEnvironment: api.EnvironmentList{{Name: "MODE", Value: "dev"}},
+			},
+			name:     "backend",
+			expected: &ComposeService{Image: "quay.io/user/api", Ports: []string{"9090:8080"}, Environment: map[string]string{"MODE": "dev"}},
+		},
+	}
+	for _, tc := range tests {
+		if name := ComposeServiceName(tc.config); name != tc.name {
+			t.Errorf("expected the service %s, got %s", tc.name, name)
+		}
+		if service := NewComposeService(tc.config, []string{"8080/tcp", "53/udp"}); !reflect.DeepEqual(service, tc.expected) {
+			t.Errorf("expected %#v, got %#v", tc.expected, service)
+		}
+	}
+}
+
+func TestUpdateComposeFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "docker-compose.yaml")
+	if err := UpdateComposeFile(file, "web", &ComposeService{Image: "web:1", Ports: []string{"8080"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the other services and settings are kept
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append([]byte("# integration tests\nname: shop\n"), bytes.Replace(data, []byte("services:\n"), []byte("services:\n  db:\n    image: postgres\n"), 1)...)
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateComposeFile(file, "web", &ComposeService{Image: "web:2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err = os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# integration tests\nname: shop\nservices:\n  db:\n    image: postgres\n  web:\n    image: web:2\n"
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+
+	if err := os.WriteFile(file, []byte("- web"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateComposeFile(file, "web", &ComposeService{Image: "web:2"}); err == nil {
+		t.Errorf("expected an error for an invalid compose file")
+	}
+}
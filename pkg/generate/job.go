@@ -0,0 +1,245 @@
+package generate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/distribution/reference"
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+const (
+	// secretMountPath is the directory the push secret is mounted in by the Job.
+	secretMountPath = "/var/run/secrets/s2i-push"
+	// envSecretMountPath is the directory the environment secret is mounted in.
+	envSecretMountPath = "/var/run/secrets/s2i-env"
+	// envFile is the key of the environment secret holding the variables.
+	envFile = "environment.yaml"
+
+	// UploadContainer is the name of the init container of the pods building
+	// local sources which waits for their upload.
+	UploadContainer = "upload"
+	// UploadDir is the directory the local sources are uploaded to.
+	UploadDir = "/workspace"
+	// uploadedMarker is the file created once the sources are uploaded.
+	uploadedMarker = UploadDir + "/.uploaded"
+)
+
+// Job is a Kubernetes batch Job.
+type Job struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       JobSpec    `yaml:"spec"`
+}
+
+// JobSpec is the specification of a Job.
+type JobSpec struct {
+	BackoffLimit int             `yaml:"backoffLimit"`
+	Template     PodTemplateSpec `yaml:"template"`
+}
+
+// PodTemplateSpec is the template of the pod of a Job.
+type PodTemplateSpec struct {
+	Spec PodSpec `yaml:"spec"`
+}
+
+// PodSpec is the specification of a pod.
+type PodSpec struct {
+	RestartPolicy  string      `yaml:"restartPolicy"`
+	InitContainers []Container `yaml:"initContainers,omitempty"`
+	Containers     []Container `yaml:"containers"`
+	Volumes        []Volume    `yaml:"volumes,omitempty"`
+}
+
+// Secret is a Kubernetes Secret.
+type Secret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// Pod is a Kubernetes pod.
+type Pod struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       PodSpec    `yaml:"spec"`
+}
+
+// KubernetesJob returns a Job building the application image of the given
+// configuration from its remote Git repository, and pushing it. The Dockerfile
+// generated by s2i and the image built from it are passed between the
+// containers of the pod through emptyDir volumes.
+func KubernetesJob(config *api.Config, opts Options) (*Job, error) {
+	if config.Source == nil || config.Source.IsLocal() {
+		return nil, errors.New("a Kubernetes Job requires the source to be a remote Git repository")
+	}
+	opts = opts.withDefaults(config.Tag)
+	return &Job{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata:   ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+		Spec: JobSpec{
+			Template: PodTemplateSpec{
+				Spec: buildPodSpec(config, opts, config.Source.String()),
+			},
+		},
+	}, nil
+}
+
+// KubernetesPod returns a pod building the application image of the given
+// configuration and pushing it, like the Job of KubernetesJob. The local
+// sources are uploaded to the pod: its first init container waits for them to
+// be extracted to UploadDir with UploadCommand.
+func KubernetesPod(config *api.Config, opts Options) (*Pod, error) {
+	if config.Source == nil {
+		return nil, errors.New("a Kubernetes pod requires sources")
+	}
+	opts = opts.withDefaults(config.Tag)
+	pod := &Pod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata:   ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+	}
+	if !config.Source.IsLocal() {
+		pod.Spec = buildPodSpec(config, opts, config.Source.String())
+		return pod, nil
+	}
+	pod.Spec = buildPodSpec(config, opts, path.Join(UploadDir, "src"))
+	mount := VolumeMount{Name: "upload", MountPath: UploadDir}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, Volume{Name: "upload", EmptyDir: &struct{}{}})
+	generate := &pod.Spec.InitContainers[0]
+	generate.VolumeMounts = append(append([]VolumeMount{}, generate.VolumeMounts...), mount)
+	upload := Container{
+		Name:         UploadContainer,
+		Image:        opts.BuildahImage,
+		Command:      []string{"sh", "-c", fmt.Sprintf("until [ -f %s ]; do sleep 1; done", uploadedMarker)},
+		VolumeMounts: []VolumeMount{mount},
+	}
+	pod.Spec.InitContainers = append([]Container{upload}, pod.Spec.InitContainers...)
+	return pod, nil
+}
+
+// EnvironmentSecret returns the secret named by the EnvironmentSecret option,
+// holding the environment of the build of the given configuration as a YAML
+// map, which the pod of KubernetesPod passes to s2i with --environment-file.
+func EnvironmentSecret(config *api.Config, opts Options) (*Secret, error) {
+	env := map[string]string{}
+	for _, e := range config.Environment {
+		env[e.Name] = e.Value
+	}
+	data, err := yaml.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return &Secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   ObjectMeta{Name: opts.EnvironmentSecret, Namespace: opts.Namespace},
+		Type:       "Opaque",
+		StringData: map[string]string{envFile: string(data)},
+	}, nil
+}
+
+// PushSecret returns the kubernetes.io/dockerconfigjson secret named by the
+// PushSecret option, holding the push credentials of the given configuration
+// for their registry, or the registry of the tag when they do not name one.
+func PushSecret(config *api.Config, opts Options) (*Secret, error) {
+	credentials := config.PushCredentials
+	registry := credentials.Registry
+	if len(registry) == 0 {
+		named, err := reference.ParseNormalizedNamed(config.Tag)
+		if err != nil {
+			return nil, err
+		}
+		registry = reference.Domain(named)
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Password))
+	data, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			registry: map[string]string{"auth": auth},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   ObjectMeta{Name: opts.PushSecret, Namespace: opts.Namespace},
+		Type:       "kubernetes.io/dockerconfigjson",
+		StringData: map[string]string{authFile: string(data)},
+	}, nil
+}
+
+// UploadCommand returns the command run in the upload container of the pod of
+// KubernetesPod, extracting the sources from the tar stream read on its
+// standard input.
+func UploadCommand() []string {
+	src := path.Join(UploadDir, "src")
+	return []string{"sh", "-c", fmt.Sprintf("mkdir -p %s && tar -xf - -C %s && touch %s", src, src, uploadedMarker)}
+}
+
+// buildPodSpec returns the specification of the pod building the application
+// image from the given source with s2i and buildah, and pushing it.
+func buildPodSpec(config *api.Config, opts Options, source string) PodSpec {
+	volumes, mounts := buildVolumes()
+	var env []EnvVar
+	if len(opts.PushSecret) > 0 {
+		volumes = append(volumes, Volume{Name: "push-secret", Secret: &SecretVolume{SecretName: opts.PushSecret}})
+		mounts = append(mounts, VolumeMount{Name: "push-secret", MountPath: secretMountPath, ReadOnly: true})
+		env = []EnvVar{{Name: "REGISTRY_AUTH_FILE", Value: path.Join(secretMountPath, authFile)}}
+	}
+	generateArgs := s2iArgs(config, source)
+	generateMounts := []VolumeMount{mounts[0]}
+	if len(opts.EnvironmentSecret) > 0 {
+		withoutEnv := *config
+		withoutEnv.Environment = nil
+		generateArgs = append(s2iArgs(&withoutEnv, source), "--environment-file", path.Join(envSecretMountPath, envFile))
+		volumes = append(volumes, Volume{Name: "env-secret", Secret: &SecretVolume{SecretName: opts.EnvironmentSecret}})
+		generateMounts = append(generateMounts, VolumeMount{Name: "env-secret", MountPath: envSecretMountPath, ReadOnly: true})
+	}
+	build, push := buildahArgs(config.Tag, "true")
+	return PodSpec{
+		RestartPolicy: "Never",
+		InitContainers: []Container{
+			{
+				Name:         "generate",
+				Image:        opts.S2IImage,
+				Command:      []string{"s2i"},
+				Args:         generateArgs,
+				VolumeMounts: generateMounts,
+			},
+			{
+				Name:            "build",
+				Image:           opts.BuildahImage,
+				Command:         []string{"buildah"},
+				Args:            build,
+				WorkingDir:      "/gen-source",
+				Env:             env,
+				VolumeMounts:    mounts,
+				SecurityContext: &SecurityContext{Privileged: true},
+			},
+		},
+		Containers: []Container{
+			{
+				Name:            "push",
+				Image:           opts.BuildahImage,
+				Command:         []string{"buildah"},
+				Args:            push,
+				Env:             env,
+				VolumeMounts:    mounts[1:],
+				SecurityContext: &SecurityContext{Privileged: true},
+			},
+		},
+		Volumes: volumes,
+	}
+}
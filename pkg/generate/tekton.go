@@ -0,0 +1,196 @@
+package generate
+
+import (
+	"errors"
+	"path"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+// tektonAPIVersion is the API version of the generated Tekton resources.
+const tektonAPIVersion = "tekton.dev/v1"
+
+// Task is a Tekton Task.
+type Task struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       TaskSpec   `yaml:"spec"`
+}
+
+// TaskSpec is the specification of a Tekton Task.
+type TaskSpec struct {
+	Params     []ParamSpec            `yaml:"params,omitempty"`
+	Workspaces []WorkspaceDeclaration `yaml:"workspaces,omitempty"`
+	Steps      []Container            `yaml:"steps"`
+	Volumes    []Volume               `yaml:"volumes,omitempty"`
+}
+
+// ParamSpec declares a parameter of a Tekton Task or Pipeline.
+type ParamSpec struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Default     string `yaml:"default"`
+}
+
+// WorkspaceDeclaration declares a workspace of a Tekton Task or Pipeline.
+type WorkspaceDeclaration struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// Pipeline is a Tekton Pipeline.
+type Pipeline struct {
+	APIVersion string       `yaml:"apiVersion"`
+	Kind       string       `yaml:"kind"`
+	Metadata   ObjectMeta   `yaml:"metadata"`
+	Spec       PipelineSpec `yaml:"spec"`
+}
+
+// PipelineSpec is the specification of a Tekton Pipeline.
+type PipelineSpec struct {
+	Params     []ParamSpec            `yaml:"params,omitempty"`
+	Workspaces []WorkspaceDeclaration `yaml:"workspaces,omitempty"`
+	Tasks      []PipelineTask         `yaml:"tasks"`
+}
+
+// PipelineTask is a Task run by a Tekton Pipeline.
+type PipelineTask struct {
+	Name       string             `yaml:"name"`
+	TaskRef    TaskRef            `yaml:"taskRef"`
+	RunAfter   []string           `yaml:"runAfter,omitempty"`
+	Params     []Param            `yaml:"params,omitempty"`
+	Workspaces []WorkspaceBinding `yaml:"workspaces,omitempty"`
+}
+
+// TaskRef references a Tekton Task.
+type TaskRef struct {
+	Name string `yaml:"name"`
+}
+
+// Param is the value of a parameter of a Tekton Task.
+type Param struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// WorkspaceBinding binds a workspace of a Pipeline to a workspace of a Task.
+type WorkspaceBinding struct {
+	Name      string `yaml:"name"`
+	Workspace string `yaml:"workspace"`
+}
+
+// TektonTask returns a Tekton Task building the application image of the given
+// configuration from the sources of its "source" workspace, and pushing it.
+// The credentials of the registry are read from its "dockerconfig" workspace
+// when a push secret is set.
+func TektonTask(config *api.Config, opts Options) *Task {
+	opts = opts.withDefaults(config.Tag)
+	volumes, mounts := buildVolumes()
+	workspaces := []WorkspaceDeclaration{
+		{Name: "source", Description: "The sources of the application."},
+	}
+	var env []EnvVar
+	if len(opts.PushSecret) > 0 {
+		workspaces = append(workspaces, WorkspaceDeclaration{
+			Name:        "dockerconfig",
+			Description: "The " + opts.PushSecret + " secret holding the " + authFile + " credentials of the registry.",
+		})
+		env = []EnvVar{{Name: "REGISTRY_AUTH_FILE", Value: path.Join("$(workspaces.dockerconfig.path)", authFile)}}
+	}
+	build, push := buildahArgs("$(params.IMAGE)", "$(params.TLS_VERIFY)")
+	return &Task{
+		APIVersion: tektonAPIVersion,
+		Kind:       "Task",
+		Metadata:   ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+		Spec: TaskSpec{
+			Params: []ParamSpec{
+				{Name: "IMAGE", Description: "The name of the image to build and push.", Default: config.Tag},
+				{Name: "TLS_VERIFY", Description: "Verify the TLS certificates of the registry.", Default: "true"},
+			},
+			Workspaces: workspaces,
+			Steps: []Container{
+				{
+					Name:         "generate",
+					Image:        opts.S2IImage,
+					Command:      []string{"s2i"},
+					Args:         s2iArgs(config, "$(workspaces.source.path)"),
+					WorkingDir:   "$(workspaces.source.path)",
+					VolumeMounts: mounts[:1],
+				},
+				{
+					Name:            "build",
+					Image:           opts.BuildahImage,
+					Command:         []string{"buildah"},
+					Args:            build,
+					WorkingDir:      "/gen-source",
+					Env:             env,
+					VolumeMounts:    mounts,
+					SecurityContext: &SecurityContext{Privileged: true},
+				},
+				{
+					Name:            "push",
+					Image:           opts.BuildahImage,
+					Command:         []string{"buildah"},
+					Args:            push,
+					Env:             env,
+					VolumeMounts:    mounts[1:],
+					SecurityContext: &SecurityContext{Privileged: true},
+				},
+			},
+			Volumes: volumes,
+		},
+	}
+}
+
+// TektonPipeline returns a Tekton Pipeline cloning the Git repository of the
+// given configuration with the git-clone Task of the Tekton catalog, and
+// running the Task returned by TektonTask.
+func TektonPipeline(config *api.Config, opts Options) (*Pipeline, error) {
+	if config.Source == nil || config.Source.IsLocal() {
+		return nil, errors.New("a Tekton Pipeline requires the source to be a remote Git repository")
+	}
+	opts = opts.withDefaults(config.Tag)
+	workspaces := []WorkspaceDeclaration{
+		{Name: "source", Description: "The workspace the Git repository is cloned into."},
+	}
+	bindings := []WorkspaceBinding{{Name: "source", Workspace: "source"}}
+	if len(opts.PushSecret) > 0 {
+		workspaces = append(workspaces, WorkspaceDeclaration{
+			Name:        "dockerconfig",
+			Description: "The " + opts.PushSecret + " secret holding the credentials of the registry.",
+		})
+		bindings = append(bindings, WorkspaceBinding{Name: "dockerconfig", Workspace: "dockerconfig"})
+	}
+	return &Pipeline{
+		APIVersion: tektonAPIVersion,
+		Kind:       "Pipeline",
+		Metadata:   ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+		Spec: PipelineSpec{
+			Params: []ParamSpec{
+				{Name: "GIT_URL", Description: "The URL of the Git repository of the application.", Default: config.Source.StringNoFragment()},
+				{Name: "GIT_REVISION", Description: "The revision of the Git repository to build.", Default: config.Source.URL.Fragment},
+				{Name: "IMAGE", Description: "The name of the image to build and push.", Default: config.Tag},
+			},
+			Workspaces: workspaces,
+			Tasks: []PipelineTask{
+				{
+					Name:    "fetch-source",
+					TaskRef: TaskRef{Name: "git-clone"},
+					Params: []Param{
+						{Name: "url", Value: "$(params.GIT_URL)"},
+						{Name: "revision", Value: "$(params.GIT_REVISION)"},
+					},
+					Workspaces: []WorkspaceBinding{{Name: "output", Workspace: "source"}},
+				},
+				{
+					Name:       "build",
+					TaskRef:    TaskRef{Name: opts.Name},
+					RunAfter:   []string{"fetch-source"},
+					Params:     []Param{{Name: "IMAGE", Value: "$(params.IMAGE)"}},
+					Workspaces: bindings,
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,154 @@
+package hermetic
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// ListenerName is the name of the socket the container runtime sends the
+// seccomp notifications of the audited containers to.
+const ListenerName = "hermetic.sock"
+
+// listenerTimeout is the time the container runtime is given to send the
+// seccomp notifications of a container which ran to the auditor.
+var listenerTimeout = 10 * time.Second
+
+// Auditor records, and denies, the attempts of the containers running with
+// its profile to reach addresses outside of the container.
+type Auditor struct {
+	listener   *net.UnixListener
+	path       string
+	connected  chan struct{}
+	connect    sync.Once
+	mu         sync.Mutex
+	violations []Violation
+	failures   []string
+}
+
+// Start starts an auditor listening in the dir directory, which must be
+// reachable by the container runtime.
+func Start(dir string) (*Auditor, error) {
+	if err := checkSupported(); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, ListenerName)
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen for seccomp notifications: %v", err)
+	}
+	a := &Auditor{listener: listener, path: path, connected: make(chan struct{})}
+	go a.serve()
+	return a, nil
+}
+
+// LocalEndpoint returns true when endpoint is the unix socket of a container
+// engine running on this host, whose container runtime can reach the socket
+// of an auditor.
+func LocalEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "unix://") || filepath.IsAbs(endpoint)
+}
+
+// Profile returns the seccomp profile of the audited containers.
+func (a *Auditor) Profile() (string, error) {
+	p, err := Profile(a.path)
+	return string(p), err
+}
+
+// Violations returns the network access attempted so far.
+func (a *Auditor) Violations() []Violation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Violation(nil), a.violations...)
+}
+
+// Err returns an error describing the network access attempted so far or,
+// failing that, the system calls which could not be audited, if any.
+func (a *Auditor) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.violations) > 0 {
+		messages := make([]string, 0, len(a.violations))
+		for _, v := range a.violations {
+			messages = append(messages, v.String())
+		}
+		return fmt.Errorf("outbound network access was attempted during the hermetic build: %s", strings.Join(messages, "; "))
+	}
+	if len(a.failures) > 0 {
+		return fmt.Errorf("unable to audit the network access of the hermetic build: %s", strings.Join(a.failures, "; "))
+	}
+	return nil
+}
+
+// Verify returns an error when the container runtime did not send the seccomp
+// notifications of the container to the auditor, because the container engine
+// does not run on this host or ignores the listener path of the profile.
+func (a *Auditor) Verify() error {
+	select {
+	case <-a.connected:
+		return nil
+	case <-time.After(listenerTimeout):
+		return fmt.Errorf("the container runtime did not send the seccomp notifications of the hermetic build to %s: the container engine must run on this host, with a runtime supporting the listenerPath of seccomp profiles", a.path)
+	}
+}
+
+// Close stops listening for new containers.
+func (a *Auditor) Close() error {
+	err := a.listener.Close()
+	os.Remove(a.path)
+	return err
+}
+
+func (a *Auditor) record(v Violation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.violations = append(a.violations, v)
+}
+
+func (a *Auditor) fail(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failures = append(a.failures, err.Error())
+}
+
+// listening records that the container runtime sent the seccomp
+// notifications of a container.
+func (a *Auditor) listening() {
+	a.connect.Do(func() { close(a.connected) })
+}
+
+func (a *Auditor) serve() {
+	for {
+		conn, err := a.listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+		go a.handle(conn)
+	}
+}
+
+// Violation is an attempt of a process to reach outside of the container.
+type Violation struct {
+	// PID is the process ID of the process, in the namespace of the host.
+	PID uint32
+	// Address is the address, outside of the container, the process tried to
+	// reach.
+	Address string
+	// Command is the command line of the process, when it could be read.
+	Command string
+}
+
+func (v Violation) String() string {
+	if len(v.Command) == 0 {
+		return fmt.Sprintf("process %d tried to reach %s", v.PID, v.Address)
+	}
+	return fmt.Sprintf("process %d (%s) tried to reach %s", v.PID, v.Command, v.Address)
+}
@@ -0,0 +1,265 @@
+package hermetic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccompNotif is the struct seccomp_notif of the kernel.
+type seccompNotif struct {
+	id    uint64
+	pid   uint32
+	flags uint32
+	nr    int32
+	arch  uint32
+	ip    uint64
+	args  [6]uint64
+}
+
+// seccompNotifResp is the struct seccomp_notif_resp of the kernel.
+type seccompNotifResp struct {
+	id    uint64
+	val   int64
+	error int32
+	flags uint32
+}
+
+// nativeArch is the audit architecture of the system calls whose addresses are
+// inspected; the system calls of the other architectures, made by 32-bit
+// processes, are denied.
+var nativeArch = map[string]uint32{
+	"amd64":   unix.AUDIT_ARCH_X86_64,
+	"arm64":   unix.AUDIT_ARCH_AARCH64,
+	"ppc64le": unix.AUDIT_ARCH_PPC64LE,
+	"s390x":   unix.AUDIT_ARCH_S390X,
+}[runtime.GOARCH]
+
+var syscallNames = map[int32]string{
+	unix.SYS_CONNECT:  "connect",
+	unix.SYS_SENDMMSG: "sendmmsg",
+	unix.SYS_SENDMSG:  "sendmsg",
+	unix.SYS_SENDTO:   "sendto",
+}
+
+const (
+	// sizeofSockaddr is the size of the struct sockaddr_storage of the kernel,
+	// the largest address a system call is given.
+	sizeofSockaddr = 128
+	// sizeofMmsghdr is the size of the struct mmsghdr of the kernel, a msghdr
+	// followed by the unsigned int length of the message, on 64-bit platforms.
+	sizeofMmsghdr = unix.SizeofMsghdr + 8
+	// maxMessages is the maximum number of messages sent by sendmmsg.
+	maxMessages = 1024
+)
+
+func checkSupported() error {
+	if nativeArch == 0 {
+		return fmt.Errorf("hermetic builds are not supported on %s", runtime.GOARCH)
+	}
+	return nil
+}
+
+// handle receives the seccomp file descriptors sent by the container runtime
+// along with the state of the container, and serves their notifications.
+func (a *Auditor) handle(conn *net.UnixConn) {
+	defer conn.Close()
+	state := make([]byte, 64*1024)
+	oob := make([]byte, unix.CmsgSpace(4*4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(state, oob)
+	if err != nil {
+		log.V(2).Infof("Unable to read the seccomp notification listener state: %v", err)
+		return
+	}
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		log.V(2).Infof("Unable to parse the seccomp notification listener state: %v", err)
+		return
+	}
+	for i := range messages {
+		fds, err := unix.ParseUnixRights(&messages[i])
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			a.listening()
+			go a.notifications(fd)
+		}
+	}
+}
+
+// notifications inspects the notifications of the fd seccomp file descriptor,
+// until the processes of the container exit. The system calls reaching the
+// loopback interface, unix sockets or other local addresses continue, the
+// others are recorded and denied.
+func (a *Auditor) notifications(fd int) {
+	defer unix.Close(fd)
+	for {
+		var req seccompNotif
+		if err := ioctl(fd, unix.SECCOMP_IOCTL_NOTIF_RECV, unsafe.Pointer(&req)); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return
+		}
+		resp := seccompNotifResp{id: req.id, flags: unix.SECCOMP_USER_NOTIF_FLAG_CONTINUE}
+		deny := seccompNotifResp{id: req.id, error: -int32(unix.EPERM)}
+		address, err := inspect(fd, &req)
+		if err != nil {
+			err = fmt.Errorf("unable to inspect the %s system call of process %d: %v", syscallName(req.nr), req.pid, err)
+			log.V(1).Infof("Hermetic build: %v", err)
+			a.fail(err)
+			resp = deny
+		} else if len(address) > 0 {
+			v := Violation{
+				PID:     req.pid,
+				Address: address,
+				Command: command(req.pid),
+			}
+			log.V(1).Infof("Hermetic build violation: %s", v)
+			a.record(v)
+			resp = deny
+		}
+
+		err = ioctl(fd, unix.SECCOMP_IOCTL_NOTIF_SEND, unsafe.Pointer(&resp))
+		if err == unix.EINVAL && resp.flags != 0 {
+			a.fail(fmt.Errorf("unable to let the %s system call of process %d continue, which requires Linux 5.5", syscallName(req.nr), req.pid))
+			err = ioctl(fd, unix.SECCOMP_IOCTL_NOTIF_SEND, unsafe.Pointer(&deny))
+		}
+		if err != nil && err != unix.ENOENT {
+			log.V(2).Infof("Unable to answer the seccomp notification of process %d: %v", req.pid, err)
+		}
+	}
+}
+
+// inspect returns the address outside of the container reached by the
+// notified system call, read from the memory of the process, or an empty
+// string when it reaches a local address.
+func inspect(fd int, req *seccompNotif) (string, error) {
+	if req.arch != nativeArch {
+		return "", fmt.Errorf("the system calls of the architecture %#x are not audited", req.arch)
+	}
+	mem, err := os.Open(fmt.Sprintf("/proc/%d/mem", req.pid))
+	if err != nil {
+		return "", err
+	}
+	defer mem.Close()
+
+	// the pointers and lengths of the addresses reached
+	var addresses [][2]uint64
+	switch req.nr {
+	case unix.SYS_CONNECT:
+		addresses = append(addresses, [2]uint64{req.args[1], req.args[2]})
+	case unix.SYS_SENDTO:
+		addresses = append(addresses, [2]uint64{req.args[4], req.args[5]})
+	case unix.SYS_SENDMSG:
+		header := make([]byte, unix.SizeofMsghdr)
+		if _, err := mem.ReadAt(header, int64(req.args[1])); err != nil {
+			return "", err
+		}
+		addresses = append(addresses, msgName(header))
+	case unix.SYS_SENDMMSG:
+		count := req.args[2]
+		if count > maxMessages {
+			count = maxMessages
+		}
+		headers := make([]byte, count*sizeofMmsghdr)
+		if _, err := mem.ReadAt(headers, int64(req.args[1])); err != nil {
+			return "", err
+		}
+		for i := uint64(0); i < count; i++ {
+			addresses = append(addresses, msgName(headers[i*sizeofMmsghdr:]))
+		}
+	default:
+		return "", fmt.Errorf("unexpected system call %d", req.nr)
+	}
+
+	var outbound string
+	for _, address := range addresses {
+		pointer, length := address[0], address[1]
+		if pointer == 0 || length == 0 {
+			continue
+		}
+		if length > sizeofSockaddr {
+			length = sizeofSockaddr
+		}
+		sockaddr := make([]byte, length)
+		if _, err := mem.ReadAt(sockaddr, int64(pointer)); err != nil {
+			return "", err
+		}
+		if outbound = outboundAddress(sockaddr); len(outbound) > 0 {
+			break
+		}
+	}
+	// the memory read is only the one of the process which made the system
+	// call if the notification is still valid
+	if err := ioctl(fd, unix.SECCOMP_IOCTL_NOTIF_ID_VALID, unsafe.Pointer(&req.id)); err != nil {
+		return "", err
+	}
+	return outbound, nil
+}
+
+// msgName returns the pointer and length of the address of the struct msghdr
+// at the beginning of header.
+func msgName(header []byte) [2]uint64 {
+	return [2]uint64{binary.NativeEndian.Uint64(header), uint64(binary.NativeEndian.Uint32(header[8:]))}
+}
+
+// outboundAddress returns the address of sockaddr when it is an internet
+// address outside of the container, that is neither a loopback nor an
+// unspecified address, which also reaches the loopback interface.
+func outboundAddress(sockaddr []byte) string {
+	if len(sockaddr) < 2 {
+		return ""
+	}
+	var ip net.IP
+	switch binary.NativeEndian.Uint16(sockaddr) {
+	case unix.AF_INET:
+		if len(sockaddr) < unix.SizeofSockaddrInet4 {
+			return ""
+		}
+		ip = net.IP(sockaddr[4:8])
+	case unix.AF_INET6:
+		if len(sockaddr) < unix.SizeofSockaddrInet6 {
+			return ""
+		}
+		ip = net.IP(sockaddr[8:24])
+	default:
+		return ""
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return ""
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(binary.BigEndian.Uint16(sockaddr[2:]))))
+}
+
+func syscallName(nr int32) string {
+	if name, ok := syscallNames[nr]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", nr)
+}
+
+func ioctl(fd int, request uint, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(request), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// command returns the command line of the process pid, read while it waits
+// for the notification to be answered.
+func command(pid uint32) string {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(bytes.ReplaceAll(bytes.TrimRight(cmdline, "\x00"), []byte{0}, []byte{' '})))
+}
@@ -0,0 +1,98 @@
+package hermetic
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSeccompNotifSize(t *testing.T) {
+	// The sizes are encoded in the SECCOMP_IOCTL_NOTIF_RECV and
+	// SECCOMP_IOCTL_NOTIF_SEND requests.
+	if size := unsafe.Sizeof(seccompNotif{}); size != 0x50 {
+		t.Errorf("unexpected size of seccomp_notif %d", size)
+	}
+	if size := unsafe.Sizeof(seccompNotifResp{}); size != 0x18 {
+		t.Errorf("unexpected size of seccomp_notif_resp %d", size)
+	}
+}
+
+func sockaddr(family uint16, ip net.IP, port uint16) []byte {
+	b := make([]byte, unix.SizeofSockaddrInet6)
+	binary.NativeEndian.PutUint16(b, family)
+	binary.BigEndian.PutUint16(b[2:], port)
+	if family == unix.AF_INET {
+		copy(b[4:], ip.To4())
+		return b[:unix.SizeofSockaddrInet4]
+	}
+	copy(b[8:], ip.To16())
+	return b
+}
+
+func TestOutboundAddress(t *testing.T) {
+	unixAddr := make([]byte, 110)
+	binary.NativeEndian.PutUint16(unixAddr, unix.AF_UNIX)
+	copy(unixAddr[2:], "/run/pip.sock")
+	tests := []struct {
+		sockaddr []byte
+		expected string
+	}{
+		{sockaddr(unix.AF_INET, net.ParseIP("151.101.0.223"), 443), "151.101.0.223:443"},
+		{sockaddr(unix.AF_INET6, net.ParseIP("2a04:4e42::223"), 443), "[2a04:4e42::223]:443"},
+		{sockaddr(unix.AF_INET6, net.ParseIP("::ffff:8.8.8.8"), 53), "8.8.8.8:53"},
+		{sockaddr(unix.AF_INET, net.ParseIP("127.0.0.1"), 5432), ""},
+		{sockaddr(unix.AF_INET, net.ParseIP("127.0.0.11"), 53), ""},
+		{sockaddr(unix.AF_INET, net.ParseIP("0.0.0.0"), 8080), ""},
+		{sockaddr(unix.AF_INET6, net.ParseIP("::1"), 8080), ""},
+		{sockaddr(unix.AF_INET6, net.ParseIP("::ffff:127.0.0.1"), 8080), ""},
+		{unixAddr, ""},
+		{[]byte{0, 0}, ""},
+		{sockaddr(unix.AF_INET, net.ParseIP("151.101.0.223"), 443)[:8], ""},
+	}
+	for _, tc := range tests {
+		if address := outboundAddress(tc.sockaddr); address != tc.expected {
+			t.Errorf("expected the address %q, got %q for %v", tc.expected, address, tc.sockaddr)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	defer func(timeout time.Duration) { listenerTimeout = timeout }(listenerTimeout)
+	listenerTimeout = 100 * time.Millisecond
+
+	a, err := Start(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer a.Close()
+	if err := a.Verify(); err == nil {
+		t.Errorf("expected an error before the container runtime sent the notifications")
+	}
+
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: a.path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if _, _, err := conn.WriteMsgUnix([]byte("{}"), unix.UnixRights(int(r.Fd())), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	listenerTimeout = 10 * time.Second
+	if err := a.Verify(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := a.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package hermetic
+
+import (
+	"errors"
+	"net"
+)
+
+var errUnsupported = errors.New("hermetic builds are only supported on Linux")
+
+// checkSupported fails, seccomp notifications not being supported on this
+// platform.
+func checkSupported() error {
+	return errUnsupported
+}
+
+// Profile fails, seccomp notifications not being supported on this platform.
+func Profile(listenerPath string) ([]byte, error) {
+	return nil, errUnsupported
+}
+
+func (a *Auditor) handle(conn *net.UnixConn) {
+	conn.Close()
+}
@@ -0,0 +1,3 @@
+// Package hermetic audits the network access attempted by the S2I scripts,
+// through a seccomp profile notifying the system calls reaching an address.
+package hermetic
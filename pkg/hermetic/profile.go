@@ -0,0 +1,72 @@
+//go:build linux
+
+package hermetic
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/profiles/seccomp"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// notifiedSyscalls are the system calls reaching an address, which the
+// auditor inspects before letting them through.
+var notifiedSyscalls = []string{
+	"connect",
+	"sendmmsg",
+	"sendmsg",
+	"sendto",
+}
+
+// deniedSyscalls are refused to the audited containers, as they would allow
+// them to reach an address without the notified system calls: the io_uring
+// operations are not seen by seccomp, and socketcall multiplexes the socket
+// system calls of 32-bit processes.
+var deniedSyscalls = []string{
+	"io_uring_enter",
+	"io_uring_register",
+	"io_uring_setup",
+	"socketcall",
+}
+
+// Profile returns the seccomp profile, in the format of the container
+// engines, notifying the listener at listenerPath of the system calls
+// reaching an address. The other system calls are filtered by the default
+// profile of the Docker engine.
+func Profile(listenerPath string) ([]byte, error) {
+	if len(listenerPath) == 0 {
+		return nil, fmt.Errorf("a listener path is required")
+	}
+	p := seccomp.DefaultProfile()
+	p.ListenerPath = listenerPath
+	syscalls := make([]*seccomp.Syscall, 0, len(p.Syscalls)+2)
+	for _, s := range p.Syscalls {
+		if s.Names = without(s.Names, notifiedSyscalls, deniedSyscalls); len(s.Names) > 0 {
+			syscalls = append(syscalls, s)
+		}
+	}
+	eperm := uint(1)
+	p.Syscalls = append(syscalls,
+		&seccomp.Syscall{LinuxSyscall: specs.LinuxSyscall{Names: deniedSyscalls, Action: specs.ActErrno, ErrnoRet: &eperm}},
+		&seccomp.Syscall{LinuxSyscall: specs.LinuxSyscall{Names: notifiedSyscalls, Action: specs.ActNotify}},
+	)
+	return json.Marshal(p)
+}
+
+// without returns the names which are not in the excluded lists.
+func without(names []string, excluded ...[]string) []string {
+	var kept []string
+Names:
+	for _, name := range names {
+		for _, list := range excluded {
+			for _, e := range list {
+				if name == e {
+					continue Names
+				}
+			}
+		}
+		kept = append(kept, name)
+	}
+	return kept
+}
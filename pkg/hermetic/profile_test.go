@@ -0,0 +1,63 @@
+//go:build linux
+
+package hermetic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/docker/profiles/seccomp"
+)
+
+func TestProfile(t *testing.T) {
+	if _, err := Profile(""); err == nil {
+		t.Errorf("expected an error without listener path")
+	}
+
+	data, err := Profile("/tmp/s2i/hermetic.sock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var p seccomp.Seccomp
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatalf("invalid profile %s: %v", data, err)
+	}
+	if p.DefaultAction != "SCMP_ACT_ERRNO" {
+		t.Errorf("expected the syscalls to be denied by default, as by the default profile, got %s", p.DefaultAction)
+	}
+	if p.ListenerPath != "/tmp/s2i/hermetic.sock" {
+		t.Errorf("unexpected listener path %s", p.ListenerPath)
+	}
+	actions := map[string][]string{}
+	for _, s := range p.Syscalls {
+		for _, name := range s.Names {
+			actions[name] = append(actions[name], string(s.Action))
+		}
+	}
+	expected := map[string]string{
+		"connect":        "SCMP_ACT_NOTIFY",
+		"sendto":         "SCMP_ACT_NOTIFY",
+		"sendmsg":        "SCMP_ACT_NOTIFY",
+		"sendmmsg":       "SCMP_ACT_NOTIFY",
+		"io_uring_setup": "SCMP_ACT_ERRNO",
+		"io_uring_enter": "SCMP_ACT_ERRNO",
+		"socketcall":     "SCMP_ACT_ERRNO",
+		"socket":         "SCMP_ACT_ALLOW",
+		"read":           "SCMP_ACT_ALLOW",
+	}
+	for name, action := range expected {
+		if len(actions[name]) != 1 || actions[name][0] != action {
+			t.Errorf("expected %s to be %s, got %v", name, action, actions[name])
+		}
+	}
+	if _, ok := actions["keyctl"]; ok {
+		t.Errorf("expected keyctl to be denied as by the default profile, got %v", actions["keyctl"])
+	}
+}
+
+func TestViolation(t *testing.T) {
+	v := Violation{PID: 42, Address: "[2a04:4e42::223]:443", Command: "pip install flask"}
+	if s := v.String(); s != "process 42 (pip install flask) tried to reach [2a04:4e42::223]:443" {
+		t.Errorf("unexpected violation %q", s)
+	}
+}
@@ -0,0 +1,237 @@
+package ignore
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+)
+
+// GitIgnorer ignores files based on the contents of the .gitignore files found
+// in the source tree
+type GitIgnorer struct{}
+
+// Ignore removes files from the workspace based on the contents of the
+// .gitignore files
+func (g *GitIgnorer) Ignore(config *api.Config) error {
+	filesToDel, err := g.GetListOfFilesToIgnore(config.WorkingSourceDir)
+	if err != nil {
+		return err
+	}
+	return removeFiles(filesToDel)
+}
+
+// GetListOfFilesToIgnore returns list of files from the workspace based on the
+// contents of the .gitignore files. Unlike .s2iignore, a .gitignore file applies
+// to the directory it is found in and all of its subdirectories, and files
+// inside of an ignored directory cannot be re-included.
+func (g *GitIgnorer) GetListOfFilesToIgnore(workingDir string) (map[string]string, error) {
+	filesToDel := make(map[string]string)
+	if err := g.walk(workingDir, workingDir, nil, filesToDel); err != nil {
+		return nil, err
+	}
+	return filesToDel, nil
+}
+
+func (g *GitIgnorer) walk(workingDir, dir string, patterns []GitPattern, filesToDel map[string]string) error {
+	base, err := filepath.Rel(workingDir, dir)
+	if err != nil {
+		return err
+	}
+	own, err := readGitIgnoreFile(filepath.Join(dir, constants.GitIgnoreFile), filepath.ToSlash(base))
+	if err != nil {
+		return err
+	}
+	// copy, so that sibling directories do not share the appended patterns
+	patterns = append(patterns[:len(patterns):len(patterns)], own...)
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		rel, err := filepath.Rel(workingDir, path)
+		if err != nil {
+			return err
+		}
+		if MatchGitPatterns(patterns, filepath.ToSlash(rel), entry.IsDir()) {
+			log.V(5).Infof(".gitignore matches %s", path)
+			filesToDel[path] = path
+			continue
+		}
+		if entry.IsDir() {
+			if err := g.walk(workingDir, path, patterns, filesToDel); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readGitIgnoreFile reads the patterns of the given .gitignore file, relative
+// to the base directory.
+func readGitIgnoreFile(path, base string) ([]GitPattern, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		log.Errorf("Ignore processing, problem opening %s because of %v\n", path, err)
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []GitPattern{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		p, ok := ParseGitPattern(scanner.Text(), base)
+		if !ok {
+			continue
+		}
+		log.V(4).Infof(".gitignore in %q lists a file spec of %s", base, scanner.Text())
+		patterns = append(patterns, p)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("Problem processing %s %v \n", path, err)
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// GitPattern is a single compiled pattern of a .gitignore file.
+type GitPattern struct {
+	// Negate is set for patterns starting with "!", which re-include
+	// previously ignored paths.
+	Negate bool
+	// DirOnly is set for patterns ending with "/", which only match
+	// directories.
+	DirOnly bool
+
+	re *regexp.Regexp
+}
+
+// ParseGitPattern compiles a single line of a .gitignore file. base is the
+// slash separated directory the pattern is relative to ("" or "." for the root
+// of the source tree). It returns false for blank lines, comments and invalid
+// patterns.
+func ParseGitPattern(line, base string) (GitPattern, bool) {
+	p, ok, err := compileGitPattern(line, base)
+	if err != nil {
+		log.V(4).Infof("Skipping invalid pattern %q: %v", line, err)
+		return p, false
+	}
+	return p, ok
+}
+
+func compileGitPattern(line, base string) (GitPattern, bool, error) {
+	p := GitPattern{}
+	line = strings.TrimRight(line, " ")
+	if strings.HasSuffix(line, "\\") {
+		// a trailing backslash escapes a trailing space
+		line += " "
+	}
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return p, false, nil
+	}
+	if strings.HasPrefix(line, "!") {
+		p.Negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if len(line) == 0 {
+		return p, false, nil
+	}
+
+	expr := "^"
+	if base != "" && base != "." {
+		expr += regexp.QuoteMeta(base) + "/"
+	}
+	// patterns without a separator, except at the end, match at any depth
+	if strings.HasPrefix(line, "/") {
+		line = line[1:]
+	} else if !strings.Contains(line, "/") {
+		expr += "(.*/)?"
+	}
+	expr += globToRegexp(line) + "$"
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return p, false, err
+	}
+	p.re = re
+	return p, true, nil
+}
+
+// Match returns true if the pattern matches the given slash separated path,
+// relative to the root of the source tree.
+func (p GitPattern) Match(path string, isDir bool) bool {
+	if p.DirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(path)
+}
+
+// MatchGitPatterns returns true if the given slash separated path is ignored by
+// the patterns. The last matching pattern wins.
+func MatchGitPatterns(patterns []GitPattern, path string, isDir bool) bool {
+	ignored := false
+	for _, p := range patterns {
+		if p.Match(path, isDir) {
+			ignored = !p.Negate
+		}
+	}
+	return ignored
+}
+
+// globToRegexp translates a gitignore style glob into a regular expression.
+// "*" and "?" do not match "/", while "**" matches any number of directories.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
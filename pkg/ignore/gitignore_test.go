@@ -0,0 +1,165 @@
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+func TestGitPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		base    string
+		path    string
+		isDir   bool
+		match   bool
+	}{
+		{"*.log", "", "debug.log", false, true},
+		{"*.log", "", "logs/debug.log", false, true},
+		{"*.log", "", "debug.log.txt", false, false},
+		{"/build", "", "build", true, true},
+		{"/build", "", "src/build", true, false},
+		{"build/", "", "src/build", true, true},
+		{"build/", "", "src/build", false, false},
+		{"doc/*.txt", "", "doc/notes.txt", false, true},
+		{"doc/*.txt", "", "doc/server/arch.txt", false, false},
+		{"**/foo", "", "a/b/foo", false, true},
+		{"**/foo", "", "foo", false, true},
+		{"abc/**", "", "abc/x/y", false, true},
+		{"a/**/b", "", "a/b", false, true},
+		{"a/**/b", "", "a/x/y/b", false, true},
+		{"file?.txt", "", "file1.txt", false, true},
+		{"file[0-9].txt", "", "filea.txt", false, false},
+		{"file[!0-9].txt", "", "filea.txt", false, true},
+		{"\\#hash", "", "#hash", false, true},
+		{"*.tmp", "sub", "sub/deep/x.tmp", false, true},
+		{"*.tmp", "sub", "other/x.tmp", false, false},
+		{"/x.tmp", "sub", "sub/x.tmp", false, true},
+	}
+	for _, tc := range tests {
+		p, ok := ParseGitPattern(tc.pattern, tc.base)
+		if !ok {
+			t.Errorf("%q: expected pattern to be parsed", tc.pattern)
+			continue
+		}
+		if p.Match(tc.path, tc.isDir) != tc.match {
+			t.Errorf("%q in %q: expected match of %q to be %v", tc.pattern, tc.base, tc.path, tc.match)
+		}
+	}
+	for _, line := range []string{"", "   ", "# comment"} {
+		if _, ok := ParseGitPattern(line, ""); ok {
+			t.Errorf("%q: expected line to be skipped", line)
+		}
+	}
+	for _, line := range []string{"[]", "file[z-a].txt", "a/[]/b"} {
+		if _, ok := ParseGitPattern(line, ""); ok {
+			t.Errorf("%q: expected the malformed pattern to be skipped", line)
+		}
+	}
+}
+
+func TestGitIgnorer(t *testing.T) {
+	workingDir, err := ioutil.TempDir("", "gitignore")
+	if err != nil {
+		t.Fatalf("Unable to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(workingDir)
+
+	files := map[string]string{
+		".gitignore":            "*.log\n/target/\n!keep.log\n",
+		"sub/.gitignore":        "local.txt\n",
+		"app.log":               "",
+		"keep.log":              "",
+		"main.go":               "",
+		"target/classes/a.txt":  "",
+		"sub/local.txt":         "",
+		"sub/nested/local.txt":  "",
+		"sub/nested/other.txt":  "",
+		"other/local.txt":       "",
+		".git/info/exclude.log": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(workingDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Unable to create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Unable to create file: %v", err)
+		}
+	}
+
+	stack, err := New([]string{api.IgnorerS2I, api.IgnorerGit})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = stack.Ignore(&api.Config{WorkingSourceDir: workingDir}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	removed := []string{"app.log", "target", "sub/local.txt", "sub/nested/local.txt"}
+	kept := []string{".gitignore", "keep.log", "main.go", "sub/nested/other.txt", "other/local.txt", ".git/info/exclude.log"}
+	for _, name := range removed {
+		if _, err := os.Stat(filepath.Join(workingDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+	for _, name := range kept {
+		if _, err := os.Stat(filepath.Join(workingDir, name)); err != nil {
+			t.Errorf("Expected %s to be kept: %v", name, err)
+		}
+	}
+}
+
+func TestNewUnknownIgnorer(t *testing.T) {
+	if _, err := New([]string{"hgignore"}); err == nil {
+		t.Errorf("Expected an error for an unknown ignorer")
+	}
+}
+
+func TestGlobMatcher(t *testing.T) {
+	m, err := NewGlobMatcher([]string{"*.log", "vendor/", "/docs"}, []string{"vendor/keep/**", "important.log"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"app.log", false, true},
+		{"logs/app.log", false, true},
+		{"important.log", false, false},
+		{"vendor", true, true},
+		{"vendor/lib/a.go", false, true},
+		{"vendor/keep/a.go", false, false},
+		{"src/vendor/a.go", false, true},
+		{"docs", true, true},
+		{"docs/index.md", false, true},
+		{"src/docs/index.md", false, false},
+		{"main.go", false, false},
+	}
+	for _, tc := range tests {
+		if m.Match(tc.path, tc.isDir) != tc.excluded {
+			t.Errorf("Expected %s to be excluded: %v", tc.path, tc.excluded)
+		}
+	}
+
+	in := m.In("src")
+	for path, excluded := range map[string]bool{"src": false, "src/docs": true, "src/app.log": true, "scripts/build.log": false, "docs": false} {
+		if in.Match(path, false) != excluded {
+			t.Errorf("Expected %s to be excluded relative to src: %v", path, excluded)
+		}
+	}
+
+	if m, err := NewGlobMatcher(nil, nil); err != nil || m.Match("anything", false) {
+		t.Errorf("Expected an empty matcher not to match anything")
+	}
+	for _, glob := range []string{"!negated", "file[z-a]", "#comment"} {
+		if _, err := NewGlobMatcher([]string{glob}, nil); err == nil {
+			t.Errorf("Expected an error for glob %q", glob)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ignore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GlobMatcher matches paths against the gitignore style patterns given by the
+// --exclude-glob and --include-glob flags. Include patterns are applied after
+// the exclude patterns, so they re-include paths which were excluded.
+type GlobMatcher struct {
+	patterns []GitPattern
+	// root is the slash separated path of the source tree relative to the
+	// paths matched, if they are not relative to the source tree itself.
+	root string
+}
+
+// NewGlobMatcher compiles the given exclude and include patterns. It returns a
+// nil matcher, which does not match anything, when no patterns are given.
+func NewGlobMatcher(exclude, include []string) (*GlobMatcher, error) {
+	if len(exclude) == 0 && len(include) == 0 {
+		return nil, nil
+	}
+	m := &GlobMatcher{}
+	for _, list := range []struct {
+		globs  []string
+		negate bool
+	}{{exclude, false}, {include, true}} {
+		for _, glob := range list.globs {
+			if strings.HasPrefix(glob, "!") {
+				return nil, fmt.Errorf("invalid glob %q: negated patterns are not supported, use --include-glob instead", glob)
+			}
+			p, ok, err := compileGitPattern(glob, "")
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %v", glob, err)
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid glob %q", glob)
+			}
+			p.Negate = list.negate
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m, nil
+}
+
+// In returns a matcher of the same patterns matching the paths relative to the
+// parent of the source tree, given by the slash separated root path, such as
+// the upload directory of the builds holding the sources in src. The paths
+// outside of the source tree are never excluded.
+func (m *GlobMatcher) In(root string) *GlobMatcher {
+	if m == nil {
+		return nil
+	}
+	return &GlobMatcher{patterns: m.patterns, root: strings.Trim(root, "/")}
+}
+
+// Match returns true if the given slash separated path, relative to the root of
+// the source tree or to its parent for the matchers returned by In, is
+// excluded. A path inherits the result of its closest
+// parent directory matched by any of the patterns.
+func (m *GlobMatcher) Match(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	if len(m.root) > 0 {
+		rel, ok := strings.CutPrefix(strings.Trim(path, "/"), m.root+"/")
+		if !ok {
+			return false
+		}
+		path = rel
+	}
+	excluded := false
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		prefixIsDir := isDir || i < len(parts)-1
+		for _, p := range m.patterns {
+			if p.Match(prefix, prefixIsDir) {
+				excluded = !p.Negate
+			}
+		}
+	}
+	return excluded
+}
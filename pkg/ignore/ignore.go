@@ -2,6 +2,7 @@ package ignore
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -14,6 +15,75 @@ import (
 
 var log = utillog.StderrLog
 
+// FileIgnorer lists the files of a source tree that are to be ignored
+type FileIgnorer interface {
+	GetListOfFilesToIgnore(workingDir string) (map[string]string, error)
+}
+
+// Stack ignores the files listed by any of its ignorers
+type Stack []FileIgnorer
+
+// New returns a Stack of the ignorers with the given names, which are
+// processed in order. When no names are given, only .s2iignore is processed.
+func New(names []string) (Stack, error) {
+	if len(names) == 0 {
+		return Stack{&DockerIgnorer{}}, nil
+	}
+	stack := Stack{}
+	for _, name := range names {
+		switch name {
+		case api.IgnorerS2I:
+			stack = append(stack, &DockerIgnorer{})
+		case api.IgnorerGit:
+			stack = append(stack, &GitIgnorer{})
+		default:
+			return nil, fmt.Errorf("unknown ignorer %q, valid values are: %s or %s", name, api.IgnorerS2I, api.IgnorerGit)
+		}
+	}
+	return stack, nil
+}
+
+// Ignore removes files from the workspace listed by any of the ignorers
+func (s Stack) Ignore(config *api.Config) error {
+	filesToDel, err := s.GetListOfFilesToIgnore(config.WorkingSourceDir)
+	if err != nil {
+		return err
+	}
+	return removeFiles(filesToDel)
+}
+
+// GetListOfFilesToIgnore returns the union of the files listed by the ignorers
+func (s Stack) GetListOfFilesToIgnore(workingDir string) (map[string]string, error) {
+	var filesToDel map[string]string
+	for _, ignorer := range s {
+		files, err := ignorer.GetListOfFilesToIgnore(workingDir)
+		if err != nil {
+			return nil, err
+		}
+		if filesToDel == nil {
+			filesToDel = files
+			continue
+		}
+		for k, v := range files {
+			filesToDel[k] = v
+		}
+	}
+	return filesToDel, nil
+}
+
+// removeFiles deletes compiled list of files
+func removeFiles(filesToDel map[string]string) error {
+	for _, fileToDel := range filesToDel {
+		log.V(5).Infof("attempting to remove file %s \n", fileToDel)
+		rerr := os.RemoveAll(fileToDel)
+		if rerr != nil {
+			log.Errorf("error removing file %s because of %v \n", fileToDel, rerr)
+			return rerr
+		}
+	}
+	return nil
+}
+
 // DockerIgnorer ignores files based on the contents of the .s2iignore file
 type DockerIgnorer struct{}
 
@@ -36,21 +106,7 @@ func (b *DockerIgnorer) Ignore(config *api.Config) error {
 		return lerr
 	}
 
-	if filesToDel == nil {
-		return nil
-	}
-
-	// delete compiled list of files
-	for _, fileToDel := range filesToDel {
-		log.V(5).Infof("attempting to remove file %s \n", fileToDel)
-		rerr := os.RemoveAll(fileToDel)
-		if rerr != nil {
-			log.Errorf("error removing file %s because of %v \n", fileToDel, rerr)
-			return rerr
-		}
-	}
-
-	return nil
+	return removeFiles(filesToDel)
 }
 
 // GetListOfFilesToIgnore returns list of files from the workspace based on the contents of the
@@ -0,0 +1,287 @@
+package imagebundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/docker"
+	"github.com/openshift/source-to-image/pkg/extract"
+	"github.com/openshift/source-to-image/pkg/scripts"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+const (
+	// metadataFile is the file of the archive holding the Metadata of the
+	// bundle. The files of the s2i directory are ignored by docker load, so
+	// that the archive can also be loaded with it.
+	metadataFile = "s2i/metadata.json"
+
+	// scriptsDir is the directory of the archive holding the scripts.
+	scriptsDir = "s2i/scripts/"
+)
+
+// Scripts are the S2I scripts bundled with the image, when it provides them.
+var Scripts = []string{constants.Assemble, constants.AssembleRuntime, constants.Run, constants.SaveArtifacts, constants.Usage}
+
+// Metadata describes the image of a bundle.
+type Metadata struct {
+	// Image is the name of the image.
+	Image string `json:"image"`
+	// ID is the ID of the image when it was saved.
+	ID string `json:"id"`
+	// Labels are the labels of the image, which must be preserved when it is
+	// loaded.
+	Labels map[string]string `json:"labels,omitempty"`
+	// ScriptsURL is the location the scripts were fetched from.
+	ScriptsURL string `json:"scriptsURL,omitempty"`
+	// Scripts are the names of the bundled scripts.
+	Scripts []string `json:"scripts,omitempty"`
+}
+
+// SaveOptions are the options of the save of a bundle.
+type SaveOptions struct {
+	// Image is the builder image saved.
+	Image string
+	// ScriptsURL is the location of the scripts bundled with the image,
+	// instead of the scripts URL label of the image.
+	ScriptsURL string
+	// PullPolicy specifies when to pull the image.
+	PullPolicy api.PullPolicy
+	// ProxyConfig is the proxy configuration of the download of the scripts.
+	ProxyConfig *api.ProxyConfig
+}
+
+// LoadOptions are the options of the load of a bundle.
+type LoadOptions struct {
+	// Archive is the path of the bundle.
+	Archive string
+	// ScriptsDir is the directory the scripts are restored to, or empty not
+	// to restore them.
+	ScriptsDir string
+}
+
+// Save writes the image, in the format of docker save, followed by its
+// metadata and its scripts, to w.
+func Save(d docker.Docker, opts SaveOptions, w io.Writer) (*Metadata, error) {
+	policy := opts.PullPolicy
+	if len(policy) == 0 {
+		policy = api.PullIfNotPresent
+	}
+	if _, err := docker.PullImage(opts.Image, d, policy); err != nil {
+		return nil, err
+	}
+	id, err := d.GetImageID(opts.Image)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := d.GetLabels(opts.Image)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &Metadata{Image: opts.Image, ID: id, Labels: labels, ScriptsURL: opts.ScriptsURL}
+	if len(metadata.ScriptsURL) == 0 {
+		if metadata.ScriptsURL, err = d.GetScriptsURL(opts.Image); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := fs.MkdirTemp("image-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{}
+	if len(metadata.ScriptsURL) > 0 {
+		if files, err = fetchScripts(d, opts, metadata.ScriptsURL, dir); err != nil {
+			return nil, err
+		}
+	}
+	for _, script := range Scripts {
+		if _, ok := files[script]; ok {
+			metadata.Scripts = append(metadata.Scripts, script)
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	if err := s2itar.StreamArchive(func(w io.Writer) error {
+		return d.SaveImage(opts.Image, w)
+	}, func(r io.Reader) error {
+		return s2itar.CopyArchive(tw, tar.NewReader(r))
+	}); err != nil {
+		return nil, fmt.Errorf("unable to save image %s: %v", opts.Image, err)
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: metadataFile, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+	for _, script := range metadata.Scripts {
+		if err := writeFile(tw, scriptsDir+script, files[script]); err != nil {
+			return nil, err
+		}
+	}
+	return metadata, tw.Close()
+}
+
+// fetchScripts fetches the scripts of the given scripts URL to dir, and
+// returns the paths of the scripts found, by name. The scripts of an image://
+// URL are extracted from a container of the image, which is never started.
+func fetchScripts(d docker.Docker, opts SaveOptions, scriptsURL, dir string) (map[string]string, error) {
+	u, err := url.Parse(scriptsURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scripts URL %q: %v", scriptsURL, err)
+	}
+	files := map[string]string{}
+	if u.Scheme == "image" {
+		extractOpts := extract.Options{
+			Image:      opts.Image,
+			Paths:      []string{u.Path},
+			Output:     dir,
+			PullPolicy: api.PullNever,
+		}
+		if err := extract.Extract(d, s2itar.New(fs.NewFileSystem()), extractOpts, nil); err != nil {
+			return nil, fmt.Errorf("unable to extract the scripts of image %s: %v", opts.Image, err)
+		}
+		for _, script := range Scripts {
+			file := filepath.Join(dir, path.Base(u.Path), script)
+			if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+				files[script] = file
+			}
+		}
+		return files, nil
+	}
+	downloader := scripts.NewDownloader(opts.ProxyConfig, "", 0)
+	for _, script := range Scripts {
+		scriptURL := *u
+		scriptURL.Path = path.Join(u.Path, script)
+		file := filepath.Join(dir, script)
+		if _, err := downloader.Download(&scriptURL, file); err != nil {
+			log.V(2).Infof("The %s script is not bundled, it cannot be fetched from %s: %v", script, scriptURL.String(), err)
+			continue
+		}
+		files[script] = file
+	}
+	return files, nil
+}
+
+// writeFile writes the file of the given path to the archive with the given
+// name, as an executable.
+func writeFile(tw *tar.Writer, name, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// Load loads the image of the bundle, restores its scripts and verifies that
+// the labels of the image were preserved.
+func Load(d docker.Docker, opts LoadOptions) (*Metadata, error) {
+	f, err := os.Open(opts.Archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	metadata, err := readBundle(f, opts.ScriptsDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := d.LoadImage(f); err != nil {
+		return nil, fmt.Errorf("unable to load image %s: %v", metadata.Image, err)
+	}
+	labels, err := d.GetLabels(metadata.Image)
+	if err != nil {
+		return nil, err
+	}
+	lost := []string{}
+	for name, value := range metadata.Labels {
+		if v, ok := labels[name]; !ok || v != value {
+			lost = append(lost, name)
+		}
+	}
+	if len(lost) > 0 {
+		sort.Strings(lost)
+		return nil, fmt.Errorf("the labels %s of image %s were not preserved by the load", strings.Join(lost, ", "), metadata.Image)
+	}
+	return metadata, nil
+}
+
+// readBundle returns the metadata of the bundle read by r, and restores its
+// scripts to scriptsDir unless it is empty.
+func readBundle(r io.Reader, dir string) (*Metadata, error) {
+	var metadata *Metadata
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case header.Name == metadataFile:
+			metadata = &Metadata{}
+			if err := json.NewDecoder(tr).Decode(metadata); err != nil {
+				return nil, fmt.Errorf("unable to parse the metadata of the bundle: %v", err)
+			}
+		case strings.HasPrefix(header.Name, scriptsDir) && len(dir) > 0:
+			script := strings.TrimPrefix(header.Name, scriptsDir)
+			if !isScript(script) {
+				log.V(2).Infof("Ignoring the unknown script %s of the bundle", header.Name)
+				continue
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, script), data, 0755); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if metadata == nil {
+		return nil, fmt.Errorf("the archive is not a bundle saved by s2i image save: %s is missing", metadataFile)
+	}
+	return metadata, nil
+}
+
+// isScript returns true if name is the name of one of the bundled scripts.
+func isScript(name string) bool {
+	for _, script := range Scripts {
+		if script == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package imagebundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/docker"
+)
+
+// archive returns a tar archive of the given files.
+func archive(t *testing.T, files ...string) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// entries returns the names of the entries of a tar archive.
+func entries(t *testing.T, data []byte) []string {
+	names := []string{}
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func newFakeDocker(t *testing.T) *docker.FakeDocker {
+	return &docker.FakeDocker{
+		PullResult:       true,
+		GetImageIDResult: "sha256:1234",
+		Labels:           map[string]string{"io.openshift.s2i.scripts-url": "image:///usr/libexec/s2i", "io.openshift.s2i.destination": "/tmp"},
+		DefaultURLResult: "image:///usr/libexec/s2i",
+		SaveImageResult:  archive(t, "manifest.json", "blobs/sha256/layer"),
+		DownloadFromContainerResult: map[string]string{
+			"/usr/libexec/s2i": string(archive(t, "s2i/assemble", "s2i/run", "s2i/usage", "s2i/helper")),
+		},
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	fakeDocker := newFakeDocker(t)
+	buf := &bytes.Buffer{}
+	metadata, err := Save(fakeDocker, SaveOptions{Image: "centos/ruby-25-centos7"}, buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"assemble", "run", "usage"}; !reflect.DeepEqual(metadata.Scripts, expected) {
+		t.Errorf("expected the scripts %v to be bundled, got %v", expected, metadata.Scripts)
+	}
+	expected := []string{"manifest.json", "blobs/sha256/layer", "s2i/metadata.json", "s2i/scripts/assemble", "s2i/scripts/run", "s2i/scripts/usage"}
+	if names := entries(t, buf.Bytes()); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the entries %v, got %v", expected, names)
+	}
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "bundle.tar")
+	if err := os.WriteFile(bundle, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scripts := filepath.Join(dir, "scripts")
+	loaded, err := Load(fakeDocker, LoadOptions{Archive: bundle, ScriptsDir: scripts})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, metadata) {
+		t.Errorf("expected the metadata %+v, got %+v", metadata, loaded)
+	}
+	if !bytes.Equal(fakeDocker.LoadImageArchive, buf.Bytes()) {
+		t.Errorf("expected the whole bundle to be loaded")
+	}
+	data, err := os.ReadFile(filepath.Join(scripts, "assemble"))
+	if err != nil || string(data) != "s2i/assemble" {
+		t.Errorf("expected the assemble script to be restored, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(scripts, "helper")); !os.IsNotExist(err) {
+		t.Errorf("expected only the S2I scripts to be restored")
+	}
+
+	fakeDocker.Labels = map[string]string{"io.openshift.s2i.scripts-url": "image:///usr/libexec/s2i"}
+	if _, err := Load(fakeDocker, LoadOptions{Archive: bundle}); err == nil || !strings.Contains(err.Error(), "labels io.openshift.s2i.destination of image centos/ruby-25-centos7 were not preserved") {
+		t.Errorf("expected the lost labels to be reported, got %v", err)
+	}
+}
+
+func TestLoadNotABundle(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(archivePath, archive(t, "manifest.json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fakeDocker := newFakeDocker(t)
+	if _, err := Load(fakeDocker, LoadOptions{Archive: archivePath}); err == nil || !strings.Contains(err.Error(), "not a bundle saved by s2i image save") {
+		t.Errorf("expected an error, got %v", err)
+	}
+	if fakeDocker.LoadImageArchive != nil {
+		t.Errorf("expected the archive not to be loaded")
+	}
+}
@@ -0,0 +1,4 @@
+// Package imagebundle saves a builder image with its S2I scripts to a single
+// archive, and loads it back, so that builder images can be carried to
+// air-gapped hosts.
+package imagebundle
@@ -0,0 +1,4 @@
+// Package imagestore reads the images of a local OCI image layout directory,
+// so that offline builds get their images without pulling them from a
+// registry.
+package imagestore
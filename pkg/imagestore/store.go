@@ -0,0 +1,235 @@
+package imagestore
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/distribution/reference"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// containerdImageNameAnnotation is the annotation holding the full name of
+	// the images exported by containerd and nerdctl, whose
+	// org.opencontainers.image.ref.name annotation only holds the tag.
+	containerdImageNameAnnotation = "io.containerd.image.name"
+
+	// dockerManifestListMediaType and dockerManifestMediaType are the media
+	// types of the Docker equivalents of the OCI image indexes and manifests.
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// archiveManifestFile is the file listing the images of an archive in the
+	// format of docker save.
+	archiveManifestFile = "manifest.json"
+)
+
+// Store is an OCI image layout directory, as written by skopeo copy, podman
+// save --format oci-dir or nerdctl save piped to tar -x.
+type Store struct {
+	dir   string
+	index v1.Index
+}
+
+// Image is an image of the store, resolved for the platform of the host.
+type Image struct {
+	// Name is the name the image is loaded with.
+	Name   string
+	Config v1.Descriptor
+	Layers []v1.Descriptor
+}
+
+// Size returns the compressed size of the layers of the image.
+func (i *Image) Size() int64 {
+	var size int64
+	for _, layer := range i.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// archiveManifest is an entry of the manifest.json file of an archive in the
+// format of docker save.
+type archiveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// Open opens the OCI image layout of the given directory.
+func Open(dir string) (*Store, error) {
+	if _, err := os.Stat(filepath.Join(dir, v1.ImageLayoutFile)); err != nil {
+		return nil, fmt.Errorf("%s is not an OCI image layout: %v", dir, err)
+	}
+	s := &Store{dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, v1.ImageIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.index); err != nil {
+		return nil, fmt.Errorf("unable to parse the index of the image layout %s: %v", dir, err)
+	}
+	return s, nil
+}
+
+// Lookup returns the image of the store with the given name, which is matched
+// against the name annotations of the images of the index. Multi-platform
+// images are resolved for the linux platform of the architecture of the host.
+func (s *Store) Lookup(name string) (*Image, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return nil, fmt.Errorf("image %s is referred to by digest, only the images referred to by tag can be loaded from the image store", name)
+	}
+	named = reference.TagNameOnly(named)
+	for _, desc := range s.index.Manifests {
+		if !matches(desc, named) {
+			continue
+		}
+		manifest, err := s.manifest(name, desc)
+		if err != nil {
+			return nil, err
+		}
+		return &Image{
+			Name:   reference.FamiliarString(named),
+			Config: manifest.Config,
+			Layers: manifest.Layers,
+		}, nil
+	}
+	return nil, fmt.Errorf("image %s is not in the image store %s", name, s.dir)
+}
+
+// matches returns true if the name annotations of the descriptor designate
+// the given image.
+func matches(desc v1.Descriptor, named reference.Named) bool {
+	for _, key := range []string{containerdImageNameAnnotation, v1.AnnotationRefName} {
+		ref, err := reference.ParseNormalizedNamed(desc.Annotations[key])
+		if err == nil && reference.TagNameOnly(ref).String() == named.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// manifest returns the manifest of the image of the given descriptor,
+// resolving the image indexes for the platform of the host.
+func (s *Store) manifest(name string, desc v1.Descriptor) (*v1.Manifest, error) {
+	switch desc.MediaType {
+	case v1.MediaTypeImageIndex, dockerManifestListMediaType:
+		index := &v1.Index{}
+		if err := s.readJSON(desc, index); err != nil {
+			return nil, err
+		}
+		for _, m := range index.Manifests {
+			if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+				return s.manifest(name, m)
+			}
+		}
+		return nil, fmt.Errorf("image %s of the image store %s has no linux/%s variant", name, s.dir, runtime.GOARCH)
+	case v1.MediaTypeImageManifest, dockerManifestMediaType:
+		manifest := &v1.Manifest{}
+		if err := s.readJSON(desc, manifest); err != nil {
+			return nil, err
+		}
+		return manifest, nil
+	}
+	return nil, fmt.Errorf("image %s of the image store %s has the unsupported media type %q", name, s.dir, desc.MediaType)
+}
+
+// readJSON parses the blob of the given descriptor into v.
+func (s *Store) readJSON(desc v1.Descriptor, v interface{}) error {
+	path, err := s.blobPath(desc)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unable to parse blob %s of the image store %s: %v", desc.Digest, s.dir, err)
+	}
+	return nil
+}
+
+// blobPath returns the path of the blob of the given descriptor.
+func (s *Store) blobPath(desc v1.Descriptor) (string, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return "", fmt.Errorf("invalid digest %q in the image store %s: %v", desc.Digest, s.dir, err)
+	}
+	return filepath.Join(s.dir, v1.ImageBlobsDir, desc.Digest.Algorithm().String(), desc.Digest.Encoded()), nil
+}
+
+// Archive returns the given image as a tar archive in the format of docker
+// save, which docker load and nerdctl load import with the name of the image.
+func (s *Store) Archive(image *Image) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(s.writeArchive(w, image))
+	}()
+	return r
+}
+
+// writeArchive writes the config and the layers of the image, followed by
+// the manifest.json file listing them, to w.
+func (s *Store) writeArchive(w io.Writer, image *Image) error {
+	tw := tar.NewWriter(w)
+	manifest := archiveManifest{RepoTags: []string{image.Name}}
+	written := map[string]bool{}
+	for i, desc := range append([]v1.Descriptor{image.Config}, image.Layers...) {
+		path, err := s.blobPath(desc)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(v1.ImageBlobsDir, desc.Digest.Algorithm().String(), desc.Digest.Encoded()))
+		if i == 0 {
+			manifest.Config = name
+		} else {
+			manifest.Layers = append(manifest.Layers, name)
+		}
+		if written[name] {
+			continue
+		}
+		written[name] = true
+		if err := writeFile(tw, name, path); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal([]archiveManifest{manifest})
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: archiveManifestFile, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// writeFile writes the file of the given path to the archive with the given
+// name.
+func writeFile(tw *tar.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: info.Size()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,166 @@
+package imagestore
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// writeBlob writes the given content to the blobs of the layout and returns
+// its descriptor.
+func writeBlob(t *testing.T, dir, mediaType string, content []byte) v1.Descriptor {
+	d := digest.FromBytes(content)
+	path := filepath.Join(dir, v1.ImageBlobsDir, d.Algorithm().String())
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, d.Encoded()), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return v1.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(content))}
+}
+
+// writeJSONBlob writes v as JSON to the blobs of the layout.
+func writeJSONBlob(t *testing.T, dir, mediaType string, v interface{}) v1.Descriptor {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeBlob(t, dir, mediaType, data)
+}
+
+// newLayout writes an OCI image layout holding a single-platform image named
+// with the OCI annotation, and a multi-platform image named with the
+// containerd annotation.
+func newLayout(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, v1.ImageLayoutFile), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := writeBlob(t, dir, v1.MediaTypeImageConfig, []byte(`{"architecture": "amd64", "os": "linux"}`))
+	layer := writeBlob(t, dir, v1.MediaTypeImageLayerGzip, []byte("layer"))
+	manifest := writeJSONBlob(t, dir, v1.MediaTypeImageManifest, v1.Manifest{
+		MediaType: v1.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []v1.Descriptor{layer, layer},
+	})
+	index := writeJSONBlob(t, dir, v1.MediaTypeImageIndex, v1.Index{
+		MediaType: v1.MediaTypeImageIndex,
+		Manifests: []v1.Descriptor{
+			{MediaType: v1.MediaTypeImageManifest, Digest: digest.FromString("other"), Platform: &v1.Platform{OS: "windows", Architecture: runtime.GOARCH}},
+			{MediaType: manifest.MediaType, Digest: manifest.Digest, Size: manifest.Size, Platform: &v1.Platform{OS: "linux", Architecture: runtime.GOARCH}},
+		},
+	})
+	manifest.Annotations = map[string]string{v1.AnnotationRefName: "quay.io/centos7/python-38-centos7:latest"}
+	index.Annotations = map[string]string{v1.AnnotationRefName: "3.9", containerdImageNameAnnotation: "docker.io/library/python:3.9"}
+	data, err := json.Marshal(v1.Index{MediaType: v1.MediaTypeImageIndex, Manifests: []v1.Descriptor{manifest, index}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, v1.ImageIndexFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLookup(t *testing.T) {
+	store, err := Open(newLayout(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		expected string
+		err      string
+	}{
+		{name: "quay.io/centos7/python-38-centos7", expected: "quay.io/centos7/python-38-centos7:latest"},
+		{name: "python:3.9", expected: "python:3.9"},
+		{name: "docker.io/library/python:3.9", expected: "python:3.9"},
+		{name: "python:3.10", err: "is not in the image store"},
+		{name: "python@sha256:" + strings.Repeat("a", 64), err: "referred to by digest"},
+	}
+	for _, tc := range tests {
+		image, err := store.Lookup(tc.name)
+		if len(tc.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if image.Name != tc.expected {
+			t.Errorf("%s: expected the image to be loaded as %q, got %q", tc.name, tc.expected, image.Name)
+		}
+		if len(image.Layers) != 2 || image.Size() != 2*int64(len("layer")) {
+			t.Errorf("%s: unexpected layers %v", tc.name, image.Layers)
+		}
+	}
+}
+
+func TestOpenNotALayout(t *testing.T) {
+	if _, err := Open(t.TempDir()); err == nil || !strings.Contains(err.Error(), "is not an OCI image layout") {
+		t.Errorf("expected an error, got %v", err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	store, err := Open(newLayout(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	image, err := store.Lookup("python:3.9")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	archive := store.Archive(image)
+	defer archive.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := files[hdr.Name]; ok {
+			t.Errorf("file %s is archived twice", hdr.Name)
+		}
+		if files[hdr.Name], err = io.ReadAll(tr); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var manifests []archiveManifest
+	if err := json.Unmarshal(files[archiveManifestFile], &manifests); err != nil {
+		t.Fatalf("unable to parse %s: %v", archiveManifestFile, err)
+	}
+	layer := "blobs/sha256/" + digest.FromString("layer").Encoded()
+	expected := []archiveManifest{{
+		Config:   "blobs/sha256/" + image.Config.Digest.Encoded(),
+		RepoTags: []string{"python:3.9"},
+		Layers:   []string{layer, layer},
+	}}
+	if !reflect.DeepEqual(manifests, expected) {
+		t.Errorf("expected the manifest %+v, got %+v", expected, manifests)
+	}
+	if string(files[layer]) != "layer" {
+		t.Errorf("unexpected content of the layer %q", files[layer])
+	}
+	if _, ok := files[expected[0].Config]; !ok {
+		t.Errorf("the config of the image is not archived")
+	}
+}
@@ -0,0 +1,4 @@
+// Package kubernetes executes S2I builds in a pod of a Kubernetes cluster,
+// driven through kubectl, so that a build triggered from a workstation runs
+// with the resources of the cluster.
+package kubernetes
@@ -0,0 +1,385 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/generate"
+	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	"github.com/openshift/source-to-image/pkg/util/interrupt"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+)
+
+var log = utillog.StderrLog
+
+// kubectlBinary is the name of the kubectl executable.
+const kubectlBinary = "kubectl"
+
+// pollInterval is the interval the status of the pod is polled at.
+var pollInterval = 2 * time.Second
+
+// startTimeout is the time a container of the pod is given to start, which
+// includes the scheduling of the pod and the pull of its images.
+var startTimeout = 10 * time.Minute
+
+// failedWaitingReasons are the reasons a container waits for which it never
+// starts from.
+var failedWaitingReasons = []string{"ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError", "CreateContainerError"}
+
+// Executor runs builds in pods created with kubectl, in the cluster of the
+// in-cluster configuration or of the kubeconfig file.
+type Executor struct {
+	binary string
+	config api.KubernetesConfig
+
+	// Out receives the output of the containers of the pods.
+	Out io.Writer
+}
+
+// New creates an executor running the builds in the cluster of the given
+// configuration.
+func New(config api.KubernetesConfig) (*Executor, error) {
+	binary, err := exec.LookPath(kubectlBinary)
+	if err != nil {
+		return nil, fmt.Errorf("the kubernetes executor requires %s to be installed: %v", kubectlBinary, err)
+	}
+	return &Executor{
+		binary: binary,
+		config: config,
+		Out:    os.Stdout,
+	}, nil
+}
+
+// command returns the kubectl command running the given arguments against the
+// configured cluster and namespace.
+func (e *Executor) command(ctx context.Context, args ...string) *exec.Cmd {
+	var global []string
+	if len(e.config.Kubeconfig) > 0 {
+		global = append(global, "--kubeconfig", e.config.Kubeconfig)
+	}
+	if len(e.config.Namespace) > 0 {
+		global = append(global, "--namespace", e.config.Namespace)
+	}
+	args = append(global, args...)
+	log.V(5).Infof("Running %s %s", e.binary, strings.Join(args, " "))
+	return exec.CommandContext(ctx, e.binary, args...)
+}
+
+// run runs kubectl with the given arguments and standard input, and returns
+// its standard output.
+func (e *Executor) run(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := e.command(ctx, args...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) == 0 {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%s %s: %s", kubectlBinary, args[0], msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Build runs the build of the given configuration in a pod, which generates
+// the Dockerfile of the build with s2i, builds the image with buildah and
+// pushes it. The local sources are uploaded to the pod, and the output of its
+// containers is streamed to Out. The pod is deleted once the build completes.
+// The callback URLs are called upon the start of the build, the push of the
+// image and the completion of the build.
+func (e *Executor) Build(config *api.Config) (*api.Result, error) {
+	invoker, err := util.NewCallbackInvoker(config)
+	if err != nil {
+		return nil, err
+	}
+	result := &api.Result{}
+	defer util.FireCallbacks(invoker, config, api.CallbackEventCompleted, result, nil)
+
+	name := podName(config.Tag)
+	opts := generate.Options{
+		Name:         name,
+		Namespace:    e.config.Namespace,
+		S2IImage:     e.config.S2IImage,
+		BuildahImage: e.config.BuildahImage,
+		PushSecret:   e.config.PushSecret,
+	}
+	// the values of the environment may be secrets, which are kept out of the
+	// manifest of the pod in a secret named after it
+	resources := []string{"pod/" + name}
+	var objects []interface{}
+	if len(config.Environment) > 0 {
+		opts.EnvironmentSecret = name
+		secret, err := generate.EnvironmentSecret(config, opts)
+		if err != nil {
+			return result, err
+		}
+		objects = append(objects, secret)
+		resources = append(resources, "secret/"+name)
+	}
+	// the image is pushed with the credentials given on the command line,
+	// which are kept in a secret named after the pod too
+	if len(config.PushCredentials.Username) > 0 {
+		opts.PushSecret = name + "-push"
+		secret, err := generate.PushSecret(config, opts)
+		if err != nil {
+			return result, err
+		}
+		objects = append(objects, secret)
+		resources = append(resources, "secret/"+opts.PushSecret)
+	}
+	pod, err := generate.KubernetesPod(config, opts)
+	if err != nil {
+		return result, err
+	}
+	manifest := &bytes.Buffer{}
+	if err := generate.Write(manifest, append(objects, pod)...); err != nil {
+		return result, err
+	}
+	ctx := context.Background()
+	if config.MaxBuildDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.MaxBuildDuration)
+		defer cancel()
+	}
+	if _, err := e.run(ctx, manifest, "create", "-f", "-"); err != nil {
+		return result, e.buildError(ctx, config, result, err)
+	}
+	log.V(0).Infof("Building %s in pod %s", config.Tag, name)
+	defer e.delete(resources...)
+	defer interrupt.Register(func(os.Signal) { e.delete(resources...) })()
+	util.FireCallbacks(invoker, config, api.CallbackEventStarted, result, nil)
+
+	containers := pod.Spec.InitContainers
+	if config.Source.IsLocal() {
+		if err := e.upload(ctx, name, config); err != nil {
+			return result, e.buildError(ctx, config, result, err)
+		}
+		containers = containers[1:]
+	}
+	for _, c := range append(containers, pod.Spec.Containers...) {
+		if err := e.runContainer(ctx, name, c.Name); err != nil {
+			return result, e.buildError(ctx, config, result, err)
+		}
+	}
+	log.V(0).Infof("Image %s built in pod %s and pushed", config.Tag, name)
+	result.Success = true
+	util.FireCallbacks(invoker, config, api.CallbackEventPushed, result, nil)
+	return result, nil
+}
+
+// buildError returns the error the build failed with, which is the maximum
+// duration of the build when the commands were canceled by its deadline.
+func (e *Executor) buildError(ctx context.Context, config *api.Config, result *api.Result, err error) error {
+	if ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	log.V(1).Infof("The build failed after its deadline: %v", err)
+	result.BuildInfo.FailureReason = utilstatus.NewFailureReason(
+		utilstatus.ReasonQuotaExceeded,
+		utilstatus.ReasonMessageQuotaExceeded,
+	)
+	return s2ierr.NewBuildDurationExceededError(config.MaxBuildDuration)
+}
+
+// upload uploads the local sources of the given configuration to the upload
+// container of the pod, once it runs.
+func (e *Executor) upload(ctx context.Context, name string, config *api.Config) error {
+	if _, err := e.waitForContainer(ctx, name, generate.UploadContainer, false); err != nil {
+		return err
+	}
+	t := tar.New(fs.NewFileSystem())
+	if len(config.ExcludeRegExp) > 0 {
+		exclude, err := regexp.Compile(config.ExcludeRegExp)
+		if err != nil {
+			return err
+		}
+		t.SetExclusionPattern(exclude)
+	}
+	excludeGlobs, err := ignore.NewGlobMatcher(config.ExcludeGlobs, config.IncludeGlobs)
+	if err != nil {
+		return err
+	}
+	t.SetExclusionGlobs(excludeGlobs)
+	t.SetSymlinkPolicy(config.SymlinkPolicy)
+	t.SetCRLFConversion(config.ConvertCRLF, filepath.Join(".s2i", "bin"))
+	dir := config.Source.LocalPath()
+	log.V(1).Infof("Uploading the sources of %s to pod %s", dir, name)
+	stream := t.CreateTarStreamReader(dir, false)
+	defer stream.Close()
+	args := append([]string{"exec", "-i", name, "-c", generate.UploadContainer, "--"}, generate.UploadCommand()...)
+	if _, err := e.run(ctx, stream, args...); err != nil {
+		return fmt.Errorf("unable to upload the sources to pod %s: %v", name, err)
+	}
+	return nil
+}
+
+// runContainer streams the output of the given container of the pod to Out,
+// once it starts, and returns an error when it fails.
+func (e *Executor) runContainer(ctx context.Context, pod, name string) error {
+	if _, err := e.waitForContainer(ctx, pod, name, false); err != nil {
+		return err
+	}
+	cmd := e.command(ctx, "logs", "--follow", pod, "-c", name)
+	cmd.Stdout = e.Out
+	cmd.Stderr = e.Out
+	if err := cmd.Run(); err != nil {
+		log.V(1).Infof("Unable to stream the output of container %s of pod %s: %v", name, pod, err)
+	}
+	status, err := e.waitForContainer(ctx, pod, name, true)
+	if err != nil {
+		return err
+	}
+	if code := status.State.Terminated.ExitCode; code != 0 {
+		return s2ierr.NewContainerError(fmt.Sprintf("container %s of pod %s", name, pod), code, "")
+	}
+	return nil
+}
+
+// waitForContainer polls the status of the given container of the pod until
+// it runs, or until it terminates when terminated is true. A container which
+// does not run within the startTimeout is an error.
+func (e *Executor) waitForContainer(ctx context.Context, pod, name string, terminated bool) (*containerStatus, error) {
+	deadline := time.Now().Add(startTimeout)
+	for {
+		out, err := e.run(ctx, nil, "get", "pod", pod, "--output", "json")
+		if err != nil {
+			return nil, err
+		}
+		status, err := parsePodStatus(out)
+		if err != nil {
+			return nil, err
+		}
+		c, err := status.wait(pod, name, terminated)
+		if c != nil || err != nil {
+			return c, err
+		}
+		if !terminated && time.Now().After(deadline) {
+			return nil, fmt.Errorf("container %s of pod %s did not start within %v", name, pod, startTimeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// delete deletes the given resources, e.g. pod/name, without waiting for
+// their termination.
+func (e *Executor) delete(resources ...string) {
+	args := append([]string{"delete"}, resources...)
+	if _, err := e.run(context.Background(), nil, append(args, "--wait=false", "--ignore-not-found")...); err != nil {
+		log.Warningf("Unable to delete %s: %v", strings.Join(resources, ", "), err)
+	}
+}
+
+// podStatus is the subset of a pod holding the status of its containers.
+type podStatus struct {
+	Status struct {
+		Phase                 string            `json:"phase"`
+		Message               string            `json:"message"`
+		Conditions            []podCondition    `json:"conditions"`
+		InitContainerStatuses []containerStatus `json:"initContainerStatuses"`
+		ContainerStatuses     []containerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// podCondition is a condition of a pod.
+type podCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// containerStatus is the status of a container of a pod.
+type containerStatus struct {
+	Name  string `json:"name"`
+	State struct {
+		Waiting *struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"waiting"`
+		Running    *struct{} `json:"running"`
+		Terminated *struct {
+			ExitCode int    `json:"exitCode"`
+			Reason   string `json:"reason"`
+		} `json:"terminated"`
+	} `json:"state"`
+}
+
+// parsePodStatus parses the status of the pod printed by kubectl get.
+func parsePodStatus(data []byte) (*podStatus, error) {
+	status := &podStatus{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, fmt.Errorf("unable to parse the status of the pod: %v", err)
+	}
+	return status, nil
+}
+
+// container returns the status of the given container of the pod, or nil if
+// it is not reported yet.
+func (s *podStatus) container(name string) *containerStatus {
+	for _, statuses := range [][]containerStatus{s.Status.InitContainerStatuses, s.Status.ContainerStatuses} {
+		for i := range statuses {
+			if statuses[i].Name == name {
+				return &statuses[i]
+			}
+		}
+	}
+	return nil
+}
+
+// wait returns the status of the given container of the pod when it runs, or
+// when it terminated if terminated is true, an error when it cannot start or
+// run anymore, or nil while it has to be waited for.
+func (s *podStatus) wait(pod, name string, terminated bool) (*containerStatus, error) {
+	c := s.container(name)
+	switch {
+	case c != nil && c.State.Terminated != nil:
+		return c, nil
+	case c != nil && c.State.Running != nil && !terminated:
+		return c, nil
+	case c != nil && c.State.Waiting != nil && isFailedWaitingReason(c.State.Waiting.Reason):
+		return nil, fmt.Errorf("container %s of pod %s cannot start: %s: %s", name, pod, c.State.Waiting.Reason, c.State.Waiting.Message)
+	case s.Status.Phase == "Failed":
+		return nil, fmt.Errorf("pod %s failed before container %s completed: %s", pod, name, s.Status.Message)
+	}
+	for _, condition := range s.Status.Conditions {
+		if condition.Type == "PodScheduled" && condition.Status == "False" && condition.Reason == "Unschedulable" {
+			return nil, fmt.Errorf("pod %s cannot be scheduled: %s", pod, condition.Message)
+		}
+	}
+	return nil, nil
+}
+
+// isFailedWaitingReason returns true if a container waiting for the given
+// reason never starts.
+func isFailedWaitingReason(reason string) bool {
+	for _, r := range failedWaitingReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// podName returns a unique name of the pod building the given image.
+func podName(tag string) string {
+	return generate.Name(tag) + "-" + uuid.New().String()[:8]
+}
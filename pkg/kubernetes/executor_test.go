@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPodStatusWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		container  string
+		terminated bool
+		ready      bool
+		err        string
+	}{
+		{
+			name:      "not reported",
+			status:    `{"status": {"phase": "Pending"}}`,
+			container: "generate",
+		},
+		{
+			name:      "running",
+			status:    `{"status": {"phase": "Pending", "initContainerStatuses": [{"name": "upload", "state": {"running": {"startedAt": "2024-01-01T00:00:00Z"}}}]}}`,
+			container: "upload",
+			ready:     true,
+		},
+		{
+			name:       "running until terminated",
+			status:     `{"status": {"phase": "Pending", "initContainerStatuses": [{"name": "generate", "state": {"running": {}}}]}}`,
+			container:  "generate",
+			terminated: true,
+		},
+		{
+			name:       "terminated",
+			status:     `{"status": {"phase": "Running", "containerStatuses": [{"name": "push", "state": {"terminated": {"exitCode": 1}}}]}}`,
+			container:  "push",
+			terminated: true,
+			ready:      true,
+		},
+		{
+			name:      "image pull failure",
+			status:    `{"status": {"phase": "Pending", "initContainerStatuses": [{"name": "generate", "state": {"waiting": {"reason": "ImagePullBackOff", "message": "Back-off pulling image"}}}]}}`,
+			container: "generate",
+			err:       "cannot start: ImagePullBackOff",
+		},
+		{
+			name:      "pod failed",
+			status:    `{"status": {"phase": "Failed", "message": "evicted", "containerStatuses": [{"name": "push", "state": {"waiting": {"reason": "PodInitializing"}}}]}}`,
+			container: "push",
+			err:       "failed before container push completed: evicted",
+		},
+		{
+			name:      "unschedulable",
+			status:    `{"status": {"phase": "Pending", "conditions": [{"type": "PodScheduled", "status": "False", "reason": "Unschedulable", "message": "0/3 nodes are available: 3 Insufficient memory."}]}}`,
+			container: "upload",
+			err:       "cannot be scheduled: 0/3 nodes are available",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, err := parsePodStatus([]byte(tc.status))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			c, err := status.wait("build", tc.container, tc.terminated)
+			if len(tc.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ready := c != nil; ready != tc.ready {
+				t.Errorf("expected ready to be %t, got %t", tc.ready, ready)
+			}
+		})
+	}
+}
+
+func TestPodName(t *testing.T) {
+	name := podName("quay.io/user/ruby_app:latest")
+	if !strings.HasPrefix(name, "ruby-app-build-") || len(name) != len("ruby-app-build-")+8 {
+		t.Errorf("unexpected pod name %q", name)
+	}
+	if podName("app") == podName("app") {
+		t.Errorf("expected the pod names to be unique")
+	}
+}
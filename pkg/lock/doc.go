@@ -0,0 +1,3 @@
+// Package lock reads, writes and compares the lockfiles recording the inputs
+// resolved by S2I builds.
+package lock
@@ -0,0 +1,170 @@
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+// DefaultFile is the lockfile used when none is specified.
+const DefaultFile = "s2i.lock.json"
+
+// Version is the version of the lockfile format.
+const Version = 1
+
+// Lock records the inputs resolved by a build.
+type Lock struct {
+	Version      int      `json:"lockfileVersion"`
+	BuilderImage Image    `json:"builderImage"`
+	RuntimeImage *Image   `json:"runtimeImage,omitempty"`
+	Source       *Source  `json:"source,omitempty"`
+	Scripts      []Script `json:"scripts,omitempty"`
+	Environment  []string `json:"environment,omitempty"`
+}
+
+// Image is an image resolved by a build.
+type Image struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// Source is the source code resolved by a build.
+type Source struct {
+	URL    string `json:"url"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// Script is a S2I script resolved by a build. Scripts provided by the builder
+// image are identified by their URL, the others by the checksum of their
+// content.
+type Script struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// File returns the lockfile of the given configuration.
+func File(config *api.Config) string {
+	if len(config.LockFile) > 0 {
+		return config.LockFile
+	}
+	return DefaultFile
+}
+
+// Read reads the given lockfile.
+func Read(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lock{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("unable to parse the lockfile %s: %v", path, err)
+	}
+	if l.Version != Version {
+		return nil, fmt.Errorf("unsupported version %d of the lockfile %s", l.Version, path)
+	}
+	return l, nil
+}
+
+// Write writes the given lock to the given lockfile.
+func Write(path string, l *Lock) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Checksum returns the SHA-256 checksum of the given content.
+func Checksum(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Environment returns the locked form of the given environment: the sorted
+// names of its variables. Their values are not recorded, not even as
+// checksums, since the values of secrets with little entropy could be
+// recovered from them.
+func Environment(env api.EnvironmentList) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, e := range env {
+		names[e.Name] = true
+	}
+	locked := make([]string, 0, len(names))
+	for name := range names {
+		locked = append(locked, name)
+	}
+	sort.Strings(locked)
+	return locked
+}
+
+// flatten returns the inputs recorded by the lock, keyed by a description of
+// each input.
+func (l *Lock) flatten() map[string]string {
+	inputs := map[string]string{
+		"builder image " + l.BuilderImage.Name: l.BuilderImage.ID,
+	}
+	if l.RuntimeImage != nil {
+		inputs["runtime image "+l.RuntimeImage.Name] = l.RuntimeImage.ID
+	}
+	if l.Source != nil {
+		inputs["source "+l.Source.URL] = strings.TrimSpace(l.Source.Ref + " " + l.Source.Commit)
+	}
+	for _, s := range l.Scripts {
+		inputs["script "+s.Name] = s.URL + s.SHA256
+	}
+	for _, name := range l.Environment {
+		inputs["environment variable "+name] = ""
+	}
+	return inputs
+}
+
+// Diff returns the differences between the locked and the resolved inputs.
+func Diff(locked, resolved *Lock) []string {
+	expected, actual := locked.flatten(), resolved.flatten()
+	diffs := []string{}
+	for input, value := range expected {
+		v, ok := actual[input]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s is locked but not used", input))
+		case v != value:
+			diffs = append(diffs, fmt.Sprintf("%s resolved to %q, locked to %q", input, v, value))
+		}
+	}
+	for input := range actual {
+		if _, ok := expected[input]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s is used but not locked", input))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// Verify returns an error listing the differences between the inputs locked in
+// the given lockfile and the resolved ones.
+func Verify(path string, resolved *Lock) error {
+	locked, err := Read(path)
+	if err != nil {
+		return err
+	}
+	diffs := Diff(locked, resolved)
+	if len(diffs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the resolved inputs differ from the lockfile %s:\n  - %s", path, strings.Join(diffs, "\n  - "))
+}
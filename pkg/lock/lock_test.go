@@ -0,0 +1,129 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+func newTestLock() *Lock {
+	return &Lock{
+		Version:      Version,
+		BuilderImage: Image{Name: "quay.io/org/ruby:3.3", ID: "sha256:1111"},
+		Source:       &Source{URL: "https://github.com/openshift/ruby-hello-world", Ref: "beta4", Commit: "abcdef"},
+		Scripts: []Script{
+			{Name: "assemble", SHA256: "0123"},
+			{Name: "run", URL: "image:///usr/libexec/s2i/run"},
+		},
+		Environment: Environment(api.EnvironmentList{{Name: "TOKEN", Value: "secret"}}),
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	l := newTestLock()
+	if err := Write(path, l); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("the lockfile holds the value of an environment variable: %s", data)
+	}
+	read, err := Read(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read, l) {
+		t.Errorf("expected %#v, got %#v", l, read)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"lockfileVersion":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(path); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := map[string]struct {
+		change   func(*Lock)
+		expected []string
+	}{
+		"same": {
+			change:   func(*Lock) {},
+			expected: []string{},
+		},
+		"builder image": {
+			change: func(l *Lock) { l.BuilderImage.ID = "sha256:2222" },
+			expected: []string{
+				`builder image quay.io/org/ruby:3.3 resolved to "sha256:2222", locked to "sha256:1111"`,
+			},
+		},
+		"commit": {
+			change: func(l *Lock) { l.Source.Commit = "123456" },
+			expected: []string{
+				`source https://github.com/openshift/ruby-hello-world resolved to "beta4 123456", locked to "beta4 abcdef"`,
+			},
+		},
+		"scripts": {
+			change: func(l *Lock) {
+				l.Scripts = []Script{{Name: "assemble", SHA256: "4567"}, {Name: "save-artifacts", URL: "image:///usr/libexec/s2i/save-artifacts"}}
+			},
+			expected: []string{
+				`script assemble resolved to "4567", locked to "0123"`,
+				"script run is locked but not used",
+				"script save-artifacts is used but not locked",
+			},
+		},
+		"environment": {
+			change: func(l *Lock) {
+				l.Environment = Environment(api.EnvironmentList{{Name: "TOKEN", Value: "other"}, {Name: "DEBUG", Value: "true"}})
+			},
+			expected: []string{
+				"environment variable DEBUG is used but not locked",
+			},
+		},
+		"runtime image": {
+			change: func(l *Lock) { l.RuntimeImage = &Image{Name: "quay.io/org/runtime", ID: "sha256:3333"} },
+			expected: []string{
+				"runtime image quay.io/org/runtime is used but not locked",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resolved := newTestLock()
+			tc.change(resolved)
+			diffs := Diff(newTestLock(), resolved)
+			if !reflect.DeepEqual(diffs, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, diffs)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := Write(path, newTestLock()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(path, newTestLock()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	resolved := newTestLock()
+	resolved.BuilderImage.ID = "sha256:2222"
+	if err := Verify(path, resolved); err == nil || !strings.Contains(err.Error(), "builder image") {
+		t.Errorf("expected a builder image mismatch, got %v", err)
+	}
+	if err := Verify(filepath.Join(t.TempDir(), "missing.json"), resolved); err == nil {
+		t.Errorf("expected an error for a missing lockfile")
+	}
+}
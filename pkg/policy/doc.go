@@ -0,0 +1,3 @@
+// Package policy evaluates Open Policy Agent (OPA) Rego policies to admit or
+// deny S2I builds before they run.
+package policy
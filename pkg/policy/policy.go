@@ -0,0 +1,198 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// Query is the Rego query evaluated against the policies. Each value of the
+// deny set is a message describing a rule violated by the build.
+const Query = "data.s2i.deny"
+
+// Input is the document passed as input to the policies.
+type Input struct {
+	Config       ConfigInput `json:"config"`
+	BuilderImage ImageInput  `json:"builderImage"`
+}
+
+// ConfigInput is the subset of the build configuration passed to the
+// policies. Credentials and the values of the environment variables are left
+// out as they may hold secrets.
+type ConfigInput struct {
+	BuilderImage      string            `json:"builderImage"`
+	RuntimeImage      string            `json:"runtimeImage,omitempty"`
+	Tag               string            `json:"tag,omitempty"`
+	Source            string            `json:"source,omitempty"`
+	ContextDir        string            `json:"contextDir,omitempty"`
+	ScriptsURL        string            `json:"scriptsURL,omitempty"`
+	AssembleUser      string            `json:"assembleUser,omitempty"`
+	Incremental       bool              `json:"incremental"`
+	DockerNetworkMode string            `json:"networkMode,omitempty"`
+	BuilderPullPolicy string            `json:"builderPullPolicy,omitempty"`
+	Labels            map[string]string `json:"labels"`
+	Environment       []string          `json:"environment"`
+}
+
+// ImageInput is the metadata of the builder image passed to the policies.
+type ImageInput struct {
+	Name    string            `json:"name"`
+	ID      string            `json:"id"`
+	Labels  map[string]string `json:"labels"`
+	Env     []string          `json:"env"`
+	OnBuild bool              `json:"onBuild"`
+}
+
+// NewInput returns the policy input of the given build configuration and
+// builder image.
+func NewInput(config *api.Config, image *api.Image, onBuild bool) *Input {
+	input := &Input{
+		Config: ConfigInput{
+			BuilderImage:      config.BuilderImage,
+			RuntimeImage:      config.RuntimeImage,
+			Tag:               config.Tag,
+			ContextDir:        hostpath.ToSlash(config.ContextDir),
+			ScriptsURL:        config.ScriptsURL,
+			AssembleUser:      config.AssembleUser,
+			Incremental:       config.Incremental,
+			DockerNetworkMode: string(config.DockerNetworkMode),
+			BuilderPullPolicy: string(config.BuilderPullPolicy),
+			Labels:            map[string]string{},
+			Environment:       []string{},
+		},
+		BuilderImage: ImageInput{
+			Name:    config.BuilderImage,
+			Labels:  map[string]string{},
+			Env:     []string{},
+			OnBuild: onBuild,
+		},
+	}
+	if config.Source != nil {
+		input.Config.Source = config.Source.String()
+	}
+	for k, v := range config.Labels {
+		input.Config.Labels[k] = v
+	}
+	for _, env := range config.Environment {
+		input.Config.Environment = append(input.Config.Environment, env.Name)
+	}
+	if image != nil {
+		input.BuilderImage.ID = image.ID
+		if image.Config != nil {
+			for k, v := range image.Config.Labels {
+				input.BuilderImage.Labels[k] = v
+			}
+			input.BuilderImage.Env = append(input.BuilderImage.Env, image.Config.Env...)
+		}
+	}
+	return input
+}
+
+// DeniedError is returned when the policies deny a build.
+type DeniedError struct {
+	Violations []string
+}
+
+// Error returns the violations reported by the policies.
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("build denied by policy:\n  - %s", strings.Join(e.Violations, "\n  - "))
+}
+
+// Evaluate evaluates the policies of the given directory against the given
+// input using the opa binary found in the PATH, and returns the violations
+// reported by the policies.
+func Evaluate(dir string, input *Input) ([]string, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("unable to read the policies: %v", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("unable to read the policies: %s is not a directory", dir)
+	}
+	binary, err := exec.LookPath("opa")
+	if err != nil {
+		return nil, fmt.Errorf("opa must be installed to evaluate the policies: %v", err)
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(binary, "eval", "--format", "json", "--data", dir, "--stdin-input", Query)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	log.V(2).Infof("Evaluating policies: %s %s", binary, strings.Join(cmd.Args[1:], " "))
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("opa failed: %v: %s", err, strings.TrimSpace(stderr.String()+stdout.String()))
+	}
+	violations, err := parseResult(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the result of opa: %v", err)
+	}
+	return violations, nil
+}
+
+// evalResult is the subset of the JSON output of opa eval.
+type evalResult struct {
+	Result []struct {
+		Expressions []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+// parseResult returns the violations of the given opa eval output. The deny
+// set may hold strings or any other value, which is reported as JSON. An
+// undefined deny set denies nothing.
+func parseResult(data []byte) ([]string, error) {
+	result := evalResult{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	violations := []string{}
+	for _, r := range result.Result {
+		for _, expression := range r.Expressions {
+			values := []json.RawMessage{}
+			if err := json.Unmarshal(expression.Value, &values); err != nil {
+				return nil, fmt.Errorf("%s must be a set of messages: %v", Query, err)
+			}
+			for _, value := range values {
+				var message string
+				if err := json.Unmarshal(value, &message); err != nil {
+					message = string(value)
+				}
+				violations = append(violations, message)
+			}
+		}
+	}
+	sort.Strings(violations)
+	return violations, nil
+}
+
+// Admit evaluates the policies of the given configuration against the build
+// and its builder image, and returns a DeniedError if they deny the build.
+// Nothing is done when no policy directory is configured.
+func Admit(config *api.Config, image *api.Image, onBuild bool) error {
+	if len(config.PolicyDir) == 0 {
+		return nil
+	}
+	log.V(1).Infof("Evaluating the policies of %s", config.PolicyDir)
+	violations, err := Evaluate(config.PolicyDir, NewInput(config, image, onBuild))
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &DeniedError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+func TestParseResult(t *testing.T) {
+	tests := map[string]struct {
+		output    string
+		expected  []string
+		expectErr bool
+	}{
+		"undefined": {
+			output:   `{}`,
+			expected: []string{},
+		},
+		"empty": {
+			output:   `{"result":[{"expressions":[{"value":[],"text":"data.s2i.deny"}]}]}`,
+			expected: []string{},
+		},
+		"messages": {
+			output:   `{"result":[{"expressions":[{"value":["missing label io.k8s.display-name","builder image docker.io/centos is not approved",{"rule":"x"}],"text":"data.s2i.deny"}]}]}`,
+			expected: []string{"builder image docker.io/centos is not approved", "missing label io.k8s.display-name", `{"rule":"x"}`},
+		},
+		"not a set": {
+			output:    `{"result":[{"expressions":[{"value":true,"text":"data.s2i.deny"}]}]}`,
+			expectErr: true,
+		},
+		"invalid": {
+			output:    `not json`,
+			expectErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			violations, err := parseResult([]byte(tc.output))
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", violations)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(violations, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, violations)
+			}
+		})
+	}
+}
+
+func TestNewInput(t *testing.T) {
+	source, err := git.Parse("https://github.com/openshift/ruby-hello-world#beta4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &api.Config{
+		BuilderImage:       "quay.io/org/ruby:3.3",
+		Tag:                "hello",
+		Source:             source,
+		Labels:             map[string]string{"team": "web"},
+		Environment:        api.EnvironmentList{{Name: "TOKEN", Value: "secret"}},
+		PullAuthentication: api.AuthConfig{Username: "user", Password: "password"},
+	}
+	image := &api.Image{
+		ID: "sha256:1234",
+		Config: &api.ContainerConfig{
+			Labels: map[string]string{"io.openshift.s2i.scripts-url": "image:///usr/libexec/s2i"},
+			Env:    []string{"PATH=/usr/bin"},
+		},
+	}
+	input := NewInput(config, image, true)
+	expected := &Input{
+		Config: ConfigInput{
+			BuilderImage: "quay.io/org/ruby:3.3",
+			Tag:          "hello",
+			Source:       source.String(),
+			Labels:       map[string]string{"team": "web"},
+			Environment:  []string{"TOKEN"},
+		},
+		BuilderImage: ImageInput{
+			Name:    "quay.io/org/ruby:3.3",
+			ID:      "sha256:1234",
+			Labels:  map[string]string{"io.openshift.s2i.scripts-url": "image:///usr/libexec/s2i"},
+			Env:     []string{"PATH=/usr/bin"},
+			OnBuild: true,
+		},
+	}
+	if !reflect.DeepEqual(input, expected) {
+		t.Errorf("expected %#v, got %#v", expected, input)
+	}
+}
+
+func TestAdmitWithoutPolicies(t *testing.T) {
+	if err := Admit(&api.Config{}, nil, false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDeniedError(t *testing.T) {
+	err := &DeniedError{Violations: []string{"first", "second"}}
+	expected := "build denied by policy:\n  - first\n  - second"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
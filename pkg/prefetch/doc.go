@@ -0,0 +1,3 @@
+// Package prefetch pulls the builder and runtime images of the builds ahead of
+// time, so that the first builds run on a node do not wait for them.
+package prefetch
@@ -0,0 +1,127 @@
+package prefetch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/reference"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// DefaultParallel is the default number of images pulled at the same time.
+const DefaultParallel = 4
+
+// Options are the options of a prefetch.
+type Options struct {
+	// Images are the images pulled. An image pinned by digest, optionally
+	// with a tag, e.g. ruby:3.2@sha256:..., is pulled by digest and tagged
+	// with its tag.
+	Images []string
+	// Parallel is the number of images pulled at the same time.
+	Parallel int
+	// PullPolicy specifies when to pull the images.
+	PullPolicy api.PullPolicy
+}
+
+// Result is the outcome of the prefetch of an image.
+type Result struct {
+	// Image is the image as listed.
+	Image string
+	// ID is the ID of the image, when it was pulled.
+	ID string
+	// Duration is the time taken to pull the image.
+	Duration time.Duration
+	// Err is the reason the image cannot be pulled or verified.
+	Err error
+}
+
+// ReadList reads the images listed one per line. Blank lines and the lines
+// starting with # are ignored.
+func ReadList(r io.Reader) ([]string, error) {
+	images := []string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		image := strings.TrimSpace(scanner.Text())
+		if len(image) == 0 || strings.HasPrefix(image, "#") {
+			continue
+		}
+		if _, err := reference.ParseNormalizedNamed(image); err != nil {
+			return nil, fmt.Errorf("line %d: invalid image %q: %v", line, image, err)
+		}
+		images = append(images, image)
+	}
+	return images, scanner.Err()
+}
+
+// Prefetch pulls the images concurrently, each with the Docker returned by
+// newDocker for it, and returns the results in the order of the images.
+func Prefetch(newDocker func(image string) docker.Docker, opts Options) []Result {
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+	policy := opts.PullPolicy
+	if len(policy) == 0 {
+		policy = api.PullIfNotPresent
+	}
+
+	results := make([]Result, len(opts.Images))
+	slots := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+	for i, image := range opts.Images {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, image string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			startTime := time.Now()
+			id, err := pull(newDocker(image), image, policy)
+			results[i] = Result{Image: image, ID: id, Duration: time.Since(startTime), Err: err}
+		}(i, image)
+	}
+	wg.Wait()
+	return results
+}
+
+// pull pulls the image and returns its ID. An image pinned by digest is
+// pulled by digest, the engine verifying that its content matches the digest,
+// and tagged with its tag if it has one.
+func pull(d docker.Docker, image string, policy api.PullPolicy) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	name := image
+	digested, pinned := named.(reference.Digested)
+	if pinned {
+		canonical, err := reference.WithDigest(reference.TrimNamed(named), digested.Digest())
+		if err != nil {
+			return "", err
+		}
+		name = reference.FamiliarString(canonical)
+	}
+	log.V(1).Infof("Pulling image %s", name)
+	if _, err := docker.PullImage(name, d, policy); err != nil {
+		return "", err
+	}
+	id, err := d.GetImageID(name)
+	if err != nil {
+		return "", fmt.Errorf("unable to verify image %s: %v", name, err)
+	}
+	if tagged, ok := named.(reference.Tagged); ok && pinned {
+		tag := reference.FamiliarName(named) + ":" + tagged.Tag()
+		if err := d.TagImage(id, tag); err != nil {
+			return "", fmt.Errorf("unable to tag image %s as %s: %v", name, tag, err)
+		}
+	}
+	return id, nil
+}
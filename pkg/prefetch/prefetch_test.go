@@ -0,0 +1,86 @@
+package prefetch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+)
+
+const digest = "sha256:4d6a5f1b7e0c3a2d9b8e7f6a5c4d3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c"
+
+func TestReadList(t *testing.T) {
+	list := `
+# builders
+centos/ruby-25-centos7
+  registry.example.com/runtime:1.0
+
+quay.io/app/builder:3.2@` + digest + `
+`
+	images, err := ReadList(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"centos/ruby-25-centos7", "registry.example.com/runtime:1.0", "quay.io/app/builder:3.2@" + digest}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected %v, got %v", expected, images)
+	}
+
+	if _, err := ReadList(strings.NewReader("ruby\nInvalid Image\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	mu := sync.Mutex{}
+	fakes := map[string]*docker.FakeDocker{}
+	newDocker := func(image string) docker.Docker {
+		mu.Lock()
+		defer mu.Unlock()
+		fake := &docker.FakeDocker{PullResult: true, GetImageIDResult: "id-" + image}
+		if strings.HasPrefix(image, "missing") {
+			fake.PullResult = false
+			fake.PullError = errors.New("not found")
+		}
+		fakes[image] = fake
+		return fake
+	}
+	pinned := "quay.io/app/builder:3.2@" + digest
+	opts := Options{
+		Images:     []string{"ruby", "missing", pinned},
+		Parallel:   2,
+		PullPolicy: api.PullAlways,
+	}
+	results := Prefetch(newDocker, opts)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, image := range opts.Images {
+		if results[i].Image != image {
+			t.Errorf("expected result %d to be of %s, got %s", i, image, results[i].Image)
+		}
+	}
+	if results[0].Err != nil || results[0].ID != "id-ruby" {
+		t.Errorf("expected ruby to be pulled, got %+v", results[0])
+	}
+	if results[1].Err == nil {
+		t.Errorf("expected the pull of missing to fail")
+	}
+	if results[2].Err != nil {
+		t.Errorf("unexpected error pulling %s: %v", pinned, results[2].Err)
+	}
+	fake := fakes[pinned]
+	if expected := "quay.io/app/builder@" + digest; fake.GetImageIDImage != expected {
+		t.Errorf("expected %s to be pulled by digest, got %s", pinned, fake.GetImageIDImage)
+	}
+	if expected := []string{"quay.io/app/builder:3.2"}; !reflect.DeepEqual(fake.TagImageTags, expected) {
+		t.Errorf("expected the image to be tagged %v, got %v", expected, fake.TagImageTags)
+	}
+	if len(fakes["ruby"].TagImageTags) != 0 {
+		t.Errorf("expected ruby not to be tagged, got %v", fakes["ruby"].TagImageTags)
+	}
+}
@@ -0,0 +1,3 @@
+// Package render renders the templates of the source tree, substituting the
+// values of the build into them before the sources are uploaded.
+package render
@@ -0,0 +1,137 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/ignore"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/scripts"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// TemplateExtension is the extension of the templates, stripped from the name
+// of the files they are rendered to.
+const TemplateExtension = ".tmpl"
+
+// Data is the data the templates are rendered with.
+type Data struct {
+	// Env holds the environment variables of the build, e.g. {{ .Env.NAME }}.
+	Env map[string]string
+	// Source describes the sources, e.g. {{ .Source.CommitID }}.
+	Source git.SourceInfo
+	// BuilderImage is the builder image of the build.
+	BuilderImage string
+	// Tag is the tag of the resulting image.
+	Tag string
+}
+
+// NewData returns the data of the build described by the config, the source
+// information and the environment variables.
+func NewData(config *api.Config, info *git.SourceInfo, env api.EnvironmentList) Data {
+	data := Data{
+		Env:          map[string]string{},
+		BuilderImage: config.BuilderImage,
+		Tag:          config.Tag,
+	}
+	if info != nil {
+		data.Source = *info
+	}
+	for _, e := range env {
+		data.Env[e.Name] = e.Value
+	}
+	return data
+}
+
+// Render renders the regular files of the source directory matched by the
+// gitignore style globs as Go templates. A template named with the
+// TemplateExtension is rendered to the file without the extension, replacing
+// the template, while the other templates are rendered in place. Referring to
+// missing environment variables fails the rendering.
+func Render(fs fs.FileSystem, dir string, globs []string, data Data) error {
+	matcher, err := ignore.NewGlobMatcher(globs, nil)
+	if err != nil || matcher == nil {
+		return err
+	}
+	var templates []string
+	err = fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if info.Mode().IsRegular() && matcher.Match(filepath.ToSlash(rel), false) {
+			templates = append(templates, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range templates {
+		if err := renderFile(fs, dir, path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderFile renders the template at path.
+func renderFile(fs fs.FileSystem, dir, path string, data Data) error {
+	rel, _ := filepath.Rel(dir, path)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(rel).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("unable to parse the template %s: %v", rel, err)
+	}
+	out := &bytes.Buffer{}
+	if err := tmpl.Execute(out, data); err != nil {
+		return fmt.Errorf("unable to render the template %s: %v", rel, err)
+	}
+	info, err := fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	target := strings.TrimSuffix(path, TemplateExtension)
+	log.V(2).Infof("Rendering the template %s to %s", rel, filepath.Base(target))
+	if err := fs.WriteFile(path, out.Bytes()); err != nil {
+		return err
+	}
+	if err := fs.Chmod(path, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if target != path {
+		return fs.Rename(path, target)
+	}
+	return nil
+}
+
+// Sources renders the templates of the sources of the build matched by the
+// RenderTemplates of the config, with the environment of the build: the
+// variables describing the sources, then the ones of the .s2i/environment
+// file, then the ones of the config.
+func Sources(fs fs.FileSystem, config *api.Config, info *git.SourceInfo) error {
+	if len(config.RenderTemplates) == 0 {
+		return nil
+	}
+	env := scripts.SourceInfoEnvironment(info)
+	if s2iEnv, err := scripts.GetEnvironment(config.WorkingSourceDir); err == nil {
+		env = append(env, s2iEnv...)
+	}
+	env = append(env, config.Environment...)
+	return Render(fs, config.WorkingSourceDir, config.RenderTemplates, NewData(config, info, env))
+}
@@ -0,0 +1,104 @@
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+func TestRender(t *testing.T) {
+	data := NewData(
+		&api.Config{BuilderImage: "centos/ruby-23-centos7", Tag: "app:latest"},
+		&git.SourceInfo{CommitID: "e3b0c44"},
+		api.EnvironmentList{{Name: "NAME", Value: "one"}, {Name: "NAME", Value: "two"}},
+	)
+	tests := []struct {
+		name    string
+		files   map[string]string
+		globs   []string
+		want    map[string]string
+		removed []string
+		err     string
+	}{
+		{
+			name: "template extension",
+			files: map[string]string{
+				"config/app.yaml.tmpl": "name: {{ .Env.NAME }}\ncommit: {{ .Source.CommitID }}\n",
+				"config/other.tmpl":    "{{ .Tag }}",
+			},
+			globs: []string{"config/app.yaml.tmpl"},
+			want: map[string]string{
+				"config/app.yaml":   "name: two\ncommit: e3b0c44\n",
+				"config/other.tmpl": "{{ .Tag }}",
+			},
+			removed: []string{"config/app.yaml.tmpl"},
+		},
+		{
+			name:  "in place",
+			files: map[string]string{"a/version.txt": "{{ .BuilderImage }} {{ .Tag }}", "version.txt": "{{ .Tag }}"},
+			globs: []string{"/a/*.txt"},
+			want:  map[string]string{"a/version.txt": "centos/ruby-23-centos7 app:latest", "version.txt": "{{ .Tag }}"},
+		},
+		{
+			name:  "missing variable",
+			files: map[string]string{"app.tmpl": "{{ .Env.MISSING }}"},
+			globs: []string{"*.tmpl"},
+			err:   "unable to render the template app.tmpl",
+		},
+		{
+			name:  "invalid template",
+			files: map[string]string{"app.tmpl": "{{ .Env.NAME "},
+			globs: []string{"*.tmpl"},
+			err:   "unable to parse the template app.tmpl",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "s2i-render-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = Render(fs.NewFileSystem(), dir, tc.globs, data)
+			if len(tc.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, want := range tc.want {
+				got, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s: expected %q, got %q", name, want, got)
+				}
+			}
+			for _, name := range tc.removed {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed, got %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -3,11 +3,22 @@
 package run
 
 import (
+	"archive/tar"
+	"fmt"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/docker"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/scripts"
+	s2itar "github.com/openshift/source-to-image/pkg/tar"
+	"github.com/openshift/source-to-image/pkg/util/fs"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
 )
 
@@ -15,6 +26,10 @@ var (
 	log = utillog.StderrLog
 )
 
+// stopTimeout is the time given to the container to exit gracefully when the
+// run is interrupted, before it is killed.
+const stopTimeout = 10 * time.Second
+
 // A DockerRunner allows running a Docker image as a new container, streaming
 // stdout and stderr with glog.
 type DockerRunner struct {
@@ -29,20 +44,184 @@ func New(client docker.Client, config *api.Config) *DockerRunner {
 }
 
 // Run invokes the Docker API to run the image defined in config as a new
-// container. The container's stdout and stderr will be logged with glog.
+// container. The container's stdout and stderr will be logged with glog,
+// unless it is detached. When interrupted, the container is stopped
+// gracefully and removed.
 func (b *DockerRunner) Run(config *api.Config) error {
+	return b.run(config, nil)
+}
+
+// Start runs the image defined in config like Run, but in the background, and
+// returns the Container running it.
+func (b *DockerRunner) Start(config *api.Config) *Container {
+	return b.startContainer(config.Tag, func(onStart func(string) error) error {
+		return b.run(config, onStart)
+	})
+}
+
+// startContainer calls run in the background, with the onStart hook of the
+// container running the image, and returns the Container.
+func (b *DockerRunner) startContainer(image string, run func(onStart func(string) error) error) *Container {
+	c := &Container{
+		client:  b.ContainerClient,
+		image:   image,
+		started: make(chan string, 1),
+		done:    make(chan error, 1),
+	}
+	go func() {
+		c.done <- run(func(id string) error {
+			c.started <- id
+			return nil
+		})
+	}()
+	return c
+}
+
+// Container is an image run in the background by Start. Its methods must not
+// be called concurrently.
+type Container struct {
+	client  docker.Docker
+	image   string
+	started chan string
+	done    chan error
+
+	id      string
+	running bool
+	exited  bool
+	err     error
+}
+
+// wait waits for the container to be started, and returns false if the run
+// failed before.
+func (c *Container) wait() bool {
+	if c.running || c.exited {
+		return c.running
+	}
+	select {
+	case c.err = <-c.done:
+		c.exited = true
+	case c.id = <-c.started:
+		c.running = true
+	}
+	return c.running
+}
+
+// Running returns true if the container is started and has not exited yet.
+func (c *Container) Running() bool {
+	if !c.wait() {
+		return false
+	}
+	select {
+	case c.err = <-c.done:
+		c.running = false
+		c.exited = true
+	default:
+	}
+	return c.running
+}
+
+// Exec runs the command in the container, once it is started.
+func (c *Container) Exec(cmd []string, stdout, stderr io.Writer) error {
+	if !c.wait() {
+		return fmt.Errorf("the container is not running: %v", c.err)
+	}
+	return c.client.ExecContainer(c.id, cmd, stdout, stderr)
+}
+
+// Stop stops the container gracefully, waits for its removal and returns the
+// error of the run if it failed before the container was started.
+func (c *Container) Stop() error {
+	if !c.wait() {
+		return c.err
+	}
+	if err := c.client.StopContainer(c.id, stopTimeout); err != nil {
+		log.V(2).Infof("Unable to stop container %q: %v", c.id, err)
+	}
+	<-c.done
+	return nil
+}
+
+// Sync uploads the given files of the source directory, as sorted slash
+// separated paths relative to it, into the destination directory of the
+// running container, and runs the reload script of the image so that the
+// application picks up the changes without the image being rebuilt. An error
+// is returned if the changes cannot be synced, e.g. because files were removed
+// or the S2I scripts of the sources changed, and the image must be rebuilt.
+func (c *Container) Sync(source string, paths []string, destination string) error {
+	if !c.wait() {
+		return fmt.Errorf("the container is not running: %v", c.err)
+	}
+	for _, p := range paths {
+		if p == ".s2i" || strings.HasPrefix(p, ".s2i/") {
+			return fmt.Errorf("%s is used by the build", p)
+		}
+		if _, err := os.Stat(filepath.Join(source, filepath.FromSlash(p))); err != nil {
+			return err
+		}
+	}
+	scriptsURL, err := c.client.GetScriptsURL(c.image)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(scriptsURL, "image://") {
+		return fmt.Errorf("the image %s does not provide a %s script", c.image, constants.Reload)
+	}
+
+	makeWritable := func(writer io.Writer) s2itar.Writer {
+		return s2itar.ChmodAdapter{Writer: tar.NewWriter(writer), NewFileMode: 0666, NewExecFileMode: 0777, NewDirMode: 0777}
+	}
+	fileSystem := fs.NewFileSystem()
+	dir := ""
+	for _, p := range paths {
+		// the content of new directories is uploaded along with them
+		if len(dir) > 0 && strings.HasPrefix(p, dir+"/") {
+			continue
+		}
+		src := filepath.Join(source, filepath.FromSlash(p))
+		if info, err := os.Stat(src); err != nil {
+			return err
+		} else if info.IsDir() {
+			dir = p
+		}
+		if err := c.client.UploadToContainerWithTarWriter(fileSystem, src, path.Join(destination, p), c.id, makeWritable); err != nil {
+			return err
+		}
+	}
+
+	outReader, outWriter := io.Pipe()
+	errReader, errWriter := io.Pipe()
+	outDone := docker.StreamContainerIO(outReader, nil, func(s string) { log.Info(s) })
+	errDone := docker.StreamContainerIO(errReader, nil, func(s string) { log.Error(s) })
+	reload := path.Join(strings.TrimPrefix(scriptsURL, "image://"), constants.Reload)
+	err = c.client.ExecContainer(c.id, []string{reload}, outWriter, errWriter)
+	outWriter.Close()
+	errWriter.Close()
+	<-outDone
+	<-errDone
+	return err
+}
+
+// run runs the image defined in config, calling onStart, if not nil, with the
+// ID of the container once it is started.
+func (b *DockerRunner) run(config *api.Config, onStart func(string) error) error {
 	log.V(4).Infof("Attempting to run image %s \n", config.Tag)
 
 	outReader, outWriter := io.Pipe()
 	errReader, errWriter := io.Pipe()
 
 	opts := docker.RunContainerOptions{
-		Image:        config.Tag,
-		Stdout:       outWriter,
-		Stderr:       errWriter,
-		TargetImage:  true,
-		CGroupLimits: config.CGroupLimits,
-		CapDrop:      config.DropCapabilities,
+		Image:             config.Tag,
+		Stdout:            outWriter,
+		Stderr:            errWriter,
+		TargetImage:       true,
+		CGroupLimits:      config.CGroupLimits,
+		CapDrop:           config.DropCapabilities,
+		Env:               scripts.ConvertEnvironmentList(config.RunEnvironment),
+		PortBindings:      config.RunPublish,
+		Detach:            config.RunDetach,
+		StopTimeout:       stopTimeout,
+		OnStart:           onStart,
+		RedactEnvPatterns: config.RedactEnvPatterns,
 	}
 
 	docker.StreamContainerIO(errReader, nil, func(s string) { log.Error(s) })
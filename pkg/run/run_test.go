@@ -0,0 +1,201 @@
+package run
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+func TestRun(t *testing.T) {
+	fake := &docker.FakeDocker{}
+	runner := &DockerRunner{ContainerClient: fake}
+	config := &api.Config{
+		Tag:            "app",
+		RunPublish:     []string{"8080:8080"},
+		RunEnvironment: api.EnvironmentList{{Name: "MODE", Value: "dev"}},
+	}
+	if err := runner.Run(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts := fake.RunContainerOpts
+	if opts.Image != "app" || !opts.TargetImage || opts.StopTimeout != stopTimeout {
+		t.Errorf("unexpected options: %#v", opts)
+	}
+	if !reflect.DeepEqual(opts.PortBindings, []string{"8080:8080"}) || !reflect.DeepEqual(opts.Env, []string{"MODE=dev"}) {
+		t.Errorf("unexpected ports %v or environment %v", opts.PortBindings, opts.Env)
+	}
+
+	fake.RunContainerError = s2ierr.NewContainerError("s2i_app_1234", 2, "")
+	err := runner.Run(config)
+	if e, ok := err.(s2ierr.ContainerError); !ok || !strings.HasSuffix(e.Message, "from app") {
+		t.Errorf("expected a container error reporting the image, got %#v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	fake := &docker.FakeDocker{
+		RunContainerErrorBeforeStart: true,
+		RunContainerError:            errors.New("no such image"),
+	}
+	runner := &DockerRunner{ContainerClient: fake}
+	container := runner.Start(&api.Config{Tag: "app"})
+	if err := container.Sync(t.TempDir(), []string{"app.rb"}, "/opt/app-root/src"); err == nil {
+		t.Errorf("expected the sync to fail")
+	}
+	if err := container.Stop(); err == nil || err.Error() != "no such image" {
+		t.Errorf("expected the error of the run, got %v", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	source := t.TempDir()
+	for _, name := range []string{"app.rb", "lib/util.rb", ".s2i/bin/assemble"} {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		uploads []string
+		wantErr bool
+	}{
+		{
+			name:    "files and new directories",
+			paths:   []string{"app.rb", "lib", "lib/util.rb"},
+			uploads: []string{"/opt/app-root/src/app.rb", "/opt/app-root/src/lib"},
+		},
+		{
+			name:    "removed file",
+			paths:   []string{"app.rb", "config.rb"},
+			wantErr: true,
+		},
+		{
+			name:    "s2i scripts",
+			paths:   []string{".s2i/bin/assemble"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &docker.FakeDocker{DefaultURLResult: "image:///usr/libexec/s2i"}
+			container := (&DockerRunner{ContainerClient: fake}).Start(&api.Config{Tag: "app"})
+			err := container.Sync(source, tc.paths, "/opt/app-root/src")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				if fake.ExecContainerCmd != nil || fake.UploadDestinations != nil {
+					t.Errorf("expected no upload nor reload, got %v and %v", fake.UploadDestinations, fake.ExecContainerCmd)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(fake.UploadDestinations, tc.uploads) {
+				t.Errorf("expected uploads %v, got %v", tc.uploads, fake.UploadDestinations)
+			}
+			if !reflect.DeepEqual(fake.ExecContainerCmd, []string{"/usr/libexec/s2i/reload"}) {
+				t.Errorf("unexpected reload command %v", fake.ExecContainerCmd)
+			}
+		})
+	}
+}
+
+func TestSmokeTest(t *testing.T) {
+	smokeTestInterval = time.Millisecond
+	config := &api.Config{PostCommitCommand: "curl -f localhost:8080/health", PostCommitTimeout: time.Second}
+
+	fake := &docker.FakeDocker{}
+	runner := &DockerRunner{ContainerClient: fake}
+	if err := runner.SmokeTest(config, "sha256:1234"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.RunContainerOpts.Image != "sha256:1234" || fake.RunContainerOpts.TargetImage {
+		t.Errorf("unexpected options: %#v", fake.RunContainerOpts)
+	}
+	if expected := []string{"/bin/sh", "-c", config.PostCommitCommand}; !reflect.DeepEqual(fake.ExecContainerCmd, expected) {
+		t.Errorf("expected the command %v, got %v", expected, fake.ExecContainerCmd)
+	}
+
+	// the fake container exits once started, before the command succeeds
+	fake.ExecContainerError = s2ierr.NewContainerError("s2i_app_1234", 7, "")
+	if err := runner.SmokeTest(config, "sha256:1234"); err == nil {
+		t.Errorf("expected the smoke test to fail")
+	}
+}
+
+// blockingDocker runs containers until they are stopped.
+type blockingDocker struct {
+	*docker.FakeDocker
+	stopped chan struct{}
+}
+
+func (b *blockingDocker) RunContainer(opts docker.RunContainerOptions) error {
+	b.RunContainerOpts = opts
+	opts.Stdout.Close()
+	opts.Stderr.Close()
+	if err := opts.OnStart("1234"); err != nil {
+		return err
+	}
+	<-b.stopped
+	return nil
+}
+
+func (b *blockingDocker) StopContainer(id string, timeout time.Duration) error {
+	close(b.stopped)
+	return nil
+}
+
+func TestVerifyRunScript(t *testing.T) {
+	config := &api.Config{
+		VerifyRunScript:       true,
+		VerifyRunScriptPeriod: 10 * time.Millisecond,
+		RunEnvironment:        api.EnvironmentList{{Name: "DATABASE_URL", Value: "postgres://db"}},
+	}
+
+	fake := &blockingDocker{
+		FakeDocker: &docker.FakeDocker{GetImageEntrypointResult: []string{"container-entrypoint"}, GetImageCmdResult: []string{"/usr/libexec/s2i/run"}},
+		stopped:    make(chan struct{}),
+	}
+	runner := &DockerRunner{ContainerClient: fake}
+	if err := runner.VerifyRunScript(config, "sha256:1234"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts := fake.RunContainerOpts
+	if opts.Image != "sha256:1234" || !reflect.DeepEqual(opts.Entrypoint, []string{"container-entrypoint"}) || !reflect.DeepEqual(opts.CommandExplicit, []string{"/usr/libexec/s2i/run"}) {
+		t.Errorf("expected the entrypoint and command of the image to be run, got %#v", opts)
+	}
+	if !reflect.DeepEqual(opts.Env, []string{"DATABASE_URL=postgres://db"}) {
+		t.Errorf("expected the container to run with the run environment, got %v", opts.Env)
+	}
+
+	// the fake container exits once started
+	exiting := &docker.FakeDocker{GetImageCmdResult: []string{"/usr/libexec/s2i/run"}}
+	runner = &DockerRunner{ContainerClient: exiting}
+	if err := runner.VerifyRunScript(config, "sha256:1234"); err != nil {
+		t.Errorf("expected a container exiting successfully to pass, got %v", err)
+	}
+	exiting.RunContainerError = s2ierr.NewContainerError("s2i_app_1234", 127, "exec: not found")
+	if err := runner.VerifyRunScript(config, "sha256:1234"); err == nil {
+		t.Errorf("expected the verification to fail")
+	}
+
+	if err := (&DockerRunner{ContainerClient: &docker.FakeDocker{}}).VerifyRunScript(config, "sha256:1234"); err == nil {
+		t.Errorf("expected an error for an image without entrypoint nor command")
+	}
+}
@@ -0,0 +1,89 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/docker"
+	"github.com/openshift/source-to-image/pkg/scripts"
+)
+
+// DefaultSmokeTestTimeout is the time given to the smoke test command to
+// succeed when the config does not set it.
+const DefaultSmokeTestTimeout = time.Minute
+
+// smokeTestInterval is the time between two runs of the smoke test command.
+var smokeTestInterval = time.Second
+
+// SmokeTest runs the given image in the background and runs the
+// PostCommitCommand of the config in its container, through /bin/sh, until it
+// succeeds. An error is returned if the command still fails after the
+// PostCommitTimeout, or if the container exits before it succeeds. The
+// container is stopped and removed afterwards.
+func (b *DockerRunner) SmokeTest(config *api.Config, image string) error {
+	timeout := config.PostCommitTimeout
+	if timeout <= 0 {
+		timeout = DefaultSmokeTestTimeout
+	}
+	log.V(1).Infof("Running %q in a container of image %s", config.PostCommitCommand, image)
+
+	c := b.startImage(config, image, nil, nil)
+	defer c.Stop()
+
+	cmd := []string{"/bin/sh", "-c", config.PostCommitCommand}
+	deadline := time.Now().Add(timeout)
+	for {
+		outReader, outWriter := io.Pipe()
+		errReader, errWriter := io.Pipe()
+		outDone := docker.StreamContainerIO(outReader, nil, func(s string) { log.Info(s) })
+		errDone := docker.StreamContainerIO(errReader, nil, func(s string) { log.Error(s) })
+		err := c.Exec(cmd, outWriter, errWriter)
+		outWriter.Close()
+		errWriter.Close()
+		<-outDone
+		<-errDone
+		if err == nil {
+			log.V(1).Infof("Smoke test of image %s passed", image)
+			return nil
+		}
+		if !c.Running() {
+			if c.err != nil {
+				return fmt.Errorf("the container of image %s exited before the command %q succeeded: %v", image, config.PostCommitCommand, c.err)
+			}
+			return fmt.Errorf("the container of image %s exited before the command %q succeeded", image, config.PostCommitCommand)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the command %q did not succeed in a container of image %s within %v: %v", config.PostCommitCommand, image, timeout, err)
+		}
+		log.V(2).Infof("The command %q failed, retrying: %v", config.PostCommitCommand, err)
+		time.Sleep(smokeTestInterval)
+	}
+}
+
+// startImage runs the given image in the background, with the given
+// entrypoint and command when they are set and the run environment of the
+// config, and logs its output at level 1.
+func (b *DockerRunner) startImage(config *api.Config, image string, entrypoint, cmd []string) *Container {
+	return b.startContainer(image, func(onStart func(string) error) error {
+		outReader, outWriter := io.Pipe()
+		errReader, errWriter := io.Pipe()
+		docker.StreamContainerIO(errReader, nil, func(s string) { log.V(1).Info(s) })
+		docker.StreamContainerIO(outReader, nil, func(s string) { log.V(1).Info(s) })
+		return b.ContainerClient.RunContainer(docker.RunContainerOptions{
+			Image:             image,
+			Stdout:            outWriter,
+			Stderr:            errWriter,
+			CGroupLimits:      config.CGroupLimits,
+			CapDrop:           config.DropCapabilities,
+			Env:               scripts.ConvertEnvironmentList(config.RunEnvironment),
+			NetworkMode:       string(config.DockerNetworkMode),
+			Entrypoint:        entrypoint,
+			CommandExplicit:   cmd,
+			StopTimeout:       stopTimeout,
+			OnStart:           onStart,
+			RedactEnvPatterns: config.RedactEnvPatterns,
+		})
+	})
+}
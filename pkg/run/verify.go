@@ -0,0 +1,55 @@
+package run
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+// DefaultVerifyRunScriptPeriod is the time the container of the resulting
+// image has to keep running when the config does not set it.
+const DefaultVerifyRunScriptPeriod = 5 * time.Second
+
+// VerifyRunScript runs the given image in the background, with its entrypoint
+// and command, to confirm that its run script starts. An error is returned if
+// the container cannot be started, or exits with an error within the
+// VerifyRunScriptPeriod of the config, while a container exiting successfully
+// passes. The container is stopped and removed afterwards.
+func (b *DockerRunner) VerifyRunScript(config *api.Config, image string) error {
+	period := config.VerifyRunScriptPeriod
+	if period <= 0 {
+		period = DefaultVerifyRunScriptPeriod
+	}
+	entrypoint, err := b.ContainerClient.GetImageEntrypoint(image)
+	if err != nil {
+		return fmt.Errorf("could not get entrypoint of image %s: %v", image, err)
+	}
+	cmd, err := b.ContainerClient.GetImageCmd(image)
+	if err != nil {
+		return fmt.Errorf("could not get command of image %s: %v", image, err)
+	}
+	if len(entrypoint) == 0 && len(cmd) == 0 {
+		return fmt.Errorf("the image %s has neither an entrypoint nor a command to run", image)
+	}
+	log.V(1).Infof("Running a container of image %s for %v to verify that it starts", image, period)
+
+	c := b.startImage(config, image, entrypoint, cmd)
+	defer c.Stop()
+
+	if c.wait() {
+		select {
+		case <-time.After(period):
+			log.V(1).Infof("The container of image %s is still running after %v", image, period)
+			return nil
+		case c.err = <-c.done:
+			c.running = false
+			c.exited = true
+		}
+	}
+	if c.err != nil {
+		return fmt.Errorf("the container of image %s failed within %v of its start: %v", image, period, c.err)
+	}
+	log.V(1).Infof("The container of image %s exited successfully within %v of its start", image, period)
+	return nil
+}
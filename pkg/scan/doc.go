@@ -0,0 +1,2 @@
+// Package scan runs vulnerability scanners against the images built by S2I.
+package scan
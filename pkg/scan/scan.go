@@ -0,0 +1,249 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// severities lists the severities, from the most to the least severe.
+var severities = []api.Severity{
+	api.SeverityCritical,
+	api.SeverityHigh,
+	api.SeverityMedium,
+	api.SeverityLow,
+	api.SeverityNegligible,
+	api.SeverityUnknown,
+}
+
+// Vulnerability is a vulnerability found in an image.
+type Vulnerability struct {
+	ID       string
+	Package  string
+	Version  string
+	Severity api.Severity
+}
+
+// String returns a human readable description of the vulnerability.
+func (v Vulnerability) String() string {
+	return fmt.Sprintf("%s (%s) in %s %s", v.ID, v.Severity, v.Package, v.Version)
+}
+
+// Report is the result of the vulnerability scan of an image.
+type Report struct {
+	Scanner         api.Scanner
+	Vulnerabilities []Vulnerability
+}
+
+// AtLeast returns the vulnerabilities of the given severity or higher.
+func (r *Report) AtLeast(threshold api.Severity) []Vulnerability {
+	found := []Vulnerability{}
+	for _, v := range r.Vulnerabilities {
+		if v.Severity.AtLeast(threshold) {
+			found = append(found, v)
+		}
+	}
+	return found
+}
+
+// Summary returns the number of vulnerabilities found for each severity.
+func (r *Report) Summary() string {
+	counts := map[api.Severity]int{}
+	for _, v := range r.Vulnerabilities {
+		counts[v.Severity]++
+	}
+	parts := []string{}
+	for _, severity := range severities {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	if len(parts) == 0 {
+		return "no vulnerabilities"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Image scans the given image, stored by the container engine of the given
+// configuration, with the given scanner. The scanner is run from the PATH.
+func Image(scanner api.Scanner, image string, config *api.DockerConfig) (*Report, error) {
+	var args []string
+	var parse func([]byte) ([]Vulnerability, error)
+	containerd := config != nil && config.Engine == api.EngineContainerd
+	switch scanner {
+	case api.ScannerTrivy:
+		args = []string{"image", "--format", "json", "--quiet"}
+		if containerd {
+			args = append(args, "--image-src", "containerd")
+		}
+		parse = parseTrivy
+	case api.ScannerGrype:
+		if containerd {
+			return nil, fmt.Errorf("%s cannot scan the images of the containerd engine", scanner)
+		}
+		args = []string{"--output", "json", "--quiet"}
+		parse = parseGrype
+		// the untagged images are only found in the engine when it is
+		// selected explicitly
+		if strings.HasPrefix(image, "sha256:") {
+			image = "docker:" + image
+		}
+	default:
+		return nil, fmt.Errorf("unknown scanner %q", scanner)
+	}
+	binary, err := exec.LookPath(string(scanner))
+	if err != nil {
+		return nil, fmt.Errorf("%s must be installed to scan the image: %v", scanner, err)
+	}
+
+	cmd := exec.Command(binary, append(args, image)...)
+	cmd.Env = append(os.Environ(), engineEnvironment(config)...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	log.V(2).Infof("Scanning image %s: %s %s", image, binary, strings.Join(cmd.Args[1:], " "))
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %v: %s", scanner, err, strings.TrimSpace(stderr.String()))
+	}
+	vulnerabilities, err := parse(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the report of %s: %v", scanner, err)
+	}
+	return &Report{Scanner: scanner, Vulnerabilities: vulnerabilities}, nil
+}
+
+// engineEnvironment returns the environment variables pointing the scanners
+// to the container engine of the given configuration.
+func engineEnvironment(config *api.DockerConfig) []string {
+	if config == nil {
+		return nil
+	}
+	if config.Engine == api.EngineContainerd {
+		return []string{
+			"CONTAINERD_ADDRESS=" + config.ContainerdAddress,
+			"CONTAINERD_NAMESPACE=" + config.ContainerdNamespace,
+		}
+	}
+	env := []string{}
+	if len(config.Endpoint) > 0 {
+		env = append(env, "DOCKER_HOST="+config.Endpoint)
+	}
+	if config.UseTLS || config.TLSVerify {
+		env = append(env, "DOCKER_CERT_PATH="+filepath.Dir(config.CertFile))
+		if config.TLSVerify {
+			env = append(env, "DOCKER_TLS_VERIFY=1")
+		}
+	}
+	return env
+}
+
+// severity returns the severity of the given name, as reported by a scanner.
+func severity(name string) api.Severity {
+	s := api.Severity(strings.ToLower(name))
+	for _, known := range severities {
+		if s == known {
+			return s
+		}
+	}
+	return api.SeverityUnknown
+}
+
+// trivyReport is the subset of the JSON report of Trivy.
+type trivyReport struct {
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID  string
+			PkgName          string
+			InstalledVersion string
+			Severity         string
+		}
+	}
+}
+
+// parseTrivy returns the vulnerabilities of the given Trivy JSON report.
+func parseTrivy(data []byte) ([]Vulnerability, error) {
+	report := trivyReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	vulnerabilities := []Vulnerability{}
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			vulnerabilities = append(vulnerabilities, Vulnerability{
+				ID:       v.VulnerabilityID,
+				Package:  v.PkgName,
+				Version:  v.InstalledVersion,
+				Severity: severity(v.Severity),
+			})
+		}
+	}
+	return vulnerabilities, nil
+}
+
+// grypeReport is the subset of the JSON report of Grype.
+type grypeReport struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+// parseGrype returns the vulnerabilities of the given Grype JSON report.
+func parseGrype(data []byte) ([]Vulnerability, error) {
+	report := grypeReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	vulnerabilities := []Vulnerability{}
+	for _, match := range report.Matches {
+		vulnerabilities = append(vulnerabilities, Vulnerability{
+			ID:       match.Vulnerability.ID,
+			Package:  match.Artifact.Name,
+			Version:  match.Artifact.Version,
+			Severity: severity(match.Vulnerability.Severity),
+		})
+	}
+	return vulnerabilities, nil
+}
+
+// Gate scans the given image with the scanner of the given configuration, and
+// returns an error if the scan fails or finds vulnerabilities of the severity
+// threshold of the configuration or higher. Nothing is done when no scanner is
+// configured.
+func Gate(config *api.Config, image string) error {
+	if len(config.Scanner) == 0 {
+		return nil
+	}
+	log.V(1).Infof("Scanning image %s for vulnerabilities using %s", image, config.Scanner)
+	report, err := Image(config.Scanner, image, config.DockerConfig)
+	if err != nil {
+		return err
+	}
+	log.V(0).Infof("Vulnerability scan of %s found %s", image, report.Summary())
+	if len(config.ScanSeverityThreshold) == 0 {
+		return nil
+	}
+	found := report.AtLeast(config.ScanSeverityThreshold)
+	if len(found) == 0 {
+		return nil
+	}
+	for _, v := range found {
+		log.V(0).Infof("Found %s", v)
+	}
+	return fmt.Errorf("image %s has %d vulnerabilities of %s severity or higher", image, len(found), config.ScanSeverityThreshold)
+}
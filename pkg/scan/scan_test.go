@@ -0,0 +1,89 @@
+package scan
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+func TestParseTrivy(t *testing.T) {
+	report := []byte(`{
+  "SchemaVersion": 2,
+  "ArtifactName": "app:latest",
+  "Results": [
+    {"Target": "app:latest (rhel 9.4)", "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2024-0001", "PkgName": "openssl", "InstalledVersion": "3.0.7", "Severity": "CRITICAL"},
+      {"VulnerabilityID": "CVE-2024-0002", "PkgName": "zlib", "InstalledVersion": "1.2.11", "Severity": "LOW"}
+    ]},
+    {"Target": "Gemfile.lock"}
+  ]
+}`)
+	vulnerabilities, err := parseTrivy(report)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Vulnerability{
+		{ID: "CVE-2024-0001", Package: "openssl", Version: "3.0.7", Severity: api.SeverityCritical},
+		{ID: "CVE-2024-0002", Package: "zlib", Version: "1.2.11", Severity: api.SeverityLow},
+	}
+	if !reflect.DeepEqual(vulnerabilities, expected) {
+		t.Errorf("Expected %v, got %v", expected, vulnerabilities)
+	}
+}
+
+func TestParseGrype(t *testing.T) {
+	report := []byte(`{
+  "matches": [
+    {"vulnerability": {"id": "CVE-2024-0003", "severity": "High"}, "artifact": {"name": "rack", "version": "2.2.3"}},
+    {"vulnerability": {"id": "CVE-2024-0004", "severity": "Negligible"}, "artifact": {"name": "bash", "version": "5.1"}},
+    {"vulnerability": {"id": "GHSA-xxxx", "severity": "Whatever"}, "artifact": {"name": "json", "version": "2.6"}}
+  ]
+}`)
+	vulnerabilities, err := parseGrype(report)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Vulnerability{
+		{ID: "CVE-2024-0003", Package: "rack", Version: "2.2.3", Severity: api.SeverityHigh},
+		{ID: "CVE-2024-0004", Package: "bash", Version: "5.1", Severity: api.SeverityNegligible},
+		{ID: "GHSA-xxxx", Package: "json", Version: "2.6", Severity: api.SeverityUnknown},
+	}
+	if !reflect.DeepEqual(vulnerabilities, expected) {
+		t.Errorf("Expected %v, got %v", expected, vulnerabilities)
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := &Report{Vulnerabilities: []Vulnerability{
+		{ID: "a", Severity: api.SeverityHigh},
+		{ID: "b", Severity: api.SeverityMedium},
+		{ID: "c", Severity: api.SeverityHigh},
+		{ID: "d", Severity: api.SeverityUnknown},
+	}}
+	if summary := report.Summary(); summary != "2 high, 1 medium, 1 unknown" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+	if found := report.AtLeast(api.SeverityHigh); len(found) != 2 {
+		t.Errorf("Expected 2 vulnerabilities of high severity or higher, got %v", found)
+	}
+	if found := report.AtLeast(api.SeverityCritical); len(found) != 0 {
+		t.Errorf("Expected no critical vulnerability, got %v", found)
+	}
+	if summary := (&Report{}).Summary(); summary != "no vulnerabilities" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+}
+
+func TestEngineEnvironment(t *testing.T) {
+	env := engineEnvironment(&api.DockerConfig{
+		Endpoint:  "tcp://docker:2376",
+		CertFile:  "/certs/cert.pem",
+		TLSVerify: true,
+	})
+	expected := []string{"DOCKER_HOST=tcp://docker:2376", "DOCKER_CERT_PATH=" + filepath.Dir("/certs/cert.pem"), "DOCKER_TLS_VERIFY=1"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %v, got %v", expected, env)
+	}
+}
@@ -0,0 +1,46 @@
+package artifact
+
+import (
+	"path/filepath"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// Artifact is the Downloader of binary builds, which upload a prebuilt
+// artifact, such as a jar, a war, a binary or a dist directory, instead of the
+// sources, so that the assemble script only has to install it.
+type Artifact struct {
+	fs.FileSystem
+}
+
+// Download copies the artifact of the config into the working directory: the
+// contents of an artifact directory, or an artifact file keeping its name.
+func (a *Artifact) Download(config *api.Config) (*git.SourceInfo, error) {
+	config.WorkingSourceDir = filepath.Join(config.WorkingDir, constants.Source)
+
+	info, err := a.Stat(config.ArtifactPath)
+	if err != nil {
+		return nil, err
+	}
+
+	log.V(1).Infof("Copying the artifact %q to %q", config.ArtifactPath, config.WorkingSourceDir)
+	a.KeepSymlinks(config.KeepSymlinks)
+	if info.IsDir() {
+		err = a.CopyContents(config.ArtifactPath, config.WorkingSourceDir, nil)
+	} else if err = a.MkdirAll(config.WorkingSourceDir); err == nil {
+		err = a.Copy(config.ArtifactPath, filepath.Join(config.WorkingSourceDir, filepath.Base(config.ArtifactPath)), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &git.SourceInfo{
+		Location: config.ArtifactPath,
+	}, nil
+}
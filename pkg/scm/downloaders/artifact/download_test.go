@@ -0,0 +1,60 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	testfs "github.com/openshift/source-to-image/pkg/test/fs"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		name     string
+		artifact string
+		isDir    bool
+		dest     string
+	}{
+		{
+			name:     "file",
+			artifact: filepath.Join("target", "app.jar"),
+			dest:     filepath.Join("/work", "upload", "src", "app.jar"),
+		},
+		{
+			name:     "directory",
+			artifact: filepath.Join("web", "dist"),
+			isDir:    true,
+			dest:     filepath.Join("/work", "upload", "src"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &testfs.FakeFileSystem{
+				Files: []os.FileInfo{&fs.FileInfo{FileName: filepath.Base(tc.artifact), FileIsDir: tc.isDir}},
+			}
+			config := &api.Config{ArtifactPath: tc.artifact, WorkingDir: "/work"}
+			info, err := (&Artifact{fake}).Download(config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fake.CopySource != tc.artifact || fake.CopyDest != tc.dest {
+				t.Errorf("expected %s to be copied to %s, got %s to %s", tc.artifact, tc.dest, fake.CopySource, fake.CopyDest)
+			}
+			if config.WorkingSourceDir != filepath.Join("/work", "upload", "src") {
+				t.Errorf("unexpected working source directory %s", config.WorkingSourceDir)
+			}
+			if info.Location != tc.artifact {
+				t.Errorf("unexpected location %s", info.Location)
+			}
+		})
+	}
+}
+
+func TestDownloadMissing(t *testing.T) {
+	config := &api.Config{ArtifactPath: "missing.jar", WorkingDir: "/work"}
+	if _, err := (&Artifact{&testfs.FakeFileSystem{}}).Download(config); err == nil {
+		t.Errorf("expected an error for a missing artifact")
+	}
+}
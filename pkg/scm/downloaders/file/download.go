@@ -2,6 +2,7 @@ package file
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
 
@@ -49,12 +50,31 @@ func (f *File) Download(config *api.Config) (*git.SourceInfo, error) {
 		return nil, RecursiveCopyError{error: fmt.Errorf("recursive copy requested, source directory %q contains the target directory %q", copySrc, config.WorkingSourceDir)}
 	}
 
-	di := ignore.DockerIgnorer{}
+	di, err := ignore.New(config.Ignorers)
+	if err != nil {
+		return nil, err
+	}
 	filesToIgnore, lerr := di.GetListOfFilesToIgnore(copySrc)
 	if lerr != nil {
 		return nil, lerr
 	}
 
+	excludeGlobs, err := ignore.NewGlobMatcher(config.ExcludeGlobs, config.IncludeGlobs)
+	if err != nil {
+		return nil, err
+	}
+	isGlobIgnored := func(path string) bool {
+		if excludeGlobs == nil {
+			return false
+		}
+		rel, err := filepath.Rel(copySrc, path)
+		if err != nil {
+			return false
+		}
+		info, err := os.Lstat(path)
+		return err == nil && excludeGlobs.Match(filepath.ToSlash(rel), info.IsDir())
+	}
+
 	var isIgnored func(path string) bool
 
 	if config.ExcludeRegExp != "" {
@@ -64,12 +84,12 @@ func (f *File) Download(config *api.Config) (*git.SourceInfo, error) {
 		}
 		isIgnored = func(path string) bool {
 			_, ok := filesToIgnore[path]
-			return ok || exclude.MatchString(path)
+			return ok || exclude.MatchString(path) || isGlobIgnored(path)
 		}
 	} else {
 		isIgnored = func(path string) bool {
 			_, ok := filesToIgnore[path]
-			return ok
+			return ok || isGlobIgnored(path)
 		}
 	}
 
@@ -1,6 +1,7 @@
 package git
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -44,17 +45,43 @@ func (c *Clone) Download(config *api.Config) (*git.SourceInfo, error) {
 	}
 
 	cloneConfig := git.CloneConfig{Quiet: true}
+	if config.PartialClone {
+		cloneConfig.Filter = git.PartialCloneFilter
+	}
 	err := c.Clone(config.Source, targetSourceDir, cloneConfig)
 	if err != nil {
 		klog.V(0).Infof("error: git clone failed: %v", err)
 		return nil, err
 	}
 
+	if git.IsFetchedRef(ref) {
+		// refs outside of the branches and tags, e.g. the ones of pull requests,
+		// are not cloned
+		klog.V(1).Infof("Fetching %q", ref)
+		if err = c.Fetch(targetSourceDir, "+"+ref+":"+ref); err != nil {
+			return nil, err
+		}
+	}
+
 	err = c.Checkout(targetSourceDir, ref)
+	if err != nil && git.IsCommitSHA(ref) {
+		// the commit might not be reachable from the branches and tags cloned
+		klog.V(1).Infof("Fetching commit %q", ref)
+		if fetchErr := c.Fetch(targetSourceDir, ref); fetchErr == nil {
+			err = c.Checkout(targetSourceDir, ref)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 	klog.V(1).Infof("Checked out %q", ref)
+	signedBy := ""
+	if config.VerifyCommitSignature {
+		if signedBy, err = c.VerifySignature(targetSourceDir, ref, config.Keyring); err != nil {
+			return nil, err
+		}
+		klog.V(1).Infof("Verified the signature of %q by key %s", ref, signedBy)
+	}
 	if !config.IgnoreSubmodules {
 		err = c.SubmoduleUpdate(targetSourceDir, true, true)
 		if err != nil {
@@ -75,6 +102,16 @@ func (c *Clone) Download(config *api.Config) (*git.SourceInfo, error) {
 	}
 
 	info := c.GetInfo(targetSourceDir)
+	if git.IsCommitSHA(ref) {
+		if info.CommitID != ref {
+			return nil, fmt.Errorf("checked out commit %q instead of the requested commit %q", info.CommitID, ref)
+		}
+		info.CommitVerified = true
+	}
+	info.SignedBy = signedBy
+	if git.IsFetchedRef(ref) {
+		info.Ref = ref
+	}
 	if len(config.ContextDir) > 0 {
 		originalTargetDir := filepath.Join(config.WorkingDir, constants.Source)
 		c.RemoveDirectory(originalTargetDir)
@@ -1,12 +1,15 @@
 package git
 
 import (
+	"fmt"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/test"
 	testcmd "github.com/openshift/source-to-image/pkg/test/cmd"
 	testfs "github.com/openshift/source-to-image/pkg/test/fs"
 )
@@ -42,3 +45,57 @@ func TestCloneWithContext(t *testing.T) {
 		t.Errorf("Unexpected command arguments: %#v", cr.Args)
 	}
 }
+
+func TestCloneCommitSHA(t *testing.T) {
+	sha := "1bf4f04c4c0fdbde2bbd0bd8ef0b2a1a4b0b3d0e"
+	fakeGit := &test.FakeGit{CheckoutError: fmt.Errorf("reference is not a tree: %s", sha)}
+	c := &Clone{fakeGit, &testfs.FakeFileSystem{}}
+	config := &api.Config{Source: git.MustParse("https://foo/bar.git#" + sha), IgnoreSubmodules: true}
+	if _, err := c.Download(config); err == nil {
+		t.Errorf("Expected the checkout of the unreachable commit to fail")
+	}
+	if fakeGit.FetchRef != sha {
+		t.Errorf("Expected the commit to be fetched, got %q", fakeGit.FetchRef)
+	}
+
+	// the fake repository has 1bf4f04 checked out
+	fakeGit = &test.FakeGit{}
+	c = &Clone{fakeGit, &testfs.FakeFileSystem{}}
+	if _, err := c.Download(config); err == nil || !strings.Contains(err.Error(), "instead of the requested commit") {
+		t.Errorf("Expected the checked out commit to be verified, got %v", err)
+	}
+}
+
+func TestCloneVerifySignature(t *testing.T) {
+	fakeGit := &test.FakeGit{VerifySignatureResult: "0123456789ABCDEF"}
+	c := &Clone{fakeGit, &testfs.FakeFileSystem{}}
+	config := &api.Config{Source: git.MustParse("https://foo/bar.git#v1.0"), IgnoreSubmodules: true, VerifyCommitSignature: true}
+	info, err := c.Download(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fakeGit.VerifySignatureRef != "v1.0" || info.SignedBy != "0123456789ABCDEF" {
+		t.Errorf("Expected the signature of v1.0 to be verified, got %q signed by %q", fakeGit.VerifySignatureRef, info.SignedBy)
+	}
+
+	fakeGit.VerifySignatureError = fmt.Errorf("bad signature")
+	if _, err := c.Download(config); err == nil {
+		t.Errorf("Expected the build to fail with an invalid signature")
+	}
+}
+
+func TestClonePullRequestRef(t *testing.T) {
+	fakeGit := &test.FakeGit{}
+	c := &Clone{fakeGit, &testfs.FakeFileSystem{}}
+	config := &api.Config{Source: git.MustParse("https://foo/bar.git#refs/pull/123/head"), IgnoreSubmodules: true}
+	info, err := c.Download(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fakeGit.FetchRef != "+refs/pull/123/head:refs/pull/123/head" {
+		t.Errorf("Expected the pull request refspec to be fetched, got %q", fakeGit.FetchRef)
+	}
+	if fakeGit.CheckoutRef != "refs/pull/123/head" || info.Ref != "refs/pull/123/head" {
+		t.Errorf("Expected the pull request ref to be checked out, got %q (%q)", fakeGit.CheckoutRef, info.Ref)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -18,7 +19,13 @@ import (
 )
 
 var log = utillog.StderrLog
+
+// PartialCloneFilter is the filter of the partial clones, leaving the blobs out
+// of the clone, to be fetched on demand by the checkout.
+const PartialCloneFilter = "blob:none"
+
 var lsTreeRegexp = regexp.MustCompile("([0-7]{6}) [^ ]+ [0-9a-f]{40}\t(.*)")
+var commitSHARegexp = regexp.MustCompile("^([0-9a-f]{40}|[0-9a-f]{64})$")
 
 // Git is an interface used by main STI code to extract/checkout git repositories
 type Git interface {
@@ -27,6 +34,8 @@ type Git interface {
 	SubmoduleUpdate(repo string, init, recursive bool) error
 	LsTree(repo, ref string, recursive bool) ([]os.FileInfo, error)
 	GetInfo(string) *SourceInfo
+	Fetch(repo, ref string) error
+	VerifySignature(repo, ref, keyring string) (string, error)
 }
 
 // New returns a new instance of the default implementation of the Git interface
@@ -50,6 +59,9 @@ func cloneConfigToArgs(opts CloneConfig) []string {
 	if opts.Recursive {
 		result = append(result, "--recursive")
 	}
+	if len(opts.Filter) > 0 {
+		result = append(result, "--filter="+opts.Filter)
+	}
 	return result
 }
 
@@ -153,6 +165,19 @@ func LocalNonBareGitRepositoryIsEmpty(fs fs.FileSystem, dir string) (bool, error
 	return true, nil
 }
 
+// IsCommitSHA returns true if the ref is the full SHA-1, or SHA-256, of a
+// commit.
+func IsCommitSHA(ref string) bool {
+	return commitSHARegexp.MatchString(ref)
+}
+
+// IsFetchedRef returns true if the ref is a full ref outside of the branches
+// and tags, e.g. refs/pull/123/head or refs/merge-requests/45/merge, which a
+// clone does not fetch.
+func IsFetchedRef(ref string) bool {
+	return strings.HasPrefix(ref, "refs/") && !strings.HasPrefix(ref, "refs/heads/") && !strings.HasPrefix(ref, "refs/tags/")
+}
+
 // HasGitBinary checks if the 'git' binary is available on the system
 func HasGitBinary() bool {
 	_, err := exec.LookPath("git")
@@ -179,11 +204,21 @@ func (h *stiGit) Clone(src *URL, target string, c CloneConfig) error {
 		}
 	}
 
-	cloneArgs := append([]string{"clone"}, cloneConfigToArgs(c)...)
-	cloneArgs = append(cloneArgs, []string{source.StringNoFragment(), target}...)
+	if len(c.Filter) > 0 && (source.Type == URLTypeLocal || !h.supportsPartialClone(source)) {
+		log.V(1).Infof("%s does not support partial clones, cloning it fully", source.StringNoFragment())
+		c.Filter = ""
+	}
+
 	stderr := &bytes.Buffer{}
 	opts := cmd.CommandOpts{Stderr: stderr}
-	err = h.RunWithOptions(opts, "git", cloneArgs...)
+	err = h.RunWithOptions(opts, "git", cloneArgs(source, target, c)...)
+	if err != nil && len(c.Filter) > 0 {
+		log.Warningf("Partial clone of %s failed, cloning it fully: %q", source.StringNoFragment(), stderr.String())
+		h.RemoveDirectory(target)
+		stderr.Reset()
+		c.Filter = ""
+		err = h.RunWithOptions(opts, "git", cloneArgs(source, target, c)...)
+	}
 	if err != nil {
 		log.Errorf("Clone failed: source %s, target %s, with output %q", source, target, stderr.String())
 		return err
@@ -191,6 +226,50 @@ func (h *stiGit) Clone(src *URL, target string, c CloneConfig) error {
 	return nil
 }
 
+// cloneArgs returns the arguments of git to clone the source to the target
+// directory, using the protocol v2 for partial clones.
+func cloneArgs(source URL, target string, c CloneConfig) []string {
+	args := []string{"clone"}
+	if len(c.Filter) > 0 {
+		args = []string{"-c", "protocol.version=2", "clone"}
+	}
+	args = append(args, cloneConfigToArgs(c)...)
+	return append(args, source.StringNoFragment(), target)
+}
+
+// supportsPartialClone probes, with the protocol v2, whether the server of the
+// repository advertises the filter capability partial clones rely on.
+func (h *stiGit) supportsPartialClone(source URL) bool {
+	stderr := &bytes.Buffer{}
+	opts := cmd.CommandOpts{
+		Stdout:    ioutil.Discard,
+		Stderr:    stderr,
+		EnvAppend: []string{"GIT_TRACE_PACKET=1"},
+	}
+	if err := h.RunWithOptions(opts, "git", "-c", "protocol.version=2", "ls-remote", source.StringNoFragment(), "HEAD"); err != nil {
+		log.V(3).Infof("Unable to probe %s for partial clones: %v", source.StringNoFragment(), err)
+		return false
+	}
+	return advertisesFilter(stderr.String())
+}
+
+// advertisesFilter returns true when the packet trace of a protocol v2
+// exchange shows the server advertising the filter capability of fetch.
+func advertisesFilter(trace string) bool {
+	for _, line := range strings.Split(trace, "\n") {
+		i := strings.Index(line, "< fetch=")
+		if i < 0 {
+			continue
+		}
+		for _, feature := range strings.Fields(strings.TrimPrefix(line[i:], "< fetch=")) {
+			if feature == "filter" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Checkout checks out a specific branch reference of a given git repository
 func (h *stiGit) Checkout(repo, ref string) error {
 	opts := cmd.CommandOpts{
@@ -204,6 +283,71 @@ func (h *stiGit) Checkout(repo, ref string) error {
 	return h.RunWithOptions(opts, "git", "checkout", ref)
 }
 
+// Fetch fetches a ref, or a refspec, from the origin remote of the repository,
+// e.g. a commit which is not reachable from its branches and tags.
+func (h *stiGit) Fetch(repo, ref string) error {
+	opts := cmd.CommandOpts{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Dir:    repo,
+	}
+	return h.RunWithOptions(opts, "git", "fetch", "--quiet", "origin", ref)
+}
+
+// VerifySignature verifies the GPG signature of the commit, or of the
+// annotated tag, ref points to, returning the fingerprint of the signing key.
+// The public keys of the keyring, or of the default GPG keyring when it is
+// empty, are trusted.
+func (h *stiGit) VerifySignature(repo, ref, keyring string) (string, error) {
+	opts := cmd.CommandOpts{Dir: repo}
+	if len(keyring) > 0 {
+		home, err := fs.MkdirTemp("gnupg")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(home)
+		opts.EnvAppend = []string{"GNUPGHOME=" + home}
+		stderr := &bytes.Buffer{}
+		importOpts := opts
+		importOpts.Stderr = stderr
+		if err := h.RunWithOptions(importOpts, "gpg", "--batch", "--quiet", "--import", keyring); err != nil {
+			return "", fmt.Errorf("unable to import the keyring %s: %v: %s", keyring, err, stderr.String())
+		}
+	}
+
+	objectType := &bytes.Buffer{}
+	typeOpts := opts
+	typeOpts.Stdout = objectType
+	if err := h.RunWithOptions(typeOpts, "git", "cat-file", "-t", ref); err != nil {
+		return "", err
+	}
+	verify := "verify-commit"
+	if strings.TrimSpace(objectType.String()) == "tag" {
+		verify = "verify-tag"
+	}
+
+	status := &bytes.Buffer{}
+	opts.Stderr = status
+	err := h.RunWithOptions(opts, "git", verify, "--raw", ref)
+	fingerprint := validSignature(status.String())
+	if err != nil || len(fingerprint) == 0 {
+		return "", fmt.Errorf("the signature of %s could not be verified: %s", ref, strings.TrimSpace(status.String()))
+	}
+	return fingerprint, nil
+}
+
+// validSignature returns the fingerprint of the key of the valid signature
+// reported by the GPG status output, if any.
+func validSignature(status string) string {
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 2 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG" {
+			return fields[2]
+		}
+	}
+	return ""
+}
+
 // SubmoduleInit initializes/clones submodules
 func (h *stiGit) SubmoduleInit(repo string) error {
 	opts := cmd.CommandOpts{
@@ -306,6 +450,7 @@ func (h *stiGit) GetInfo(repo string) *SourceInfo {
 		Location:       git("config", "--get", "remote.origin.url"),
 		Ref:            git("rev-parse", "--abbrev-ref", "HEAD"),
 		CommitID:       git("rev-parse", "--verify", "HEAD"),
+		Describe:       git("describe", "--tags", "--always"),
 		AuthorName:     git("--no-pager", "show", "-s", "--format=%an", "HEAD"),
 		AuthorEmail:    git("--no-pager", "show", "-s", "--format=%ae", "HEAD"),
 		CommitterName:  git("--no-pager", "show", "-s", "--format=%cn", "HEAD"),
@@ -1,14 +1,19 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	testcmd "github.com/openshift/source-to-image/pkg/test/cmd"
 	testfs "github.com/openshift/source-to-image/pkg/test/fs"
+	"github.com/openshift/source-to-image/pkg/util/cmd"
 	"github.com/openshift/source-to-image/pkg/util/fs"
 )
 
@@ -103,6 +108,160 @@ func TestGitCloneError(t *testing.T) {
 	}
 }
 
+func TestAdvertisesFilter(t *testing.T) {
+	tests := map[string]bool{
+		"packet:          git< fetch=shallow wait-for-done filter\n":        true,
+		"packet:    ls-remote< fetch=filter shallow\n":                      true,
+		"packet:          git< fetch=shallow wait-for-done\n":               false,
+		"packet:  upload-pack> fetch=shallow filter\npacket: git< fetch=\n": false,
+		"": false,
+	}
+	for trace, expected := range tests {
+		if actual := advertisesFilter(trace); actual != expected {
+			t.Errorf("advertisesFilter(%q) returned %v, expected %v", trace, actual, expected)
+		}
+	}
+}
+
+func TestGitClonePartial(t *testing.T) {
+	d, err := CreateLocalGitDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	gh := New(fs.NewFileSystem(), cmd.NewCommandRunner())
+	source := MustParse("file://" + filepath.ToSlash(d))
+
+	// the server does not support partial clones until it allows filters
+	for _, allowFilter := range []string{"false", "true"} {
+		if err := cmd.NewCommandRunner().RunWithOptions(cmd.CommandOpts{Dir: d}, "git", "config", "uploadpack.allowFilter", allowFilter); err != nil {
+			t.Fatal(err)
+		}
+		target := filepath.Join(t.TempDir(), "clone")
+		if err := gh.Clone(source, target, CloneConfig{Quiet: true, Filter: PartialCloneFilter}); err != nil {
+			t.Fatalf("Unexpected error returned from clone: %v", err)
+		}
+		promisor := &bytes.Buffer{}
+		cmd.NewCommandRunner().RunWithOptions(cmd.CommandOpts{Dir: target, Stdout: promisor}, "git", "config", "remote.origin.promisor")
+		if partial := strings.TrimSpace(promisor.String()) == "true"; partial != (allowFilter == "true") {
+			t.Errorf("Expected a partial clone %s, got %v", allowFilter, partial)
+		}
+	}
+}
+
+func TestIsCommitSHA(t *testing.T) {
+	tests := map[string]bool{
+		"1bf4f04c4c0fdbde2bbd0bd8ef0b2a1a4b0b3d0e":                         true,
+		"1bf4f04c4c0fdbde2bbd0bd8ef0b2a1a4b0b3d0e1bf4f04c4c0fdbde2bbd0bd8": true,
+		"1bf4f04": false,
+		"1BF4F04C4C0FDBDE2BBD0BD8EF0B2A1A4B0B3D0E": false,
+		"master": false,
+	}
+	for ref, expected := range tests {
+		if actual := IsCommitSHA(ref); actual != expected {
+			t.Errorf("IsCommitSHA(%q) returned %v, expected %v", ref, actual, expected)
+		}
+	}
+}
+
+func TestIsFetchedRef(t *testing.T) {
+	tests := map[string]bool{
+		"refs/pull/123/head":           true,
+		"refs/merge-requests/45/merge": true,
+		"refs/heads/master":            false,
+		"refs/tags/v1.0":               false,
+		"master":                       false,
+	}
+	for ref, expected := range tests {
+		if actual := IsFetchedRef(ref); actual != expected {
+			t.Errorf("IsFetchedRef(%q) returned %v, expected %v", ref, actual, expected)
+		}
+	}
+}
+
+func TestCheckoutPullRequestRef(t *testing.T) {
+	d, err := CreateLocalGitDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	env := []string{"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test"}
+	cr := cmd.NewCommandRunner()
+	// record a commit only reachable from a pull request ref
+	if err := cr.RunWithOptions(cmd.CommandOpts{Dir: d, EnvAppend: env}, "git", "commit", "--allow-empty", "-m", "pull request"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.RunWithOptions(cmd.CommandOpts{Dir: d}, "git", "update-ref", "refs/pull/1/head", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.RunWithOptions(cmd.CommandOpts{Dir: d}, "git", "reset", "--hard", "--quiet", "HEAD~1"); err != nil {
+		t.Fatal(err)
+	}
+
+	gh := New(fs.NewFileSystem(), cmd.NewCommandRunner())
+	target := filepath.Join(t.TempDir(), "clone")
+	if err := gh.Clone(MustParse("file://"+filepath.ToSlash(d)), target, CloneConfig{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gh.Checkout(target, "refs/pull/1/head"); err == nil {
+		t.Errorf("Expected the pull request ref not to be cloned")
+	}
+	if err := gh.Fetch(target, "+refs/pull/1/head:refs/pull/1/head"); err != nil {
+		t.Fatalf("Unexpected error fetching the pull request ref: %v", err)
+	}
+	if err := gh.Checkout(target, "refs/pull/1/head"); err != nil {
+		t.Fatalf("Unexpected error checking out the pull request ref: %v", err)
+	}
+	if info := gh.GetInfo(target); !strings.Contains(info.Message, "pull request") {
+		t.Errorf("Expected the pull request commit to be checked out, got %q", info.Message)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not available")
+	}
+	d, err := CreateLocalGitDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+
+	// sign a commit with a new key, exported to the keyring
+	home := t.TempDir()
+	env := []string{"GNUPGHOME=" + home, "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test"}
+	defer cmd.NewCommandRunner().RunWithOptions(cmd.CommandOpts{EnvAppend: env}, "gpgconf", "--kill", "gpg-agent")
+	run := func(stdout io.Writer, name string, args ...string) {
+		if err := cmd.NewCommandRunner().RunWithOptions(cmd.CommandOpts{Dir: d, Stdout: stdout, EnvAppend: env}, name, args...); err != nil {
+			t.Fatalf("%s %v failed: %v", name, args, err)
+		}
+	}
+	run(nil, "gpg", "--batch", "--passphrase", "", "--quick-gen-key", "test <test@test>", "ed25519", "sign", "never")
+	keys := &bytes.Buffer{}
+	run(keys, "gpg", "--list-keys", "--with-colons")
+	fingerprint := ""
+	for _, line := range strings.Split(keys.String(), "\n") {
+		if fields := strings.Split(line, ":"); fields[0] == "fpr" && len(fingerprint) == 0 {
+			fingerprint = fields[9]
+		}
+	}
+	keyring := filepath.Join(home, "keyring.asc")
+	run(nil, "gpg", "--armor", "--output", keyring, "--export", fingerprint)
+	run(nil, "git", "-c", "user.signingkey="+fingerprint, "commit", "--allow-empty", "-S", "-m", "signed")
+
+	gh := New(fs.NewFileSystem(), cmd.NewCommandRunner())
+	signedBy, err := gh.VerifySignature(d, "HEAD", keyring)
+	if err != nil {
+		t.Fatalf("Unexpected error verifying the signed commit: %v", err)
+	}
+	if signedBy != fingerprint {
+		t.Errorf("Expected the commit to be signed by %s, got %s", fingerprint, signedBy)
+	}
+	if _, err := gh.VerifySignature(d, "HEAD~1", keyring); err == nil {
+		t.Errorf("Expected the verification of the unsigned commit to fail")
+	}
+}
+
 func TestGitCheckout(t *testing.T) {
 	gh, ch := getGit()
 	err := gh.Checkout("repo1", "ref1")
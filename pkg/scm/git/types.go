@@ -5,6 +5,9 @@ package git
 type CloneConfig struct {
 	Recursive bool
 	Quiet     bool
+	// Filter is the filter of a partial clone, e.g. PartialCloneFilter, used
+	// when the server of the repository supports partial clones.
+	Filter string
 }
 
 // SourceInfo stores information about the source code
@@ -17,6 +20,11 @@ type SourceInfo struct {
 	// The output image will contain this information as 'io.openshift.build.commit.id' label.
 	CommitID string
 
+	// Describe is the most recent tag reachable from the commit, followed by
+	// the number of commits since the tag and the abbreviated commit, as
+	// output by git describe.
+	Describe string
+
 	// Date contains a date when the committer created the commit.
 	// The output image will contain this information as 'io.openshift.build.commit.date' label.
 	Date string
@@ -43,6 +51,16 @@ type SourceInfo struct {
 	// The output image will contain this information as 'io.openshift.build.source-location' label.
 	Location string
 
+	// CommitVerified is true when the commit checked out was verified to be
+	// the one given by its full SHA as the ref.
+	// The output image will contain this information as 'io.openshift.build.commit.verified' label.
+	CommitVerified bool
+
+	// SignedBy contains the fingerprint of the key of the verified GPG
+	// signature of the commit or tag.
+	// The output image will contain this information as 'io.openshift.build.commit.signed-by' label.
+	SignedBy string
+
 	// ContextDir contains path inside the Location directory that
 	// contains the application source code.
 	// The output image will contain this information as 'io.openshift.build.source-context-dir'
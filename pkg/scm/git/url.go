@@ -173,6 +173,22 @@ func (u URL) StringNoFragment() string {
 	return u.String()
 }
 
+// MarshalText returns the string representation of the URL, so that the URL
+// is serialized as a string in JSON and YAML
+func (u URL) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText parses the given string representation of a "Git URL"
+func (u *URL) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*u = *parsed
+	return nil
+}
+
 // IsLocal returns true if the Git URL refers to a local repository
 func (u URL) IsLocal() bool {
 	return u.Type == URLTypeLocal || (u.Type == URLTypeURL && u.URL.Scheme == "file" && u.URL.Opaque == "")
@@ -1,6 +1,7 @@
 package git
 
 import (
+	"encoding/json"
 	"net/url"
 	"reflect"
 	"runtime"
@@ -259,6 +260,23 @@ func TestStringNoFragment(t *testing.T) {
 	}
 }
 
+func TestURLJSON(t *testing.T) {
+	for _, rawurl := range []string{"https://github.com/openshift/source-to-image#v1.4.0", "git@github.com:openshift/source-to-image", "file:///foo/bar"} {
+		data, err := json.Marshal(MustParse(rawurl))
+		if err != nil || string(data) != `"`+rawurl+`"` {
+			t.Errorf("%s: expected to be serialized as a string, got %s, %v", rawurl, data, err)
+			continue
+		}
+		u := &URL{}
+		if err := json.Unmarshal(data, u); err != nil || !reflect.DeepEqual(u, MustParse(rawurl)) {
+			t.Errorf("%s: expected to be deserialized, got %#v, %v", rawurl, u, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(`"http://github.com/%"`), &URL{}); err == nil {
+		t.Errorf("expected an error for an invalid URL")
+	}
+}
+
 type localPathTest struct {
 	url      *URL
 	expected string
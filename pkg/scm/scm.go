@@ -1,8 +1,10 @@
 package scm
 
 import (
+	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/build"
 	"github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/scm/downloaders/artifact"
 	"github.com/openshift/source-to-image/pkg/scm/downloaders/empty"
 	"github.com/openshift/source-to-image/pkg/scm/downloaders/file"
 	gitdownloader "github.com/openshift/source-to-image/pkg/scm/downloaders/git"
@@ -14,6 +16,15 @@ import (
 
 var log = utillog.StderrLog
 
+// DownloaderForConfig determines the Downloader of the build: the one of its
+// prebuilt artifact for binary builds, or the one of its sources.
+func DownloaderForConfig(fs fs.FileSystem, config *api.Config) (build.Downloader, error) {
+	if len(config.ArtifactPath) > 0 {
+		return &artifact.Artifact{FileSystem: fs}, nil
+	}
+	return DownloaderForSource(fs, config.Source, config.ForceCopy)
+}
+
 // DownloaderForSource determines what SCM plugin should be used for downloading
 // the sources from the repository.
 func DownloaderForSource(fs fs.FileSystem, s *git.URL, forceCopy bool) (build.Downloader, error) {
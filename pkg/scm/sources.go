@@ -0,0 +1,98 @@
+package scm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+// sourcesDir is the subdirectory of the working directory the additional
+// sources are downloaded to, before they are moved into the sources.
+const sourcesDir = "sources"
+
+// sourcesFile is the format of the file listing additional sources, e.g.:
+//
+//	sources:
+//	- url: https://github.com/user/app-config
+//	  ref: v1.2.0
+//	  directory: config
+type sourcesFile struct {
+	Sources []struct {
+		URL       string `yaml:"url"`
+		Ref       string `yaml:"ref"`
+		Directory string `yaml:"directory"`
+	} `yaml:"sources"`
+}
+
+// ReadSourcesFile reads the additional sources listed in the YAML file at the
+// given path.
+func ReadSourcesFile(path string) (api.SourceList, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := sourcesFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("unable to parse the sources file %s: %v", path, err)
+	}
+	sources := api.SourceList{}
+	for _, s := range file.Sources {
+		source, err := git.Parse(s.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url %q in the sources file %s: %v", s.URL, path, err)
+		}
+		if len(s.Ref) > 0 {
+			source.URL.Fragment = s.Ref
+		}
+		sources = append(sources, api.SourceSpec{Source: source, Directory: s.Directory})
+	}
+	return sources, nil
+}
+
+// DownloadSources downloads the additional sources of the build, each with the
+// Downloader of its location, into their subdirectories of the sources of the
+// build.
+func DownloadSources(fs fs.FileSystem, config *api.Config) error {
+	if len(config.Sources) == 0 {
+		return nil
+	}
+	if len(config.WorkingSourceDir) == 0 {
+		// the build has no source of its own
+		config.WorkingSourceDir = filepath.Join(config.WorkingDir, constants.Source)
+	}
+	for i, spec := range config.Sources {
+		target := filepath.Join(config.WorkingSourceDir, filepath.FromSlash(spec.Directory))
+		if fs.Exists(target) {
+			return fmt.Errorf("unable to download %s into %q: the directory already exists in the sources", spec.Source, spec.Directory)
+		}
+
+		downloader, err := DownloaderForSource(fs, spec.Source, config.ForceCopy)
+		if err != nil {
+			return err
+		}
+		sourceConfig := *config
+		sourceConfig.Source = spec.Source
+		sourceConfig.ContextDir = ""
+		sourceConfig.WorkingDir = filepath.Join(config.WorkingDir, sourcesDir, strconv.Itoa(i))
+		log.V(1).Infof("Downloading %s into %q", spec.Source, spec.Directory)
+		if _, err := downloader.Download(&sourceConfig); err != nil {
+			return fmt.Errorf("unable to download %s: %v", spec.Source, err)
+		}
+
+		if err := fs.MkdirAll(filepath.Dir(target)); err != nil {
+			return err
+		}
+		if err := fs.Rename(sourceConfig.WorkingSourceDir, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
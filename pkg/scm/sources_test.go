@@ -0,0 +1,79 @@
+package scm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+func TestReadSourcesFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "s2i-sources-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sources.yaml")
+	content := `sources:
+- url: https://github.com/user/app-config
+  ref: v1.2.0
+  directory: config
+- url: ../assets
+  directory: public/assets
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sources, err := ReadSourcesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := api.SourceList{
+		{Source: git.MustParse("https://github.com/user/app-config#v1.2.0"), Directory: "config"},
+		{Source: git.MustParse("../assets"), Directory: "public/assets"},
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("got %#v, expected %#v", sources, expected)
+	}
+}
+
+func TestDownloadSources(t *testing.T) {
+	dir, err := os.MkdirTemp("", "s2i-sources-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configDir := filepath.Join(dir, "config")
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "app.yaml"), []byte("name: app\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &api.Config{
+		WorkingDir: filepath.Join(dir, "work"),
+		ForceCopy:  true,
+		Sources:    api.SourceList{{Source: git.MustParse(configDir), Directory: "etc/config"}},
+	}
+	if err := DownloadSources(fs.NewFileSystem(), config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(config.WorkingSourceDir, "etc", "config", "app.yaml"))
+	if err != nil {
+		t.Fatalf("the source was not downloaded into its directory: %v", err)
+	}
+	if string(data) != "name: app\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	// the directory of a source cannot be taken by the sources of the build
+	if err := DownloadSources(fs.NewFileSystem(), config); err == nil {
+		t.Errorf("expected an error for a directory which exists in the sources")
+	}
+}
@@ -1,12 +1,17 @@
 package scripts
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
@@ -30,9 +35,11 @@ type downloader struct {
 	schemeReaders map[string]schemeReader
 }
 
-// NewDownloader creates an instance of the default Downloader implementation
-func NewDownloader(proxyConfig *api.ProxyConfig) Downloader {
-	httpReader := NewHTTPURLReader(proxyConfig)
+// NewDownloader creates an instance of the default Downloader implementation.
+// The downloads over http(s) are cached in cacheDir, unless it is empty, and
+// each of their attempts is allowed to take timeout, unless it is 0.
+func NewDownloader(proxyConfig *api.ProxyConfig, cacheDir string, timeout time.Duration) Downloader {
+	httpReader := NewHTTPURLReader(proxyConfig, cacheDir, timeout)
 	return &downloader{
 		schemeReaders: map[string]schemeReader{
 			"http":  httpReader,
@@ -46,6 +53,8 @@ func NewDownloader(proxyConfig *api.ProxyConfig) Downloader {
 // Download downloads the file pointed to by URL into local targetFile
 // Returns information a boolean flag informing whether any download/copy operation
 // happened and an error if there was a problem during that operation
+// When the fragment of the URL is sha256=<checksum>, the checksum of the
+// downloaded file is verified.
 func (d *downloader) Download(url *url.URL, targetFile string) (*git.SourceInfo, error) {
 	r := d.schemeReaders[url.Scheme]
 	info := &git.SourceInfo{}
@@ -68,20 +77,43 @@ func (d *downloader) Download(url *url.URL, targetFile string) (*git.SourceInfo,
 		return nil, err
 	}
 
-	if _, err = io.Copy(out, reader); err != nil {
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(out, hash), reader); err != nil {
 		os.Remove(targetFile)
 		log.Warningf("Skipping file %s due to error copying from source: %v", targetFile, err)
 		return nil, err
 	}
+	if err = verifyChecksum(url, hash.Sum(nil)); err != nil {
+		os.Remove(targetFile)
+		return nil, err
+	}
 
 	log.V(2).Infof("Downloaded '%s'", url.String())
 	info.Location = url.String()
 	return info, nil
 }
 
+// verifyChecksum checks the SHA-256 checksum of the content downloaded from
+// the URL against the one given by its sha256=<checksum> fragment, if any.
+func verifyChecksum(url *url.URL, sum []byte) error {
+	if !strings.HasPrefix(url.Fragment, "sha256=") {
+		return nil
+	}
+	expected := strings.ToLower(strings.TrimPrefix(url.Fragment, "sha256="))
+	if actual := hex.EncodeToString(sum); actual != expected {
+		return fmt.Errorf("the SHA-256 checksum of %s is %s, expected %s", url.Redacted(), actual, expected)
+	}
+	return nil
+}
+
 // HTTPURLReader retrieves a response from a given HTTP(S) URL.
 type HTTPURLReader struct {
 	Get func(url string) (*http.Response, error)
+	// Do sends the requests of the downloads cached in CacheDir.
+	Do func(req *http.Request) (*http.Response, error)
+	// CacheDir is the directory the downloads are cached in, or empty not to
+	// cache them.
+	CacheDir string
 }
 
 var transportMap map[api.ProxyConfig]*http.Transport
@@ -91,9 +123,10 @@ func init() {
 	transportMap = make(map[api.ProxyConfig]*http.Transport)
 }
 
-// NewHTTPURLReader returns a new HTTPURLReader.
-func NewHTTPURLReader(proxyConfig *api.ProxyConfig) *HTTPURLReader {
-	getFunc := http.Get
+// NewHTTPURLReader returns a new HTTPURLReader, caching the downloads in
+// cacheDir unless it is empty.
+func NewHTTPURLReader(proxyConfig *api.ProxyConfig, cacheDir string, timeout time.Duration) *HTTPURLReader {
+	client := &http.Client{Timeout: timeout}
 	if proxyConfig != nil {
 		transportMapMutex.Lock()
 		transport, ok := transportMap[*proxyConfig]
@@ -109,16 +142,16 @@ func NewHTTPURLReader(proxyConfig *api.ProxyConfig) *HTTPURLReader {
 			transportMap[*proxyConfig] = transport
 		}
 		transportMapMutex.Unlock()
-		client := &http.Client{
-			Transport: transport,
-		}
-		getFunc = client.Get
+		client.Transport = transport
 	}
-	return &HTTPURLReader{Get: getFunc}
+	return &HTTPURLReader{Get: client.Get, Do: client.Do, CacheDir: cacheDir}
 }
 
 // Read produces an io.Reader from an http(s) URL.
 func (h *HTTPURLReader) Read(url *url.URL) (io.ReadCloser, error) {
+	if len(h.CacheDir) > 0 {
+		return h.readCached(url)
+	}
 	resp, err := h.Get(url.String())
 	if err != nil {
 		if resp != nil {
@@ -0,0 +1,149 @@
+package scripts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/storage/pkg/lockfile"
+
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+// downloadAttempts is the number of attempts of a cached download, each of
+// them resuming the download where the previous one stopped.
+const downloadAttempts = 3
+
+// readCached returns the content of the URL from the download cache, after
+// downloading it when it is missing or its ETag changed. A download which is
+// interrupted is resumed by the next attempt, or by the next build. The cached
+// content is locked while it is read and updated, so that the builds sharing
+// the cache, concurrently or in other processes, wait for each other.
+func (h *HTTPURLReader) readCached(u *url.URL) (io.ReadCloser, error) {
+	if err := os.MkdirAll(h.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(h.CacheDir, cacheKey(u))
+	lock, err := lockfile.GetLockFile(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if err = h.fetch(u, path); err == nil {
+			return os.Open(path)
+		}
+		if _, ok := err.(s2ierr.Error); ok {
+			return nil, err
+		}
+		log.V(1).Infof("Attempt %d of %d to download %s failed: %v", attempt, downloadAttempts, u.Redacted(), err)
+	}
+	return nil, err
+}
+
+// fetch updates the cached content of the URL at path. The content being
+// downloaded is written to path.partial, and the ETags of the content and of
+// the partial content to path.etag and path.partial.etag.
+func (h *HTTPURLReader) fetch(u *url.URL, path string) error {
+	partial := path + ".partial"
+	req, err := http.NewRequest(http.MethodGet, withoutFragment(u), nil)
+	if err != nil {
+		return err
+	}
+	if etag := readETag(path); len(etag) > 0 {
+		req.Header.Set("If-None-Match", etag)
+	}
+	var offset int64
+	if etag := readETag(partial); len(etag) > 0 {
+		if info, err := os.Stat(partial); err == nil && info.Size() > 0 {
+			offset = info.Size()
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", etag)
+		}
+	}
+
+	resp, err := h.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		log.V(2).Infof("Using the cached download of %s", u.Redacted())
+		return nil
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		log.V(2).Infof("Resuming the download of %s at byte %d", u.Redacted(), offset)
+		flags = os.O_WRONLY | os.O_APPEND
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		if err := writeETag(partial, resp.Header.Get("ETag")); err != nil {
+			return err
+		}
+	default:
+		return s2ierr.NewDownloadError(u.String(), resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(partial, flags, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partial, path); err != nil {
+		return err
+	}
+	if err := writeETag(path, readETag(partial)); err != nil {
+		return err
+	}
+	return writeETag(partial, "")
+}
+
+// cacheKey returns the name of the cached content of the URL.
+func cacheKey(u *url.URL) string {
+	sum := sha256.Sum256([]byte(withoutFragment(u)))
+	return hex.EncodeToString(sum[:])
+}
+
+// withoutFragment returns the URL without its fragment, which is not sent to
+// the server.
+func withoutFragment(u *url.URL) string {
+	c := *u
+	c.Fragment = ""
+	c.RawFragment = ""
+	return c.String()
+}
+
+// readETag returns the ETag recorded for the cached content at path.
+func readETag(path string) string {
+	data, err := ioutil.ReadFile(path + ".etag")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeETag records the ETag of the cached content at path, or removes it
+// when the ETag is empty.
+func writeETag(path, etag string) error {
+	if len(etag) == 0 {
+		if err := os.Remove(path + ".etag"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(path+".etag", []byte(etag), 0600)
+}
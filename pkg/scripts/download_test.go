@@ -5,10 +5,15 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 )
@@ -144,3 +149,127 @@ func TestNoDownloader(t *testing.T) {
 		t.Errorf("Expected error, got nil!")
 	}
 }
+
+func TestDownloadChecksum(t *testing.T) {
+	dl, fr := getDownloader()
+	fr.content = "test file content"
+	target := filepath.Join(t.TempDir(), "file")
+	// echo -n "test file content" | sha256sum
+	u, _ := url.Parse("http://www.test.url/a/file#sha256=60f5237ed4049f0382661ef009d2bc42e48c3ceb3edb6600f7024e7ab3b838f3")
+	if _, err := dl.Download(u, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	u.Fragment = "sha256=0000"
+	if _, err := dl.Download(u, target); err == nil {
+		t.Errorf("Expected a checksum error")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("Expected the file with a wrong checksum to be removed, got %v", err)
+	}
+}
+
+func TestHTTPReadCached(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	var statuses []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("ETag", `"v1"`)
+		http.ServeContent(rec, r, "assemble", time.Time{}, strings.NewReader(content))
+		statuses = append(statuses, rec.Code)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	sr := NewHTTPURLReader(nil, cacheDir, time.Minute)
+	u, _ := url.Parse(server.URL + "/assemble")
+	read := func() string {
+		rc, err := sr.Read(u)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer rc.Close()
+		data, _ := ioutil.ReadAll(rc)
+		return string(data)
+	}
+
+	if data := read(); data != content {
+		t.Errorf("Unexpected content %q", data)
+	}
+	if data := read(); data != content {
+		t.Errorf("Unexpected cached content %q", data)
+	}
+
+	// simulate an interrupted download of a new version of the script
+	path := filepath.Join(cacheDir, cacheKey(u))
+	os.Remove(path)
+	os.Remove(path + ".etag")
+	if err := ioutil.WriteFile(path+".partial", []byte(content[:300]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path+".partial.etag", []byte(`"v1"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data := read(); data != content {
+		t.Errorf("Unexpected resumed content %q", data)
+	}
+
+	expected := []int{http.StatusOK, http.StatusNotModified, http.StatusPartialContent}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected the responses %v, got %v", expected, statuses)
+	}
+	if _, err := os.Stat(path + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial download to be removed, got %v", err)
+	}
+}
+
+func TestHTTPReadCachedConcurrently(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	var mu sync.Mutex
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		mu.Lock()
+		downloads++
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, content)
+	}))
+	defer server.Close()
+
+	sr := NewHTTPURLReader(nil, t.TempDir(), time.Minute)
+	u, _ := url.Parse(server.URL + "/assemble")
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rc, err := sr.Read(u)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer rc.Close()
+			if data, _ := ioutil.ReadAll(rc); string(data) != content {
+				errs <- fmt.Errorf("unexpected content %q", data)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if downloads != 1 {
+		t.Errorf("Expected the script to be downloaded once, got %d downloads", downloads)
+	}
+}
@@ -10,14 +10,28 @@ import (
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util"
 )
 
 // GetEnvironment gets the .s2i/environment file located in the sources and
 // parse it into EnvironmentList.
 func GetEnvironment(path string) (api.EnvironmentList, error) {
-	envPath := filepath.Join(path, ".s2i", constants.Environment)
+	return readEnvironmentFile(path, constants.Environment)
+}
+
+// GetRuntimeEnvironment gets the .s2i/environment.runtime file located in the
+// sources and parse it into EnvironmentList.
+func GetRuntimeEnvironment(path string) (api.EnvironmentList, error) {
+	return readEnvironmentFile(path, constants.RuntimeEnvironment)
+}
+
+// readEnvironmentFile parses the given environment file of the .s2i directory
+// of the sources.
+func readEnvironmentFile(path, name string) (api.EnvironmentList, error) {
+	envPath := filepath.Join(path, ".s2i", name)
 	if _, err := os.Stat(envPath); os.IsNotExist(err) {
-		return nil, errors.New("no environment file found in application sources")
+		return nil, fmt.Errorf("no %s file found in application sources", name)
 	}
 
 	f, err := os.Open(envPath)
@@ -38,10 +52,35 @@ func GetEnvironment(path string) (api.EnvironmentList, error) {
 		result.Set(s)
 	}
 
-	log.V(1).Infof("Setting %d environment variables provided by environment file in sources", len(result))
+	log.V(1).Infof("Setting %d environment variables provided by %s file in sources", len(result), name)
 	return result, scanner.Err()
 }
 
+// SourceInfoEnvironment returns the environment variables describing the
+// sources, leaving out the ones the source information is missing.
+func SourceInfoEnvironment(info *git.SourceInfo) api.EnvironmentList {
+	result := api.EnvironmentList{}
+	if info == nil {
+		return result
+	}
+	author := ""
+	if len(info.AuthorName) > 0 {
+		author = fmt.Sprintf("%s <%s>", info.AuthorName, info.AuthorEmail)
+	}
+	for _, e := range []api.EnvironmentSpec{
+		{Name: constants.GitCommitEnvironment, Value: info.CommitID},
+		{Name: constants.GitRefEnvironment, Value: info.Ref},
+		{Name: constants.GitAuthorEnvironment, Value: author},
+		{Name: constants.GitDateEnvironment, Value: info.Date},
+		{Name: constants.SourceURLEnvironment, Value: info.Location},
+	} {
+		if len(e.Value) > 0 {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
 // ConvertEnvironmentList converts the EnvironmentList to "key=val" strings.
 func ConvertEnvironmentList(env api.EnvironmentList) (result []string) {
 	for _, e := range env {
@@ -54,24 +93,37 @@ func ConvertEnvironmentList(env api.EnvironmentList) (result []string) {
 func ConvertEnvironmentToDocker(env api.EnvironmentList) (result string) {
 	for i, e := range env {
 		if i == 0 {
-			result += fmt.Sprintf("ENV %s=\"%s\"", e.Name, escape(e.Value))
+			result += fmt.Sprintf("ENV %s=\"%s\"", e.Name, util.EscapeDockerfileValue(e.Value))
 		} else {
-			result += fmt.Sprintf(" \\\n    %s=\"%s\"", e.Name, escape(e.Value))
+			result += fmt.Sprintf(" \\\n    %s=\"%s\"", e.Name, util.EscapeDockerfileValue(e.Value))
 		}
 	}
 	result += "\n"
 	return
 }
 
-// escape returns the passed-in value, escaped so that it will not undergo
-// expansion in an ENV instruction.
-func escape(value string) string {
-	result := ""
-	for _, ch := range value {
-		if strings.ContainsRune(`$"\`, ch) {
-			result += `\`
+// ConvertBuildArgsToDocker converts the build arguments into ARG instructions
+// declaring the arguments with their values as defaults.
+func ConvertBuildArgsToDocker(args api.BuildArgList) (result string) {
+	for _, a := range args {
+		result += fmt.Sprintf("ARG %s=\"%s\"\n", a.Name, util.EscapeDockerfileValue(a.Value))
+	}
+	return
+}
+
+// ConvertBuildArgsToDockerEnv converts the build arguments into an ENV
+// instruction setting the variables to the values of the arguments declared by
+// ConvertBuildArgsToDocker, including the ones given to the docker build.
+func ConvertBuildArgsToDockerEnv(args api.BuildArgList) (result string) {
+	for i, a := range args {
+		if i == 0 {
+			result += fmt.Sprintf("ENV %s=\"${%s}\"", a.Name, a.Name)
+		} else {
+			result += fmt.Sprintf(" \\\n    %s=\"${%s}\"", a.Name, a.Name)
 		}
-		result += string(ch)
 	}
-	return result
+	if len(result) > 0 {
+		result += "\n"
+	}
+	return
 }
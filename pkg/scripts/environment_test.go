@@ -1,9 +1,11 @@
 package scripts
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
 )
 
 func TestConvertEnvironmentList(t *testing.T) {
@@ -60,3 +62,56 @@ func TestConvertEnvironmentToDocker(t *testing.T) {
 		t.Errorf("Expected environment\n%s\ngot\n%s", expectedOutput, output)
 	}
 }
+
+func TestConvertBuildArgsToDocker(t *testing.T) {
+	args := api.BuildArgList{
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+		{Name: "VERSION", Value: "${pinned}"},
+	}
+	expectedOutput := `ARG HTTP_PROXY="http://proxy:3128"
+ARG VERSION="\${pinned}"
+`
+	output := ConvertBuildArgsToDocker(args)
+	if output != expectedOutput {
+		t.Errorf("Expected build arguments\n%s\ngot\n%s", expectedOutput, output)
+	}
+}
+
+func TestConvertBuildArgsToDockerEnv(t *testing.T) {
+	args := api.BuildArgList{
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+		{Name: "VERSION", Value: "${pinned}"},
+	}
+	expectedOutput := `ENV HTTP_PROXY="${HTTP_PROXY}" \
+    VERSION="${VERSION}"
+`
+	output := ConvertBuildArgsToDockerEnv(args)
+	if output != expectedOutput {
+		t.Errorf("Expected environment\n%s\ngot\n%s", expectedOutput, output)
+	}
+	if output := ConvertBuildArgsToDockerEnv(nil); output != "" {
+		t.Errorf("Expected no environment without build arguments, got %q", output)
+	}
+}
+
+func TestSourceInfoEnvironment(t *testing.T) {
+	info := &git.SourceInfo{
+		CommitID:    "1bf4f04",
+		Ref:         "master",
+		AuthorName:  "John Doe",
+		AuthorEmail: "john@example.com",
+		Location:    "https://github.com/openshift/ruby-hello-world",
+	}
+	expected := api.EnvironmentList{
+		{Name: "S2I_GIT_COMMIT", Value: "1bf4f04"},
+		{Name: "S2I_GIT_REF", Value: "master"},
+		{Name: "S2I_GIT_AUTHOR", Value: "John Doe <john@example.com>"},
+		{Name: "S2I_SOURCE_URL", Value: "https://github.com/openshift/ruby-hello-world"},
+	}
+	if result := SourceInfoEnvironment(info); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected result. Expected: %#v. Actual: %#v", expected, result)
+	}
+	if result := SourceInfoEnvironment(nil); len(result) != 0 {
+		t.Errorf("Expected no environment without source information, got %#v", result)
+	}
+}
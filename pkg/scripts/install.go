@@ -44,6 +44,10 @@ const (
 	// ScriptURLHandler is the name of the script URL handler
 	ScriptURLHandler = "script URL handler"
 
+	// ScriptOverrideHandler is the name of the handler of the URLs given for
+	// individual scripts
+	ScriptOverrideHandler = "script override handler"
+
 	// ImageURLHandler is the name of the image URL handler
 	ImageURLHandler = "image URL handler"
 
@@ -110,6 +114,32 @@ func (s *URLScriptHandler) Install(r *api.InstallResult) error {
 	return nil
 }
 
+// OverrideScriptHandler handles script download using the URLs given for
+// individual scripts.
+type OverrideScriptHandler struct {
+	URLScriptHandler
+	URLs map[string]string
+}
+
+// Get parses the URL given for the script.
+func (s *OverrideScriptHandler) Get(script string) *api.InstallResult {
+	u, ok := s.URLs[script]
+	if !ok || len(u) == 0 {
+		return nil
+	}
+	// unlike the scripts URL, the URL of a script might carry the checksum of
+	// the script in its fragment
+	scriptURL, err := url.Parse(u)
+	if err != nil {
+		log.Infof("invalid %s script url %q: %v", script, u, err)
+		return nil
+	}
+	return &api.InstallResult{
+		Script: script,
+		URL:    scriptURL.String(),
+	}
+}
+
 // SourceScriptHandler handles the case when the scripts are contained in the
 // source code directory.
 type SourceScriptHandler struct {
@@ -199,10 +229,21 @@ func NewInstaller(image string, scriptsURL string, proxyConfig *api.ProxyConfig,
 		dockerAuth: auth,
 		docker:     docker,
 		fs:         fs,
-		download:   NewDownloader(proxyConfig),
 	}
-	// Order is important here, first we try to get the scripts from provided URL,
-	// then we look into sources and check for .s2i/bin scripts.
+	if config != nil {
+		m.download = NewDownloader(proxyConfig, config.DownloadCacheDir, config.DownloadTimeout)
+	} else {
+		m.download = NewDownloader(proxyConfig, "", 0)
+	}
+	// Order is important here, first we try to get the scripts from the URLs
+	// provided for the individual scripts, then from provided URL, then we look
+	// into sources and check for .s2i/bin scripts.
+	if config != nil && len(config.ScriptURLs) > 0 {
+		m.Add(&OverrideScriptHandler{
+			URLScriptHandler: URLScriptHandler{Download: m.download, FS: m.fs, Name: ScriptOverrideHandler},
+			URLs:             config.ScriptURLs,
+		})
+	}
 	if len(m.ScriptsURL) > 0 {
 		m.Add(&URLScriptHandler{URL: m.ScriptsURL, Download: m.download, FS: m.fs, Name: ScriptURLHandler})
 	}
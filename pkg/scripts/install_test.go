@@ -351,3 +351,48 @@ func TestInstallOptionalFailedSources(t *testing.T) {
 		}
 	}
 }
+
+func TestInstallOptionalFromScriptURLs(t *testing.T) {
+	config := newFakeConfig()
+	download := config.download.(*test.FakeDownloader)
+	download.Err = map[string]error{"http://run.url/broken": fmt.Errorf("download error")}
+	inst := newFakeInstaller(config).(*DefaultScriptSourceManager)
+	inst.sources = append([]ScriptHandler{&OverrideScriptHandler{
+		URLScriptHandler: URLScriptHandler{Download: config.download, FS: config.fs, Name: ScriptOverrideHandler},
+		URLs: map[string]string{
+			constants.Assemble: "http://assemble.url/assemble.sh",
+			constants.Run:      "http://run.url/broken",
+		},
+	}}, inst.sources...)
+	results := inst.InstallOptional([]string{constants.Assemble, constants.Run, constants.SaveArtifacts}, "/output")
+	expected := map[string]string{
+		constants.Assemble:      "http://assemble.url/assemble.sh",
+		constants.Run:           "http://the.scripts.url/s2i/bin/run",
+		constants.SaveArtifacts: "http://the.scripts.url/s2i/bin/save-artifacts",
+	}
+	for _, r := range results {
+		isValidInstallResult(r, t)
+		if r.URL != expected[r.Script] {
+			t.Errorf("expected the %q script to be installed from %q, got %q", r.Script, expected[r.Script], r.URL)
+		}
+	}
+	if !reflect.DeepEqual(results[1].FailedSources, []string{ScriptOverrideHandler}) {
+		t.Errorf("expected the run script to fail from the %s, got %+v", ScriptOverrideHandler, results[1].FailedSources)
+	}
+	if target := download.Target[0]; target != filepath.Join("/output", constants.UploadScripts, constants.Assemble) {
+		t.Errorf("expected the assemble script to be downloaded to the upload scripts, got %q", target)
+	}
+}
+
+func TestNewInstallerWithScriptURLs(t *testing.T) {
+	config := &api.Config{ScriptURLs: map[string]string{constants.Assemble: "http://assemble.url/assemble.sh"}}
+	inst := NewInstaller("test-image", "http://foo.bar", nil, nil, api.AuthConfig{}, &testfs.FakeFileSystem{}, config)
+	sources := inst.(*DefaultScriptSourceManager).sources
+	firstHandler, ok := sources[0].(*OverrideScriptHandler)
+	if !ok {
+		t.Fatalf("expected first handler to be script override handler, got %#v", sources)
+	}
+	if !reflect.DeepEqual(firstHandler.URLs, config.ScriptURLs) {
+		t.Errorf("expected first handler to handle the script URLs, got %+v", firstHandler)
+	}
+}
@@ -0,0 +1,182 @@
+package scripts
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api/constants"
+)
+
+// Severity is the severity of a problem found in a script.
+type Severity string
+
+const (
+	// SeverityError is the severity of the problems which make the script fail
+	// in the container.
+	SeverityError Severity = "error"
+	// SeverityWarning is the severity of the problems which might make the
+	// script fail in the container.
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is a problem found in a script.
+type Problem struct {
+	// Script is the name of the script.
+	Script string
+	// Line is the number of the line the problem was found on, or 0 when it
+	// concerns the whole script.
+	Line int
+	// Severity is the severity of the problem.
+	Severity Severity
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the problem in the format of the compilers,
+// script:line: severity: message.
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", p.Script, p.Line, p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Script, p.Severity, p.Message)
+}
+
+// LintOptions are the options of the lint of the scripts.
+type LintOptions struct {
+	// NoBash is true when the image the scripts run in has no bash, so that
+	// the bash scripts and the bashisms of the sh scripts fail.
+	NoBash bool
+}
+
+// bashisms are the constructs of bash which the POSIX shells, such as the
+// dash or busybox sh of the minimal images, do not support.
+var bashisms = []struct {
+	pattern     *regexp.Regexp
+	description string
+}{
+	{regexp.MustCompile(`\[\[\s`), "[[ tests"},
+	{regexp.MustCompile(`^\s*function\s+[\w-]+`), "the function keyword"},
+	{regexp.MustCompile(`(^|[;&|]\s*)source\s`), "source instead of ."},
+	{regexp.MustCompile(`^\s*(export\s+)?\w+=\(`), "arrays"},
+	{regexp.MustCompile(`(^|[^&>])&>`), "&> redirections"},
+	{regexp.MustCompile(`<<<`), "here-strings"},
+	{regexp.MustCompile(`\$'`), "$'...' strings"},
+	{regexp.MustCompile(`\$\{#?\w+(\[|//?|\^|,|:[0-9])`), "bash parameter expansions"},
+	{regexp.MustCompile(`(^|[;&|]\s*)(declare|typeset|let|shopt|pushd|popd)\s`), "bash builtins"},
+	{regexp.MustCompile(`\[\s[^]]*\s==\s`), "== in [ tests"},
+	{regexp.MustCompile(`\$\{?(BASH_SOURCE|BASH_VERSION|PIPESTATUS|FUNCNAME|RANDOM)\b`), "bash variables"},
+}
+
+// LintScript checks the content of the script of the given name for the
+// problems which make it fail inside the container: CRLF line endings, a
+// missing shebang, and bash constructs when the image might not run them.
+func LintScript(name string, content []byte, opts LintOptions) []Problem {
+	problems := []Problem{}
+	if len(content) == 0 {
+		return problems
+	}
+	report := func(line int, severity Severity, format string, args ...interface{}) {
+		problems = append(problems, Problem{Script: name, Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if bytes.Contains(content, []byte("\r\n")) {
+		report(0, SeverityError, "the script has CRLF (Windows) line endings, convert it to LF (for example with dos2unix, or with core.autocrlf=false in Git)")
+		content = bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	interpreter := ""
+	if strings.HasPrefix(lines[0], "#!") {
+		interpreter = shebangInterpreter(lines[0])
+	} else if name == constants.Run {
+		// the run script is the command of the image, which the container
+		// runtime executes without a shell
+		report(1, SeverityError, "the script has no shebang, the container fails to execute it (exec format error), add #!/bin/sh")
+	} else {
+		report(1, SeverityWarning, "the script has no shebang, it is run by /bin/sh, add #!/bin/sh or #!/bin/bash")
+	}
+
+	switch interpreter {
+	case "bash":
+		if opts.NoBash {
+			report(1, SeverityError, "the script requires bash, which the image does not provide")
+		}
+		return problems
+	case "", "sh":
+	default:
+		return problems
+	}
+
+	severity := SeverityWarning
+	if opts.NoBash {
+		severity = SeverityError
+	}
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, bashism := range bashisms {
+			if bashism.pattern.MatchString(line) {
+				report(i+1, severity, "the sh script uses %s, which only bash supports, use #!/bin/bash or POSIX sh constructs", bashism.description)
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// shebangInterpreter returns the base name of the interpreter of the given
+// shebang line, resolving env.
+func shebangInterpreter(shebang string) string {
+	fields := strings.Fields(strings.TrimPrefix(shebang, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				return filepath.Base(field)
+			}
+		}
+	}
+	return interpreter
+}
+
+// LintDir checks the scripts of the given directory, which must also be
+// executable.
+func LintDir(dir string, opts LintOptions) ([]Problem, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	problems := []Problem{}
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if file.Mode()&0111 == 0 {
+			problems = append(problems, Problem{Script: file.Name(), Severity: SeverityWarning, Message: "the script is not executable, s2i makes it executable, but other tools might not (chmod +x, or git update-index --chmod=+x)"})
+		}
+		problems = append(problems, LintScript(file.Name(), content, opts)...)
+	}
+	return problems, nil
+}
+
+// HasErrors returns true if any of the problems is an error.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package scripts
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLintScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		content  string
+		noBash   bool
+		expected []string
+	}{
+		{
+			name:     "valid sh script",
+			script:   "assemble",
+			content:  "#!/bin/sh\nset -e\n# [[ in a comment\n[ \"$A\" = b ] && . ./env\n",
+			expected: []string{},
+		},
+		{
+			name:    "CRLF line endings",
+			script:  "assemble",
+			content: "#!/bin/bash\r\necho hi\r\n",
+			expected: []string{
+				"assemble: error: the script has CRLF (Windows) line endings, convert it to LF (for example with dos2unix, or with core.autocrlf=false in Git)",
+			},
+		},
+		{
+			name:    "missing shebang",
+			script:  "assemble",
+			content: "echo hi\n",
+			expected: []string{
+				"assemble:1: warning: the script has no shebang, it is run by /bin/sh, add #!/bin/sh or #!/bin/bash",
+			},
+		},
+		{
+			name:    "run script without shebang",
+			script:  "run",
+			content: "exec app\n",
+			expected: []string{
+				"run:1: error: the script has no shebang, the container fails to execute it (exec format error), add #!/bin/sh",
+			},
+		},
+		{
+			name:     "bash script with bash",
+			script:   "assemble",
+			content:  "#!/usr/bin/env bash\n[[ -n $A ]] && source env\n",
+			expected: []string{},
+		},
+		{
+			name:    "bash script without bash",
+			script:  "assemble",
+			content: "#!/usr/bin/env bash\n[[ -n $A ]]\n",
+			noBash:  true,
+			expected: []string{
+				"assemble:1: error: the script requires bash, which the image does not provide",
+			},
+		},
+		{
+			name:    "bashisms with bash",
+			script:  "assemble",
+			content: "#!/bin/sh\nif [[ -n $A ]]; then\n  ls &> /dev/null\nfi\n",
+			expected: []string{
+				"assemble:2: warning: the sh script uses [[ tests, which only bash supports, use #!/bin/bash or POSIX sh constructs",
+				"assemble:3: warning: the sh script uses &> redirections, which only bash supports, use #!/bin/bash or POSIX sh constructs",
+			},
+		},
+		{
+			name:    "bashisms without bash",
+			script:  "assemble",
+			content: "#!/bin/sh\nfiles=(a b)\necho ${A//x/y}\n",
+			noBash:  true,
+			expected: []string{
+				"assemble:2: error: the sh script uses arrays, which only bash supports, use #!/bin/bash or POSIX sh constructs",
+				"assemble:3: error: the sh script uses bash parameter expansions, which only bash supports, use #!/bin/bash or POSIX sh constructs",
+			},
+		},
+		{
+			name:     "other interpreter",
+			script:   "run",
+			content:  "#!/usr/bin/python3\nx = [[1]]\n",
+			noBash:   true,
+			expected: []string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := []string{}
+			for _, p := range LintScript(tc.script, []byte(tc.content), LintOptions{NoBash: tc.noBash}) {
+				problems = append(problems, p.String())
+			}
+			if !reflect.DeepEqual(problems, tc.expected) {
+				t.Errorf("expected the problems %q, got %q", tc.expected, problems)
+			}
+		})
+	}
+}
+
+func TestLintDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "assemble"), []byte("#!/bin/sh\necho hi\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run"), []byte("#!/bin/sh\r\nexec app\r\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := LintDir(dir, LintOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(problems) != 2 || problems[0].Script != "assemble" || problems[0].Severity != SeverityWarning || problems[1].Script != "run" || problems[1].Severity != SeverityError {
+		t.Errorf("expected a warning for assemble and an error for run, got %v", problems)
+	}
+	if !HasErrors(problems) || HasErrors(problems[:1]) {
+		t.Errorf("expected only the CRLF problem to be an error")
+	}
+}
@@ -0,0 +1,383 @@
+// Package selfupdate replaces the s2i binary with the one of the latest
+// release published on GitHub.
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultReleaseURL is the URL of the GitHub API describing the latest
+	// release of s2i.
+	DefaultReleaseURL = "https://api.github.com/repos/openshift/source-to-image/releases/latest"
+
+	// ChecksumsAsset is the asset of the releases listing the SHA-512
+	// checksums of their archives, as written by sha512sum.
+	ChecksumsAsset = "SHA512-SUMS.txt"
+
+	// SignatureAsset is the asset of the releases holding the Ed25519
+	// signature of their checksums, as written by openssl pkeyutl -sign.
+	SignatureAsset = ChecksumsAsset + ".sig"
+
+	// maxDownloadSize is the size beyond which a download is rejected.
+	maxDownloadSize = 256 * 1024 * 1024
+)
+
+// releaseKey is the base64 encoded Ed25519 public key the checksums of the
+// releases are signed with. It is pinned at build time by hack/common.sh from
+// S2I_RELEASE_KEY, and binaries built without it cannot be updated.
+var releaseKey = ""
+
+// Release is a release of s2i published on GitHub.
+type Release struct {
+	// TagName is the version of the release, e.g. v1.4.0.
+	TagName string `json:"tag_name"`
+	// HTMLURL is the URL of the page of the release.
+	HTMLURL string `json:"html_url"`
+	// Assets are the files published with the release.
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file published with a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Updater fetches the releases of s2i.
+type Updater struct {
+	// ReleaseURL is the URL of the GitHub API describing the latest release.
+	ReleaseURL string
+	// Token authenticates the requests to the GitHub API, which limits the
+	// rate of the anonymous ones, unless it is empty.
+	Token string
+	// GOOS and GOARCH are the platform of the binary installed.
+	GOOS   string
+	GOARCH string
+	// Key is the public key the checksums of the releases must be signed
+	// with. Nothing is downloaded when it is nil.
+	Key ed25519.PublicKey
+
+	client *http.Client
+}
+
+// NewUpdater returns an updater of the binary of the current platform,
+// authenticated by the GITHUB_TOKEN environment variable when it is set.
+func NewUpdater() *Updater {
+	return &Updater{
+		ReleaseURL: DefaultReleaseURL,
+		Token:      os.Getenv("GITHUB_TOKEN"),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		Key:        pinnedKey(),
+		client:     &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// pinnedKey returns the release key pinned in the binary, or nil when there is
+// none.
+func pinnedKey() ed25519.PublicKey {
+	key, err := base64.StdEncoding.DecodeString(releaseKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil
+	}
+	return ed25519.PublicKey(key)
+}
+
+// LatestRelease returns the latest release of s2i.
+func (u *Updater) LatestRelease() (*Release, error) {
+	req, err := http.NewRequest("GET", u.ReleaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if len(u.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+u.Token)
+	}
+	data, err := u.get(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the latest release of s2i: %v", err)
+	}
+	release := &Release{}
+	if err := json.Unmarshal(data, release); err != nil {
+		return nil, fmt.Errorf("unable to parse the latest release of s2i: %v", err)
+	}
+	if len(release.TagName) == 0 {
+		return nil, fmt.Errorf("the latest release of s2i has no version")
+	}
+	return release, nil
+}
+
+// Download downloads the archive of the release for the platform of the
+// updater, verifies the signature of the checksums of the release and the
+// checksum of the archive against them, and returns the s2i binary it holds.
+func (u *Updater) Download(release *Release) ([]byte, error) {
+	if len(u.Key) == 0 {
+		return nil, fmt.Errorf("this binary was built without the key signing the releases of s2i, so they cannot be verified")
+	}
+	archive, err := release.asset(u.archiveSuffix())
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := release.asset(ChecksumsAsset)
+	if err != nil {
+		return nil, fmt.Errorf("%v, the archive cannot be verified", err)
+	}
+	signature, err := release.asset(SignatureAsset)
+	if err != nil {
+		return nil, fmt.Errorf("%v, the checksums cannot be verified", err)
+	}
+	sums, err := u.download(checksums)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := u.download(signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(u.Key, sums, sig) {
+		return nil, fmt.Errorf("the signature of %s of the release %s is invalid", ChecksumsAsset, release.TagName)
+	}
+	expected, err := checksum(sums, archive.Name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.download(archive)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha512.Sum512(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("the SHA-512 checksum of %s is %s, expected %s", archive.Name, actual, expected)
+	}
+	if u.GOOS == "windows" {
+		return extractZip(data, "s2i.exe")
+	}
+	return extractTarGz(data, "s2i")
+}
+
+// archiveSuffix returns the suffix of the name of the archive of the
+// platform of the updater, e.g. -linux-amd64.tar.gz.
+func (u *Updater) archiveSuffix() string {
+	if u.GOOS == "windows" {
+		return fmt.Sprintf("-%s-%s.zip", u.GOOS, u.GOARCH)
+	}
+	return fmt.Sprintf("-%s-%s.tar.gz", u.GOOS, u.GOARCH)
+}
+
+// asset returns the asset of the release whose name ends with the given
+// suffix.
+func (r *Release) asset(suffix string) (*Asset, error) {
+	for i := range r.Assets {
+		if strings.HasSuffix(r.Assets[i].Name, suffix) {
+			return &r.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the release %s of s2i has no %s asset", r.TagName, strings.TrimPrefix(suffix, "-"))
+}
+
+// download returns the content of the given asset.
+func (u *Updater) download(asset *Asset) ([]byte, error) {
+	req, err := http.NewRequest("GET", asset.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.get(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %v", asset.Name, err)
+	}
+	return data, nil
+}
+
+// get sends the given request and returns the body of the response.
+func (u *Updater) get(req *http.Request) ([]byte, error) {
+	client := u.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded %s", req.URL.Redacted(), resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", req.URL.Redacted(), maxDownloadSize)
+	}
+	return data, nil
+}
+
+// checksum returns the checksum of the file of the given name listed by the
+// given output of sha512sum.
+func checksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s does not list the checksum of %s", ChecksumsAsset, name)
+}
+
+// extractTarGz returns the regular file of the given name of a gzip
+// compressed tar archive.
+func extractTarGz(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the archive has no %s binary", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// extractZip returns the file of the given name of a zip archive.
+func extractZip(data []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if path.Base(f.Name) != name {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return nil, fmt.Errorf("the archive has no %s binary", name)
+}
+
+// IsNewer returns true if the given release version, e.g. v1.4.0, is newer
+// than the current version. Development builds, e.g. v1.4.0-12-gabcdef0, are
+// as new as the version they follow, and builds of an unknown version are
+// older than any release.
+func IsNewer(release, current string) (bool, error) {
+	releaseVersion, err := parseVersion(release)
+	if err != nil {
+		return false, fmt.Errorf("invalid release version %q: %v", release, err)
+	}
+	currentVersion, err := parseVersion(current)
+	if err != nil {
+		return true, nil
+	}
+	for i := range releaseVersion {
+		if releaseVersion[i] != currentVersion[i] {
+			return releaseVersion[i] > currentVersion[i], nil
+		}
+	}
+	return false, nil
+}
+
+// parseVersion returns the major, minor and patch numbers of the given
+// version.
+func parseVersion(version string) ([3]int, error) {
+	numbers := [3]int{}
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) > len(numbers) {
+		return numbers, fmt.Errorf("too many numbers")
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return numbers, err
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// Executable returns the path of the binary of the running process, with
+// the symbolic links resolved.
+func Executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// Replace atomically replaces the file of the given path with the given
+// binary, keeping its permissions. The binary is written to a temporary file
+// of the same directory, which is renamed over the file. A running binary
+// cannot be replaced on Windows, so it is renamed with the .old extension
+// first.
+func Replace(path string, binary []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".s2i-update-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			os.Rename(old, path)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmp.Name(), path)
+}
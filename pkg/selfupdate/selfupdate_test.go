@@ -0,0 +1,185 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarGz returns a gzip compressed tar archive of the given files.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newReleaseServer serves a release whose checksums list the given checksum
+// of the archive, or the actual one when it is empty, and are signed with the
+// given key.
+func newReleaseServer(t *testing.T, archive []byte, sum string, key ed25519.PrivateKey) *httptest.Server {
+	if len(sum) == 0 {
+		s := sha512.Sum512(archive)
+		sum = hex.EncodeToString(s[:])
+	}
+	archiveName := "source-to-image-v1.5.0-abcdef0-linux-amd64.tar.gz"
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Release{
+			TagName: "v1.5.0",
+			Assets: []Asset{
+				{Name: archiveName, URL: server.URL + "/download/" + archiveName},
+				{Name: ChecksumsAsset, URL: server.URL + "/download/" + ChecksumsAsset},
+				{Name: SignatureAsset, URL: server.URL + "/download/" + SignatureAsset},
+			},
+		})
+	})
+	mux.HandleFunc("/download/"+archiveName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	mux.HandleFunc("/download/"+ChecksumsAsset, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sum + "  " + archiveName + "\n"))
+	})
+	mux.HandleFunc("/download/"+SignatureAsset, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ed25519.Sign(key, []byte(sum+"  "+archiveName+"\n")))
+	})
+	return server
+}
+
+func TestDownload(t *testing.T) {
+	archive := tarGz(t, map[string]string{"./s2i": "new binary", "./README": "readme"})
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for desc, sum := range map[string]string{"valid checksum": "", "invalid checksum": strings.Repeat("0", 128)} {
+		server := newReleaseServer(t, archive, sum, private)
+		u := &Updater{ReleaseURL: server.URL + "/releases/latest", GOOS: "linux", GOARCH: "amd64", Key: public}
+		release, err := u.LatestRelease()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", desc, err)
+		}
+		binary, err := u.Download(release)
+		if len(sum) > 0 {
+			if err == nil || !strings.Contains(err.Error(), "SHA-512 checksum") {
+				t.Errorf("%s: expected a checksum error, got %v", desc, err)
+			}
+		} else if err != nil || string(binary) != "new binary" {
+			t.Errorf("%s: expected the binary of the archive, got %q, %v", desc, binary, err)
+		}
+
+		u.GOARCH = "s390x"
+		if _, err := u.Download(release); err == nil || !strings.Contains(err.Error(), "has no linux-s390x.tar.gz asset") {
+			t.Errorf("%s: expected a missing archive error, got %v", desc, err)
+		}
+		server.Close()
+	}
+}
+
+func TestDownloadSignature(t *testing.T) {
+	archive := tarGz(t, map[string]string{"./s2i": "new binary"})
+	public, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newReleaseServer(t, archive, "", other)
+	defer server.Close()
+	u := &Updater{ReleaseURL: server.URL + "/releases/latest", GOOS: "linux", GOARCH: "amd64", Key: public}
+	release, err := u.LatestRelease()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := u.Download(release); err == nil || !strings.Contains(err.Error(), "signature of SHA512-SUMS.txt") {
+		t.Errorf("expected a signature error, got %v", err)
+	}
+
+	u.Key = nil
+	if _, err := u.Download(release); err == nil || !strings.Contains(err.Error(), "built without the key") {
+		t.Errorf("expected a missing key error, got %v", err)
+	}
+	release.Assets = release.Assets[:2]
+	u.Key = public
+	if _, err := u.Download(release); err == nil || !strings.Contains(err.Error(), "has no SHA512-SUMS.txt.sig asset") {
+		t.Errorf("expected a missing signature error, got %v", err)
+	}
+}
+
+func TestIsNewer(t *testing.T) {
+	tests := []struct {
+		release, current string
+		expected         bool
+	}{
+		{"v1.5.0", "v1.4.0", true},
+		{"v1.4.1", "v1.4.0", true},
+		{"v1.10.0", "v1.9.3", true},
+		{"v1.4.0", "v1.4.0", false},
+		{"v1.4.0", "v1.4.0-12-gabcdef0-dirty", false},
+		{"v1.4.0", "v1.5.0", false},
+		{"v1.4.0", "", true},
+		{"v1.4.0", "unknown", true},
+	}
+	for _, tc := range tests {
+		newer, err := IsNewer(tc.release, tc.current)
+		if err != nil {
+			t.Errorf("%s, %s: unexpected error: %v", tc.release, tc.current, err)
+			continue
+		}
+		if newer != tc.expected {
+			t.Errorf("%s, %s: expected newer to be %t", tc.release, tc.current, tc.expected)
+		}
+	}
+	if _, err := IsNewer("latest", "v1.4.0"); err == nil {
+		t.Errorf("expected an error for an invalid release version")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s2i")
+	if err := ioutil.WriteFile(path, []byte("old binary"), 0751); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replace(path, []byte("new binary")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "new binary" {
+		t.Errorf("expected the binary to be replaced, got %q, %v", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0751 {
+		t.Errorf("expected the permissions to be kept, got %v, %v", info.Mode(), err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("expected the temporary file to be removed, got %d files", len(files))
+	}
+}
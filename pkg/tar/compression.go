@@ -0,0 +1,111 @@
+package tar
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+// NewCompressionWriter wraps the given writer so that everything written to
+// the returned WriteCloser is compressed using the requested algorithm.
+// Closing the returned writer flushes the compressor, but does not close the
+// underlying writer.
+func NewCompressionWriter(w io.Writer, compression api.Compression) (io.WriteCloser, error) {
+	switch compression {
+	case "", api.CompressionNone:
+		return nopWriteCloser{w}, nil
+	case api.CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case api.CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// NewCompressedReader returns an io.ReadCloser from which the content of the
+// given reader can be read, compressed using the requested algorithm.
+func NewCompressedReader(r io.Reader, compression api.Compression) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		cw, err := NewCompressionWriter(pw, compression)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		buf := getCopyBuffer()
+		defer putCopyBuffer(buf)
+		if _, err = io.CopyBuffer(cw, r, *buf); err != nil {
+			cw.Close()
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(cw.Close())
+	}()
+	return pr
+}
+
+// magic numbers of the compressed streams
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewDecompressedReader returns an io.ReadCloser from which the content of the
+// given reader can be read decompressed, the compression being detected from
+// its first bytes. An uncompressed stream is read as-is. The content is
+// decompressed in another goroutine, and zstd streams by several goroutines,
+// so that the decompression runs in parallel with the consumer of the
+// returned reader, which must close it.
+func NewDecompressedReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var decompress func(io.Writer) error
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		decompress = func(w io.Writer) error {
+			gr, err := gzip.NewReader(br)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, gr)
+			return err
+		}
+	case bytes.HasPrefix(magic, zstdMagic):
+		decompress = func(w io.Writer) error {
+			zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(runtime.GOMAXPROCS(0)))
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			_, err = zr.WriteTo(w)
+			return err
+		}
+	default:
+		return ioutil.NopCloser(br), nil
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(decompress(pw))
+	}()
+	return pr, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package tar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+func TestNewCompressedReader(t *testing.T) {
+	content := strings.Repeat("compressible build context ", 10000)
+	tests := []struct {
+		compression  api.Compression
+		decompress   func(io.Reader) (io.Reader, error)
+		expectError  bool
+		uncompressed bool
+	}{
+		{
+			compression:  api.CompressionNone,
+			decompress:   func(r io.Reader) (io.Reader, error) { return r, nil },
+			uncompressed: true,
+		},
+		{
+			compression: api.CompressionGzip,
+			decompress:  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+		{
+			compression: api.CompressionZstd,
+			decompress:  func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		},
+		{
+			compression: "lz4",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		compressed, err := ioutil.ReadAll(NewCompressedReader(strings.NewReader(content), tc.compression))
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.compression)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.compression, err)
+			continue
+		}
+		if !tc.uncompressed && len(compressed) >= len(content) {
+			t.Errorf("%s: expected content to be compressed, got %d bytes out of %d", tc.compression, len(compressed), len(content))
+		}
+		r, err := tc.decompress(bytes.NewReader(compressed))
+		if err != nil {
+			t.Errorf("%s: unable to decompress: %v", tc.compression, err)
+			continue
+		}
+		decompressed, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("%s: unable to decompress: %v", tc.compression, err)
+			continue
+		}
+		if string(decompressed) != content {
+			t.Errorf("%s: decompressed content differs from the original", tc.compression)
+		}
+	}
+}
+
+func TestNewDecompressedReader(t *testing.T) {
+	content := strings.Repeat("saved build artifacts ", 10000)
+	for _, compression := range []api.Compression{api.CompressionNone, api.CompressionGzip, api.CompressionZstd} {
+		compressed, err := ioutil.ReadAll(NewCompressedReader(strings.NewReader(content), compression))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", compression, err)
+			continue
+		}
+		r, err := NewDecompressedReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", compression, err)
+			continue
+		}
+		decompressed, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Errorf("%s: unable to decompress: %v", compression, err)
+			continue
+		}
+		if string(decompressed) != content {
+			t.Errorf("%s: decompressed content differs from the original one", compression)
+		}
+	}
+
+	r, err := NewDecompressedReader(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatalf("unexpected error on an empty stream: %v", err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || len(b) != 0 {
+		t.Errorf("expected an empty stream, got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,49 @@
+package tar
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+)
+
+// CopyArchive copies the entries of the tar archive read by tr to tw.
+func CopyArchive(tw *tar.Writer, tr *tar.Reader) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// StreamArchive streams the tar archive written by write to read, which run
+// concurrently. The padding following the end of the archive is drained, so
+// that write completes. The error of write takes precedence over the one of
+// read.
+func StreamArchive(write func(io.Writer) error, read func(io.Reader) error) error {
+	r, w := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		err := write(w)
+		w.CloseWithError(err)
+		writeErr <- err
+	}()
+	err := read(r)
+	if err == nil {
+		_, err = io.Copy(ioutil.Discard, r)
+	}
+	r.Close()
+	if e := <-writeErr; e != nil {
+		return e
+	}
+	return err
+}
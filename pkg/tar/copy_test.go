@@ -0,0 +1,49 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStreamArchive(t *testing.T) {
+	archive := &bytes.Buffer{}
+	tw := tar.NewWriter(archive)
+	if err := tw.WriteHeader(&tar.Header{Name: "file", Mode: 0644, Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+
+	copied := &bytes.Buffer{}
+	out := tar.NewWriter(copied)
+	err := StreamArchive(func(w io.Writer) error {
+		_, err := w.Write(archive.Bytes())
+		return err
+	}, func(r io.Reader) error {
+		return CopyArchive(out, tar.NewReader(r))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.Close()
+	tr := tar.NewReader(copied)
+	if header, err := tr.Next(); err != nil || header.Name != "file" {
+		t.Errorf("expected the entry to be copied, got %v, %v", header, err)
+	}
+
+	writeErr := errors.New("write failed")
+	err = StreamArchive(func(w io.Writer) error {
+		return writeErr
+	}, func(r io.Reader) error {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	})
+	if err != writeErr {
+		t.Errorf("expected the error of the writer, got %v", err)
+	}
+}
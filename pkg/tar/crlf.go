@@ -0,0 +1,81 @@
+package tar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+const (
+	// maxConvertedFileSize is the size of the largest file whose line endings
+	// are converted, as it is read into memory to compute its converted size.
+	maxConvertedFileSize = 16 * 1024 * 1024
+
+	// textDetectionSize is the size of the beginning of a file which tells a
+	// text file, without NUL bytes, from a binary file, as done by Git.
+	textDetectionSize = 8000
+)
+
+var (
+	crlf = []byte("\r\n")
+	lf   = []byte("\n")
+)
+
+// SetCRLFConversion sets the files whose CRLF line endings are converted to LF
+// during tar creation. With api.CRLFConversionScripts, the files of the given
+// directories, relative to the archived directory, are converted.
+func (t *stiTar) SetCRLFConversion(c api.CRLFConversion, scriptsDirs ...string) {
+	t.crlfConversion = c
+	t.crlfScriptsDirs = scriptsDirs
+}
+
+// convertsCRLF returns true if the CRLF line endings of the file of the given
+// path of the archived directory are converted.
+func (t *stiTar) convertsCRLF(dir, path string) bool {
+	switch t.crlfConversion {
+	case api.CRLFConversionAll:
+		return true
+	case api.CRLFConversionScripts:
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return false
+		}
+		for _, scriptsDir := range t.crlfScriptsDirs {
+			if strings.HasPrefix(rel, filepath.Clean(scriptsDir)+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// convertCRLF returns the content of the file of the given entry with its CRLF
+// line endings converted to LF, or nil if the file is streamed as-is: when it
+// is too large to be converted, or it is binary.
+func convertCRLF(e *tarEntry, c tarContent) ([]byte, error) {
+	var content []byte
+	switch {
+	case c.data != nil:
+		content = (*c.data)[:c.size]
+	case e.info.Size() > maxConvertedFileSize:
+		log.V(2).Infof("Not converting the line endings of %s, larger than %d bytes", e.path, maxConvertedFileSize)
+		return nil, nil
+	default:
+		var err error
+		if content, err = ioutil.ReadAll(c.file); err != nil {
+			return nil, err
+		}
+	}
+	head := content
+	if len(head) > textDetectionSize {
+		head = head[:textDetectionSize]
+	}
+	if bytes.IndexByte(head, 0) >= 0 || !bytes.Contains(content, crlf) {
+		return content, nil
+	}
+	log.V(5).Infof("Converting the CRLF line endings of %s to LF", e.path)
+	return bytes.ReplaceAll(content, crlf, lf), nil
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package tar
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file on the local file system.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// getFileID returns the identity of the file described by info, if the file
+// has more than one hard link.
+func getFileID(info os.FileInfo) (fileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
@@ -2,16 +2,21 @@ package tar
 
 import (
 	"archive/tar"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/openshift/source-to-image/pkg/api"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/ignore"
 	"github.com/openshift/source-to-image/pkg/util"
 	"github.com/openshift/source-to-image/pkg/util/fs"
 	utillog "github.com/openshift/source-to-image/pkg/util/log"
@@ -28,12 +33,33 @@ const defaultTimeout = 300 * time.Second
 // file when creating one. By default it is any file inside a .git metadata directory
 var DefaultExclusionPattern = regexp.MustCompile(`(^|/)\.git(/|$)`)
 
+// defaultConcurrency is the number of workers used to read files ahead of the
+// tar writer when creating a tar stream.
+var defaultConcurrency = runtime.NumCPU()
+
+// errTarAborted is used internally to stop walking the source tree when
+// writing the tar stream failed.
+var errTarAborted = errors.New("tar stream creation aborted")
+
 // Tar can create and extract tar files used in an STI build
 type Tar interface {
 	// SetExclusionPattern sets the exclusion pattern for tar
 	// creation
 	SetExclusionPattern(*regexp.Regexp)
 
+	// SetExclusionGlobs sets the gitignore style patterns of files excluded
+	// from tar creation, in addition to the exclusion pattern
+	SetExclusionGlobs(*ignore.GlobMatcher)
+
+	// SetSymlinkPolicy sets how symbolic links pointing outside of the
+	// archived directory are handled during tar creation
+	SetSymlinkPolicy(api.SymlinkPolicy)
+
+	// SetCRLFConversion sets the text files whose CRLF line endings are
+	// converted to LF during tar creation, the scripts being the files of the
+	// given directories relative to the archived directory
+	SetCRLFConversion(c api.CRLFConversion, scriptsDirs ...string)
+
 	// CreateTarFile creates a tar file in the base directory
 	// using the contents of dir directory
 	// The name of the new tar file is returned if successful
@@ -127,6 +153,14 @@ func (a RenameAdapter) WriteHeader(hdr *tar.Header) error {
 	} else if strings.HasPrefix(hdr.Name, a.Old+"/") {
 		hdr.Name = a.New + hdr.Name[len(a.Old):]
 	}
+	// hard links refer to other entries of the same tar file
+	if hdr.Typeflag == tar.TypeLink {
+		if hdr.Linkname == a.Old {
+			hdr.Linkname = a.New
+		} else if strings.HasPrefix(hdr.Linkname, a.Old+"/") {
+			hdr.Linkname = a.New + hdr.Linkname[len(a.Old):]
+		}
+	}
 
 	return a.Writer.WriteHeader(hdr)
 }
@@ -134,18 +168,20 @@ func (a RenameAdapter) WriteHeader(hdr *tar.Header) error {
 // New creates a new Tar
 func New(fs fs.FileSystem) Tar {
 	return &stiTar{
-		FileSystem: fs,
-		exclude:    DefaultExclusionPattern,
-		timeout:    defaultTimeout,
+		FileSystem:  fs,
+		exclude:     DefaultExclusionPattern,
+		timeout:     defaultTimeout,
+		concurrency: defaultConcurrency,
 	}
 }
 
 // NewWithTimeout creates a new Tar with the provided timeout extracting files.
 func NewWithTimeout(fs fs.FileSystem, timeout time.Duration) Tar {
 	return &stiTar{
-		FileSystem: fs,
-		exclude:    DefaultExclusionPattern,
-		timeout:    timeout,
+		FileSystem:  fs,
+		exclude:     DefaultExclusionPattern,
+		timeout:     timeout,
+		concurrency: defaultConcurrency,
 	}
 }
 
@@ -159,6 +195,7 @@ func NewParanoid(fs fs.FileSystem) Tar {
 		disallowOverwrite:    true,
 		disallowOutsidePaths: true,
 		disallowSpecialFiles: true,
+		concurrency:          defaultConcurrency,
 	}
 }
 
@@ -172,6 +209,7 @@ func NewParanoidWithTimeout(fs fs.FileSystem, timeout time.Duration) Tar {
 		disallowOverwrite:    true,
 		disallowOutsidePaths: true,
 		disallowSpecialFiles: true,
+		concurrency:          defaultConcurrency,
 	}
 }
 
@@ -184,6 +222,11 @@ type stiTar struct {
 	disallowOverwrite    bool
 	disallowOutsidePaths bool
 	disallowSpecialFiles bool
+	concurrency          int
+	symlinkPolicy        api.SymlinkPolicy
+	excludeGlobs         *ignore.GlobMatcher
+	crlfConversion       api.CRLFConversion
+	crlfScriptsDirs      []string
 }
 
 // SetExclusionPattern sets the exclusion pattern for tar creation.  The
@@ -208,8 +251,28 @@ func (t *stiTar) CreateTarFile(base, dir string) (string, error) {
 	return tarFile.Name(), nil
 }
 
-func (t *stiTar) shouldExclude(path string) bool {
-	return t.exclude != nil && t.exclude.String() != "" && t.exclude.MatchString(filepath.ToSlash(path))
+// SetSymlinkPolicy sets how symbolic links pointing outside of the archived
+// directory are handled during tar creation.
+func (t *stiTar) SetSymlinkPolicy(p api.SymlinkPolicy) {
+	t.symlinkPolicy = p
+}
+
+// SetExclusionGlobs sets the gitignore style patterns of files excluded from
+// tar creation. The paths given to the matcher are relative to the archived
+// directory, see GlobMatcher.In to match them relative to a subdirectory.
+func (t *stiTar) SetExclusionGlobs(m *ignore.GlobMatcher) {
+	t.excludeGlobs = m
+}
+
+func (t *stiTar) shouldExclude(dir, path string, info os.FileInfo) bool {
+	if t.exclude != nil && t.exclude.String() != "" && t.exclude.MatchString(filepath.ToSlash(path)) {
+		return true
+	}
+	if t.excludeGlobs != nil && path != dir {
+		rel, err := filepath.Rel(dir, path)
+		return err == nil && t.excludeGlobs.Match(filepath.ToSlash(rel), info.IsDir())
+	}
+	return false
 }
 
 // CreateTarStream calls CreateTarStreamToTarWriter with a nil logger
@@ -233,81 +296,330 @@ func (t *stiTar) CreateTarStreamReader(dir string, includeDirInPath bool) io.Rea
 // CreateTarStreamToTarWriter creates a tar stream on the given writer from
 // the given directory while excluding files that match the given
 // exclusion pattern.
+// The directory tree is walked in a separate goroutine while a pool of workers
+// opens (and, for small files, reads) the regular files ahead of the writer,
+// so that filesystem latency overlaps with writing the stream. Entries are
+// always written in the order in which they were walked.
 func (t *stiTar) CreateTarStreamToTarWriter(dir string, includeDirInPath bool, tarWriter Writer, logger io.Writer) error {
 	dir = filepath.Clean(dir) // remove relative paths and extraneous slashes
 	log.V(5).Infof("Adding %q to tar ...", dir)
-	err := t.Walk(dir, func(path string, info os.FileInfo, err error) error {
+
+	concurrency := t.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	entries := make(chan *tarEntry, concurrency*prefetchFactor)
+	jobs := make(chan *tarEntry, concurrency*prefetchFactor)
+	done := make(chan struct{})
+
+	var walkErr error
+	go func() {
+		defer close(entries)
+		defer close(jobs)
+		walkErr = t.walkTarEntries(dir, entries, jobs, done)
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for e := range jobs {
+				e.content <- prefetchFile(e)
+			}
+		}()
+	}
+
+	var err error
+	for e := range entries {
+		if err != nil {
+			// drain the remaining entries, releasing prefetched files
+			if e.content != nil {
+				(<-e.content).release()
+			}
+			continue
+		}
+		if err = t.writeTarEntry(tarWriter, dir, e, includeDirInPath, logger); err != nil {
+			close(done)
+		}
+	}
+	workers.Wait()
+
+	if err == nil {
+		err = walkErr
+	}
+	if err != nil {
+		log.Errorf("Error writing tar: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// walkTarEntries walks the given directory and queues every entry which is
+// not excluded. Regular files are additionally handed over to the prefetch
+// workers.
+func (t *stiTar) walkTarEntries(dir string, entries, jobs chan<- *tarEntry, done <-chan struct{}) error {
+	// hardlinks maps the identity of already queued regular files with more
+	// than one link to their path, so that further links to the same file are
+	// stored as hard links
+	hardlinks := map[fileID]string{}
+	return t.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		// on Windows, directory symlinks report as a directory and as a symlink.
-		// They should be treated as symlinks.
-		if !t.shouldExclude(path) {
-			// if file is a link just writing header info is enough
-			if info.Mode()&os.ModeSymlink != 0 {
-				if dir == path {
-					return nil
-				}
-				if err = t.writeTarHeader(tarWriter, dir, path, info, includeDirInPath, logger); err != nil {
-					log.Errorf("Error writing header for %q: %v", info.Name(), err)
-				}
-				// on Windows, filepath.Walk recurses into directory symlinks when it
-				// shouldn't.  https://github.com/golang/go/issues/17540
-				if err == nil && info.Mode()&os.ModeDir != 0 {
-					return filepath.SkipDir
-				}
-				return err
+		if t.shouldExclude(dir, path, info) {
+			return nil
+		}
+		e := &tarEntry{path: path, info: info}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if dir == path {
+				return nil
 			}
-			if info.IsDir() {
-				if dir == path {
-					return nil
-				}
-				if err = t.writeTarHeader(tarWriter, dir, path, info, includeDirInPath, logger); err != nil {
-					log.Errorf("Error writing header for %q: %v", info.Name(), err)
-				}
+			if err := t.applySymlinkPolicy(dir, e); err != nil {
 				return err
 			}
-
-			// regular files are copied into tar, if accessible
-			file, err := os.Open(path)
-			if err != nil {
-				log.Errorf("Ignoring file %s: %v", path, err)
-				return nil
+		}
+		// on Windows, directory symlinks report as a directory and as a symlink.
+		// They should be treated as symlinks.
+		if e.info.Mode()&os.ModeSymlink == 0 && !e.info.IsDir() {
+			if id, ok := getFileID(e.info); ok {
+				if first, seen := hardlinks[id]; seen {
+					e.hardlink = first
+				} else {
+					hardlinks[id] = path
+				}
 			}
-			defer file.Close()
-			if err = t.writeTarHeader(tarWriter, dir, path, info, includeDirInPath, logger); err != nil {
-				log.Errorf("Error writing header for %q: %v", info.Name(), err)
-				return err
+			if len(e.hardlink) == 0 {
+				e.content = make(chan tarContent, 1)
 			}
-			if _, err = io.Copy(tarWriter, file); err != nil {
-				log.Errorf("Error copying file %q to tar: %v", path, err)
-				return err
+		} else if dir == path {
+			return nil
+		}
+		select {
+		case entries <- e:
+		case <-done:
+			return errTarAborted
+		}
+		if e.content != nil {
+			select {
+			case jobs <- e:
+			case <-done:
+				// the entry is already queued, so it must be resolved
+				e.content <- tarContent{err: errTarAborted}
+				return errTarAborted
 			}
 		}
+		// on Windows, filepath.Walk recurses into directory symlinks when it
+		// shouldn't.  https://github.com/golang/go/issues/17540
+		if info.Mode()&os.ModeSymlink != 0 && info.Mode()&os.ModeDir != 0 {
+			return filepath.SkipDir
+		}
 		return nil
 	})
+}
 
+// applySymlinkPolicy reads the target of the symbolic link described by e and
+// applies the configured symlink policy to it.
+func (t *stiTar) applySymlinkPolicy(dir string, e *tarEntry) error {
+	link, err := os.Readlink(e.path)
 	if err != nil {
-		log.Errorf("Error writing tar: %v", err)
 		return err
 	}
+	e.link = link
+
+	target := link
+	if !filepath.IsAbs(target) {
+		target = filepath.Dir(e.path) + string(filepath.Separator) + target
+	}
+	target = resolvePath(target)
+	if isWithin(resolvePath(dir), target) {
+		if t.symlinkPolicy == api.SymlinkRewrite && filepath.IsAbs(link) {
+			if e.link, err = filepath.Rel(resolvePath(filepath.Dir(e.path)), target); err != nil {
+				return err
+			}
+			log.V(5).Infof("Rewriting symbolic link %s from %q to %q", e.path, link, e.link)
+		}
+		return nil
+	}
 
+	switch t.symlinkPolicy {
+	case api.SymlinkError:
+		return s2ierr.NewSymlinkPolicyError(e.path, link)
+	case api.SymlinkRewrite:
+		info, err := os.Stat(e.path)
+		if err != nil || !info.Mode().IsRegular() {
+			return s2ierr.NewSymlinkPolicyError(e.path, link)
+		}
+		log.V(5).Infof("Replacing symbolic link %s with the content of %q", e.path, link)
+		e.info = info
+		e.link = ""
+	}
 	return nil
 }
 
-// writeTarHeader writes tar header for given file, returns error if operation fails
-func (t *stiTar) writeTarHeader(tarWriter Writer, dir string, path string, info os.FileInfo, includeDirInPath bool, logger io.Writer) error {
-	var (
-		link string
-		err  error
-	)
-	if info.Mode()&os.ModeSymlink != 0 {
-		link, err = os.Readlink(path)
-		if err != nil {
+// writeTarEntry writes a single walked entry, including the content of
+// regular files, to the tar writer.
+func (t *stiTar) writeTarEntry(tarWriter Writer, dir string, e *tarEntry, includeDirInPath bool, logger io.Writer) error {
+	// if file is a link or a directory just writing header info is enough
+	if e.content == nil {
+		if err := t.writeTarHeader(tarWriter, dir, e, includeDirInPath, logger); err != nil {
+			log.Errorf("Error writing header for %q: %v", e.info.Name(), err)
 			return err
 		}
+		return nil
+	}
+
+	// regular files are copied into tar, if accessible
+	c := <-e.content
+	defer c.release()
+	if c.err != nil {
+		log.Errorf("Ignoring file %s: %v", e.path, c.err)
+		return nil
+	}
+	if c.readErr != nil {
+		log.Errorf("Error reading file %q: %v", e.path, c.readErr)
+		return c.readErr
+	}
+	if t.convertsCRLF(dir, e.path) {
+		var err error
+		if e.converted, err = convertCRLF(e, c); err != nil {
+			log.Errorf("Error converting the line endings of %q: %v", e.path, err)
+			return err
+		}
+	}
+	if err := t.writeTarHeader(tarWriter, dir, e, includeDirInPath, logger); err != nil {
+		log.Errorf("Error writing header for %q: %v", e.info.Name(), err)
+		return err
+	}
+	var err error
+	if e.converted != nil {
+		_, err = tarWriter.Write(e.converted)
+	} else if c.data != nil {
+		_, err = tarWriter.Write((*c.data)[:c.size])
+	} else {
+		buf := getCopyBuffer()
+		_, err = io.CopyBuffer(tarWriter, c.file, *buf)
+		putCopyBuffer(buf)
 	}
-	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		log.Errorf("Error copying file %q to tar: %v", e.path, err)
+		return err
+	}
+	return nil
+}
+
+const (
+	// prefetchFactor is the number of entries per worker which may be queued
+	// ahead of the tar writer.
+	prefetchFactor = 4
+
+	// smallFileSize is the maximum size of files which are read completely
+	// into memory by the prefetch workers. Larger files are only opened and
+	// are streamed into the tar by the writer.
+	smallFileSize = 64 * 1024
+
+	// copyBufferSize is the size of buffers used to copy file content.
+	copyBufferSize = 32 * 1024
+
+	// paxXattrPrefix is the prefix of PAX records holding extended attributes.
+	paxXattrPrefix = "SCHILY.xattr."
+
+	// paxSparsePrefix is the prefix of PAX records describing sparse files.
+	paxSparsePrefix = "GNU.sparse."
+)
+
+var (
+	smallFilePool = sync.Pool{New: func() interface{} {
+		b := make([]byte, smallFileSize)
+		return &b
+	}}
+	copyBufferPool = sync.Pool{New: func() interface{} {
+		b := make([]byte, copyBufferSize)
+		return &b
+	}}
+)
+
+func getCopyBuffer() *[]byte {
+	return copyBufferPool.Get().(*[]byte)
+}
+
+func putCopyBuffer(b *[]byte) {
+	copyBufferPool.Put(b)
+}
+
+// tarEntry is a walked file system entry waiting to be written to a tar
+// stream. content is only set for regular files and receives the result of
+// prefetching the file. link is the target of a symbolic link and hardlink the
+// path of a previously written file the entry is a hard link to. converted is
+// the content of a regular file whose line endings were converted, written
+// instead of the file.
+type tarEntry struct {
+	path      string
+	info      os.FileInfo
+	link      string
+	hardlink  string
+	content   chan tarContent
+	converted []byte
+}
+
+// tarContent is the prefetched content of a regular file. Either data holds
+// the complete content of a small file, or file is the opened large file. err
+// is set when the file could not be opened, and the file is then left out of
+// the tar, while readErr fails the tar.
+type tarContent struct {
+	data    *[]byte
+	size    int
+	file    *os.File
+	err     error
+	readErr error
+}
+
+// release returns the buffer to the pool and closes the file held by c.
+func (c tarContent) release() {
+	if c.data != nil {
+		smallFilePool.Put(c.data)
+	}
+	if c.file != nil {
+		c.file.Close()
+	}
+}
+
+// prefetchFile opens the file of the given entry, reading it into a pooled
+// buffer if it is small enough. The entry is updated with the information of
+// the opened file, whose size differs from the walked one when the file is
+// being written to.
+func prefetchFile(e *tarEntry) tarContent {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return tarContent{err: err}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return tarContent{err: err}
+	}
+	if info.Size() != e.info.Size() {
+		log.V(4).Infof("The size of %s changed from %d to %d bytes while it was added to the tar", e.path, e.info.Size(), info.Size())
+	}
+	e.info = info
+	if e.info.Size() > smallFileSize {
+		return tarContent{file: f}
+	}
+	defer f.Close()
+	buf := smallFilePool.Get().(*[]byte)
+	n, err := io.ReadFull(f, (*buf)[:e.info.Size()])
+	if err != nil {
+		smallFilePool.Put(buf)
+		return tarContent{readErr: fmt.Errorf("unable to read %d bytes of %s: %v", e.info.Size(), e.path, err)}
+	}
+	return tarContent{data: buf, size: n}
+}
+
+// writeTarHeader writes tar header for given file, returns error if operation fails
+func (t *stiTar) writeTarHeader(tarWriter Writer, dir string, e *tarEntry, includeDirInPath bool, logger io.Writer) error {
+	info := e.info
+	header, err := tar.FileInfoHeader(info, e.link)
 	if err != nil {
 		return err
 	}
@@ -317,26 +629,39 @@ func (t *stiTar) writeTarHeader(tarWriter Writer, dir string, path string, info
 		header.Typeflag = tar.TypeSymlink
 		header.Mode &^= 040000 // c_ISDIR
 		header.Mode |= 0120000 // c_ISLNK
-		header.Linkname = link
+		header.Linkname = e.link
 	}
-	prefix := dir
-	if includeDirInPath {
-		prefix = filepath.Dir(prefix)
+	if e.converted != nil {
+		header.Size = int64(len(e.converted))
 	}
-	fileName := path
-	if prefix != "." {
-		fileName = path[1+len(prefix):]
+	if len(e.hardlink) > 0 {
+		header.Typeflag = tar.TypeLink
+		header.Size = 0
+		header.Linkname = tarEntryName(dir, e.hardlink, includeDirInPath)
 	}
-	header.Name = filepath.ToSlash(fileName)
+	header.Name = tarEntryName(dir, e.path, includeDirInPath)
 	header.Linkname = filepath.ToSlash(header.Linkname)
 	// Force the header format to PAX to support UTF-8 filenames
 	// and use the same format throughout the entire tar file.
 	header.Format = tar.FormatPAX
 	logFile(logger, header.Name)
-	log.V(5).Infof("Adding to tar: %s as %s", path, header.Name)
+	log.V(5).Infof("Adding to tar: %s as %s", e.path, header.Name)
 	return tarWriter.WriteHeader(header)
 }
 
+// tarEntryName returns the name under which path is stored in a tar of dir.
+func tarEntryName(dir, path string, includeDirInPath bool) string {
+	prefix := dir
+	if includeDirInPath {
+		prefix = filepath.Dir(prefix)
+	}
+	fileName := path
+	if prefix != "." {
+		fileName = path[1+len(prefix):]
+	}
+	return filepath.ToSlash(fileName)
+}
+
 // ExtractTarStream calls ExtractTarStreamFromTarReader with a default reader and nil logger
 func (t *stiTar) ExtractTarStream(dir string, reader io.Reader) error {
 	tarReader := tar.NewReader(reader)
@@ -394,6 +719,7 @@ func (t *stiTar) ExtractTarStreamFromTarReader(dir string, tarReader Reader, log
 					return err
 				}
 				t.Chmod(dirPath, header.FileInfo().Mode())
+				t.extractXattrs(dirPath, header)
 			} else {
 				fileDir := filepath.Dir(header.Name)
 				dirPath := filepath.Join(dir, filepath.Clean(fileDir))
@@ -409,6 +735,13 @@ func (t *stiTar) ExtractTarStreamFromTarReader(dir string, tarReader Reader, log
 					}
 					continue
 				}
+				if header.Typeflag == tar.TypeLink {
+					if err := t.extractHardLink(dir, header); err != nil {
+						log.Errorf("Error extracting hard link %q: %v", header.Name, err)
+						return err
+					}
+					continue
+				}
 				logFile(logger, header.Name)
 				if err := t.extractFile(dir, header, tarReader); err != nil {
 					log.Errorf("Error extracting file %q: %v", header.Name, err)
@@ -436,8 +769,10 @@ func (t *stiTar) extractLink(dir string, header *tar.Header, tarReader io.Reader
 	source := header.Linkname
 
 	if t.disallowOutsidePaths {
-		target := filepath.Clean(filepath.Join(dest, "..", source))
-		if !strings.HasPrefix(target, dir) {
+		// the link is checked where it points to once created, through the
+		// symbolic links already extracted
+		target := resolvePath(filepath.Dir(dest) + string(filepath.Separator) + source)
+		if !isWithin(resolvePath(dir), target) {
 			log.Warningf("Skipping symlink that points to relative path: %s", header.Linkname)
 			return nil
 		}
@@ -456,6 +791,55 @@ func (t *stiTar) extractLink(dir string, header *tar.Header, tarReader io.Reader
 	return os.Symlink(source, dest)
 }
 
+func (t *stiTar) extractHardLink(dir string, header *tar.Header) error {
+	dest := filepath.Join(dir, header.Name)
+	source := filepath.Join(dir, header.Linkname)
+
+	if t.disallowOutsidePaths {
+		// the final symbolic link of the source is linked, not followed
+		target := filepath.Join(resolvePath(filepath.Dir(source)), filepath.Base(source))
+		if target == resolvePath(dir) || !isWithin(resolvePath(dir), target) {
+			log.Warningf("Skipping hard link that points to relative path: %s", header.Linkname)
+			return nil
+		}
+	}
+
+	if t.disallowOverwrite {
+		if _, err := os.Lstat(dest); !os.IsNotExist(err) {
+			log.Warningf("Refusing to overwrite existing file: %s", dest)
+			return nil
+		}
+	} else if dest != source {
+		// os.Link does not replace an existing file, as extractFile does
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	log.V(3).Infof("Creating hard link from %q to %q", dest, source)
+	return os.Link(source, dest)
+}
+
+// resolvePath returns the given path with its symbolic links resolved, as far
+// as its components exist. The components which do not exist are joined
+// lexically.
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(resolvePath(parent), filepath.Base(path))
+}
+
+// isWithin returns true if path is root or is in the root directory, both
+// with their symbolic links resolved.
+func isWithin(root, path string) bool {
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
 func (t *stiTar) extractFile(dir string, header *tar.Header, tarReader io.Reader) error {
 	path := filepath.Join(dir, header.Name)
 	if t.disallowOverwrite {
@@ -476,14 +860,96 @@ func (t *stiTar) extractFile(dir string, header *tar.Header, tarReader io.Reader
 	defer os.Chtimes(path, time.Now(), header.FileInfo().ModTime())
 	defer file.Close()
 	log.V(3).Infof("Extracting/writing %s", path)
-	written, err := io.Copy(file, tarReader)
+	var written int64
+	if isSparse(header) {
+		written, err = copySparse(file, tarReader)
+	} else {
+		written, err = io.Copy(file, tarReader)
+	}
 	if err != nil {
 		return err
 	}
 	if written != header.Size {
 		return fmt.Errorf("wrote %d bytes, expected to write %d", written, header.Size)
 	}
-	return t.Chmod(path, header.FileInfo().Mode())
+	if err = t.Chmod(path, header.FileInfo().Mode()); err != nil {
+		return err
+	}
+	// extended attributes are set last, as changing the mode of the file may
+	// clear some of them (e.g. file capabilities)
+	t.extractXattrs(path, header)
+	return nil
+}
+
+// extractXattrs sets the extended attributes recorded in the PAX records of
+// the given header on path. Failing to set an attribute is not fatal, as the
+// target file system or the current user may not support it. The paranoid
+// extractor only restores user attributes and file capabilities.
+func (t *stiTar) extractXattrs(path string, header *tar.Header) {
+	for key, value := range header.PAXRecords {
+		if !strings.HasPrefix(key, paxXattrPrefix) {
+			continue
+		}
+		name := key[len(paxXattrPrefix):]
+		if t.disallowSpecialFiles && !strings.HasPrefix(name, "user.") && name != "security.capability" {
+			log.V(3).Infof("Skipping extended attribute %s of %s", name, path)
+			continue
+		}
+		log.V(3).Infof("Setting extended attribute %s of %s", name, path)
+		if err := setXattr(path, name, value); err != nil {
+			log.Warningf("Unable to set extended attribute %s of %s: %v", name, path, err)
+		}
+	}
+}
+
+// isSparse returns true if the given header describes a sparse file.
+func isSparse(header *tar.Header) bool {
+	if header.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range header.PAXRecords {
+		if strings.HasPrefix(key, paxSparsePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// copySparse copies the content of r to file, seeking over blocks of zeros
+// instead of writing them so that holes are preserved in the extracted file.
+func copySparse(file *os.File, r io.Reader) (int64, error) {
+	buf := getCopyBuffer()
+	defer putCopyBuffer(buf)
+	var written int64
+	for {
+		n, err := r.Read(*buf)
+		if n > 0 {
+			if isZero((*buf)[:n]) {
+				if _, serr := file.Seek(int64(n), io.SeekCurrent); serr != nil {
+					return written, serr
+				}
+			} else if _, werr := file.Write((*buf)[:n]); werr != nil {
+				return written, werr
+			}
+			written += int64(n)
+		}
+		if err == io.EOF {
+			// a trailing hole is only recorded by the file size
+			return written, file.Truncate(written)
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 func logFile(logger io.Writer, name string) {
@@ -2,17 +2,23 @@ package tar
 
 import (
 	"archive/tar"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/openshift/source-to-image/pkg/api"
 	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+	"github.com/openshift/source-to-image/pkg/ignore"
 	"github.com/openshift/source-to-image/pkg/util/fs"
 )
 
@@ -652,3 +658,526 @@ func TestRoundTripTar(t *testing.T) {
 	}
 	verifyDirectory(t, destDir, testDirs, testFiles, testLinks)
 }
+
+func TestCreateTarStreamConcurrency(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "testtar")
+	defer os.RemoveAll(tempDir)
+	if err != nil {
+		t.Fatalf("Cannot create temp directory for test: %v", err)
+	}
+	modificationDate := time.Date(2011, time.March, 5, 23, 30, 1, 0, time.UTC)
+	testDirs := []dirDesc{
+		{"dir01", modificationDate, 0700},
+		{"dir01/dir02", modificationDate, 0755},
+	}
+	testFiles := []fileDesc{
+		{"dir01/empty.txt", modificationDate, 0600, "", false, ""},
+		{"dir01/large.bin", modificationDate, 0600, strings.Repeat("large file content", 2*smallFileSize/10), false, ""},
+	}
+	for i := 0; i < 50; i++ {
+		testFiles = append(testFiles, fileDesc{fmt.Sprintf("dir01/dir02/test%02d.txt", i), modificationDate, 0644, fmt.Sprintf("Test%d file content", i), false, ""})
+	}
+	if err = createTestFiles(tempDir, testDirs, testFiles, []linkDesc{}); err != nil {
+		t.Fatalf("Cannot create test files: %v", err)
+	}
+
+	var expected []string
+	var expectedTar []byte
+	for _, concurrency := range []int{1, 2, 8} {
+		th := New(fs.NewFileSystem()).(*stiTar)
+		th.concurrency = concurrency
+		buf := &bytes.Buffer{}
+		if err = th.CreateTarStream(tempDir, false, buf); err != nil {
+			t.Fatalf("Unable to create tar stream with concurrency %d: %v", concurrency, err)
+		}
+		entries := []string{}
+		tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Unable to read tar stream with concurrency %d: %v", concurrency, err)
+			}
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("Unable to read tar stream with concurrency %d: %v", concurrency, err)
+			}
+			entries = append(entries, fmt.Sprintf("%s %o %d %x", hdr.Name, hdr.Mode, hdr.Size, content))
+		}
+		if expected == nil {
+			expected, expectedTar = entries, buf.Bytes()
+			continue
+		}
+		if !reflect.DeepEqual(expected, entries) {
+			t.Errorf("Tar stream created with concurrency %d differs from the one created with concurrency 1", concurrency)
+		}
+	}
+
+	tarFile, err := ioutil.TempFile("", "testtarout")
+	if err != nil {
+		t.Fatalf("Unable to create temporary file %v", err)
+	}
+	defer os.Remove(tarFile.Name())
+	if _, err = tarFile.Write(expectedTar); err != nil {
+		t.Fatalf("Unable to write tar file %v", err)
+	}
+	tarFile.Close()
+	verifyTarFile(t, tarFile.Name(), testDirs, testFiles, []linkDesc{})
+}
+
+func BenchmarkCreateTarStream(b *testing.B) {
+	tempDir, err := ioutil.TempDir("", "testtar")
+	defer os.RemoveAll(tempDir)
+	if err != nil {
+		b.Fatalf("Cannot create temp directory for test: %v", err)
+	}
+	modificationDate := time.Date(2011, time.March, 5, 23, 30, 1, 0, time.UTC)
+	testDirs := []dirDesc{}
+	for i := 0; i < 10; i++ {
+		testDirs = append(testDirs, dirDesc{fmt.Sprintf("dir%02d", i), modificationDate, 0755})
+	}
+	testFiles := []fileDesc{}
+	for i := 0; i < 500; i++ {
+		testFiles = append(testFiles, fileDesc{fmt.Sprintf("dir%02d/test%03d.txt", i%10, i), modificationDate, 0644, strings.Repeat("x", 1024*(i%100)), false, ""})
+	}
+	if err = createTestFiles(tempDir, testDirs, testFiles, []linkDesc{}); err != nil {
+		b.Fatalf("Cannot create test files: %v", err)
+	}
+
+	for _, concurrency := range []int{1, defaultConcurrency} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			th := New(fs.NewFileSystem()).(*stiTar)
+			th.concurrency = concurrency
+			for i := 0; i < b.N; i++ {
+				if err := th.CreateTarStream(tempDir, false, ioutil.Discard); err != nil {
+					b.Fatalf("Unable to create tar stream: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateTarStreamSymlinkPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not supported by this test on Windows")
+	}
+	outsideDir, err := ioutil.TempDir("", "testtaroutside")
+	defer os.RemoveAll(outsideDir)
+	if err != nil {
+		t.Fatalf("Cannot create temp directory for test: %v", err)
+	}
+	outsideFile := filepath.Join(outsideDir, "outside.txt")
+	if err = ioutil.WriteFile(outsideFile, []byte("outside content"), 0644); err != nil {
+		t.Fatalf("Cannot create test file: %v", err)
+	}
+
+	tests := map[string]struct {
+		policy      api.SymlinkPolicy
+		links       []linkDesc
+		expected    map[string]string
+		expectError bool
+	}{
+		"preserve outside link": {
+			policy:   api.SymlinkPreserve,
+			links:    []linkDesc{{"link", outsideFile}},
+			expected: map[string]string{"link": "-> " + outsideFile},
+		},
+		"error on outside link": {
+			policy:      api.SymlinkError,
+			links:       []linkDesc{{"link", outsideFile}},
+			expectError: true,
+		},
+		"error keeps inside link": {
+			policy:   api.SymlinkError,
+			links:    []linkDesc{{"link", "file.txt"}},
+			expected: map[string]string{"link": "-> file.txt"},
+		},
+		"error keeps inside link through a link": {
+			policy:   api.SymlinkError,
+			links:    []linkDesc{{"a/b/link", "file.txt"}, {"ab", "a/b"}, {"link", "ab/../../file.txt"}},
+			expected: map[string]string{"link": "-> ab/../../file.txt"},
+		},
+		"rewrite outside link": {
+			policy:   api.SymlinkRewrite,
+			links:    []linkDesc{{"link", outsideFile}},
+			expected: map[string]string{"link": "outside content"},
+		},
+		"rewrite absolute inside link": {
+			policy:   api.SymlinkRewrite,
+			links:    []linkDesc{{"dir/link", "@/file.txt"}},
+			expected: map[string]string{"dir/link": "-> ../file.txt"},
+		},
+		"rewrite dangling outside link": {
+			policy:      api.SymlinkRewrite,
+			links:       []linkDesc{{"link", filepath.Join(outsideDir, "missing")}},
+			expectError: true,
+		},
+	}
+
+	for desc, tc := range tests {
+		tempDir, err := ioutil.TempDir("", "testtar")
+		if err != nil {
+			t.Fatalf("Cannot create temp directory for test: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+		for i := range tc.links {
+			tc.links[i].fileName = strings.Replace(tc.links[i].fileName, "@", tempDir, 1)
+		}
+		testFiles := []fileDesc{{"file.txt", time.Now(), 0644, "inside content", false, ""}}
+		if err = createTestFiles(tempDir, []dirDesc{}, testFiles, tc.links); err != nil {
+			t.Fatalf("Cannot create test files: %v", err)
+		}
+
+		th := New(fs.NewFileSystem())
+		th.SetSymlinkPolicy(tc.policy)
+		buf := &bytes.Buffer{}
+		err = th.CreateTarStream(tempDir, false, buf)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("%s: expected an error", desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+			continue
+		}
+		tr := tar.NewReader(buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: unable to read tar stream: %v", desc, err)
+			}
+			expected, ok := tc.expected[hdr.Name]
+			if !ok {
+				continue
+			}
+			actual := "-> " + hdr.Linkname
+			if hdr.Typeflag != tar.TypeSymlink {
+				content, _ := ioutil.ReadAll(tr)
+				actual = string(content)
+			}
+			if actual != expected {
+				t.Errorf("%s: expected %s to be %q, got %q", desc, hdr.Name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestExtractSymlinkThroughSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not supported by this test on Windows")
+	}
+	destDir := filepath.Join(t.TempDir(), "dest")
+	if err := os.Mkdir(destDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "self", Linkname: ".", Typeflag: tar.TypeSymlink},
+		{Name: "self/parent", Linkname: "..", Typeflag: tar.TypeSymlink},
+		{Name: "self/self/file", Linkname: "../file.txt", Typeflag: tar.TypeLink},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+
+	if err := NewParanoid(fs.NewFileSystem()).ExtractTarStream(destDir, buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(destDir, "self")); err != nil {
+		t.Errorf("Expected the link to the extracted directory to be created: %v", err)
+	}
+	for _, name := range []string{"parent", "file"} {
+		if _, err := os.Lstat(filepath.Join(destDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected the link %s pointing outside of the extracted directory through another link to be skipped: %v", name, err)
+		}
+	}
+}
+
+func TestRoundTripTarHardlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not preserved on Windows")
+	}
+	tempDir, err := ioutil.TempDir("", "testtar")
+	defer os.RemoveAll(tempDir)
+	if err != nil {
+		t.Fatalf("Cannot create temp input directory for test: %v", err)
+	}
+	destDir, err := ioutil.TempDir("", "testExtract")
+	defer os.RemoveAll(destDir)
+	if err != nil {
+		t.Fatalf("Cannot create temp extract directory for test: %v", err)
+	}
+	testFiles := []fileDesc{{"dir01/file.txt", time.Now(), 0644, "hard linked content", false, ""}}
+	if err = createTestFiles(tempDir, []dirDesc{{"dir01", time.Now(), 0755}}, testFiles, []linkDesc{}); err != nil {
+		t.Fatalf("Cannot create test files: %v", err)
+	}
+	if err = os.Link(filepath.Join(tempDir, "dir01", "file.txt"), filepath.Join(tempDir, "dir01", "hardlink.txt")); err != nil {
+		t.Fatalf("Cannot create hard link: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = New(fs.NewFileSystem()).CreateTarStream(tempDir, false, buf); err != nil {
+		t.Fatalf("Unable to create tar stream: %v", err)
+	}
+	if err = NewParanoid(fs.NewFileSystem()).ExtractTarStream(destDir, buf); err != nil {
+		t.Fatalf("Unable to extract tar stream: %v", err)
+	}
+	first, err := os.Stat(filepath.Join(destDir, "dir01", "file.txt"))
+	if err != nil {
+		t.Fatalf("Unable to stat extracted file: %v", err)
+	}
+	second, err := os.Stat(filepath.Join(destDir, "dir01", "hardlink.txt"))
+	if err != nil {
+		t.Fatalf("Unable to stat extracted hard link: %v", err)
+	}
+	if !os.SameFile(first, second) {
+		t.Errorf("Expected %s and %s to be the same file", first.Name(), second.Name())
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(destDir, "dir01", "hardlink.txt")); string(content) != "hard linked content" {
+		t.Errorf("Unexpected content of hard link: %q", content)
+	}
+}
+
+func TestExtractHardLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not preserved on Windows")
+	}
+	tests := map[string]struct {
+		tar      Tar
+		linkname string
+		existing bool
+		linked   bool
+	}{
+		"replaces an existing file": {
+			tar:      New(fs.NewFileSystem()),
+			linkname: "file.txt",
+			existing: true,
+			linked:   true,
+		},
+		"outside of a sibling directory": {
+			tar:      NewParanoid(fs.NewFileSystem()),
+			linkname: "../dest-sibling/file.txt",
+		},
+	}
+	for desc, tc := range tests {
+		parent := t.TempDir()
+		destDir := filepath.Join(parent, "dest")
+		for _, dir := range []string{destDir, filepath.Join(parent, "dest-sibling")} {
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("content"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tc.existing {
+			if err := ioutil.WriteFile(filepath.Join(destDir, "link.txt"), []byte("existing"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		if err := tw.WriteHeader(&tar.Header{Name: "link.txt", Linkname: tc.linkname, Typeflag: tar.TypeLink}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Close()
+
+		if err := tc.tar.ExtractTarStream(destDir, buf); err != nil {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+			continue
+		}
+		file, err := os.Stat(filepath.Join(destDir, tc.linkname))
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, err := os.Stat(filepath.Join(destDir, "link.txt"))
+		if (err == nil && os.SameFile(link, file)) != tc.linked {
+			t.Errorf("%s: expected the hard link to be extracted: %v", desc, tc.linked)
+		}
+	}
+}
+
+func TestCopySparse(t *testing.T) {
+	file, err := ioutil.TempFile("", "testsparse")
+	if err != nil {
+		t.Fatalf("Unable to create temporary file %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	content := append([]byte("head"), make([]byte, 4*copyBufferSize)...)
+	content = append(content, []byte("tail")...)
+	content = append(content, make([]byte, 2*copyBufferSize)...)
+	written, err := copySparse(file, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if written != int64(len(content)) {
+		t.Errorf("Expected to write %d bytes, wrote %d", len(content), written)
+	}
+	actual, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("Unable to read file: %v", err)
+	}
+	if !bytes.Equal(content, actual) {
+		t.Errorf("Content of the sparse file differs from the original")
+	}
+}
+
+func TestCreateTarStreamExclusionGlobs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "testtar")
+	defer os.RemoveAll(tempDir)
+	if err != nil {
+		t.Fatalf("Cannot create temp directory for test: %v", err)
+	}
+	modificationDate := time.Date(2011, time.March, 5, 23, 30, 1, 0, time.UTC)
+	testDirs := []dirDesc{
+		{"build", modificationDate, 0755},
+		{"src", modificationDate, 0755},
+	}
+	testFiles := []fileDesc{
+		{"build/out.bin", modificationDate, 0644, "binary", false, ""},
+		{"build/keep.txt", modificationDate, 0644, "kept", false, ""},
+		{"src/main.go", modificationDate, 0644, "package main", false, ""},
+		{"src/debug.log", modificationDate, 0644, "log", false, ""},
+	}
+	if err = createTestFiles(tempDir, testDirs, testFiles, []linkDesc{}); err != nil {
+		t.Fatalf("Cannot create test files: %v", err)
+	}
+	globs, err := ignore.NewGlobMatcher([]string{"/build/", "*.log"}, []string{"build/keep.txt"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	th := New(fs.NewFileSystem())
+	th.SetExclusionGlobs(globs)
+	buf := &bytes.Buffer{}
+	if err = th.CreateTarStream(tempDir, false, buf); err != nil {
+		t.Fatalf("Unable to create tar stream: %v", err)
+	}
+	names := []string{}
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unable to read tar stream: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	expected := []string{"build/keep.txt", "src", "src/main.go"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("Expected tar entries %v, got %v", expected, names)
+	}
+}
+
+func TestCreateTarStreamCRLFConversion(t *testing.T) {
+	large := strings.Repeat("line\r\n", smallFileSize/4)
+	files := map[string]string{
+		"scripts/assemble":  "#!/bin/sh\r\necho assemble\r\n",
+		"src/.s2i/bin/run":  "#!/bin/sh\r\nexec app\r\n",
+		"src/app.txt":       "text\r\n",
+		"src/data.bin":      "\x00binary\r\n",
+		"src/large.txt":     large,
+		"src/scripts/setup": "setup\r\n",
+	}
+	tests := map[api.CRLFConversion][]string{
+		api.CRLFConversionOff:     {},
+		api.CRLFConversionScripts: {"scripts/assemble", "src/.s2i/bin/run"},
+		api.CRLFConversionAll:     {"scripts/assemble", "src/.s2i/bin/run", "src/app.txt", "src/large.txt", "src/scripts/setup"},
+	}
+	tempDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for conversion, converted := range tests {
+		th := New(fs.NewFileSystem())
+		th.SetCRLFConversion(conversion, "scripts", filepath.Join("src", ".s2i", "bin"))
+		buf := &bytes.Buffer{}
+		if err := th.CreateTarStream(tempDir, false, buf); err != nil {
+			t.Fatalf("%s: unable to create tar stream: %v", conversion, err)
+		}
+		actual := []string{}
+		tr := tar.NewReader(buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: unable to read tar stream: %v", conversion, err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("%s: unable to read %s: %v", conversion, hdr.Name, err)
+			}
+			if string(content) == files[hdr.Name] {
+				continue
+			}
+			if string(content) != strings.ReplaceAll(files[hdr.Name], "\r\n", "\n") {
+				t.Errorf("%s: unexpected content of %s: %q", conversion, hdr.Name, content)
+			}
+			actual = append(actual, hdr.Name)
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, converted) {
+			t.Errorf("%s: expected the files %v to be converted, got %v", conversion, converted, actual)
+		}
+	}
+
+	// the scripts directory of the sources is not converted when the sources
+	// are archived
+	th := New(fs.NewFileSystem()).(*stiTar)
+	th.SetCRLFConversion(api.CRLFConversionScripts, filepath.Join(".s2i", "bin"))
+	src := filepath.Join(tempDir, "src")
+	if !th.convertsCRLF(src, filepath.Join(src, ".s2i", "bin", "run")) {
+		t.Errorf("expected the scripts of the sources to be converted")
+	}
+	if th.convertsCRLF(src, filepath.Join(src, "scripts", "setup")) {
+		t.Errorf("expected the scripts directory of the sources not to be converted")
+	}
+}
+
+func TestPrefetchFileChangedSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	if err := ioutil.WriteFile(path, []byte("first line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := &tarEntry{path: path, info: info}
+	c := prefetchFile(e)
+	defer c.release()
+	if c.err != nil || c.readErr != nil {
+		t.Fatalf("Unexpected error: %v, %v", c.err, c.readErr)
+	}
+	if e.info.Size() != 5 || string((*c.data)[:c.size]) != "line\n" {
+		t.Errorf("Expected the current content of the file, got %q and size %d", (*c.data)[:c.size], e.info.Size())
+	}
+}
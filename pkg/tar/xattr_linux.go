@@ -0,0 +1,9 @@
+package tar
+
+import "golang.org/x/sys/unix"
+
+// setXattr sets the extended attribute name of path, without following
+// symbolic links.
+func setXattr(path, name, value string) error {
+	return unix.Lsetxattr(path, name, []byte(value), 0)
+}
@@ -0,0 +1,60 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/openshift/source-to-image/pkg/util/fs"
+)
+
+func TestExtractTarStreamXattrs(t *testing.T) {
+	destDir, err := ioutil.TempDir("", "testExtract")
+	defer os.RemoveAll(destDir)
+	if err != nil {
+		t.Fatalf("Cannot create temp extract directory for test: %v", err)
+	}
+	if err = setXattr(destDir, "user.s2i.probe", "1"); err != nil {
+		t.Skipf("extended attributes are not supported by the file system: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	content := "binary"
+	if err = tw.WriteHeader(&tar.Header{
+		Name:     "bin/server",
+		Mode:     0755,
+		Size:     int64(len(content)),
+		Typeflag: tar.TypeReg,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.user.s2i.test":     "value",
+			"SCHILY.xattr.trusted.s2i.test":  "value",
+			"SCHILY.xattr.user.s2i.notxattr": "other",
+		},
+	}); err != nil {
+		t.Fatalf("Unable to write tar header: %v", err)
+	}
+	tw.Write([]byte(content))
+	tw.Close()
+
+	if err = NewParanoid(fs.NewFileSystem()).ExtractTarStream(destDir, buf); err != nil {
+		t.Fatalf("Unable to extract tar stream: %v", err)
+	}
+	path := filepath.Join(destDir, "bin", "server")
+	value := make([]byte, 64)
+	n, err := unix.Lgetxattr(path, "user.s2i.test", value)
+	if err != nil {
+		t.Fatalf("Extended attribute user.s2i.test was not restored: %v", err)
+	}
+	if string(value[:n]) != "value" {
+		t.Errorf("Unexpected value of extended attribute user.s2i.test: %q", value[:n])
+	}
+	if _, err = unix.Lgetxattr(path, "trusted.s2i.test", value); err == nil {
+		t.Errorf("Expected extended attribute trusted.s2i.test to be skipped by the paranoid extractor")
+	}
+}
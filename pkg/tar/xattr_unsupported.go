@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tar
+
+import "errors"
+
+// setXattr is not supported on this platform.
+func setXattr(path, name, value string) error {
+	return errors.New("extended attributes are not supported on this platform")
+}
@@ -1,19 +1,24 @@
 package test
 
+import "github.com/openshift/source-to-image/pkg/api"
+
 // FakeCallbackInvoker provides the fake callback invoker
 type FakeCallbackInvoker struct {
 	CallbackURL string
+	Events      []string
 	Success     bool
 	Messages    []string
 	Labels      map[string]string
 	Result      []string
+	Status      *api.CallbackStatus
 }
 
 // ExecuteCallback executes the fake callback
-func (f *FakeCallbackInvoker) ExecuteCallback(callbackURL string, success bool, labels map[string]string, messages []string) []string {
+func (f *FakeCallbackInvoker) ExecuteCallback(callbackURL, event string, success bool, labels map[string]string, messages []string) ([]string, *api.CallbackStatus) {
 	f.CallbackURL = callbackURL
+	f.Events = append(f.Events, event)
 	f.Success = success
 	f.Labels = labels
 	f.Messages = messages
-	return f.Result
+	return f.Result, f.Status
 }
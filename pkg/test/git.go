@@ -23,6 +23,14 @@ type FakeGit struct {
 	SubmoduleUpdateInit      bool
 	SubmoduleUpdateRecursive bool
 	SubmoduleUpdateError     error
+
+	FetchRepo  string
+	FetchRef   string
+	FetchError error
+
+	VerifySignatureRef    string
+	VerifySignatureResult string
+	VerifySignatureError  error
 }
 
 // Clone clones the fake source Git repository to target directory
@@ -68,3 +76,16 @@ func (f *FakeGit) GetInfo(repo string) *git.SourceInfo {
 		Location: "file:///foo",
 	}
 }
+
+// Fetch fetches a ref in the fake Git repository
+func (f *FakeGit) Fetch(repo, ref string) error {
+	f.FetchRepo = repo
+	f.FetchRef = ref
+	return f.FetchError
+}
+
+// VerifySignature verifies the signature of a ref in the fake Git repository
+func (f *FakeGit) VerifySignature(repo, ref, keyring string) (string, error) {
+	f.VerifySignatureRef = ref
+	return f.VerifySignatureResult, f.VerifySignatureError
+}
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"sync"
 
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/ignore"
 	"github.com/openshift/source-to-image/pkg/tar"
 )
 
@@ -72,6 +74,18 @@ func (f *FakeTar) ExtractTarStream(dir string, reader io.Reader) error {
 func (f *FakeTar) SetExclusionPattern(*regexp.Regexp) {
 }
 
+// SetExclusionGlobs sets the exclusion globs
+func (f *FakeTar) SetExclusionGlobs(*ignore.GlobMatcher) {
+}
+
+// SetSymlinkPolicy sets the symlink policy
+func (f *FakeTar) SetSymlinkPolicy(api.SymlinkPolicy) {
+}
+
+// SetCRLFConversion sets the CRLF conversion
+func (f *FakeTar) SetCRLFConversion(api.CRLFConversion, ...string) {
+}
+
 // CreateTarStreamToTarWriter creates a tar from the given directory and streams
 // it to the given writer.
 func (f *FakeTar) CreateTarStreamToTarWriter(dir string, includeDirInPath bool, writer tar.Writer, logger io.Writer) error {
@@ -1,70 +1,202 @@
 package util
 
 import (
-	"bufio"
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
 )
 
-// CallbackInvoker posts results to a callback URL when a STI build is done.
+// callbackBackoff is the time waited before the first retry of the callback,
+// doubled before each of the following ones.
+const callbackBackoff = time.Second
+
+// CallbackInvoker posts the lifecycle events of a build, and its result once
+// it is done, to a callback URL.
 type CallbackInvoker interface {
-	ExecuteCallback(callbackURL string, success bool, labels map[string]string, messages []string) []string
+	ExecuteCallback(callbackURL, event string, success bool, labels map[string]string, messages []string) ([]string, *api.CallbackStatus)
+}
+
+// FireCallbacks posts the given lifecycle event of a build to the callback
+// URLs of its configuration, when they are called upon the event, and records
+// the delivery of the callbacks in the result.
+func FireCallbacks(invoker CallbackInvoker, config *api.Config, event string, result *api.Result, labels map[string]string) {
+	if event != api.CallbackEventCompleted && !Includes(config.CallbackEvents, event) {
+		return
+	}
+	for _, callbackURL := range config.CallbackURLs {
+		var status *api.CallbackStatus
+		result.Messages, status = invoker.ExecuteCallback(callbackURL, event, result.Success, labels, result.Messages)
+		if status != nil {
+			status.URL, status.Event = redactedURL(callbackURL), event
+			result.Callbacks = append(result.Callbacks, *status)
+		}
+	}
 }
 
-// NewCallbackInvoker creates an instance of the default CallbackInvoker implementation
-func NewCallbackInvoker() CallbackInvoker {
-	invoker := &callbackInvoker{}
-	invoker.postFunc = invoker.httpPost
-	return invoker
+// NewCallbackInvoker creates an instance of the default CallbackInvoker
+// implementation, calling the callback URL with the retries, timeout, headers
+// and certificates of the given configuration.
+func NewCallbackInvoker(config *api.Config) (CallbackInvoker, error) {
+	header, err := parseCallbackHeaders(config.CallbackHeaders)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := callbackTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport, Timeout: config.CallbackTimeout}
+	return &callbackInvoker{
+		postFunc: client.Do,
+		sleep:    time.Sleep,
+		retries:  config.CallbackRetries,
+		header:   header,
+	}, nil
 }
 
 type callbackInvoker struct {
-	postFunc func(url, contentType string, body io.Reader) (resp *http.Response, err error)
+	postFunc func(req *http.Request) (*http.Response, error)
+	sleep    func(time.Duration)
+	retries  int
+	header   http.Header
 }
 
-// ExecuteCallback prepares a JSON payload and posts it to the specified callback URL
-func (c *callbackInvoker) ExecuteCallback(callbackURL string, success bool, labels map[string]string, messages []string) []string {
-	buf := new(bytes.Buffer)
-	writer := bufio.NewWriter(buf)
-
+// ExecuteCallback prepares a JSON payload of the event and posts it to the
+// specified callback URL, retrying with an exponential backoff when the URL
+// cannot be reached or answers with a server error. The success of the build
+// is only part of the payload of its completion. It returns the messages, with
+// the reason the callback failed, and the delivery status of the callback.
+func (c *callbackInvoker) ExecuteCallback(callbackURL, event string, success bool, labels map[string]string, messages []string) ([]string, *api.CallbackStatus) {
 	data := map[string]interface{}{
-		"success": success,
+		"event": event,
+	}
+
+	if event == api.CallbackEventCompleted {
+		data["success"] = success
 	}
 
 	if len(labels) > 0 {
 		data["labels"] = labels
 	}
 
-	jsonBuffer := new(bytes.Buffer)
-	writer = bufio.NewWriter(jsonBuffer)
-	jsonWriter := json.NewEncoder(writer)
-	jsonWriter.Encode(data)
-	writer.Flush()
+	body, err := json.Marshal(data)
+	if err != nil {
+		messages = append(messages, fmt.Sprintf("Unable to invoke callback: %v", err))
+		return messages, &api.CallbackStatus{Error: err.Error()}
+	}
 
-	var (
-		resp *http.Response
-		err  error
-	)
-	for retries := 0; retries < 3; retries++ {
-		resp, err = c.postFunc(callbackURL, "application/json", jsonBuffer)
-		if err != nil {
-			errorMessage := fmt.Sprintf("Unable to invoke callback: %v", err)
-			messages = append(messages, errorMessage)
-		}
-		if resp != nil {
-			if resp.StatusCode >= 300 {
-				errorMessage := fmt.Sprintf("Callback returned with error code: %d", resp.StatusCode)
-				messages = append(messages, errorMessage)
-			}
+	status := &api.CallbackStatus{}
+	backoff := callbackBackoff
+	for {
+		status.Attempts++
+		retry := c.post(callbackURL, body, status)
+		if !retry || status.Attempts > c.retries {
 			break
 		}
+		log.V(1).Infof("Retrying the callback of the %s event in %v: %s", event, backoff, status.Error)
+		c.sleep(backoff)
+		backoff *= 2
+	}
+	if !status.Delivered {
+		if status.StatusCode > 0 {
+			messages = append(messages, fmt.Sprintf("Callback %s of the %s event returned with error code: %d", redactedURL(callbackURL), event, status.StatusCode))
+		} else {
+			messages = append(messages, fmt.Sprintf("Unable to invoke callback %s of the %s event: %s", redactedURL(callbackURL), event, status.Error))
+		}
+	}
+	return messages, status
+}
+
+// post makes one attempt to post the body to the callback URL, recording its
+// outcome in the status, and returns whether the attempt may be retried: the
+// URL could not be reached, or answered with a server error or 429.
+func (c *callbackInvoker) post(callbackURL string, body []byte, status *api.CallbackStatus) bool {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		status.StatusCode, status.Error = 0, err.Error()
+		return false
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.postFunc(req)
+	if err != nil {
+		status.StatusCode, status.Error = 0, err.Error()
+		return true
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	status.StatusCode = resp.StatusCode
+	if resp.StatusCode < 300 {
+		status.Delivered, status.Error = true, ""
+		return false
+	}
+	status.Error = fmt.Sprintf("the callback URL answered %s", resp.Status)
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// redactedURL returns the URL without its password.
+func redactedURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	return u.Redacted()
+}
+
+// parseCallbackHeaders parses the headers of the callback requests, given in
+// the "Name: value" form.
+func parseCallbackHeaders(headers []string) (http.Header, error) {
+	header := http.Header{}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("invalid callback header %q, expected the Name: value form", h)
+		}
+		header.Add(name, strings.TrimSpace(value))
 	}
-	return messages
+	return header, nil
 }
 
-func (*callbackInvoker) httpPost(url, contentType string, body io.Reader) (resp *http.Response, err error) {
-	return http.Post(url, contentType, body)
+// callbackTLSConfig returns the TLS configuration of the callback requests,
+// with the client certificate and certificate authority of the given
+// configuration, or nil when there are none.
+func callbackTLSConfig(config *api.Config) (*tls.Config, error) {
+	if len(config.CallbackCertFile) == 0 && len(config.CallbackCAFile) == 0 {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(config.CallbackCertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(config.CallbackCertFile, config.CallbackKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the callback client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if len(config.CallbackCAFile) > 0 {
+		pem, err := ioutil.ReadFile(config.CallbackCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the callback certificate authority: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the callback certificate authority %s", config.CallbackCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
 }
@@ -1,11 +1,27 @@
 package util
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
-	"io"
+	"encoding/pem"
+	"fmt"
 	"io/ioutil"
+	stdlog "log"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
 )
 
 type FakePost struct {
@@ -15,14 +31,14 @@ type FakePost struct {
 	url      string
 }
 
-func (f *FakePost) post(url, contentType string, body io.Reader) (resp *http.Response, err error) {
-	f.url = url
-	f.body, _ = ioutil.ReadAll(body)
+func (f *FakePost) post(req *http.Request) (resp *http.Response, err error) {
+	f.url = req.URL.String()
+	f.body, _ = ioutil.ReadAll(req.Body)
 	return &f.response, f.err
 }
 
 func TestExecuteCallback(t *testing.T) {
-	fp := FakePost{}
+	fp := FakePost{response: http.Response{StatusCode: http.StatusOK, Body: http.NoBody}}
 	cb := callbackInvoker{
 		postFunc: fp.post,
 	}
@@ -30,7 +46,7 @@ func TestExecuteCallback(t *testing.T) {
 	labels := map[string]string{
 		"foo": "bar",
 	}
-	cb.ExecuteCallback("http://the.callback.url/test", true, labels, []string{"msg1", "msg2"})
+	cb.ExecuteCallback("http://the.callback.url/test", api.CallbackEventCompleted, true, labels, []string{"msg1", "msg2"})
 
 	type postBody struct {
 		Labels  map[string]string
@@ -48,3 +64,232 @@ func TestExecuteCallback(t *testing.T) {
 		t.Errorf("Unexpected success flag: %v", pb.Success)
 	}
 }
+
+func TestExecuteCallbackRetries(t *testing.T) {
+	tests := map[string]struct {
+		codes    []int
+		retries  int
+		expected api.CallbackStatus
+		sleeps   []time.Duration
+		messages int
+	}{
+		"delivered": {
+			codes:    []int{http.StatusOK},
+			retries:  2,
+			expected: api.CallbackStatus{Delivered: true, Attempts: 1, StatusCode: http.StatusOK},
+		},
+		"delivered after server errors": {
+			codes:    []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			retries:  2,
+			expected: api.CallbackStatus{Delivered: true, Attempts: 3, StatusCode: http.StatusNoContent},
+			sleeps:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		"retries exhausted": {
+			codes:    []int{http.StatusBadGateway, http.StatusBadGateway},
+			retries:  1,
+			expected: api.CallbackStatus{Attempts: 2, StatusCode: http.StatusBadGateway, Error: "the callback URL answered 502 Bad Gateway"},
+			sleeps:   []time.Duration{time.Second},
+			messages: 1,
+		},
+		"client error": {
+			codes:    []int{http.StatusUnauthorized},
+			retries:  2,
+			expected: api.CallbackStatus{Attempts: 1, StatusCode: http.StatusUnauthorized, Error: "the callback URL answered 401 Unauthorized"},
+			messages: 1,
+		},
+	}
+	for desc, tc := range tests {
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			w.WriteHeader(tc.codes[len(bodies)-1])
+		}))
+		invoker, err := NewCallbackInvoker(&api.Config{CallbackRetries: tc.retries})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", desc, err)
+		}
+		var sleeps []time.Duration
+		invoker.(*callbackInvoker).sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+		messages, status := invoker.ExecuteCallback(server.URL, api.CallbackEventCompleted, true, nil, nil)
+		server.Close()
+
+		if !reflect.DeepEqual(*status, tc.expected) {
+			t.Errorf("%s: expected the status %+v, got %+v", desc, tc.expected, *status)
+		}
+		if !reflect.DeepEqual(sleeps, tc.sleeps) {
+			t.Errorf("%s: expected the backoffs %v, got %v", desc, tc.sleeps, sleeps)
+		}
+		if len(messages) != tc.messages {
+			t.Errorf("%s: expected %d messages, got %v", desc, tc.messages, messages)
+		}
+		for i, body := range bodies {
+			if body != `{"event":"completed","success":true}` {
+				t.Errorf("%s: unexpected body of attempt %d: %q", desc, i+1, body)
+			}
+		}
+	}
+}
+
+func TestFireCallbacks(t *testing.T) {
+	var events []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		events = append(events, fmt.Sprintf("%s %s %v", r.URL.Path, payload["event"], payload["success"]))
+	}))
+	defer server.Close()
+	config := &api.Config{
+		CallbackURLs:   []string{server.URL + "/ci", strings.Replace(server.URL, "http://", "http://hook:s3cr3t@", 1) + "/chat"},
+		CallbackEvents: []string{api.CallbackEventStarted},
+	}
+	invoker, err := NewCallbackInvoker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &api.Result{}
+	FireCallbacks(invoker, config, api.CallbackEventStarted, result, nil)
+	FireCallbacks(invoker, config, api.CallbackEventAssembled, result, nil)
+	result.Success = true
+	FireCallbacks(invoker, config, api.CallbackEventCompleted, result, nil)
+
+	expected := []string{"/ci started <nil>", "/chat started <nil>", "/ci completed true", "/chat completed true"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected the events %v, got %v", expected, events)
+	}
+	if len(result.Callbacks) != 4 {
+		t.Fatalf("expected the delivery of 4 callbacks, got %+v", result.Callbacks)
+	}
+	if status := result.Callbacks[3]; !status.Delivered || status.Event != api.CallbackEventCompleted || strings.Contains(status.URL, "s3cr3t") {
+		t.Errorf("expected the delivery of the completion with a redacted URL, got %+v", status)
+	}
+}
+
+func TestExecuteCallbackUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	invoker, err := NewCallbackInvoker(&api.Config{CallbackRetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoker.(*callbackInvoker).sleep = func(time.Duration) {}
+	messages, status := invoker.ExecuteCallback(server.URL, api.CallbackEventCompleted, false, nil, nil)
+	if status.Delivered || status.Attempts != 2 || status.StatusCode != 0 || len(status.Error) == 0 {
+		t.Errorf("expected two failed attempts, got %+v", *status)
+	}
+	if len(messages) != 1 {
+		t.Errorf("expected a message, got %v", messages)
+	}
+}
+
+func TestExecuteCallbackTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	invoker, err := NewCallbackInvoker(&api.Config{CallbackTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, status := invoker.ExecuteCallback(server.URL, api.CallbackEventCompleted, true, nil, nil)
+	if status.Delivered || status.Attempts != 1 {
+		t.Errorf("expected the attempt to time out, got %+v", *status)
+	}
+}
+
+func TestExecuteCallbackHeaders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+	invoker, err := NewCallbackInvoker(&api.Config{CallbackHeaders: []string{"Authorization: Bearer token", "X-Build: a:b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, status := invoker.ExecuteCallback(server.URL, api.CallbackEventCompleted, true, nil, nil); !status.Delivered {
+		t.Fatalf("expected the callback to be delivered, got %+v", *status)
+	}
+	if header.Get("Authorization") != "Bearer token" || header.Get("X-Build") != "a:b" {
+		t.Errorf("expected the headers to be sent, got %v", header)
+	}
+	if header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected a JSON content type, got %q", header.Get("Content-Type"))
+	}
+
+	if _, err := NewCallbackInvoker(&api.Config{CallbackHeaders: []string{"Authorization"}}); err == nil {
+		t.Errorf("expected an error for a header without value")
+	}
+}
+
+func TestExecuteCallbackClientCertificate(t *testing.T) {
+	var peers int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peers = len(r.TLS.PeerCertificates)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	cert, key := newTestClientCertificate(t)
+	writePEM(t, certFile, "CERTIFICATE", cert)
+	writePEM(t, keyFile, "PRIVATE KEY", key)
+
+	invoker, err := NewCallbackInvoker(&api.Config{CallbackCertFile: certFile, CallbackKeyFile: keyFile, CallbackCAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, status := invoker.ExecuteCallback(server.URL, api.CallbackEventCompleted, true, nil, nil); !status.Delivered || peers != 1 {
+		t.Errorf("expected the callback to be delivered with the client certificate, got %+v", *status)
+	}
+
+	invoker, err = NewCallbackInvoker(&api.Config{CallbackCAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, status := invoker.ExecuteCallback(server.URL, api.CallbackEventCompleted, true, nil, nil); status.Delivered {
+		t.Errorf("expected the callback to be refused without a client certificate")
+	}
+
+	if _, err := NewCallbackInvoker(&api.Config{CallbackCertFile: certFile, CallbackKeyFile: caFile}); err == nil {
+		t.Errorf("expected an error for a mismatched key")
+	}
+}
+
+// newTestClientCertificate returns a DER encoded self-signed client
+// certificate and its PKCS8 encoded key.
+func newTestClientCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der, pkcs8
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,53 +1,223 @@
 package util
 
 import (
-	"bufio"
 	"fmt"
+	"io/ioutil"
 	"net/url"
-	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/source-to-image/pkg/api"
 )
 
 // case insensitively match all key=value variables containing the word "proxy"
 var proxyRegex = regexp.MustCompile("(?i).*proxy.*")
 
+// RedactedEnvValue replaces the values of the redacted environment variables.
+const RedactedEnvValue = "REDACTED"
+
+// envNameRegex matches the names of the variables of the environment files.
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
 // ReadEnvironmentFile reads the content for a file that contains a list of
-// environment variables and values. The key-pairs are separated by a new line
-// character. The file can also have comments (both '#' and '//' are supported).
-func ReadEnvironmentFile(path string) (map[string]string, error) {
-	f, err := os.Open(path)
+// environment variables and values. The files with the .yaml or .yml extension
+// hold a YAML map of the variables to scalar values. The others are dotenv
+// files: the key-pairs are separated by a new line character, may be prefixed
+// with export, and their values may be single quoted, taken literally, or
+// double quoted, with escape sequences. Both quoted values may span several
+// lines. The file can also have comments (both '#' and '//' are supported).
+// The malformed lines are skipped with a warning, unless strict is set and
+// they are an error.
+func ReadEnvironmentFile(path string, strict bool) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAMLEnvironment(path, data, strict)
+	}
+	return parseDotenv(path, data, strict)
+}
 
+// parseDotenv parses the variables of a dotenv file.
+func parseDotenv(path string, data []byte, strict bool) (map[string]string, error) {
 	result := map[string]string{}
-
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		s := strings.TrimSpace(scanner.Text())
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		s := strings.TrimSpace(lines[i])
 		// Allow for comments in environment file
-		if strings.HasPrefix(s, "#") || strings.HasPrefix(s, "//") {
+		if len(s) == 0 || strings.HasPrefix(s, "#") || strings.HasPrefix(s, "//") {
+			continue
+		}
+		start := i
+		// the trailing spaces of the line are part of a quoted value spanning
+		// the following lines
+		name, value, next, err := parseDotenvLine(strings.TrimLeft(lines[i], " \t"), lines[i+1:])
+		i += next
+		if err != nil {
+			if strict {
+				return nil, fmt.Errorf("%s:%d: %v", path, start+1, err)
+			}
+			log.Warningf("Skipping line %d of environment file %q: %v", start+1, path, err)
 			continue
 		}
-		parts := strings.SplitN(s, "=", 2)
-		if len(parts) != 2 {
+		result[name] = value
+	}
+	return result, nil
+}
+
+// parseDotenvLine parses the variable of the given line of a dotenv file,
+// with the following lines its quoted value spans. It returns the number of
+// following lines consumed.
+func parseDotenvLine(s string, following []string) (string, string, int, error) {
+	if rest := strings.TrimPrefix(s, "export"); rest != s && len(rest) > 0 && (rest[0] == ' ' || rest[0] == '\t') {
+		s = strings.TrimLeft(rest, " \t")
+	}
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok {
+		return "", "", 0, fmt.Errorf("expected NAME=VALUE, got %q", strings.TrimSpace(s))
+	}
+	if !envNameRegex.MatchString(name) {
+		return "", "", 0, fmt.Errorf("invalid variable name %q", name)
+	}
+	value = strings.TrimLeft(value, " \t")
+	if len(value) == 0 || (value[0] != '"' && value[0] != '\'') {
+		// an unquoted value ends with the comment following it
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		return name, strings.TrimRight(value, " \t"), 0, nil
+	}
+
+	quote := value[0]
+	value = value[1:]
+	for next := 0; ; next++ {
+		if end := closingQuote(value, quote); end >= 0 {
+			if rest := strings.TrimSpace(value[end+1:]); len(rest) > 0 && !strings.HasPrefix(rest, "#") {
+				return "", "", next, fmt.Errorf("unexpected %q after the quoted value of %s", rest, name)
+			}
+			value = value[:end]
+			if quote == '"' {
+				value = unescapeDotenv(value)
+			}
+			return name, value, next, nil
+		}
+		if next == len(following) {
+			return "", "", 0, fmt.Errorf("unterminated quoted value of %s", name)
+		}
+		value += "\n" + following[next]
+	}
+}
+
+// closingQuote returns the index of the quote closing the given value, or -1
+// when it is not closed. Double quotes are escaped with a backslash.
+func closingQuote(value string, quote byte) int {
+	for i := 0; i < len(value); i++ {
+		switch {
+		case quote == '"' && value[i] == '\\':
+			i++
+		case value[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeDotenv replaces the escape sequences of a double quoted value.
+func unescapeDotenv(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, `$`)
+	return replacer.Replace(value)
+}
+
+// parseYAMLEnvironment parses the variables of a YAML environment file.
+func parseYAMLEnvironment(path string, data []byte, strict bool) (map[string]string, error) {
+	result := map[string]string{}
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return result, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: expected a map of the variables to their values", path, root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		var err error
+		switch {
+		case !envNameRegex.MatchString(key.Value):
+			err = fmt.Errorf("invalid variable name %q", key.Value)
+		case value.Kind != yaml.ScalarNode:
+			err = fmt.Errorf("the value of %s is not a scalar", key.Value)
+		}
+		if err != nil {
+			if strict {
+				return nil, fmt.Errorf("%s:%d: %v", path, key.Line, err)
+			}
+			log.Warningf("Skipping line %d of environment file %q: %v", key.Line, path, err)
 			continue
 		}
-		result[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		if value.Tag == "!!null" {
+			result[key.Value] = ""
+		} else {
+			result[key.Value] = value.Value
+		}
 	}
+	return result, nil
+}
 
-	return result, scanner.Err()
+// IsRedactedEnv returns whether the value of the environment variable is
+// redacted, its name matching one of the glob patterns case insensitively. The
+// api.DefaultRedactEnvPatterns apply when patterns is nil, as for the
+// configurations which did not set them, while an empty list redacts nothing.
+func IsRedactedEnv(name string, patterns []string) bool {
+	if patterns == nil {
+		patterns = api.DefaultRedactEnvPatterns
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(name)); ok {
+			return true
+		}
+	}
+	return false
 }
 
-// SafeForLoggingEnv attempts to strip sensitive information from proxy
-// environment variable strings in key=value form.
-func SafeForLoggingEnv(env []string) []string {
+// RedactEnvironment returns a copy of the environment where the values of the
+// variables matching the patterns are redacted.
+func RedactEnvironment(env api.EnvironmentList, patterns []string) api.EnvironmentList {
+	result := make(api.EnvironmentList, len(env))
+	for i, e := range env {
+		result[i] = e
+		if IsRedactedEnv(e.Name, patterns) {
+			result[i].Value = RedactedEnvValue
+		}
+	}
+	return result
+}
+
+// SafeForLoggingEnv attempts to strip sensitive information from environment
+// variable strings in key=value form: the values of the variables matching the
+// redaction patterns (see IsRedactedEnv) and the credentials of the proxy URLs.
+func SafeForLoggingEnv(env []string, patterns []string) []string {
 	newEnv := make([]string, len(env))
 	copy(newEnv, env)
 	for i, entry := range newEnv {
 		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 && IsRedactedEnv(parts[0], patterns) {
+			newEnv[i] = parts[0] + "=" + RedactedEnvValue
+			continue
+		}
 		if !proxyRegex.MatchString(parts[0]) {
 			continue
 		}
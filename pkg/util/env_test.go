@@ -1,7 +1,13 @@
 package util
 
 import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
 )
 
 type envTestCase struct {
@@ -48,10 +54,114 @@ func TestStripProxyCredentials(t *testing.T) {
 		inputs[i] = c.Input
 		expected[i] = c.Output
 	}
-	result := SafeForLoggingEnv(inputs)
+	result := SafeForLoggingEnv(inputs, nil)
 	for i := range result {
 		if result[i] != expected[i] {
 			t.Errorf("expected %s for environment variable, but got %s", expected[i], result[i])
 		}
 	}
 }
+
+func TestReadEnvironmentFile(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		content  string
+		expected map[string]string
+		strict   string
+	}{
+		"simple": {
+			name:     "env",
+			content:  "# comment\n// comment\n\nFOO=bar\n  BAZ = qux  \nEMPTY=\nURL=http://example.com/?a=b\n",
+			expected: map[string]string{"FOO": "bar", "BAZ": "qux", "EMPTY": "", "URL": "http://example.com/?a=b"},
+		},
+		"dotenv": {
+			name: ".env",
+			content: "export FOO=bar\r\nexport\tBAR='single $HOME \\n'\nBAZ=\"double \\\"quoted\\\"\\tvalue\\n\" # comment\n" +
+				"INLINE=value # comment\nHASH=a#b\nMULTI=\"first\nsecond \n  third\"\nKEY='-----BEGIN-----\nabc\n-----END-----'\nexported=1\n",
+			expected: map[string]string{
+				"FOO":      "bar",
+				"BAR":      "single $HOME \\n",
+				"BAZ":      "double \"quoted\"\tvalue\n",
+				"INLINE":   "value",
+				"HASH":     "a#b",
+				"MULTI":    "first\nsecond \n  third",
+				"KEY":      "-----BEGIN-----\nabc\n-----END-----",
+				"exported": "1",
+			},
+		},
+		"malformed": {
+			name:     "env",
+			content:  "FOO=bar\nnot a variable\nexport\n1NAME=value\nQUOTED=\"a\" b\nUNTERMINATED='value\nBAZ=qux\n",
+			expected: map[string]string{"FOO": "bar", "BAZ": "qux"},
+			strict:   `env:2: expected NAME=VALUE, got "not a variable"`,
+		},
+		"yaml": {
+			name:     "env.yaml",
+			content:  "FOO: bar\nPORT: 8080\nVERSION: 1.10\nDEBUG: true\nEMPTY:\nQUOTED: \"a: b\"\nMULTI: |\n  first\n  second\n",
+			expected: map[string]string{"FOO": "bar", "PORT": "8080", "VERSION": "1.10", "DEBUG": "true", "EMPTY": "", "QUOTED": "a: b", "MULTI": "first\nsecond\n"},
+		},
+		"yaml with non-scalar values": {
+			name:     "env.yml",
+			content:  "FOO: bar\nLIST: [a, b]\nMAP:\n  a: b\n",
+			expected: map[string]string{"FOO": "bar"},
+			strict:   "env.yml:2: the value of LIST is not a scalar",
+		},
+		"empty yaml": {
+			name:     "env.yaml",
+			content:  "",
+			expected: map[string]string{},
+		},
+	}
+	for desc, tc := range tests {
+		path := filepath.Join(t.TempDir(), tc.name)
+		if err := ioutil.WriteFile(path, []byte(tc.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		env, err := ReadEnvironmentFile(path, false)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+		} else if !reflect.DeepEqual(env, tc.expected) {
+			t.Errorf("%s: expected %q, got %q", desc, tc.expected, env)
+		}
+		_, err = ReadEnvironmentFile(path, true)
+		switch {
+		case len(tc.strict) == 0 && err != nil:
+			t.Errorf("%s: unexpected error in strict mode: %v", desc, err)
+		case len(tc.strict) > 0 && (err == nil || !strings.HasSuffix(err.Error(), tc.strict)):
+			t.Errorf("%s: expected the error %q in strict mode, got %v", desc, tc.strict, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "env.yaml")
+	if err := ioutil.WriteFile(path, []byte("- FOO=bar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadEnvironmentFile(path, false); err == nil {
+		t.Errorf("expected an error for a YAML list")
+	}
+}
+
+func TestRedactEnv(t *testing.T) {
+	env := []string{"GITHUB_TOKEN=ghp_s3cr3t", "db_password=s3cr3t", "SECRET=s3cr3t", "DEBUG=true", "EMPTY_TOKEN"}
+	expected := []string{"GITHUB_TOKEN=REDACTED", "db_password=REDACTED", "SECRET=REDACTED", "DEBUG=true", "EMPTY_TOKEN"}
+	if result := SafeForLoggingEnv(env, nil); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	expected = []string{"GITHUB_TOKEN=ghp_s3cr3t", "db_password=s3cr3t", "SECRET=s3cr3t", "DEBUG=REDACTED", "EMPTY_TOKEN"}
+	if result := SafeForLoggingEnv(env, []string{"DEBUG"}); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	list := api.EnvironmentList{{Name: "API_KEY", Value: "s3cr3t"}, {Name: "NPM_AUTH_TOKEN", Value: "s3cr3t"}}
+	redacted := RedactEnvironment(list, []string{"*_key", "*token*"})
+	if redacted[0].Value != RedactedEnvValue || redacted[1].Value != RedactedEnvValue || list[0].Value != "s3cr3t" {
+		t.Errorf("expected a redacted copy of the environment, got %v", redacted)
+	}
+	if redacted := RedactEnvironment(list, nil); redacted[1].Value != RedactedEnvValue {
+		t.Errorf("expected the default patterns to apply without patterns, got %v", redacted)
+	}
+	if redacted := RedactEnvironment(list, []string{}); redacted[1].Value != "s3cr3t" {
+		t.Errorf("expected an empty list of patterns not to redact anything, got %v", redacted)
+	}
+}
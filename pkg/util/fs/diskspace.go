@@ -0,0 +1,56 @@
+package fs
+
+import (
+	"os"
+
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+// DiskSpaceHeadroom is the space, in bytes, which must remain free on a file
+// system once the estimated needs of the build are met.
+const DiskSpaceHeadroom = 64 * 1024 * 1024
+
+// DiskSpace is the space available on a file system.
+type DiskSpace struct {
+	// Bytes is the number of bytes available to unprivileged users.
+	Bytes uint64
+	// Inodes is the number of inodes available, or 0 when the file system
+	// does not report them.
+	Inodes uint64
+}
+
+// CheckDiskSpace checks that the file system holding path has room for the
+// given number of bytes, plus DiskSpaceHeadroom, and of files. The check is
+// skipped, logging why, when the space available cannot be determined.
+func CheckDiskSpace(path string, bytes, files uint64) error {
+	space, err := GetDiskSpace(path)
+	if err != nil {
+		log.V(1).Infof("Unable to determine the disk space available for %q: %v", path, err)
+		return nil
+	}
+	log.V(3).Infof("The file system holding %q has %d bytes and %d inodes available, %d bytes and %d files are needed", path, space.Bytes, space.Inodes, bytes, files)
+	if required := bytes + DiskSpaceHeadroom; space.Bytes < required {
+		return s2ierr.NewInsufficientDiskSpaceError(path, "disk space (bytes)", required, space.Bytes)
+	}
+	if space.Inodes > 0 && space.Inodes < files {
+		return s2ierr.NewInsufficientDiskSpaceError(path, "inodes", files, space.Inodes)
+	}
+	return nil
+}
+
+// DirectorySize returns the total size of the regular files under dir, and
+// the number of files and directories it holds.
+func DirectorySize(fs FileSystem, dir string) (uint64, uint64, error) {
+	var size, files uint64
+	err := fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		files++
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, files, err
+}
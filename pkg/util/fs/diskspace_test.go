@@ -0,0 +1,43 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckDiskSpace(dir, 0, 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := CheckDiskSpace(dir, 1<<62, 0)
+	if e, ok := err.(s2ierr.Error); !ok || e.ErrorCode != s2ierr.InsufficientDiskSpaceError {
+		t.Errorf("expected an insufficient disk space error, got %v", err)
+	}
+	if err := CheckDiskSpace(filepath.Join(dir, "missing"), 1<<62, 0); err != nil {
+		t.Errorf("expected the check to be skipped, got %v", err)
+	}
+}
+
+func TestDirectorySize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b"), []byte("world!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	size, files, err := DirectorySize(NewFileSystem(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 11 || files != 4 {
+		t.Errorf("expected 11 bytes in 4 files, got %d bytes in %d files", size, files)
+	}
+}
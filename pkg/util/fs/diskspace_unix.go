@@ -0,0 +1,17 @@
+//go:build !windows
+
+package fs
+
+import "golang.org/x/sys/unix"
+
+// GetDiskSpace returns the space available on the file system holding path.
+func GetDiskSpace(path string) (*DiskSpace, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return nil, err
+	}
+	return &DiskSpace{
+		Bytes:  uint64(stat.Bavail) * uint64(stat.Bsize),
+		Inodes: uint64(stat.Ffree),
+	}, nil
+}
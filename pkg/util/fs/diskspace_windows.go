@@ -0,0 +1,17 @@
+package fs
+
+import "golang.org/x/sys/windows"
+
+// GetDiskSpace returns the space available on the file system holding path.
+// Windows does not report the inodes available.
+func GetDiskSpace(path string) (*DiskSpace, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(name, &available, &total, &free); err != nil {
+		return nil, err
+	}
+	return &DiskSpace{Bytes: available}, nil
+}
@@ -326,9 +326,10 @@ func (h *fs) RemoveDirectory(dir string) error {
 	return err
 }
 
-// CreateWorkingDirectory creates a directory to be used for STI
+// CreateWorkingDirectory creates a directory to be used for STI, under the
+// directory set by SetTempDir
 func (h *fs) CreateWorkingDirectory() (directory string, err error) {
-	directory, err = ioutil.TempDir("", "s2i")
+	directory, err = MkdirTemp("build")
 	if err != nil {
 		return "", s2ierr.NewWorkDirError(directory, err)
 	}
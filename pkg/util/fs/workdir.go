@@ -0,0 +1,171 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultStaleAge is the age after which the working directories and
+// temporary files left behind by the s2i processes which crashed are removed.
+const DefaultStaleAge = 24 * time.Hour
+
+// MinTempSpace is the space, in bytes, which must be available in the
+// temporary directory before the working directories are created there.
+const MinTempSpace = 64 * 1024 * 1024
+
+// tempPattern matches the names of the working directories and temporary
+// files, capturing the ID of the s2i process which created them.
+var tempPattern = regexp.MustCompile(`^s2i-(\d+)-`)
+
+// Workdir creates the working directories and temporary files of s2i under a
+// temporary directory. Their names are prefixed with the ID of the s2i process
+// so that those left behind by the processes which crashed can be removed.
+type Workdir struct {
+	root     string
+	staleAge time.Duration
+	cleanup  sync.Once
+}
+
+// NewWorkdir returns a Workdir creating its directories and files under root,
+// or under the default temporary directory ($TMPDIR or /tmp) when root is
+// empty. Those older than staleAge, created by other processes, are removed
+// the first time a directory or file is created; 0 disables the removal.
+func NewWorkdir(root string, staleAge time.Duration) *Workdir {
+	return &Workdir{root: root, staleAge: staleAge}
+}
+
+var (
+	defaultWorkdir      = NewWorkdir("", DefaultStaleAge)
+	defaultWorkdirMutex sync.Mutex
+)
+
+// SetTempDir sets the directory under which the working directories and the
+// temporary files of s2i are created, the default temporary directory when
+// dir is empty.
+func SetTempDir(dir string) {
+	defaultWorkdirMutex.Lock()
+	defer defaultWorkdirMutex.Unlock()
+	defaultWorkdir = NewWorkdir(dir, DefaultStaleAge)
+}
+
+// TempDir returns the directory set by SetTempDir, empty when the default
+// temporary directory is used.
+func TempDir() string {
+	return DefaultWorkdir().root
+}
+
+// DefaultWorkdir returns the Workdir creating the working directories and the
+// temporary files of s2i.
+func DefaultWorkdir() *Workdir {
+	defaultWorkdirMutex.Lock()
+	defer defaultWorkdirMutex.Unlock()
+	return defaultWorkdir
+}
+
+// MkdirTemp creates a new directory named after name in the temporary
+// directory of the default Workdir, and returns its path.
+func MkdirTemp(name string) (string, error) {
+	return DefaultWorkdir().MkdirTemp(name)
+}
+
+// CreateTemp creates a new file named after name in the temporary directory of
+// the default Workdir, and returns it opened for writing.
+func CreateTemp(name string) (*os.File, error) {
+	return DefaultWorkdir().CreateTemp(name)
+}
+
+// Root returns the directory under which the directories and files are
+// created.
+func (w *Workdir) Root() string {
+	if len(w.root) == 0 {
+		return os.TempDir()
+	}
+	return w.root
+}
+
+// MkdirTemp creates a new directory named after name, and returns its path.
+func (w *Workdir) MkdirTemp(name string) (string, error) {
+	root, err := w.prepare()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(root, w.pattern(name))
+}
+
+// CreateTemp creates a new file named after name, and returns it opened for
+// writing.
+func (w *Workdir) CreateTemp(name string) (*os.File, error) {
+	root, err := w.prepare()
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(root, w.pattern(name))
+}
+
+// pattern returns the pattern of the names of the directories and files,
+// unique thanks to the ID of the process and the random suffix added by
+// os.MkdirTemp and os.CreateTemp.
+func (w *Workdir) pattern(name string) string {
+	return fmt.Sprintf("s2i-%d-%s-", os.Getpid(), name)
+}
+
+// prepare checks that the temporary directory is usable and has room for the
+// directories and files of the build, removing the stale ones the first time
+// it is called.
+func (w *Workdir) prepare() (string, error) {
+	root := w.Root()
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("unable to use the temporary directory: %v", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("unable to use the temporary directory %q: not a directory", root)
+	}
+	w.cleanup.Do(w.RemoveStale)
+	if space, err := GetDiskSpace(root); err != nil {
+		log.V(3).Infof("Unable to determine the disk space available in %q: %v", root, err)
+	} else if space.Bytes < MinTempSpace {
+		return "", fmt.Errorf("the temporary directory %q has %d bytes available, at least %d are required", root, space.Bytes, MinTempSpace)
+	}
+	return root, nil
+}
+
+// RemoveStale removes the directories and files created by the other s2i
+// processes which were not modified for longer than the stale age, left
+// behind by builds which crashed. Only those of the current user, whose
+// process is no longer running, are removed.
+func (w *Workdir) RemoveStale() {
+	if w.staleAge <= 0 {
+		return
+	}
+	root := w.Root()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		log.V(3).Infof("Unable to list the temporary directory %q: %v", root, err)
+		return
+	}
+	for _, entry := range entries {
+		match := tempPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		pid, err := strconv.Atoi(match[1])
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < w.staleAge || !ownedByCurrentUser(info) || processRunning(pid) {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		log.V(2).Infof("Removing %q, left behind by a previous build", path)
+		if err := os.RemoveAll(path); err != nil {
+			log.V(1).Infof("Unable to remove %q: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkdirMkdirTemp(t *testing.T) {
+	root := t.TempDir()
+	w := NewWorkdir(root, DefaultStaleAge)
+	first, err := w.MkdirTemp("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := w.MkdirTemp("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("expected distinct directories, got %q twice", first)
+	}
+	prefix := fmt.Sprintf("s2i-%d-build-", os.Getpid())
+	for _, dir := range []string{first, second} {
+		if filepath.Dir(dir) != root || !strings.HasPrefix(filepath.Base(dir), prefix) {
+			t.Errorf("expected %q to be in %q and prefixed with %q", dir, root, prefix)
+		}
+	}
+
+	file, err := w.CreateTemp("upload-done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if !strings.HasPrefix(filepath.Base(file.Name()), fmt.Sprintf("s2i-%d-upload-done-", os.Getpid())) {
+		t.Errorf("unexpected temporary file %q", file.Name())
+	}
+}
+
+func TestWorkdirInvalidRoot(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{file, filepath.Join(root, "missing")} {
+		if _, err := NewWorkdir(dir, DefaultStaleAge).MkdirTemp("build"); err == nil {
+			t.Errorf("expected an error creating a directory in %q", dir)
+		}
+	}
+}
+
+func TestWorkdirRemoveStale(t *testing.T) {
+	root := t.TempDir()
+	// the parent of the test process runs until the test ends
+	running := os.Getppid()
+	old := time.Now().Add(-2 * DefaultStaleAge)
+	entries := map[string]bool{
+		"s2i-999999991-build-123":                      false,
+		"s2i-999999992-injection-result-456":           false,
+		fmt.Sprintf("s2i-%d-build-789", os.Getpid()):   true,
+		fmt.Sprintf("s2i-%d-build-running", running):   true,
+		"s2i-999999993-build-recent":                   true,
+		"s2i12345":                                     true,
+		"other-1-build-123":                            true,
+		fmt.Sprintf("s2i-%d-upload-done", os.Getpid()): true,
+	}
+	// the directories of other users can only be created by root
+	if runtime.GOOS != "windows" && os.Getuid() == 0 {
+		entries["s2i-999999994-build-other-user"] = true
+	}
+	for name := range entries {
+		path := filepath.Join(root, name)
+		if strings.Contains(name, "result") || strings.Contains(name, "done") {
+			if err := os.WriteFile(path, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.MkdirAll(filepath.Join(path, "upload", "src"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if name == "s2i-999999994-build-other-user" {
+			if err := os.Chown(path, 65534, 65534); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if name == "s2i-999999993-build-recent" {
+			continue
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := NewWorkdir(root, DefaultStaleAge).MkdirTemp("build"); err != nil {
+		t.Fatal(err)
+	}
+	for name, kept := range entries {
+		_, err := os.Stat(filepath.Join(root, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s: expected to be kept: %t, exists: %t", name, kept, exists)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package fs
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// processRunning returns true when the process pid exists, even when it
+// belongs to another user.
+func processRunning(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
+
+// ownedByCurrentUser returns true when the file described by info belongs to
+// the user running s2i.
+func ownedByCurrentUser(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
@@ -0,0 +1,32 @@
+package fs
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code of the processes which did not exit.
+const stillActive = 259
+
+// processRunning returns true when the process pid exists.
+func processRunning(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// the processes of other users cannot be opened
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(handle)
+	var code uint32
+	if err := windows.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
+
+// ownedByCurrentUser returns true when the file described by info belongs to
+// the user running s2i. The temporary directory of Windows is in the profile of
+// the user, so its files are assumed to be theirs.
+func ownedByCurrentUser(info os.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,137 @@
+// Package hostpath translates the paths given on the host running s2i, which
+// might be a Windows host, into the paths understood by the Linux containers
+// and daemons. Unlike the path/filepath package, it handles both the Windows
+// and the POSIX paths whatever the operating system s2i runs on, so that the
+// clients of the Linux daemons behave the same on every platform.
+package hostpath
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ToSlash returns p with its backslashes replaced by slashes, on every
+// operating system.
+func ToSlash(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// VolumeName returns the drive letter prefix, such as C:, of the given
+// Windows path, or an empty string.
+func VolumeName(p string) string {
+	if len(p) >= 2 && p[1] == ':' && isLetter(p[0]) {
+		return p[:2]
+	}
+	return ""
+}
+
+// IsWindowsAbs returns true if p is an absolute Windows path, starting with a
+// drive letter, such as C:\cache or C:/cache, or a UNC path, such as
+// \\server\share.
+func IsWindowsAbs(p string) bool {
+	if v := VolumeName(p); len(v) > 0 {
+		return len(p) > 2 && (p[2] == '\\' || p[2] == '/')
+	}
+	return strings.HasPrefix(p, `\\`)
+}
+
+// ContainerPath returns the given path of a Linux container, with slashes and
+// cleaned, for the paths typed with backslashes on Windows.
+func ContainerPath(p string) string {
+	if len(p) == 0 {
+		return p
+	}
+	return path.Clean(ToSlash(p))
+}
+
+// DaemonPath returns the given host path in the form the Linux daemons of the
+// Windows hosts, such as Docker Desktop, mount: C:\cache becomes /c/cache and
+// \\server\share //server/share. The other paths are returned as-is.
+func DaemonPath(p string) string {
+	if !IsWindowsAbs(p) {
+		return p
+	}
+	if v := VolumeName(p); len(v) > 0 {
+		return path.Clean("/" + strings.ToLower(v[:1]) + "/" + ToSlash(p[2:]))
+	}
+	return "/" + path.Clean(ToSlash(p))
+}
+
+// SplitVolume splits the given source:destination specification of a volume
+// at the colon following the source, which might be a Windows path starting
+// with a drive letter. The destination is empty when there is no colon.
+func SplitVolume(spec string) (source, destination string) {
+	drive := ""
+	if IsWindowsAbs(spec) {
+		drive = VolumeName(spec)
+	}
+	pos := strings.LastIndex(spec[len(drive):], ":")
+	if pos == -1 {
+		return spec, ""
+	}
+	pos += len(drive)
+	return spec[:pos], spec[pos+1:]
+}
+
+// TranslateBind translates the source of the given bind mount, in the
+// source:destination[:options] form of docker run --volume, into the form the
+// Linux daemons mount, and cleans its destination. A bind mount without a
+// colon, an anonymous volume, is returned as-is.
+func TranslateBind(bind string) (string, error) {
+	drive := ""
+	if IsWindowsAbs(bind) {
+		drive = VolumeName(bind)
+	}
+	parts := strings.SplitN(bind[len(drive):], ":", 3)
+	if len(parts) == 1 && len(drive) == 0 {
+		// an anonymous volume, mounted at the given destination
+		return bind, nil
+	}
+	if len(parts) < 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", fmt.Errorf("invalid volume %q, must be source:destination[:options]", bind)
+	}
+	parts[0] = DaemonPath(drive + parts[0])
+	parts[1] = ContainerPath(parts[1])
+	if !path.IsAbs(parts[1]) {
+		return "", fmt.Errorf("invalid volume %q, the destination %q must be absolute", bind, parts[1])
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// TranslateBinds translates the sources of the given bind mounts with
+// TranslateBind.
+func TranslateBinds(binds []string) ([]string, error) {
+	if len(binds) == 0 {
+		return binds, nil
+	}
+	translated := make([]string, 0, len(binds))
+	for _, bind := range binds {
+		t, err := TranslateBind(bind)
+		if err != nil {
+			return nil, err
+		}
+		translated = append(translated, t)
+	}
+	return translated, nil
+}
+
+// ExpandHome replaces the leading ~ of the given path, followed by a slash or
+// a backslash, with the home directory of the user, as the Windows shells do
+// not expand it.
+func ExpandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") && !strings.HasPrefix(p, `~\`) {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || len(home) == 0 {
+		return p
+	}
+	return filepath.Join(home, filepath.FromSlash(ToSlash(p[1:])))
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
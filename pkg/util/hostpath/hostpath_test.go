@@ -0,0 +1,121 @@
+package hostpath
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsWindowsAbs(t *testing.T) {
+	tests := map[string]bool{
+		`C:\cache`:       true,
+		`c:/cache`:       true,
+		`\\server\share`: true,
+		`C:cache`:        false,
+		`/cache`:         false,
+		`cache`:          false,
+		`1:\cache`:       false,
+	}
+	for p, expected := range tests {
+		if actual := IsWindowsAbs(p); actual != expected {
+			t.Errorf("IsWindowsAbs(%q): expected %t, got %t", p, expected, actual)
+		}
+	}
+}
+
+func TestContainerPath(t *testing.T) {
+	tests := map[string]string{
+		`\app\src\`:        "/app/src",
+		`/app//src/../lib`: "/app/lib",
+		`sub\dir`:          "sub/dir",
+		``:                 "",
+	}
+	for p, expected := range tests {
+		if actual := ContainerPath(p); actual != expected {
+			t.Errorf("ContainerPath(%q): expected %q, got %q", p, expected, actual)
+		}
+	}
+}
+
+func TestDaemonPath(t *testing.T) {
+	tests := map[string]string{
+		`C:\Users\me\cache`: "/c/Users/me/cache",
+		`D:/cache/`:         "/d/cache",
+		`\\server\share\x`:  "//server/share/x",
+		`/var/cache`:        "/var/cache",
+		`cache`:             "cache",
+	}
+	for p, expected := range tests {
+		if actual := DaemonPath(p); actual != expected {
+			t.Errorf("DaemonPath(%q): expected %q, got %q", p, expected, actual)
+		}
+	}
+}
+
+func TestSplitVolume(t *testing.T) {
+	tests := []struct {
+		spec        string
+		source      string
+		destination string
+	}{
+		{`C:\cache:/cache`, `C:\cache`, "/cache"},
+		{`C:\cache`, `C:\cache`, ""},
+		{`/cache:/tmp/cache`, "/cache", "/tmp/cache"},
+		{`/cache`, "/cache", ""},
+		{`c:dest`, "c", "dest"},
+	}
+	for _, tc := range tests {
+		source, destination := SplitVolume(tc.spec)
+		if source != tc.source || destination != tc.destination {
+			t.Errorf("SplitVolume(%q): expected %q, %q, got %q, %q", tc.spec, tc.source, tc.destination, source, destination)
+		}
+	}
+}
+
+func TestTranslateBind(t *testing.T) {
+	tests := []struct {
+		bind        string
+		expected    string
+		expectError bool
+	}{
+		{bind: `C:\cache:/cache`, expected: "/c/cache:/cache"},
+		{bind: `C:\cache:\opt\cache:ro`, expected: "/c/cache:/opt/cache:ro"},
+		{bind: `/var/cache:/cache:ro,z`, expected: "/var/cache:/cache:ro,z"},
+		{bind: `maven-cache:/root/.m2`, expected: "maven-cache:/root/.m2"},
+		{bind: `/data`, expected: "/data"},
+		{bind: `C:\cache`, expectError: true},
+		{bind: `/cache:cache`, expectError: true},
+		{bind: `:/cache`, expectError: true},
+	}
+	for _, tc := range tests {
+		actual, err := TranslateBind(tc.bind)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("TranslateBind(%q): expected an error, got %q", tc.bind, actual)
+			}
+			continue
+		}
+		if err != nil || actual != tc.expected {
+			t.Errorf("TranslateBind(%q): expected %q, got %q, %v", tc.bind, tc.expected, actual, err)
+		}
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+	tests := map[string]string{
+		`~`:                       home,
+		`~/.docker/config.json`:   filepath.Join(home, ".docker", "config.json"),
+		`~\.docker\config.json`:   filepath.Join(home, ".docker", "config.json"),
+		`~user/.docker`:           "~user/.docker",
+		`/etc/docker/config.json`: "/etc/docker/config.json",
+	}
+	for p, expected := range tests {
+		if actual := ExpandHome(p); actual != expected {
+			t.Errorf("ExpandHome(%q): expected %q, got %q", p, expected, actual)
+		}
+	}
+}
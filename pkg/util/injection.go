@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/openshift/source-to-image/pkg/api"
@@ -13,7 +15,8 @@ import (
 
 // FixInjectionsWithRelativePath fixes the injections that does not specify the
 // destination directory or the directory is relative to use the provided
-// working directory.
+// working directory. Single files injected without a destination keep their
+// name.
 func FixInjectionsWithRelativePath(workdir string, injections api.VolumeList) api.VolumeList {
 	if len(injections) == 0 {
 		return injections
@@ -23,6 +26,9 @@ func FixInjectionsWithRelativePath(workdir string, injections api.VolumeList) ap
 		changed := false
 		if filepath.Clean(filepath.FromSlash(injection.Destination)) == "." {
 			injection.Destination = filepath.ToSlash(workdir)
+			if info, err := os.Stat(injection.Source); err == nil && !info.IsDir() {
+				injection.Destination = path.Join(injection.Destination, filepath.Base(injection.Source))
+			}
 			changed = true
 		}
 		if filepath.ToSlash(injection.Destination)[0] != '/' {
@@ -37,9 +43,31 @@ func FixInjectionsWithRelativePath(workdir string, injections api.VolumeList) ap
 	return newList
 }
 
+// WriteLiteralInjections writes the content of the literal injections to
+// files in the provided directory and returns the injections of these files.
+func WriteLiteralInjections(fs fs.FileSystem, dir string, literals api.LiteralInjectionList) (api.VolumeList, error) {
+	injections := api.VolumeList{}
+	for i, literal := range literals {
+		literalDir := filepath.Join(dir, strconv.Itoa(i))
+		if err := fs.MkdirAllWithPermissions(literalDir, 0700); err != nil {
+			return nil, err
+		}
+		source := filepath.Join(literalDir, literal.Name)
+		if err := fs.WriteFile(source, []byte(literal.Value)); err != nil {
+			return nil, err
+		}
+		injections = append(injections, api.VolumeSpec{
+			Source:      source,
+			Destination: path.Join(literal.Destination, literal.Name),
+		})
+	}
+	return injections, nil
+}
+
 // ListFilesToTruncate returns a flat list of all files that are injected into a
 // container which need to be truncated. All files from nested directories are returned in the list.
-func ListFilesToTruncate(fs fs.FileSystem, injections api.VolumeList) ([]string, error) {
+// The files at, or below, the keep paths are left out.
+func ListFilesToTruncate(fs fs.FileSystem, injections api.VolumeList, keep []string) ([]string, error) {
 	result := []string{}
 	for _, s := range injections {
 		if s.Keep {
@@ -49,11 +77,29 @@ func ListFilesToTruncate(fs fs.FileSystem, injections api.VolumeList) ([]string,
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, files...)
+		for _, file := range files {
+			if isKeptInjection(file, keep) {
+				log.V(1).Infof("Keeping the injected file %q in the image", file)
+				continue
+			}
+			result = append(result, file)
+		}
 	}
 	return result, nil
 }
 
+// isKeptInjection returns true when the injected file is one of the keep
+// paths, or lies in one of them.
+func isKeptInjection(file string, keep []string) bool {
+	for _, k := range keep {
+		k = path.Clean(filepath.ToSlash(k))
+		if file == k || strings.HasPrefix(file, strings.TrimSuffix(k, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // ListFiles returns a flat list of all files injected into a container for the given `VolumeSpec`.
 func ListFiles(fs fs.FileSystem, spec api.VolumeSpec) ([]string, error) {
 	result := []string{}
@@ -115,10 +161,11 @@ func CreateTruncateFilesScript(files []string, scriptName string) (string, error
 		rmScript += fmt.Sprintf("truncate -s0 %q\n", s)
 	}
 
-	f, err := ioutil.TempFile("", "s2i-injection-remove")
+	f, err := fs.CreateTemp("injection-remove")
 	if err != nil {
 		return "", err
 	}
+	f.Close()
 	if len(scriptName) > 0 {
 		rmScript += fmt.Sprintf("truncate -s0 %q\n", scriptName)
 	}
@@ -131,10 +178,11 @@ func CreateTruncateFilesScript(files []string, scriptName string) (string, error
 // error. The path to the result file is returned. If the provided error is nil, an empty file is
 // created.
 func CreateInjectionResultFile(injectErr error) (string, error) {
-	f, err := ioutil.TempFile("", "s2i-injection-result")
+	f, err := fs.CreateTemp("injection-result")
 	if err != nil {
 		return "", err
 	}
+	f.Close()
 	if injectErr != nil {
 		err = ioutil.WriteFile(f.Name(), []byte(injectErr.Error()), 0700)
 	}
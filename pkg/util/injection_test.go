@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -58,7 +59,7 @@ func TestListFilesToTruncate(t *testing.T) {
 	f1, _ := ioutil.TempFile(tmp, "foo")
 	f2, _ := ioutil.TempFile(tmpNested, "bar")
 	ioutil.TempFile(tmpKeep, "that")
-	files, err := ListFilesToTruncate(fs.NewFileSystem(), list)
+	files, err := ListFilesToTruncate(fs.NewFileSystem(), list, nil)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -80,6 +81,98 @@ func TestListFilesToTruncate(t *testing.T) {
 	}
 }
 
+func TestListFilesToTruncateKeep(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "s2i-test-")
+	if err != nil {
+		t.Fatalf("Unable to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	for _, name := range []string{"ca.crt", "token", "certs/a.crt", "certs/b.crt", "certs2/c.crt"} {
+		file := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatalf("Unable to create %q: %v", filepath.Dir(file), err)
+		}
+		if err := ioutil.WriteFile(file, []byte(name), 0600); err != nil {
+			t.Fatalf("Unable to write %q: %v", file, err)
+		}
+	}
+	list := api.VolumeList{{Source: tmp, Destination: "/etc/pki"}}
+	files, err := ListFilesToTruncate(fs.NewFileSystem(), list, []string{"/etc/pki/ca.crt", "/etc/pki/certs/"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"/etc/pki/certs2/c.crt", "/etc/pki/token"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, files)
+	}
+}
+
+func TestFixInjectionsWithRelativePath(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "s2i-test-")
+	if err != nil {
+		t.Fatalf("Unable to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, "ca.crt")
+	if err := ioutil.WriteFile(file, []byte("cert"), 0600); err != nil {
+		t.Fatalf("Unable to write %q: %v", file, err)
+	}
+	list := api.VolumeList{
+		{Source: tmp, Destination: "."},
+		{Source: tmp, Destination: "certs"},
+		{Source: file, Destination: "."},
+		{Source: file, Destination: "/etc/pki/ca.pem"},
+	}
+	expected := api.VolumeList{
+		{Source: tmp, Destination: "/opt/app"},
+		{Source: tmp, Destination: "/opt/app/certs"},
+		{Source: file, Destination: "/opt/app/ca.crt"},
+		{Source: file, Destination: "/etc/pki/ca.pem"},
+	}
+	if got := FixInjectionsWithRelativePath("/opt/app", list); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestWriteLiteralInjections(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "s2i-test-")
+	if err != nil {
+		t.Fatalf("Unable to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	literals := api.LiteralInjectionList{
+		{Name: "token", Value: "secret", Destination: "/var/run/secrets"},
+		{Name: "token", Value: "other"},
+	}
+	injections, err := WriteLiteralInjections(fs.NewFileSystem(), tmp, literals)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := api.VolumeList{
+		{Source: filepath.Join(tmp, "0", "token"), Destination: "/var/run/secrets/token"},
+		{Source: filepath.Join(tmp, "1", "token"), Destination: "token"},
+	}
+	if !reflect.DeepEqual(injections, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, injections)
+	}
+	for i, injection := range injections {
+		data, err := ioutil.ReadFile(injection.Source)
+		if err != nil {
+			t.Fatalf("Unable to read %q: %v", injection.Source, err)
+		}
+		if string(data) != literals[i].Value {
+			t.Errorf("Expected %q to contain %q, got %q", injection.Source, literals[i].Value, string(data))
+		}
+	}
+	files, err := ListFilesToTruncate(fs.NewFileSystem(), injections, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"/var/run/secrets/token", "token"}) {
+		t.Errorf("Unexpected files to truncate: %+v", files)
+	}
+}
+
 func TestCreateInjectionResultFile(t *testing.T) {
 	type testCase struct {
 		Error   error
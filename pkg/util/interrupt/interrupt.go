@@ -23,8 +23,11 @@ package interrupt
 import (
 	"os"
 	"os/signal"
+	"sort"
 	"sync"
 	"syscall"
+
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 )
 
 // terminationSignals are signals that cause the program to exit in the
@@ -46,7 +49,7 @@ type Handler struct {
 
 // New creates a new Handler. The final function will be called only if a
 // termination signal is caught, after all notify functions are run. If final is
-// nil, it defaults to os.Exit(2). The final function may call os.Exit if
+// nil, it defaults to Terminate. The final function may call os.Exit if
 // exiting is desired. The notify functions will be called when a termination
 // signal is caught, or after the argument to the Run method completes.
 func New(final func(os.Signal), notify ...func()) *Handler {
@@ -89,16 +92,78 @@ func (h *Handler) close() {
 }
 
 // signal calls the notify functions and final, used when a signal was caught
-// while the Run method was running. If final is nil, os.Exit will be called as
-// a default.
+// while the Run method was running. If final is nil, Terminate will be called
+// as a default.
 func (h *Handler) signal(s os.Signal) {
 	h.once.Do(func() {
 		for _, fn := range h.notify {
 			fn()
 		}
 		if h.final == nil {
-			os.Exit(2)
+			Terminate(s)
 		}
 		h.final(s)
 	})
 }
+
+// termination holds the functions called when the process terminates on a
+// termination signal.
+var termination = struct {
+	sync.Mutex
+	once  sync.Once
+	next  int
+	funcs map[int]func(os.Signal)
+}{funcs: map[int]func(os.Signal){}}
+
+// Register registers a function called with the caught signal when the process
+// terminates on a termination signal, until the returned function is called.
+// The functions are called in the reverse order of their registration, so
+// that the resources are released in the reverse order of their acquisition.
+func Register(fn func(os.Signal)) func() {
+	termination.Lock()
+	defer termination.Unlock()
+	id := termination.next
+	termination.next++
+	termination.funcs[id] = fn
+	return func() {
+		termination.Lock()
+		defer termination.Unlock()
+		delete(termination.funcs, id)
+	}
+}
+
+// Terminate calls the registered functions, then exits with
+// s2ierr.ExitCodeInterrupted. Concurrent calls block until the process exits.
+func Terminate(s os.Signal) {
+	terminate(s, s2ierr.ExitCodeInterrupted)
+}
+
+// Abort stops the process as if it had caught a SIGTERM, for a reason other
+// than a termination signal: it calls the registered functions with SIGTERM,
+// then exits with the given exit code.
+func Abort(exitCode int) {
+	terminate(syscall.SIGTERM, exitCode)
+}
+
+// terminate calls the registered functions with s, then exits with exitCode.
+// Concurrent calls block until the process exits.
+func terminate(s os.Signal, exitCode int) {
+	termination.once.Do(func() {
+		termination.Lock()
+		ids := make([]int, 0, len(termination.funcs))
+		for id := range termination.funcs {
+			ids = append(ids, id)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+		funcs := make([]func(os.Signal), 0, len(ids))
+		for _, id := range ids {
+			funcs = append(funcs, termination.funcs[id])
+		}
+		termination.Unlock()
+		for _, fn := range funcs {
+			fn(s)
+		}
+		os.Exit(exitCode)
+	})
+	select {}
+}
@@ -2,10 +2,12 @@ package util
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/openshift/source-to-image/pkg/api"
 	"github.com/openshift/source-to-image/pkg/api/constants"
 	"github.com/openshift/source-to-image/pkg/scm/git"
+	"github.com/openshift/source-to-image/pkg/util/hostpath"
 )
 
 // GenerateOutputImageLabels generate the labels based on the s2i Config
@@ -35,9 +37,33 @@ func GenerateLabelsFromConfig(labels map[string]string, config *api.Config, name
 	}
 
 	addBuildLabel(labels, "image", config.BuilderImage, namespace)
+	addAutoTagLabels(labels, config, namespace)
+	if len(config.Injections) > 0 || len(config.LiteralInjections) > 0 {
+		addBuildLabel(labels, "injections.kept", strings.Join(config.KeepInjections, ","), namespace)
+	}
 	return labels
 }
 
+// GenerateAutoTagLabels generates the labels recording the tag derived from
+// the sources, for the images built without the other output image labels.
+func GenerateAutoTagLabels(config *api.Config) map[string]string {
+	labels := map[string]string{}
+	namespace := constants.DefaultNamespace
+	if len(config.LabelNamespace) > 0 {
+		namespace = config.LabelNamespace
+	}
+	addAutoTagLabels(labels, config, namespace)
+	return labels
+}
+
+// addAutoTagLabels adds the tag derived from the sources and its mode to labels.
+func addAutoTagLabels(labels map[string]string, config *api.Config, namespace string) {
+	if len(config.AutoTag) > 0 {
+		addBuildLabel(labels, "tag", config.Tag, namespace)
+		addBuildLabel(labels, "tag-mode", string(config.AutoTag), namespace)
+	}
+}
+
 // GenerateLabelsFromSourceInfo generate the labels based on the source repository
 // informations.
 func GenerateLabelsFromSourceInfo(labels map[string]string, info *git.SourceInfo, namespace string) map[string]string {
@@ -54,9 +80,13 @@ func GenerateLabelsFromSourceInfo(labels map[string]string, info *git.SourceInfo
 	addBuildLabel(labels, "commit.date", info.Date, namespace)
 	addBuildLabel(labels, "commit.id", info.CommitID, namespace)
 	addBuildLabel(labels, "commit.ref", info.Ref, namespace)
+	if info.CommitVerified {
+		addBuildLabel(labels, "commit.verified", "true", namespace)
+	}
+	addBuildLabel(labels, "commit.signed-by", info.SignedBy, namespace)
 	addBuildLabel(labels, "commit.message", info.Message, namespace)
 	addBuildLabel(labels, "source-location", info.Location, namespace)
-	addBuildLabel(labels, "source-context-dir", info.ContextDir, namespace)
+	addBuildLabel(labels, "source-context-dir", hostpath.ToSlash(info.ContextDir), namespace)
 	return labels
 }
 
@@ -7,7 +7,9 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/moby/term"
 	"k8s.io/klog/v2"
 )
 
@@ -23,6 +25,26 @@ type Logger interface {
 	Error(args ...interface{})
 	Fatalf(format string, args ...interface{})
 	Fatal(args ...interface{})
+	Stream(stream Stream, line string)
+}
+
+// Stream identifies the origin of a logged line.
+type Stream string
+
+const (
+	// StreamS2I identifies the messages of s2i itself.
+	StreamS2I Stream = "s2i"
+	// StreamStdout identifies the standard output of the containers.
+	StreamStdout Stream = "out"
+	// StreamStderr identifies the standard error of the containers.
+	StreamStderr Stream = "err"
+)
+
+// streamColors are the ANSI colors of the prefixes of the streams.
+var streamColors = map[Stream]string{
+	StreamS2I:    "\033[36m",
+	StreamStdout: "\033[32m",
+	StreamStderr: "\033[33m",
 }
 
 // VerboseLogger is roughly equivalent to klog's Verbose.
@@ -35,9 +57,9 @@ type VerboseLogger interface {
 // any other output to klog (no matter what the level is).
 func ToFile(x io.Writer, level int32) Logger {
 	return &FileLogger{
-		&sync.Mutex{},
-		bufio.NewWriter(x),
-		level,
+		mutex: &sync.Mutex{},
+		w:     bufio.NewWriter(x),
+		level: level,
 	}
 }
 
@@ -93,12 +115,49 @@ func (discard) Fatalf(string, ...interface{}) {
 func (discard) Fatal(...interface{}) {
 }
 
+// Stream records a line of a stream.
+func (discard) Stream(Stream, string) {
+}
+
 // FileLogger logs the provided messages at level or below to the writer, or delegates
 // to klog.
 type FileLogger struct {
 	mutex *sync.Mutex
 	w     *bufio.Writer
 	level int32
+
+	// prefixes, when set, prefixes the lines with their stream, in color when
+	// color is set.
+	prefixes bool
+	color    bool
+
+	// file, when set, receives the timestamped messages of s2i, without the
+	// output of the containers.
+	file io.Writer
+}
+
+// SetOutput replaces the writer the messages are logged to.
+func (f *FileLogger) SetOutput(x io.Writer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.w = bufio.NewWriter(x)
+}
+
+// SetFile logs the messages of s2i to x as well, prefixed with their time.
+func (f *FileLogger) SetFile(x io.Writer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.file = x
+}
+
+// SetPrefixes prefixes the logged lines with their stream, colored with ANSI
+// escape sequences when color is true, so that the messages of s2i and the
+// output of the containers can be told apart.
+func (f *FileLogger) SetPrefixes(color bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.prefixes = true
+	f.color = color
 }
 
 // Is returns whether the current logging level is greater than or equal to the parameter.
@@ -141,33 +200,68 @@ var severities = []severityDetail{
 	fatalLog:   {"FATAL: ", klog.FatalDepth},
 }
 
-func (f *FileLogger) writeln(sev severity, line string) {
+func (f *FileLogger) writeln(sev severity, stream Stream, line string) {
 	severity := severities[sev]
 
 	// If the loglevel has been elevated above this file logger's verbosity (generally set to 2)
 	// then delegate ALL messages to elevated logger in order to leverage its file/line/timestamp
 	// prefix information.
 	if klog.V(klog.Level(f.level + 1)).Enabled() {
-		severity.delegateFn(3, line)
+		f.mutex.Lock()
+		prefix := f.streamPrefix(stream)
+		f.mutex.Unlock()
+		severity.delegateFn(3, prefix+line)
+		f.mutex.Lock()
+		f.writeFile(severity, stream, line)
+		f.mutex.Unlock()
 	} else {
 		// buf.io is not threadsafe, so serialize access to the stream
 		f.mutex.Lock()
 		defer f.mutex.Unlock()
+		f.w.WriteString(f.streamPrefix(stream))
 		f.w.WriteString(severity.prefix)
 		f.w.WriteString(line)
 		if !strings.HasSuffix(line, "\n") {
 			f.w.WriteByte('\n')
 		}
 		f.w.Flush()
+		f.writeFile(severity, stream, line)
+	}
+}
+
+// writeFile writes the line of s2i to the log file, if any. The mutex must be
+// held.
+func (f *FileLogger) writeFile(severity severityDetail, stream Stream, line string) {
+	if f.file == nil || stream != StreamS2I {
+		return
 	}
+	line = time.Now().UTC().Format(time.RFC3339) + " " + severity.prefix + strings.TrimSuffix(line, "\n") + "\n"
+	io.WriteString(f.file, line)
+}
+
+// streamPrefix returns the prefix of the lines of the stream. The mutex must be
+// held.
+func (f *FileLogger) streamPrefix(stream Stream) string {
+	if !f.prefixes {
+		return ""
+	}
+	if f.color {
+		return streamColors[stream] + string(stream) + "\033[0m | "
+	}
+	return string(stream) + " | "
 }
 
 func (f *FileLogger) outputf(sev severity, format string, args ...interface{}) {
-	f.writeln(sev, fmt.Sprintf(format, args...))
+	f.writeln(sev, StreamS2I, fmt.Sprintf(format, args...))
 }
 
 func (f *FileLogger) output(sev severity, args ...interface{}) {
-	f.writeln(sev, fmt.Sprint(args...))
+	f.writeln(sev, StreamS2I, fmt.Sprint(args...))
+}
+
+// Stream records a line of a stream.
+func (f *FileLogger) Stream(stream Stream, line string) {
+	f.writeln(infoLog, stream, line)
 }
 
 // Infof records an info log entry.
@@ -211,3 +305,13 @@ func (f *FileLogger) Fatal(args ...interface{}) {
 	defer os.Exit(1)
 	f.output(fatalLog, args...)
 }
+
+// IsTerminal returns whether w is a terminal able to interpret ANSI escape
+// sequences.
+func IsTerminal(w io.Writer) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	_, isTerminal := term.GetFdInfo(w)
+	return isTerminal
+}
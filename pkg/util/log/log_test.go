@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamPrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes bool
+		color    bool
+		expected string
+	}{
+		{
+			name:     "no prefixes",
+			expected: "building\nWARNING: deprecated\ncompiling\nnpm WARN\n",
+		},
+		{
+			name:     "prefixes",
+			prefixes: true,
+			expected: "s2i | building\ns2i | WARNING: deprecated\nout | compiling\nerr | npm WARN\n",
+		},
+		{
+			name:     "colored prefixes",
+			prefixes: true,
+			color:    true,
+			expected: "\033[36ms2i\033[0m | building\n\033[36ms2i\033[0m | WARNING: deprecated\n\033[32mout\033[0m | compiling\n\033[33merr\033[0m | npm WARN\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			log := ToFile(out, 2).(*FileLogger)
+			if tc.prefixes {
+				log.SetPrefixes(tc.color)
+			}
+			log.Info("building")
+			log.Warning("deprecated")
+			log.Stream(StreamStdout, "compiling")
+			log.Stream(StreamStderr, "npm WARN")
+			if out.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	out := &bytes.Buffer{}
+	file := &bytes.Buffer{}
+	log := ToFile(out, 2).(*FileLogger)
+	log.SetFile(file)
+	log.Info("building")
+	log.Stream(StreamStdout, "compiling")
+	log.Warning("deprecated\n")
+	lines := strings.Split(strings.TrimSuffix(file.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected the messages of s2i only in the log file, got %q", file.String())
+	}
+	for i, expected := range []string{"building", "WARNING: deprecated"} {
+		parts := strings.SplitN(lines[i], " ", 2)
+		if _, err := time.Parse(time.RFC3339, parts[0]); err != nil {
+			t.Errorf("expected %q to start with a timestamp: %v", lines[i], err)
+		}
+		if len(parts) != 2 || parts[1] != expected {
+			t.Errorf("expected %q, got %q", expected, lines[i])
+		}
+	}
+}
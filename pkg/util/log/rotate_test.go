@@ -0,0 +1,78 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s2i-log-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "s2i.log")
+	if err := ioutil.WriteFile(path, []byte("0123456789\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRotatingFile(path, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 6; i++ {
+		if _, err := fmt.Fprintf(r, "line %d ....\n", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"s2i.log":   "line 6 ....\n",
+		"s2i.log.1": "line 4 ....\nline 5 ....\n",
+		"s2i.log.2": "line 2 ....\nline 3 ....\n",
+		"s2i.log.3": "0123456789\nline 1 ....\n",
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(expected) {
+		t.Errorf("expected %d log files, got %d", len(expected), len(files))
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("unable to read %s: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("expected %s to contain %q, got %q", name, content, string(data))
+		}
+	}
+}
+
+func TestRotatingFileNoMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s2i-log-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "s2i.log")
+	r, err := NewRotatingFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(r, "line %d\n", i)
+	}
+	r.Close()
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("expected the log file not to be rotated, got %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+// Package progress displays the progress of the steps of the builds.
+package progress
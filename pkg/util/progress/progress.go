@@ -0,0 +1,198 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+// labels are the descriptions of the steps displayed on the status lines.
+var labels = map[api.StepName]string{
+	api.StepPullBuilderImage:          "Pull builder image",
+	api.StepPullPreviousImage:         "Pull previous image",
+	api.StepPullRuntimeImage:          "Pull runtime image",
+	api.StepFetchSource:               "Fetch sources",
+	api.StepRetrievePreviousArtifacts: "Retrieve previous artifacts",
+	api.StepBuildDockerImage:          "Build layered image",
+	api.StepAssembleBuildScripts:      "Run assemble",
+	api.StepCommitContainer:           "Commit image",
+	api.StepScanImage:                 "Scan image",
+	api.StepPostCommitTest:            "Test image",
+	api.StepVerifyRunScript:           "Verify run script",
+}
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+type display interface {
+	step(step api.StepName)
+	finish(err error)
+	clear()
+	redraw()
+}
+
+var (
+	mu      sync.Mutex
+	current display
+)
+
+// Enable displays the progress of the builds on out in the given mode, and
+// returns true if the progress is displayed with status lines rather than
+// line-based logs.
+func Enable(mode api.ProgressMode, out io.Writer) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if mode == api.ProgressPlain || (mode != api.ProgressTTY && !utillog.IsTerminal(out)) {
+		current = nil
+		return false
+	}
+	current = &tty{out: out, done: make(chan struct{})}
+	return true
+}
+
+// Step displays the step as in progress, and the previous step as completed.
+func Step(step api.StepName) {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		current.step(step)
+	}
+}
+
+// Finish displays the step in progress as completed, or as failed if err is
+// not nil, and stops displaying the progress.
+func Finish(err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		current.finish(err)
+		current = nil
+	}
+}
+
+// Output returns a writer writing to w without garbling the status lines of
+// the progress.
+func Output(w io.Writer) io.Writer {
+	return &output{w: w}
+}
+
+type output struct {
+	w io.Writer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return o.w.Write(p)
+	}
+	current.clear()
+	defer current.redraw()
+	return o.w.Write(p)
+}
+
+// tty displays a live status line, with a spinner and the elapsed time, for
+// the step in progress.
+type tty struct {
+	out     io.Writer
+	mu      sync.Mutex
+	current api.StepName
+	started time.Time
+	frame   int
+	ticking bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (t *tty) step(step api.StepName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if step == t.current {
+		return
+	}
+	t.end(nil)
+	t.current = step
+	t.started = time.Now()
+	t.draw()
+	if !t.ticking {
+		t.ticking = true
+		t.wg.Add(1)
+		go t.tick()
+	}
+}
+
+func (t *tty) finish(err error) {
+	t.mu.Lock()
+	t.end(err)
+	t.mu.Unlock()
+	close(t.done)
+	t.wg.Wait()
+}
+
+// tick redraws the status line of the step in progress until the progress
+// stops being displayed.
+func (t *tty) tick() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			if len(t.current) > 0 {
+				t.frame++
+				t.draw()
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+func (t *tty) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.current) > 0 {
+		fmt.Fprint(t.out, "\r\033[K")
+	}
+}
+
+func (t *tty) redraw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.current) > 0 {
+		t.draw()
+	}
+}
+
+func (t *tty) draw() {
+	fmt.Fprintf(t.out, "\r\033[K%s %s %s", spinner[t.frame%len(spinner)], label(t.current), elapsed(t.started))
+}
+
+// end completes the status line of the step in progress.
+func (t *tty) end(err error) {
+	if len(t.current) == 0 {
+		return
+	}
+	symbol := "✔"
+	if err != nil {
+		symbol = "✘"
+	}
+	fmt.Fprintf(t.out, "\r\033[K%s %s %s\n", symbol, label(t.current), elapsed(t.started))
+	t.current = ""
+}
+
+func label(step api.StepName) string {
+	if l, ok := labels[step]; ok {
+		return l
+	}
+	return string(step)
+}
+
+func elapsed(since time.Time) string {
+	return fmt.Sprintf("%.1fs", time.Since(since).Seconds())
+}
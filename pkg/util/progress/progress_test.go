@@ -0,0 +1,51 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+)
+
+func TestEnable(t *testing.T) {
+	defer Finish(nil)
+	out := &bytes.Buffer{}
+	if Enable(api.ProgressPlain, out) {
+		t.Errorf("expected the plain progress to be displayed with logs")
+	}
+	if Enable(api.ProgressAuto, out) {
+		t.Errorf("expected the progress to be displayed with logs when the output is not a terminal")
+	}
+	if !Enable(api.ProgressTTY, out) {
+		t.Errorf("expected the tty progress to be displayed with status lines")
+	}
+}
+
+func TestTTY(t *testing.T) {
+	out := &bytes.Buffer{}
+	Enable(api.ProgressTTY, out)
+	Step(api.StepPullBuilderImage)
+	Step(api.StepFetchSource)
+	fmt.Fprintln(Output(out), "message")
+	Step(api.StepAssembleBuildScripts)
+	Finish(errors.New("assemble failed"))
+
+	for _, expected := range []string{"✔ Pull builder image", "✔ Fetch sources", "\r\033[Kmessage\n", "✘ Run assemble"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the output, got %q", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "✔ Run assemble") {
+		t.Errorf("expected the assemble step to fail, got %q", out.String())
+	}
+
+	// the progress is no longer displayed
+	out.Reset()
+	Step(api.StepCommitContainer)
+	if out.Len() != 0 {
+		t.Errorf("expected no output after the progress finished, got %q", out.String())
+	}
+}
@@ -1,7 +1,10 @@
 package status
 
 import (
+	"errors"
+
 	"github.com/openshift/source-to-image/pkg/api"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 )
 
 const (
@@ -69,6 +72,13 @@ const (
 	// invalid artifacts mapping of files that need to be copied.
 	ReasonMessageInvalidArtifactsMapping api.StepFailureMessage = "Invalid artifacts mapping specified."
 
+	// ReasonRestoreArtifactsFailed is the reason associated with a failure to
+	// restore the build artifacts of the archive given to the build.
+	ReasonRestoreArtifactsFailed api.StepFailureReason = "RestoreArtifactsFailed"
+	// ReasonMessageRestoreArtifactsFailed is the message associated with a
+	// failure to restore the build artifacts of the archive given to the build.
+	ReasonMessageRestoreArtifactsFailed api.StepFailureMessage = "Failed to restore the build artifacts."
+
 	// ReasonScriptsFetchFailed is the reason associated with a failure to
 	// download specified scripts in the application image.
 	ReasonScriptsFetchFailed api.StepFailureReason = "FetchScriptsFailed"
@@ -98,6 +108,20 @@ const (
 	// install scripts in the builder image.
 	ReasonMessageInstallScriptsFailed api.StepFailureMessage = "Failed to install specified scripts."
 
+	// ReasonBuildQueueTimeout is the reason associated with a build waiting
+	// longer than the queue timeout of the scheduler for a build slot.
+	ReasonBuildQueueTimeout api.StepFailureReason = "BuildQueueTimeout"
+	// ReasonMessageBuildQueueTimeout is the message associated with a build
+	// waiting longer than the queue timeout of the scheduler for a build slot.
+	ReasonMessageBuildQueueTimeout api.StepFailureMessage = "Timed out waiting for a build slot."
+
+	// ReasonBuildCanceled is the reason associated with a build canceled by
+	// its caller while it waited for a build slot.
+	ReasonBuildCanceled api.StepFailureReason = "BuildCanceled"
+	// ReasonMessageBuildCanceled is the message associated with a build
+	// canceled by its caller while it waited for a build slot.
+	ReasonMessageBuildCanceled api.StepFailureMessage = "The build was canceled while waiting for a build slot."
+
 	// ReasonGenericS2IBuildFailed is the reason associated with a broad range of
 	// failures.
 	ReasonGenericS2IBuildFailed api.StepFailureReason = "GenericS2IBuildFailed"
@@ -119,8 +143,156 @@ const (
 	// ReasonMessageAssembleUserForbidden is the failure reason associated with an image that
 	// uses a forbidden AssembleUser.
 	ReasonMessageAssembleUserForbidden api.StepFailureMessage = "Assemble user for S2I build is forbidden."
+
+	// ReasonImageScanFailed is the failure reason associated with a resulting
+	// image that could not be scanned for vulnerabilities, or whose scan found
+	// vulnerabilities exceeding the severity threshold.
+	ReasonImageScanFailed api.StepFailureReason = "ImageScanFailed"
+	// ReasonMessageImageScanFailed is the message associated with a resulting
+	// image that could not be scanned for vulnerabilities, or whose scan found
+	// vulnerabilities exceeding the severity threshold.
+	ReasonMessageImageScanFailed api.StepFailureMessage = "Vulnerability scan of the image failed."
+
+	// ReasonPostCommitTestFailed is the failure reason associated with a
+	// resulting image whose smoke test command did not succeed.
+	ReasonPostCommitTestFailed api.StepFailureReason = "PostCommitTestFailed"
+	// ReasonMessagePostCommitTestFailed is the message associated with a
+	// resulting image whose smoke test command did not succeed.
+	ReasonMessagePostCommitTestFailed api.StepFailureMessage = "Smoke test of the image failed."
+
+	// ReasonRunScriptVerificationFailed is the failure reason associated with
+	// a resulting image whose container did not start or exited with an error.
+	ReasonRunScriptVerificationFailed api.StepFailureReason = "RunScriptVerificationFailed"
+	// ReasonMessageRunScriptVerificationFailed is the message associated with
+	// a resulting image whose container did not start or exited with an error.
+	ReasonMessageRunScriptVerificationFailed api.StepFailureMessage = "Run script of the image failed to start."
+
+	// ReasonPolicyDenied is the failure reason associated with a build denied
+	// by the admission policies, or whose policies could not be evaluated.
+	ReasonPolicyDenied api.StepFailureReason = "PolicyDenied"
+	// ReasonMessagePolicyDenied is the message associated with a build denied
+	// by the admission policies, or whose policies could not be evaluated.
+	ReasonMessagePolicyDenied api.StepFailureMessage = "Build denied by the admission policies."
+
+	// ReasonLockfileMismatch is the failure reason associated with a locked
+	// build whose resolved inputs differ from the lockfile.
+	ReasonLockfileMismatch api.StepFailureReason = "LockfileMismatch"
+	// ReasonMessageLockfileMismatch is the message associated with a locked
+	// build whose resolved inputs differ from the lockfile.
+	ReasonMessageLockfileMismatch api.StepFailureMessage = "Resolved inputs differ from the lockfile."
+
+	// ReasonTagImageFailed is the failure reason associated with a resulting
+	// image which could not be given its additional tags.
+	ReasonTagImageFailed api.StepFailureReason = "TagImageFailed"
+	// ReasonMessageTagImageFailed is the message associated with a resulting
+	// image which could not be given its additional tags.
+	ReasonMessageTagImageFailed api.StepFailureMessage = "Failed to tag the image."
+
+	// ReasonHermeticViolation is the failure reason associated with a
+	// hermetic build whose scripts attempted outbound network access.
+	ReasonHermeticViolation api.StepFailureReason = "HermeticViolation"
+	// ReasonMessageHermeticViolation is the message associated with a
+	// hermetic build whose scripts attempted outbound network access.
+	ReasonMessageHermeticViolation api.StepFailureMessage = "Outbound network access attempted during the hermetic build."
+
+	// ReasonAssembleRuntimeFailed is the reason associated with the
+	// assemble-runtime script failing.
+	ReasonAssembleRuntimeFailed api.StepFailureReason = "AssembleRuntimeFailed"
+	// ReasonMessageAssembleRuntimeFailed is the message associated with the
+	// assemble-runtime script failing.
+	ReasonMessageAssembleRuntimeFailed api.StepFailureMessage = "Assemble-runtime script failed."
+
+	// ReasonInvalidImageLabels is the reason associated with the labels of the
+	// resulting image being invalid.
+	ReasonInvalidImageLabels api.StepFailureReason = "InvalidImageLabels"
+	// ReasonMessageInvalidImageLabels is the message associated with the labels
+	// of the resulting image being invalid.
+	ReasonMessageInvalidImageLabels api.StepFailureMessage = "Invalid labels for the resulting image."
+
+	// ReasonBuilderImageMissingRequirements is the reason associated with the
+	// builder image missing the basic requirements (sh or tar) when the
+	// fallback to a layered build is disabled.
+	ReasonBuilderImageMissingRequirements api.StepFailureReason = "BuilderImageMissingRequirements"
+	// ReasonMessageBuilderImageMissingRequirements is the message associated
+	// with the builder image missing the basic requirements when the fallback
+	// to a layered build is disabled.
+	ReasonMessageBuilderImageMissingRequirements api.StepFailureMessage = "Builder image is missing sh or tar."
+
+	// ReasonInvalidScripts is the reason associated with the scripts provided
+	// by the user having problems which make them fail in the container, such
+	// as CRLF line endings.
+	ReasonInvalidScripts api.StepFailureReason = "InvalidScripts"
+	// ReasonMessageInvalidScripts is the message associated with the scripts
+	// provided by the user having problems which make them fail in the
+	// container.
+	ReasonMessageInvalidScripts api.StepFailureMessage = "Scripts would fail in the container."
+
+	// ReasonDestinationNotWritable is the reason associated with the assemble
+	// user being unable to write the scripts and sources to the destination
+	// directory of the builder image.
+	ReasonDestinationNotWritable api.StepFailureReason = "DestinationNotWritable"
+	// ReasonMessageDestinationNotWritable is the message associated with the
+	// assemble user being unable to write to the destination directory.
+	ReasonMessageDestinationNotWritable api.StepFailureMessage = "Destination directory of the builder image is not writable."
+
+	// ReasonInsufficientDiskSpace is the reason associated with a file system
+	// lacking the space or inodes the build is estimated to need.
+	ReasonInsufficientDiskSpace api.StepFailureReason = "InsufficientDiskSpace"
+	// ReasonMessageInsufficientDiskSpace is the message associated with a file
+	// system lacking the space or inodes the build is estimated to need.
+	ReasonMessageInsufficientDiskSpace api.StepFailureMessage = "Not enough disk space for the build."
+
+	// ReasonRenderTemplatesFailed is the reason associated with a failure to
+	// render the templates of the sources.
+	ReasonRenderTemplatesFailed api.StepFailureReason = "RenderTemplatesFailed"
+	// ReasonMessageRenderTemplatesFailed is the message associated with a
+	// failure to render the templates of the sources.
+	ReasonMessageRenderTemplatesFailed api.StepFailureMessage = "Failed to render the templates of the sources."
+
+	// ReasonQuotaExceeded is the reason associated with a build running longer
+	// than its maximum duration or committing a layer larger than its maximum
+	// size.
+	ReasonQuotaExceeded api.StepFailureReason = "QuotaExceeded"
+	// ReasonMessageQuotaExceeded is the message associated with a build
+	// exceeding its maximum duration or output size.
+	ReasonMessageQuotaExceeded api.StepFailureMessage = "Build exceeded its maximum duration or output size."
 )
 
+// FailureError is an error of a build which failed for the given reason, so
+// that the callers of the builds can tell the failures apart without matching
+// their messages.
+type FailureError struct {
+	Err           error
+	FailureReason api.FailureReason
+}
+
+// Error returns a string for the given error
+func (e FailureError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of the build.
+func (e FailureError) Unwrap() error {
+	return e.Err
+}
+
+// NewFailureError attaches the reason a build failed for to its error.
+func NewFailureError(reason api.FailureReason, err error) error {
+	return FailureError{
+		Err:           err,
+		FailureReason: reason,
+	}
+}
+
+// FailureReasonOf returns the reason attached to the error of a build, if any.
+func FailureReasonOf(err error) (api.FailureReason, bool) {
+	var e FailureError
+	if errors.As(err, &e) {
+		return e.FailureReason, true
+	}
+	return api.FailureReason{}, false
+}
+
 // NewFailureReason initializes a new failure reason that contains both the
 // reason and a message to be displayed.
 func NewFailureReason(reason api.StepFailureReason, message api.StepFailureMessage) api.FailureReason {
@@ -129,3 +301,24 @@ func NewFailureReason(reason api.StepFailureReason, message api.StepFailureMessa
 		Message: message,
 	}
 }
+
+// exitCodes are the exit codes of the builds, by failure reason.
+var exitCodes = map[api.StepFailureReason]int{
+	ReasonPullBuilderImageFailed:  s2ierr.ExitCodePull,
+	ReasonPullRuntimeImageFailed:  s2ierr.ExitCodePull,
+	ReasonPullPreviousImageFailed: s2ierr.ExitCodePull,
+	ReasonFetchSourceFailed:       s2ierr.ExitCodeClone,
+	ReasonAssembleFailed:          s2ierr.ExitCodeAssemble,
+	ReasonAssembleRuntimeFailed:   s2ierr.ExitCodeAssemble,
+	ReasonCommitContainerFailed:   s2ierr.ExitCodeCommit,
+	ReasonQuotaExceeded:           s2ierr.ExitCodeQuotaExceeded,
+}
+
+// ExitCode returns the exit code of the class of failures of a build which
+// failed for the given reason.
+func ExitCode(reason api.StepFailureReason) int {
+	if exitCode, ok := exitCodes[reason]; ok {
+		return exitCode
+	}
+	return s2ierr.ExitCodeFailure
+}
@@ -1,10 +1,13 @@
 package status
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/openshift/source-to-image/pkg/api"
+	s2ierr "github.com/openshift/source-to-image/pkg/errors"
 )
 
 func TestNewFailureReason(t *testing.T) {
@@ -75,3 +78,30 @@ func TestUpdateStageDuration(t *testing.T) {
 	}
 
 }
+
+func TestExitCode(t *testing.T) {
+	tests := map[api.StepFailureReason]int{
+		ReasonPullRuntimeImageFailed: s2ierr.ExitCodePull,
+		ReasonFetchSourceFailed:      s2ierr.ExitCodeClone,
+		ReasonAssembleFailed:         s2ierr.ExitCodeAssemble,
+		ReasonCommitContainerFailed:  s2ierr.ExitCodeCommit,
+		ReasonImageScanFailed:        s2ierr.ExitCodeFailure,
+		"":                           s2ierr.ExitCodeFailure,
+	}
+	for reason, expected := range tests {
+		if exitCode := ExitCode(reason); exitCode != expected {
+			t.Errorf("Expected exit code %d for reason %q, got %d", expected, reason, exitCode)
+		}
+	}
+}
+
+func TestFailureReasonOf(t *testing.T) {
+	reason := NewFailureReason(ReasonFetchSourceFailed, ReasonMessageFetchSourceFailed)
+	err := fmt.Errorf("build failed: %w", NewFailureError(reason, errors.New("repository not found")))
+	if got, ok := FailureReasonOf(err); !ok || got != reason {
+		t.Errorf("Expected the reason %v, got %v", reason, got)
+	}
+	if _, ok := FailureReasonOf(errors.New("repository not found")); ok {
+		t.Errorf("Expected no reason for an error without any")
+	}
+}
@@ -1,5 +1,7 @@
 package util
 
+import "strings"
+
 // Includes determines if the given string is in the provided slice of strings.
 func Includes(arr []string, str string) bool {
 	for _, s := range arr {
@@ -19,3 +21,16 @@ func FirstNonEmpty(args ...string) string {
 	}
 	return ""
 }
+
+// EscapeDockerfileValue returns the passed-in value, escaped so that it will
+// not undergo expansion within double quotes in a Dockerfile instruction.
+func EscapeDockerfileValue(value string) string {
+	result := ""
+	for _, ch := range value {
+		if strings.ContainsRune(`$"\`, ch) {
+			result += `\`
+		}
+		result += string(ch)
+	}
+	return result
+}
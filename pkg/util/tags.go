@@ -0,0 +1,138 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/distribution/reference"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+// maxTagLength is the maximum length of the tag of an image.
+const maxTagLength = 128
+
+var invalidTagCharacters = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// TagTemplateData holds the fields the tag templates of a build can refer
+// to.
+type TagTemplateData struct {
+	// Repo is the repository of the tag of the build, e.g. quay.io/user/app.
+	Repo string
+	// Tag is the tag part of the tag of the build, e.g. latest.
+	Tag string
+	// GitSHA is the commit the sources were built from.
+	GitSHA string
+	// GitShortSHA is the abbreviated commit the sources were built from.
+	GitShortSHA string
+	// GitBranch is the reference the sources were built from, with its slashes
+	// replaced by dashes.
+	GitBranch string
+	// Timestamp is the UTC time of the build, in the YYYYMMDDhhmmss format.
+	Timestamp string
+}
+
+// NewTagTemplateData returns the data the tag templates of a build tagged
+// tag, from the sources described by info, are rendered with.
+func NewTagTemplateData(tag string, info *git.SourceInfo) (*TagTemplateData, error) {
+	named, err := reference.ParseNormalizedNamed(tag)
+	if err != nil {
+		return nil, err
+	}
+	data := &TagTemplateData{
+		Repo:      reference.FamiliarName(named),
+		Tag:       "latest",
+		Timestamp: time.Now().UTC().Format("20060102150405"),
+	}
+	if tagged, ok := named.(reference.Tagged); ok {
+		data.Tag = tagged.Tag()
+	}
+	if info != nil {
+		data.GitSHA = info.CommitID
+		data.GitShortSHA = info.CommitID
+		if len(data.GitShortSHA) > 7 {
+			data.GitShortSHA = data.GitShortSHA[:7]
+		}
+		data.GitBranch = strings.ReplaceAll(info.Ref, "/", "-")
+	}
+	return data, nil
+}
+
+// AutoTag returns tag, with its tag part replaced by the one derived from the
+// source information according to mode.
+func AutoTag(tag string, mode api.AutoTagMode, info *git.SourceInfo) (string, error) {
+	named, err := reference.ParseNormalizedNamed(tag)
+	if err != nil {
+		return "", err
+	}
+	if info == nil {
+		return "", fmt.Errorf("the image cannot be tagged from the %s of the sources, which are not a Git repository", mode)
+	}
+	var derived string
+	switch mode {
+	case api.AutoTagGitDescribe:
+		derived = info.Describe
+	case api.AutoTagCommit:
+		derived = info.CommitID
+		if len(derived) > 7 {
+			derived = derived[:7]
+		}
+	case api.AutoTagBranch:
+		// the sources are not on a branch when HEAD is detached
+		if info.Ref != "HEAD" {
+			derived = info.Ref
+		}
+	default:
+		return "", fmt.Errorf("invalid auto tag mode %q", mode)
+	}
+	derived = SanitizeTag(derived)
+	if len(derived) == 0 {
+		return "", fmt.Errorf("the image cannot be tagged from the %s of the sources, which is unknown", mode)
+	}
+	return reference.FamiliarName(named) + ":" + derived, nil
+}
+
+// SanitizeTag replaces the characters not allowed in the tag of an image by
+// dashes, and truncates it to the maximum length of a tag.
+func SanitizeTag(tag string) string {
+	sanitized := []rune(strings.TrimLeft(invalidTagCharacters.ReplaceAllString(tag, "-"), ".-"))
+	if len(sanitized) > maxTagLength {
+		sanitized = sanitized[:maxTagLength]
+	}
+	return string(sanitized)
+}
+
+// AdditionalImageTags returns the tags applied to the image built from config
+// besides config.Tag: its additional tags, followed by its tag templates
+// rendered with the source information.
+func AdditionalImageTags(config *api.Config, info *git.SourceInfo) ([]string, error) {
+	tags := append([]string{}, config.AdditionalTags...)
+	if len(config.TagTemplates) == 0 {
+		return tags, nil
+	}
+	data, err := NewTagTemplateData(config.Tag, info)
+	if err != nil {
+		return nil, err
+	}
+	for _, text := range config.TagTemplates {
+		tmpl, err := template.New("tag").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag template %q: %v", text, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, data); err != nil {
+			return nil, fmt.Errorf("unable to render the tag template %q: %v", text, err)
+		}
+		tag := buf.String()
+		if _, err := reference.ParseNormalizedNamed(tag); err != nil {
+			return nil, fmt.Errorf("the tag template %q rendered the invalid tag %q: %v", text, tag, err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/scm/git"
+)
+
+func TestAdditionalImageTags(t *testing.T) {
+	info := &git.SourceInfo{CommitID: "0123456789abcdef", Ref: "feature/tags"}
+	tests := []struct {
+		config   *api.Config
+		info     *git.SourceInfo
+		expected []string
+		err      bool
+	}{
+		{
+			config:   &api.Config{Tag: "app", AdditionalTags: []string{"app:v1", "registry:5000/app"}},
+			expected: []string{"app:v1", "registry:5000/app"},
+		},
+		{
+			config:   &api.Config{Tag: "registry:5000/team/app:v2", TagTemplates: []string{"{{.Repo}}:{{.Tag}}-{{.GitShortSHA}}", "{{.Repo}}:{{.GitBranch}}", "mirror/app:{{.GitSHA}}"}},
+			info:     info,
+			expected: []string{"registry:5000/team/app:v2-0123456", "registry:5000/team/app:feature-tags", "mirror/app:0123456789abcdef"},
+		},
+		{
+			// the commit is not known
+			config: &api.Config{Tag: "app", TagTemplates: []string{"{{.Repo}}:{{.GitShortSHA}}"}},
+			err:    true,
+		},
+		{
+			config: &api.Config{Tag: "app", TagTemplates: []string{"{{.Repository}}"}},
+			info:   info,
+			err:    true,
+		},
+	}
+	for i, test := range tests {
+		tags, err := AdditionalImageTags(test.config, test.info)
+		if (err != nil) != test.err {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !test.err && !reflect.DeepEqual(tags, test.expected) {
+			t.Errorf("%d: expected tags %v, got %v", i, test.expected, tags)
+		}
+	}
+}
+
+func TestAutoTag(t *testing.T) {
+	info := &git.SourceInfo{CommitID: "0123456789abcdef", Ref: "feature/auto+tag", Describe: "v1.2.0-3-g0123456"}
+	tests := []struct {
+		tag      string
+		mode     api.AutoTagMode
+		info     *git.SourceInfo
+		expected string
+		err      bool
+	}{
+		{tag: "quay.io/user/app", mode: api.AutoTagGitDescribe, info: info, expected: "quay.io/user/app:v1.2.0-3-g0123456"},
+		{tag: "app:latest", mode: api.AutoTagCommit, info: info, expected: "app:0123456"},
+		{tag: "registry:5000/app", mode: api.AutoTagBranch, info: info, expected: "registry:5000/app:feature-auto-tag"},
+		{tag: "app", mode: api.AutoTagBranch, info: &git.SourceInfo{Ref: "HEAD"}, err: true},
+		{tag: "app", mode: api.AutoTagCommit, err: true},
+	}
+	for i, test := range tests {
+		tag, err := AutoTag(test.tag, test.mode, test.info)
+		if (err != nil) != test.err {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if tag != test.expected {
+			t.Errorf("%d: expected tag %q, got %q", i, test.expected, tag)
+		}
+	}
+}
+
+func TestSanitizeTag(t *testing.T) {
+	for tag, expected := range map[string]string{
+		"v1.0.0":                 "v1.0.0",
+		"feature/x":              "feature-x",
+		"-.release 1.0~rc1":      "release-1.0-rc1",
+		"__init":                 "__init",
+		strings.Repeat("a", 200): strings.Repeat("a", 128),
+	} {
+		if sanitized := SanitizeTag(tag); sanitized != expected {
+			t.Errorf("expected %q to be sanitized as %q, got %q", tag, expected, sanitized)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package telemetry reports the anonymized usage of the builds to the
+// endpoint the user opted in to.
+package telemetry
@@ -0,0 +1,121 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/version"
+)
+
+// timeout is the timeout of the post of a report, which must not hold the
+// build back.
+const timeout = 5 * time.Second
+
+// durationBuckets are the upper bounds of the buckets the durations of the
+// builds are reported in.
+var durationBuckets = []struct {
+	max  time.Duration
+	name string
+}{
+	{time.Minute, "<1m"},
+	{5 * time.Minute, "1m-5m"},
+	{15 * time.Minute, "5m-15m"},
+	{time.Hour, "15m-1h"},
+}
+
+// Report is the anonymized usage report of a build. It only holds counters
+// and classes, never the names of the images, the sources or the host.
+type Report struct {
+	// Version is the version of s2i.
+	Version string `json:"version"`
+	// OS and Arch are the platform of s2i.
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Engine is the container engine of the build: docker or containerd.
+	Engine string `json:"engine"`
+	// Strategy is the strategy of the build: source, layered, onbuild or
+	// dockerfile.
+	Strategy string `json:"strategy"`
+	// Incremental is true for the incremental builds.
+	Incremental bool `json:"incremental"`
+	// RuntimeImage is true for the builds with a runtime image.
+	RuntimeImage bool `json:"runtimeImage"`
+	// Result is Success, or the reason the build failed for.
+	Result string `json:"result"`
+	// Duration is the bucket of the duration of the build: <1m, 1m-5m,
+	// 5m-15m, 15m-1h or >1h.
+	Duration string `json:"duration"`
+}
+
+// NewReport returns the report of the build of the given configuration and
+// information, which ran for the given duration.
+func NewReport(config *api.Config, info api.BuildInfo, duration time.Duration) *Report {
+	report := &Report{
+		Version:      version.Get().String(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Engine:       string(api.EngineDocker),
+		Strategy:     strategy(config, info),
+		Incremental:  config.Incremental,
+		RuntimeImage: len(config.RuntimeImage) > 0,
+		Result:       "Success",
+		Duration:     ">1h",
+	}
+	if config.DockerConfig != nil {
+		report.Engine = config.DockerConfig.Engine.String()
+	}
+	if len(info.FailureReason.Reason) > 0 {
+		report.Result = string(info.FailureReason.Reason)
+	}
+	for _, bucket := range durationBuckets {
+		if duration < bucket.max {
+			report.Duration = bucket.name
+			break
+		}
+	}
+	return report
+}
+
+// strategy returns the strategy of the build of the given configuration and
+// information.
+func strategy(config *api.Config, info api.BuildInfo) string {
+	switch {
+	case len(config.AsDockerfile) > 0 || config.Strategy == api.StrategyDockerfile:
+		return "dockerfile"
+	case config.Strategy == api.StrategyOnBuild:
+		return "onbuild"
+	case config.HasOnBuild && !config.BlockOnBuild && config.Strategy != api.StrategySource:
+		return "onbuild"
+	}
+	for _, stage := range info.Stages {
+		for _, step := range stage.Steps {
+			if step.Name == api.StepLayeredFallback {
+				return "layered"
+			}
+		}
+	}
+	return "source"
+}
+
+// Send posts the given report as JSON to the given endpoint.
+func Send(endpoint string, report *Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %s", endpoint, resp.Status)
+	}
+	return nil
+}
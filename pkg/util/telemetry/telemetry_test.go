@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+)
+
+func TestNewReport(t *testing.T) {
+	start := time.Now()
+	layered := api.RecordStageAndStepInfo(nil, api.StageBuild, api.StepLayeredFallback, start, start.Add(time.Second))
+	tests := map[string]struct {
+		config   *api.Config
+		info     api.BuildInfo
+		duration time.Duration
+		expected Report
+	}{
+		"source": {
+			config:   &api.Config{BuilderImage: "registry.example.com/builder", Incremental: true, DockerConfig: &api.DockerConfig{}},
+			duration: 30 * time.Second,
+			expected: Report{Engine: "docker", Strategy: "source", Incremental: true, Result: "Success", Duration: "<1m"},
+		},
+		"layered failure": {
+			config: &api.Config{RuntimeImage: "runtime", DockerConfig: &api.DockerConfig{Engine: api.EngineContainerd}},
+			info: api.BuildInfo{
+				Stages:        layered,
+				FailureReason: utilstatus.NewFailureReason(utilstatus.ReasonAssembleFailed, utilstatus.ReasonMessageAssembleFailed),
+			},
+			duration: 10 * time.Minute,
+			expected: Report{Engine: "containerd", Strategy: "layered", RuntimeImage: true, Result: "AssembleFailed", Duration: "5m-15m"},
+		},
+		"onbuild": {
+			config:   &api.Config{HasOnBuild: true},
+			duration: 2 * time.Hour,
+			expected: Report{Engine: "docker", Strategy: "onbuild", Result: "Success", Duration: ">1h"},
+		},
+		"forced source": {
+			config:   &api.Config{HasOnBuild: true, Strategy: api.StrategySource},
+			duration: time.Second,
+			expected: Report{Engine: "docker", Strategy: "source", Result: "Success", Duration: "<1m"},
+		},
+		"dockerfile": {
+			config:   &api.Config{AsDockerfile: "Dockerfile", HasOnBuild: true},
+			duration: time.Minute,
+			expected: Report{Engine: "docker", Strategy: "dockerfile", Result: "Success", Duration: "1m-5m"},
+		},
+	}
+	for desc, tc := range tests {
+		report := NewReport(tc.config, tc.info, tc.duration)
+		if len(report.Version) == 0 || len(report.OS) == 0 || len(report.Arch) == 0 {
+			t.Errorf("%s: expected the version and platform of s2i, got %+v", desc, report)
+		}
+		report.Version, report.OS, report.Arch = "", "", ""
+		if *report != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v", desc, tc.expected, *report)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Unable to decode the report: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	report := NewReport(&api.Config{BuilderImage: "registry.example.com/builder"}, api.BuildInfo{}, time.Second)
+	if err := Send(server.URL, report); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, field := range []string{"version", "os", "arch", "engine", "strategy", "incremental", "runtimeImage", "result", "duration"} {
+		if _, ok := received[field]; !ok {
+			t.Errorf("Expected the report to have the %s field", field)
+		}
+	}
+	if len(received) != 9 {
+		t.Errorf("Expected the report to only have the anonymized fields, got %v", received)
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	if err := Send(server.URL, report); err == nil {
+		t.Errorf("Expected an error for a failed post")
+	}
+}
@@ -0,0 +1,3 @@
+// Package tracing exports the timing of the build stages as OpenTelemetry
+// traces.
+package tracing
@@ -0,0 +1,349 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	"github.com/openshift/source-to-image/pkg/version"
+)
+
+const (
+	// scopeName is the instrumentation scope of the exported spans.
+	scopeName = "github.com/openshift/source-to-image"
+
+	// defaultServiceName is the service name of the exported spans, unless
+	// set by OTEL_SERVICE_NAME.
+	defaultServiceName = "s2i"
+
+	// defaultTimeout is the timeout of the export, unless set in milliseconds
+	// by OTEL_EXPORTER_OTLP_TIMEOUT.
+	defaultTimeout = 10 * time.Second
+
+	// span kinds and status codes, as defined by OTLP
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+// getenv returns the value of the first of the given environment variables
+// which is set.
+func getenv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+// endpoint returns the URL the traces are sent to, or an empty string if no
+// OTLP endpoint is configured.
+func endpoint() string {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return ""
+	}
+	if traces := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); len(traces) > 0 {
+		return traces
+	}
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); len(base) > 0 {
+		return strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// Enabled returns true if the traces of the builds are exported, that is if
+// an OTLP endpoint is set by the OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment variables.
+func Enabled() bool {
+	return len(endpoint()) > 0
+}
+
+// ExportBuild exports the stages and steps of the given build, which ran from
+// start to end, as OpenTelemetry spans. The spans are sent to the OTLP/HTTP
+// endpoint configured by the standard OTEL_EXPORTER_OTLP_* environment
+// variables, using the JSON encoding. The trace is continued from the W3C
+// trace context set in the TRACEPARENT environment variable, if any, so that
+// the builds can be part of the traces of CI pipelines. Nothing is exported
+// unless an endpoint is set.
+func ExportBuild(config *api.Config, result *api.Result, start, end time.Time) error {
+	target := endpoint()
+	if len(target) == 0 {
+		return nil
+	}
+	if protocol := getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"); protocol == "grpc" {
+		return fmt.Errorf("the %s OTLP protocol is not supported, use http/json", protocol)
+	}
+	headers, err := parseKeyValues(getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", "OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return fmt.Errorf("invalid OTLP headers: %v", err)
+	}
+	timeout := defaultTimeout
+	if value := getenv("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "OTEL_EXPORTER_OTLP_TIMEOUT"); len(value) > 0 {
+		ms, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid OTLP timeout %q: %v", value, err)
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	request, err := newExportRequest(config, result, start, end)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("the OTLP endpoint %s returned %s: %s", target, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// parseKeyValues parses a list of comma-separated key=value pairs, whose
+// values are URL encoded, as used by the OTEL_EXPORTER_OTLP_HEADERS and
+// OTEL_RESOURCE_ATTRIBUTES environment variables.
+func parseKeyValues(list string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range strings.Split(list, ",") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, fmt.Errorf("%q is not in the key=value format", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		values[strings.TrimSpace(parts[0])] = value
+	}
+	return values, nil
+}
+
+// exportRequest is the JSON encoding of an OTLP ExportTraceServiceRequest.
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            *status    `json:"status,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttribute(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+func boolAttribute(key string, value bool) keyValue {
+	return keyValue{Key: key, Value: anyValue{BoolValue: &value}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// newSpanID returns a new random span ID.
+func newSpanID() (trace.SpanID, error) {
+	id := trace.SpanID{}
+	_, err := rand.Read(id[:])
+	return id, err
+}
+
+// parentSpanContext returns the span context set in the TRACEPARENT and
+// TRACESTATE environment variables, which is invalid if none is set.
+func parentSpanContext() trace.SpanContext {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(ctx)
+}
+
+// newExportRequest returns the request exporting the spans of the given
+// build: a root span for the whole build, a child span for each stage, and a
+// grandchild span for each step.
+func newExportRequest(config *api.Config, result *api.Result, start, end time.Time) (*exportRequest, error) {
+	traceID := trace.TraceID{}
+	parentID := ""
+	if parent := parentSpanContext(); parent.IsValid() {
+		traceID = parent.TraceID()
+		parentID = parent.SpanID().String()
+	} else if _, err := rand.Read(traceID[:]); err != nil {
+		return nil, err
+	}
+	rootID, err := newSpanID()
+	if err != nil {
+		return nil, err
+	}
+
+	root := span{
+		TraceID:           traceID.String(),
+		SpanID:            rootID.String(),
+		ParentSpanID:      parentID,
+		Name:              "s2i build",
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(end),
+		Attributes: []keyValue{
+			stringAttribute("s2i.builder_image", config.BuilderImage),
+			stringAttribute("s2i.tag", config.Tag),
+			boolAttribute("s2i.incremental", config.Incremental),
+		},
+		Status: &status{Code: statusCodeOK},
+	}
+	if len(config.RuntimeImage) > 0 {
+		root.Attributes = append(root.Attributes, stringAttribute("s2i.runtime_image", config.RuntimeImage))
+	}
+	if result != nil && len(result.ImageID) > 0 {
+		root.Attributes = append(root.Attributes, stringAttribute("s2i.image_id", result.ImageID))
+	}
+	if result == nil || !result.Success {
+		root.Status = &status{Code: statusCodeError, Message: "build failed"}
+		if result != nil && len(result.BuildInfo.FailureReason.Reason) > 0 {
+			reason := result.BuildInfo.FailureReason
+			root.Status.Message = string(reason.Message)
+			root.Attributes = append(root.Attributes, stringAttribute("s2i.failure_reason", string(reason.Reason)))
+		}
+	}
+
+	spans := []span{root}
+	if result != nil {
+		for _, stage := range result.BuildInfo.Stages {
+			stageID, err := newSpanID()
+			if err != nil {
+				return nil, err
+			}
+			spans = append(spans, span{
+				TraceID:           root.TraceID,
+				SpanID:            stageID.String(),
+				ParentSpanID:      root.SpanID,
+				Name:              string(stage.Name),
+				Kind:              spanKindInternal,
+				StartTimeUnixNano: unixNano(stage.StartTime),
+				EndTimeUnixNano:   unixNano(stage.StartTime.Add(time.Duration(stage.DurationMilliseconds) * time.Millisecond)),
+			})
+			for _, step := range stage.Steps {
+				stepID, err := newSpanID()
+				if err != nil {
+					return nil, err
+				}
+				spans = append(spans, span{
+					TraceID:           root.TraceID,
+					SpanID:            stepID.String(),
+					ParentSpanID:      stageID.String(),
+					Name:              string(step.Name),
+					Kind:              spanKindInternal,
+					StartTimeUnixNano: unixNano(step.StartTime),
+					EndTimeUnixNano:   unixNano(step.StartTime.Add(time.Duration(step.DurationMilliseconds) * time.Millisecond)),
+				})
+			}
+		}
+	}
+
+	resourceAttributes, err := parseKeyValues(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %v", err)
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); len(name) > 0 {
+		resourceAttributes["service.name"] = name
+	} else if _, ok := resourceAttributes["service.name"]; !ok {
+		resourceAttributes["service.name"] = defaultServiceName
+	}
+	res := resource{}
+	for _, key := range sortedKeys(resourceAttributes) {
+		res.Attributes = append(res.Attributes, stringAttribute(key, resourceAttributes[key]))
+	}
+
+	return &exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: res,
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: scopeName, Version: version.Get().GitVersion},
+			Spans: spans,
+		}},
+	}}}, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
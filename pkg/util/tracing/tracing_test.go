@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/source-to-image/pkg/api"
+	utilstatus "github.com/openshift/source-to-image/pkg/util/status"
+)
+
+func TestExportBuild(t *testing.T) {
+	var request exportRequest
+	var path, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Unable to decode the request: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL+"/")
+	t.Setenv("OTEL_SDK_DISABLED", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "ci.pipeline=app")
+	t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	t.Setenv("TRACESTATE", "")
+	if !Enabled() {
+		t.Fatalf("Expected the export to be enabled")
+	}
+
+	start := time.Now()
+	stages := api.RecordStageAndStepInfo(nil, api.StagePullImages, api.StepPullBuilderImage, start, start.Add(time.Second))
+	stages = api.RecordStageAndStepInfo(stages, api.StageAssemble, api.StepAssembleBuildScripts, start.Add(time.Second), start.Add(3*time.Second))
+	result := &api.Result{
+		BuildInfo: api.BuildInfo{
+			Stages:        stages,
+			FailureReason: utilstatus.NewFailureReason(utilstatus.ReasonAssembleFailed, utilstatus.ReasonMessageAssembleFailed),
+		},
+	}
+	config := &api.Config{BuilderImage: "builder", Tag: "app"}
+	if err := ExportBuild(config, result, start, start.Add(4*time.Second)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if path != "/v1/traces" {
+		t.Errorf("Expected the spans to be sent to /v1/traces, got %s", path)
+	}
+	if authorization != "Bearer token" {
+		t.Errorf("Expected the Authorization header to be set, got %q", authorization)
+	}
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Unexpected request %#v", request)
+	}
+	attributes := map[string]string{}
+	for _, attribute := range request.ResourceSpans[0].Resource.Attributes {
+		attributes[attribute.Key] = *attribute.Value.StringValue
+	}
+	if attributes["service.name"] != "s2i" || attributes["ci.pipeline"] != "app" {
+		t.Errorf("Unexpected resource attributes %v", attributes)
+	}
+
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 spans, got %d", len(spans))
+	}
+	root := spans[0]
+	if root.TraceID != "0af7651916cd43dd8448eb211c80319c" || root.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("Expected the root span to continue the TRACEPARENT trace, got %#v", root)
+	}
+	if root.Status == nil || root.Status.Code != statusCodeError || root.Status.Message != string(utilstatus.ReasonMessageAssembleFailed) {
+		t.Errorf("Expected the root span to report the failure, got %#v", root.Status)
+	}
+	expected := []struct {
+		name   string
+		parent string
+	}{
+		{string(api.StagePullImages), root.SpanID},
+		{string(api.StepPullBuilderImage), spans[1].SpanID},
+		{string(api.StageAssemble), root.SpanID},
+		{string(api.StepAssembleBuildScripts), spans[3].SpanID},
+	}
+	for i, e := range expected {
+		s := spans[i+1]
+		if s.Name != e.name || s.ParentSpanID != e.parent || s.TraceID != root.TraceID {
+			t.Errorf("Expected span %s with parent %s, got %#v", e.name, e.parent, s)
+		}
+	}
+	if spans[3].EndTimeUnixNano != unixNano(start.Add(3*time.Second)) {
+		t.Errorf("Expected the %s span to end at %s, got %s", spans[3].Name, unixNano(start.Add(3*time.Second)), spans[3].EndTimeUnixNano)
+	}
+}
+
+func TestExportBuildDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if Enabled() {
+		t.Errorf("Expected the export to be disabled")
+	}
+	if err := ExportBuild(&api.Config{}, &api.Result{}, time.Now(), time.Now()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	if Enabled() {
+		t.Errorf("Expected the export to be disabled by OTEL_SDK_DISABLED")
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	values, err := parseKeyValues("a=1, b = x%3Dy ,")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(values) != 2 || values["a"] != "1" || values["b"] != "x=y" {
+		t.Errorf("Unexpected values %v", values)
+	}
+	if _, err := parseKeyValues("a"); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
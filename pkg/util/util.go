@@ -9,10 +9,10 @@ import (
 var log = utillog.StderrLog
 
 // SafeForLoggingContainerConfig returns a copy of the container.Config object
-// with sensitive information (proxy environment variables containing credentials)
-// redacted.
-func SafeForLoggingContainerConfig(config *container.Config) *container.Config {
-	strippedEnv := SafeForLoggingEnv(config.Env)
+// with sensitive information (proxy environment variables containing credentials
+// and the variables matching the redaction patterns) redacted.
+func SafeForLoggingContainerConfig(config *container.Config, patterns []string) *container.Config {
+	strippedEnv := SafeForLoggingEnv(config.Env, patterns)
 	newConfig := *config
 	newConfig.Env = strippedEnv
 	return &newConfig
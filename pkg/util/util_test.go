@@ -19,7 +19,7 @@ func TestSafeForLoggingContainerConfig(t *testing.T) {
 	}
 	orig := fmt.Sprintf("%+v", *c)
 
-	s := fmt.Sprintf("%+v", *SafeForLoggingContainerConfig(c))
+	s := fmt.Sprintf("%+v", *SafeForLoggingContainerConfig(c, nil))
 	if strings.Contains(s, "user:password") {
 		t.Errorf("expected %s to not contain credentials", s)
 	}
@@ -0,0 +1,2 @@
+// Package watch detects the changes made to the files of a source tree.
+package watch
@@ -0,0 +1,243 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	utillog "github.com/openshift/source-to-image/pkg/util/log"
+)
+
+var log = utillog.StderrLog
+
+// IgnoreFunc returns true if the given slash separated path, relative to the
+// root of the watched tree, is ignored.
+type IgnoreFunc func(path string, isDir bool) bool
+
+// fileState is the state of a file compared between two scans of the tree.
+type fileState struct {
+	size    int64
+	modTime time.Time
+	mode    os.FileMode
+}
+
+// equal returns true if the file did not change between the two states.
+func (s fileState) equal(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime) && s.mode == other.mode
+}
+
+// Watcher detects the files created, modified or removed in a directory tree
+// by comparing their size and modification time. The tree is compared when the
+// file system notifies a change in one of its directories or, when the
+// notifications are unavailable (e.g. on network file systems or when the
+// limit of watched directories is reached), periodically. The .git directories
+// are ignored.
+type Watcher struct {
+	dir      string
+	interval time.Duration
+	ignored  IgnoreFunc
+	files    map[string]fileState
+	// notify is nil when the tree is polled
+	notify *fsnotify.Watcher
+}
+
+// New returns a Watcher of the given directory. The interval is how long Wait
+// waits for more changes, and how often the tree is scanned when it is polled.
+// The ignore function may be nil.
+func New(dir string, interval time.Duration, ignored IgnoreFunc) (*Watcher, error) {
+	w := &Watcher{
+		dir:      dir,
+		interval: interval,
+		ignored:  ignored,
+	}
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.files = files
+	if w.notify, err = fsnotify.NewWatcher(); err == nil {
+		err = w.watchDirs()
+	}
+	if err != nil {
+		log.V(1).Infof("Unable to watch %s for file system notifications, polling it every %s instead: %v", dir, interval, err)
+		w.Close()
+	}
+	return w, nil
+}
+
+// Close stops the file system notifications of the Watcher.
+func (w *Watcher) Close() error {
+	if w.notify == nil {
+		return nil
+	}
+	err := w.notify.Close()
+	w.notify = nil
+	return err
+}
+
+// watchDirs watches the root of the tree and the directories found by the last
+// scan for file system notifications. The removed directories are unwatched
+// by the file system.
+func (w *Watcher) watchDirs() error {
+	if err := w.notify.Add(w.dir); err != nil {
+		return err
+	}
+	watched := map[string]bool{}
+	for _, path := range w.notify.WatchList() {
+		watched[path] = true
+	}
+	for path, state := range w.files {
+		path = filepath.Join(w.dir, filepath.FromSlash(path))
+		if !state.mode.IsDir() || watched[path] {
+			continue
+		}
+		// directories removed since the scan are reported by the next one
+		if err := w.notify.Add(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan returns the state of the files of the tree, keyed by their slash
+// separated path relative to its root.
+func (w *Watcher) scan() (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.Walk(w.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// files removed while walking the tree are reported by the next scan
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(w.dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if w.ignored != nil && w.ignored(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		state := fileState{mode: info.Mode()}
+		// the size and modification time of directories change along with
+		// their content, which is already compared
+		if !info.IsDir() {
+			state.size = info.Size()
+			state.modTime = info.ModTime()
+		}
+		files[rel] = state
+		return nil
+	})
+	return files, err
+}
+
+// Changed returns the sorted paths of the files created, modified or removed
+// since the previous call, or since the Watcher was created.
+func (w *Watcher) Changed() ([]string, error) {
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	changed := []string{}
+	for path, state := range files {
+		if previous, ok := w.files[path]; !ok || !previous.equal(state) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.files {
+		if _, ok := files[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	w.files = files
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// Wait blocks until files change, and returns their sorted paths once no more
+// changes are detected for one interval, so that a burst of changes (e.g. an
+// editor saving many files, or a git checkout) is reported once.
+func (w *Watcher) Wait() ([]string, error) {
+	changed := map[string]bool{}
+	for {
+		if err := w.waitEvents(len(changed) > 0); err != nil {
+			return nil, err
+		}
+		paths, err := w.Changed()
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 && len(changed) > 0 {
+			break
+		}
+		for _, path := range paths {
+			changed[path] = true
+		}
+		if w.notify != nil {
+			// the directories created since the previous scan
+			if err := w.watchDirs(); err != nil {
+				log.V(1).Infof("Unable to watch %s for file system notifications, polling it every %s instead: %v", w.dir, w.interval, err)
+				w.Close()
+			}
+		}
+	}
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// waitEvents blocks until the tree may have changed. Without file system
+// notifications, it waits for one interval. Otherwise it waits for a first
+// notification, unless changes are already pending, and then until no
+// notification is received for one interval.
+func (w *Watcher) waitEvents(pending bool) error {
+	if w.notify == nil {
+		time.Sleep(w.interval)
+		return nil
+	}
+	if !pending {
+		select {
+		case _, ok := <-w.notify.Events:
+			if !ok {
+				return errors.New("the file system notifications were closed")
+			}
+		case err := <-w.notify.Errors:
+			// the lost notifications are found by comparing the tree
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				return err
+			}
+		}
+	}
+	timer := time.NewTimer(w.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return nil
+		case _, ok := <-w.notify.Events:
+			if !ok {
+				return errors.New("the file system notifications were closed")
+			}
+			timer.Reset(w.interval)
+		case err := <-w.notify.Errors:
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				return err
+			}
+			timer.Reset(w.interval)
+		}
+	}
+}
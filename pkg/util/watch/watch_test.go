@@ -0,0 +1,106 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("app.rb", "puts 1")
+	write("lib/util.rb", "")
+	write("log/dev.log", "")
+	write(".git/HEAD", "ref: refs/heads/main")
+
+	ignored := func(path string, isDir bool) bool {
+		return strings.HasPrefix(path, "log")
+	}
+	w, err := New(dir, time.Millisecond, ignored)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed, err := w.Changed(); err != nil || len(changed) != 0 {
+		t.Errorf("expected no changes, got %v (%v)", changed, err)
+	}
+
+	write("app.rb", "puts 12")
+	write("lib/new.rb", "")
+	write("log/dev.log", "started")
+	write(".git/HEAD", "ref: refs/heads/dev")
+	if err := os.Remove(filepath.Join(dir, "lib", "util.rb")); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := w.Changed()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"app.rb", "lib/new.rb", "lib/util.rb"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v, got %v", expected, changed)
+	}
+	if changed, err := w.Changed(); err != nil || len(changed) != 0 {
+		t.Errorf("expected no changes, got %v (%v)", changed, err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(dir, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+	go func() {
+		for _, name := range []string{"a", "b"} {
+			os.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+		}
+	}()
+	changed, err := w.Wait()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []string{"a", "b"}) {
+		t.Errorf("expected [a b], got %v", changed)
+	}
+
+	// the directories created since are watched as well
+	go os.Mkdir(filepath.Join(dir, "lib"), 0755)
+	if changed, err := w.Wait(); err != nil || !reflect.DeepEqual(changed, []string{"lib"}) {
+		t.Errorf("expected [lib], got %v (%v)", changed, err)
+	}
+	go os.WriteFile(filepath.Join(dir, "lib", "c"), []byte("c"), 0644)
+	if changed, err := w.Wait(); err != nil || !reflect.DeepEqual(changed, []string{"lib/c"}) {
+		t.Errorf("expected [lib/c], got %v (%v)", changed, err)
+	}
+}
+
+func TestWaitPolling(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(dir, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// without file system notifications, the tree is polled
+	w.Close()
+	go os.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644)
+	changed, err := w.Wait()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []string{"a"}) {
+		t.Errorf("expected [a], got %v", changed)
+	}
+}
@@ -1,5 +1,10 @@
 package version
 
+import (
+	"runtime"
+	"runtime/debug"
+)
+
 var (
 	// commitFromGit is a constant representing the source version that
 	// generated this build. It should be set during build via -ldflags.
@@ -11,6 +16,9 @@ var (
 	majorFromGit string
 	// minor version
 	minorFromGit string
+	// buildDate is the date of the build in the RFC 3339 format. It should be
+	// set during build via -ldflags.
+	buildDate string
 )
 
 // Info contains versioning information.
@@ -19,17 +27,35 @@ type Info struct {
 	Minor      string `json:"minor"`
 	GitCommit  string `json:"gitCommit"`
 	GitVersion string `json:"gitVersion"`
+	BuildDate  string `json:"buildDate"`
+	GoVersion  string `json:"goVersion"`
+	Platform   string `json:"platform"`
 }
 
 // Get returns the overall codebase version. It's for detecting what code a
 // binary was built from.
 func Get() Info {
-	return Info{
+	info := Info{
 		Major:      majorFromGit,
 		Minor:      minorFromGit,
 		GitCommit:  commitFromGit,
 		GitVersion: versionFromGit,
+		BuildDate:  buildDate,
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	// binaries built without the -ldflags, e.g. by go install, still record
+	// the commit they were built from
+	if len(info.GitCommit) == 0 {
+		if buildInfo, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range buildInfo.Settings {
+				if setting.Key == "vcs.revision" {
+					info.GitCommit = setting.Value
+				}
+			}
+		}
 	}
+	return info
 }
 
 // String returns info as a human-friendly version string.
@@ -303,6 +303,7 @@ func (i *integrationTest) exerciseCleanAllowedUIDsBuild(tag, imageName string, e
 		Incremental:       false,
 		ScriptsURL:        "",
 		ExcludeRegExp:     tar.DefaultExclusionPattern.String(),
+		AllowOnBuild:      true,
 	}
 	config.AllowedUIDs.Set("1-")
 	_, _, err := strategies.Strategy(engineClient, config, build.Overrides{})
@@ -316,7 +317,7 @@ func (i *integrationTest) exerciseCleanAllowedUIDsBuild(tag, imageName string, e
 
 func (i *integrationTest) exerciseCleanBuild(tag string, verifyCallback bool, imageName string, scriptsURL string, expectImageName bool, setTag bool, checkLabel bool) {
 	t := i.t
-	callbackURL := ""
+	var callbackURLs []string
 	callbackInvoked := false
 	callbackHasValidJSON := false
 	if verifyCallback {
@@ -341,7 +342,7 @@ func (i *integrationTest) exerciseCleanBuild(tag string, verifyCallback bool, im
 		}
 		ts := httptest.NewServer(http.HandlerFunc(handler))
 		defer ts.Close()
-		callbackURL = ts.URL
+		callbackURLs = []string{ts.URL}
 	}
 
 	var buildTag string
@@ -358,9 +359,10 @@ func (i *integrationTest) exerciseCleanBuild(tag string, verifyCallback bool, im
 		Source:            git.MustParse(TestSource),
 		Tag:               buildTag,
 		Incremental:       false,
-		CallbackURL:       callbackURL,
+		CallbackURLs:      callbackURLs,
 		ScriptsURL:        scriptsURL,
 		ExcludeRegExp:     tar.DefaultExclusionPattern.String(),
+		AllowOnBuild:      true,
 	}
 
 	b, _, err := strategies.Strategy(engineClient, config, build.Overrides{})
@@ -522,6 +524,7 @@ func (i *integrationTest) exerciseIncrementalBuild(tag, imageName string, remove
 		Incremental:         false,
 		RemovePreviousImage: removePreviousImage,
 		ExcludeRegExp:       tar.DefaultExclusionPattern.String(),
+		AllowOnBuild:        true,
 	}
 
 	builder, _, err := strategies.Strategy(engineClient, config, build.Overrides{})
@@ -50,8 +50,7 @@ func TestDockerfileBuild(t *testing.T) {
 		"\"io.openshift.s2i.build.source-location\"",
 		"\"io.openshift.s2i.build.image\"=\"docker.io/centos/nodejs-8-centos7\"",
 		"\"io.openshift.s2i.build.commit.author\"",
-		"(?m)^COPY upload/src /tmp/src",
-		"(?m)^RUN chown -R 1001:0.* /tmp/src",
+		"(?m)^COPY --chown=1001:0 upload/src /tmp/src",
 		// Ensure we are using the default image user when running assemble
 		"(?m)^USER 1001\n.+\n.+\nRUN /usr/libexec/s2i/assemble",
 		"(?m)^CMD /usr/libexec/s2i/run",
@@ -93,8 +92,7 @@ func TestDockerfileBuildDefaultDockerfile(t *testing.T) {
 		"\"io.openshift.s2i.build.source-location\"",
 		"\"io.openshift.s2i.build.image\"=\"docker.io/centos/nodejs-8-centos7\"",
 		"\"io.openshift.s2i.build.commit.author\"",
-		"(?m)^COPY upload/src /tmp/src",
-		"(?m)^RUN chown -R 1001:0.* /tmp/src",
+		"(?m)^COPY --chown=1001:0 upload/src /tmp/src",
 		"(?m)^RUN /usr/libexec/s2i/assemble",
 		"(?m)^CMD /usr/libexec/s2i/run",
 	}
@@ -244,10 +242,8 @@ func TestDockerfileBuildInjections(t *testing.T) {
 	trimmedInjection2 := filepath.ToSlash(strings.TrimPrefix(injection2, filepath.VolumeName(injection2)))
 
 	expected := []string{
-		"(?m)^COPY upload/injections" + trimmedInjection1 + " /workdir/injection1",
-		"(?m)^RUN chown -R 1001:0.* /workdir/injection1",
-		"(?m)^COPY upload/injections" + trimmedInjection2 + " /destination/injection2",
-		"(?m)^RUN chown -R 1001:0.* /destination/injection2",
+		"(?m)^COPY --chown=1001:0 upload/injections" + trimmedInjection1 + " /workdir/injection1",
+		"(?m)^COPY --chown=1001:0 upload/injections" + trimmedInjection2 + " /destination/injection2",
 		"(?m)^RUN rm /workdir/injection1/injectfile-",
 		"    rm /workdir/injection1/injectfile-",
 	}
@@ -290,8 +286,7 @@ func TestDockerfileBuildScriptsURLAssemble(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /destination/scripts/assemble",
 		"(?m)^CMD /usr/libexec/s2i/run",
 	}
@@ -330,8 +325,7 @@ func TestDockerfileBuildScriptsURLRun(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /usr/libexec/s2i/assemble",
 		"(?m)^CMD /destination/scripts/run",
 	}
@@ -402,8 +396,7 @@ func TestDockerfileBuildSourceScriptsAssemble(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /destination/scripts/assemble",
 		"(?m)^CMD /usr/libexec/s2i/run",
 	}
@@ -449,8 +442,7 @@ func TestDockerfileBuildSourceScriptsRun(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /usr/libexec/s2i/assemble",
 		"(?m)^CMD /destination/scripts/run",
 	}
@@ -504,8 +496,7 @@ func TestDockerfileBuildScriptsURLImage(t *testing.T) {
 		"(?m)^CMD /usr/custom/s2i/run",
 	}
 	notExpected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /destination/scripts/assemble",
 	}
 	runDockerfileTest(t, config, expected, notExpected, nil, false)
@@ -539,8 +530,7 @@ func TestDockerfileBuildImageScriptsURLAssemble(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /destination/scripts/assemble",
 		"(?m)^CMD /usr/libexec/s2i/run",
 	}
@@ -579,8 +569,7 @@ func TestDockerfileBuildImageScriptsURLRun(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /usr/libexec/s2i/assemble",
 		"(?m)^CMD /destination/scripts/run",
 	}
@@ -627,8 +616,7 @@ func TestDockerfileBuildImageScriptsURLImage(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /destination/scripts/assemble",
 		"(?m)^CMD /usr/custom/s2i/run",
 	}
@@ -667,8 +655,7 @@ func TestDockerfileBuildScriptsAndImageURL(t *testing.T) {
 		AsDockerfile: filepath.Join(tempdir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /destination/scripts/assemble",
 		"(?m)^CMD /usr/some/dir/run",
 	}
@@ -730,8 +717,7 @@ func TestDockerfileBuildScriptsAndImageURLConflicts(t *testing.T) {
 		AsDockerfile: filepath.Join(outputDir, "Dockerfile"),
 	}
 	expected := []string{
-		"(?m)^COPY upload/scripts /destination/scripts",
-		"(?m)^RUN chown -R 1001:0.* /destination/scripts",
+		"(?m)^COPY --chown=1001:0 upload/scripts /destination/scripts",
 		"(?m)^RUN /destination/scripts/assemble",
 		"(?m)^CMD /usr/libexec/s2i/run",
 	}
@@ -774,15 +760,14 @@ func TestDockerfileIncrementalBuild(t *testing.T) {
 	}
 
 	expected := []string{
-		"(?m)^FROM test:tag as cached\n#.+\nUSER 1001",
+		"(?m)^ARG S2I_INCREMENTAL=true\nFROM test:tag as cached-true\n#.+\nUSER 1001",
 		"(?m)^RUN if \\[ -s /usr/libexec/s2i/save-artifacts \\]; then /usr/libexec/s2i/save-artifacts > /tmp/artifacts.tar; else touch /tmp/artifacts.tar; fi",
+		"(?m)^FROM docker.io/centos/nodejs-8-centos7 as cached-false\nRUN touch /tmp/artifacts.tar\nFROM cached-\\${S2I_INCREMENTAL} as cached\n",
 		"(?m)^FROM docker.io/centos/nodejs-8-centos7",
-		"(?m)^COPY --from=cached /tmp/artifacts.tar /tmp/artifacts.tar",
-		"(?m)^RUN chown -R 1001:0.* /tmp/artifacts.tar",
+		"(?m)^COPY --from=cached --chown=1001:0 /tmp/artifacts.tar /tmp/artifacts.tar",
 		"if \\[ -s /tmp/artifacts.tar \\]; then mkdir -p /tmp/artifacts; tar -xf /tmp/artifacts.tar -C /tmp/artifacts; fi",
 		"rm /tmp/artifacts.tar",
-		"(?m)^COPY upload/src /tmp/src",
-		"(?m)^RUN chown -R 1001:0.* /tmp/src",
+		"(?m)^COPY --chown=1001:0 upload/src /tmp/src",
 		"(?m)^RUN /usr/libexec/s2i/assemble",
 		"(?m)^CMD /usr/libexec/s2i/run",
 	}
@@ -828,9 +813,7 @@ func TestDockerfileIncrementalSourceSave(t *testing.T) {
 	}
 
 	expected := []string{
-		"(?m)^FROM test:tag as cached\n#.+\nUSER root\n",
-		"(?m)^COPY upload/scripts/save-artifacts /destination/scripts/save-artifacts",
-		"(?m)^RUN chown .*1001:0 /destination/scripts/save-artifacts",
+		"(?m)^FROM test:tag as cached-true\n#.+\nCOPY --chown=1001:0 upload/scripts/save-artifacts /destination/scripts/save-artifacts\n",
 		"(?m)^USER 1001\nRUN if \\[ -s /destination/scripts/save-artifacts \\]; then /destination/scripts/save-artifacts > /tmp/artifacts.tar;",
 		"(?m)^FROM docker.io/centos/nodejs-8-centos7",
 		"mkdir -p /destination/artifacts",
@@ -876,9 +859,7 @@ func TestDockerfileIncrementalSaveURL(t *testing.T) {
 	}
 
 	expected := []string{
-		"(?m)^FROM test:tag as cached\n#.+\nUSER root\n",
-		"(?m)^COPY upload/scripts/save-artifacts /destination/scripts/save-artifacts",
-		"(?m)^RUN chown 1001:0 /destination/scripts/save-artifacts",
+		"(?m)^FROM test:tag as cached-true\n#.+\nCOPY --chown=1001:0 upload/scripts/save-artifacts /destination/scripts/save-artifacts\n",
 		"(?m)^USER 1001\nRUN if \\[ -s /destination/scripts/save-artifacts \\]; then /destination/scripts/save-artifacts > /tmp/artifacts.tar;",
 		"(?m)^FROM docker.io/centos/nodejs-8-centos7",
 		"mkdir -p /destination/artifacts",
@@ -916,7 +897,7 @@ func TestDockerfileIncrementalTag(t *testing.T) {
 	}
 
 	expected := []string{
-		"(?m)^FROM incremental:tag as cached",
+		"(?m)^FROM incremental:tag as cached-true",
 		"/usr/libexec/s2i/save-artifacts > /tmp/artifacts.tar",
 		"(?m)^FROM docker.io/centos/nodejs-8-centos7",
 		"mkdir -p /tmp/artifacts",
@@ -950,11 +931,10 @@ func TestDockerfileIncrementalAssembleUser(t *testing.T) {
 	}
 
 	expected := []string{
-		"(?m)^FROM test:tag as cached\n#.+\nUSER 2250",
+		"(?m)^FROM test:tag as cached-true\n#.+\nUSER 2250",
 		"/usr/libexec/s2i/save-artifacts > /tmp/artifacts.tar",
 		"(?m)^FROM docker.io/centos/nodejs-8-centos7",
-		"(?m)^COPY --from=cached /tmp/artifacts.tar /tmp/artifacts.tar",
-		"(?m)^RUN chown -R 2250:0 .*/tmp/artifacts.tar",
+		"(?m)^COPY --from=cached --chown=2250:0 /tmp/artifacts.tar /tmp/artifacts.tar",
 		"mkdir -p /tmp/artifacts",
 		"tar -xf /tmp/artifacts.tar -C /tmp/artifacts",
 		"rm /tmp/artifacts.tar",